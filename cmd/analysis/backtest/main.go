@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/analyzer"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+func main() {
+	var (
+		input       = flag.String("input", "", "Path to historical mNAV JSON file (default: most recent MSTR file)")
+		tablePath   = flag.String("table", "", "Rebalancing table CSV or JSON (default: configs/rebalancing/rebalancing_table.csv)")
+		bitcoinLeg  = flag.String("bitcoin", "fbtc", "Bitcoin leg: fbtc (FBTC closes, BTC before FBTC listed) or btc")
+		capital     = flag.Float64("capital", 100000, "Initial capital")
+		tolerance   = flag.Float64("tolerance", 0.05, "Tolerance band around the target ratio (0.05 = ±5%; default: the table's tolerance)")
+		costBps     = flag.Float64("cost-bps", 0, "Trade cost per side in basis points")
+		slippageBps = flag.Float64("slippage-bps", 0, "Slippage per side in basis points")
+		frequency   = flag.String("frequency", "daily", "Rebalance frequency: daily, weekly or monthly")
+		outputDir   = flag.String("output", "data/analysis/backtest", "Output directory for reports")
+		format      = flag.String("format", "all", "Output format: json, html or all")
+	)
+	flag.Parse()
+
+	fmt.Printf("🧪 mNAV REBALANCING BACKTEST\n")
+	fmt.Printf("============================\n\n")
+
+	if *format != "json" && *format != "html" && *format != "all" {
+		log.Fatalf("❌ Unknown format: %s", *format)
+	}
+
+	if *input == "" {
+		files, err := filepath.Glob("data/analysis/mnav/MSTR_mnav_historical_*.json")
+		if err != nil || len(files) == 0 {
+			log.Fatalf("❌ No input file specified and no MSTR historical mNAV files found (run mnav-historical first)")
+		}
+		sort.Strings(files)
+		*input = files[len(files)-1]
+		fmt.Printf("📂 Using most recent file: %s\n", *input)
+	}
+
+	points, symbol, err := analyzer.LoadBacktestPoints(*input)
+	if err != nil {
+		log.Fatalf("❌ Error loading mNAV data: %v", err)
+	}
+	fmt.Printf("✅ Loaded %d data points for %s\n", len(points), symbol)
+
+	switch strings.ToLower(*bitcoinLeg) {
+	case "btc":
+	case "fbtc":
+		replaced, err := analyzer.UseFBTCPrices(points, repository.NewJSONStore(".").Prices())
+		if err != nil {
+			log.Fatalf("❌ Error loading FBTC prices: %v", err)
+		}
+		fmt.Printf("📈 Using FBTC closes for %d of %d days (scaled BTC on other days)\n", replaced, len(points))
+	default:
+		log.Fatalf("❌ Unknown bitcoin leg: %s (want fbtc or btc)", *bitcoinLeg)
+	}
+
+	table, err := loadTable(*tablePath)
+	if err != nil {
+		log.Fatalf("❌ Error loading rebalancing table: %v", err)
+	}
+
+	if !flagSet("tolerance") {
+		*tolerance = table.Tolerance()
+	}
+
+	freq, err := analyzer.ParseRebalanceFrequency(*frequency)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	opts := analyzer.BacktestOptions{
+		InitialCapital: *capital,
+		Tolerance:      *tolerance,
+		TradeCostBps:   *costBps,
+		SlippageBps:    *slippageBps,
+		Frequency:      freq,
+	}
+
+	result, err := table.Backtest(points, opts)
+	if err != nil {
+		log.Fatalf("❌ Backtest failed: %v", err)
+	}
+	printSummary(result, strings.ToUpper(*bitcoinLeg), symbol)
+
+	report := Report{
+		Symbol:      symbol,
+		Input:       *input,
+		BitcoinLeg:  strings.ToUpper(*bitcoinLeg),
+		Table:       table.GetConfigSummary(),
+		GeneratedAt: time.Now(),
+		Result:      result,
+	}
+
+	if *format == "json" || *format == "all" {
+		if err := saveJSON(report, *outputDir); err != nil {
+			log.Fatalf("❌ Error saving JSON report: %v", err)
+		}
+	}
+	if *format == "html" || *format == "all" {
+		if err := saveHTML(report, *outputDir); err != nil {
+			log.Fatalf("❌ Error saving HTML report: %v", err)
+		}
+	}
+	fmt.Printf("\n✅ Backtest complete!\n")
+}
+
+// Report is the saved backtest output
+type Report struct {
+	Symbol      string                   `json:"symbol"`
+	Input       string                   `json:"input"`
+	BitcoinLeg  string                   `json:"bitcoin_leg"`
+	Table       string                   `json:"table"`
+	GeneratedAt time.Time                `json:"generated_at"`
+	Result      *analyzer.BacktestResult `json:"result"`
+}
+
+// flagSet reports whether a flag was passed on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// loadTable loads the rule table from a file, or the default configs/rebalancing location
+func loadTable(path string) (*analyzer.DynamicRebalancingTable, error) {
+	if path == "" {
+		return analyzer.NewDynamicRebalancingTable()
+	}
+	rebalanceConfig, err := config.LoadRebalancingConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return analyzer.NewDynamicRebalancingTableFromConfig(rebalanceConfig)
+}
+
+func printSummary(r *analyzer.BacktestResult, bitcoinLeg, symbol string) {
+	fmt.Printf("\n📅 Period: %s to %s (%d days", r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"), r.Days)
+	if r.SkippedDays > 0 {
+		fmt.Printf(", %d skipped without prices or mNAV", r.SkippedDays)
+	}
+	fmt.Printf(")\n")
+	fmt.Printf("⚙️  Tolerance ±%.1f%%, %s checks, costs %.1f bps + slippage %.1f bps per side\n\n",
+		r.Options.Tolerance*100, r.Options.Frequency, r.Options.TradeCostBps, r.Options.SlippageBps)
+
+	fmt.Printf("%-12s %14s %10s %10s %10s\n", "Strategy", "Final Value", "Return", "CAGR", "Max DD")
+	fmt.Printf("%s\n", strings.Repeat("-", 60))
+	for _, row := range []struct {
+		name  string
+		stats analyzer.BacktestStats
+	}{
+		{"Rule table", r.Strategy},
+		{"HODL " + bitcoinLeg, r.HODLBitcoin},
+		{"HODL " + symbol, r.HODLMSTR},
+	} {
+		fmt.Printf("%-12s %14s %9.1f%% %9.1f%% %9.1f%%\n", row.name, fmt.Sprintf("$%.2f", row.stats.FinalValue),
+			row.stats.TotalReturnPct, row.stats.CAGRPct, row.stats.MaxDrawdownPct)
+	}
+
+	fmt.Printf("\n💱 Trading:\n")
+	fmt.Printf("   Rebalances: %d\n", r.TradeCount)
+	fmt.Printf("   Traded Value: $%.2f (turnover %.2fx, %.2fx per year)\n", r.TradedValue, r.Turnover, r.AnnualizedTurnover)
+	fmt.Printf("   Fees: $%.2f   Slippage: $%.2f\n", r.TotalFees, r.TotalSlippage)
+}
+
+func saveJSON(report Report, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(outputDir, fmt.Sprintf("%s_backtest_%s.json", report.Symbol, time.Now().Format("2006-01-02")))
+	if err := storage.WriteFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("💾 JSON report saved to: %s\n", path)
+	return nil
+}
+
+func saveHTML(report Report, outputDir string) error {
+	r := report.Result
+	labels := make([]string, len(r.Curve))
+	equity := make([]float64, len(r.Curve))
+	hodlBitcoin := make([]float64, len(r.Curve))
+	hodlMSTR := make([]float64, len(r.Curve))
+	for i, d := range r.Curve {
+		labels[i] = d.Date.Format("2006-01-02")
+		equity[i] = d.Equity
+		hodlBitcoin[i] = d.HODLBitcoin
+		hodlMSTR[i] = d.HODLMSTR
+	}
+	datasets, err := json.Marshal([]map[string]interface{}{
+		{"label": "Rule table", "data": equity, "borderColor": "rgb(75, 192, 192)", "pointRadius": 0, "fill": false},
+		{"label": "HODL " + report.BitcoinLeg, "data": hodlBitcoin, "borderColor": "rgb(247, 147, 26)", "pointRadius": 0, "fill": false},
+		{"label": "HODL " + report.Symbol, "data": hodlMSTR, "borderColor": "rgb(255, 99, 132)", "pointRadius": 0, "fill": false},
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("backtest").Funcs(template.FuncMap{
+		"mul100": func(v float64) float64 { return v * 100 },
+		"add":    func(a, b float64) float64 { return a + b },
+	}).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(outputDir, fmt.Sprintf("%s_backtest_%s.html", report.Symbol, time.Now().Format("2006-01-02")))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, struct {
+		Report
+		Labels       []string
+		DatasetsJSON template.JS
+		Rows         []struct {
+			Name  string
+			Stats analyzer.BacktestStats
+		}
+	}{
+		Report:       report,
+		Labels:       labels,
+		DatasetsJSON: template.JS(datasets),
+		Rows: []struct {
+			Name  string
+			Stats analyzer.BacktestStats
+		}{
+			{"Rule table", r.Strategy},
+			{"HODL " + report.BitcoinLeg, r.HODLBitcoin},
+			{"HODL " + report.Symbol, r.HODLMSTR},
+		},
+	}); err != nil {
+		return err
+	}
+
+	fmt.Printf("💾 HTML report saved to: %s\n", path)
+	return nil
+}
+
+// HTML template for the backtest report
+const reportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.Symbol}} Rebalancing Backtest</title>
+    <script src="https://cdn.jsdelivr.net/npm/chart.js"></script>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 20px;
+            background-color: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 0 auto;
+            background-color: white;
+            padding: 20px;
+            border-radius: 10px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        h1, h2 {
+            text-align: center;
+            color: #333;
+        }
+        .chart-container {
+            position: relative;
+            height: 500px;
+            margin-top: 20px;
+        }
+        table {
+            margin: 20px auto;
+            border-collapse: collapse;
+            font-size: 14px;
+        }
+        th, td {
+            padding: 6px 14px;
+            border-bottom: 1px solid #eee;
+            text-align: right;
+        }
+        th:first-child, td:first-child {
+            text-align: left;
+        }
+        .info {
+            text-align: center;
+            color: #666;
+            margin-top: 10px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>{{.Symbol}} Rebalancing Backtest ({{.Result.StartDate.Format "2006-01-02"}} to {{.Result.EndDate.Format "2006-01-02"}})</h1>
+        <div class="info">
+            Tolerance ±{{printf "%.1f" (mul100 .Result.Options.Tolerance)}}%, {{.Result.Options.Frequency}} checks,
+            costs {{printf "%.1f" .Result.Options.TradeCostBps}} bps + slippage {{printf "%.1f" .Result.Options.SlippageBps}} bps per side
+        </div>
+        <div class="chart-container">
+            <canvas id="equityChart"></canvas>
+        </div>
+        <table>
+            <tr><th>Strategy</th><th>Final Value</th><th>Return</th><th>CAGR</th><th>Max Drawdown</th></tr>
+            {{range .Rows}}<tr><td>{{.Name}}</td><td>${{printf "%.2f" .Stats.FinalValue}}</td><td>{{printf "%.1f" .Stats.TotalReturnPct}}%</td><td>{{printf "%.1f" .Stats.CAGRPct}}%</td><td>{{printf "%.1f" .Stats.MaxDrawdownPct}}%</td></tr>
+            {{end}}
+        </table>
+        <div class="info">
+            {{.Result.TradeCount}} rebalances, ${{printf "%.2f" .Result.TradedValue}} traded
+            (turnover {{printf "%.2f" .Result.Turnover}}x, {{printf "%.2f" .Result.AnnualizedTurnover}}x per year),
+            fees ${{printf "%.2f" .Result.TotalFees}}, slippage ${{printf "%.2f" .Result.TotalSlippage}}
+        </div>
+        {{if .Result.Trades}}<h2>Rebalances</h2>
+        <table>
+            <tr><th>Date</th><th>mNAV</th><th>From</th><th>To</th><th>{{.BitcoinLeg}}</th><th>{{.Symbol}}</th><th>Costs</th></tr>
+            {{range .Result.Trades}}<tr><td>{{.Date.Format "2006-01-02"}}</td><td>{{printf "%.2f" .MNAV}}</td><td>{{printf "%.2f" .FromRatio}}:1</td><td>{{printf "%.2f" .TargetRatio}}:1</td><td>{{printf "%.2f" .BitcoinValue}}</td><td>{{printf "%.2f" .MSTRValue}}</td><td>{{printf "%.2f" (add .Fees .Slippage)}}</td></tr>
+            {{end}}
+        </table>{{end}}
+        <div class="info">
+            Generated: {{.GeneratedAt.Format "2006-01-02 15:04:05"}}
+        </div>
+    </div>
+
+    <script>
+        new Chart(document.getElementById('equityChart').getContext('2d'), {
+            type: 'line',
+            data: {
+                labels: {{.Labels}},
+                datasets: {{.DatasetsJSON}}
+            },
+            options: {
+                responsive: true,
+                maintainAspectRatio: false,
+                interaction: {
+                    mode: 'index',
+                    intersect: false,
+                },
+                plugins: {
+                    tooltip: {
+                        callbacks: {
+                            label: function(context) {
+                                return context.dataset.label + ': $' + context.parsed.y.toFixed(2);
+                            }
+                        }
+                    }
+                },
+                scales: {
+                    y: {
+                        type: 'logarithmic',
+                        title: {
+                            display: true,
+                            text: 'Portfolio Value ($)'
+                        }
+                    }
+                }
+            }
+        });
+    </script>
+</body>
+</html>`
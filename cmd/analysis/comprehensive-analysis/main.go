@@ -13,6 +13,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // BitcoinTransaction represents a Bitcoin transaction from parsing
@@ -618,7 +620,9 @@ func saveComprehensiveReport(outputDir string, transactions []BitcoinTransaction
 		return
 	}
 
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	}); err != nil {
 		log.Printf("❌ Error writing report: %v", err)
 		return
 	}
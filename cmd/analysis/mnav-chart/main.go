@@ -1,14 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
+	"image/color"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/chart"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // ChartData represents the data structure for the chart
@@ -63,6 +73,35 @@ const chartTemplate = `<!DOCTYPE html>
             color: #666;
             margin-top: 10px;
         }
+        .event-card {
+            display: none;
+            position: absolute;
+            pointer-events: none;
+            max-width: 320px;
+            background-color: white;
+            border: 1px solid #ccc;
+            border-radius: 6px;
+            padding: 8px 10px;
+            font-size: 12px;
+            color: #333;
+            box-shadow: 0 2px 6px rgba(0,0,0,0.15);
+        }
+        .event-card hr {
+            border: none;
+            border-top: 1px solid #eee;
+        }
+        .event-legend {
+            text-align: center;
+            color: #666;
+            font-size: 12px;
+            margin-top: 10px;
+        }
+        .event-legend span {
+            display: inline-block;
+            width: 10px;
+            height: 10px;
+            margin: 0 4px 0 12px;
+        }
     </style>
 </head>
 <body>
@@ -70,7 +109,11 @@ const chartTemplate = `<!DOCTYPE html>
         <h1>{{.Title}}</h1>
         <div class="chart-container">
             <canvas id="mnavChart"></canvas>
+            <div id="eventCard" class="event-card"></div>
         </div>
+        {{if .EventLegend}}<div class="event-legend">
+            Events:{{range .EventLegend}}<span style="background-color: {{.Color}}"></span>{{.Label}}{{end}}
+        </div>{{end}}
         <div class="info">
             Generated: {{.Generated.Format "2006-01-02 15:04:05"}}
         </div>
@@ -82,10 +125,75 @@ const chartTemplate = `<!DOCTYPE html>
             labels: {{.Labels}},
             datasets: {{.DatasetsJSON}}
         };
+        const lineage = {{.LineageJSON}};
+        const events = {{.EventsJSON}};
+
+        // Draws event markers and shaded ranges, and shows a hover card for the events under the cursor
+        const eventOverlay = {
+            id: 'eventOverlay',
+            beforeDatasetsDraw(chart) {
+                const {ctx, chartArea: area, scales: {x}} = chart;
+                ctx.save();
+                events.forEach(e => {
+                    const x0 = x.getPixelForValue(e.start);
+                    if (e.end !== null) {
+                        ctx.fillStyle = e.color;
+                        ctx.fillRect(x0, area.top, Math.max(x.getPixelForValue(e.end) - x0, 1), area.bottom - area.top);
+                        return;
+                    }
+                    ctx.strokeStyle = e.color;
+                    ctx.lineWidth = 1;
+                    ctx.beginPath();
+                    ctx.moveTo(x0, area.top);
+                    ctx.lineTo(x0, area.bottom);
+                    ctx.stroke();
+                    ctx.fillStyle = e.color;
+                    ctx.fillRect(x0 - 3, area.top - 3, 6, 6);
+                    if (e.label) {
+                        ctx.fillStyle = '#333';
+                        ctx.font = '11px Arial';
+                        ctx.fillText(e.label, x0 + 4, area.top + 12);
+                    }
+                });
+                ctx.restore();
+            },
+            afterEvent(chart, args) {
+                const ev = args.event;
+                const card = document.getElementById('eventCard');
+                const x = chart.scales.x;
+                const hits = ev.type !== 'mousemove' ? [] : events.filter(e => {
+                    const x0 = x.getPixelForValue(e.start);
+                    if (e.end !== null) {
+                        return ev.x >= x0 && ev.x <= x.getPixelForValue(e.end);
+                    }
+                    return Math.abs(ev.x - x0) <= 4;
+                });
+                if (!hits.length) {
+                    card.style.display = 'none';
+                    return;
+                }
+                card.replaceChildren();
+                hits.forEach((e, i) => {
+                    if (i > 0) {
+                        card.appendChild(document.createElement('hr'));
+                    }
+                    e.lines.forEach((line, j) => {
+                        const div = document.createElement('div');
+                        div.textContent = line;
+                        div.style.fontWeight = j === 0 ? 'bold' : 'normal';
+                        card.appendChild(div);
+                    });
+                });
+                card.style.left = Math.min(ev.x + 12, chart.width - 330) + 'px';
+                card.style.top = (ev.y + 12) + 'px';
+                card.style.display = 'block';
+            }
+        };
 
         new Chart(ctx, {
             type: 'line',
             data: chartData,
+            plugins: [eventOverlay],
             options: {
                 responsive: true,
                 maintainAspectRatio: false,
@@ -109,7 +217,7 @@ const chartTemplate = `<!DOCTYPE html>
                                 }
                                 if (context.parsed.y !== null) {
                                     if (label.includes('Price')) {
-                                        label += '$' + context.parsed.y.toFixed(2);
+                                        label += {{.CurrencySymbol}} + context.parsed.y.toFixed(2);
                                     } else if (label.includes('Premium')) {
                                         label += context.parsed.y.toFixed(1) + '%';
                                     } else {
@@ -117,6 +225,9 @@ const chartTemplate = `<!DOCTYPE html>
                                     }
                                 }
                                 return label;
+                            },
+                            afterBody: function(items) {
+                                return items.length ? (lineage[items[0].dataIndex] || []) : [];
                             }
                         }
                     }
@@ -161,7 +272,12 @@ func main() {
 	var (
 		input     = flag.String("input", "", "Path to historical mNAV JSON file")
 		outputDir = flag.String("output", "data/charts", "Output directory for chart files")
-		format    = flag.String("format", "html", "Output format: html, json, csv")
+		format    = flag.String("format", "html", "Output format: html, json, csv, svg, png")
+		lineage   = flag.String("lineage", "", "Optional lineage manifest (from csv-exporter) to show in tooltips")
+		logScale  = flag.Bool("log", false, "Use log scales for prices and holdings (svg, png)")
+		eventsDir = flag.String("events", "data/events", "Directory of user-editable {SYMBOL}.json event files (empty to skip)")
+		backend   = flag.String("backend", "json", "Company store for BTC purchase events: json, sqlite or none")
+		dbPath    = flag.String("db", "data/mnav.db", "SQLite database path when -backend=sqlite")
 	)
 	flag.Parse()
 
@@ -187,6 +303,28 @@ func main() {
 
 	fmt.Printf("✅ Loaded %d data points for %s\n", len(data.DataPoints), data.Symbol)
 
+	if *lineage != "" {
+		merged, err := mergeLineageManifest(data, *lineage)
+		if err != nil {
+			log.Fatalf("❌ Error loading lineage manifest: %v", err)
+		}
+		fmt.Printf("🧾 Added lineage for %d data points from %s\n", merged, *lineage)
+	}
+
+	events, err := loadEvents(data.Symbol, *backend, *dbPath, *eventsDir)
+	if err != nil {
+		fmt.Printf("⚠️  Could not load events: %v\n", err)
+	}
+	data.Events = models.MergeEvents(data.Events, events)
+	if len(data.DataPoints) > 0 {
+		first, _ := time.Parse("2006-01-02", data.DataPoints[0].Date)
+		last, _ := time.Parse("2006-01-02", data.DataPoints[len(data.DataPoints)-1].Date)
+		data.Events = models.EventsBetween(data.Events, first, last)
+	}
+	if len(data.Events) > 0 {
+		fmt.Printf("📌 Overlaying %d events\n", len(data.Events))
+	}
+
 	// Generate chart based on format
 	switch *format {
 	case "html":
@@ -201,6 +339,10 @@ func main() {
 		if err := generateCSVChart(data, *outputDir); err != nil {
 			log.Fatalf("❌ Error generating CSV: %v", err)
 		}
+	case "svg", "png":
+		if err := generateStaticCharts(data, *outputDir, chart.Format(*format), *logScale); err != nil {
+			log.Fatalf("❌ Error generating %s charts: %v", *format, err)
+		}
 	default:
 		log.Fatalf("❌ Unknown format: %s", *format)
 	}
@@ -215,6 +357,30 @@ type HistoricalMNAVData struct {
 	EndDate    string                 `json:"end_date"`
 	DataPoints []HistoricalMNAVPoint  `json:"data_points"`
 	Metadata   map[string]interface{} `json:"metadata"`
+	Events     []models.CompanyEvent  `json:"events,omitempty"`
+	Asset      string                 `json:"asset,omitempty"`    // Treasury asset in the bitcoin_* fields (default BTC)
+	Currency   string                 `json:"currency,omitempty"` // Currency of prices and values (default USD)
+}
+
+// asset returns the treasury asset symbol the dataset values
+func (d *HistoricalMNAVData) asset() string {
+	if d.Asset == "" {
+		return "BTC"
+	}
+	return strings.ToUpper(d.Asset)
+}
+
+// currencySymbol returns the display prefix for the dataset's prices and values
+func (d *HistoricalMNAVData) currencySymbol() string {
+	return models.CurrencySymbol(d.Currency)
+}
+
+// assetName returns the asset's display name, keeping "Bitcoin" for BTC
+func (d *HistoricalMNAVData) assetName() string {
+	if d.asset() == "BTC" {
+		return "Bitcoin"
+	}
+	return d.asset()
 }
 
 type HistoricalMNAVPoint struct {
@@ -228,6 +394,20 @@ type HistoricalMNAVPoint struct {
 	MNAV              float64 `json:"mnav"`
 	MNAVPerShare      float64 `json:"mnav_per_share"`
 	Premium           float64 `json:"premium_percentage"`
+
+	Lineage models.RowLineage `json:"lineage,omitempty"`
+}
+
+// tooltipLineageFields lists the lineage shown in chart tooltips, in display order
+var tooltipLineageFields = []struct {
+	field string
+	label string
+}{
+	{models.FieldStockPrice, "Stock price"},
+	{models.FieldBitcoinPrice, "BTC price"},
+	{models.FieldBitcoinHoldings, "BTC holdings"},
+	{models.FieldSharesOutstanding, "Shares"},
+	{models.FieldMNAV, "mNAV"},
 }
 
 func loadMNAVData(filepath string) (*HistoricalMNAVData, error) {
@@ -244,6 +424,188 @@ func loadMNAVData(filepath string) (*HistoricalMNAVData, error) {
 	return &mnavData, nil
 }
 
+// mergeLineageManifest adds lineage from a csv-exporter manifest to points that have none
+func mergeLineageManifest(data *HistoricalMNAVData, manifestPath string) (int, error) {
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var manifest models.LineageManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return 0, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	merged := 0
+	for i := range data.DataPoints {
+		point := &data.DataPoints[i]
+		row, ok := manifest.Rows[point.Date]
+		if !ok {
+			continue
+		}
+		if point.Lineage == nil {
+			point.Lineage = models.RowLineage{}
+		}
+		for field, lineage := range row {
+			if _, exists := point.Lineage[field]; !exists {
+				point.Lineage[field] = lineage
+			}
+		}
+		merged++
+	}
+	return merged, nil
+}
+
+// tooltipLineage formats each point's lineage as tooltip lines, naming the dataset's asset
+func tooltipLineage(data *HistoricalMNAVData) [][]string {
+	lines := make([][]string, len(data.DataPoints))
+	for i, dp := range data.DataPoints {
+		for _, f := range tooltipLineageFields {
+			if lineage, ok := dp.Lineage[f.field]; ok {
+				label := strings.Replace(f.label, "BTC", data.asset(), 1)
+				lines[i] = append(lines[i], fmt.Sprintf("%s: %s", label, lineage))
+			}
+		}
+	}
+	return lines
+}
+
+// loadEvents loads the company's event timeline from the company store and events file
+func loadEvents(symbol, backend, dbPath, eventsDir string) ([]models.CompanyEvent, error) {
+	var txs repository.TransactionRepository
+	switch backend {
+	case "none":
+	case repository.BackendSQLite:
+		store, err := repository.OpenSQLiteStore(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		txs = store.Transactions()
+	default:
+		store, err := repository.Open(backend, ".")
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		txs = store.Transactions()
+	}
+
+	var events *storage.EventStorage
+	if eventsDir != "" {
+		events = storage.NewEventStorage(eventsDir)
+	}
+	return repository.CompanyEvents(txs, events, symbol)
+}
+
+// eventColors assigns each event type a marker color
+var eventColors = map[models.EventType]color.RGBA{
+	models.EventBTCPurchase:         {247, 147, 26, 140},
+	models.EventEquityOffering:      {54, 162, 235, 255},
+	models.EventPreferredOffering:   {153, 102, 255, 255},
+	models.EventConvertibleIssuance: {201, 76, 76, 255},
+	models.EventEarnings:            {90, 90, 90, 255},
+	models.EventStockSplit:          {46, 160, 67, 255},
+	models.EventIndexInclusion:      {214, 168, 0, 255},
+	models.EventOther:               {150, 150, 150, 255},
+}
+
+// eventColor returns the marker color of an event, translucent for ranges
+func eventColor(e models.CompanyEvent) color.RGBA {
+	c, ok := eventColors[e.Type]
+	if !ok {
+		c = eventColors[models.EventOther]
+	}
+	if e.IsRange() {
+		c.A = 40
+	}
+	return c
+}
+
+// cssColor formats a chart color for the HTML template
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("rgba(%d, %d, %d, %.2f)", c.R, c.G, c.B, float64(c.A)/255)
+}
+
+// eventLabel returns the text drawn beside a marker. Purchases are frequent, so they
+// are left to the hover cards.
+func eventLabel(e models.CompanyEvent) string {
+	if e.Type == models.EventBTCPurchase {
+		return ""
+	}
+	if len(e.Title) > 28 {
+		return e.Title[:27] + "…"
+	}
+	return e.Title
+}
+
+// eventAnnotations converts events into chart annotations
+func eventAnnotations(events []models.CompanyEvent) []chart.Annotation {
+	annotations := make([]chart.Annotation, 0, len(events))
+	for _, e := range events {
+		annotations = append(annotations, chart.Annotation{
+			Start:   e.Date,
+			End:     e.EndDate,
+			Label:   eventLabel(e),
+			Group:   e.Type.Label(),
+			Details: e.Summary(),
+			Color:   eventColor(e),
+		})
+	}
+	return annotations
+}
+
+// htmlEvent is an event positioned on the HTML chart's category axis
+type htmlEvent struct {
+	Start int      `json:"start"`
+	End   *int     `json:"end"` // Last label index of a range; null for markers
+	Color string   `json:"color"`
+	Label string   `json:"label"`
+	Lines []string `json:"lines"`
+}
+
+// htmlEvents maps events onto label indexes: markers snap to the first label on or
+// after the event date, ranges end at the last label on or before their end date
+func htmlEvents(labels []string, events []models.CompanyEvent) []htmlEvent {
+	out := []htmlEvent{}
+	for _, e := range events {
+		start := sort.SearchStrings(labels, e.Date.Format("2006-01-02"))
+		if start >= len(labels) {
+			continue
+		}
+		he := htmlEvent{Start: start, Color: cssColor(eventColor(e)), Label: eventLabel(e), Lines: e.Summary()}
+		if e.IsRange() {
+			end := sort.SearchStrings(labels, e.EndDate.Format("2006-01-02"))
+			if end >= len(labels) || labels[end] != e.EndDate.Format("2006-01-02") {
+				end--
+			}
+			if end < start {
+				end = start
+			}
+			he.End = &end
+		}
+		out = append(out, he)
+	}
+	return out
+}
+
+// eventLegend lists the event types present with their colors
+func eventLegend(events []models.CompanyEvent) []struct{ Label, Color string } {
+	present := make(map[models.EventType]bool)
+	for _, e := range events {
+		present[e.Type] = true
+	}
+	var legend []struct{ Label, Color string }
+	for _, t := range models.EventTypes {
+		if present[t] {
+			c := eventColors[t]
+			c.A = 255
+			legend = append(legend, struct{ Label, Color string }{t.Label(), cssColor(c)})
+		}
+	}
+	return legend
+}
+
 func generateHTMLChart(data *HistoricalMNAVData, outputDir string) error {
 	// Prepare chart data
 	labels := make([]string, len(data.DataPoints))
@@ -285,6 +647,16 @@ func generateHTMLChart(data *HistoricalMNAVData, outputDir string) error {
 		return err
 	}
 
+	lineageJSON, err := json.Marshal(tooltipLineage(data))
+	if err != nil {
+		return err
+	}
+
+	eventsJSON, err := json.Marshal(htmlEvents(labels, data.Events))
+	if err != nil {
+		return err
+	}
+
 	// Create template
 	tmpl, err := template.New("chart").Parse(chartTemplate)
 	if err != nil {
@@ -309,10 +681,18 @@ func generateHTMLChart(data *HistoricalMNAVData, outputDir string) error {
 	// Execute template with additional data
 	templateData := struct {
 		ChartData
-		DatasetsJSON template.JS
+		DatasetsJSON   template.JS
+		LineageJSON    template.JS
+		EventsJSON     template.JS
+		EventLegend    []struct{ Label, Color string }
+		CurrencySymbol string
 	}{
-		ChartData:    chartData,
-		DatasetsJSON: template.JS(datasetsJSON),
+		ChartData:      chartData,
+		DatasetsJSON:   template.JS(datasetsJSON),
+		LineageJSON:    template.JS(lineageJSON),
+		EventsJSON:     template.JS(eventsJSON),
+		EventLegend:    eventLegend(data.Events),
+		CurrencySymbol: data.currencySymbol(),
 	}
 
 	if err := tmpl.Execute(file, templateData); err != nil {
@@ -342,11 +722,15 @@ func generateJSONChart(data *HistoricalMNAVData, outputDir string) error {
 	}
 
 	chartData["symbol"] = data.Symbol
+	chartData["asset"] = data.asset()
+	chartData["currency"] = models.CurrencyCode(data.Currency)
 	chartData["dates"] = dates
 	chartData["mnav"] = mnavs
 	chartData["premium_percentage"] = premiums
 	chartData["stock_price"] = stockPrices
 	chartData["bitcoin_price"] = btcPrices
+	chartData["lineage"] = tooltipLineage(data)
+	chartData["events"] = data.Events
 	chartData["metadata"] = map[string]interface{}{
 		"start_date": data.StartDate,
 		"end_date":   data.EndDate,
@@ -366,7 +750,9 @@ func generateJSONChart(data *HistoricalMNAVData, outputDir string) error {
 		return err
 	}
 
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	}); err != nil {
 		return err
 	}
 
@@ -376,7 +762,8 @@ func generateJSONChart(data *HistoricalMNAVData, outputDir string) error {
 
 func generateCSVChart(data *HistoricalMNAVData, outputDir string) error {
 	// Create CSV content
-	csv := "Date,Stock Price,Bitcoin Price,Bitcoin Holdings,Shares Outstanding,Market Cap,Bitcoin Value,mNAV,mNAV Per Share,Premium %\n"
+	name := data.assetName()
+	csv := fmt.Sprintf("Date,Stock Price,%s Price,%s Holdings,Shares Outstanding,Market Cap,%s Value,mNAV,mNAV Per Share,Premium %%\n", name, name, name)
 
 	for _, dp := range data.DataPoints {
 		csv += fmt.Sprintf("%s,%.2f,%.2f,%.2f,%.0f,%.2f,%.2f,%.4f,%.2f,%.2f\n",
@@ -401,10 +788,97 @@ func generateCSVChart(data *HistoricalMNAVData, outputDir string) error {
 	filename := fmt.Sprintf("%s_mnav_data_%s.csv", data.Symbol, time.Now().Format("2006-01-02"))
 	filepath := filepath.Join(outputDir, filename)
 
-	if err := os.WriteFile(filepath, []byte(csv), 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, []byte(csv), 0644)
+	}); err != nil {
 		return err
 	}
 
 	fmt.Printf("💾 CSV data saved to: %s\n", filepath)
 	return nil
 }
+
+// generateStaticCharts renders the mNAV/premium, treasury holdings and stock vs asset
+// price charts as SVG or PNG images
+func generateStaticCharts(data *HistoricalMNAVData, outputDir string, format chart.Format, logScale bool) error {
+	dates := make([]time.Time, 0, len(data.DataPoints))
+	var mnavs, premiums, holdings, stockPrices, btcPrices []float64
+	for _, dp := range data.DataPoints {
+		date, err := time.Parse("2006-01-02", dp.Date)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", dp.Date, err)
+		}
+		dates = append(dates, date)
+		mnavs = append(mnavs, missingAsNaN(dp.MNAV))
+		premiums = append(premiums, dp.Premium)
+		holdings = append(holdings, missingAsNaN(dp.BitcoinHoldings))
+		stockPrices = append(stockPrices, missingAsNaN(dp.StockPrice))
+		btcPrices = append(btcPrices, missingAsNaN(dp.BitcoinPrice))
+	}
+
+	annotations := eventAnnotations(data.Events)
+	asset, name := data.asset(), data.assetName()
+	percent := func(v float64) string { return chart.FormatCompact(v) + "%" }
+	money := func(v float64) string { return data.currencySymbol() + chart.FormatCompact(v) }
+
+	charts := map[string]*chart.Chart{
+		"mnav_chart": {
+			Title: fmt.Sprintf("%s mNAV and Premium", data.Symbol),
+			Dates: dates,
+			Series: []chart.Series{
+				{Name: "mNAV", Values: mnavs, Color: color.RGBA{75, 192, 192, 255}, Axis: chart.Left},
+				{Name: "Premium %", Values: premiums, Color: color.RGBA{255, 99, 132, 255}, Axis: chart.Right},
+			},
+			Annotations: annotations,
+			Left:        chart.YAxis{Label: "mNAV"},
+			Right:       chart.YAxis{Label: "Premium", Format: percent},
+		},
+		"holdings_chart": {
+			Title: fmt.Sprintf("%s %s Holdings", data.Symbol, name),
+			Dates: dates,
+			Series: []chart.Series{
+				{Name: asset + " Holdings", Values: holdings, Color: color.RGBA{247, 147, 26, 255}, Axis: chart.Left},
+			},
+			Annotations: annotations,
+			Left:        chart.YAxis{Label: asset, Log: logScale},
+		},
+		"stock_vs_btc_chart": {
+			Title: fmt.Sprintf("%s Stock Price vs %s Price", data.Symbol, name),
+			Dates: dates,
+			Series: []chart.Series{
+				{Name: data.Symbol, Values: stockPrices, Color: color.RGBA{54, 162, 235, 255}, Axis: chart.Left},
+				{Name: asset, Values: btcPrices, Color: color.RGBA{247, 147, 26, 255}, Axis: chart.Right},
+			},
+			Annotations: annotations,
+			Left:        chart.YAxis{Label: data.Symbol, Log: logScale, Format: money},
+			Right:       chart.YAxis{Label: asset, Log: logScale, Format: money},
+		},
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"mnav_chart", "holdings_chart", "stock_vs_btc_chart"} {
+		var buf bytes.Buffer
+		if err := charts[name].Render(&buf, format); err != nil {
+			return fmt.Errorf("failed to render %s: %w", name, err)
+		}
+
+		filename := fmt.Sprintf("%s_%s_%s.%s", data.Symbol, name, time.Now().Format("2006-01-02"), format)
+		filepath := filepath.Join(outputDir, filename)
+		if err := storage.WriteFileAtomic(filepath, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("💾 %s chart saved to: %s\n", format, filepath)
+	}
+	return nil
+}
+
+// missingAsNaN turns zero (missing) values into NaN so they render as gaps
+func missingAsNaN(v float64) float64 {
+	if v == 0 {
+		return math.NaN()
+	}
+	return v
+}
@@ -8,12 +8,16 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/alphavantage"
 	"github.com/ultrarare-tech/mNAV/pkg/collection/fmp"
+	"github.com/ultrarare-tech/mNAV/pkg/config"
 	"github.com/ultrarare-tech/mNAV/pkg/metrics"
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // SharesOutstanding represents shares outstanding at a point in time
@@ -24,7 +28,9 @@ type SharesOutstanding struct {
 	Source      string    `json:"source"`
 }
 
-// HistoricalMNAVPoint represents a single point in the mNAV time series
+// HistoricalMNAVPoint represents a single point in the mNAV time series. The bitcoin_*
+// fields hold the dataset's treasury asset, which is BTC unless HistoricalMNAVData.Asset
+// says otherwise.
 type HistoricalMNAVPoint struct {
 	Date              string  `json:"date"`
 	StockPrice        float64 `json:"stock_price"`
@@ -36,11 +42,24 @@ type HistoricalMNAVPoint struct {
 	MNAV              float64 `json:"mnav"`
 	MNAVPerShare      float64 `json:"mnav_per_share"`
 	Premium           float64 `json:"premium_percentage"`
+
+	// Where each input came from, keyed by models.Field* names
+	Lineage models.RowLineage `json:"lineage,omitempty"`
+}
+
+// inputLineage records where each input series was loaded from
+type inputLineage struct {
+	Stock    models.FieldLineage
+	Bitcoin  models.FieldLineage
+	Holdings models.FieldLineage
+	Shares   models.FieldLineage
 }
 
 // HistoricalMNAVData represents the complete historical mNAV dataset
 type HistoricalMNAVData struct {
 	Symbol      string                 `json:"symbol"`
+	Asset       string                 `json:"asset,omitempty"`    // Treasury asset valued (default BTC)
+	Currency    string                 `json:"currency,omitempty"` // Currency of prices and values (default USD)
 	StartDate   string                 `json:"start_date"`
 	EndDate     string                 `json:"end_date"`
 	DataPoints  []HistoricalMNAVPoint  `json:"data_points"`
@@ -57,6 +76,9 @@ func main() {
 		interval  = flag.String("interval", "daily", "Calculation interval: daily, weekly, monthly")
 		fmpAPIKey = flag.String("fmp-api-key", "", "Financial Modeling Prep API key (or set FMP_API_KEY env var)")
 		avAPIKey  = flag.String("av-api-key", "", "Alpha Vantage API key (or set ALPHA_VANTAGE_API_KEY env var)")
+		asOfKnown = flag.String("as-of-knowledge", "", "Only use company data known on this date (YYYY-MM-DD); records saved before knowledge tracking count from their own date")
+		assetFlag = flag.String("asset", "", "Treasury asset to value (default: the company's primary asset in data/companies.json, else BTC)")
+		currency  = flag.String("currency", "USD", "Currency for prices, market cap and NAV in the output")
 	)
 	flag.Parse()
 
@@ -83,8 +105,41 @@ func main() {
 		*endDate = time.Now().Format("2006-01-02")
 	}
 
+	// Nothing after the knowledge date was known on it
+	var knownAt time.Time
+	if *asOfKnown != "" {
+		var err error
+		if knownAt, err = models.ParseKnowledgeDate(*asOfKnown); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if *endDate > *asOfKnown {
+			*endDate = *asOfKnown
+		}
+	}
+
+	company := registryCompany(*symbol)
+	asset, registryAsset := resolveAsset(company, *assetFlag)
+	tradingCurrency := company.TradingCurrency()
+	*currency = models.CurrencyCode(*currency)
+
+	// Rates are only needed when the listing or the output is not in USD
+	var fx *models.FXTable
+	if tradingCurrency != models.USD || *currency != models.USD {
+		var err error
+		if fx, err = storage.NewFXStorage("data/fx").LoadTable(tradingCurrency, *currency); err != nil {
+			log.Fatalf("❌ Error loading FX rates: %v", err)
+		}
+	}
+
 	fmt.Printf("🏢 Symbol: %s\n", *symbol)
+	fmt.Printf("🪙 Asset: %s\n", asset)
+	if tradingCurrency != models.USD || *currency != models.USD {
+		fmt.Printf("💱 Trading currency: %s, output currency: %s\n", tradingCurrency, *currency)
+	}
 	fmt.Printf("📅 Period: %s to %s\n", *startDate, *endDate)
+	if !knownAt.IsZero() {
+		fmt.Printf("🕰️  As known on: %s\n", *asOfKnown)
+	}
 	fmt.Printf("⏱️  Interval: %s\n\n", *interval)
 
 	// Initialize API clients
@@ -94,19 +149,47 @@ func main() {
 	// Load required data
 	fmt.Printf("📂 Loading historical data...\n")
 
-	// 1. Load Bitcoin transactions
-	bitcoinTxs, err := loadBitcoinTransactions(*symbol)
-	if err != nil {
-		log.Fatalf("❌ Error loading Bitcoin transactions: %v", err)
-	}
-	fmt.Printf("   ✅ Loaded %d Bitcoin transactions\n", len(bitcoinTxs))
+	var sources inputLineage
 
-	// 2. Load shares outstanding from Alpha Vantage
-	sharesData, err := loadSharesFromAlphaVantage(avClient, *symbol)
-	if err != nil {
-		log.Fatalf("❌ Error loading shares data: %v", err)
+	// 1. Load holdings: Bitcoin follows SEC transactions, other assets the registry snapshot
+	var holdingsAt holdingsFunc
+	metadata := map[string]interface{}{}
+	if asset == "BTC" {
+		bitcoinTxs, holdingsLineage, err := loadBitcoinTransactions(*symbol, knownAt)
+		if err != nil {
+			log.Fatalf("❌ Error loading Bitcoin transactions: %v", err)
+		}
+		fmt.Printf("   ✅ Loaded %d Bitcoin transactions\n", len(bitcoinTxs))
+		sources.Holdings = holdingsLineage
+		holdingsAt = transactionHoldings(bitcoinTxs)
+		metadata["bitcoin_transactions_count"] = len(bitcoinTxs)
+	} else {
+		if registryAsset.Holdings <= 0 {
+			log.Fatalf("❌ No %s holdings for %s in data/companies.json", asset, *symbol)
+		}
+		fmt.Printf("   ✅ Loaded %s holdings from the company registry: %.2f\n", asset, registryAsset.Holdings)
+		sources.Holdings = models.FieldLineage{Source: "registry", File: "data/companies.json", Method: models.LineageLatestKnown, Filled: true}
+		holdingsAt = func(time.Time) (float64, string) { return registryAsset.Holdings, "" }
+	}
+
+	// 2. Load shares outstanding (Alpha Vantage only knows today's figure)
+	var sharesData float64
+	var err error
+	if !knownAt.IsZero() {
+		sharesData, err = loadKnownShares(*symbol, knownAt)
+		if err != nil {
+			log.Fatalf("❌ Error loading shares data known on %s: %v", *asOfKnown, err)
+		}
+		fmt.Printf("   ✅ Loaded shares outstanding known on %s: %.0f\n", *asOfKnown, sharesData)
+		sources.Shares = models.FieldLineage{Source: "sec", File: "data/edgar/companies", Method: models.LineageLatestKnown, Filled: true}
+	} else {
+		sharesData, err = loadSharesFromAlphaVantage(avClient, *symbol)
+		if err != nil {
+			log.Fatalf("❌ Error loading shares data: %v", err)
+		}
+		fmt.Printf("   ✅ Loaded current shares outstanding: %.0f\n", sharesData)
+		sources.Shares = models.FieldLineage{Source: "alphavantage", FetchedAt: time.Now(), Method: models.LineageLatestKnown, Filled: true}
 	}
-	fmt.Printf("   ✅ Loaded current shares outstanding: %.0f\n", sharesData)
 
 	// 3. Load historical stock prices from Financial Modeling Prep
 	stockPrices, err := loadHistoricalStockPricesFromFMP(fmpClient, *symbol, *startDate, *endDate)
@@ -114,20 +197,46 @@ func main() {
 		log.Fatalf("❌ Error loading stock prices: %v", err)
 	}
 	fmt.Printf("   ✅ Loaded %d stock price points\n", len(stockPrices))
+	sources.Stock = models.FieldLineage{Source: "fmp", FetchedAt: time.Now(), Method: models.LineageObserved}
+	if tradingCurrency != models.USD {
+		var skipped int
+		stockPrices, skipped = convertPriceMap(stockPrices, tradingCurrency, models.USD, fx)
+		fmt.Printf("   ✅ Converted stock prices from %s to USD at each day's rate\n", tradingCurrency)
+		if skipped > 0 {
+			fmt.Printf("   ⚠️  Skipped %d days without a %s/USD rate\n", skipped, tradingCurrency)
+		}
+	}
 
-	// 4. Load historical Bitcoin prices
-	btcPrices, err := loadHistoricalBitcoinPrices(*startDate, *endDate)
+	// 4. Load historical asset prices
+	var assetPrices map[string]float64
+	if asset == "BTC" {
+		assetPrices, sources.Bitcoin, err = loadHistoricalBitcoinPrices(*startDate, *endDate)
+	} else {
+		assetPrices, sources.Bitcoin, err = loadHistoricalAssetPrices(registryAsset.PriceSymbol(), *startDate, *endDate)
+	}
 	if err != nil {
-		log.Fatalf("❌ Error loading Bitcoin prices: %v", err)
+		log.Fatalf("❌ Error loading %s prices: %v", asset, err)
 	}
-	fmt.Printf("   ✅ Loaded %d Bitcoin price points\n", len(btcPrices))
+	fmt.Printf("   ✅ Loaded %d %s price points\n", len(assetPrices), asset)
 
 	// Calculate historical mNAV
 	fmt.Printf("\n📈 Calculating historical mNAV...\n")
-	mnavData := calculateHistoricalMNAV(*symbol, bitcoinTxs, sharesData, stockPrices, btcPrices, *startDate, *endDate, *interval)
+	mnavData := calculateHistoricalMNAV(*symbol, asset, holdingsAt, sharesData, stockPrices, assetPrices, sources, *startDate, *endDate, *interval)
+	for key, value := range metadata {
+		mnavData.Metadata[key] = value
+	}
+	if !knownAt.IsZero() {
+		mnavData.Metadata["as_of_knowledge"] = *asOfKnown
+	}
 
 	fmt.Printf("   ✅ Generated %d mNAV data points\n", len(mnavData.DataPoints))
 
+	if *currency != models.USD {
+		if err := convertMNAVData(mnavData, *currency, fx); err != nil {
+			log.Fatalf("❌ Error converting output to %s: %v", *currency, err)
+		}
+	}
+
 	// Save results
 	if err := saveMNAVData(mnavData, *outputDir); err != nil {
 		log.Fatalf("❌ Error saving mNAV data: %v", err)
@@ -137,14 +246,67 @@ func main() {
 	printSummary(mnavData)
 }
 
+// holdingsFunc returns the treasury holdings on a date and the date (YYYY-MM-DD) they
+// last changed, or "" when they come from a snapshot
+type holdingsFunc func(date time.Time) (float64, string)
+
+// registryCompany returns the company's registry entry, or just its symbol when the
+// registry is missing or does not list it
+func registryCompany(symbol string) config.CompanyData {
+	registry, err := config.LoadCompaniesConfig(".")
+	if err != nil {
+		return config.CompanyData{Symbol: symbol}
+	}
+	if company, ok := registry.GetCompanyBySymbol(symbol); ok {
+		return company
+	}
+	return config.CompanyData{Symbol: symbol}
+}
+
+// resolveAsset picks the asset to value: the flag, else the company's primary asset in
+// the registry, else BTC
+func resolveAsset(company config.CompanyData, flagAsset string) (string, config.TreasuryAsset) {
+	asset := strings.ToUpper(flagAsset)
+	if asset == "" {
+		primary, ok := company.PrimaryAsset()
+		if !ok {
+			return "BTC", config.TreasuryAsset{Symbol: "BTC"}
+		}
+		return strings.ToUpper(primary.Symbol), primary
+	}
+	if a, ok := company.Asset(asset); ok {
+		return asset, a
+	}
+	return asset, config.TreasuryAsset{Symbol: asset}
+}
+
+// transactionHoldings sums Bitcoin transactions up to each date
+func transactionHoldings(txs []models.BitcoinTransaction) holdingsFunc {
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Date.Before(txs[j].Date)
+	})
+	return func(date time.Time) (float64, string) {
+		return calculateBTCHoldingsAtDate(txs, date), lastTransactionDate(txs, date)
+	}
+}
+
 // Load functions
-func loadBitcoinTransactions(symbol string) ([]models.BitcoinTransaction, error) {
-	// Try to load from the comprehensive analysis file first
+func loadBitcoinTransactions(symbol string, knownAt time.Time) ([]models.BitcoinTransaction, models.FieldLineage, error) {
+	// For as-of-knowledge runs prefer the EDGAR company store, which keeps superseded versions
+	if !knownAt.IsZero() {
+		companyStorage := storage.NewCompanyDataStorage("data/edgar/companies")
+		if data, err := companyStorage.LoadCompanyDataAsOf(symbol, knownAt); err == nil && len(data.BTCTransactions) > 0 {
+			lineage := models.FieldLineage{Source: "sec", File: filepath.Join("data/edgar/companies", symbol), Method: models.LineageObserved}
+			return data.BTCTransactions, lineage, nil
+		}
+	}
+
+	// Otherwise load from the comprehensive analysis file
 	analysisFile := fmt.Sprintf("data/analysis/%s_comprehensive_bitcoin_analysis.json", symbol)
 
 	data, err := os.ReadFile(analysisFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read analysis file: %w", err)
+		return nil, models.FieldLineage{}, fmt.Errorf("failed to read analysis file: %w", err)
 	}
 
 	var analysis struct {
@@ -152,10 +314,35 @@ func loadBitcoinTransactions(symbol string) ([]models.BitcoinTransaction, error)
 	}
 
 	if err := json.Unmarshal(data, &analysis); err != nil {
-		return nil, fmt.Errorf("failed to parse analysis file: %w", err)
+		return nil, models.FieldLineage{}, fmt.Errorf("failed to parse analysis file: %w", err)
 	}
 
-	return analysis.AllTransactions, nil
+	lineage := models.FileLineage("sec", analysisFile)
+	if !knownAt.IsZero() {
+		return models.TransactionsKnownAsOf(analysis.AllTransactions, knownAt), lineage, nil
+	}
+	return analysis.AllTransactions, lineage, nil
+}
+
+// loadKnownShares returns the latest shares outstanding known at knownAt from the EDGAR company store
+func loadKnownShares(symbol string, knownAt time.Time) (float64, error) {
+	companyStorage := storage.NewCompanyDataStorage("data/edgar/companies")
+	data, err := companyStorage.LoadCompanyDataAsOf(symbol, knownAt)
+	if err != nil {
+		return 0, err
+	}
+
+	var latest *models.SharesOutstandingRecord
+	for i := range data.SharesHistory {
+		if !data.SharesHistory[i].Date.After(knownAt) {
+			latest = &data.SharesHistory[i]
+		}
+	}
+	if latest == nil || latest.TotalShares == 0 {
+		return 0, fmt.Errorf("no shares outstanding known for %s", symbol)
+	}
+
+	return latest.TotalShares, nil
 }
 
 func loadSharesFromAlphaVantage(client *alphavantage.Client, symbol string) (float64, error) {
@@ -198,12 +385,12 @@ func loadHistoricalStockPricesFromFMP(client *fmp.Client, symbol string, startDa
 	return priceMap, nil
 }
 
-func loadHistoricalBitcoinPrices(startDate, endDate string) (map[string]float64, error) {
+func loadHistoricalBitcoinPrices(startDate, endDate string) (map[string]float64, models.FieldLineage, error) {
 	// Load from the historical Bitcoin price file we created
 	pattern := fmt.Sprintf("data/bitcoin-prices/historical/bitcoin_historical_*_to_*.json")
 	files, err := filepath.Glob(pattern)
 	if err != nil || len(files) == 0 {
-		return nil, fmt.Errorf("no historical Bitcoin price files found")
+		return nil, models.FieldLineage{}, fmt.Errorf("no historical Bitcoin price files found")
 	}
 
 	// Use the most recent file
@@ -212,7 +399,7 @@ func loadHistoricalBitcoinPrices(startDate, endDate string) (map[string]float64,
 
 	data, err := os.ReadFile(latestFile)
 	if err != nil {
-		return nil, err
+		return nil, models.FieldLineage{}, err
 	}
 
 	var histData struct {
@@ -223,7 +410,7 @@ func loadHistoricalBitcoinPrices(startDate, endDate string) (map[string]float64,
 	}
 
 	if err := json.Unmarshal(data, &histData); err != nil {
-		return nil, err
+		return nil, models.FieldLineage{}, err
 	}
 
 	// Convert to map
@@ -232,30 +419,93 @@ func loadHistoricalBitcoinPrices(startDate, endDate string) (map[string]float64,
 		priceMap[dp.Date] = dp.Close
 	}
 
-	return priceMap, nil
+	return priceMap, models.FileLineage("bitcoin-prices", latestFile), nil
+}
+
+// loadHistoricalAssetPrices loads a treasury asset's USD price series from data/asset-prices
+func loadHistoricalAssetPrices(series, startDate, endDate string) (map[string]float64, models.FieldLineage, error) {
+	start, _ := time.Parse("2006-01-02", startDate)
+	end, _ := time.Parse("2006-01-02", endDate)
+
+	prices, err := repository.NewJSONStore(".").GetPrices(series, start, end)
+	if err != nil {
+		return nil, models.FieldLineage{}, err
+	}
+	if len(prices) == 0 {
+		return nil, models.FieldLineage{}, fmt.Errorf("no %s price files found in data/asset-prices/historical", series)
+	}
+
+	priceMap := make(map[string]float64, len(prices))
+	for _, p := range prices {
+		priceMap[p.Date.Format("2006-01-02")] = p.Close
+	}
+	return priceMap, models.FieldLineage{Source: prices[0].Source, File: "data/asset-prices/historical", Method: models.LineageObserved}, nil
+}
+
+// convertPriceMap converts YYYY-MM-DD keyed prices at each day's rate, dropping days
+// without one
+func convertPriceMap(prices map[string]float64, from, to string, fx *models.FXTable) (map[string]float64, int) {
+	converted := make(map[string]float64, len(prices))
+	skipped := 0
+	for day, price := range prices {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			skipped++
+			continue
+		}
+		value, err := fx.Convert(price, from, to, date)
+		if err != nil {
+			skipped++
+			continue
+		}
+		converted[day] = value
+	}
+	return converted, skipped
+}
+
+// convertMNAVData restates the USD prices and values of every point in currency at
+// that day's rate; mNAV and premium are unchanged
+func convertMNAVData(data *HistoricalMNAVData, currency string, fx *models.FXTable) error {
+	for i := range data.DataPoints {
+		dp := &data.DataPoints[i]
+		date, err := time.Parse("2006-01-02", dp.Date)
+		if err != nil {
+			return err
+		}
+		rate, err := fx.Convert(1, models.USD, currency, date)
+		if err != nil {
+			return err
+		}
+		dp.StockPrice *= rate
+		dp.BitcoinPrice *= rate
+		dp.MarketCap *= rate
+		dp.BitcoinValue *= rate
+		dp.MNAVPerShare *= rate
+	}
+	data.Currency = currency
+	data.Metadata["currency"] = currency
+	return nil
 }
 
 // Calculate historical mNAV
 func calculateHistoricalMNAV(
 	symbol string,
-	bitcoinTxs []models.BitcoinTransaction,
+	asset string,
+	holdingsAt holdingsFunc,
 	currentShares float64,
 	stockPrices map[string]float64,
 	btcPrices map[string]float64,
+	sources inputLineage,
 	startDate, endDate, interval string,
 ) *HistoricalMNAVData {
 	// Parse dates
 	start, _ := time.Parse("2006-01-02", startDate)
 	end, _ := time.Parse("2006-01-02", endDate)
 
-	// Sort Bitcoin transactions by date
-	sort.Slice(bitcoinTxs, func(i, j int) bool {
-		return bitcoinTxs[i].Date.Before(bitcoinTxs[j].Date)
-	})
-
 	// Create result structure
 	result := &HistoricalMNAVData{
 		Symbol:      symbol,
+		Asset:       asset,
 		StartDate:   startDate,
 		EndDate:     endDate,
 		DataPoints:  []HistoricalMNAVPoint{},
@@ -264,7 +514,7 @@ func calculateHistoricalMNAV(
 			"interval":                   interval,
 			"source":                     "SEC filings + FMP + Alpha Vantage",
 			"current_shares_outstanding": currentShares,
-			"bitcoin_transactions_count": len(bitcoinTxs),
+			"asset":                      asset,
 		},
 	}
 
@@ -272,17 +522,17 @@ func calculateHistoricalMNAV(
 	for current := start; !current.After(end); current = getNextDate(current, interval) {
 		dateStr := current.Format("2006-01-02")
 
-		// Skip if we don't have both stock and Bitcoin prices for this date
+		// Skip if we don't have both stock and asset prices for this date
 		stockPrice, hasStock := stockPrices[dateStr]
 		btcPrice, hasBTC := btcPrices[dateStr]
 		if !hasStock || !hasBTC {
 			continue
 		}
 
-		// Calculate Bitcoin holdings at this date
-		btcHoldings := calculateBTCHoldingsAtDate(bitcoinTxs, current)
+		// Calculate holdings at this date
+		btcHoldings, lastChange := holdingsAt(current)
 		if btcHoldings == 0 {
-			continue // No Bitcoin holdings yet
+			continue // No holdings yet
 		}
 
 		// Use current shares outstanding (simplified approach)
@@ -298,6 +548,13 @@ func calculateHistoricalMNAV(
 		mnavPerShare := btcValue / shares
 		premium := ((stockPrice - mnavPerShare) / mnavPerShare) * 100
 
+		// Holdings are carried forward from the most recent transaction
+		holdingsLineage := sources.Holdings
+		if lastChange != "" && lastChange != dateStr {
+			holdingsLineage = holdingsLineage.FilledFromDate(models.LineageCarriedForward, lastChange)
+		}
+		derived := models.DerivedLineage(sources.Stock, sources.Bitcoin, holdingsLineage, sources.Shares)
+
 		// Add data point
 		result.DataPoints = append(result.DataPoints, HistoricalMNAVPoint{
 			Date:              dateStr,
@@ -310,6 +567,16 @@ func calculateHistoricalMNAV(
 			MNAV:              mnav,
 			MNAVPerShare:      mnavPerShare,
 			Premium:           premium,
+			Lineage: models.RowLineage{
+				models.FieldStockPrice:        sources.Stock,
+				models.FieldBitcoinPrice:      sources.Bitcoin,
+				models.FieldBitcoinHoldings:   holdingsLineage,
+				models.FieldSharesOutstanding: sources.Shares,
+				models.FieldMarketCap:         models.DerivedLineage(sources.Stock, sources.Shares),
+				models.FieldBitcoinValue:      models.DerivedLineage(sources.Bitcoin, holdingsLineage),
+				models.FieldMNAV:              derived,
+				models.FieldPremium:           derived,
+			},
 		})
 	}
 
@@ -327,6 +594,18 @@ func calculateBTCHoldingsAtDate(txs []models.BitcoinTransaction, date time.Time)
 	return holdings
 }
 
+// lastTransactionDate returns the date (YYYY-MM-DD) of the last transaction on or before date
+func lastTransactionDate(txs []models.BitcoinTransaction, date time.Time) string {
+	var last string
+	for _, tx := range txs {
+		if tx.Date.After(date) {
+			break
+		}
+		last = tx.Date.Format("2006-01-02")
+	}
+	return last
+}
+
 func getNextDate(current time.Time, interval string) time.Time {
 	switch interval {
 	case "weekly":
@@ -353,7 +632,9 @@ func saveCompanyOverview(overview *alphavantage.ParsedCompanyOverview, symbol st
 		return err
 	}
 
-	return os.WriteFile(filepath, jsonData, 0644)
+	return storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	})
 }
 
 func saveHistoricalStockData(data *fmp.HistoricalData, symbol string) error {
@@ -370,7 +651,9 @@ func saveHistoricalStockData(data *fmp.HistoricalData, symbol string) error {
 		return err
 	}
 
-	return os.WriteFile(filepath, jsonData, 0644)
+	return storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	})
 }
 
 // Save and display functions
@@ -392,7 +675,9 @@ func saveMNAVData(data *HistoricalMNAVData, outputDir string) error {
 	}
 
 	// Write to file
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing file: %w", err)
 	}
 
@@ -436,9 +721,14 @@ func printSummary(data *HistoricalMNAVData) {
 
 	fmt.Printf("\n📈 Current Values:\n")
 	fmt.Printf("   • Date: %s\n", current.Date)
-	fmt.Printf("   • Stock Price: $%.2f\n", current.StockPrice)
-	fmt.Printf("   • Bitcoin Holdings: %.0f BTC\n", current.BitcoinHoldings)
-	fmt.Printf("   • Bitcoin Value: $%.2fB\n", current.BitcoinValue/1e9)
+	sym := models.CurrencySymbol(data.Currency)
+	fmt.Printf("   • Stock Price: %s%.2f\n", sym, current.StockPrice)
+	asset := data.Asset
+	if asset == "" {
+		asset = "BTC"
+	}
+	fmt.Printf("   • %s Holdings: %.0f %s\n", asset, current.BitcoinHoldings, asset)
+	fmt.Printf("   • %s Value: %s%.2fB\n", asset, sym, current.BitcoinValue/1e9)
 	fmt.Printf("   • mNAV: %.2f\n", current.MNAV)
 	fmt.Printf("   • Premium: %.1f%%\n", current.Premium)
 
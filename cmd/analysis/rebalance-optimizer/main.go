@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/analyzer"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+func main() {
+	defaults := analyzer.DefaultOptimizerOptions()
+	var (
+		input          = flag.String("input", "", "Path to historical mNAV JSON file (default: most recent MSTR file)")
+		tablePath      = flag.String("table", "", "Base rebalancing table CSV or JSON (default: configs/rebalancing/rebalancing_table.csv)")
+		bitcoinLeg     = flag.String("bitcoin", "fbtc", "Bitcoin leg: fbtc (FBTC closes, scaled BTC on other days) or btc")
+		method         = flag.String("method", defaults.Method, "Search method: grid or random")
+		objective      = flag.String("objective", defaults.Objective, "Objective: cagr or calmar (CAGR over max drawdown)")
+		steps          = flag.Int("steps", defaults.Steps, "Grid points per dimension")
+		samples        = flag.Int("samples", defaults.Samples, "Random search candidates")
+		seed           = flag.Int64("seed", defaults.Seed, "Random search seed")
+		thresholdScale = flag.String("threshold-scale", "0.8,1.2", "Multiplier range for Min/Max thresholds")
+		ratioScale     = flag.String("ratio-scale", "0.5,1.5", "Multiplier range for target ratios")
+		toleranceRange = flag.String("tolerance", "0.02,0.15", "Tolerance band range")
+		folds          = flag.Int("folds", defaults.Folds, "Walk-forward folds")
+		top            = flag.Int("top", defaults.Top, "Candidate tables to write")
+		capital        = flag.Float64("capital", defaults.Backtest.InitialCapital, "Initial capital")
+		costBps        = flag.Float64("cost-bps", 0, "Trade cost per side in basis points")
+		slippageBps    = flag.Float64("slippage-bps", 0, "Slippage per side in basis points")
+		frequency      = flag.String("frequency", "daily", "Rebalance frequency: daily, weekly or monthly")
+		outputDir      = flag.String("output", "data/analysis/optimizer", "Output directory for candidate tables and the report")
+	)
+	flag.Parse()
+
+	fmt.Printf("🔬 REBALANCING TABLE OPTIMIZER\n")
+	fmt.Printf("==============================\n\n")
+
+	if *input == "" {
+		files, err := filepath.Glob("data/analysis/mnav/MSTR_mnav_historical_*.json")
+		if err != nil || len(files) == 0 {
+			log.Fatalf("❌ No input file specified and no MSTR historical mNAV files found (run mnav-historical first)")
+		}
+		sort.Strings(files)
+		*input = files[len(files)-1]
+		fmt.Printf("📂 Using most recent file: %s\n", *input)
+	}
+
+	points, symbol, err := analyzer.LoadBacktestPoints(*input)
+	if err != nil {
+		log.Fatalf("❌ Error loading mNAV data: %v", err)
+	}
+	fmt.Printf("✅ Loaded %d data points for %s\n", len(points), symbol)
+
+	switch strings.ToLower(*bitcoinLeg) {
+	case "btc":
+	case "fbtc":
+		replaced, err := analyzer.UseFBTCPrices(points, repository.NewJSONStore(".").Prices())
+		if err != nil {
+			log.Fatalf("❌ Error loading FBTC prices: %v", err)
+		}
+		fmt.Printf("📈 Using FBTC closes for %d of %d days (scaled BTC on other days)\n", replaced, len(points))
+	default:
+		log.Fatalf("❌ Unknown bitcoin leg: %s (want fbtc or btc)", *bitcoinLeg)
+	}
+
+	base, err := loadBaseTable(*tablePath)
+	if err != nil {
+		log.Fatalf("❌ Error loading base table: %v", err)
+	}
+
+	opts := defaults
+	opts.Method = *method
+	opts.Objective = *objective
+	opts.Steps = *steps
+	opts.Samples = *samples
+	opts.Seed = *seed
+	opts.Folds = *folds
+	opts.Top = *top
+	for _, r := range []struct {
+		name, value string
+		target      *[2]float64
+	}{
+		{"threshold-scale", *thresholdScale, &opts.ThresholdScale},
+		{"ratio-scale", *ratioScale, &opts.RatioScale},
+		{"tolerance", *toleranceRange, &opts.Tolerance},
+	} {
+		if *r.target, err = parseRange(r.value); err != nil {
+			log.Fatalf("❌ Invalid -%s: %v", r.name, err)
+		}
+	}
+	opts.Backtest.InitialCapital = *capital
+	opts.Backtest.TradeCostBps = *costBps
+	opts.Backtest.SlippageBps = *slippageBps
+	if opts.Backtest.Frequency, err = analyzer.ParseRebalanceFrequency(*frequency); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	fmt.Printf("🔎 %s search, %s objective, %d walk-forward folds\n", opts.Method, opts.Objective, opts.Folds)
+	result, err := analyzer.OptimizeRebalancingTable(base, points, opts)
+	if err != nil {
+		log.Fatalf("❌ Optimization failed: %v", err)
+	}
+	printSummary(result)
+
+	if err := saveResults(result, *input, *outputDir); err != nil {
+		log.Fatalf("❌ Error saving results: %v", err)
+	}
+
+	fmt.Printf("\n💡 Import a candidate with: ./bin/config-manager -import %s\n", filepath.Join(*outputDir, "candidate_01.json"))
+	fmt.Printf("\n✅ Optimization complete!\n")
+}
+
+// loadBaseTable loads the table to search around, or the default configs/rebalancing location
+func loadBaseTable(path string) (*config.RebalancingConfig, error) {
+	if path == "" {
+		return config.LoadRebalancingConfig()
+	}
+	return config.LoadRebalancingConfigFile(path)
+}
+
+// parseRange parses a LOW,HIGH pair
+func parseRange(value string) ([2]float64, error) {
+	low, high, ok := strings.Cut(value, ",")
+	if !ok {
+		return [2]float64{}, fmt.Errorf("expected LOW,HIGH, got %q", value)
+	}
+	lo, err := strconv.ParseFloat(strings.TrimSpace(low), 64)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	hi, err := strconv.ParseFloat(strings.TrimSpace(high), 64)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	if lo <= 0 || hi < lo {
+		return [2]float64{}, fmt.Errorf("expected 0 < LOW <= HIGH, got %q", value)
+	}
+	return [2]float64{lo, hi}, nil
+}
+
+func printSummary(r *analyzer.OptimizerResult) {
+	fmt.Printf("\n📅 Period: %s to %s, %d candidates evaluated\n\n",
+		r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"), r.Evaluated)
+
+	fmt.Printf("%-4s %-50s %10s %10s %10s %10s\n", "#", "Candidate", "Train", "Test", "Test CAGR", "Test DD")
+	fmt.Printf("%s\n", strings.Repeat("-", 100))
+	row := func(rank string, c analyzer.OptimizerCandidate) {
+		last := c.Folds[len(c.Folds)-1]
+		fmt.Printf("%-4s %-50s %10.2f %10.2f %9.1f%% %9.1f%%\n", rank, c.Label, c.TrainScore, c.TestScore, last.Test.CAGRPct, last.Test.MaxDrawdownPct)
+	}
+	row("-", r.Baseline)
+	for i, c := range r.Top {
+		row(strconv.Itoa(i+1), c)
+	}
+	if len(r.Top) > 0 {
+		last := r.Top[0].Folds[len(r.Top[0].Folds)-1]
+		fmt.Printf("   Ranked on %s to %s; tested on %s to %s\n",
+			last.TrainStart.Format("2006-01-02"), last.TrainEnd.Format("2006-01-02"),
+			last.TestStart.Format("2006-01-02"), last.TestEnd.Format("2006-01-02"))
+	}
+
+	fmt.Printf("\n🚶 Walk-forward (best training candidate per fold, scored on the next segment):\n")
+	for i, f := range r.WalkForward {
+		fmt.Printf("   Fold %d: test %s to %s  score %.2f  CAGR %.1f%% (HODL BTC %.1f%%, HODL MSTR %.1f%%)  %s\n", i+1,
+			f.TestStart.Format("2006-01-02"), f.TestEnd.Format("2006-01-02"), f.TestScore,
+			f.Test.CAGRPct, f.TestHODLBTC.CAGRPct, f.TestHODLMSTR.CAGRPct, f.CandidateLabel)
+	}
+	fmt.Printf("   Mean out-of-sample score: %.2f (current table %.2f)\n", r.WalkForwardTestScore, r.Baseline.MeanTestScore())
+}
+
+// saveResults writes each top candidate as a loadable table plus the full report
+func saveResults(r *analyzer.OptimizerResult, input, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	for i, c := range r.Top {
+		c.Config.Validation.Input = input
+		path := filepath.Join(outputDir, fmt.Sprintf("candidate_%02d.json", i+1))
+		if err := config.SaveRebalancingConfigFile(c.Config, path); err != nil {
+			return err
+		}
+		fmt.Printf("💾 Candidate %d saved to: %s\n", i+1, path)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(outputDir, fmt.Sprintf("optimizer_%s.json", time.Now().Format("2006-01-02")))
+	if err := storage.WriteFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("💾 Report saved to: %s\n", path)
+	return nil
+}
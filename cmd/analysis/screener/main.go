@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/metrics"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/chart"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// companyColors is the palette for per-company chart lines
+var companyColors = []color.RGBA{
+	{247, 147, 26, 255}, {54, 162, 235, 255}, {255, 99, 132, 255}, {75, 192, 192, 255},
+	{153, 102, 255, 255}, {46, 160, 67, 255}, {201, 76, 76, 255}, {214, 168, 0, 255},
+	{90, 90, 90, 255}, {0, 128, 128, 255},
+}
+
+func main() {
+	var (
+		basePath  = flag.String("base", ".", "Project root containing data/")
+		backend   = flag.String("backend", "json", "Data source: json or sqlite")
+		dbPath    = flag.String("db", "data/mnav.db", "SQLite database path when -backend=sqlite")
+		symbols   = flag.String("symbols", "", "Comma-separated symbols to screen (default: every company in the registry)")
+		sortKey   = flag.String("sort", "mnav", "Sort column: "+strings.Join(metrics.ScreenerSortKeys, ", "))
+		ascending = flag.Bool("asc", false, "Sort ascending instead of descending")
+		window    = flag.Int("window", 365, "Lookback in days for mNAV range, premium percentile and holdings/share growth")
+		startDate = flag.String("start", "2020-08-11", "Start of the price history to load (YYYY-MM-DD)")
+		outputDir = flag.String("output", "data/analysis/screener", "Output directory")
+		format    = flag.String("format", "json", "Output file format: json, csv, svg, png or none")
+		logScale  = flag.Bool("log", false, "Use a log scale for the mNAV comparison chart (svg, png)")
+		currency  = flag.String("currency", "USD", "Currency for prices, market cap, NAV and EV in the output")
+	)
+	flag.Parse()
+
+	fmt.Printf("🔎 TREASURY COMPANY SCREENER\n")
+	fmt.Printf("============================\n\n")
+
+	start, err := time.Parse("2006-01-02", *startDate)
+	if err != nil {
+		log.Fatalf("❌ Error parsing start date: %v", err)
+	}
+
+	registry, err := config.LoadCompaniesConfig(*basePath)
+	if err != nil {
+		log.Fatalf("❌ Error loading company registry: %v", err)
+	}
+	companies := selectCompanies(registry.Companies, *symbols)
+	if len(companies) == 0 {
+		log.Fatalf("❌ No companies to screen")
+	}
+
+	var store repository.Store
+	if *backend == repository.BackendSQLite {
+		store, err = repository.OpenSQLiteStore(*dbPath)
+	} else {
+		store, err = repository.Open(*backend, *basePath)
+	}
+	if err != nil {
+		log.Fatalf("❌ Error opening %s store: %v", *backend, err)
+	}
+	defer store.Close()
+
+	fx, err := storage.NewFXStorage(filepath.Join(*basePath, "data", "fx")).LoadTable()
+	if err != nil {
+		log.Fatalf("❌ Error loading FX rates: %v", err)
+	}
+
+	fmt.Printf("🏢 Screening %d companies over a %d-day window...\n\n", len(companies), *window)
+	rows, errs := metrics.ScreenCompanies(store, companies, start, *window, fx)
+	for _, err := range errs {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	if len(rows) == 0 {
+		log.Fatalf("❌ No company could be screened")
+	}
+	if *currency = models.CurrencyCode(*currency); *currency != models.USD {
+		for _, row := range rows {
+			if err := row.ConvertTo(*currency, fx); err != nil {
+				log.Fatalf("❌ Error converting to %s: %v", *currency, err)
+			}
+		}
+		fmt.Printf("💱 Values in %s\n\n", *currency)
+	}
+
+	if err := metrics.SortScreenerRows(rows, *sortKey, !*ascending); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	printTable(rows)
+
+	if *format == "none" {
+		return
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		log.Fatalf("❌ Error creating output directory: %v", err)
+	}
+
+	stamp := time.Now().Format("2006-01-02")
+	switch *format {
+	case "json":
+		path := filepath.Join(*outputDir, fmt.Sprintf("screener_%s.json", stamp))
+		if err := storage.WriteJSONAtomic(path, rows); err != nil {
+			log.Fatalf("❌ Error writing JSON: %v", err)
+		}
+		fmt.Printf("\n💾 Screener results saved to: %s\n", path)
+	case "csv":
+		path := filepath.Join(*outputDir, fmt.Sprintf("screener_%s.csv", stamp))
+		if err := writeCSV(rows, path); err != nil {
+			log.Fatalf("❌ Error writing CSV: %v", err)
+		}
+		fmt.Printf("\n💾 Screener results saved to: %s\n", path)
+	case "svg", "png":
+		if err := writeComparisonCharts(rows, rows[0].WindowStart, *outputDir, stamp, chart.Format(*format), *logScale); err != nil {
+			log.Fatalf("❌ Error generating charts: %v", err)
+		}
+	default:
+		log.Fatalf("❌ Unknown format: %s", *format)
+	}
+}
+
+// selectCompanies filters the registry to the requested symbols
+func selectCompanies(companies []config.CompanyData, symbols string) []config.CompanyData {
+	if symbols == "" {
+		return companies
+	}
+	wanted := make(map[string]bool)
+	for _, s := range strings.Split(symbols, ",") {
+		wanted[strings.ToUpper(strings.TrimSpace(s))] = true
+	}
+
+	var selected []config.CompanyData
+	for _, c := range companies {
+		if wanted[c.Symbol] {
+			selected = append(selected, c)
+			delete(wanted, c.Symbol)
+		}
+	}
+	for symbol := range wanted {
+		fmt.Printf("⚠️  %s is not in the company registry\n", symbol)
+	}
+	return selected
+}
+
+// printTable prints the screener rows as an aligned table
+func printTable(rows []*metrics.ScreenerRow) {
+	fmt.Printf("%-6s %-26s %-5s %8s %8s %9s %8s %8s %8s %11s %10s %14s\n",
+		"Symbol", "Name", "Asset", "mNAV", "EV mNAV", "Premium", "Low", "Median", "High", "Percentile", "Hold/sh Δ", "Holdings")
+	fmt.Println(strings.Repeat("─", 136))
+	for _, r := range rows {
+		name := r.Name
+		if len(name) > 26 {
+			name = name[:25] + "…"
+		}
+		fmt.Printf("%-6s %-26s %-5s %8.2f %8.2f %8.1f%% %8.2f %8.2f %8.2f %10.0f%% %9.1f%% %14.0f\n",
+			r.Symbol, name, r.PrimaryAsset, r.MNAV, r.EVMNAV, r.Premium, r.MNAVLow, r.MNAVMedian, r.MNAVHigh,
+			r.PremiumPercentile, r.HoldingsPerShareGrowth, r.Holdings[r.PrimaryAsset])
+	}
+}
+
+// writeCSV writes one row per company without the daily history
+func writeCSV(rows []*metrics.ScreenerRow, path string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Symbol", "Name", "Exchange", "Currency", "Value Currency", "As Of", "Stock Price", "Market Cap", "Holdings",
+		"Shares Outstanding", "Primary Asset", "Holdings per Share", "NAV", "mNAV", "Premium %", "Enterprise Value",
+		"EV mNAV", "mNAV Low", "mNAV Median", "mNAV High", "Premium Percentile", "Holdings per Share Growth %"})
+	for _, r := range rows {
+		w.Write([]string{
+			r.Symbol, r.Name, r.Exchange, r.Currency, r.ValueCurrency, r.AsOf.Format("2006-01-02"),
+			fmt.Sprintf("%.2f", r.StockPrice), fmt.Sprintf("%.0f", r.MarketCap), formatHoldings(r.Holdings),
+			fmt.Sprintf("%.0f", r.SharesOutstanding), r.PrimaryAsset, fmt.Sprintf("%.8f", r.HoldingsPerShare),
+			fmt.Sprintf("%.0f", r.NAV), fmt.Sprintf("%.4f", r.MNAV), fmt.Sprintf("%.2f", r.Premium),
+			fmt.Sprintf("%.0f", r.EnterpriseValue), fmt.Sprintf("%.4f", r.EVMNAV), fmt.Sprintf("%.4f", r.MNAVLow),
+			fmt.Sprintf("%.4f", r.MNAVMedian), fmt.Sprintf("%.4f", r.MNAVHigh), fmt.Sprintf("%.1f", r.PremiumPercentile),
+			fmt.Sprintf("%.2f", r.HoldingsPerShareGrowth),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// formatHoldings lists holdings per asset as "BTC=1000;ETH=500"
+func formatHoldings(holdings map[string]float64) string {
+	assets := make([]string, 0, len(holdings))
+	for asset := range holdings {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	for i, asset := range assets {
+		assets[i] = fmt.Sprintf("%s=%g", asset, holdings[asset])
+	}
+	return strings.Join(assets, ";")
+}
+
+// writeComparisonCharts renders mNAV, indexed holdings/share and indexed stock price for
+// every company over the window
+func writeComparisonCharts(rows []*metrics.ScreenerRow, windowStart time.Time, outputDir, stamp string, format chart.Format, logScale bool) error {
+	dates, series := alignHistories(rows, windowStart)
+	if len(dates) == 0 {
+		return fmt.Errorf("no price history in the window")
+	}
+
+	charts := []struct {
+		name  string
+		title string
+		axis  chart.YAxis
+		value func(p metrics.ScreenerPoint) float64
+		index bool
+	}{
+		{"mnav", "mNAV Comparison", chart.YAxis{Label: "mNAV", Log: logScale}, func(p metrics.ScreenerPoint) float64 { return p.MNAV }, false},
+		{"holdings_per_share", "Treasury Holdings per Share (indexed to 100)", chart.YAxis{Label: "Index"}, func(p metrics.ScreenerPoint) float64 { return p.HoldingsPerShare }, true},
+		{"stock_price", "Stock Price (indexed to 100)", chart.YAxis{Label: "Index"}, func(p metrics.ScreenerPoint) float64 { return p.StockPrice }, true},
+	}
+
+	for _, spec := range charts {
+		c := &chart.Chart{Title: spec.title, Dates: dates, Left: spec.axis}
+		for i, r := range rows {
+			values := make([]float64, len(dates))
+			for d, p := range series[i] {
+				if p == nil {
+					values[d] = math.NaN()
+				} else {
+					values[d] = spec.value(*p)
+				}
+			}
+			if spec.index {
+				values = metrics.IndexSeries(values)
+			}
+			c.Series = append(c.Series, chart.Series{Name: r.Symbol, Values: values, Color: companyColors[i%len(companyColors)]})
+		}
+
+		var buf bytes.Buffer
+		if err := c.Render(&buf, format); err != nil {
+			return fmt.Errorf("failed to render %s chart: %w", spec.name, err)
+		}
+		path := filepath.Join(outputDir, fmt.Sprintf("screener_%s_%s.%s", spec.name, stamp, format))
+		if err := storage.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("💾 %s chart saved to: %s\n", format, path)
+	}
+	return nil
+}
+
+// alignHistories returns the union of dates in the window and, per company, the point
+// on each date (nil where the company has none)
+func alignHistories(rows []*metrics.ScreenerRow, windowStart time.Time) ([]time.Time, [][]*metrics.ScreenerPoint) {
+	seen := make(map[time.Time]bool)
+	for _, r := range rows {
+		for _, p := range r.History {
+			if !p.Date.Before(windowStart) {
+				seen[p.Date] = true
+			}
+		}
+	}
+	dates := make([]time.Time, 0, len(seen))
+	for d := range seen {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	index := make(map[time.Time]int, len(dates))
+	for i, d := range dates {
+		index[d] = i
+	}
+
+	series := make([][]*metrics.ScreenerPoint, len(rows))
+	for i, r := range rows {
+		series[i] = make([]*metrics.ScreenerPoint, len(dates))
+		for j := range r.History {
+			if d, ok := index[r.History[j].Date]; ok {
+				series[i][d] = &r.History[j]
+			}
+		}
+	}
+	return dates, series
+}
@@ -7,33 +7,60 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/coindesk"
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
+// defaultCoinIDs maps common treasury assets to their CoinGecko coin ids
+var defaultCoinIDs = map[string]string{
+	"BTC": "bitcoin",
+	"ETH": "ethereum",
+	"SOL": "solana",
+}
+
 func main() {
 	var (
 		startDate = flag.String("start", "2020-08-11", "Start date (YYYY-MM-DD)")
 		endDate   = flag.String("end", "", "End date (YYYY-MM-DD), defaults to today")
-		output    = flag.String("output", "data/bitcoin-prices/historical", "Output directory")
+		output    = flag.String("output", "", "Output directory (default data/bitcoin-prices/historical for BTC, data/asset-prices/historical otherwise)")
+		asset     = flag.String("asset", "BTC", "Treasury asset symbol, e.g. BTC, ETH, SOL")
+		coinID    = flag.String("coin", "", "CoinGecko coin id (default: from the company registry or built-in list)")
+		basePath  = flag.String("base", ".", "Project root containing data/companies.json")
 	)
 	flag.Parse()
 
-	fmt.Printf("📊 BITCOIN HISTORICAL PRICE COLLECTOR\n")
+	*asset = strings.ToUpper(*asset)
+	fmt.Printf("📊 %s HISTORICAL PRICE COLLECTOR\n", *asset)
 	fmt.Printf("====================================\n\n")
 
+	if *coinID == "" {
+		*coinID = resolveCoinID(*basePath, *asset)
+		if *coinID == "" {
+			log.Fatalf("❌ No CoinGecko coin id known for %s; pass -coin", *asset)
+		}
+	}
+	if *output == "" {
+		*output = "data/asset-prices/historical"
+		if *asset == "BTC" {
+			*output = "data/bitcoin-prices/historical"
+		}
+	}
+
 	// Default end date to today
 	if *endDate == "" {
 		*endDate = time.Now().Format("2006-01-02")
 	}
 
-	fmt.Printf("📅 Fetching Bitcoin prices from %s to %s...\n", *startDate, *endDate)
+	fmt.Printf("📅 Fetching %s prices from %s to %s...\n", *asset, *startDate, *endDate)
 	fmt.Printf("🔗 Data source: CoinGecko (Free API)\n")
 	fmt.Printf("💡 Using free CoinGecko API - no API key required!\n\n")
 
 	// Fetch historical data using CoinGecko API
-	histData, err := coindesk.GetHistoricalBitcoinPrices(*startDate, *endDate)
+	histData, err := coindesk.NewClient().GetHistoricalAssetPrices(*coinID, *asset, *startDate, *endDate)
 	if err != nil {
 		log.Fatalf("❌ Error fetching historical data: %v", err)
 	}
@@ -68,9 +95,13 @@ func saveHistoricalData(data *coindesk.HistoricalBitcoinData, outputDir string)
 		return fmt.Errorf("error creating directory: %w", err)
 	}
 
-	// Create filename
-	filename := fmt.Sprintf("bitcoin_historical_%s_to_%s.json",
-		data.StartDate, data.EndDate)
+	// Create filename; other assets are named after their price series (ETH-USD_...)
+	prefix := "bitcoin"
+	if data.Symbol != "BTC" {
+		prefix = data.Symbol + "-USD"
+	}
+	filename := fmt.Sprintf("%s_historical_%s_to_%s.json",
+		prefix, data.StartDate, data.EndDate)
 	filepath := filepath.Join(outputDir, filename)
 
 	// Marshal to JSON
@@ -80,9 +111,24 @@ func saveHistoricalData(data *coindesk.HistoricalBitcoinData, outputDir string)
 	}
 
 	// Write to file
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing file: %w", err)
 	}
 
 	return nil
 }
+
+// resolveCoinID finds the CoinGecko id for an asset in the company registry, falling
+// back to the built-in list
+func resolveCoinID(basePath, asset string) string {
+	if registry, err := config.LoadCompaniesConfig(basePath); err == nil {
+		for _, company := range registry.Companies {
+			if a, ok := company.Asset(asset); ok && a.CoinGeckoID != "" {
+				return a.CoinGeckoID
+			}
+		}
+	}
+	return defaultCoinIDs[asset]
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	edgarclient "github.com/ultrarare-tech/mNAV/pkg/collection/edgar"
+	"github.com/ultrarare-tech/mNAV/pkg/config"
 )
 
 func main() {
@@ -21,6 +22,7 @@ func main() {
 		startDate   = flag.String("start", "", "Start date (YYYY-MM-DD, optional)")
 		endDate     = flag.String("end", time.Now().Format("2006-01-02"), "End date (YYYY-MM-DD)")
 		dataDir     = flag.String("data-dir", "data/edgar/companies", "Data directory")
+		basePath    = flag.String("base", ".", "Project root containing data/companies.json")
 		dryRun      = flag.Bool("dry-run", false, "Show what would be collected without actually downloading")
 		listLocal   = flag.Bool("list", false, "List already downloaded filings")
 		verbose     = flag.Bool("verbose", false, "Verbose output")
@@ -55,6 +57,16 @@ func main() {
 	userAgent := "mNAV Application - Jeffrey Kibler (jeffreykibler@protonmail.com)"
 	client := edgarclient.NewClient(userAgent)
 
+	// Resolve CIKs from the company registry; companies listed outside the SEC are refused
+	if registry, err := config.LoadCompaniesConfig(*basePath); err == nil {
+		client.UseRegistry(registry)
+	} else if *verbose {
+		fmt.Printf("⚠️  Company registry not loaded: %v\n", err)
+	}
+	if *cik != "" {
+		client.RegisterCIK(*ticker, *cik)
+	}
+
 	// Handle list command
 	if *listLocal {
 		companyDir := filepath.Join(*dataDir, *ticker)
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/collection/fx"
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+func main() {
+	var (
+		currencies = flag.String("currencies", "", "Comma-separated currencies to collect (default: registry trading currencies plus those already stored)")
+		startDate  = flag.String("start", "2020-08-11", "Start date for currencies with no stored rates (YYYY-MM-DD)")
+		endDate    = flag.String("end", "", "End date (YYYY-MM-DD), defaults to today")
+		full       = flag.Bool("full", false, "Refetch from -start even when rates are already stored")
+		basePath   = flag.String("base", ".", "Project root containing data/")
+	)
+	flag.Parse()
+
+	fmt.Printf("💱 FX RATE COLLECTOR\n")
+	fmt.Printf("====================\n\n")
+
+	if *endDate == "" {
+		*endDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", *startDate); err != nil {
+		log.Fatalf("❌ Invalid start date: %v", err)
+	}
+
+	store := storage.NewFXStorage(filepath.Join(*basePath, "data", "fx"))
+	wanted := selectCurrencies(*currencies, *basePath)
+	if len(wanted) == 0 {
+		log.Fatalf("❌ No non-USD currencies to collect; pass -currencies (e.g. JPY,EUR)")
+	}
+
+	fmt.Printf("🔗 Data source: %s\n", fx.Source)
+	fmt.Printf("💡 Rates are stored as USD per unit in %s\n\n", filepath.Join(*basePath, "data", "fx"))
+
+	client := fx.NewClient()
+	failed := 0
+	for _, currency := range wanted {
+		history, err := store.LoadRates(currency)
+		if err != nil {
+			log.Fatalf("❌ Error loading stored %s rates: %v", currency, err)
+		}
+
+		from := *startDate
+		if !*full && len(history.Rates) > 0 {
+			from = history.Rates[len(history.Rates)-1].Date.AddDate(0, 0, 1).Format("2006-01-02")
+		}
+		if from > *endDate {
+			fmt.Printf("✅ %s: up to date (%d rates through %s)\n", currency, len(history.Rates),
+				history.Rates[len(history.Rates)-1].Date.Format("2006-01-02"))
+			continue
+		}
+
+		fmt.Printf("📅 %s: fetching %s to %s...\n", currency, from, *endDate)
+		rates, err := client.GetHistoricalRates([]string{currency}, from, *endDate)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", currency, err)
+			failed++
+			continue
+		}
+
+		history.Source = fx.Source
+		added := history.Merge(rates[currency])
+		if err := store.SaveRates(history); err != nil {
+			log.Fatalf("❌ Error saving %s rates: %v", currency, err)
+		}
+		fmt.Printf("   ✅ %d new rates (%d stored)\n", added, len(history.Rates))
+		if n := len(history.Rates); n > 0 {
+			last := history.Rates[n-1]
+			fmt.Printf("   💾 Latest: %s 1 %s = $%.6f\n", last.Date.Format("2006-01-02"), currency, last.USDPerUnit)
+		}
+	}
+
+	if failed > 0 {
+		log.Fatalf("❌ %d currencies failed", failed)
+	}
+}
+
+// selectCurrencies returns the requested currencies, or the registry's trading
+// currencies plus every currency already in the store
+func selectCurrencies(flagValue, basePath string) []string {
+	seen := make(map[string]bool)
+	add := func(currency string) {
+		if currency = models.CurrencyCode(currency); currency != models.USD {
+			seen[currency] = true
+		}
+	}
+
+	if flagValue != "" {
+		for _, c := range strings.Split(flagValue, ",") {
+			add(c)
+		}
+	} else {
+		if registry, err := config.LoadCompaniesConfig(basePath); err == nil {
+			for _, company := range registry.Companies {
+				add(company.TradingCurrency())
+			}
+		}
+		files, _ := filepath.Glob(filepath.Join(basePath, "data", "fx", "*.json"))
+		for _, file := range files {
+			add(strings.TrimSuffix(filepath.Base(file), ".json"))
+		}
+	}
+
+	currencies := make([]string, 0, len(seen))
+	for currency := range seen {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/alphavantage"
 	"github.com/ultrarare-tech/mNAV/pkg/collection/fmp"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // StockDataCollection represents collected stock data from multiple sources
@@ -162,7 +163,9 @@ func saveStockData(data *StockDataCollection, outputDir string) error {
 	}
 
 	// Write to file
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing file: %w", err)
 	}
 
@@ -186,7 +189,9 @@ func saveIndividualFiles(data *StockDataCollection, outputDir string) error {
 			filename := fmt.Sprintf("%s_historical_prices_%s.json", data.Symbol, timestamp)
 			path := filepath.Join(histDir, filename)
 			jsonData, _ := json.MarshalIndent(data.HistoricalPrices, "", "  ")
-			os.WriteFile(path, jsonData, 0644)
+			storage.WithLock(path+".lock", func() error {
+				return storage.WriteFileAtomic(path, jsonData, 0644)
+			})
 		}
 	}
 
@@ -197,7 +202,9 @@ func saveIndividualFiles(data *StockDataCollection, outputDir string) error {
 			filename := fmt.Sprintf("%s_profile_%s.json", data.Symbol, timestamp)
 			path := filepath.Join(profileDir, filename)
 			jsonData, _ := json.MarshalIndent(data.CompanyProfile, "", "  ")
-			os.WriteFile(path, jsonData, 0644)
+			storage.WithLock(path+".lock", func() error {
+				return storage.WriteFileAtomic(path, jsonData, 0644)
+			})
 		}
 	}
 
@@ -208,7 +215,9 @@ func saveIndividualFiles(data *StockDataCollection, outputDir string) error {
 			filename := fmt.Sprintf("%s_overview_%s.json", data.Symbol, timestamp)
 			path := filepath.Join(overviewDir, filename)
 			jsonData, _ := json.MarshalIndent(data.CompanyOverview, "", "  ")
-			os.WriteFile(path, jsonData, 0644)
+			storage.WithLock(path+".lock", func() error {
+				return storage.WriteFileAtomic(path, jsonData, 0644)
+			})
 		}
 	}
 
@@ -1,16 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"image/color"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/metrics"
 	"github.com/ultrarare-tech/mNAV/pkg/portfolio/analyzer"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/ledger"
 	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/chart"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 func main() {
@@ -18,74 +33,262 @@ func main() {
 		latest     = flag.Bool("latest", false, "Analyze latest portfolio snapshot")
 		date       = flag.String("date", "", "Analyze specific date (YYYY-MM-DD)")
 		historical = flag.Bool("historical", false, "Show historical portfolio summary")
+		perf       = flag.Bool("performance", false, "Show time- and money-weighted returns net of ledger deposits and withdrawals")
 		mnav       = flag.Bool("mnav", true, "Include mNAV-based dynamic rebalancing analysis")
 		verbose    = flag.Bool("verbose", false, "Enable verbose output")
+		asOfKnown  = flag.String("as-of-knowledge", "", "Only use data known on this date (YYYY-MM-DD); records saved before knowledge tracking count from their own date")
+		currency   = flag.String("currency", "USD", "Currency to report values in; positions in other currencies are converted with data/fx rates")
+		saveState  = flag.Bool("save-state", true, "Persist the active rebalancing rule (only for the latest snapshot with current market data)")
+		classes    = flag.String("classes", config.AssetClassPath, "Asset classification JSON (missing file uses the defaults)")
+		accounts   = flag.String("accounts", config.AccountsPath, "Account settings JSON (missing file infers types from account names)")
+		lotMethod  = flag.String("lot-method", "hifo", "Lots sold by rebalancing trades: fifo, hifo or avoid-short-term")
+		shortRate  = flag.Float64("short-term-rate", taxlots.DefaultRates.ShortTerm, "Tax rate on short-term gains")
+		longRate   = flag.Float64("long-term-rate", taxlots.DefaultRates.LongTerm, "Tax rate on long-term gains")
+		deposit    = flag.Float64("deposit", 0, "Invest new cash toward the target ratio without selling")
+		withdraw   = flag.Float64("withdraw", 0, "Raise a withdrawal from the Bitcoin classes toward the target ratio without buying")
+		cashAcct   = flag.String("cash-account", "", "Account for -deposit or -withdraw (default: the largest account for deposits, taxable accounts for withdrawals)")
+		dcaPeriods = flag.Int("dca-periods", 0, "Spread -deposit over N periods, splitting each tranche by the rule table")
+		dcaMNAV    = flag.String("dca-mnav", "", "Expected mNAV of each DCA period, comma-separated; the last holds for the rest (default: current mNAV)")
+		riskFree   = flag.Float64("risk-free", ledger.DefaultRiskOptions.RiskFreeRate, "Annual risk-free rate for -performance Sharpe and Sortino ratios")
+		confidence = flag.Float64("confidence", ledger.DefaultRiskOptions.Confidence, "Confidence of -performance VaR and CVaR")
+		rolling    = flag.Int("rolling", ledger.DefaultRiskOptions.Window, "Days in each -performance rolling risk window (0 for none)")
+		asJSON     = flag.Bool("json", false, "Print -performance metrics or the -historical daily values as JSON")
+		chartPath  = flag.String("chart", "", "Save a chart of the daily portfolio values (.svg or .png)")
 	)
 	flag.Parse()
 
+	cash := cashOptions{Deposit: *deposit, Withdraw: *withdraw, Account: *cashAcct, DCAPeriods: *dcaPeriods}
+	if err := cash.parse(*dcaMNAV); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	fx, err := storage.NewFXStorage("data/fx").LoadTable()
+	if err != nil {
+		log.Fatalf("❌ Error loading FX rates: %v", err)
+	}
+	portfolioAnalyzer := &analyzer.Analyzer{Currency: *currency, FX: fx}
+	if portfolioAnalyzer.Classes, err = config.LoadAssetClassification(*classes); err != nil {
+		log.Fatalf("❌ Error loading asset classes: %v", err)
+	}
+	if companies, err := config.LoadCompaniesConfig("."); err == nil {
+		portfolioAnalyzer.Classes.AddTreasuryCompanies(companies)
+		portfolioAnalyzer.Companies = companies
+	}
+
+	var knownAt time.Time
+	if *asOfKnown != "" {
+		var err error
+		if knownAt, err = sharedmodels.ParseKnowledgeDate(*asOfKnown); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	// Company facts for look-through Bitcoin exposure, as known at the knowledge date
+	store := repository.NewJSONStore(".")
+	portfolioAnalyzer.Prices = store.Prices()
+	portfolioAnalyzer.Transactions = store.Transactions()
+	portfolioAnalyzer.Shares = store.Shares()
+	portfolioAnalyzer.KnownAt = knownAt
+
+	// Account types and lots for tax-aware trades
+	if portfolioAnalyzer.Accounts, err = config.LoadAccountsConfig(*accounts); err != nil {
+		log.Fatalf("❌ Error loading account settings: %v", err)
+	}
+	if portfolioAnalyzer.LotMethod, err = taxlots.ParseMethod(*lotMethod); err != nil || portfolioAnalyzer.LotMethod == taxlots.SpecificID {
+		log.Fatalf("❌ Invalid -lot-method %q: use fifo, hifo or avoid-short-term", *lotMethod)
+	}
+	if portfolioAnalyzer.Lots, err = tracker.NewTracker("data/portfolio/processed").LoadTaxLots(); err != nil {
+		log.Fatalf("❌ Error loading tax lots: %v", err)
+	}
+	portfolioAnalyzer.TaxRates = taxlots.Rates{ShortTerm: *shortRate, LongTerm: *longRate}
+
+	if *chartPath != "" {
+		if err := saveValueChart(portfolioAnalyzer, *chartPath); err != nil {
+			log.Fatalf("❌ Error saving chart: %v", err)
+		}
+		return
+	}
 	if *historical {
-		showHistoricalSummary()
+		showHistoricalSummary(portfolioAnalyzer, *asJSON)
+		return
+	}
+	if *perf {
+		showPerformanceMetrics(portfolioAnalyzer, ledger.RiskOptions{RiskFreeRate: *riskFree, Confidence: *confidence, Window: *rolling}, *asJSON)
 		return
 	}
 
 	var targetDate string
 	if *latest {
-		targetDate = getLatestPortfolioDate()
+		targetDate = getLatestPortfolioDate(*asOfKnown)
 	} else if *date != "" {
 		targetDate = *date
 	} else {
-		targetDate = getLatestPortfolioDate()
+		targetDate = getLatestPortfolioDate(*asOfKnown)
 		*latest = true
 	}
 
 	if targetDate == "" {
 		log.Fatal("❌ No portfolio data found")
 	}
+	if *asOfKnown != "" && targetDate > *asOfKnown {
+		log.Fatalf("❌ Portfolio date %s is after the knowledge date %s", targetDate, *asOfKnown)
+	}
 
 	// Load portfolio data
 	portfolio, err := loadPortfolioData(targetDate)
 	if err != nil {
 		log.Fatalf("❌ Error loading portfolio data: %v", err)
 	}
+	if portfolio, err = portfolioAnalyzer.ConvertPortfolio(portfolio, portfolioAnalyzer.Currency); err != nil {
+		log.Fatalf("❌ Error converting portfolio to %s: %v", *currency, err)
+	}
 
 	// Display basic portfolio analysis
 	displayPortfolioAnalysis(portfolio, *verbose)
 
 	// Add dynamic rebalancing analysis if requested
 	if *mnav {
+		market := defaultMarketContext()
+		if !knownAt.IsZero() {
+			known, err := knownMarketContext(knownAt)
+			if err != nil {
+				log.Fatalf("❌ Error loading market data known on %s: %v", *asOfKnown, err)
+			}
+			market = known
+			fmt.Printf("\n🕰️  Using market data as known on %s\n", *asOfKnown)
+		}
+
+		// Only live analysis of the latest snapshot moves the persisted rule state
+		persist := *saveState && *latest && knownAt.IsZero()
+		evaluatedAt := time.Now()
+		if !knownAt.IsZero() {
+			evaluatedAt = knownAt
+		}
+
 		fmt.Printf("\n")
-		performDynamicRebalancingAnalysis(portfolio, *verbose)
+		performDynamicRebalancingAnalysis(portfolioAnalyzer, portfolio, market, cash, evaluatedAt, persist, *verbose)
 	}
 }
 
-func performDynamicRebalancingAnalysis(portfolio *models.Portfolio, verbose bool) {
-	if verbose {
-		fmt.Printf("🔄 Performing mNAV-based dynamic rebalancing analysis...\n")
+// marketContext holds the MSTR/Bitcoin figures used for mNAV-based rebalancing
+type marketContext struct {
+	MNAV              float64
+	BitcoinPrice      float64
+	BitcoinHoldings   float64
+	SharesOutstanding float64
+}
+
+// defaultMarketContext returns the values from our latest mNAV update
+func defaultMarketContext() marketContext {
+	return marketContext{
+		MNAV:              1.54,
+		BitcoinPrice:      106108.00,
+		BitcoinHoldings:   597325.0,
+		SharesOutstanding: 256473000.0,
 	}
+}
 
-	// Current market data - using the same values from our mNAV update
-	currentMNAV := 1.54              // From our mNAV analysis
-	currentBitcoinPrice := 106108.00 // Current Bitcoin price
+// knownMarketContext rebuilds the mNAV inputs from the prices and company facts known at knownAt
+func knownMarketContext(knownAt time.Time) (marketContext, error) {
+	store := repository.NewJSONStore(".")
 
-	// Calculate total FBTC and MSTR values across all accounts
-	var totalFBTCValue, totalMSTRValue float64
-	var totalFBTCShares, totalMSTRShares float64
-	var fbtcPrice, mstrPrice float64
+	mstrPrices, err := store.GetPrices("MSTR", time.Time{}, knownAt)
+	if err != nil || len(mstrPrices) == 0 {
+		return marketContext{}, fmt.Errorf("no MSTR price known: %v", err)
+	}
+	btcPrices, err := store.GetPrices(repository.BitcoinSymbol, time.Time{}, knownAt)
+	if err != nil || len(btcPrices) == 0 {
+		return marketContext{}, fmt.Errorf("no Bitcoin price known: %v", err)
+	}
 
-	for _, pos := range portfolio.Positions {
-		if pos.Symbol == "FBTC" {
-			totalFBTCValue += pos.CurrentValue
-			totalFBTCShares += pos.Quantity
-			fbtcPrice = pos.LastPrice // All should be the same price
-		} else if pos.Symbol == "MSTR" {
-			totalMSTRValue += pos.CurrentValue
-			totalMSTRShares += pos.Quantity
-			mstrPrice = pos.LastPrice // All should be the same price
+	txs, err := store.LoadBTCTransactionsAsOf("MSTR", knownAt)
+	if err != nil {
+		return marketContext{}, err
+	}
+	if len(txs) == 0 {
+		// Fall back to the comprehensive analysis, known from each transaction's date
+		if data, err := os.ReadFile("data/analysis/MSTR_comprehensive_bitcoin_analysis.json"); err == nil {
+			var analysis sharedmodels.ComprehensiveBitcoinAnalysis
+			if err := json.Unmarshal(data, &analysis); err == nil {
+				txs = sharedmodels.TransactionsKnownAsOf(analysis.AllTransactions, knownAt)
+			}
+		}
+	}
+	var holdings float64
+	for _, tx := range txs {
+		if !tx.Date.After(knownAt) {
+			holdings += tx.BTCPurchased
 		}
 	}
 
-	if totalFBTCValue == 0 || totalMSTRValue == 0 {
-		fmt.Printf("⚠️  Dynamic rebalancing requires both FBTC and MSTR positions\n")
+	shares, err := repository.SharesAtAsOf(store, "MSTR", knownAt, knownAt)
+	if err != nil {
+		return marketContext{}, err
+	}
+
+	stockPrice := mstrPrices[len(mstrPrices)-1].Close
+	bitcoinPrice := btcPrices[len(btcPrices)-1].Close
+	mnav, err := metrics.CalculateMNAV(stockPrice*shares.TotalShares, holdings, bitcoinPrice)
+	if err != nil {
+		return marketContext{}, err
+	}
+
+	return marketContext{
+		MNAV:              mnav,
+		BitcoinPrice:      bitcoinPrice,
+		BitcoinHoldings:   holdings,
+		SharesOutstanding: shares.TotalShares,
+	}, nil
+}
+
+// cashOptions are the deposit, withdrawal and DCA settings for rebalancing
+type cashOptions struct {
+	Deposit    float64
+	Withdraw   float64
+	Account    string
+	DCAPeriods int
+	DCAMNAV    []float64 // Expected mNAV per DCA period
+}
+
+// parse checks the options and reads the comma-separated DCA mNAVs
+func (c *cashOptions) parse(mnavs string) error {
+	switch {
+	case c.Deposit < 0 || c.Withdraw < 0:
+		return fmt.Errorf("-deposit and -withdraw take positive amounts")
+	case c.Deposit > 0 && c.Withdraw > 0:
+		return fmt.Errorf("use either -deposit or -withdraw")
+	case c.DCAPeriods < 0:
+		return fmt.Errorf("-dca-periods must be positive")
+	case c.DCAPeriods > 0 && c.Deposit == 0:
+		return fmt.Errorf("-dca-periods spreads a -deposit; set one")
+	}
+	for _, value := range strings.Split(mnavs, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		mnav, err := strconv.ParseFloat(value, 64)
+		if err != nil || mnav <= 0 {
+			return fmt.Errorf("invalid -dca-mnav value %q", value)
+		}
+		c.DCAMNAV = append(c.DCAMNAV, mnav)
+	}
+	return nil
+}
+
+func performDynamicRebalancingAnalysis(a *analyzer.Analyzer, portfolio *models.Portfolio, market marketContext, cash cashOptions, evaluatedAt time.Time, persist, verbose bool) {
+	if verbose {
+		fmt.Printf("🔄 Performing mNAV-based dynamic rebalancing analysis...\n")
+	}
+
+	currentMNAV := market.MNAV
+	currentBitcoinPrice := market.BitcoinPrice
+
+	// Sum the spot BTC proxy and BTC treasury equity classes across all accounts
+	spot := a.ClassHoldings(portfolio, config.AssetClassSpotBTC)
+	treasury := a.ClassHoldings(portfolio, config.AssetClassBTCTreasury)
+
+	if spot.Value == 0 || treasury.Value == 0 {
+		fmt.Printf("⚠️  Dynamic rebalancing requires both spot BTC proxy and BTC treasury equity positions\n")
 		return
 	}
 
@@ -100,27 +303,73 @@ func performDynamicRebalancingAnalysis(portfolio *models.Portfolio, verbose bool
 		fmt.Printf("📋 Loaded Configuration:\n%s\n", rebalanceTable.GetConfigSummary())
 	}
 
-	// Calculate recommendation
-	recommendation, err := rebalanceTable.CalculateRebalanceRecommendation(
-		currentMNAV,
-		totalFBTCValue,
-		totalMSTRValue,
-		fbtcPrice,
-		mstrPrice,
+	// Advance the rule state machine and calculate the recommendation. A persisted step holds
+	// the state lock from load to save.
+	var (
+		state          *models.RebalancingState
+		recommendation *analyzer.RebalanceRecommendation
+		transition     *models.RebalancingTransition
 	)
-	if err != nil {
-		fmt.Printf("❌ Error calculating dynamic rebalancing: %v\n", err)
-		return
+	advance := func(loaded *models.RebalancingState) error {
+		state = loaded
+		recommendation, transition = rebalanceTable.CalculateStatefulRecommendation(
+			state,
+			currentMNAV,
+			evaluatedAt,
+			spot,
+			treasury,
+		)
+		return nil
+	}
+	stateTracker := tracker.NewTracker("data/portfolio/processed")
+	if persist {
+		if err := stateTracker.UpdateRebalancingState(advance); err != nil && state == nil {
+			fmt.Printf("❌ Error loading rebalancing state: %v\n", err)
+			return
+		} else if err != nil {
+			fmt.Printf("⚠️  Could not save rebalancing state: %v\n", err)
+		}
+	} else {
+		loaded, err := stateTracker.LoadRebalancingState()
+		if err != nil {
+			fmt.Printf("❌ Error loading rebalancing state: %v\n", err)
+			return
+		}
+		advance(loaded)
 	}
 
 	// Print holdings info and recommendation
 	fmt.Printf("\n💰 Current Holdings:\n")
-	fmt.Printf("   FBTC: %.2f shares ($%.2f total)\n", totalFBTCShares, totalFBTCValue)
-	fmt.Printf("   MSTR: %.2f shares ($%.2f total)\n", totalMSTRShares, totalMSTRValue)
+	sym := sharedmodels.CurrencySymbol(portfolio.Currency)
+	for _, holding := range []analyzer.ClassHolding{spot, treasury} {
+		fmt.Printf("   %s:\n", config.AssetClassLabel(holding.Class))
+		for _, summary := range holding.Symbols {
+			fmt.Printf("      %s: %.2f shares (%s%.2f total)\n", summary.Symbol, summary.TotalQuantity, sym, summary.TotalValue)
+		}
+	}
 	fmt.Printf("\n")
 
-	// Print the recommendation (includes its own header)
-	recommendation.Print()
+	// Print the recommendation (includes its own header), or the DCA schedule
+	if cash.DCAPeriods > 0 {
+		mnavs := cash.DCAMNAV
+		if len(mnavs) == 0 {
+			mnavs = []float64{currentMNAV}
+		}
+		schedule := rebalanceTable.DCASchedule(spot.Value, treasury.Value, state.ActiveRatio, cash.Deposit, cash.DCAPeriods, mnavs)
+		printDCASchedule(schedule, recommendation, spot, treasury, sym)
+	} else {
+		var plan *models.RebalanceRecommendation
+		if amount := cash.Deposit - cash.Withdraw; amount != 0 {
+			plan = a.PlanCashTrades(portfolio, recommendation, amount, cash.Account)
+		} else {
+			plan = a.PlanAccountTrades(portfolio, recommendation)
+		}
+		recommendation.Print()
+		if plan != nil {
+			printTaxImpact(plan, sym, a.Lots)
+		}
+	}
+	printRebalancingState(state, transition, persist)
 
 	// Add context about mNAV
 	fmt.Printf("📊 mNAV Context:\n")
@@ -129,9 +378,15 @@ func performDynamicRebalancingAnalysis(portfolio *models.Portfolio, verbose bool
 	fmt.Printf("   • MSTR Premium: %.1f%% above Bitcoin NAV\n", (currentMNAV-1.0)*100)
 
 	// Calculate Bitcoin exposure through MSTR
-	mstrBitcoinHoldings := 597325.0 // From our data update
-	mstrSharesOutstanding := 256473000.0
+	mstrBitcoinHoldings := market.BitcoinHoldings
+	mstrSharesOutstanding := market.SharesOutstanding
 	mstrBitcoinPerShare := mstrBitcoinHoldings / mstrSharesOutstanding
+	var totalMSTRShares float64
+	for _, summary := range treasury.Symbols {
+		if summary.Symbol == "MSTR" {
+			totalMSTRShares = summary.TotalQuantity
+		}
+	}
 	yourMSTRBitcoinExposure := totalMSTRShares * mstrBitcoinPerShare
 
 	fmt.Printf("   • Your MSTR Bitcoin Exposure: %.4f BTC\n", yourMSTRBitcoinExposure)
@@ -139,6 +394,96 @@ func performDynamicRebalancingAnalysis(portfolio *models.Portfolio, verbose bool
 	fmt.Printf("\n")
 }
 
+// printTaxImpact shows the gains and estimated tax of the plan's taxable sells
+func printTaxImpact(plan *models.RebalanceRecommendation, sym string, lots *models.TaxLots) {
+	taxable := false
+	for _, trade := range plan.Trades {
+		taxable = taxable || trade.Action == "SELL" && !config.TaxAdvantaged(trade.AccountType)
+	}
+	if !taxable {
+		return
+	}
+
+	fmt.Printf("🧾 Tax Impact:\n")
+	for _, trade := range plan.Trades {
+		if trade.Action != "SELL" || config.TaxAdvantaged(trade.AccountType) {
+			continue
+		}
+		fmt.Printf("   SELL %.4f %s in %s: gain %s%.2f (short-term %s%.2f, long-term %s%.2f), estimated tax %s%.2f\n",
+			trade.Shares, trade.Symbol, models.MaskAccount(trade.Account), sym, trade.RealizedGain(), sym, trade.ShortTermGain,
+			sym, trade.LongTermGain, sym, trade.EstimatedTax)
+		for _, sale := range trade.Lots {
+			term := "short-term"
+			if sale.LongTerm {
+				term = "long-term"
+			}
+			fmt.Printf("        lot %s: %.4f shares, gain %s%.2f, %s\n", sale.Acquired.Format("2006-01-02"), sale.Quantity, sym, sale.Gain, term)
+		}
+		if trade.TaxNote != "" {
+			fmt.Printf("        ℹ️  %s\n", trade.TaxNote)
+		}
+	}
+	fmt.Printf("   Realized gain: %s%.2f, estimated tax: %s%.2f\n", sym, plan.RealizedGain, sym, plan.EstimatedTax)
+	if lots == nil || len(lots.Lots) == 0 {
+		fmt.Printf("   💡 Import tax lots with portfolio-lots -csv for lot-level gains\n")
+	}
+	fmt.Printf("\n")
+}
+
+// printDCASchedule shows how each tranche of a DCA schedule is split between the classes
+func printDCASchedule(schedule []analyzer.DCATranche, rec *analyzer.RebalanceRecommendation, spot, treasury analyzer.ClassHolding, sym string) {
+	fmt.Printf("📅 DCA SCHEDULE\n")
+	fmt.Printf("===============\n\n")
+	fmt.Printf("   Current Spot BTC:Treasury Ratio: %.2f:1\n", rec.CurrentRatio)
+	fmt.Printf("   Target Spot BTC:Treasury Ratio:  %.2f:1\n", rec.TargetRatio)
+	fmt.Printf("   %s\n\n", rec.Explanation)
+
+	money := func(value float64) string { return fmt.Sprintf("%s%.2f", sym, value) }
+	fmt.Printf("   %-6s %6s %8s %12s %12s %12s %8s\n", "Period", "mNAV", "Target", "Amount", spot.Symbol, treasury.Symbol, "Ratio")
+	var toSpot, toTreasury float64
+	for _, tranche := range schedule {
+		fmt.Printf("   %-6d %6.2f %8s %12s %12s %12s %8s\n", tranche.Period, tranche.MNAV, fmt.Sprintf("%.2f:1", tranche.TargetRatio),
+			money(tranche.Amount), money(tranche.Spot), money(tranche.Treasury), fmt.Sprintf("%.2f:1", tranche.Ratio))
+		toSpot += tranche.Spot
+		toTreasury += tranche.Treasury
+	}
+	fmt.Printf("   Total: %s%.2f into %s (%.2f shares), %s%.2f into %s (%.2f shares) at current prices\n",
+		sym, toSpot, spot.Symbol, shares(toSpot, spot.Price), sym, toTreasury, treasury.Symbol, shares(toTreasury, treasury.Price))
+	if last := schedule[len(schedule)-1]; math.Abs(last.Ratio-last.TargetRatio) > 0.005 {
+		fmt.Printf("   ⚠️  The deposit leaves the ratio at %.2f:1; the rest needs a sell-and-buy rebalance\n", last.Ratio)
+	}
+	fmt.Printf("\n")
+}
+
+// shares converts a value into shares, or 0 without a price
+func shares(value, price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return value / price
+}
+
+// printRebalancingState shows the active rule and its most recent transition
+func printRebalancingState(state *models.RebalancingState, transition *models.RebalancingTransition, persisted bool) {
+	fmt.Printf("🔁 Rule State:\n")
+	fmt.Printf("   Active Ratio: %v:1 (band %.4f - %.4f), since %s\n",
+		state.ActiveRatio, state.DownThreshold, state.UpThreshold, state.ActiveSince.Format("2006-01-02"))
+	if transition != nil {
+		fmt.Printf("   🔀 Transition now: %s\n", transition.Reason)
+	}
+	if last := state.LastTransition(); last != nil {
+		from := "start"
+		if last.FromRatio != 0 {
+			from = fmt.Sprintf("%v:1", last.FromRatio)
+		}
+		fmt.Printf("   Last Transition: %s %s → %v:1 at mNAV %.4f\n", last.Date.Format("2006-01-02"), from, last.ToRatio, last.MNAV)
+	}
+	if !persisted {
+		fmt.Printf("   (state not saved: only live analysis of the latest snapshot updates it)\n")
+	}
+	fmt.Printf("\n")
+}
+
 func getStrategyDescription(mnav float64) string {
 	switch {
 	case mnav < 1.5:
@@ -157,39 +502,34 @@ func getStrategyDescription(mnav float64) string {
 func displayPortfolioAnalysis(portfolio *models.Portfolio, verbose bool) {
 	fmt.Printf("📊 Portfolio Analysis - %s\n", portfolio.Date.Format("January 2, 2006"))
 	fmt.Printf("============================================================\n")
-	fmt.Printf("💰 Total Portfolio Value: $%.2f\n", portfolio.TotalValue)
-	fmt.Printf("📈 Total Gain/Loss: $%.2f (%.2f%%)\n",
-		portfolio.TotalGainLoss, portfolio.TotalGainLossPct)
+	sym := sharedmodels.CurrencySymbol(portfolio.Currency)
+	fmt.Printf("💰 Total Portfolio Value: %s%.2f\n", sym, portfolio.TotalValue)
+	fmt.Printf("📈 Total Gain/Loss: %s%.2f (%.2f%%)\n",
+		sym, portfolio.TotalGainLoss, portfolio.TotalGainLossPct)
 
-	// Calculate Bitcoin exposure
-	bitcoinExposure := 0.0
-	fbtcValue := 0.0
-	mstrValue := 0.0
+	allocation := portfolio.AssetAllocation
+	fmt.Printf("₿  Bitcoin Exposure: %s%.2f (%.1f%%)\n", sym, allocation.BitcoinExposure, allocation.BitcoinPercent)
 
-	for _, pos := range portfolio.Positions {
-		if pos.Symbol == "FBTC" || pos.Symbol == "MSTR" {
-			bitcoinExposure += pos.CurrentValue
-			if pos.Symbol == "FBTC" {
-				fbtcValue += pos.CurrentValue // Sum all FBTC positions
-			} else if pos.Symbol == "MSTR" {
-				mstrValue += pos.CurrentValue // Sum all MSTR positions
-			}
-		}
+	if allocation.SpotTreasuryRatio > 0 {
+		fmt.Printf("⚖️  Spot BTC:Treasury Ratio: %.2f:1\n", allocation.SpotTreasuryRatio)
+	}
+	if allocation.LookThrough != nil {
+		displayLookThrough(allocation.LookThrough, sym)
 	}
 
-	bitcoinPercent := (bitcoinExposure / portfolio.TotalValue) * 100
-	fmt.Printf("₿  Bitcoin Exposure: $%.2f (%.1f%%)\n", bitcoinExposure, bitcoinPercent)
-
-	if fbtcValue > 0 && mstrValue > 0 {
-		ratio := fbtcValue / mstrValue
-		fmt.Printf("⚖️  FBTC/MSTR Ratio: %.2f:1\n", ratio)
+	// Asset classes
+	fmt.Printf("\n🧩 Asset Classes:\n")
+	for _, class := range config.AssetClasses {
+		if c, ok := allocation.Classes[class]; ok {
+			fmt.Printf("   %-25s %s%9.2f (%5.1f%%)\n", config.AssetClassLabel(class), sym, c.Value, c.Percent)
+		}
 	}
 
 	// Account breakdown
 	fmt.Printf("\n🏦 Account Breakdown:\n")
 	for name, account := range portfolio.Accounts {
 		percent := (account.TotalValue / portfolio.TotalValue) * 100
-		fmt.Printf("   %-25s $%9.2f (%5.1f%%)\n", name, account.TotalValue, percent)
+		fmt.Printf("   %-25s %s%9.2f (%5.1f%%)\n", models.MaskAccount(name), sym, account.TotalValue, percent)
 	}
 
 	// Top holdings
@@ -247,8 +587,8 @@ func displayPortfolioAnalysis(portfolio *models.Portfolio, verbose bool) {
 				description = description[:25] + "..."
 			}
 
-			fmt.Printf("   %-4s %-25s $%9.2f (%5.1f%%) [%.2f shares @ $%.2f]\n",
-				sv.symbol, description, sv.value, percent, totalShares, avgPrice)
+			fmt.Printf("   %-4s %-25s %s%9.2f (%5.1f%%) [%.2f shares @ %s%.2f]\n",
+				sv.symbol, description, sym, sv.value, percent, totalShares, sym, avgPrice)
 		}
 	}
 
@@ -256,124 +596,320 @@ func displayPortfolioAnalysis(portfolio *models.Portfolio, verbose bool) {
 		fmt.Printf("\n📋 Detailed Holdings:\n")
 		for _, pos := range portfolio.Positions {
 			if pos.CurrentValue > 50 { // Show positions over $50
-				fmt.Printf("   %s: %.2f shares @ $%.2f = $%.2f\n",
-					pos.Symbol, pos.Quantity, pos.LastPrice, pos.CurrentValue)
+				fmt.Printf("   %s: %.2f shares @ %s%.2f = %s%.2f\n",
+					pos.Symbol, pos.Quantity, sym, pos.LastPrice, sym, pos.CurrentValue)
 			}
 		}
 	}
 }
 
+// displayLookThrough shows the Bitcoin owned through each position and the premium paid for it
+func displayLookThrough(lookThrough *models.LookThroughExposure, sym string) {
+	fmt.Printf("\n🔍 Look-Through Bitcoin (BTC at %s%.2f):\n", sym, lookThrough.BitcoinPrice)
+	fmt.Printf("   Sats Owned: %d (%.8f BTC)\n", lookThrough.Sats, lookThrough.BTC)
+	fmt.Printf("   Leverage-Adjusted Exposure: %.4f BTC\n", lookThrough.EffectiveBTC)
+	fmt.Printf("   Premium Paid vs Holding BTC: %s%.2f (%.1f%%)\n", sym, lookThrough.PremiumPaid, lookThrough.PremiumPercent)
+	for _, p := range lookThrough.Positions {
+		if p.BTCPerShare > 0 {
+			fmt.Printf("   %-6s %12.8f BTC (%.8f BTC/share, mNAV %.2f, leverage %.2fx, %s)\n",
+				p.Symbol, p.BTC, p.BTCPerShare, p.MNAV(), p.Leverage, p.Source)
+			continue
+		}
+		fmt.Printf("   %-6s %12.8f BTC (%s)\n", p.Symbol, p.BTC, p.Source)
+	}
+}
+
 // Helper functions (simplified versions)
-func getLatestPortfolioDate() string {
+// getLatestPortfolioDate returns the newest snapshot date, or the newest on or before notAfter if set
+func getLatestPortfolioDate(notAfter string) string {
 	files, err := filepath.Glob("data/portfolio/processed/portfolio_*.json")
 	if err != nil || len(files) == 0 {
 		return ""
 	}
 	sort.Strings(files)
-	latest := files[len(files)-1]
 
-	// Extract date from filename
-	base := filepath.Base(latest)
-	if len(base) >= 19 {
-		return base[10:20] // Extract YYYY-MM-DD from portfolio_YYYY-MM-DD.json
+	for i := len(files) - 1; i >= 0; i-- {
+		// Extract date from filename
+		base := filepath.Base(files[i])
+		if len(base) < 19 {
+			continue
+		}
+		date := base[10:20] // Extract YYYY-MM-DD from portfolio_YYYY-MM-DD.json
+		if notAfter == "" || date <= notAfter {
+			return date
+		}
 	}
 	return ""
 }
 
+// loadPortfolioData loads a snapshot through the tracker, which upgrades and decrypts it
 func loadPortfolioData(date string) (*models.Portfolio, error) {
-	filename := fmt.Sprintf("data/portfolio/processed/portfolio_%s.json", date)
-	data, err := os.ReadFile(filename)
+	day, err := time.Parse("2006-01-02", date)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid portfolio date %q: %w", date, err)
 	}
+	return tracker.NewTracker("data/portfolio/processed").Load(day)
+}
 
-	var portfolio models.Portfolio
-	if err := json.Unmarshal(data, &portfolio); err != nil {
-		return nil, err
+// showPerformanceMetrics prints returns with deposits and withdrawals taken out, and the risk
+// of the daily values reconstructed between snapshots
+func showPerformanceMetrics(a *analyzer.Analyzer, opts ledger.RiskOptions, asJSON bool) {
+	t := tracker.NewTracker("data/portfolio/processed")
+	metrics, err := t.GetPerformanceMetrics()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	book, err := t.LoadLedger()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	var riskErr error
+	if snapshots, err := loadSnapshots(a, t); err != nil {
+		riskErr = err
+	} else {
+		metrics.Risk, riskErr = a.DailyRisk(snapshots, book.Transactions, opts)
 	}
 
-	return &portfolio, nil
-}
-
-func showHistoricalSummary() {
-	files, err := filepath.Glob("data/portfolio/processed/portfolio_*.json")
-	if err != nil || len(files) == 0 {
-		fmt.Printf("❌ No portfolio data found\n")
+	if asJSON {
+		data, err := json.MarshalIndent(metrics, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ Error encoding metrics: %v", err)
+		}
+		fmt.Println(string(data))
 		return
 	}
 
-	sort.Strings(files)
+	if len(book.Transactions) == 0 {
+		fmt.Printf("⚠️  No transactions ledger: deposits and withdrawals count as performance (see portfolio-ledger -csv)\n\n")
+	}
 
-	fmt.Printf("📈 Historical Portfolio Summary (%d snapshots)\n", len(files))
+	fmt.Printf("📈 Performance Metrics\n")
 	fmt.Printf("================================================================================\n")
+	fmt.Printf("   Period: %s to %s\n", metrics.StartDate.Format("2006-01-02"), metrics.EndDate.Format("2006-01-02"))
+	fmt.Printf("   Starting Value: $%.2f\n", metrics.StartValue)
+	fmt.Printf("   Ending Value: $%.2f\n", metrics.EndValue)
+	fmt.Printf("   Net Contributions: $%.2f\n", metrics.NetContributions)
+	fmt.Printf("   Investment Gain: $%.2f\n", metrics.TotalReturn)
+	fmt.Printf("   Time-Weighted Return: %.2f%%\n", metrics.TotalReturnPercent)
+	fmt.Printf("   CAGR: %.2f%%\n", metrics.CAGR)
+	fmt.Printf("   Money-Weighted Return (XIRR): %.2f%%\n", metrics.XIRR)
+	fmt.Printf("   Volatility: %.2f%%\n", metrics.Volatility)
+	fmt.Printf("   Max Drawdown: %.2f%%\n", metrics.MaxDrawdown)
+
+	if riskErr != nil {
+		fmt.Printf("\n⚠️  No risk metrics: %v\n", riskErr)
+		return
+	}
+	printRiskMetrics(metrics.Risk)
+}
 
-	var previousValue float64
-	for i, file := range files {
-		base := filepath.Base(file)
-		if len(base) < 19 {
-			continue
-		}
-
-		date := base[10:20]
-		portfolio, err := loadPortfolioData(date)
+// loadSnapshots loads every snapshot in the analyzer's reporting currency
+func loadSnapshots(a *analyzer.Analyzer, t *tracker.Tracker) ([]*models.Portfolio, error) {
+	dates, err := t.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*models.Portfolio
+	for _, date := range dates {
+		portfolio, err := t.Load(date)
 		if err != nil {
-			continue
+			continue // Skip corrupted files
+		}
+		if portfolio, err = a.ConvertPortfolio(portfolio, a.Currency); err != nil {
+			return nil, fmt.Errorf("error converting %s: %w", date.Format("2006-01-02"), err)
 		}
+		snapshots = append(snapshots, portfolio)
+	}
+	return snapshots, nil
+}
 
-		// Calculate Bitcoin exposure
-		bitcoinExposure := 0.0
-		fbtcValue := 0.0
-		mstrValue := 0.0
+// printRiskMetrics shows the risk of the daily values and a sample of the rolling windows
+func printRiskMetrics(risk *ledger.RiskMetrics) {
+	confidence := risk.Confidence * 100
+	fmt.Printf("\n📉 Risk Metrics (daily values, %d returns)\n", risk.Returns)
+	fmt.Printf("================================================================================\n")
+	fmt.Printf("   Volatility: %.2f%% (downside %.2f%%)\n", risk.Volatility, risk.DownsideDev)
+	fmt.Printf("   1-Day VaR %.0f%%: %.2f%% historical, %.2f%% parametric\n", confidence, risk.HistoricalVaR, risk.ParametricVaR)
+	fmt.Printf("   1-Day CVaR %.0f%%: %.2f%% historical, %.2f%% parametric\n", confidence, risk.HistoricalCVaR, risk.ParametricCVaR)
+	fmt.Printf("   Sharpe Ratio: %.2f (risk-free %.2f%%)\n", risk.Sharpe, risk.RiskFreeRate*100)
+	fmt.Printf("   Sortino Ratio: %.2f\n", risk.Sortino)
+	fmt.Printf("   Beta to BTC: %.2f (correlation %.2f)\n", risk.Beta, risk.Correlation)
+
+	if len(risk.Rolling) == 0 {
+		return
+	}
+	fmt.Printf("\n   Rolling %d-day windows:\n", risk.Window)
+	fmt.Printf("   %-10s %10s %9s %8s %8s %6s %6s\n", "End", "Volatility", "VaR", "Sharpe", "Sortino", "Beta", "Corr")
+	step := (len(risk.Rolling) + 11) / 12 // At most 12 rows, always ending with the latest
+	for i := (len(risk.Rolling) - 1) % step; i < len(risk.Rolling); i += step {
+		r := risk.Rolling[i]
+		fmt.Printf("   %-10s %9.2f%% %8.2f%% %8.2f %8.2f %6.2f %6.2f\n", r.End.Format("2006-01-02"),
+			r.Volatility, r.HistoricalVaR, r.Sharpe, r.Sortino, r.Beta, r.Correlation)
+	}
+}
 
-		for _, pos := range portfolio.Positions {
-			if pos.Symbol == "FBTC" || pos.Symbol == "MSTR" {
-				bitcoinExposure += pos.CurrentValue
-				if pos.Symbol == "FBTC" {
-					fbtcValue = pos.CurrentValue
-				} else if pos.Symbol == "MSTR" {
-					mstrValue = pos.CurrentValue
-				}
-			}
+// showHistoricalSummary lists every snapshot in the analyzer's reporting currency, and the
+// month-end values of the daily series reconstructed between them
+func showHistoricalSummary(a *analyzer.Analyzer, asJSON bool) {
+	t := tracker.NewTracker("data/portfolio/processed")
+	values, snapshots, err := dailySeries(a, t)
+	if asJSON {
+		if err != nil {
+			log.Fatalf("❌ %v", err)
 		}
-
-		bitcoinPercent := (bitcoinExposure / portfolio.TotalValue) * 100
-		ratio := 0.0
-		if mstrValue > 0 {
-			ratio = fbtcValue / mstrValue
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ Error encoding daily values: %v", err)
 		}
+		fmt.Println(string(data))
+		return
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("❌ No portfolio data found\n")
+		return
+	}
+
+	sym := sharedmodels.CurrencySymbol(a.Currency)
+	fmt.Printf("📈 Historical Portfolio Summary (%d snapshots)\n", len(snapshots))
+	fmt.Printf("================================================================================\n")
+
+	var previousValue float64
+	for i, portfolio := range snapshots {
+		bitcoinPercent := portfolio.AssetAllocation.BitcoinPercent
+		ratio := portfolio.AssetAllocation.SpotTreasuryRatio
 
 		changeText := ""
 		if i > 0 && previousValue > 0 {
 			change := portfolio.TotalValue - previousValue
 			changePercent := (change / previousValue) * 100
 			if change >= 0 {
-				changeText = fmt.Sprintf(" | Δ $+%.2f (+%.2f%%)", change, changePercent)
+				changeText = fmt.Sprintf(" | Δ %s+%.2f (+%.2f%%)", sym, change, changePercent)
 			} else {
-				changeText = fmt.Sprintf(" | Δ $%.2f (%.2f%%)", change, changePercent)
+				changeText = fmt.Sprintf(" | Δ %s%.2f (%.2f%%)", sym, change, changePercent)
 			}
 		}
 
-		fmt.Printf("%s | $%9.2f | ₿ %4.1f%% | Ratio: %5.2f:1%s\n",
-			date, portfolio.TotalValue, bitcoinPercent, ratio, changeText)
+		fmt.Printf("%s | %s%9.2f | ₿ %4.1f%% | Ratio: %5.2f:1%s\n",
+			portfolio.Date.Format("2006-01-02"), sym, portfolio.TotalValue, bitcoinPercent, ratio, changeText)
 
 		previousValue = portfolio.TotalValue
 	}
 
-	if len(files) >= 2 {
-		firstPortfolio, _ := loadPortfolioData(files[0][10:20])
-		lastPortfolio, _ := loadPortfolioData(files[len(files)-1][10:20])
+	if err != nil {
+		fmt.Printf("\n⚠️  No daily values: %v\n", err)
+	} else if len(values) > 1 {
+		fmt.Printf("\n📅 Month-End Values (daily series, ~ reconstructed between snapshots)\n")
+		previousValue = values[0].Value
+		for i, value := range values {
+			if i+1 < len(values) && values[i+1].Date.Month() == value.Date.Month() {
+				continue
+			}
+			marker := " "
+			if value.Interpolated {
+				marker = "~"
+			}
+			change := 0.0
+			if previousValue > 0 {
+				change = (value.Value/previousValue - 1) * 100
+			}
+			fmt.Printf("%s%s | %s%9.2f | %+6.2f%%\n", value.Date.Format("2006-01-02"), marker, sym, value.Value, change)
+			previousValue = value.Value
+		}
+	}
+
+	if len(snapshots) >= 2 {
+		firstPortfolio, lastPortfolio := snapshots[0], snapshots[len(snapshots)-1]
+		totalReturn := lastPortfolio.TotalValue - firstPortfolio.TotalValue
+		totalReturnPercent := (totalReturn / firstPortfolio.TotalValue) * 100
+
+		fmt.Printf("\n📊 Overall Performance:\n")
+		fmt.Printf("   Period: %s to %s\n",
+			firstPortfolio.Date.Format("2006-01-02"),
+			lastPortfolio.Date.Format("2006-01-02"))
+		fmt.Printf("   Total Return: %s%.2f (%.2f%%)\n", sym, totalReturn, totalReturnPercent)
+		fmt.Printf("   Starting Value: %s%.2f\n", sym, firstPortfolio.TotalValue)
+		fmt.Printf("   Ending Value: %s%.2f\n", sym, lastPortfolio.TotalValue)
+	}
+}
 
-		if firstPortfolio != nil && lastPortfolio != nil {
-			totalReturn := lastPortfolio.TotalValue - firstPortfolio.TotalValue
-			totalReturnPercent := (totalReturn / firstPortfolio.TotalValue) * 100
+// dailySeries reconstructs the daily values from the first to the last snapshot with the
+// ledger's transactions. The snapshots are returned even when the series can't be built.
+func dailySeries(a *analyzer.Analyzer, t *tracker.Tracker) ([]analyzer.DailyValue, []*models.Portfolio, error) {
+	snapshots, err := loadSnapshots(a, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, nil, fmt.Errorf("no portfolio snapshots found")
+	}
+	book, err := t.LoadLedger()
+	if err != nil {
+		return nil, snapshots, err
+	}
+	values, err := a.DailyValues(snapshots, book.Transactions, snapshots[len(snapshots)-1].Date)
+	return values, snapshots, err
+}
 
-			fmt.Printf("\n📊 Overall Performance:\n")
-			fmt.Printf("   Period: %s to %s\n",
-				firstPortfolio.Date.Format("2006-01-02"),
-				lastPortfolio.Date.Format("2006-01-02"))
-			fmt.Printf("   Total Return: $%.2f (%.2f%%)\n", totalReturn, totalReturnPercent)
-			fmt.Printf("   Starting Value: $%.2f\n", firstPortfolio.TotalValue)
-			fmt.Printf("   Ending Value: $%.2f\n", lastPortfolio.TotalValue)
+// saveValueChart renders the daily portfolio values and the Bitcoin price, with a marker on each
+// snapshot; the lines between markers are reconstructed
+func saveValueChart(a *analyzer.Analyzer, path string) error {
+	format := chart.Format(strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	if format != chart.SVG && format != chart.PNG {
+		return fmt.Errorf("chart must be .svg or .png, got %s", path)
+	}
+	values, snapshots, err := dailySeries(a, tracker.NewTracker("data/portfolio/processed"))
+	if err != nil {
+		return err
+	}
+
+	dates := make([]time.Time, len(values))
+	portfolioValues := make([]float64, len(values))
+	bitcoinValues := make([]float64, len(values))
+	var bitcoin []repository.PricePoint
+	if a.Prices != nil && len(values) > 0 {
+		bitcoin, _ = a.Prices.GetPrices(repository.BitcoinSymbol, values[0].Date.AddDate(0, 0, -7), values[len(values)-1].Date)
+	}
+	for i, value := range values {
+		dates[i], portfolioValues[i] = value.Date, value.Value
+		bitcoinValues[i] = math.NaN()
+		j := sort.Search(len(bitcoin), func(j int) bool { return bitcoin[j].Date.After(value.Date) })
+		if j > 0 {
+			bitcoinValues[i] = bitcoin[j-1].Close
 		}
 	}
+	var annotations []chart.Annotation
+	for _, snapshot := range snapshots {
+		annotations = append(annotations, chart.Annotation{
+			Start:   snapshot.Date,
+			Group:   "Snapshot",
+			Details: []string{fmt.Sprintf("Snapshot %s: %.2f", snapshot.Date.Format("2006-01-02"), snapshot.TotalValue)},
+			Color:   color.RGBA{102, 102, 102, 160},
+		})
+	}
+
+	sym := sharedmodels.CurrencySymbol(a.Currency)
+	money := func(v float64) string { return sym + chart.FormatCompact(v) }
+	c := &chart.Chart{
+		Title: "Daily Portfolio Value",
+		Dates: dates,
+		Series: []chart.Series{
+			{Name: "Portfolio", Values: portfolioValues, Color: color.RGBA{54, 162, 235, 255}, Axis: chart.Left},
+			{Name: "BTC", Values: bitcoinValues, Color: color.RGBA{247, 147, 26, 255}, Axis: chart.Right},
+		},
+		Annotations: annotations,
+		Left:        chart.YAxis{Label: "Portfolio", Format: money},
+		Right:       chart.YAxis{Label: "BTC", Format: func(v float64) string { return "$" + chart.FormatCompact(v) }},
+	}
+
+	var buf bytes.Buffer
+	if err := c.Render(&buf, format); err != nil {
+		return fmt.Errorf("failed to render chart: %w", err)
+	}
+	if err := storage.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("💾 %s chart of %d daily values saved to: %s\n", format, len(values), path)
+	return nil
 }
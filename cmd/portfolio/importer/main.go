@@ -6,16 +6,31 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/ultrarare-tech/mNAV/pkg/config"
 	"github.com/ultrarare-tech/mNAV/pkg/portfolio/analyzer"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/importer"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
 	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 func main() {
 	var (
-		csvFile = flag.String("csv", "", "Path to portfolio CSV file")
-		dataDir = flag.String("data", "data/portfolio/processed", "Directory to store processed portfolio data")
-		verbose = flag.Bool("v", false, "Verbose output")
+		csvFile         = flag.String("csv", "", "Path to portfolio CSV file")
+		broker          = flag.String("broker", "auto", "Export format: auto, fidelity, schwab, vanguard, ibkr, coinbase, kraken or generic")
+		mapping         = flag.String("mapping", "", "Column mapping JSON for -broker generic")
+		dataDir         = flag.String("data", "data/portfolio/processed", "Directory to store processed portfolio data")
+		verbose         = flag.Bool("v", false, "Verbose output")
+		currency        = flag.String("currency", "USD", "Currency for portfolio totals")
+		accountCurrency = flag.String("account-currency", "", "Currencies of non-USD accounts as ACCOUNT=CUR pairs, e.g. Z123=EUR,Z456=JPY")
+		fxDir           = flag.String("fx", "data/fx", "Directory of stored FX rates")
+		classesPath     = flag.String("classes", config.AssetClassPath, "Asset classification JSON (missing file uses the defaults)")
 	)
 	flag.Parse()
 
@@ -31,22 +46,51 @@ func main() {
 	}
 
 	// Create analyzer and tracker
+	fx, err := storage.NewFXStorage(*fxDir).LoadTable()
+	if err != nil {
+		log.Fatalf("Failed to load FX rates: %v", err)
+	}
+	accountCurrencies, err := parseAccountCurrencies(*accountCurrency)
+	if err != nil {
+		log.Fatalf("Invalid -account-currency: %v", err)
+	}
 	analyzer := analyzer.NewAnalyzer()
+	analyzer.Currency = *currency
+	analyzer.FX = fx
+	analyzer.AccountCurrencies = accountCurrencies
+	store := repository.NewJSONStore(".")
+	analyzer.Prices = store.Prices()
+	analyzer.Transactions = store.Transactions()
+	analyzer.Shares = store.Shares()
+	if analyzer.Classes, err = config.LoadAssetClassification(*classesPath); err != nil {
+		log.Fatalf("Failed to load asset classes: %v", err)
+	}
+	if companies, err := config.LoadCompaniesConfig("."); err == nil {
+		analyzer.Classes.AddTreasuryCompanies(companies)
+		analyzer.Companies = companies
+	}
 	tracker := tracker.NewTracker(*dataDir)
+	analyzer.Files = tracker.Files()
+
+	imp, err := selectImporter(*broker, *mapping)
+	if err != nil {
+		log.Fatalf("Invalid -broker: %v", err)
+	}
 
 	if *verbose {
 		log.Printf("Parsing CSV file: %s", *csvFile)
 	}
 
 	// Parse the CSV file
-	portfolio, err := analyzer.ParseCSV(*csvFile)
+	portfolio, result, err := analyzer.ImportCSV(*csvFile, imp)
 	if err != nil {
 		log.Fatalf("Failed to parse CSV: %v", err)
 	}
+	printImportReport(result)
 
 	if *verbose {
 		log.Printf("Successfully parsed portfolio with %d positions", len(portfolio.Positions))
-		log.Printf("Total portfolio value: $%.2f", portfolio.TotalValue)
+		log.Printf("Total portfolio value: %s%.2f", sharedmodels.CurrencySymbol(portfolio.Currency), portfolio.TotalValue)
 		log.Printf("Portfolio date: %s", portfolio.Date.Format("2006-01-02"))
 	}
 
@@ -63,7 +107,7 @@ func main() {
 		rawFileName := fmt.Sprintf("portfolio_%s.csv", portfolio.Date.Format("2006-01-02"))
 		rawPath := filepath.Join(rawDir, rawFileName)
 
-		if err := copyFile(*csvFile, rawPath); err != nil {
+		if err := copyFile(tracker.Files(), *csvFile, rawPath); err != nil {
 			log.Printf("Warning: Failed to copy CSV to raw directory: %v", err)
 		} else if *verbose {
 			log.Printf("Copied CSV to: %s", rawPath)
@@ -71,38 +115,90 @@ func main() {
 	}
 
 	fmt.Printf("✅ Successfully imported portfolio data for %s\n", portfolio.Date.Format("2006-01-02"))
+	sym := sharedmodels.CurrencySymbol(portfolio.Currency)
 	fmt.Printf("📊 Portfolio Summary:\n")
-	fmt.Printf("   Total Value: $%.2f\n", portfolio.TotalValue)
-	fmt.Printf("   Total Gain/Loss: $%.2f (%.2f%%)\n", portfolio.TotalGainLoss, portfolio.TotalGainLossPct)
-	fmt.Printf("   Bitcoin Exposure: $%.2f (%.1f%%)\n", portfolio.AssetAllocation.BitcoinExposure, portfolio.AssetAllocation.BitcoinPercent)
-	fmt.Printf("   FBTC/MSTR Ratio: %.2f:1\n", portfolio.AssetAllocation.FBTCMSTRRatio)
+	fmt.Printf("   Total Value: %s%.2f\n", sym, portfolio.TotalValue)
+	fmt.Printf("   Total Gain/Loss: %s%.2f (%.2f%%)\n", sym, portfolio.TotalGainLoss, portfolio.TotalGainLossPct)
+	fmt.Printf("   Bitcoin Exposure: %s%.2f (%.1f%%)\n", sym, portfolio.AssetAllocation.BitcoinExposure, portfolio.AssetAllocation.BitcoinPercent)
+	if lookThrough := portfolio.AssetAllocation.LookThrough; lookThrough != nil {
+		fmt.Printf("   Sats Owned: %d (%.8f BTC, %.4f BTC leverage-adjusted)\n", lookThrough.Sats, lookThrough.BTC, lookThrough.EffectiveBTC)
+		fmt.Printf("   Premium Paid vs Holding BTC: %s%.2f (%.1f%%)\n", sym, lookThrough.PremiumPaid, lookThrough.PremiumPercent)
+	}
+	fmt.Printf("   Spot BTC:Treasury Ratio: %.2f:1\n", portfolio.AssetAllocation.SpotTreasuryRatio)
 
 	fmt.Printf("\n🏦 Account Breakdown:\n")
 	for name, account := range portfolio.Accounts {
-		fmt.Printf("   %s: $%.2f\n", name, account.TotalValue)
+		fmt.Printf("   %s: %s%.2f\n", models.MaskAccount(name), sym, account.TotalValue)
 	}
 
 	fmt.Printf("\n💰 Asset Allocation:\n")
-	fmt.Printf("   FBTC: $%.2f (%.1f%%)\n", portfolio.AssetAllocation.FBTCValue, portfolio.AssetAllocation.FBTCPercent)
-	fmt.Printf("   MSTR: $%.2f (%.1f%%)\n", portfolio.AssetAllocation.MSTRValue, portfolio.AssetAllocation.MSTRPercent)
-	fmt.Printf("   GLD:  $%.2f (%.1f%%)\n", portfolio.AssetAllocation.GLDValue, portfolio.AssetAllocation.GLDPercent)
-	fmt.Printf("   Other: $%.2f (%.1f%%)\n", portfolio.AssetAllocation.OtherValue, portfolio.AssetAllocation.OtherPercent)
+	for _, class := range config.AssetClasses {
+		allocation, ok := portfolio.AssetAllocation.Classes[class]
+		if !ok {
+			continue
+		}
+		symbols := make([]string, 0, len(allocation.Symbols))
+		for symbol := range allocation.Symbols {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		fmt.Printf("   %-20s %s%.2f (%.1f%%) %s\n", config.AssetClassLabel(class)+":", sym, allocation.Value, allocation.Percent, strings.Join(symbols, ", "))
+	}
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
+// selectImporter returns the importer for -broker, or nil to detect it from the file
+func selectImporter(broker, mapping string) (importer.Importer, error) {
+	switch strings.ToLower(broker) {
+	case "", "auto":
+		if mapping != "" {
+			return nil, fmt.Errorf("-mapping needs -broker generic")
+		}
+		return nil, nil
+	case "generic":
+		if mapping == "" {
+			return nil, fmt.Errorf("-broker generic needs -mapping")
+		}
+		return importer.LoadGenericMapping(mapping)
+	}
+	return importer.ByName(broker)
+}
+
+// printImportReport shows which importer was used and what it could not map
+func printImportReport(result *importer.Result) {
+	fmt.Printf("🔎 Format: %s", result.Broker)
+	if result.DateSource != "" {
+		fmt.Printf(", as of %s (from %s)", result.Date.Format("2006-01-02"), result.DateSource)
+	}
+	fmt.Printf("\n")
+	if len(result.UnmappedColumns) > 0 {
+		fmt.Printf("⚠️  Unmapped columns: %s\n", strings.Join(result.UnmappedColumns, ", "))
+	}
+	for _, warning := range result.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
 	}
-	defer sourceFile.Close()
+}
 
-	destFile, err := os.Create(dst)
+// parseAccountCurrencies parses ACCOUNT=CUR pairs
+func parseAccountCurrencies(value string) (map[string]string, error) {
+	currencies := make(map[string]string)
+	if value == "" {
+		return currencies, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		account, currency, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || account == "" || currency == "" {
+			return nil, fmt.Errorf("expected ACCOUNT=CUR, got %q", pair)
+		}
+		currencies[strings.TrimSpace(account)] = sharedmodels.CurrencyCode(currency)
+	}
+	return currencies, nil
+}
+
+// copyFile copies a file from src to dst, sealing the copy when the portfolio is encrypted
+func copyFile(files *vault.Files, src, dst string) error {
+	data, err := files.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
-
-	_, err = destFile.ReadFrom(sourceFile)
-	return err
+	return files.WriteFile(dst, data, 0644)
 }
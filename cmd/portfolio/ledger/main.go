@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/importer"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/ledger"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+)
+
+func main() {
+	var (
+		csvFile     = flag.String("csv", "", "Broker activity export to add to the ledger")
+		reconcile   = flag.Bool("reconcile", false, "Compare the holdings the ledger implies with a snapshot")
+		from        = flag.String("from", "", "Opening snapshot date (YYYY-MM-DD); -reconcile starts from nothing without it, returns from the first snapshot")
+		to          = flag.String("to", "", "Closing snapshot date (YYYY-MM-DD, default latest)")
+		list        = flag.Int("list", 0, "List the last N ledger transactions")
+		classesPath = flag.String("classes", config.AssetClassPath, "Asset classification JSON (cash class positions are pooled)")
+		dataDir     = flag.String("data", "data/portfolio/processed", "Directory of processed portfolio data")
+	)
+	flag.Parse()
+
+	t := tracker.NewTracker(*dataDir)
+	if *csvFile != "" {
+		importActivity(t, *csvFile)
+		return
+	}
+
+	book, err := t.LoadLedger()
+	if err != nil {
+		log.Fatalf("❌ Error loading ledger: %v", err)
+	}
+	if *list > 0 {
+		listTransactions(book.Transactions, *list)
+		return
+	}
+
+	classes, err := config.LoadAssetClassification(*classesPath)
+	if err != nil {
+		log.Fatalf("❌ Error loading asset classes: %v", err)
+	}
+	cash := func(symbol string) bool { return classes.Classify(symbol).Class == config.AssetClassCash }
+
+	opening, closing, err := snapshots(t, *from, *to, !*reconcile)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if *reconcile {
+		printReconciliation(ledger.Reconcile(book.Transactions, opening, closing, cash))
+		return
+	}
+	if len(book.Transactions) == 0 {
+		fmt.Printf("⚠️  The ledger is empty; returns below count deposits and withdrawals as performance.\n")
+		fmt.Printf("   Import activity with -csv <activity export>\n\n")
+	}
+	printPerformance(t, book.Transactions, opening, closing, cash)
+}
+
+// importActivity adds an activity export's transactions to the ledger
+func importActivity(t *tracker.Tracker, path string) {
+	result, err := importer.ImportActivity(path)
+	if err != nil {
+		log.Fatalf("❌ Error reading activity: %v", err)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if len(result.UnmappedColumns) > 0 {
+		fmt.Printf("   Ignored columns: %s\n", strings.Join(result.UnmappedColumns, ", "))
+	}
+
+	var added, total int
+	err = t.UpdateLedger(func(book *models.Ledger) error {
+		book.Transactions, added = ledger.Merge(book.Transactions, result.Transactions)
+		book.UpdatedAt = time.Now()
+		total = len(book.Transactions)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("❌ Error saving ledger: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, tx := range result.Transactions {
+		counts[tx.Type]++
+	}
+	fmt.Printf("✅ Read %d transactions from %s\n", len(result.Transactions), path)
+	for _, kind := range transactionTypes {
+		if counts[kind] > 0 {
+			fmt.Printf("   %-10s %d\n", kind, counts[kind])
+		}
+	}
+	fmt.Printf("💾 Added %d new transactions (%d already in the ledger); %d in total\n",
+		added, len(result.Transactions)-added, total)
+}
+
+// transactionTypes lists the ledger transaction types in display order
+var transactionTypes = []string{
+	models.TransactionDeposit, models.TransactionWithdrawal, models.TransactionBuy, models.TransactionSell,
+	models.TransactionDividend, models.TransactionFee,
+}
+
+// listTransactions prints the last n transactions
+func listTransactions(transactions []models.Transaction, n int) {
+	if n > len(transactions) {
+		n = len(transactions)
+	}
+	fmt.Printf("🧾 Last %d of %d ledger transactions\n", n, len(transactions))
+	for _, tx := range transactions[len(transactions)-n:] {
+		quantity := ""
+		if tx.Quantity > 0 {
+			quantity = fmt.Sprintf("%.4f", tx.Quantity)
+		}
+		fmt.Printf("   %s %-12s %-10s %-8s %12s $%12.2f\n",
+			tx.Date.Format("2006-01-02"), models.MaskAccount(tx.Account()), tx.Type, tx.Symbol, quantity, tx.Amount)
+	}
+}
+
+// snapshots loads the opening and closing snapshots. Without -from the opening snapshot is the
+// first one when first is set, and none otherwise.
+func snapshots(t *tracker.Tracker, from, to string, first bool) (*models.Portfolio, *models.Portfolio, error) {
+	dates, err := t.ListAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing snapshots: %w", err)
+	}
+	if len(dates) == 0 {
+		return nil, nil, fmt.Errorf("no portfolio snapshots found; import one with portfolio-importer")
+	}
+
+	load := func(value string, fallback time.Time) (*models.Portfolio, error) {
+		date := fallback
+		if value != "" {
+			if date, err = time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("invalid date %q: %w", value, err)
+			}
+		}
+		return t.Load(date)
+	}
+
+	closing, err := load(to, dates[len(dates)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	var opening *models.Portfolio
+	if from != "" || first {
+		if opening, err = load(from, dates[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return opening, closing, nil
+}
+
+// printReconciliation prints the holdings on which the ledger and snapshot disagree
+func printReconciliation(r *ledger.Reconciliation) {
+	start := "an empty portfolio"
+	if !r.From.IsZero() {
+		start = "the " + r.From.Format("2006-01-02") + " snapshot"
+	}
+	fmt.Printf("🔎 Reconciling the ledger from %s to the %s snapshot\n", start, r.To.Format("2006-01-02"))
+	fmt.Printf("   Trades replayed: %d\n", r.Transactions)
+	fmt.Printf("   Holdings matched: %d\n", r.Matched)
+	if len(r.Discrepancies) == 0 {
+		fmt.Printf("✅ The ledger matches the snapshot\n")
+		return
+	}
+
+	fmt.Printf("\n⚠️  Discrepancies (%d):\n", len(r.Discrepancies))
+	fmt.Printf("   %-14s %-8s %14s %14s %14s\n", "Account", "Symbol", "Snapshot", "Ledger", "Difference")
+	for _, d := range r.Discrepancies {
+		fmt.Printf("   %-14s %-8s %14.4f %14.4f %+14.4f\n", models.MaskAccount(d.Account), d.Symbol, d.Snapshot, d.Ledger, d.Difference)
+	}
+	fmt.Printf("\n💡 Missing activity, transfers of securities, splits and reinvested dividends cause most differences\n")
+}
+
+// printPerformance prints time- and money-weighted returns and the gain by symbol
+func printPerformance(t *tracker.Tracker, transactions []models.Transaction, opening, closing *models.Portfolio, cash func(string) bool) {
+	if metrics, err := t.GetPerformanceMetrics(); err == nil {
+		fmt.Printf("📊 Performance %s to %s\n", metrics.StartDate.Format("2006-01-02"), metrics.EndDate.Format("2006-01-02"))
+		fmt.Printf("   Value: $%.2f → $%.2f\n", metrics.StartValue, metrics.EndValue)
+		fmt.Printf("   Net Contributions: $%.2f\n", metrics.NetContributions)
+		fmt.Printf("   Investment Gain: $%.2f\n", metrics.TotalReturn)
+		fmt.Printf("   Time-Weighted Return: %.2f%% (%.2f%% annualized)\n", metrics.TotalReturnPercent, metrics.CAGR)
+		fmt.Printf("   Money-Weighted Return (XIRR): %.2f%% a year\n", metrics.XIRR)
+		fmt.Printf("   Volatility: %.2f%%, Max Drawdown: %.2f%%\n\n", metrics.Volatility, metrics.MaxDrawdown)
+	}
+
+	a := ledger.Attribute(opening, closing, transactions, cash)
+	fmt.Printf("🧩 Attribution %s to %s: gain $%.2f (%.2f%%)\n", a.Start.Format("2006-01-02"), a.End.Format("2006-01-02"), a.Gain, a.Return*100)
+	fmt.Printf("   %-8s %12s %12s %12s %12s %10s %12s %8s\n", "Symbol", "Start", "End", "Bought", "Sold", "Income", "Gain", "Contrib")
+	for _, s := range append(a.Symbols, a.Cash) {
+		fmt.Printf("   %-8s %12.2f %12.2f %12.2f %12.2f %10.2f %12.2f %7.2f%%\n",
+			s.Symbol, s.StartValue, s.EndValue, s.Bought, s.Sold, s.Income, s.Gain, s.Contribution)
+	}
+	if a.Fees != 0 {
+		fmt.Printf("   %-8s %77.2f\n", "FEES", a.Fees)
+	}
+}
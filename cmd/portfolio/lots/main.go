@@ -0,0 +1,296 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/importer"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+)
+
+func main() {
+	var (
+		csvFile      = flag.String("csv", "", "Lot export, or activity export of buys and sells to replay into lots")
+		replace      = flag.Bool("replace", false, "Replace all stored lots instead of the accounts and symbols in -csv")
+		activityFile = flag.String("activity", "", "Activity export to check for wash sales")
+		sellSymbol   = flag.String("sell", "", "Simulate selling this symbol")
+		quantity     = flag.Float64("quantity", 0, "Shares to sell with -sell")
+		price        = flag.Float64("price", 0, "Sale price for -sell (default: latest lot cost)")
+		account      = flag.String("account", "", "Account number, masked number or name for -sell")
+		methodName   = flag.String("method", "fifo", "Lot selection: fifo, hifo, specific or avoid-short-term")
+		ids          = flag.String("ids", "", "Comma-separated lot IDs for -method specific, as printed")
+		dateStr      = flag.String("date", "", "Date for holding periods and -sell (YYYY-MM-DD, default today)")
+		shortRate    = flag.Float64("short-term-rate", taxlots.DefaultRates.ShortTerm, "Tax rate on short-term gains")
+		longRate     = flag.Float64("long-term-rate", taxlots.DefaultRates.LongTerm, "Tax rate on long-term gains")
+		accountsPath = flag.String("accounts", config.AccountsPath, "Account settings JSON (missing file infers types from account names)")
+		dataDir      = flag.String("data", "data/portfolio/processed", "Directory of processed portfolio data")
+	)
+	flag.Parse()
+
+	method, err := taxlots.ParseMethod(*methodName)
+	if err != nil {
+		log.Fatalf("Invalid -method: %v", err)
+	}
+	date := time.Now()
+	if *dateStr != "" {
+		if date, err = time.Parse("2006-01-02", *dateStr); err != nil {
+			log.Fatalf("Invalid -date: %v", err)
+		}
+	}
+	accounts, err := config.LoadAccountsConfig(*accountsPath)
+	if err != nil {
+		log.Fatalf("Failed to load account settings: %v", err)
+	}
+	rates := taxlots.Rates{ShortTerm: *shortRate, LongTerm: *longRate}
+	t := tracker.NewTracker(*dataDir)
+
+	switch {
+	case *csvFile != "":
+		if method == taxlots.SpecificID {
+			log.Fatalf("-method specific needs lot IDs and can't replay an activity export")
+		}
+		importLots(t, *csvFile, method, *replace, rates)
+	case *activityFile != "":
+		checkWashSales(*activityFile, method)
+	case *sellSymbol != "":
+		var lotIDs []string
+		if *ids != "" {
+			lotIDs = strings.Split(*ids, ",")
+		}
+		simulateSale(t, accounts, strings.ToUpper(*sellSymbol), *account, *quantity, *price, date, method, lotIDs, rates)
+	default:
+		lots, err := t.LoadTaxLots()
+		if err != nil {
+			log.Fatalf("Failed to load tax lots: %v", err)
+		}
+		if len(lots.Lots) == 0 {
+			fmt.Printf("⚠️  No tax lots stored. Import them with -csv <lot or activity export>\n")
+			return
+		}
+		printLots(lots.Lots, accounts, date)
+	}
+}
+
+// importLots reads lots from a lot or activity export and stores them
+func importLots(t *tracker.Tracker, path string, method taxlots.Method, replace bool, rates taxlots.Rates) {
+	result, err := importer.ImportLots(path, method)
+	if err != nil {
+		log.Fatalf("Failed to import lots: %v", err)
+	}
+	fmt.Printf("✅ Read %d open lots from %s export %s\n", len(result.Lots), result.Format, path)
+	if len(result.UnmappedColumns) > 0 {
+		fmt.Printf("   Ignored columns: %s\n", strings.Join(result.UnmappedColumns, ", "))
+	}
+	for _, warning := range result.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+
+	var total int
+	err = t.UpdateTaxLots(func(stored *models.TaxLots) error {
+		if replace {
+			stored.Lots = nil
+		}
+		stored.Lots = mergeLots(stored.Lots, result.Lots)
+		stored.UpdatedAt = time.Now()
+		total = len(stored.Lots)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to save tax lots: %v", err)
+	}
+	fmt.Printf("💾 Stored %d lots\n", total)
+
+	if len(result.Sales) > 0 {
+		fmt.Printf("\n🧾 Realized Sales (%s):\n", method)
+		var shortTerm, longTerm float64
+		for _, sale := range result.Sales {
+			st, lt := taxlots.Gains(sale.Lots)
+			shortTerm += st
+			longTerm += lt
+			fmt.Printf("   %s %-6s %10.4f shares in %-12s gain $%.2f (short-term $%.2f, long-term $%.2f)\n",
+				sale.Date.Format("2006-01-02"), sale.Symbol, sale.Quantity, models.MaskAccount(sale.Account), sale.Gain(), st, lt)
+		}
+		fmt.Printf("   Total: short-term $%.2f, long-term $%.2f, estimated tax $%.2f\n", shortTerm, longTerm, rates.Tax(shortTerm, longTerm))
+		printWashSales(taxlots.WashSales(result.Sales, result.Transactions))
+	}
+}
+
+// mergeLots replaces the stored lots of every account and symbol in imported
+func mergeLots(stored, imported []models.TaxLot) []models.TaxLot {
+	replaced := make(map[string]bool)
+	for _, lot := range imported {
+		replaced[lot.AccountNumber+"|"+lot.AccountName+"|"+lot.Symbol] = true
+	}
+	var merged []models.TaxLot
+	for _, lot := range stored {
+		if !replaced[lot.AccountNumber+"|"+lot.AccountName+"|"+lot.Symbol] {
+			merged = append(merged, lot)
+		}
+	}
+	return append(merged, imported...)
+}
+
+// printLots lists lots by account and symbol with their holding periods
+func printLots(lots []models.TaxLot, accounts *config.AccountsConfig, date time.Time) {
+	sorted := append([]models.TaxLot(nil), lots...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if ai, aj := lotAccount(sorted[i]), lotAccount(sorted[j]); ai != aj {
+			return ai < aj
+		}
+		if sorted[i].Symbol != sorted[j].Symbol {
+			return sorted[i].Symbol < sorted[j].Symbol
+		}
+		return sorted[i].Acquired.Before(sorted[j].Acquired)
+	})
+
+	fmt.Printf("🧾 Tax Lots as of %s\n", date.Format("2006-01-02"))
+	fmt.Printf("====================================\n")
+	current := ""
+	for _, lot := range sorted {
+		if account := lotAccount(lot); account != current {
+			current = account
+			fmt.Printf("\n🏦 %s (%s)\n", account, accounts.Settings(lot.AccountNumber, lot.AccountName).Type)
+		}
+		term := "short-term"
+		if lot.LongTerm(date) {
+			term = "long-term"
+		} else if days := lot.Acquired.AddDate(1, 0, 0).Sub(date).Hours() / 24; days >= 0 {
+			term = fmt.Sprintf("short-term, long-term in %.0f days", days+1)
+		}
+		fmt.Printf("   %-6s %s %10.4f shares  cost $%10.2f ($%.2f/share)  %4d days, %s  [%s]\n",
+			lot.Symbol, lot.Acquired.Format("2006-01-02"), lot.Quantity, lot.CostBasis, lot.UnitCost(),
+			lot.HoldingDays(date), term, lot.DisplayID())
+	}
+}
+
+// lotAccount returns the masked account number of a lot, or its name
+func lotAccount(lot models.TaxLot) string {
+	if lot.AccountNumber != "" {
+		return models.MaskAccount(lot.AccountNumber)
+	}
+	return lot.AccountName
+}
+
+// checkWashSales replays an activity export and reports wash sales across its accounts
+func checkWashSales(path string, method taxlots.Method) {
+	result, err := importer.ImportActivity(path)
+	if err != nil {
+		log.Fatalf("Failed to read activity: %v", err)
+	}
+	_, sales, warnings := taxlots.Replay(result.Transactions, method)
+	for _, warning := range append(result.Warnings, warnings...) {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	fmt.Printf("🔎 Checked %d sales in %d transactions\n", len(sales), len(result.Transactions))
+	printWashSales(taxlots.WashSales(sales, result.Transactions))
+}
+
+// printWashSales lists wash sales and the loss they disallow
+func printWashSales(washes []models.WashSale) {
+	if len(washes) == 0 {
+		fmt.Printf("✅ No wash sales\n")
+		return
+	}
+	fmt.Printf("\n⚠️  Wash Sales:\n")
+	var disallowed float64
+	for _, wash := range washes {
+		disallowed += wash.DisallowedLoss
+		fmt.Printf("   %s sold %s in %s at a $%.2f loss; %.4f shares bought in %s on %s disallow $%.2f\n",
+			wash.SaleDate.Format("2006-01-02"), wash.Symbol, models.MaskAccount(wash.SaleAccount), wash.Loss,
+			wash.ReplacementQuantity, models.MaskAccount(wash.ReplacementAccount), wash.ReplacementDate.Format("2006-01-02"), wash.DisallowedLoss)
+	}
+	fmt.Printf("   Total disallowed loss: $%.2f (added to the replacement shares' basis)\n", disallowed)
+}
+
+// simulateSale shows the lots a sale would dispose of and the tax it would realize
+func simulateSale(t *tracker.Tracker, accounts *config.AccountsConfig, symbol, account string, quantity, price float64, date time.Time, method taxlots.Method, ids []string, rates taxlots.Rates) {
+	stored, err := t.LoadTaxLots()
+	if err != nil {
+		log.Fatalf("Failed to load tax lots: %v", err)
+	}
+	lots := stored.Symbol(symbol)
+	if account != "" {
+		lots = stored.For(account, symbol)
+	}
+	if len(lots) == 0 {
+		log.Fatalf("No lots of %s stored %s", symbol, models.MaskAccount(account))
+	}
+	if account == "" && len(lotAccounts(lots)) > 1 {
+		log.Fatalf("%s is held in %s; choose one with -account", symbol, strings.Join(lotAccounts(lots), ", "))
+	}
+	if quantity <= 0 {
+		for _, lot := range lots {
+			quantity += lot.Quantity
+		}
+	}
+	if price <= 0 {
+		latest := lots[0]
+		for _, lot := range lots {
+			if lot.Acquired.After(latest.Acquired) {
+				latest = lot
+			}
+		}
+		price = latest.UnitCost()
+		fmt.Printf("⚠️  No -price given; using the latest lot's cost of $%.2f\n", price)
+	}
+
+	accountType := accounts.Settings(lots[0].AccountNumber, lots[0].AccountName).Type
+	sales, err := taxlots.Select(lots, quantity, price, date, method, ids)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	fmt.Printf("🔬 Selling %.4f %s at $%.2f in %s (%s) with %s\n", quantity, symbol, price, lotAccount(lots[0]), accountType, method)
+	for _, sale := range sales {
+		term := "short-term"
+		if sale.LongTerm {
+			term = "long-term"
+		}
+		fmt.Printf("   %-28s %s %10.4f shares  proceeds $%10.2f  cost $%10.2f  gain $%10.2f  %s\n",
+			models.MaskAccountIn(sale.LotID, lots[0].AccountNumber), sale.Acquired.Format("2006-01-02"), sale.Quantity, sale.Proceeds, sale.CostBasis, sale.Gain, term)
+	}
+	shortTerm, longTerm := taxlots.Gains(sales)
+	fmt.Printf("   Short-term gain: $%.2f\n", shortTerm)
+	fmt.Printf("   Long-term gain: $%.2f\n", longTerm)
+	if config.TaxAdvantaged(accountType) {
+		fmt.Printf("   Estimated tax: $0.00 (tax-advantaged account)\n")
+	} else {
+		fmt.Printf("   Estimated tax: $%.2f\n", rates.Tax(shortTerm, longTerm))
+	}
+
+	if shortTerm+longTerm < 0 {
+		sold := make(map[string]bool)
+		for _, sale := range sales {
+			sold[sale.LotID] = true
+		}
+		for _, lot := range taxlots.RecentPurchases(stored.Lots, symbol, date) {
+			if !sold[lot.ID] {
+				fmt.Printf("⚠️  Wash sale: %.4f shares bought in %s on %s\n", lot.Quantity, lotAccount(lot), lot.Acquired.Format("2006-01-02"))
+			}
+		}
+		fmt.Printf("💡 Don't buy %s in any account, including IRAs, for %d days after a loss sale\n", symbol, taxlots.WashSaleWindow)
+	}
+	if len(sales) > 0 {
+		fmt.Printf("   Lots left: %d\n", len(taxlots.Remove(lots, sales)))
+	}
+}
+
+// lotAccounts lists the accounts holding lots
+func lotAccounts(lots []models.TaxLot) []string {
+	var accounts []string
+	seen := make(map[string]bool)
+	for _, lot := range lots {
+		if account := lotAccount(lot); !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+	return accounts
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/analyzer"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+func main() {
+	var (
+		scenarioFile = flag.String("file", config.ScenariosPath, "Scenario sets JSON (missing file uses the built-in stress and recovery sets)")
+		set          = flag.String("set", "", "Scenario set to run (default: all)")
+		initFile     = flag.Bool("init", false, "Write the built-in scenario sets to -file")
+		btc          = flag.Float64("btc", 0, "Ad-hoc scenario: Bitcoin price change, e.g. -0.4")
+		mnavTo       = flag.Float64("mnav", 0, "Ad-hoc scenario: mNAV of every treasury company after the shock")
+		mnavChange   = flag.Float64("mnav-change", 0, "Ad-hoc scenario: change in every treasury company's mNAV, e.g. -0.3")
+		gold         = flag.Float64("gold", 0, "Ad-hoc scenario: gold price change")
+		historical   = flag.Int("historical", 0, "Replay the N worst Bitcoin periods in the stored prices")
+		windowDays   = flag.Int("window", 30, "Length in days of the -historical periods")
+		dateStr      = flag.String("date", "", "Portfolio snapshot date (YYYY-MM-DD, default latest)")
+		currentMNAV  = flag.Float64("current-mnav", 0, "Current "+analyzer.RuleSymbol+" mNAV (default: look-through, else the last in the rule state)")
+		currency     = flag.String("currency", "USD", "Currency to report values in")
+		classesPath  = flag.String("classes", config.AssetClassPath, "Asset classification JSON")
+		accountsPath = flag.String("accounts", config.AccountsPath, "Account settings JSON")
+		dataDir      = flag.String("data", "data/portfolio/processed", "Directory of processed portfolio data")
+		verbose      = flag.Bool("v", false, "Show the full rebalancing analysis of each scenario")
+	)
+	flag.Parse()
+
+	if *initFile {
+		if err := config.SaveScenarioSets(config.DefaultScenarioSets(), *scenarioFile); err != nil {
+			log.Fatalf("❌ Error writing scenarios: %v", err)
+		}
+		fmt.Printf("💾 Wrote the built-in scenario sets to %s\n", *scenarioFile)
+		return
+	}
+
+	adHoc := false
+	flag.Visit(func(f *flag.Flag) {
+		adHoc = adHoc || f.Name == "btc" || f.Name == "mnav" || f.Name == "mnav-change" || f.Name == "gold"
+	})
+
+	a, err := newAnalyzer(*currency, *classesPath, *accountsPath, *dataDir)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	t := tracker.NewTracker(*dataDir)
+	portfolio, err := loadSnapshot(t, *dateStr)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if portfolio, err = a.ConvertPortfolio(portfolio, a.Currency); err != nil {
+		log.Fatalf("❌ Error converting portfolio to %s: %v", a.Currency, err)
+	}
+
+	var scenarios []config.Scenario
+	switch {
+	case adHoc:
+		s := config.Scenario{Name: "Ad hoc", BTCChange: *btc, GoldChange: *gold}
+		if *mnavTo > 0 {
+			s.MNAV = map[string]float64{config.AllTreasuries: *mnavTo}
+		} else if *mnavChange != 0 {
+			s.MNAVChange = map[string]float64{config.AllTreasuries: *mnavChange}
+		}
+		if err := s.Validate(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		scenarios = append(scenarios, s)
+	case *historical > 0:
+		if scenarios, err = a.HistoricalScenarios(portfolio, *windowDays, *historical, portfolio.Date); err != nil {
+			log.Fatalf("❌ Error building historical scenarios: %v", err)
+		}
+	default:
+		if scenarios, err = loadScenarios(*scenarioFile, *set); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	table, err := analyzer.NewDynamicRebalancingTable()
+	if err != nil {
+		log.Fatalf("❌ Error loading rebalancing configuration: %v", err)
+	}
+	state, err := t.LoadRebalancingState()
+	if err != nil {
+		log.Fatalf("❌ Error loading rebalancing state: %v", err)
+	}
+	ruleMNAV := *currentMNAV
+	if ruleMNAV == 0 {
+		ruleMNAV = state.LastMNAV
+	}
+
+	sym := sharedmodels.CurrencySymbol(portfolio.Currency)
+	plural := "s"
+	if len(scenarios) == 1 {
+		plural = ""
+	}
+	fmt.Printf("🔬 Stress testing the %s portfolio (%s%.2f) under %d scenario%s\n\n",
+		portfolio.Date.Format("2006-01-02"), sym, portfolio.TotalValue, len(scenarios), plural)
+
+	var results []*analyzer.ScenarioResult
+	for _, s := range scenarios {
+		result, err := a.RunScenario(portfolio, s, table, state.ActiveRatio, ruleMNAV)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n\n", s.Name, err)
+			continue
+		}
+		printScenario(portfolio, result, analyzer.ScenarioMNAVs(portfolio), ruleMNAV, sym, *verbose)
+		results = append(results, result)
+	}
+	printSummary(portfolio, results, sym)
+}
+
+// newAnalyzer sets up an analyzer with the asset classes, company data, prices and account
+// settings the scenarios need
+func newAnalyzer(currency, classesPath, accountsPath, dataDir string) (*analyzer.Analyzer, error) {
+	fx, err := storage.NewFXStorage("data/fx").LoadTable()
+	if err != nil {
+		return nil, fmt.Errorf("error loading FX rates: %w", err)
+	}
+	a := &analyzer.Analyzer{Currency: currency, FX: fx, LotMethod: taxlots.HIFO}
+	if a.Classes, err = config.LoadAssetClassification(classesPath); err != nil {
+		return nil, fmt.Errorf("error loading asset classes: %w", err)
+	}
+	if companies, err := config.LoadCompaniesConfig("."); err == nil {
+		a.Classes.AddTreasuryCompanies(companies)
+		a.Companies = companies
+	}
+	store := repository.NewJSONStore(".")
+	a.Prices = store.Prices()
+	a.Transactions = store.Transactions()
+	a.Shares = store.Shares()
+	if a.Accounts, err = config.LoadAccountsConfig(accountsPath); err != nil {
+		return nil, fmt.Errorf("error loading account settings: %w", err)
+	}
+	if a.Lots, err = tracker.NewTracker(dataDir).LoadTaxLots(); err != nil {
+		return nil, fmt.Errorf("error loading tax lots: %w", err)
+	}
+	return a, nil
+}
+
+// loadSnapshot loads the snapshot on date, or the latest
+func loadSnapshot(t *tracker.Tracker, date string) (*models.Portfolio, error) {
+	if date == "" {
+		dates, err := t.ListAll()
+		if err != nil {
+			return nil, fmt.Errorf("error listing snapshots: %w", err)
+		}
+		if len(dates) == 0 {
+			return nil, fmt.Errorf("no portfolio snapshots found; import one with portfolio-importer")
+		}
+		return t.Load(dates[len(dates)-1])
+	}
+	parsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -date %q: %w", date, err)
+	}
+	return t.Load(parsed)
+}
+
+// loadScenarios returns the scenarios of one set, or of every set
+func loadScenarios(path, set string) ([]config.Scenario, error) {
+	sets, err := config.LoadScenarioSets(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading scenarios: %w", err)
+	}
+	if set != "" {
+		scenarios, ok := sets.Sets[set]
+		if !ok {
+			return nil, fmt.Errorf("no scenario set %q (have %s)", set, strings.Join(sets.Names(), ", "))
+		}
+		return scenarios, nil
+	}
+	var scenarios []config.Scenario
+	for _, name := range sets.Names() {
+		scenarios = append(scenarios, sets.Sets[name]...)
+	}
+	return scenarios, nil
+}
+
+// printScenario shows the revalued portfolio and the trades the scenario calls for
+func printScenario(portfolio *models.Portfolio, result *analyzer.ScenarioResult, mnavs map[string]float64, ruleMNAV float64, sym string, verbose bool) {
+	s := result.Scenario
+	shocked := result.Portfolio
+	fmt.Printf("🔬 %s\n", s.Name)
+	fmt.Printf("   Shocks: BTC %+.1f%%, gold %+.1f%%", s.BTCChange*100, s.GoldChange*100)
+	current := mnavs[analyzer.RuleSymbol]
+	if current == 0 {
+		current = ruleMNAV
+	}
+	if current > 0 {
+		fmt.Printf(", %s mNAV %.2f → %.2f", analyzer.RuleSymbol, current, result.MNAV)
+	}
+	fmt.Printf("\n")
+	symbols := make([]string, 0, len(s.SymbolChange))
+	for symbol := range s.SymbolChange {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		fmt.Printf("      %s %+.1f%%\n", symbol, s.SymbolChange[symbol]*100)
+	}
+
+	percent := 0.0
+	if portfolio.TotalValue > 0 {
+		percent = result.ValueChange / portfolio.TotalValue * 100
+	}
+	sign := "+"
+	if result.ValueChange < 0 {
+		sign = "-"
+	}
+	fmt.Printf("   Value: %s%.2f → %s%.2f (%s%s%.2f, %+.2f%%)\n",
+		sym, portfolio.TotalValue, sym, shocked.TotalValue, sign, sym, math.Abs(result.ValueChange), percent)
+	allocation := shocked.AssetAllocation
+	fmt.Printf("   Bitcoin Exposure: %s%.2f (%.1f%%)\n", sym, allocation.BitcoinExposure, allocation.BitcoinPercent)
+	fmt.Printf("   Spot BTC:Treasury Ratio: %.2f:1 → %.2f:1\n", portfolio.AssetAllocation.SpotTreasuryRatio, allocation.SpotTreasuryRatio)
+
+	rec := result.Recommendation
+	switch {
+	case rec == nil:
+		fmt.Printf("   ⚠️  No target: needs both spot BTC and treasury equity and a current %s mNAV (-current-mnav)\n", analyzer.RuleSymbol)
+	case verbose:
+		fmt.Printf("\n")
+		rec.Print()
+	case rec.IsWellBalanced:
+		fmt.Printf("   🎯 Target %v:1 - ✅ well balanced, no trades\n", rec.TargetRatio)
+	default:
+		fmt.Printf("   🎯 Target %v:1 - %s\n", rec.TargetRatio, rec.Explanation)
+		for _, trade := range rec.Trades {
+			fmt.Printf("      %-4s %10.4f %-6s %s%10.2f  in %s\n", trade.Action, trade.Shares, trade.Symbol, sym, trade.EstimatedValue, models.MaskAccount(trade.Account))
+		}
+		if result.Plan != nil && result.Plan.EstimatedTax != 0 {
+			fmt.Printf("      Estimated tax: %s%.2f\n", sym, result.Plan.EstimatedTax)
+		}
+		for _, warning := range rec.Warnings {
+			fmt.Printf("      ⚠️  %s\n", warning)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// printSummary lists every scenario's value, ratio and target side by side
+func printSummary(portfolio *models.Portfolio, results []*analyzer.ScenarioResult, sym string) {
+	if len(results) < 2 {
+		return
+	}
+	fmt.Printf("📊 Scenario Summary\n")
+	fmt.Printf("   %-40s %14s %9s %9s %8s %7s\n", "Scenario", "Value", "Change", "Ratio", "Target", "Trades")
+	for _, result := range results {
+		name := result.Scenario.Name
+		if len(name) > 40 {
+			name = name[:37] + "..."
+		}
+		percent := 0.0
+		if portfolio.TotalValue > 0 {
+			percent = result.ValueChange / portfolio.TotalValue * 100
+		}
+		target, trades := "-", 0
+		if rec := result.Recommendation; rec != nil {
+			target = fmt.Sprintf("%v:1", rec.TargetRatio)
+			trades = len(rec.Trades)
+		}
+		fmt.Printf("   %-40s %14s %+8.2f%% %7.2f:1 %8s %7d\n", name, fmt.Sprintf("%s%.2f", sym, result.Portfolio.TotalValue),
+			percent, result.Portfolio.AssetAllocation.SpotTreasuryRatio, target, trades)
+	}
+}
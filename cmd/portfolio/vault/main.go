@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// newPassphraseEnv holds the passphrase -rotate and -rekey wrap the data key under
+const newPassphraseEnv = "MNAV_PORTFOLIO_NEW_PASSPHRASE"
+
+func main() {
+	var (
+		dir        = flag.String("dir", "data/portfolio", "Portfolio data directory holding raw/, processed/ and the keyring")
+		initialize = flag.Bool("init", false, "Create a keyring from "+vault.PassphraseEnv+" or "+vault.KeyFileEnv+" and encrypt the existing files")
+		rotate     = flag.Bool("rotate", false, "Rewrap the data key under a new passphrase ("+newPassphraseEnv+") or -new-keyfile")
+		rekey      = flag.Bool("rekey", false, "Like -rotate, and replace the data key, re-encrypting every file")
+		decrypt    = flag.Bool("decrypt", false, "Decrypt every file and remove the keyring")
+		newKeyFile = flag.String("new-keyfile", "", "Key file for -rotate or -rekey")
+		genKey     = flag.String("genkey", "", "Write a new random key file to this path")
+	)
+	flag.Parse()
+
+	keyringPath := vault.KeyringPath(*dir)
+	switch {
+	case *genKey != "":
+		if err := vault.GenerateKeyFile(*genKey); err != nil {
+			log.Fatalf("❌ Error writing key file: %v", err)
+		}
+		fmt.Printf("🔑 Wrote a new key file to %s\n", *genKey)
+		fmt.Printf("   Keep it outside the repository and set %s=%s\n", vault.KeyFileEnv, *genKey)
+	case *initialize:
+		initVault(*dir, keyringPath)
+	case *rotate || *rekey:
+		next := vault.Secret{Passphrase: os.Getenv(newPassphraseEnv), KeyFile: *newKeyFile}
+		if next.IsZero() {
+			log.Fatalf("❌ Set %s or -new-keyfile to the new secret", newPassphraseEnv)
+		}
+		rotateVault(*dir, keyringPath, next, *rekey)
+	case *decrypt:
+		decryptVault(*dir, keyringPath)
+	default:
+		showStatus(*dir, keyringPath)
+	}
+}
+
+// portfolioFiles lists the raw exports and processed files under dir
+func portfolioFiles(dir string) []string {
+	var files []string
+	for _, pattern := range []string{"raw/*", "processed/*.json"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, path := range matches {
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() || strings.HasPrefix(filepath.Base(path), ".") {
+				continue
+			}
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// reseal opens every file with open and writes it back sealed by seal, or as plaintext when
+// seal is nil. Files under processed/ are rewritten under the tracker's lock. It returns the
+// files it rewrote, which are all of them unless err is set.
+func reseal(dir string, files []string, open, seal *vault.Box) (done []string, err error) {
+	for _, path := range files {
+		rewrite := func() error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if vault.Sealed(data) {
+				if open == nil {
+					return fmt.Errorf("encrypted without a keyring")
+				}
+				if data, err = open.Open(data); err != nil {
+					return err
+				}
+			}
+			perm := os.FileMode(0644)
+			if seal != nil {
+				if data, err = seal.Seal(data); err != nil {
+					return err
+				}
+				perm = 0600
+			}
+			return storage.WriteFileAtomic(path, data, perm)
+		}
+		if filepath.Base(filepath.Dir(path)) == "processed" {
+			err = storage.WithLock(filepath.Join(dir, "processed", ".lock"), rewrite)
+		} else {
+			err = rewrite()
+		}
+		if err != nil {
+			return done, fmt.Errorf("%s: %w", path, err)
+		}
+		done = append(done, path)
+	}
+	return done, nil
+}
+
+// unlock loads the keyring and unwraps its data key with the secret in the environment
+func unlock(keyringPath string) (*vault.Keyring, *vault.Box) {
+	keyring, err := vault.LoadKeyring(keyringPath)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if keyring == nil {
+		log.Fatalf("❌ No keyring at %s; the portfolio isn't encrypted (use -init)", keyringPath)
+	}
+	box, err := keyring.Unlock(vault.SecretFromEnv())
+	if err != nil {
+		log.Fatalf("❌ Error unlocking %s: %v", keyringPath, err)
+	}
+	return keyring, box
+}
+
+// initVault creates the keyring and encrypts the existing files. The keyring is saved first, so
+// an interrupted run leaves plaintext files that still load and are sealed by a rerun of -rekey.
+func initVault(dir, keyringPath string) {
+	if existing, err := vault.LoadKeyring(keyringPath); err != nil {
+		log.Fatalf("❌ %v", err)
+	} else if existing != nil {
+		log.Fatalf("❌ %s already exists; use -rotate to change the secret", keyringPath)
+	}
+	keyring, box, err := vault.NewKeyring(vault.SecretFromEnv())
+	if err != nil {
+		log.Fatalf("❌ Error creating keyring: %v", err)
+	}
+	if err := vault.SaveKeyring(keyringPath, keyring); err != nil {
+		log.Fatalf("❌ Error saving keyring: %v", err)
+	}
+	fmt.Printf("🔐 Created %s (%s, key %s)\n", keyringPath, keyring.KDF, keyring.KeyID)
+
+	done, err := reseal(dir, portfolioFiles(dir), nil, box)
+	fmt.Printf("🔒 Encrypted %d files\n", len(done))
+	if err != nil {
+		log.Fatalf("❌ Error encrypting: %v", err)
+	}
+}
+
+// rotateVault rewraps the data key under the next secret. With rekey it also replaces the data
+// key: every file is resealed first and the keyring saved last, and a failure reseals the
+// rewritten files with the old key so the saved keyring still opens all of them.
+func rotateVault(dir, keyringPath string, next vault.Secret, rekey bool) {
+	keyring, _ := unlock(keyringPath)
+	if !rekey {
+		if err := keyring.Rotate(vault.SecretFromEnv(), next); err != nil {
+			log.Fatalf("❌ Error rotating: %v", err)
+		}
+		if err := vault.SaveKeyring(keyringPath, keyring); err != nil {
+			log.Fatalf("❌ Error saving keyring: %v", err)
+		}
+		fmt.Printf("🔄 Rewrapped data key %s under the new %s\n", keyring.KeyID, keyring.KDF)
+		fmt.Printf("   Files are unchanged; use the new secret from now on\n")
+		return
+	}
+
+	oldID := keyring.KeyID
+	oldBox, newBox, err := keyring.Rekey(vault.SecretFromEnv(), next)
+	if err != nil {
+		log.Fatalf("❌ Error rekeying: %v", err)
+	}
+	done, err := reseal(dir, portfolioFiles(dir), oldBox, newBox)
+	if err == nil {
+		err = vault.SaveKeyring(keyringPath, keyring)
+	}
+	if err != nil {
+		if _, rollback := reseal(dir, done, newBox, oldBox); rollback != nil {
+			log.Fatalf("❌ Error rekeying: %v; restoring the old key also failed: %v", err, rollback)
+		}
+		log.Fatalf("❌ Error rekeying: %v; files restored to key %s", err, oldID)
+	}
+	fmt.Printf("🔄 Replaced data key %s with %s under the new %s\n", oldID, keyring.KeyID, keyring.KDF)
+	fmt.Printf("🔒 Re-encrypted %d files\n", len(done))
+}
+
+// decryptVault writes every file back as plaintext, then removes the keyring
+func decryptVault(dir, keyringPath string) {
+	_, box := unlock(keyringPath)
+	done, err := reseal(dir, portfolioFiles(dir), box, nil)
+	fmt.Printf("🔓 Decrypted %d files\n", len(done))
+	if err != nil {
+		log.Fatalf("❌ Error decrypting: %v", err)
+	}
+	if err := os.Remove(keyringPath); err != nil {
+		log.Fatalf("❌ Error removing keyring: %v", err)
+	}
+	fmt.Printf("✅ Removed %s; portfolio files are stored unencrypted\n", keyringPath)
+}
+
+// showStatus reports whether the portfolio is encrypted and which files are sealed
+func showStatus(dir, keyringPath string) {
+	keyring, err := vault.LoadKeyring(keyringPath)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	var sealed, plain []string
+	for _, path := range portfolioFiles(dir) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("❌ Error reading %s: %v", path, err)
+		}
+		if vault.Sealed(data) {
+			sealed = append(sealed, path)
+		} else {
+			plain = append(plain, path)
+		}
+	}
+
+	fmt.Printf("🔐 Portfolio Encryption (%s)\n", dir)
+	fmt.Printf("====================================\n")
+	if keyring == nil {
+		fmt.Printf("   Keyring: none; files are stored unencrypted\n")
+	} else {
+		fmt.Printf("   Keyring: %s (%s, key %s)\n", keyringPath, keyring.KDF, keyring.KeyID)
+		fmt.Printf("   Created: %s\n", keyring.CreatedAt.Format("2006-01-02 15:04"))
+		if !keyring.RotatedAt.IsZero() {
+			fmt.Printf("   Rotated: %s\n", keyring.RotatedAt.Format("2006-01-02 15:04"))
+		}
+		secret := vault.SecretFromEnv()
+		switch _, err := keyring.Unlock(secret); {
+		case secret.IsZero():
+			fmt.Printf("   ⚠️  No secret set; tools can't read or write portfolio data (set %s or %s)\n", vault.PassphraseEnv, vault.KeyFileEnv)
+		case err != nil:
+			fmt.Printf("   ❌ The secret in the environment doesn't unlock it: %v\n", err)
+		default:
+			fmt.Printf("   ✅ Unlocked by the secret in the environment\n")
+		}
+	}
+	fmt.Printf("   Files: %d encrypted, %d plaintext\n", len(sealed), len(plain))
+	if keyring != nil && len(plain) > 0 {
+		fmt.Printf("\n⚠️  Plaintext files (encrypted on their next save, or now with -rekey):\n")
+		for _, path := range plain {
+			fmt.Printf("   %s\n", path)
+		}
+	}
+}
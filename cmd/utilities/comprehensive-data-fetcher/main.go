@@ -12,6 +12,7 @@ import (
 	"github.com/ultrarare-tech/mNAV/pkg/collection/alphavantage"
 	"github.com/ultrarare-tech/mNAV/pkg/collection/external"
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 func main() {
@@ -151,7 +152,9 @@ func saveComprehensiveData(standardData *models.ComprehensiveBitcoinAnalysis, sh
 		return fmt.Errorf("error marshaling Bitcoin data: %w", err)
 	}
 
-	if err := os.WriteFile(bitcoinPath, bitcoinJSON, 0644); err != nil {
+	if err := storage.WithLock(bitcoinPath+".lock", func() error {
+		return storage.WriteFileAtomic(bitcoinPath, bitcoinJSON, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing Bitcoin data: %w", err)
 	}
 	fmt.Printf("   ✅ Saved: %s\n", bitcoinFilename)
@@ -166,7 +169,9 @@ func saveComprehensiveData(standardData *models.ComprehensiveBitcoinAnalysis, sh
 			return fmt.Errorf("error marshaling shares data: %w", err)
 		}
 
-		if err := os.WriteFile(sharesPath, sharesJSON, 0644); err != nil {
+		if err := storage.WithLock(sharesPath+".lock", func() error {
+			return storage.WriteFileAtomic(sharesPath, sharesJSON, 0644)
+		}); err != nil {
 			return fmt.Errorf("error writing shares data: %w", err)
 		}
 		fmt.Printf("   ✅ Saved: %s\n", sharesFilename)
@@ -181,7 +186,9 @@ func saveComprehensiveData(standardData *models.ComprehensiveBitcoinAnalysis, sh
 		return fmt.Errorf("error marshaling raw data: %w", err)
 	}
 
-	if err := os.WriteFile(rawPath, rawJSON, 0644); err != nil {
+	if err := storage.WithLock(rawPath+".lock", func() error {
+		return storage.WriteFileAtomic(rawPath, rawJSON, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing raw data: %w", err)
 	}
 	fmt.Printf("   ✅ Saved: %s\n", rawFilename)
@@ -212,7 +219,9 @@ func saveComprehensiveData(standardData *models.ComprehensiveBitcoinAnalysis, sh
 		return fmt.Errorf("error marshaling summary: %w", err)
 	}
 
-	if err := os.WriteFile(summaryPath, summaryJSON, 0644); err != nil {
+	if err := storage.WithLock(summaryPath+".lock", func() error {
+		return storage.WriteFileAtomic(summaryPath, summaryJSON, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing summary: %w", err)
 	}
 	fmt.Printf("   ✅ Saved: %s\n", summaryFilename)
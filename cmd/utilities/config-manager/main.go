@@ -15,9 +15,27 @@ func main() {
 		convert  = flag.Bool("convert", false, "Convert CSV to JSON")
 		summary  = flag.Bool("summary", false, "Show configuration summary")
 		force    = flag.Bool("force", false, "Force regeneration of JSON from CSV")
+		importer = flag.String("import", "", "Make a candidate table (e.g. from rebalance-optimizer) the active configuration")
+		classes  = flag.Bool("classes", false, "Show the portfolio asset classification")
+		initCls  = flag.Bool("init-classes", false, "Write the default asset classification to "+config.AssetClassPath)
 	)
 	flag.Parse()
 
+	if *initCls {
+		initClasses(*force)
+		return
+	}
+
+	if *classes {
+		showClasses()
+		return
+	}
+
+	if *importer != "" {
+		importConfig(*importer)
+		return
+	}
+
 	if *validate {
 		validateConfig()
 		return
@@ -58,7 +76,7 @@ func validateConfig() {
 	// Show ranges for verification
 	fmt.Printf("📊 Ratio Ranges:\n")
 	for _, rule := range config.Rules {
-		fmt.Printf("   %.0f:1 → [%.4f - %.4f]\n",
+		fmt.Printf("   %g:1 → [%.4f - %.4f]\n",
 			rule.TargetRatio, rule.MinThreshold, rule.MaxThreshold)
 	}
 }
@@ -67,8 +85,8 @@ func convertConfig(force bool) {
 	fmt.Printf("🔄 CONVERTING CONFIGURATION\n")
 	fmt.Printf("===========================\n\n")
 
-	csvPath := "configs/rebalancing/rebalancing_table.csv"
-	jsonPath := "configs/rebalancing/rebalancing_table.json"
+	csvPath := config.RebalancingCSVPath
+	jsonPath := config.RebalancingJSONPath
 
 	if !force {
 		// Check if JSON already exists and is newer
@@ -94,6 +112,38 @@ func convertConfig(force bool) {
 	fmt.Printf("   Rules: %d\n", len(configObj.Rules))
 }
 
+func importConfig(path string) {
+	fmt.Printf("📥 IMPORTING REBALANCING CONFIGURATION\n")
+	fmt.Printf("=====================================\n\n")
+
+	configObj, err := config.LoadRebalancingConfigFile(path)
+	if err != nil {
+		log.Fatalf("❌ Failed to load %s: %v", path, err)
+	}
+
+	if err := config.ImportRebalancingConfig(configObj); err != nil {
+		log.Fatalf("❌ Import failed: %v", err)
+	}
+
+	fmt.Printf("✅ Imported %d rules from %s\n", len(configObj.Rules), path)
+	fmt.Printf("   CSV: %s (previous table kept as .bak)\n", config.RebalancingCSVPath)
+	fmt.Printf("   JSON: %s\n", config.RebalancingJSONPath)
+	fmt.Printf("   Tolerance: ±%.1f%%\n", configObj.GetTolerance()*100)
+
+	if v := configObj.Validation; v != nil {
+		fmt.Printf("\n🔬 Out-of-sample validation (%s search, %s objective, %d folds):\n", v.Method, v.Objective, v.Folds)
+		fmt.Printf("   Candidate: %s\n", v.Candidate)
+		if !v.TestStart.IsZero() {
+			fmt.Printf("   Held-out segment: %s to %s\n", v.TestStart.Format("2006-01-02"), v.TestEnd.Format("2006-01-02"))
+		}
+		fmt.Printf("   Score: %.2f in sample, %.2f out of sample\n", v.TrainScore, v.TestScore)
+		fmt.Printf("   Test CAGR: %.1f%% (HODL BTC %.1f%%, HODL MSTR %.1f%%)\n", v.TestCAGRPct, v.HODLBitcoinCAGRPct, v.HODLMSTRCAGRPct)
+		fmt.Printf("   Test Max Drawdown: %.1f%%, turnover %.2fx per year\n", v.TestMaxDrawdownPct, v.TestTurnover)
+	}
+
+	fmt.Printf("\n⚠️  Editing the CSV regenerates the JSON and drops the tolerance and validation\n")
+}
+
 func showSummary() {
 	fmt.Printf("📋 REBALANCING CONFIGURATION SUMMARY\n")
 	fmt.Printf("===================================\n\n")
@@ -115,4 +165,47 @@ func showSummary() {
 	fmt.Printf("   ./bin/config-manager -summary    # Show this summary\n")
 	fmt.Printf("   ./bin/config-manager -validate   # Validate configuration\n")
 	fmt.Printf("   ./bin/config-manager -convert    # Force CSV to JSON conversion\n")
+	fmt.Printf("   ./bin/config-manager -import F   # Activate an optimizer candidate table\n")
+	fmt.Printf("   ./bin/config-manager -classes    # Show the portfolio asset classes\n")
+}
+
+func showClasses() {
+	fmt.Printf("🧩 PORTFOLIO ASSET CLASSES\n")
+	fmt.Printf("=========================\n\n")
+
+	classification, err := config.LoadAssetClassification(config.AssetClassPath)
+	if err != nil {
+		log.Fatalf("❌ Failed to load asset classes: %v", err)
+	}
+	if companies, err := config.LoadCompaniesConfig("."); err == nil {
+		classification.AddTreasuryCompanies(companies)
+	}
+	if _, err := os.Stat(config.AssetClassPath); os.IsNotExist(err) {
+		fmt.Printf("ℹ️  %s not found, showing defaults\n\n", config.AssetClassPath)
+	}
+
+	for _, class := range config.AssetClasses {
+		symbols := classification.SymbolsIn(class)
+		if len(symbols) == 0 {
+			continue
+		}
+		fmt.Printf("%s (%s):\n", config.AssetClassLabel(class), class)
+		for _, symbol := range symbols {
+			fmt.Printf("   %-6s BTC factor %.2f\n", symbol, classification.Symbols[symbol].BTCFactor)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Unlisted symbols are classed as other with no Bitcoin exposure\n")
+}
+
+func initClasses(force bool) {
+	if _, err := os.Stat(config.AssetClassPath); err == nil && !force {
+		fmt.Printf("ℹ️  %s already exists\n", config.AssetClassPath)
+		fmt.Printf("   Use -force to overwrite it with the defaults\n")
+		return
+	}
+	if err := config.SaveAssetClassification(config.DefaultAssetClassification(), config.AssetClassPath); err != nil {
+		log.Fatalf("❌ Failed to write asset classes: %v", err)
+	}
+	fmt.Printf("✅ Wrote the default asset classes to %s\n", config.AssetClassPath)
 }
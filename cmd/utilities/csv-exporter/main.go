@@ -15,7 +15,12 @@ import (
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/yahoo"
+	portfoliomodels "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/columnar"
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // DailyFinancialData represents a single day's comprehensive financial data
@@ -39,6 +44,9 @@ type DailyFinancialData struct {
 	CumulativeBitcoinInvested float64
 	AverageBitcoinCost        float64
 	MarketClosed              bool
+
+	// Where each field's value came from, keyed by models.Field* names
+	Lineage models.RowLineage
 }
 
 // StockDataPoint represents daily stock data
@@ -49,12 +57,16 @@ type StockDataPoint struct {
 	Low    float64   `json:"low"`
 	Close  float64   `json:"close"`
 	Volume float64   `json:"volume"`
+
+	Lineage models.FieldLineage `json:"-"`
 }
 
 // BitcoinDataPoint represents daily Bitcoin price data
 type BitcoinDataPoint struct {
 	Timestamp time.Time `json:"timestamp"`
 	Price     float64   `json:"price"`
+
+	Lineage models.FieldLineage `json:"-"`
 }
 
 // StockDataResponse represents the stock data file format
@@ -86,15 +98,33 @@ type BitcoinHistoricalData struct {
 func main() {
 	var (
 		symbol     = flag.String("symbol", "MSTR", "Stock symbol to export")
-		outputFile = flag.String("output", "", "Output CSV file (default: {symbol}_financial_data_{date}.csv)")
+		outputFile = flag.String("output", "", "Output CSV file, or directory for parquet/arrow (default: {symbol}_financial_data_{date}[.csv])")
 		startDate  = flag.String("start", "2020-08-11", "Start date (YYYY-MM-DD)")
 		endDate    = flag.String("end", "", "End date (YYYY-MM-DD), defaults to today")
 		verbose    = flag.Bool("verbose", false, "Enable verbose output")
+		backend    = flag.String("backend", "files", "Data source: files (legacy JSON/CSV discovery) or sqlite")
+		dbPath     = flag.String("db", "data/mnav.db", "SQLite database path when -backend=sqlite")
+		asOfKnown  = flag.String("as-of-knowledge", "", "Only use data known on this date (YYYY-MM-DD); records saved before knowledge tracking count from their own date")
+		lineage    = flag.String("lineage", "manifest", "Field lineage export: manifest (JSON sidecar), columns, both or none")
+		format     = flag.String("format", "csv", "Output format: csv, parquet or arrow (Arrow IPC)")
+		partition  = flag.String("partition", "year", "Date partitioning for parquet/arrow: none, year or month")
+		portfolio  = flag.String("portfolio-dir", "data/portfolio/processed", "Portfolio snapshots to include in parquet/arrow exports")
 	)
 	flag.Parse()
 
 	fmt.Printf("📊 CSV FINANCIAL DATA EXPORTER\n")
 	fmt.Printf("==============================\n\n")
+	switch *lineage {
+	case "manifest", "columns", "both", "none":
+	default:
+		log.Fatalf("❌ Unknown lineage mode: %s (expected manifest, columns, both or none)", *lineage)
+	}
+	switch *format {
+	case "csv", string(columnar.Parquet), string(columnar.Arrow):
+	default:
+		log.Fatalf("❌ Unknown format: %s (expected csv, parquet or arrow)", *format)
+	}
+
 	fmt.Printf("🏢 Symbol: %s\n", *symbol)
 	fmt.Printf("📅 Period: %s to %s\n", *startDate, getEndDate(*endDate))
 	fmt.Printf("📁 Collecting all available financial data...\n\n")
@@ -113,12 +143,121 @@ func main() {
 		}
 	}
 
+	// Restrict to what was known on the knowledge date
+	var knownAt time.Time
+	if *asOfKnown != "" {
+		knownAt, err = models.ParseKnowledgeDate(*asOfKnown)
+		if err != nil {
+			log.Fatalf("❌ Error parsing knowledge date: %v", err)
+		}
+		if end.After(knownAt) {
+			end = knownAt
+		}
+		fmt.Printf("🕰️  As known on: %s\n", *asOfKnown)
+	}
+
 	// Load all data sources
+	var (
+		stockData     *StockDataResponse
+		bitcoinData   *BitcoinDataResponse
+		bitcoinTxData *models.ComprehensiveBitcoinAnalysis
+		sharesData    *SharesOutstandingData
+	)
+
+	if *backend == repository.BackendSQLite {
+		fmt.Printf("🗄️  Loading data from %s...\n", *dbPath)
+		store, err := repository.OpenSQLiteStore(*dbPath)
+		if err != nil {
+			log.Fatalf("❌ Error opening database: %v", err)
+		}
+		stockData, bitcoinData, bitcoinTxData, sharesData = loadFromRepository(store, *symbol, knownAt, *verbose)
+		store.Close()
+	} else {
+		stockData, bitcoinData, bitcoinTxData, sharesData = loadFromFiles(*symbol, *verbose)
+		if !knownAt.IsZero() {
+			fmt.Printf("🕰️  Loading company facts known on %s...\n", *asOfKnown)
+			bitcoinTxData, sharesData, err = loadKnownCompanyFacts(*symbol, knownAt, *verbose)
+			if err != nil {
+				log.Fatalf("❌ -as-of-knowledge with -backend=files needs the EDGAR company store: %v", err)
+			}
+		}
+	}
+
+	// Generate comprehensive daily dataset
+	fmt.Printf("\n🔄 Processing daily financial data...\n")
+	dailyData := generateDailyDataset(start, end, stockData, bitcoinData, bitcoinTxData, sharesData, knownAt.IsZero(), *verbose)
+
+	if *verbose {
+		fmt.Printf("   ✅ Generated %d daily records\n", len(dailyData))
+	}
+
+	// Validate data freshness
+	fmt.Printf("\n🔍 Validating data freshness...\n")
+	validateDataFreshness(dailyData, *symbol)
+
+	// Columnar exports write one dataset per input alongside the daily data
+	if *format != "csv" {
+		outputDir := *outputFile
+		if outputDir == "" {
+			outputDir = fmt.Sprintf("%s_financial_data_%s", *symbol, time.Now().Format("2006-01-02"))
+		}
+
+		fmt.Printf("\n💾 Exporting %s datasets to: %s\n", *format, outputDir)
+		inputs := columnarInputs{
+			symbol:       *symbol,
+			daily:        dailyData,
+			stock:        stockData,
+			bitcoin:      bitcoinData,
+			transactions: bitcoinTxData,
+			portfolioDir: *portfolio,
+		}
+		files, err := exportColumnar(inputs, outputDir, columnar.Format(*format), columnar.Partitioning(*partition))
+		if err != nil {
+			log.Fatalf("❌ Error exporting %s: %v", *format, err)
+		}
+		fmt.Printf("   ✅ Wrote %d files\n", len(files))
+		if *verbose {
+			for _, file := range files {
+				fmt.Printf("   • %s\n", file)
+			}
+		}
+
+		printSummary(dailyData, *symbol, outputDir)
+		return
+	}
+
+	// Export to CSV
+	outputPath := *outputFile
+	if outputPath == "" {
+		timestamp := time.Now().Format("2006-01-02")
+		outputPath = fmt.Sprintf("%s_financial_data_%s.csv", *symbol, timestamp)
+	}
+
+	fmt.Printf("\n💾 Exporting to CSV: %s\n", outputPath)
+	withColumns := *lineage == "columns" || *lineage == "both"
+	if err := exportToCSV(dailyData, outputPath, withColumns); err != nil {
+		log.Fatalf("❌ Error exporting CSV: %v", err)
+	}
+
+	if *lineage == "manifest" || *lineage == "both" {
+		manifestPath, err := exportLineageManifest(dailyData, *symbol, outputPath)
+		if err != nil {
+			log.Fatalf("❌ Error exporting lineage manifest: %v", err)
+		}
+		fmt.Printf("🧾 Lineage manifest: %s\n", manifestPath)
+	}
+
+	// Print summary
+	printSummary(dailyData, *symbol, outputPath)
+}
+
+// loadFromFiles discovers data in the legacy JSON/CSV file layout
+func loadFromFiles(symbol string, verbose bool) (*StockDataResponse, *BitcoinDataResponse, *models.ComprehensiveBitcoinAnalysis, *SharesOutstandingData) {
 	fmt.Printf("📈 Loading stock data...\n")
-	stockData, err := loadStockData(*symbol)
+	stockData, err := loadStockData(symbol)
 	if err != nil {
 		log.Printf("⚠️  Warning: Could not load stock data: %v", err)
-	} else if *verbose {
+	} else if verbose {
 		fmt.Printf("   ✅ Loaded %d stock data points\n", len(stockData.DataPoints))
 	}
 
@@ -126,52 +265,149 @@ func main() {
 	bitcoinData, err := loadBitcoinData()
 	if err != nil {
 		log.Printf("⚠️  Warning: Could not load Bitcoin data: %v", err)
-	} else if *verbose {
+	} else if verbose {
 		fmt.Printf("   ✅ Loaded %d Bitcoin price points\n", len(bitcoinData.Prices))
 	}
 
 	fmt.Printf("🪙 Loading Bitcoin transaction data...\n")
-	bitcoinTxData, err := loadBitcoinTransactionData(*symbol)
+	bitcoinTxData, err := loadBitcoinTransactionData(symbol)
 	if err != nil {
 		log.Printf("⚠️  Warning: Could not load Bitcoin transaction data: %v", err)
-	} else if *verbose {
+	} else if verbose {
 		fmt.Printf("   ✅ Loaded %d Bitcoin transactions\n", len(bitcoinTxData.AllTransactions))
 	}
 
 	fmt.Printf("📊 Loading shares outstanding data...\n")
-	sharesData, err := loadSharesData(*symbol)
+	sharesData, err := loadSharesData(symbol)
 	if err != nil {
 		log.Printf("⚠️  Warning: Could not load shares data: %v", err)
-	} else if *verbose {
+	} else if verbose {
 		fmt.Printf("   ✅ Loaded shares outstanding data\n")
 	}
 
-	// Generate comprehensive daily dataset
-	fmt.Printf("\n🔄 Processing daily financial data...\n")
-	dailyData := generateDailyDataset(start, end, stockData, bitcoinData, bitcoinTxData, sharesData, *verbose)
+	return stockData, bitcoinData, bitcoinTxData, sharesData
+}
 
-	if *verbose {
-		fmt.Printf("   ✅ Generated %d daily records\n", len(dailyData))
+// loadKnownCompanyFacts replaces the file layout's transactions and shares, which carry no
+// knowledge history, with the versions the EDGAR company store knew at knownAt
+func loadKnownCompanyFacts(symbol string, knownAt time.Time, verbose bool) (*models.ComprehensiveBitcoinAnalysis, *SharesOutstandingData, error) {
+	companyDir := "data/edgar/companies"
+	data, err := storage.NewCompanyDataStorage(companyDir).LoadCompanyDataAsOf(symbol, knownAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data.BTCTransactions) == 0 && len(data.SharesHistory) == 0 {
+		return nil, nil, fmt.Errorf("no company facts known for %s", symbol)
 	}
 
-	// Validate data freshness
-	fmt.Printf("\n🔍 Validating data freshness...\n")
-	validateDataFreshness(dailyData, *symbol)
+	lineage := models.FieldLineage{Source: "sec", File: filepath.Join(companyDir, symbol), Method: models.LineageObserved}
+	bitcoinTxData := transactionAnalysis(symbol, "edgar", data.BTCTransactions)
+	sharesData := sharesFromRecords(symbol, data.SharesHistory, lineage)
+	if verbose {
+		fmt.Printf("   ✅ Loaded %d Bitcoin transactions and %d shares records known on %s\n",
+			len(data.BTCTransactions), len(data.SharesHistory), knownAt.Format("2006-01-02"))
+	}
+	return bitcoinTxData, sharesData, nil
+}
 
-	// Export to CSV
-	outputPath := *outputFile
-	if outputPath == "" {
-		timestamp := time.Now().Format("2006-01-02")
-		outputPath = fmt.Sprintf("%s_financial_data_%s.csv", *symbol, timestamp)
+// transactionAnalysis totals a list of transactions, or returns nil when there are none
+func transactionAnalysis(symbol, source string, txs []models.BitcoinTransaction) *models.ComprehensiveBitcoinAnalysis {
+	if len(txs) == 0 {
+		return nil
+	}
+	analysis := &models.ComprehensiveBitcoinAnalysis{
+		Symbol:          symbol,
+		Source:          source,
+		AllTransactions: txs,
+	}
+	for _, tx := range txs {
+		analysis.TotalBTC += tx.BTCPurchased
+		analysis.TotalInvestmentUSD += tx.USDSpent
 	}
+	return analysis
+}
 
-	fmt.Printf("\n💾 Exporting to CSV: %s\n", outputPath)
-	if err := exportToCSV(dailyData, outputPath); err != nil {
-		log.Fatalf("❌ Error exporting CSV: %v", err)
+// sharesFromRecords converts date-ordered shares records, or returns nil when there are none
+func sharesFromRecords(symbol string, records []models.SharesOutstandingRecord, lineage models.FieldLineage) *SharesOutstandingData {
+	if len(records) == 0 {
+		return nil
 	}
+	sharesData := &SharesOutstandingData{
+		Symbol:                   symbol,
+		CurrentSharesOutstanding: records[len(records)-1].TotalShares,
+		Lineage:                  lineage,
+	}
+	for _, r := range records {
+		sharesData.HistoricalData = append(sharesData.HistoricalData, SharesDataPoint{
+			Date:              r.Date.Format("2006-01-02"),
+			SharesOutstanding: r.TotalShares,
+		})
+	}
+	return sharesData
+}
 
-	// Print summary
-	printSummary(dailyData, *symbol, outputPath)
+// loadFromRepository loads the same inputs from a storage backend instead of the file layout.
+// A non-zero knownAt restricts company facts to the versions known at that time.
+func loadFromRepository(store repository.Store, symbol string, knownAt time.Time, verbose bool) (*StockDataResponse, *BitcoinDataResponse, *models.ComprehensiveBitcoinAnalysis, *SharesOutstandingData) {
+	var (
+		stockData     *StockDataResponse
+		bitcoinData   *BitcoinDataResponse
+		bitcoinTxData *models.ComprehensiveBitcoinAnalysis
+		sharesData    *SharesOutstandingData
+	)
+
+	fmt.Printf("📈 Loading stock data...\n")
+	if prices, err := store.Prices().GetPrices(symbol, time.Time{}, time.Time{}); err != nil || len(prices) == 0 {
+		log.Printf("⚠️  Warning: Could not load stock data: %v", err)
+	} else {
+		stockData = &StockDataResponse{Symbol: symbol}
+		for _, p := range prices {
+			stockData.DataPoints = append(stockData.DataPoints, StockDataPoint{
+				Date: p.Date, Open: p.Open, High: p.High, Low: p.Low, Close: p.Close, Volume: p.Volume,
+				Lineage: repositoryLineage(p.Source),
+			})
+		}
+		if verbose {
+			fmt.Printf("   ✅ Loaded %d stock data points\n", len(stockData.DataPoints))
+		}
+	}
+
+	fmt.Printf("₿ Loading Bitcoin price data...\n")
+	if prices, err := store.Prices().GetPrices(repository.BitcoinSymbol, time.Time{}, time.Time{}); err != nil || len(prices) == 0 {
+		log.Printf("⚠️  Warning: Could not load Bitcoin data: %v", err)
+	} else {
+		bitcoinData = &BitcoinDataResponse{}
+		for _, p := range prices {
+			bitcoinData.Prices = append(bitcoinData.Prices, BitcoinDataPoint{
+				Timestamp: p.Date, Price: p.Close, Lineage: repositoryLineage(p.Source),
+			})
+		}
+		if verbose {
+			fmt.Printf("   ✅ Loaded %d Bitcoin price points\n", len(bitcoinData.Prices))
+		}
+	}
+
+	fmt.Printf("🪙 Loading Bitcoin transaction data...\n")
+	if txs, err := store.Transactions().LoadBTCTransactionsAsOf(symbol, knownAt); err != nil || len(txs) == 0 {
+		log.Printf("⚠️  Warning: Could not load Bitcoin transaction data: %v", err)
+	} else {
+		bitcoinTxData = transactionAnalysis(symbol, "repository", txs)
+		if verbose {
+			fmt.Printf("   ✅ Loaded %d Bitcoin transactions\n", len(txs))
+		}
+	}
+
+	fmt.Printf("📊 Loading shares outstanding data...\n")
+	if records, err := store.Shares().LoadSharesHistoryAsOf(symbol, knownAt); err != nil || len(records) == 0 {
+		log.Printf("⚠️  Warning: Could not load shares data: %v", err)
+	} else {
+		sharesData = sharesFromRecords(symbol, records, repositoryLineage("sec"))
+		if verbose {
+			fmt.Printf("   ✅ Loaded %d shares outstanding records\n", len(records))
+		}
+	}
+
+	return stockData, bitcoinData, bitcoinTxData, sharesData
 }
 
 // loadStockData loads historical stock data from all available sources
@@ -301,6 +537,12 @@ func loadStockDataFromFile(filename, symbol string) (*StockDataResponse, error)
 		return nil, fmt.Errorf("no historical array found in historical_prices")
 	}
 
+	source, _ := rawData["source"].(string)
+	if source == "" {
+		source = "stock-data"
+	}
+	lineage := models.FileLineage(source, filename)
+
 	stockData := &StockDataResponse{
 		Symbol:     symbol,
 		DataPoints: make([]StockDataPoint, 0, len(historicalData)),
@@ -330,12 +572,13 @@ func loadStockDataFromFile(filename, symbol string) (*StockDataResponse, error)
 		low, _ := point["low"].(float64)
 
 		stockPoint := StockDataPoint{
-			Date:   date,
-			Open:   open,
-			High:   high,
-			Low:    low,
-			Close:  close,
-			Volume: volume,
+			Date:    date,
+			Open:    open,
+			High:    high,
+			Low:     low,
+			Close:   close,
+			Volume:  volume,
+			Lineage: lineage,
 		}
 
 		stockData.DataPoints = append(stockData.DataPoints, stockPoint)
@@ -448,6 +691,7 @@ func loadFromCoinMarketCapCSVFile(filename string) (*BitcoinDataResponse, error)
 	bitcoinData := &BitcoinDataResponse{
 		Prices: make([]BitcoinDataPoint, 0, len(records)-1),
 	}
+	lineage := models.FileLineage("coinmarketcap", filename)
 
 	// Skip header row (index 0)
 	for i := 1; i < len(records); i++ {
@@ -473,6 +717,7 @@ func loadFromCoinMarketCapCSVFile(filename string) (*BitcoinDataResponse, error)
 		bitcoinPoint := BitcoinDataPoint{
 			Timestamp: timestamp,
 			Price:     closePrice,
+			Lineage:   lineage,
 		}
 
 		bitcoinData.Prices = append(bitcoinData.Prices, bitcoinPoint)
@@ -564,6 +809,11 @@ func loadFromLegacyFiles() (*BitcoinDataResponse, error) {
 		return nil, err
 	}
 
+	lineage := models.FileLineage(bitcoinSourceFromFile(latestFile, "bitcoin-prices"), latestFile)
+	for i := range response.Prices {
+		response.Prices[i].Lineage = lineage
+	}
+
 	return &response, nil
 }
 
@@ -594,6 +844,11 @@ func loadFromCoinGeckoHistoricalJSON(filename string) (*BitcoinDataResponse, err
 		bitcoinData := &BitcoinDataResponse{
 			Prices: make([]BitcoinDataPoint, 0, len(coinGeckoData.Data)),
 		}
+		source := coinGeckoData.Source
+		if source == "" {
+			source = bitcoinSourceFromFile(filename, "coingecko")
+		}
+		lineage := models.FileLineage(source, filename)
 
 		for _, dataPoint := range coinGeckoData.Data {
 			timestamp, err := time.Parse("2006-01-02", dataPoint.Date)
@@ -604,6 +859,7 @@ func loadFromCoinGeckoHistoricalJSON(filename string) (*BitcoinDataResponse, err
 			bitcoinPoint := BitcoinDataPoint{
 				Timestamp: timestamp,
 				Price:     dataPoint.Close,
+				Lineage:   lineage,
 			}
 
 			bitcoinData.Prices = append(bitcoinData.Prices, bitcoinPoint)
@@ -844,31 +1100,36 @@ func loadFromSaylorTrackerFormat(filename string) (*models.ComprehensiveBitcoinA
 
 // SharesOutstandingData represents shares data structure
 type SharesOutstandingData struct {
-	Symbol                   string  `json:"symbol"`
-	CurrentSharesOutstanding float64 `json:"current_shares_outstanding"`
-	HistoricalData           []struct {
-		Date              string  `json:"date"`
-		SharesOutstanding float64 `json:"shares_outstanding"`
-	} `json:"historical_data"`
+	Symbol                   string            `json:"symbol"`
+	CurrentSharesOutstanding float64           `json:"current_shares_outstanding"`
+	HistoricalData           []SharesDataPoint `json:"historical_data"`
+
+	Lineage models.FieldLineage `json:"-"`
+}
+
+// SharesDataPoint represents shares outstanding on a given date
+type SharesDataPoint struct {
+	Date              string  `json:"date"`
+	SharesOutstanding float64 `json:"shares_outstanding"`
 }
 
 // loadFreshHoldingsData loads the most recent Bitcoin holdings from fetch-mstr-holdings output
-func loadFreshHoldingsData() (float64, error) {
+func loadFreshHoldingsData() (float64, models.FieldLineage, error) {
 	// Try to load from the most recent comprehensive analysis file
 	comprehensiveFile := "data/analysis/MSTR_comprehensive_bitcoin_analysis.json"
 	if _, err := os.Stat(comprehensiveFile); err == nil {
 		data, err := os.ReadFile(comprehensiveFile)
 		if err != nil {
-			return 0, err
+			return 0, models.FieldLineage{}, err
 		}
 
 		var analysis models.ComprehensiveBitcoinAnalysis
 		if err := json.Unmarshal(data, &analysis); err != nil {
-			return 0, err
+			return 0, models.FieldLineage{}, err
 		}
 
 		if analysis.TotalBTC > 0 {
-			return analysis.TotalBTC, nil
+			return analysis.TotalBTC, models.FileLineage("fetch-mstr-holdings", comprehensiveFile), nil
 		}
 	}
 
@@ -877,20 +1138,20 @@ func loadFreshHoldingsData() (float64, error) {
 	if _, err := os.Stat(rawHoldingsFile); err == nil {
 		data, err := os.ReadFile(rawHoldingsFile)
 		if err != nil {
-			return 0, err
+			return 0, models.FieldLineage{}, err
 		}
 
 		var rawData map[string]interface{}
 		if err := json.Unmarshal(data, &rawData); err != nil {
-			return 0, err
+			return 0, models.FieldLineage{}, err
 		}
 
 		if totalBTC, ok := rawData["TotalBTC"].(float64); ok && totalBTC > 0 {
-			return totalBTC, nil
+			return totalBTC, models.FileLineage("fetch-mstr-holdings", rawHoldingsFile), nil
 		}
 	}
 
-	return 0, fmt.Errorf("no fresh holdings data found")
+	return 0, models.FieldLineage{}, fmt.Errorf("no fresh holdings data found")
 }
 
 // loadSharesData loads shares outstanding data
@@ -915,6 +1176,7 @@ func loadSharesData(symbol string) (*SharesOutstandingData, error) {
 	if err := json.Unmarshal(data, &sharesData); err != nil {
 		return nil, err
 	}
+	sharesData.Lineage = models.FileLineage("sec", latestFile)
 
 	return &sharesData, nil
 }
@@ -930,7 +1192,7 @@ func fetchCurrentStockPrice(symbol string) (float64, error) {
 
 // generateDailyDataset creates a comprehensive daily dataset
 func generateDailyDataset(start, end time.Time, stockData *StockDataResponse, bitcoinData *BitcoinDataResponse,
-	bitcoinTxData *models.ComprehensiveBitcoinAnalysis, sharesData *SharesOutstandingData, verbose bool) []DailyFinancialData {
+	bitcoinTxData *models.ComprehensiveBitcoinAnalysis, sharesData *SharesOutstandingData, useLiveData, verbose bool) []DailyFinancialData {
 
 	dailyData := make(map[string]*DailyFinancialData)
 
@@ -938,7 +1200,8 @@ func generateDailyDataset(start, end time.Time, stockData *StockDataResponse, bi
 	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
 		dateStr := d.Format("2006-01-02")
 		dailyData[dateStr] = &DailyFinancialData{
-			Date: d,
+			Date:    d,
+			Lineage: models.RowLineage{},
 		}
 	}
 
@@ -949,15 +1212,22 @@ func generateDailyDataset(start, end time.Time, stockData *StockDataResponse, bi
 			if record, exists := dailyData[dateStr]; exists {
 				record.StockPrice = point.Close
 				record.StockVolume = point.Volume
+				record.Lineage[models.FieldStockPrice] = point.Lineage
+				record.Lineage[models.FieldStockVolume] = point.Lineage
 			}
 		}
 	}
 
-	// Get fresh current stock price for today's date
+	// Get fresh current stock price for today's date (skipped for as-of-knowledge runs)
 	today := time.Now().Format("2006-01-02")
-	if record, exists := dailyData[today]; exists && stockData != nil {
+	if record, exists := dailyData[today]; exists && stockData != nil && useLiveData {
 		if currentPrice, err := fetchCurrentStockPrice(stockData.Symbol); err == nil {
 			record.StockPrice = currentPrice
+			record.Lineage[models.FieldStockPrice] = models.FieldLineage{
+				Source:    "yahoo",
+				FetchedAt: time.Now(),
+				Method:    models.LineageLive,
+			}
 			if verbose {
 				fmt.Printf("   💰 Updated today's stock price to fresh data: $%.2f\n", currentPrice)
 			}
@@ -972,6 +1242,7 @@ func generateDailyDataset(start, end time.Time, stockData *StockDataResponse, bi
 			dateStr := point.Timestamp.Format("2006-01-02")
 			if record, exists := dailyData[dateStr]; exists {
 				record.BitcoinPrice = point.Price
+				record.Lineage[models.FieldBitcoinPrice] = point.Lineage
 			}
 		}
 	}
@@ -981,7 +1252,7 @@ func generateDailyDataset(start, end time.Time, stockData *StockDataResponse, bi
 
 	// Calculate Bitcoin holdings over time
 	if bitcoinTxData != nil {
-		calculateBitcoinHoldings(dailyData, bitcoinTxData, verbose)
+		calculateBitcoinHoldings(dailyData, bitcoinTxData, useLiveData, verbose)
 	}
 
 	// Add shares outstanding data
@@ -1008,6 +1279,7 @@ func generateDailyDataset(start, end time.Time, stockData *StockDataResponse, bi
 func fillMissingData(dailyData map[string]*DailyFinancialData, start, end time.Time) {
 	var lastStockPrice, lastBitcoinPrice float64
 	var lastStockDate, lastBitcoinDate time.Time
+	var lastStockLineage, lastBitcoinLineage models.FieldLineage
 	const maxForwardFillDays = 5 // Don't forward-fill beyond 5 trading days
 
 	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
@@ -1020,12 +1292,15 @@ func fillMissingData(dailyData map[string]*DailyFinancialData, start, end time.T
 			if record.StockPrice > 0 {
 				lastStockPrice = record.StockPrice
 				lastStockDate = d
+				lastStockLineage = record.Lineage[models.FieldStockPrice]
 				record.MarketClosed = false
 			} else if lastStockPrice > 0 {
 				// Only forward-fill if within the acceptable time range
 				daysSinceLastStock := int(d.Sub(lastStockDate).Hours() / 24)
 				if daysSinceLastStock <= maxForwardFillDays {
 					record.StockPrice = lastStockPrice
+					record.Lineage[models.FieldStockPrice] = lastStockLineage.FilledFromDate(
+						models.LineageForwardFilled, lastStockDate.Format("2006-01-02"))
 					if isHoliday {
 						record.MarketClosed = true // True market closure
 					} else {
@@ -1042,10 +1317,13 @@ func fillMissingData(dailyData map[string]*DailyFinancialData, start, end time.T
 			if record.BitcoinPrice > 0 {
 				lastBitcoinPrice = record.BitcoinPrice
 				lastBitcoinDate = d
+				lastBitcoinLineage = record.Lineage[models.FieldBitcoinPrice]
 			} else if lastBitcoinPrice > 0 {
 				daysSinceLastBitcoin := int(d.Sub(lastBitcoinDate).Hours() / 24)
 				if daysSinceLastBitcoin <= 2 { // Only 2 days for Bitcoin
 					record.BitcoinPrice = lastBitcoinPrice
+					record.Lineage[models.FieldBitcoinPrice] = lastBitcoinLineage.FilledFromDate(
+						models.LineageForwardFilled, lastBitcoinDate.Format("2006-01-02"))
 				}
 			}
 		}
@@ -1102,13 +1380,18 @@ func validateDataFreshness(data []DailyFinancialData, symbol string) {
 }
 
 // calculateBitcoinHoldings calculates Bitcoin holdings over time
-func calculateBitcoinHoldings(dailyData map[string]*DailyFinancialData, bitcoinTxData *models.ComprehensiveBitcoinAnalysis, verbose bool) {
+func calculateBitcoinHoldings(dailyData map[string]*DailyFinancialData, bitcoinTxData *models.ComprehensiveBitcoinAnalysis, useFreshHoldings, verbose bool) {
 	// Check for fresh holdings data from recent fetch-mstr-holdings run
+	// (not for as-of-knowledge runs, since it reflects today's knowledge)
 	var latestHoldings float64
-	if freshHoldings, err := loadFreshHoldingsData(); err == nil && freshHoldings > 0 {
-		latestHoldings = freshHoldings
-		if verbose {
-			fmt.Printf("   🆕 Using fresh holdings data: %.0f BTC\n", latestHoldings)
+	var freshLineage models.FieldLineage
+	if useFreshHoldings {
+		if freshHoldings, lineage, err := loadFreshHoldingsData(); err == nil && freshHoldings > 0 {
+			latestHoldings = freshHoldings
+			freshLineage = lineage.FilledFromDate(models.LineageFreshOverride, lineage.FetchedAt.Format("2006-01-02"))
+			if verbose {
+				fmt.Printf("   🆕 Using fresh holdings data: %.0f BTC\n", latestHoldings)
+			}
 		}
 	}
 
@@ -1133,6 +1416,10 @@ func calculateBitcoinHoldings(dailyData map[string]*DailyFinancialData, bitcoinT
 
 	var currentHoldings, totalInvested float64
 
+	// Lineage of currentHoldings and the date it was last set
+	var holdingsLineage models.FieldLineage
+	var holdingsSetOn string
+
 	// Process each date in chronological order
 	for _, dateStr := range dates {
 		record := dailyData[dateStr]
@@ -1143,6 +1430,14 @@ func calculateBitcoinHoldings(dailyData map[string]*DailyFinancialData, bitcoinT
 			totalInvested += tx.USDSpent
 			record.TransactionDate = true
 			record.TransactionAmount = tx.BTCPurchased
+			holdingsLineage = models.FieldLineage{
+				Source:    bitcoinTxData.Source,
+				File:      tx.FilingURL,
+				FetchedAt: tx.KnownAt,
+				Method:    models.LineageObserved,
+			}
+			holdingsSetOn = dateStr
+			record.Lineage[models.FieldTransactionAmount] = holdingsLineage
 			if verbose {
 				fmt.Printf("   📈 %s: +%.0f BTC (Total: %.0f BTC)\n", dateStr, tx.BTCPurchased, currentHoldings)
 			}
@@ -1157,6 +1452,8 @@ func calculateBitcoinHoldings(dailyData map[string]*DailyFinancialData, bitcoinT
 			// Use fresh holdings for recent dates (within last 30 days)
 			if daysDiff <= 30 && latestHoldings > currentHoldings {
 				currentHoldings = latestHoldings
+				holdingsLineage = freshLineage
+				holdingsSetOn = dateStr
 				if verbose && daysDiff <= 1 {
 					fmt.Printf("   🆕 %s: Updated to fresh holdings: %.0f BTC\n", dateStr, currentHoldings)
 				}
@@ -1167,12 +1464,21 @@ func calculateBitcoinHoldings(dailyData map[string]*DailyFinancialData, bitcoinT
 		record.BitcoinHoldings = currentHoldings
 		record.CumulativeBitcoinInvested = totalInvested
 		if currentHoldings > 0 {
+			lineage := holdingsLineage
+			if holdingsSetOn != dateStr && lineage.Method != models.LineageFreshOverride {
+				lineage = lineage.FilledFromDate(models.LineageCarriedForward, holdingsSetOn)
+			}
+			record.Lineage[models.FieldBitcoinHoldings] = lineage
+			record.Lineage[models.FieldCumulativeInvest] = lineage
 			record.AverageBitcoinCost = totalInvested / currentHoldings
+			record.Lineage[models.FieldAverageCost] = models.DerivedLineage(lineage)
 		}
 
 		// Calculate Bitcoin value
 		if record.BitcoinHoldings > 0 && record.BitcoinPrice > 0 {
 			record.BitcoinValue = record.BitcoinHoldings * record.BitcoinPrice
+			record.Lineage[models.FieldBitcoinValue] = models.DerivedLineage(
+				record.Lineage[models.FieldBitcoinHoldings], record.Lineage[models.FieldBitcoinPrice])
 		}
 	}
 }
@@ -1192,6 +1498,7 @@ func populateSharesData(dailyData map[string]*DailyFinancialData, sharesData *Sh
 		recordDate, _ := time.Parse("2006-01-02", dateStr)
 
 		var bestShares float64
+		var bestDate string
 		var bestDiff time.Duration = time.Hour * 24 * 365 * 10 // 10 years
 
 		for shareDate, shares := range sharesLookup {
@@ -1204,13 +1511,25 @@ func populateSharesData(dailyData map[string]*DailyFinancialData, sharesData *Sh
 			if diff >= 0 && diff < bestDiff {
 				bestDiff = diff
 				bestShares = shares
+				bestDate = shareDate
 			}
 		}
 
 		if bestShares > 0 {
 			record.SharesOutstanding = bestShares
+			if bestDate == dateStr {
+				record.Lineage[models.FieldSharesOutstanding] = sharesData.Lineage
+			} else {
+				record.Lineage[models.FieldSharesOutstanding] = sharesData.Lineage.FilledFromDate(models.LineageNearestPrior, bestDate)
+			}
 		} else {
 			record.SharesOutstanding = currentShares
+			if currentShares > 0 {
+				lineage := sharesData.Lineage
+				lineage.Method = models.LineageLatestKnown
+				lineage.Filled = true
+				record.Lineage[models.FieldSharesOutstanding] = lineage
+			}
 		}
 	}
 }
@@ -1218,14 +1537,20 @@ func populateSharesData(dailyData map[string]*DailyFinancialData, sharesData *Sh
 // calculateDerivedMetrics calculates financial metrics
 func calculateDerivedMetrics(dailyData map[string]*DailyFinancialData) {
 	for _, record := range dailyData {
+		lineage := record.Lineage
+
 		// Market cap
 		if record.StockPrice > 0 && record.SharesOutstanding > 0 {
 			record.MarketCap = record.StockPrice * record.SharesOutstanding
+			lineage[models.FieldMarketCap] = models.DerivedLineage(
+				lineage[models.FieldStockPrice], lineage[models.FieldSharesOutstanding])
 		}
 
 		// Bitcoin per share
 		if record.BitcoinHoldings > 0 && record.SharesOutstanding > 0 {
 			record.BitcoinPerShare = record.BitcoinHoldings / record.SharesOutstanding
+			lineage[models.FieldBitcoinPerShare] = models.DerivedLineage(
+				lineage[models.FieldBitcoinHoldings], lineage[models.FieldSharesOutstanding])
 		}
 
 		// mNAV (Bitcoin value per share)
@@ -1234,24 +1559,33 @@ func calculateDerivedMetrics(dailyData map[string]*DailyFinancialData) {
 			if record.StockPrice > 0 {
 				record.MNAV = record.StockPrice / bitcoinValuePerShare
 				record.Premium = (record.MNAV - 1.0) * 100.0
+				lineage[models.FieldMNAV] = models.DerivedLineage(lineage[models.FieldStockPrice],
+					lineage[models.FieldBitcoinValue], lineage[models.FieldSharesOutstanding])
+				lineage[models.FieldPremium] = lineage[models.FieldMNAV]
 			}
 			record.BookValuePerShare = bitcoinValuePerShare
+			lineage[models.FieldBookValuePerShare] = models.DerivedLineage(
+				lineage[models.FieldBitcoinValue], lineage[models.FieldSharesOutstanding])
 		}
 
 		// Price to book
 		if record.StockPrice > 0 && record.BookValuePerShare > 0 {
 			record.PriceToBook = record.StockPrice / record.BookValuePerShare
+			lineage[models.FieldPriceToBook] = models.DerivedLineage(
+				lineage[models.FieldStockPrice], lineage[models.FieldBookValuePerShare])
 		}
 
 		// Bitcoin yield (Bitcoin value as % of market cap)
 		if record.BitcoinValue > 0 && record.MarketCap > 0 {
 			record.BitcoinYield = (record.BitcoinValue / record.MarketCap) * 100.0
+			lineage[models.FieldBitcoinYield] = models.DerivedLineage(
+				lineage[models.FieldBitcoinValue], lineage[models.FieldMarketCap])
 		}
 	}
 }
 
-// exportToCSV exports the data to CSV format
-func exportToCSV(data []DailyFinancialData, filename string) error {
+// exportToCSV exports the data to CSV format, optionally with a lineage column per field
+func exportToCSV(data []DailyFinancialData, filename string, withLineage bool) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -1283,6 +1617,11 @@ func exportToCSV(data []DailyFinancialData, filename string) error {
 		"Average_Bitcoin_Cost",
 		"Market_Closed",
 	}
+	if withLineage {
+		for _, column := range lineageColumns {
+			header = append(header, column.header+"_Lineage")
+		}
+	}
 
 	if err := writer.Write(header); err != nil {
 		return err
@@ -1327,6 +1666,11 @@ func exportToCSV(data []DailyFinancialData, filename string) error {
 			formatFloat(record.AverageBitcoinCost),
 			marketStatus,
 		}
+		if withLineage {
+			for _, column := range lineageColumns {
+				row = append(row, record.Lineage[column.field].String())
+			}
+		}
 
 		if err := writer.Write(row); err != nil {
 			return err
@@ -1336,6 +1680,226 @@ func exportToCSV(data []DailyFinancialData, filename string) error {
 	return nil
 }
 
+// columnarInputs collects everything written by a parquet/arrow export
+type columnarInputs struct {
+	symbol       string
+	daily        []DailyFinancialData
+	stock        *StockDataResponse
+	bitcoin      *BitcoinDataResponse
+	transactions *models.ComprehensiveBitcoinAnalysis
+	portfolioDir string
+}
+
+// exportColumnar writes the daily dataset, raw price histories, transactions and
+// portfolio snapshots as typed, date-partitioned parquet or Arrow IPC datasets
+func exportColumnar(inputs columnarInputs, outputDir string, format columnar.Format, partitioning columnar.Partitioning) ([]string, error) {
+	tables := []*columnar.Table{}
+
+	daily, err := dailyTable(inputs.symbol, inputs.daily)
+	if err != nil {
+		return nil, err
+	}
+	tables = append(tables, daily)
+
+	if inputs.stock != nil {
+		table := columnar.NewTable("stock_prices",
+			columnar.Column{Name: "symbol", Type: columnar.String},
+			columnar.Column{Name: "date", Type: columnar.Date},
+			columnar.Column{Name: "open", Type: columnar.Float},
+			columnar.Column{Name: "high", Type: columnar.Float},
+			columnar.Column{Name: "low", Type: columnar.Float},
+			columnar.Column{Name: "close", Type: columnar.Float},
+			columnar.Column{Name: "volume", Type: columnar.Float},
+			columnar.Column{Name: "source", Type: columnar.String},
+			columnar.Column{Name: "source_file", Type: columnar.String},
+		)
+		table.DateColumn = "date"
+		for _, p := range inputs.stock.DataPoints {
+			if err := table.Append(inputs.stock.Symbol, p.Date, p.Open, p.High, p.Low, p.Close, p.Volume,
+				p.Lineage.Source, p.Lineage.File); err != nil {
+				return nil, err
+			}
+		}
+		tables = append(tables, table)
+	}
+
+	if inputs.bitcoin != nil {
+		table := columnar.NewTable("bitcoin_prices",
+			columnar.Column{Name: "date", Type: columnar.Date},
+			columnar.Column{Name: "price", Type: columnar.Float},
+			columnar.Column{Name: "source", Type: columnar.String},
+			columnar.Column{Name: "source_file", Type: columnar.String},
+		)
+		table.DateColumn = "date"
+		for _, p := range inputs.bitcoin.Prices {
+			if err := table.Append(p.Timestamp, p.Price, p.Lineage.Source, p.Lineage.File); err != nil {
+				return nil, err
+			}
+		}
+		tables = append(tables, table)
+	}
+
+	if inputs.transactions != nil {
+		table, err := columnar.TransactionsTable(inputs.symbol, inputs.transactions.AllTransactions)
+		if err != nil {
+			return nil, err
+		}
+		table.Metadata["source"] = inputs.transactions.Source
+		tables = append(tables, table)
+	}
+
+	if portfolios := loadPortfolioSnapshots(inputs.portfolioDir); len(portfolios) > 0 {
+		table, err := columnar.PortfolioTable(portfolios)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+
+	var files []string
+	for _, table := range tables {
+		table.Metadata["generated_at"] = time.Now().UTC().Format(time.RFC3339)
+		written, err := columnar.Write(table, outputDir, format, partitioning)
+		files = append(files, written...)
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// dailyTable converts the daily dataset to a typed table with its lineage attached
+func dailyTable(symbol string, data []DailyFinancialData) (*columnar.Table, error) {
+	table := columnar.NewTable("daily",
+		columnar.Column{Name: "date", Type: columnar.Date},
+		columnar.Column{Name: "stock_price", Type: columnar.Float},
+		columnar.Column{Name: "stock_volume", Type: columnar.Float},
+		columnar.Column{Name: "market_cap", Type: columnar.Float},
+		columnar.Column{Name: "bitcoin_price", Type: columnar.Float},
+		columnar.Column{Name: "bitcoin_holdings", Type: columnar.Float},
+		columnar.Column{Name: "bitcoin_value", Type: columnar.Float},
+		columnar.Column{Name: "shares_outstanding", Type: columnar.Float},
+		columnar.Column{Name: "mnav", Type: columnar.Float},
+		columnar.Column{Name: "premium_percent", Type: columnar.Float},
+		columnar.Column{Name: "bitcoin_per_share", Type: columnar.Float},
+		columnar.Column{Name: "book_value_per_share", Type: columnar.Float},
+		columnar.Column{Name: "price_to_book", Type: columnar.Float},
+		columnar.Column{Name: "bitcoin_yield_percent", Type: columnar.Float},
+		columnar.Column{Name: "transaction_date", Type: columnar.Bool},
+		columnar.Column{Name: "transaction_amount_btc", Type: columnar.Float},
+		columnar.Column{Name: "cumulative_investment_usd", Type: columnar.Float},
+		columnar.Column{Name: "average_bitcoin_cost", Type: columnar.Float},
+		columnar.Column{Name: "market_closed", Type: columnar.Bool},
+	)
+	table.DateColumn = "date"
+	table.Metadata["symbol"] = symbol
+	table.Lineage = make(map[string]models.RowLineage, len(data))
+
+	f := columnar.NullableFloat
+	for _, r := range data {
+		if err := table.Append(r.Date, f(r.StockPrice), f(r.StockVolume), f(r.MarketCap), f(r.BitcoinPrice),
+			f(r.BitcoinHoldings), f(r.BitcoinValue), f(r.SharesOutstanding), f(r.MNAV), f(r.Premium),
+			f(r.BitcoinPerShare), f(r.BookValuePerShare), f(r.PriceToBook), f(r.BitcoinYield), r.TransactionDate,
+			f(r.TransactionAmount), f(r.CumulativeBitcoinInvested), f(r.AverageBitcoinCost), isNYSEHoliday(r.Date)); err != nil {
+			return nil, err
+		}
+		if len(r.Lineage) > 0 {
+			table.Lineage[r.Date.Format("2006-01-02")] = r.Lineage
+		}
+	}
+	return table, nil
+}
+
+// loadPortfolioSnapshots loads every stored portfolio snapshot, skipping unreadable ones
+func loadPortfolioSnapshots(dir string) []*portfoliomodels.Portfolio {
+	if _, err := os.Stat(dir); err != nil {
+		return nil
+	}
+
+	t := tracker.NewTracker(dir)
+	dates, err := t.ListAll()
+	if err != nil {
+		log.Printf("⚠️  Warning: Could not list portfolio snapshots: %v", err)
+		return nil
+	}
+
+	var portfolios []*portfoliomodels.Portfolio
+	for _, date := range dates {
+		p, err := t.Load(date)
+		if err != nil {
+			log.Printf("⚠️  Warning: Could not load portfolio %s: %v", date.Format("2006-01-02"), err)
+			continue
+		}
+		portfolios = append(portfolios, p)
+	}
+	return portfolios
+}
+
+// lineageColumns maps lineage fields to the CSV columns they describe, in column order
+var lineageColumns = []struct {
+	field  string
+	header string
+}{
+	{models.FieldStockPrice, "Stock_Price"},
+	{models.FieldStockVolume, "Stock_Volume"},
+	{models.FieldMarketCap, "Market_Cap"},
+	{models.FieldBitcoinPrice, "Bitcoin_Price"},
+	{models.FieldBitcoinHoldings, "Bitcoin_Holdings_BTC"},
+	{models.FieldBitcoinValue, "Bitcoin_Value_USD"},
+	{models.FieldSharesOutstanding, "Shares_Outstanding"},
+	{models.FieldMNAV, "mNAV_Ratio"},
+	{models.FieldPremium, "Premium_Percent"},
+	{models.FieldBitcoinPerShare, "Bitcoin_Per_Share"},
+	{models.FieldBookValuePerShare, "Book_Value_Per_Share"},
+	{models.FieldPriceToBook, "Price_To_Book"},
+	{models.FieldBitcoinYield, "Bitcoin_Yield_Percent"},
+	{models.FieldTransactionAmount, "Transaction_Amount_BTC"},
+	{models.FieldCumulativeInvest, "Cumulative_Investment_USD"},
+	{models.FieldAverageCost, "Average_Bitcoin_Cost"},
+}
+
+// exportLineageManifest writes the per-row field lineage next to the CSV as {name}.lineage.json
+func exportLineageManifest(data []DailyFinancialData, symbol, csvPath string) (string, error) {
+	manifest := models.LineageManifest{
+		Symbol:      symbol,
+		DataFile:    filepath.Base(csvPath),
+		GeneratedAt: time.Now(),
+		Rows:        make(map[string]models.RowLineage, len(data)),
+	}
+	for _, record := range data {
+		if len(record.Lineage) > 0 {
+			manifest.Rows[record.Date.Format("2006-01-02")] = record.Lineage
+		}
+	}
+
+	manifestPath := strings.TrimSuffix(csvPath, filepath.Ext(csvPath)) + ".lineage.json"
+	if err := storage.WriteJSONAtomic(manifestPath, manifest); err != nil {
+		return "", err
+	}
+	return manifestPath, nil
+}
+
+// repositoryLineage describes values loaded from a storage backend
+func repositoryLineage(source string) models.FieldLineage {
+	if source == "" {
+		source = "repository"
+	}
+	return models.FieldLineage{Source: source, Method: models.LineageObserved}
+}
+
+// bitcoinSourceFromFile infers the price provider from a Bitcoin price file name
+func bitcoinSourceFromFile(filename, fallback string) string {
+	name := strings.ToLower(filepath.Base(filename))
+	switch {
+	case strings.Contains(name, "coinmarketcap"):
+		return "coinmarketcap"
+	case strings.Contains(name, "coingecko"):
+		return "coingecko"
+	default:
+		return fallback
+	}
+}
+
 // formatFloat formats a float64 for CSV
 func formatFloat(f float64) string {
 	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
@@ -1430,6 +1994,7 @@ func loadFromBitcoinHistoricalJSON(filename string) (*BitcoinDataResponse, error
 	bitcoinData := &BitcoinDataResponse{
 		Prices: make([]BitcoinDataPoint, 0, len(historicalData.Data)),
 	}
+	lineage := models.FileLineage(bitcoinSourceFromFile(filename, "bitcoin-prices"), filename)
 
 	for _, dataPoint := range historicalData.Data {
 		// Parse the date
@@ -1441,6 +2006,7 @@ func loadFromBitcoinHistoricalJSON(filename string) (*BitcoinDataResponse, error
 		bitcoinPoint := BitcoinDataPoint{
 			Timestamp: timestamp,
 			Price:     dataPoint.Close, // Use close price
+			Lineage:   lineage,
 		}
 
 		bitcoinData.Prices = append(bitcoinData.Prices, bitcoinPoint)
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+func main() {
+	var (
+		basePath = flag.String("base", ".", "Project root containing the data directory")
+		dbPath   = flag.String("db", "data/mnav.db", "SQLite database to import into")
+		symbols  = flag.String("symbols", "", "Comma-separated symbols to import (default: discover from companies.json and EDGAR data)")
+		verbose  = flag.Bool("verbose", false, "Enable verbose output")
+	)
+	flag.Parse()
+
+	fmt.Printf("📦 LEGACY DATA IMPORTER\n")
+	fmt.Printf("=======================\n\n")
+	fmt.Printf("📁 Source: %s/data\n", *basePath)
+	fmt.Printf("🗄️  Destination: %s\n\n", *dbPath)
+
+	store, err := repository.OpenSQLiteStore(*dbPath)
+	if err != nil {
+		log.Fatalf("❌ Error opening database: %v", err)
+	}
+	defer store.Close()
+
+	var symbolList []string
+	if *symbols != "" {
+		for _, s := range strings.Split(*symbols, ",") {
+			if s = strings.TrimSpace(strings.ToUpper(s)); s != "" {
+				symbolList = append(symbolList, s)
+			}
+		}
+	}
+
+	report, err := repository.ImportLegacyData(*basePath, store, symbolList)
+	if err != nil {
+		log.Fatalf("❌ Import failed: %v", err)
+	}
+
+	printReport(report, *verbose)
+}
+
+func printReport(report *repository.ImportReport, verbose bool) {
+	fmt.Printf("🏢 Companies: %s\n\n", strings.Join(report.Symbols, ", "))
+
+	fmt.Printf("📈 Prices:\n")
+	for _, symbol := range sortedKeys(report.Prices) {
+		fmt.Printf("   %-8s %6d days\n", symbol, report.Prices[symbol])
+	}
+
+	fmt.Printf("\n📄 Per company:\n")
+	for _, symbol := range report.Symbols {
+		fmt.Printf("   %-8s %4d filings | %4d BTC transactions | %4d shares records\n",
+			symbol, report.Filings[symbol], report.Transactions[symbol], report.Shares[symbol])
+	}
+
+	fmt.Printf("\n💼 Portfolio snapshots: %d\n", report.Portfolios)
+
+	if len(report.Warnings) > 0 {
+		fmt.Printf("\n⚠️  %d warnings\n", len(report.Warnings))
+		if verbose {
+			for _, warning := range report.Warnings {
+				fmt.Printf("   • %s\n", warning)
+			}
+		}
+	}
+
+	fmt.Printf("\n✅ Import complete!\n")
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
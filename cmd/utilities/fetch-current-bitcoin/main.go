@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/coindesk"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // Use the structures from coindesk package
@@ -108,7 +109,9 @@ func main() {
 		log.Fatalf("❌ Error marshaling data: %v", err)
 	}
 
-	if err := os.WriteFile(outputFile, jsonData, 0644); err != nil {
+	if err := storage.WithLock(outputFile+".lock", func() error {
+		return storage.WriteFileAtomic(outputFile, jsonData, 0644)
+	}); err != nil {
 		log.Fatalf("❌ Error writing file: %v", err)
 	}
 
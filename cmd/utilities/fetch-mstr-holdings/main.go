@@ -11,6 +11,7 @@ import (
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/scraper"
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 func main() {
@@ -143,7 +144,9 @@ func saveTransactionData(transactions []models.BitcoinTransaction, holdings *scr
 		return fmt.Errorf("error marshaling data: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filePath+".lock", func() error {
+		return storage.WriteFileAtomic(filePath, jsonData, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing file: %w", err)
 	}
 
@@ -158,7 +161,9 @@ func saveTransactionData(transactions []models.BitcoinTransaction, holdings *scr
 		return fmt.Errorf("error marshaling holdings: %w", err)
 	}
 
-	if err := os.WriteFile(holdingsPath, holdingsData, 0644); err != nil {
+	if err := storage.WithLock(holdingsPath+".lock", func() error {
+		return storage.WriteFileAtomic(holdingsPath, holdingsData, 0644)
+	}); err != nil {
 		return fmt.Errorf("error writing holdings file: %w", err)
 	}
 
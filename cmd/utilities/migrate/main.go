@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// documentPatterns lists where each kind of document lives, relative to the project root
+var documentPatterns = map[schema.Kind][]string{
+	schema.CompanyData:         {"data/edgar/companies/*/financial_data.json"},
+	schema.CompanySnapshot:     {"data/edgar/companies/*/latest_snapshot.json"},
+	schema.RawFiling:           {"data/edgar/companies/*/raw_filings/*.json"},
+	schema.Portfolio:           {"data/portfolio/processed/portfolio_*.json"},
+	schema.RebalancingConfig:   {"configs/rebalancing/*.json"},
+	schema.CompanyEvents:       {"data/events/*.json"},
+	schema.CompanyRegistry:     {"data/companies.json"},
+	schema.FXRates:             {"data/fx/*.json"},
+	schema.RebalancingState:    {"data/portfolio/processed/rebalancing_state.json"},
+	schema.AssetClassification: {"configs/portfolio/asset_classes.json"},
+	schema.PortfolioAccounts:   {"configs/portfolio/accounts.json"},
+	schema.TaxLots:             {"data/portfolio/processed/tax_lots.json"},
+	schema.PortfolioLedger:     {"data/portfolio/processed/ledger.json"},
+	schema.PortfolioScenarios:  {"configs/portfolio/scenarios.json"},
+	schema.PortfolioKeyring:    {"data/portfolio/keyring.json"},
+}
+
+// migrationStats counts the outcome per document kind
+type migrationStats struct {
+	upToDate int
+	migrated int
+	failed   int
+}
+
+func main() {
+	var (
+		basePath = flag.String("base", ".", "Project root containing data/ and configs/")
+		dryRun   = flag.Bool("dry-run", false, "Show what would be migrated without writing")
+		showDiff = flag.Bool("diff", false, "Show a diff of each migrated document")
+		kind     = flag.String("kind", "", "Only migrate one document kind (e.g. company_data, portfolio)")
+		verbose  = flag.Bool("verbose", false, "Enable verbose output")
+	)
+	flag.Parse()
+
+	fmt.Printf("🧬 DATA SCHEMA MIGRATION\n")
+	fmt.Printf("========================\n\n")
+	if *dryRun {
+		fmt.Printf("🔍 Dry run: no files will be written\n\n")
+	}
+
+	kinds := schema.Kinds()
+	if *kind != "" {
+		if _, ok := documentPatterns[schema.Kind(*kind)]; !ok {
+			log.Fatalf("❌ Unknown document kind: %s", *kind)
+		}
+		kinds = []schema.Kind{schema.Kind(*kind)}
+	}
+
+	stats := make(map[schema.Kind]*migrationStats)
+	for _, k := range kinds {
+		stats[k] = &migrationStats{}
+
+		var files []string
+		for _, pattern := range documentPatterns[k] {
+			matches, _ := filepath.Glob(filepath.Join(*basePath, pattern))
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+
+		fmt.Printf("📂 %s (current version %d): %d files\n", k, schema.CurrentVersion(k), len(files))
+
+		for _, file := range files {
+			migrated, err := migrateFile(k, file, *dryRun, *showDiff, *verbose)
+			switch {
+			case err != nil:
+				stats[k].failed++
+				fmt.Printf("   ❌ %s: %v\n", file, err)
+			case migrated:
+				stats[k].migrated++
+			default:
+				stats[k].upToDate++
+			}
+		}
+	}
+
+	fmt.Printf("\n📊 Summary:\n")
+	var failed int
+	for _, k := range kinds {
+		s := stats[k]
+		action := "migrated"
+		if *dryRun {
+			action = "to migrate"
+		}
+		fmt.Printf("   %-20s %4d up to date | %4d %s | %4d failed\n", k, s.upToDate, s.migrated, action, s.failed)
+		failed += s.failed
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Migration complete!\n")
+}
+
+// migrateFile upgrades one document, returning whether any migration applied. Real
+// runs hold the owning store's lock from read to write so no concurrent update is lost.
+func migrateFile(kind schema.Kind, file string, dryRun, showDiff, verbose bool) (bool, error) {
+	if dryRun {
+		return migrateDocument(kind, file, dryRun, showDiff, verbose)
+	}
+
+	var migrated bool
+	err := storage.WithLock(lockPathFor(kind, file), func() error {
+		var err error
+		migrated, err = migrateDocument(kind, file, dryRun, showDiff, verbose)
+		return err
+	})
+	return migrated, err
+}
+
+// migrateDocument reads, migrates and (unless dryRun) rewrites one document
+func migrateDocument(kind schema.Kind, file string, dryRun, showDiff, verbose bool) (bool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	// Encrypted portfolio files are upgraded by the tracker when it loads them
+	if vault.Sealed(data) {
+		if verbose {
+			fmt.Printf("   🔒 %s (encrypted; upgraded on load)\n", file)
+		}
+		return false, nil
+	}
+
+	doc, err := schema.Decode(data)
+	if err != nil {
+		return false, fmt.Errorf("not a JSON document: %w", err)
+	}
+
+	from := schema.Version(kind, doc)
+	before, _ := json.MarshalIndent(doc, "", "  ")
+
+	applied, err := schema.Migrate(kind, doc)
+	if err != nil {
+		return false, err
+	}
+	if len(applied) == 0 {
+		if verbose {
+			fmt.Printf("   ✅ %s (v%d)\n", file, from)
+		}
+		return false, nil
+	}
+
+	fmt.Printf("   🔄 %s: v%d → v%d\n", file, from, schema.CurrentVersion(kind))
+	for _, m := range applied {
+		fmt.Printf("      • %d→%d %s\n", m.From, m.From+1, m.Description)
+	}
+
+	after, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal migrated document: %w", err)
+	}
+
+	if showDiff {
+		printDiff(before, after)
+	}
+
+	if dryRun {
+		return true, nil
+	}
+
+	if err := storage.WriteFileAtomic(file, after, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lockPathFor returns the same lock the owning store takes for a document
+func lockPathFor(kind schema.Kind, file string) string {
+	switch kind {
+	case schema.CompanyData, schema.CompanySnapshot, schema.Portfolio,
+		schema.RebalancingState, schema.TaxLots, schema.PortfolioLedger:
+		return filepath.Join(filepath.Dir(file), ".lock")
+	case schema.RawFiling:
+		return filepath.Join(filepath.Dir(filepath.Dir(file)), ".lock")
+	default:
+		return file + ".lock"
+	}
+}
+
+// printDiff prints the changed region between two documents. Migrations usually touch
+// a few fields, so trimming the common prefix and suffix keeps this cheap on large files.
+func printDiff(before, after []byte) {
+	oldLines := strings.Split(string(bytes.TrimSpace(before)), "\n")
+	newLines := strings.Split(string(bytes.TrimSpace(after)), "\n")
+
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	fmt.Printf("      @@ line %d @@\n", prefix+1)
+	for _, line := range oldLines[prefix : len(oldLines)-suffix] {
+		fmt.Printf("      - %s\n", line)
+	}
+	for _, line := range newLines[prefix : len(newLines)-suffix] {
+		fmt.Printf("      + %s\n", line)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+)
+
+func TestLockPathForMatchesTracker(t *testing.T) {
+	dir := filepath.Join("data", "portfolio", "processed")
+	files := map[schema.Kind]string{
+		schema.Portfolio:        "portfolio_2025-06-11.json",
+		schema.RebalancingState: "rebalancing_state.json",
+		schema.TaxLots:          "tax_lots.json",
+		schema.PortfolioLedger:  "ledger.json",
+	}
+	for kind, name := range files {
+		if got, want := lockPathFor(kind, filepath.Join(dir, name)), tracker.LockPath(dir); got != want {
+			t.Errorf("%s: migrate locks %s, the tracker %s", kind, got, want)
+		}
+	}
+}
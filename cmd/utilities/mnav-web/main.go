@@ -13,6 +13,10 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	pmodels "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
 )
 
 // ScriptOutput represents the parsed output from the update-mnav script
@@ -48,14 +52,15 @@ type MarketTrend struct {
 
 // PortfolioData represents portfolio analysis
 type PortfolioData struct {
-	Holdings        []PortfolioHolding `json:"holdings"`
-	NetValue        string             `json:"net_value"`
-	NetBitcoinValue string             `json:"net_bitcoin_value"`
-	BitcoinExposure string             `json:"bitcoin_exposure"`
-	CurrentRatio    string             `json:"current_ratio"`
-	TargetRatio     string             `json:"target_ratio"`
-	IsBalanced      bool               `json:"is_balanced"`
-	Recommendation  string             `json:"recommendation"`
+	Holdings        []PortfolioHolding        `json:"holdings"`
+	NetValue        string                    `json:"net_value"`
+	NetBitcoinValue string                    `json:"net_bitcoin_value"`
+	BitcoinExposure string                    `json:"bitcoin_exposure"`
+	CurrentRatio    string                    `json:"current_ratio"`
+	TargetRatio     string                    `json:"target_ratio"`
+	IsBalanced      bool                      `json:"is_balanced"`
+	Recommendation  string                    `json:"recommendation"`
+	RuleState       *pmodels.RebalancingState `json:"rule_state,omitempty"` // Saved by portfolio-analyzer
 }
 
 // PortfolioHolding represents individual portfolio holdings
@@ -436,6 +441,7 @@ func (ws *WebServer) serveHTML(w http.ResponseWriter, r *http.Request) {
         <div class="header">
             <h1>🚀 mNAV Dashboard</h1>
             <p>MicroStrategy Net Asset Value Tracking & Portfolio Analysis</p>
+            <p><a href="/screener" style="color: white;">🔎 Compare treasury companies</a></p>
         </div>
         
         <div class="update-section">
@@ -695,6 +701,16 @@ func (ws *WebServer) serveHTML(w http.ResponseWriter, r *http.Request) {
                     html += '<tr><td>Target FBTC:MSTR Ratio</td><td>' + data.portfolio.target_ratio + '</td></tr>';
                 }
                 
+                if (data.portfolio.rule_state) {
+                    const state = data.portfolio.rule_state;
+                    html += '<tr><td>Active Rule</td><td>' + state.active_ratio + ':1 (mNAV ' + state.down_threshold.toFixed(4) + ' - ' + state.up_threshold.toFixed(4) + ', since ' + state.active_since.slice(0, 10) + ')</td></tr>';
+                    const last = state.transitions && state.transitions.length ? state.transitions[state.transitions.length - 1] : null;
+                    if (last) {
+                        const from = last.from_ratio ? last.from_ratio + ':1' : 'start';
+                        html += '<tr><td>Last Transition</td><td>' + last.date.slice(0, 10) + ': ' + from + ' → ' + last.to_ratio + ':1 at mNAV ' + last.mnav.toFixed(4) + '<br><span style="font-style: italic;">' + last.reason + '</span></td></tr>';
+                    }
+                }
+
                 let balanceStatus = data.portfolio.is_balanced ? '✅ Balanced' : '⚠️ Needs Rebalancing';
                 html += '<tr><td>Balance Status</td><td>' + balanceStatus + '</td></tr>';
                 
@@ -1033,17 +1049,14 @@ func (ws *WebServer) handleUpload(w http.ResponseWriter, r *http.Request) {
 		destPath = filepath.Join(portfolioDir, fmt.Sprintf("%s_%s%s", name, timestamp, ext))
 	}
 
-	// Create destination file
-	destFile, err := os.Create(destPath)
+	// Save the upload, sealed when the portfolio has a keyring
+	data, err := io.ReadAll(file)
 	if err != nil {
-		ws.sendError(w, "Failed to create destination file: "+err.Error())
+		ws.sendError(w, "Failed to read file: "+err.Error())
 		return
 	}
-	defer destFile.Close()
-
-	// Copy uploaded file to destination
-	_, err = io.Copy(destFile, file)
-	if err != nil {
+	files := vault.NewFiles(filepath.Join(ws.workspaceRoot, "data", "portfolio"))
+	if err := files.WriteFile(destPath, data, 0644); err != nil {
 		ws.sendError(w, "Failed to save file: "+err.Error())
 		return
 	}
@@ -1539,6 +1552,13 @@ func (ws *WebServer) updateCachedData() *ScriptOutput {
 		ws.parseScriptOutput(&result, string(output))
 	}
 
+	// Show the rule state machine's active rule alongside the script's analysis
+	if state, err := tracker.NewTracker("data/portfolio/processed").LoadRebalancingState(); err != nil {
+		log.Printf("Could not load rebalancing state: %v", err)
+	} else if state.ActiveRatio != 0 {
+		result.Portfolio.RuleState = state
+	}
+
 	// Cache the result
 	ws.mutex.Lock()
 	ws.cachedData = &result
@@ -1588,6 +1608,8 @@ func main() {
 	http.HandleFunc("/api/update", validatedHandler(server.handleUpdate))
 	http.HandleFunc("/api/data", validatedHandler(server.handleData))
 	http.HandleFunc("/api/upload", validatedHandler(server.handleUpload))
+	http.HandleFunc("/screener", validatedHandler(server.serveScreener))
+	http.HandleFunc("/api/screener", validatedHandler(server.handleScreener))
 
 	// Bind to port 8080 on all interfaces (hostname filtering handled by middleware)
 	port := ":8080"
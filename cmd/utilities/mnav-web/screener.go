@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/metrics"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// ScreenerResponse is the screener API payload: one row per company plus aligned
+// daily series for the comparison charts
+type ScreenerResponse struct {
+	Window      int                    `json:"window"`
+	WindowStart string                 `json:"window_start"`
+	Rows        []*metrics.ScreenerRow `json:"rows"`
+	Dates       []string               `json:"dates"`
+	Series      []ScreenerSeries       `json:"series"`
+	Warnings    []string               `json:"warnings,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// ScreenerSeries holds a company's chart series; nulls mark days without data
+type ScreenerSeries struct {
+	Symbol           string     `json:"symbol"`
+	MNAV             []*float64 `json:"mnav"`
+	HoldingsPerShare []*float64 `json:"holdings_per_share_index"`
+	StockPrice       []*float64 `json:"stock_price_index"`
+}
+
+// handleScreener screens every company in the registry
+func (ws *WebServer) handleScreener(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := 365
+	if v := r.URL.Query().Get("window"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			window = n
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response, err := ws.screen(window)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ScreenerResponse{Window: window, Error: err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// screen runs the screener against the workspace's JSON store
+func (ws *WebServer) screen(window int) (*ScreenerResponse, error) {
+	registry, err := config.LoadCompaniesConfig(ws.workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+	store := repository.NewJSONStore(ws.workspaceRoot)
+	defer store.Close()
+	fx, err := storage.NewFXStorage(filepath.Join(ws.workspaceRoot, "data", "fx")).LoadTable()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, errs := metrics.ScreenCompanies(store, registry.Companies, time.Time{}, window, fx)
+	response := &ScreenerResponse{Window: window, Rows: rows}
+	for _, err := range errs {
+		response.Warnings = append(response.Warnings, err.Error())
+	}
+	if len(rows) == 0 {
+		return response, nil
+	}
+	metrics.SortScreenerRows(rows, "mnav", true)
+
+	windowStart := rows[0].WindowStart
+	response.WindowStart = windowStart.Format("2006-01-02")
+
+	// Align every company's history on the union of dates in the window
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for _, p := range row.History {
+			if !p.Date.Before(windowStart) {
+				seen[p.Date.Format("2006-01-02")] = true
+			}
+		}
+	}
+	for d := range seen {
+		response.Dates = append(response.Dates, d)
+	}
+	sort.Strings(response.Dates)
+	index := make(map[string]int, len(response.Dates))
+	for i, d := range response.Dates {
+		index[d] = i
+	}
+
+	for _, row := range rows {
+		mnav := make([]float64, len(response.Dates))
+		perShare := make([]float64, len(response.Dates))
+		price := make([]float64, len(response.Dates))
+		for i := range mnav {
+			mnav[i], perShare[i], price[i] = math.NaN(), math.NaN(), math.NaN()
+		}
+		for _, p := range row.History {
+			if i, ok := index[p.Date.Format("2006-01-02")]; ok {
+				mnav[i], perShare[i], price[i] = p.MNAV, p.HoldingsPerShare, p.StockPrice
+			}
+		}
+		response.Series = append(response.Series, ScreenerSeries{
+			Symbol:           row.Symbol,
+			MNAV:             nullable(mnav),
+			HoldingsPerShare: nullable(metrics.IndexSeries(perShare)),
+			StockPrice:       nullable(metrics.IndexSeries(price)),
+		})
+		row.History = nil
+	}
+	return response, nil
+}
+
+// nullable converts NaN gaps to JSON nulls
+func nullable(values []float64) []*float64 {
+	out := make([]*float64, len(values))
+	for i := range values {
+		if !math.IsNaN(values[i]) {
+			out[i] = &values[i]
+		}
+	}
+	return out
+}
+
+// serveScreener serves the comparative screener page
+func (ws *WebServer) serveScreener(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(screenerHTML))
+}
+
+// screenerHTML is the screener page: a sortable table and normalised comparison charts
+const screenerHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Treasury Company Screener</title>
+    <script src="https://cdn.jsdelivr.net/npm/chart.js"></script>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            min-height: 100vh;
+            margin: 0;
+            padding: 20px;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 0 auto;
+            background: white;
+            border-radius: 15px;
+            box-shadow: 0 20px 40px rgba(0,0,0,0.1);
+            overflow: hidden;
+        }
+        .header {
+            background: linear-gradient(135deg, #2c3e50 0%, #3498db 100%);
+            color: white;
+            padding: 30px;
+            text-align: center;
+        }
+        .header h1 {
+            font-size: 2.2em;
+            margin: 0 0 10px 0;
+            font-weight: 300;
+        }
+        .header a {
+            color: white;
+        }
+        .section {
+            padding: 20px 30px;
+        }
+        .controls {
+            display: flex;
+            gap: 12px;
+            align-items: center;
+            flex-wrap: wrap;
+        }
+        .controls button {
+            border: 1px solid #3498db;
+            background: white;
+            color: #3498db;
+            border-radius: 20px;
+            padding: 6px 16px;
+            cursor: pointer;
+        }
+        .controls button.active {
+            background: #3498db;
+            color: white;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+            font-size: 0.9em;
+        }
+        th, td {
+            padding: 8px 10px;
+            text-align: right;
+            border-bottom: 1px solid #eee;
+        }
+        th:first-child, td:first-child, th:nth-child(2), td:nth-child(2) {
+            text-align: left;
+        }
+        th {
+            cursor: pointer;
+            user-select: none;
+            background: #f8f9fa;
+        }
+        th.sorted::after {
+            content: attr(data-arrow);
+        }
+        .chart-container {
+            position: relative;
+            height: 450px;
+        }
+        .warnings {
+            color: #c0392b;
+            font-size: 0.9em;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>🔎 Treasury Company Screener</h1>
+            <p>mNAV, premium percentile and holdings/share growth for every company in the registry · <a href="/">Back to dashboard</a></p>
+        </div>
+        <div class="section controls">
+            <span>Window:</span>
+            <button data-window="90">90d</button>
+            <button data-window="180">180d</button>
+            <button data-window="365" class="active">1y</button>
+            <button data-window="730">2y</button>
+            <span id="windowStart"></span>
+        </div>
+        <div class="section">
+            <table>
+                <thead><tr id="tableHead"></tr></thead>
+                <tbody id="tableBody"></tbody>
+            </table>
+            <div id="warnings" class="warnings"></div>
+        </div>
+        <div class="section controls">
+            <span>Compare:</span>
+            <button data-series="mnav" class="active">mNAV</button>
+            <button data-series="holdings_per_share_index">Holdings/share (indexed)</button>
+            <button data-series="stock_price_index">Stock price (indexed)</button>
+        </div>
+        <div class="section">
+            <div class="chart-container"><canvas id="compareChart"></canvas></div>
+        </div>
+    </div>
+
+    <script>
+        const columns = [
+            {key: 'symbol', label: 'Symbol', format: v => v},
+            {key: 'name', label: 'Name', format: v => v},
+            {key: 'mnav', label: 'mNAV', format: v => v.toFixed(2)},
+            {key: 'evMnav', label: 'EV mNAV', format: v => v.toFixed(2)},
+            {key: 'premiumPercent', label: 'Premium', format: v => v.toFixed(1) + '%'},
+            {key: 'mnavLow', label: 'Low', format: v => v.toFixed(2)},
+            {key: 'mnavMedian', label: 'Median', format: v => v.toFixed(2)},
+            {key: 'mnavHigh', label: 'High', format: v => v.toFixed(2)},
+            {key: 'premiumPercentile', label: 'Percentile', format: v => v.toFixed(0) + '%'},
+            {key: 'holdingsPerShareGrowthPercent', label: 'Holdings/share Δ', format: v => v.toFixed(1) + '%'},
+            {key: 'primaryAsset', label: 'Asset', format: v => v},
+            {key: 'nav', label: 'NAV', format: v => '$' + (v / 1e9).toFixed(2) + 'B'},
+            {key: 'marketCap', label: 'Market Cap', format: v => '$' + (v / 1e9).toFixed(2) + 'B'},
+        ];
+        const colors = ['#f7931a', '#36a2eb', '#ff6384', '#4bc0c0', '#9966ff', '#2ea043', '#c94c4c', '#d6a800', '#5a5a5a', '#008080'];
+
+        let data = null;
+        let sortKey = 'mnav';
+        let descending = true;
+        let seriesKey = 'mnav';
+        let chart = null;
+
+        function renderTable() {
+            const head = document.getElementById('tableHead');
+            head.innerHTML = '';
+            columns.forEach(col => {
+                const th = document.createElement('th');
+                th.textContent = col.label;
+                if (col.key === sortKey) {
+                    th.className = 'sorted';
+                    th.dataset.arrow = descending ? ' ▼' : ' ▲';
+                }
+                th.onclick = () => {
+                    descending = col.key === sortKey ? !descending : !['symbol', 'name', 'primaryAsset'].includes(col.key);
+                    sortKey = col.key;
+                    renderTable();
+                };
+                head.appendChild(th);
+            });
+
+            const rows = [...data.rows].sort((a, b) => {
+                const x = a[sortKey], y = b[sortKey];
+                const cmp = typeof x === 'string' ? x.localeCompare(y) : x - y;
+                return descending ? -cmp : cmp;
+            });
+            const body = document.getElementById('tableBody');
+            body.innerHTML = '';
+            rows.forEach(row => {
+                const tr = document.createElement('tr');
+                columns.forEach(col => {
+                    const td = document.createElement('td');
+                    td.textContent = col.format(row[col.key]);
+                    tr.appendChild(td);
+                });
+                body.appendChild(tr);
+            });
+        }
+
+        function renderChart() {
+            const datasets = data.series.map((s, i) => ({
+                label: s.symbol,
+                data: s[seriesKey],
+                borderColor: colors[i % colors.length],
+                pointRadius: 0,
+                borderWidth: 2,
+                spanGaps: true,
+                fill: false,
+            }));
+            if (chart) {
+                chart.destroy();
+            }
+            chart = new Chart(document.getElementById('compareChart').getContext('2d'), {
+                type: 'line',
+                data: {labels: data.dates, datasets: datasets},
+                options: {
+                    responsive: true,
+                    maintainAspectRatio: false,
+                    interaction: {mode: 'index', intersect: false},
+                    scales: {
+                        y: {title: {display: true, text: seriesKey === 'mnav' ? 'mNAV' : 'Index (start = 100)'}}
+                    }
+                }
+            });
+        }
+
+        async function load(window) {
+            const response = await fetch('/api/screener?window=' + window);
+            data = await response.json();
+            document.getElementById('warnings').textContent = data.error || (data.warnings || []).join(' · ');
+            if (!data.rows) {
+                return;
+            }
+            document.getElementById('windowStart').textContent = 'since ' + data.window_start;
+            renderTable();
+            renderChart();
+        }
+
+        function activate(buttons, button) {
+            buttons.forEach(b => b.classList.toggle('active', b === button));
+        }
+
+        const windowButtons = document.querySelectorAll('[data-window]');
+        windowButtons.forEach(b => b.onclick = () => { activate(windowButtons, b); load(b.dataset.window); });
+        const seriesButtons = document.querySelectorAll('[data-series]');
+        seriesButtons.forEach(b => b.onclick = () => { activate(seriesButtons, b); seriesKey = b.dataset.series; renderChart(); });
+
+        load(365);
+    </script>
+</body>
+</html>`
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/collection/yahoo"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // StockDataCollection represents the stock data file format
@@ -205,7 +206,9 @@ func saveStockDataFile(data *StockDataCollection, filename string) error {
 		return err
 	}
 
-	return os.WriteFile(filename, jsonData, 0644)
+	return storage.WithLock(filename+".lock", func() error {
+		return storage.WriteFileAtomic(filename, jsonData, 0644)
+	})
 }
 
 func getLatestDateInData(data *StockDataCollection) string {
@@ -10,7 +10,7 @@ import (
 
 const (
 	coinGeckoCurrentEndpoint    = "https://api.coingecko.com/api/v3/simple/price"
-	coinGeckoHistoricalEndpoint = "https://api.coingecko.com/api/v3/coins/bitcoin/market_chart/range"
+	coinGeckoHistoricalEndpoint = "https://api.coingecko.com/api/v3/coins/%s/market_chart/range"
 )
 
 // CoinGeckoMarketChartResponse represents the response from CoinGecko market chart endpoint
@@ -86,6 +86,12 @@ func (c *Client) GetCurrentPrice() (*CurrentPriceResponse, error) {
 
 // GetHistoricalPrices fetches historical Bitcoin prices from CoinGecko
 func (c *Client) GetHistoricalPrices(startDate, endDate string) (*HistoricalBitcoinData, error) {
+	return c.GetHistoricalAssetPrices("bitcoin", "BTC", startDate, endDate)
+}
+
+// GetHistoricalAssetPrices fetches historical USD prices for any CoinGecko coin id
+// (e.g. "ethereum", "solana"), labelled with the asset symbol
+func (c *Client) GetHistoricalAssetPrices(coinID, symbol, startDate, endDate string) (*HistoricalBitcoinData, error) {
 	// Validate date formats
 	start, err := time.Parse("2006-01-02", startDate)
 	if err != nil {
@@ -107,8 +113,8 @@ func (c *Client) GetHistoricalPrices(startDate, endDate string) (*HistoricalBitc
 	}
 
 	// Build URL with UNIX timestamps
-	url := fmt.Sprintf("%s?vs_currency=usd&from=%d&to=%d",
-		coinGeckoHistoricalEndpoint, start.Unix(), end.Unix())
+	url := fmt.Sprintf(coinGeckoHistoricalEndpoint+"?vs_currency=usd&from=%d&to=%d",
+		coinID, start.Unix(), end.Unix())
 
 	resp, err := c.client.Get(url)
 	if err != nil {
@@ -128,7 +134,7 @@ func (c *Client) GetHistoricalPrices(startDate, endDate string) (*HistoricalBitc
 
 	// Convert to our standard format
 	histData := &HistoricalBitcoinData{
-		Symbol:    "BTC",
+		Symbol:    symbol,
 		StartDate: startDate,
 		EndDate:   endDate,
 		Data:      make([]HistoricalBitcoinPrice, 0, len(marketResp.Prices)),
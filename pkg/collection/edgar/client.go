@@ -9,10 +9,13 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ultrarare-tech/mNAV/pkg/config"
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 	"golang.org/x/time/rate"
 
 	"compress/gzip"
@@ -34,6 +37,11 @@ type Client struct {
 	httpClient *http.Client
 	userAgent  string
 	limiter    *rate.Limiter
+
+	// ciks caches ticker → CIK from the company registry and SEC lookups;
+	// nonSEC lists registry companies identified outside the SEC
+	ciks   map[string]string
+	nonSEC map[string]string
 }
 
 // NewClient creates a new SEC EDGAR API client
@@ -50,9 +58,31 @@ func NewClient(userAgent string) *Client {
 		},
 		userAgent: userAgent,
 		limiter:   rate.NewLimiter(rate.Limit(0.1), 1), // 1 request per 10 seconds
+		ciks:      make(map[string]string),
+		nonSEC:    make(map[string]string),
+	}
+}
+
+// UseRegistry resolves tickers from the company registry before asking the SEC
+func (c *Client) UseRegistry(registry *config.CompaniesConfig) {
+	for _, company := range registry.Companies {
+		ticker := strings.ToUpper(company.Symbol)
+		if company.CIK != "" {
+			c.RegisterCIK(ticker, company.CIK)
+		} else if !company.IsSECRegistrant() {
+			c.nonSEC[ticker] = company.Identifier
+		}
 	}
 }
 
+// RegisterCIK records the CIK for a ticker so it is not looked up
+func (c *Client) RegisterCIK(ticker, cik string) {
+	if n, err := strconv.Atoi(cik); err == nil {
+		cik = fmt.Sprintf("%010d", n)
+	}
+	c.ciks[strings.ToUpper(ticker)] = cik
+}
+
 // Get performs a rate-limited GET request to the specified URL
 func (c *Client) Get(url string) (*http.Response, error) {
 	// Wait for rate limiter
@@ -118,9 +148,12 @@ type TickersMap map[string]TickerData
 
 // GetCIKByTicker finds the CIK (Central Index Key) for a given ticker symbol
 func (c *Client) GetCIKByTicker(ticker string) (string, error) {
-	// Hard-coded fallback for MSTR in case the API call fails
-	if ticker == "MSTR" {
-		return "0001050446", nil
+	tickerUpper := strings.ToUpper(ticker)
+	if cik, ok := c.ciks[tickerUpper]; ok {
+		return cik, nil
+	}
+	if identifier, ok := c.nonSEC[tickerUpper]; ok {
+		return "", fmt.Errorf("%s is not an SEC registrant (identifier %s)", ticker, identifier)
 	}
 
 	// Retrieve the company tickers mapping from SEC
@@ -137,11 +170,12 @@ func (c *Client) GetCIKByTicker(ticker string) (string, error) {
 	}
 
 	// Find the ticker in the map
-	tickerUpper := strings.ToUpper(ticker)
 	for _, data := range tickersMap {
 		if data.Ticker == tickerUpper {
 			// Format CIK with leading zeros to 10 digits
-			return fmt.Sprintf("%010d", data.CIK), nil
+			cik := fmt.Sprintf("%010d", data.CIK)
+			c.ciks[tickerUpper] = cik
+			return cik, nil
 		}
 	}
 
@@ -331,7 +365,9 @@ func (c *Client) DownloadFilingContent(filing models.Filing, baseDir string) (st
 	}
 
 	// Write to file
-	if err := os.WriteFile(filePath, content, 0644); err != nil {
+	if err := storage.WithLock(filePath+".lock", func() error {
+		return storage.WriteFileAtomic(filePath, content, 0644)
+	}); err != nil {
 		return "", fmt.Errorf("error writing file: %w", err)
 	}
 
@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // SaylorTrackerClient mimics the comprehensive approach of SaylorTracker.com
@@ -123,7 +123,9 @@ func (s *SaylorTrackerClient) SaveToJSON(filename string) error {
 		return fmt.Errorf("failed to marshal data to JSON: %w", err)
 	}
 
-	err = os.WriteFile(filename, jsonData, 0644)
+	err = storage.WithLock(filename+".lock", func() error {
+		return storage.WriteFileAtomic(filename, jsonData, 0644)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to write JSON file: %w", err)
 	}
@@ -0,0 +1,122 @@
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+)
+
+// Source labels rates collected by this client
+const Source = "frankfurter (ECB reference rates)"
+
+// TimeSeriesResponse represents the response from the Frankfurter time series endpoint.
+// Rates are units of each currency per one unit of the base currency.
+type TimeSeriesResponse struct {
+	Base      string                        `json:"base"`
+	StartDate string                        `json:"start_date"`
+	EndDate   string                        `json:"end_date"`
+	Rates     map[string]map[string]float64 `json:"rates"`
+}
+
+// Client represents a Frankfurter API client. Frankfurter publishes the European
+// Central Bank's daily reference rates and needs no API key.
+type Client struct {
+	BaseURL string
+	client  *http.Client
+}
+
+// NewClient creates a new Frankfurter client
+func NewClient() *Client {
+	return &Client{
+		BaseURL: "https://api.frankfurter.app",
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// GetHistoricalRates fetches daily USD rates for each currency between startDate and
+// endDate (YYYY-MM-DD), keyed by currency code. Ranges are fetched a year at a time.
+func (c *Client) GetHistoricalRates(currencies []string, startDate, endDate string) (map[string][]models.FXRate, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date format: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date format: %w", err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end date %s is before start date %s", endDate, startDate)
+	}
+
+	var symbols []string
+	for _, currency := range currencies {
+		if currency = models.CurrencyCode(currency); currency != models.USD {
+			symbols = append(symbols, currency)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no non-USD currencies requested")
+	}
+
+	rates := make(map[string][]models.FXRate, len(symbols))
+	for chunkStart := start; !chunkStart.After(end); chunkStart = chunkStart.AddDate(1, 0, 0) {
+		chunkEnd := chunkStart.AddDate(1, 0, -1)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		series, err := c.getTimeSeries(symbols, chunkStart, chunkEnd)
+		if err != nil {
+			return nil, err
+		}
+		for day, perUSD := range series.Rates {
+			date, err := time.Parse("2006-01-02", day)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q in response: %w", day, err)
+			}
+			for currency, unitsPerUSD := range perUSD {
+				if unitsPerUSD > 0 {
+					rates[currency] = append(rates[currency], models.FXRate{Date: date, USDPerUnit: 1 / unitsPerUSD})
+				}
+			}
+		}
+	}
+
+	for currency := range rates {
+		sort.Slice(rates[currency], func(i, j int) bool {
+			return rates[currency][i].Date.Before(rates[currency][j].Date)
+		})
+	}
+	return rates, nil
+}
+
+// getTimeSeries fetches one time series request with USD as the base currency
+func (c *Client) getTimeSeries(symbols []string, start, end time.Time) (*TimeSeriesResponse, error) {
+	url := fmt.Sprintf("%s/%s..%s?from=USD&to=%s", c.BaseURL,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), strings.Join(symbols, ","))
+
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var series TimeSeriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&series); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+	return &series, nil
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // HistoricalDataPoint represents a single day of market data
@@ -251,7 +253,9 @@ func SaveHistoricalDataToFile(data *HistoricalData, directory string) (string, e
 	}
 
 	// Write to file
-	if err := os.WriteFile(filepath, jsonData, 0644); err != nil {
+	if err := storage.WithLock(filepath+".lock", func() error {
+		return storage.WriteFileAtomic(filepath, jsonData, 0644)
+	}); err != nil {
 		return "", fmt.Errorf("error writing data to file: %w", err)
 	}
 
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// Account tax treatments
+const (
+	AccountTaxable = "taxable"
+	AccountIRA     = "ira"  // Traditional IRA, 401(k) and other tax-deferred accounts
+	AccountRoth    = "roth" // Roth IRA and Roth 401(k)
+	AccountHSA     = "hsa"
+)
+
+// AccountTypes lists the account tax treatments
+var AccountTypes = []string{AccountTaxable, AccountIRA, AccountRoth, AccountHSA}
+
+// TaxAdvantaged reports whether trades in an account of this type realize no taxable gains
+func TaxAdvantaged(accountType string) bool {
+	return accountType == AccountIRA || accountType == AccountRoth || accountType == AccountHSA
+}
+
+// AccountsPath is the default location of the account settings
+const AccountsPath = "configs/portfolio/accounts.json"
+
+// AccountSettings describes how an account may be traded
+type AccountSettings struct {
+	Type     string   `json:"type"`                      // taxable, ira, roth or hsa
+	NoSell   bool     `json:"no_sell,omitempty"`         // Never sell here, e.g. an account held for the long term
+	Exclude  []string `json:"exclude_symbols,omitempty"` // Symbols never bought here, e.g. MSTR in a 401(k) without a brokerage window
+	MinTrade float64  `json:"min_trade,omitempty"`       // Smallest trade worth placing (default: the config's min_trade)
+}
+
+// Allows reports whether symbol may be bought in the account
+func (s AccountSettings) Allows(symbol string) bool {
+	for _, excluded := range s.Exclude {
+		if strings.EqualFold(excluded, symbol) {
+			return false
+		}
+	}
+	return true
+}
+
+// AccountsConfig holds settings per account, keyed by account number or name
+type AccountsConfig struct {
+	SchemaVersion int                        `json:"schema_version,omitempty"`
+	MinTrade      float64                    `json:"min_trade,omitempty"` // Default minimum trade size for every account
+	Accounts      map[string]AccountSettings `json:"accounts"`
+}
+
+// Settings returns an account's settings. Accounts that are not configured get a type
+// inferred from their name and no constraints beyond the default minimum trade.
+func (c *AccountsConfig) Settings(number, name string) AccountSettings {
+	settings := AccountSettings{}
+	if c != nil {
+		settings.MinTrade = c.MinTrade
+		for _, key := range []string{number, name} {
+			if configured, ok := c.Accounts[key]; ok && key != "" {
+				if configured.MinTrade == 0 {
+					configured.MinTrade = c.MinTrade
+				}
+				settings = configured
+				break
+			}
+		}
+	}
+	if settings.Type == "" {
+		settings.Type = InferAccountType(name)
+	}
+	return settings
+}
+
+// InferAccountType guesses an account's tax treatment from its name, e.g. "ROTH IRA" or
+// "Rollover IRA"; anything unrecognised is taxable
+func InferAccountType(name string) string {
+	upper := strings.ToUpper(name)
+	switch {
+	case strings.Contains(upper, "ROTH"):
+		return AccountRoth
+	case strings.Contains(upper, "HSA") || strings.Contains(upper, "HEALTH SAVINGS"):
+		return AccountHSA
+	case strings.Contains(upper, "IRA") || strings.Contains(upper, "401K") || strings.Contains(upper, "401(K)") ||
+		strings.Contains(upper, "403B") || strings.Contains(upper, "403(B)") || strings.Contains(upper, "ROLLOVER"):
+		return AccountIRA
+	}
+	return AccountTaxable
+}
+
+// Validate checks every account's type and minimum trade
+func (c *AccountsConfig) Validate() error {
+	if c.MinTrade < 0 {
+		return fmt.Errorf("min_trade must not be negative")
+	}
+	for account, settings := range c.Accounts {
+		if settings.MinTrade < 0 {
+			return fmt.Errorf("%s: min_trade must not be negative", account)
+		}
+		if settings.Type == "" {
+			continue
+		}
+		known := false
+		for _, t := range AccountTypes {
+			known = known || settings.Type == t
+		}
+		if !known {
+			return fmt.Errorf("%s: unknown account type %q (want one of %s)", account, settings.Type, strings.Join(AccountTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// LoadAccountsConfig loads the account settings at path. A missing file leaves every account
+// to name-based inference.
+func LoadAccountsConfig(path string) (*AccountsConfig, error) {
+	c := &AccountsConfig{Accounts: make(map[string]AccountSettings)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account settings: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.PortfolioAccounts, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade account settings: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse account settings: %w", err)
+	}
+	if c.Accounts == nil {
+		c.Accounts = make(map[string]AccountSettings)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid account settings %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// SaveAccountsConfig writes the account settings to path
+func SaveAccountsConfig(c *AccountsConfig, path string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	c.SchemaVersion = schema.CurrentVersion(schema.PortfolioAccounts)
+	return storage.WithLock(path+".lock", func() error {
+		return storage.WriteJSONAtomic(path, c)
+	})
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// Asset classes that portfolio holdings are grouped into
+const (
+	AssetClassSpotBTC     = "spot_btc"     // Spot Bitcoin and Bitcoin ETFs/trusts (FBTC, IBIT, GBTC, ...)
+	AssetClassBTCTreasury = "btc_treasury" // Equity of Bitcoin treasury companies (MSTR, preferreds, ...)
+	AssetClassGold        = "gold"
+	AssetClassCash        = "cash"
+	AssetClassOther       = "other"
+)
+
+// AssetClasses lists the classes in display order
+var AssetClasses = []string{AssetClassSpotBTC, AssetClassBTCTreasury, AssetClassGold, AssetClassCash, AssetClassOther}
+
+// AssetClassLabel returns the display name of a class
+func AssetClassLabel(class string) string {
+	switch class {
+	case AssetClassSpotBTC:
+		return "Spot BTC proxy"
+	case AssetClassBTCTreasury:
+		return "BTC treasury equity"
+	case AssetClassGold:
+		return "Gold"
+	case AssetClassCash:
+		return "Cash"
+	}
+	return "Other"
+}
+
+// AssetClassPath is the default location of the asset classification
+const AssetClassPath = "configs/portfolio/asset_classes.json"
+
+// SymbolClass assigns a symbol to an asset class
+type SymbolClass struct {
+	Class     string  `json:"class"`
+	BTCFactor float64 `json:"btc_factor"` // Fraction of the position's value counted as Bitcoin exposure
+}
+
+// AssetClassification maps symbols to asset classes. Symbols that are not listed are "other"
+// with no Bitcoin exposure.
+type AssetClassification struct {
+	SchemaVersion int                    `json:"schema_version,omitempty"`
+	Symbols       map[string]SymbolClass `json:"symbols"`
+}
+
+// DefaultAssetClassification classifies the common Bitcoin ETFs, treasury stocks, gold funds
+// and money market funds
+func DefaultAssetClassification() *AssetClassification {
+	c := &AssetClassification{Symbols: make(map[string]SymbolClass)}
+	for _, symbol := range []string{"BTC", "FBTC", "IBIT", "GBTC", "BITB", "ARKB", "BTCO", "HODL", "BRRR", "EZBC", "BTCW"} {
+		c.Symbols[symbol] = SymbolClass{Class: AssetClassSpotBTC, BTCFactor: 1}
+	}
+	for _, symbol := range []string{"MSTR", "MTPLF", "SMLR"} {
+		c.Symbols[symbol] = SymbolClass{Class: AssetClassBTCTreasury, BTCFactor: 1}
+	}
+	// Strategy's preferreds are claims on the treasury rather than on the Bitcoin price; STRK
+	// converts into MSTR
+	c.Symbols["STRK"] = SymbolClass{Class: AssetClassBTCTreasury, BTCFactor: 0.5}
+	for _, symbol := range []string{"STRF", "STRD", "STRC"} {
+		c.Symbols[symbol] = SymbolClass{Class: AssetClassBTCTreasury}
+	}
+	for _, symbol := range []string{"GLD", "IAU", "GLDM", "SGOL", "PHYS", "BAR", "AAAU"} {
+		c.Symbols[symbol] = SymbolClass{Class: AssetClassGold}
+	}
+	for _, symbol := range []string{"SPAXX", "FDRXX", "FZFXX", "VMFXX", "SWVXX", "USD"} {
+		c.Symbols[symbol] = SymbolClass{Class: AssetClassCash}
+	}
+	return c
+}
+
+// Classify returns the class of a symbol
+func (c *AssetClassification) Classify(symbol string) SymbolClass {
+	symbol = strings.TrimRight(strings.ToUpper(strings.TrimSpace(symbol)), "*")
+	if class, ok := c.Symbols[symbol]; ok {
+		return class
+	}
+	return SymbolClass{Class: AssetClassOther}
+}
+
+// AddTreasuryCompanies classifies the registry's Bitcoin treasury companies that are not
+// listed yet as BTC treasury equity
+func (c *AssetClassification) AddTreasuryCompanies(companies *CompaniesConfig) {
+	for _, company := range companies.Companies {
+		asset, ok := company.PrimaryAsset()
+		if !ok || asset.Symbol != "BTC" {
+			continue
+		}
+		if _, listed := c.Symbols[strings.ToUpper(company.Symbol)]; !listed {
+			c.Symbols[strings.ToUpper(company.Symbol)] = SymbolClass{Class: AssetClassBTCTreasury, BTCFactor: 1}
+		}
+	}
+}
+
+// Validate checks every symbol's class and factor
+func (c *AssetClassification) Validate() error {
+	known := make(map[string]bool)
+	for _, class := range AssetClasses {
+		known[class] = true
+	}
+	for symbol, class := range c.Symbols {
+		if !known[class.Class] {
+			return fmt.Errorf("%s: unknown asset class %q (want one of %s)", symbol, class.Class, strings.Join(AssetClasses, ", "))
+		}
+		if class.BTCFactor < 0 || class.BTCFactor > 10 {
+			return fmt.Errorf("%s: btc_factor %.2f out of range [0, 10]", symbol, class.BTCFactor)
+		}
+	}
+	return nil
+}
+
+// LoadAssetClassification loads the classification at path. A missing file gives the defaults;
+// listed symbols replace the defaults' entries.
+func LoadAssetClassification(path string) (*AssetClassification, error) {
+	c := DefaultAssetClassification()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset classes: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.AssetClassification, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade asset classes: %w", err)
+	}
+	var file AssetClassification
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse asset classes: %w", err)
+	}
+	for symbol, class := range file.Symbols {
+		c.Symbols[strings.ToUpper(symbol)] = class
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid asset classes %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// SaveAssetClassification writes the classification to path
+func SaveAssetClassification(c *AssetClassification, path string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	c.SchemaVersion = schema.CurrentVersion(schema.AssetClassification)
+	return storage.WithLock(path+".lock", func() error {
+		return storage.WriteJSONAtomic(path, c)
+	})
+}
+
+// SymbolsIn lists the classified symbols of a class in alphabetical order
+func (c *AssetClassification) SymbolsIn(class string) []string {
+	var symbols []string
+	for symbol, sc := range c.Symbols {
+		if sc.Class == class {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
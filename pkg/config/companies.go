@@ -5,7 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // MNAVPriceTarget represents a stock price target for a specific mNAV value
@@ -14,22 +19,165 @@ type MNAVPriceTarget struct {
 	TargetPrice float64 `json:"targetPrice"`
 }
 
-// CompanyData represents a single company's data
+// DefaultCurrency is the trading and reporting currency when a company sets none
+const DefaultCurrency = "USD"
+
+// TreasuryAsset is an asset a company holds on its balance sheet
+type TreasuryAsset struct {
+	Symbol      string  `json:"symbol"`                // Asset ticker, e.g. BTC, ETH, SOL
+	Holdings    float64 `json:"holdings"`              // Units held as of the company's lastUpdated
+	PriceSeries string  `json:"priceSeries,omitempty"` // Unit price series in the store (default {SYMBOL}-USD)
+	CoinGeckoID string  `json:"coingeckoId,omitempty"` // CoinGecko coin id used by the price collector
+	Yield       float64 `json:"yield,omitempty"`       // Daily growth of holdings as a decimal
+}
+
+// PriceSymbol returns the store symbol of the asset's USD unit price series
+func (a TreasuryAsset) PriceSymbol() string {
+	if a.PriceSeries != "" {
+		return a.PriceSeries
+	}
+	return strings.ToUpper(a.Symbol) + "-USD"
+}
+
+// DataSources names the preferred provider for each kind of company data
+type DataSources struct {
+	Prices   string `json:"prices,omitempty"`   // Stock prices: fmp, yahoo
+	Shares   string `json:"shares,omitempty"`   // Shares outstanding: sec, alphavantage, manual
+	Holdings string `json:"holdings,omitempty"` // Treasury holdings: sec, manual
+}
+
+// CompanyData describes a treasury company: its listing, filing identity, fiscal
+// calendar, treasury assets and latest market snapshot
 type CompanyData struct {
-	Symbol            string            `json:"symbol"`
-	Name              string            `json:"name"`
+	Symbol        string          `json:"symbol"`
+	Name          string          `json:"name"`
+	CIK           string          `json:"cik,omitempty"`           // SEC Central Index Key, 10 digits
+	Identifier    string          `json:"identifier,omitempty"`    // Non-SEC identifier, e.g. TSE:3350 or an ISIN
+	Exchange      string          `json:"exchange,omitempty"`      // Listing exchange, e.g. NASDAQ, TSE
+	Currency      string          `json:"currency,omitempty"`      // Trading currency (default USD)
+	FiscalYearEnd string          `json:"fiscalYearEnd,omitempty"` // Fiscal year end as MM-DD (default 12-31)
+	Assets        []TreasuryAsset `json:"assets"`
+	DataSources   DataSources     `json:"dataSources,omitempty"`
+
 	OutstandingShares float64           `json:"outstandingShares"`
-	BTCHoldings       float64           `json:"btcHoldings"`
-	BTCYield          float64           `json:"btcYield"`
-	MarketCap         float64           `json:"marketCap"`
+	MarketCap         float64           `json:"marketCap"` // In the trading currency
 	LastUpdated       time.Time         `json:"lastUpdated"`
 	MNAVPriceTargets  []MNAVPriceTarget `json:"mnavPriceTargets,omitempty"`
 	DaysToCover       float64           `json:"daysToCover,omitempty"`
+
+	// Balance sheet items for enterprise-value adjusted mNAV, in the trading currency
+	TotalDebt       float64 `json:"totalDebt,omitempty"`
+	PreferredEquity float64 `json:"preferredEquity,omitempty"`
+	Cash            float64 `json:"cash,omitempty"`
+}
+
+// TradingCurrency returns the currency the company's shares trade in
+func (c CompanyData) TradingCurrency() string {
+	if c.Currency == "" {
+		return DefaultCurrency
+	}
+	return strings.ToUpper(c.Currency)
+}
+
+// IsSECRegistrant reports whether the company files with the SEC. Companies with only
+// a non-SEC identifier are skipped by the EDGAR collectors.
+func (c CompanyData) IsSECRegistrant() bool {
+	return c.CIK != "" || c.Identifier == ""
+}
+
+// Asset returns the company's holding of an asset
+func (c CompanyData) Asset(symbol string) (TreasuryAsset, bool) {
+	for _, a := range c.Assets {
+		if strings.EqualFold(a.Symbol, symbol) {
+			return a, true
+		}
+	}
+	return TreasuryAsset{}, false
+}
+
+// Holdings returns the units held of an asset, or 0 when the company holds none
+func (c CompanyData) Holdings(symbol string) float64 {
+	a, _ := c.Asset(symbol)
+	return a.Holdings
+}
+
+// PrimaryAsset returns the first listed treasury asset, which per-share metrics and
+// holdings history are reported in
+func (c CompanyData) PrimaryAsset() (TreasuryAsset, bool) {
+	if len(c.Assets) == 0 {
+		return TreasuryAsset{}, false
+	}
+	return c.Assets[0], true
+}
+
+// FiscalYear returns the fiscal year a date falls in, named after the calendar year
+// the fiscal year ends in
+func (c CompanyData) FiscalYear(date time.Time) int {
+	month, day := c.fiscalYearEnd()
+	nextStart := time.Date(date.Year(), month, day, 0, 0, 0, 0, date.Location()).AddDate(0, 0, 1)
+	if date.Before(nextStart) {
+		return date.Year()
+	}
+	return date.Year() + 1
+}
+
+// FiscalQuarter returns the fiscal year and quarter (1-4) a date falls in. Quarters
+// follow calendar months starting the month after the fiscal year end.
+func (c CompanyData) FiscalQuarter(date time.Time) (int, int) {
+	month, _ := c.fiscalYearEnd()
+	// Months elapsed since the fiscal year started, 0-11
+	elapsed := (int(date.Month()) - int(month) - 1 + 24) % 12
+	return c.FiscalYear(date), elapsed/3 + 1
+}
+
+// fiscalYearEnd parses FiscalYearEnd, defaulting to December 31
+func (c CompanyData) fiscalYearEnd() (time.Month, int) {
+	t, err := time.Parse("01-02", c.FiscalYearEnd)
+	if err != nil {
+		return time.December, 31
+	}
+	return t.Month(), t.Day()
+}
+
+// Validate checks the registry entry for missing or malformed fields
+func (c CompanyData) Validate() error {
+	if c.Symbol == "" {
+		return fmt.Errorf("company without a symbol")
+	}
+	if c.CIK != "" {
+		if _, err := strconv.Atoi(c.CIK); err != nil || len(c.CIK) > 10 {
+			return fmt.Errorf("%s: invalid CIK %q", c.Symbol, c.CIK)
+		}
+	}
+	if c.Currency != "" && len(c.Currency) != 3 {
+		return fmt.Errorf("%s: invalid currency %q", c.Symbol, c.Currency)
+	}
+	if c.FiscalYearEnd != "" {
+		if _, err := time.Parse("01-02", c.FiscalYearEnd); err != nil {
+			return fmt.Errorf("%s: invalid fiscal year end %q (expected MM-DD)", c.Symbol, c.FiscalYearEnd)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, a := range c.Assets {
+		symbol := strings.ToUpper(a.Symbol)
+		if symbol == "" {
+			return fmt.Errorf("%s: treasury asset without a symbol", c.Symbol)
+		}
+		if seen[symbol] {
+			return fmt.Errorf("%s: treasury asset %s listed twice", c.Symbol, symbol)
+		}
+		if a.Holdings < 0 {
+			return fmt.Errorf("%s: negative %s holdings", c.Symbol, symbol)
+		}
+		seen[symbol] = true
+	}
+	return nil
 }
 
 // CompaniesConfig represents the structure of the companies.json file
 type CompaniesConfig struct {
-	Companies []CompanyData `json:"companies"`
+	SchemaVersion int           `json:"schemaVersion,omitempty"`
+	Companies     []CompanyData `json:"companies"`
 }
 
 // LoadCompaniesConfig loads company data from the JSON file
@@ -43,15 +191,47 @@ func LoadCompaniesConfig(basePath string) (*CompaniesConfig, error) {
 		return nil, fmt.Errorf("failed to read companies config: %w", err)
 	}
 
+	// Bring older registries (BTC-only companies) up to the current schema
+	data, err = schema.Upgrade(schema.CompanyRegistry, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate companies config: %w", err)
+	}
+
 	// Parse the JSON
 	var config CompaniesConfig
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse companies config: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid companies config: %w", err)
+	}
 
 	return &config, nil
 }
 
+// Validate checks every company and rejects duplicate symbols or CIKs
+func (c *CompaniesConfig) Validate() error {
+	symbols := make(map[string]bool)
+	ciks := make(map[string]string)
+	for _, company := range c.Companies {
+		if err := company.Validate(); err != nil {
+			return err
+		}
+		if symbols[company.Symbol] {
+			return fmt.Errorf("company %s listed twice", company.Symbol)
+		}
+		symbols[company.Symbol] = true
+		if company.CIK != "" {
+			cik := strings.TrimLeft(company.CIK, "0")
+			if other, ok := ciks[cik]; ok {
+				return fmt.Errorf("%s and %s share CIK %s", other, company.Symbol, company.CIK)
+			}
+			ciks[cik] = company.Symbol
+		}
+	}
+	return nil
+}
+
 // GetCompanyBySymbol returns company data for a specific symbol
 func (c *CompaniesConfig) GetCompanyBySymbol(symbol string) (CompanyData, bool) {
 	for _, company := range c.Companies {
@@ -62,6 +242,32 @@ func (c *CompaniesConfig) GetCompanyBySymbol(symbol string) (CompanyData, bool)
 	return CompanyData{}, false
 }
 
+// GetCompanyByCIK returns the company registered under a CIK, ignoring leading zeros
+func (c *CompaniesConfig) GetCompanyByCIK(cik string) (CompanyData, bool) {
+	want := strings.TrimLeft(cik, "0")
+	for _, company := range c.Companies {
+		if company.CIK != "" && strings.TrimLeft(company.CIK, "0") == want {
+			return company, true
+		}
+	}
+	return CompanyData{}, false
+}
+
+// AssetPriceSymbols returns the unit price series of every asset held in the registry
+func (c *CompaniesConfig) AssetPriceSymbols() []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, company := range c.Companies {
+		for _, a := range company.Assets {
+			if symbol := a.PriceSymbol(); !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	return symbols
+}
+
 // UpdateCompany updates the data for a specific company
 func (c *CompaniesConfig) UpdateCompany(updatedCompany CompanyData) bool {
 	// Set the LastUpdated timestamp to now
@@ -82,17 +288,48 @@ func (c *CompaniesConfig) SaveCompaniesConfig(basePath string) error {
 	jsonPath := filepath.Join(basePath, "data", "companies.json")
 
 	// Marshal the JSON with indentation for readability
+	c.SchemaVersion = schema.CurrentVersion(schema.CompanyRegistry)
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal companies config: %w", err)
 	}
 
-	// Write the file
-	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write companies config: %w", err)
-	}
+	// Write the file atomically under the companies.json lock
+	return storage.WithLock(jsonPath+".lock", func() error {
+		if err := storage.WriteFileAtomic(jsonPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write companies config: %w", err)
+		}
+		return nil
+	})
+}
 
-	return nil
+// UpdateCompaniesConfig loads, modifies and saves companies.json while holding its lock,
+// so concurrent updates are not lost
+func UpdateCompaniesConfig(basePath string, update func(c *CompaniesConfig) error) error {
+	jsonPath := filepath.Join(basePath, "data", "companies.json")
+
+	return storage.WithLock(jsonPath+".lock", func() error {
+		c, err := LoadCompaniesConfig(basePath)
+		if err != nil {
+			return err
+		}
+		if err := update(c); err != nil {
+			return err
+		}
+		if err := c.Validate(); err != nil {
+			return err
+		}
+
+		c.SchemaVersion = schema.CurrentVersion(schema.CompanyRegistry)
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal companies config: %w", err)
+		}
+		if err := storage.WriteFileAtomic(jsonPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write companies config: %w", err)
+		}
+		return nil
+	})
 }
 
 // UpdateMNAVPriceTargets sets the mNAV price targets for a company
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCompaniesConfigMigratesLegacyRegistry(t *testing.T) {
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"companies":[{"symbol":"MSTR","name":"Strategy","outstandingShares":250000000,"btcHoldings":500000}]}`
+	if err := os.WriteFile(filepath.Join(base, "data", "companies.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := LoadCompaniesConfig(base)
+	if err != nil {
+		t.Fatalf("Failed to load legacy registry: %v", err)
+	}
+	company, ok := registry.GetCompanyBySymbol("MSTR")
+	if !ok {
+		t.Fatal("Expected MSTR in the registry")
+	}
+	primary, ok := company.PrimaryAsset()
+	if !ok || primary.Symbol != "BTC" || primary.Holdings != 500000 || primary.PriceSymbol() != "BTC-USD" {
+		t.Errorf("Expected BTC holdings to become a treasury asset, got %+v", company.Assets)
+	}
+	if company.TradingCurrency() != "USD" {
+		t.Errorf("Expected default currency USD, got %s", company.TradingCurrency())
+	}
+
+	// Saving writes the current schema version so the migration runs once
+	if err := registry.SaveCompaniesConfig(base); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadCompaniesConfig(base)
+	if err != nil || again.SchemaVersion == 0 || again.Companies[0].Holdings("BTC") != 500000 {
+		t.Errorf("Expected saved registry to round-trip (err %v)", err)
+	}
+}
+
+func TestFiscalCalendar(t *testing.T) {
+	calendar := CompanyData{Symbol: "MSTR"}
+	march := CompanyData{Symbol: "3350.T", FiscalYearEnd: "03-31"}
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		company CompanyData
+		date    string
+		year    int
+		quarter int
+	}{
+		{calendar, "2024-01-15", 2024, 1},
+		{calendar, "2024-12-31", 2024, 4},
+		{march, "2024-03-31", 2024, 4},
+		{march, "2024-04-01", 2025, 1},
+		{march, "2024-12-15", 2025, 3},
+	}
+	for _, tt := range tests {
+		year, quarter := tt.company.FiscalQuarter(date(tt.date))
+		if year != tt.year || quarter != tt.quarter {
+			t.Errorf("%s %s: got FY%d Q%d, want FY%d Q%d", tt.company.Symbol, tt.date, year, quarter, tt.year, tt.quarter)
+		}
+	}
+}
+
+func TestCompaniesConfigValidate(t *testing.T) {
+	tests := map[string]CompaniesConfig{
+		"duplicate symbol": {Companies: []CompanyData{{Symbol: "A"}, {Symbol: "A"}}},
+		"shared CIK":       {Companies: []CompanyData{{Symbol: "A", CIK: "1050446"}, {Symbol: "B", CIK: "0001050446"}}},
+		"duplicate asset":  {Companies: []CompanyData{{Symbol: "A", Assets: []TreasuryAsset{{Symbol: "BTC"}, {Symbol: "btc"}}}}},
+		"bad fiscal end":   {Companies: []CompanyData{{Symbol: "A", FiscalYearEnd: "March"}}},
+		"bad CIK":          {Companies: []CompanyData{{Symbol: "A", CIK: "MSTR"}}},
+	}
+	for name, registry := range tests {
+		if err := registry.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	valid := CompaniesConfig{Companies: []CompanyData{
+		{Symbol: "MSTR", CIK: "0001050446", Assets: []TreasuryAsset{{Symbol: "BTC", Holdings: 1}}},
+		{Symbol: "3350.T", Identifier: "TSE:3350", Currency: "JPY", Assets: []TreasuryAsset{{Symbol: "BTC", Holdings: 1}}},
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+	if valid.Companies[1].IsSECRegistrant() {
+		t.Error("Expected a TSE-only company not to be an SEC registrant")
+	}
+	if company, ok := valid.GetCompanyByCIK("1050446"); !ok || company.Symbol != "MSTR" {
+		t.Error("Expected CIK lookup to ignore leading zeros")
+	}
+}
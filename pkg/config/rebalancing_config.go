@@ -7,6 +7,11 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // RebalancingRule represents a single row in the dynamic rebalancing table
@@ -18,15 +23,55 @@ type RebalancingRule struct {
 
 // RebalancingConfig represents the complete rebalancing configuration
 type RebalancingConfig struct {
-	Rules   []RebalancingRule `json:"rules"`
-	Version string            `json:"version"`
-	Source  string            `json:"source"`
+	SchemaVersion int                    `json:"schema_version,omitempty"`
+	Rules         []RebalancingRule      `json:"rules"`
+	Version       string                 `json:"version"`
+	Source        string                 `json:"source"`
+	Tolerance     float64                `json:"tolerance,omitempty"`  // Band around the target ratio before trading (default 0.05)
+	Validation    *RebalancingValidation `json:"validation,omitempty"` // Set on tables produced by the optimiser
+}
+
+// RebalancingValidation records the out-of-sample performance of an optimised table on
+// the final walk-forward segment, which it wasn't selected on
+type RebalancingValidation struct {
+	Input              string    `json:"input"`
+	Method             string    `json:"method"`
+	Objective          string    `json:"objective"`
+	Candidate          string    `json:"candidate"`
+	Folds              int       `json:"folds"`
+	TrainScore         float64   `json:"train_score"`
+	TestScore          float64   `json:"test_score"`
+	TestStart          time.Time `json:"test_start"`
+	TestEnd            time.Time `json:"test_end"`
+	TestCAGRPct        float64   `json:"test_cagr_percent"`
+	TestMaxDrawdownPct float64   `json:"test_max_drawdown_percent"`
+	TestTurnover       float64   `json:"test_turnover"`
+	HODLBitcoinCAGRPct float64   `json:"hodl_bitcoin_cagr_percent"`
+	HODLMSTRCAGRPct    float64   `json:"hodl_mstr_cagr_percent"`
+	GeneratedAt        time.Time `json:"generated_at"`
+}
+
+// Default locations of the rebalancing table
+const (
+	RebalancingCSVPath  = "configs/rebalancing/rebalancing_table.csv"
+	RebalancingJSONPath = "configs/rebalancing/rebalancing_table.json"
+)
+
+// DefaultRebalancingTolerance is the band used when a table sets no tolerance
+const DefaultRebalancingTolerance = 0.05
+
+// GetTolerance returns the table's tolerance band, or the default
+func (config *RebalancingConfig) GetTolerance() float64 {
+	if config.Tolerance > 0 {
+		return config.Tolerance
+	}
+	return DefaultRebalancingTolerance
 }
 
 // LoadRebalancingConfig loads the rebalancing configuration from CSV or JSON
 func LoadRebalancingConfig() (*RebalancingConfig, error) {
-	csvPath := "configs/rebalancing/rebalancing_table.csv"
-	jsonPath := "configs/rebalancing/rebalancing_table.json"
+	csvPath := RebalancingCSVPath
+	jsonPath := RebalancingJSONPath
 
 	// Check if JSON exists and is newer than CSV
 	csvInfo, csvErr := os.Stat(csvPath)
@@ -56,6 +101,84 @@ func LoadRebalancingConfig() (*RebalancingConfig, error) {
 	return nil, fmt.Errorf("no rebalancing configuration found at %s or %s", csvPath, jsonPath)
 }
 
+// LoadRebalancingConfigFile loads a rebalancing table from a specific CSV or JSON file
+func LoadRebalancingConfigFile(filePath string) (*RebalancingConfig, error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		return loadFromJSON(filePath)
+	}
+	return loadFromCSV(filePath)
+}
+
+// SaveRebalancingConfigFile writes a rebalancing table to a JSON file
+func SaveRebalancingConfigFile(config *RebalancingConfig, filePath string) error {
+	return saveToJSON(config, filePath)
+}
+
+// writeRebalancingCSV backs up the active CSV and rewrites it from the config's rules
+func writeRebalancingCSV(config *RebalancingConfig) error {
+	if previous, err := os.ReadFile(RebalancingCSVPath); err == nil {
+		if err := storage.WriteFileAtomic(RebalancingCSVPath+".bak", previous, 0644); err != nil {
+			return fmt.Errorf("failed to back up CSV: %w", err)
+		}
+	}
+
+	var buf strings.Builder
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"Min", "Max", "Ratio (X:1)"})
+	for _, rule := range config.Rules {
+		writer.Write([]string{
+			strconv.FormatFloat(rule.MinThreshold, 'f', -1, 64),
+			strconv.FormatFloat(rule.MaxThreshold, 'f', -1, 64),
+			strconv.FormatFloat(rule.TargetRatio, 'f', -1, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	if err := storage.WriteFileAtomic(RebalancingCSVPath, []byte(buf.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// ImportRebalancingConfig makes config the active table. The CSV is rewritten from its
+// rules and the JSON cache, which also keeps the tolerance and validation, is written after
+// it so it loads first. The previous CSV is kept as a .bak file.
+func ImportRebalancingConfig(config *RebalancingConfig) error {
+	if err := config.ValidateConfig(); err != nil {
+		return fmt.Errorf("invalid rebalancing configuration: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(RebalancingCSVPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The CSV lock is held across the CSV, the JSON cache and the timestamps, so concurrent
+	// imports can't leave one table's rules with another's cache. saveToJSON takes the JSON
+	// lock inside it; nothing takes the two in the other order.
+	return storage.WithLock(RebalancingCSVPath+".lock", func() error {
+		if err := writeRebalancingCSV(config); err != nil {
+			return err
+		}
+
+		imported := *config
+		if absPath, err := filepath.Abs(RebalancingCSVPath); err == nil {
+			imported.Source = absPath
+		}
+		if err := saveToJSON(&imported, RebalancingJSONPath); err != nil {
+			return err
+		}
+
+		// Make sure the cache is strictly newer than the CSV on coarse-grained filesystems
+		now := time.Now()
+		if err := os.Chtimes(RebalancingCSVPath, now.Add(-time.Second), now.Add(-time.Second)); err != nil {
+			return fmt.Errorf("failed to date the CSV before its JSON cache: %w", err)
+		}
+		return nil
+	})
+}
+
 // loadFromCSV loads the rebalancing rules from a CSV file
 func loadFromCSV(filePath string) (*RebalancingConfig, error) {
 	file, err := os.Open(filePath)
@@ -144,6 +267,11 @@ func loadFromJSON(filePath string) (*RebalancingConfig, error) {
 		return nil, fmt.Errorf("failed to read JSON file: %w", err)
 	}
 
+	data, err = schema.Upgrade(schema.RebalancingConfig, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade JSON file: %w", err)
+	}
+
 	var config RebalancingConfig
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
@@ -160,16 +288,19 @@ func saveToJSON(config *RebalancingConfig, filePath string) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
+	config.SchemaVersion = schema.CurrentVersion(schema.RebalancingConfig)
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write JSON file: %w", err)
-	}
-
-	return nil
+	// Hold the lock migrate takes for the file while writing it
+	return storage.WithLock(filePath+".lock", func() error {
+		if err := storage.WriteFileAtomic(filePath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write JSON file: %w", err)
+		}
+		return nil
+	})
 }
 
 // ValidateConfig validates the rebalancing configuration
@@ -191,6 +322,10 @@ func (config *RebalancingConfig) ValidateConfig() error {
 		}
 	}
 
+	if config.Tolerance < 0 || config.Tolerance >= 1 {
+		return fmt.Errorf("tolerance (%.4f) must be between 0 and 1", config.Tolerance)
+	}
+
 	// Note: Overlapping ranges are intentional for threshold-based rebalancing
 	// They allow for smooth transitions between ratios
 
@@ -201,13 +336,14 @@ func (config *RebalancingConfig) ValidateConfig() error {
 func (config *RebalancingConfig) GetSummary() string {
 	summary := fmt.Sprintf("Rebalancing Configuration (Version %s)\n", config.Version)
 	summary += fmt.Sprintf("Source: %s\n", config.Source)
-	summary += fmt.Sprintf("Rules: %d\n\n", len(config.Rules))
+	summary += fmt.Sprintf("Rules: %d\n", len(config.Rules))
+	summary += fmt.Sprintf("Tolerance: ±%.1f%%\n\n", config.GetTolerance()*100)
 
 	summary += "Min    | Max    | Ratio\n"
 	summary += "-------|--------|----- \n"
 
 	for _, rule := range config.Rules {
-		summary += fmt.Sprintf("%.4f | %.4f | %.0f:1\n",
+		summary += fmt.Sprintf("%.4f | %.4f | %g:1\n",
 			rule.MinThreshold, rule.MaxThreshold, rule.TargetRatio)
 	}
 
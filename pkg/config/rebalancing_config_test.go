@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestImportRebalancingConfigKeepsToleranceAndValidation(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if err := os.MkdirAll("configs/rebalancing", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(RebalancingCSVPath, []byte("Min,Max,Ratio (X:1)\n0,2,3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := &RebalancingConfig{
+		Rules: []RebalancingRule{
+			{MinThreshold: 0, MaxThreshold: 1.75, TargetRatio: 3.5},
+			{MinThreshold: 1.5, MaxThreshold: 10, TargetRatio: 0.75},
+		},
+		Version:    "1.0",
+		Tolerance:  0.08,
+		Validation: &RebalancingValidation{Candidate: "thresholds ×0.90", Folds: 4, TestCAGRPct: 42},
+	}
+	if err := ImportRebalancingConfig(imported); err != nil {
+		t.Fatal(err)
+	}
+
+	if backup, err := os.ReadFile(RebalancingCSVPath + ".bak"); err != nil || string(backup) != "Min,Max,Ratio (X:1)\n0,2,3\n" {
+		t.Errorf("Expected the previous CSV as a backup, got %q (%v)", backup, err)
+	}
+	fromCSV, err := loadFromCSV(RebalancingCSVPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fromCSV.Rules) != 2 || fromCSV.Rules[0].MaxThreshold != 1.75 || fromCSV.Rules[1].TargetRatio != 0.75 {
+		t.Errorf("Expected the imported rules in the CSV, got %+v", fromCSV.Rules)
+	}
+
+	loaded, err := LoadRebalancingConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GetTolerance() != 0.08 || loaded.Validation == nil || loaded.Validation.TestCAGRPct != 42 {
+		t.Errorf("Expected the JSON cache to keep tolerance and validation, got %+v", loaded)
+	}
+	if (&RebalancingConfig{}).GetTolerance() != DefaultRebalancingTolerance {
+		t.Error("Expected the default tolerance when none is set")
+	}
+}
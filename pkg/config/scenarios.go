@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// ScenariosPath is the default location of the named scenario sets
+const ScenariosPath = "configs/portfolio/scenarios.json"
+
+// AllTreasuries is the mnav and mnav_change key that applies to every treasury company
+const AllTreasuries = "*"
+
+// Scenario is a set of market shocks. Changes are fractions, e.g. -0.4 for a 40% fall.
+type Scenario struct {
+	Name         string             `json:"name"`
+	BTCChange    float64            `json:"btc_change"`
+	GoldChange   float64            `json:"gold_change,omitempty"`
+	MNAV         map[string]float64 `json:"mnav,omitempty"`          // mNAV of a treasury company after the shock, "*" for all
+	MNAVChange   map[string]float64 `json:"mnav_change,omitempty"`   // Change in a treasury company's mNAV, "*" for all
+	SymbolChange map[string]float64 `json:"symbol_change,omitempty"` // Price change of a symbol, overriding the shocks above
+}
+
+// MNAVFor returns a treasury company's mNAV under the scenario, given its current mNAV.
+// A symbol's own entry takes precedence over "*", and an absolute mNAV over a change.
+func (s Scenario) MNAVFor(symbol string, current float64) float64 {
+	symbol = strings.ToUpper(symbol)
+	for _, key := range []string{symbol, AllTreasuries} {
+		if mnav, ok := s.MNAV[key]; ok {
+			return mnav
+		}
+		if change, ok := s.MNAVChange[key]; ok {
+			return current * (1 + change)
+		}
+	}
+	return current
+}
+
+// MNAVMultiple returns the factor by which a treasury company's mNAV moves under the scenario.
+// Without a current mNAV (0) an absolute mNAV can't be applied, so only changes count.
+func (s Scenario) MNAVMultiple(symbol string, current float64) float64 {
+	if current > 0 {
+		return s.MNAVFor(symbol, current) / current
+	}
+	symbol = strings.ToUpper(symbol)
+	for _, key := range []string{symbol, AllTreasuries} {
+		if _, ok := s.MNAV[key]; ok {
+			return 1
+		}
+		if change, ok := s.MNAVChange[key]; ok {
+			return 1 + change
+		}
+	}
+	return 1
+}
+
+// upperKeys returns a copy of m keyed by upper-case symbol
+func upperKeys(m map[string]float64) map[string]float64 {
+	if m == nil {
+		return nil
+	}
+	upper := make(map[string]float64, len(m))
+	for key, value := range m {
+		upper[strings.ToUpper(strings.TrimSpace(key))] = value
+	}
+	return upper
+}
+
+// Validate checks that no price or mNAV falls to zero or below
+func (s Scenario) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("scenario without a name")
+	}
+	if s.BTCChange <= -1 || s.GoldChange <= -1 {
+		return fmt.Errorf("%s: price changes must be above -1", s.Name)
+	}
+	for symbol, mnav := range s.MNAV {
+		if mnav <= 0 {
+			return fmt.Errorf("%s: mnav of %s must be positive", s.Name, symbol)
+		}
+	}
+	for symbol, change := range s.MNAVChange {
+		if change <= -1 {
+			return fmt.Errorf("%s: mnav_change of %s must be above -1", s.Name, symbol)
+		}
+	}
+	for symbol, change := range s.SymbolChange {
+		if change <= -1 {
+			return fmt.Errorf("%s: symbol_change of %s must be above -1", s.Name, symbol)
+		}
+	}
+	return nil
+}
+
+// ScenarioSets groups scenarios into named sets, e.g. "stress" or "bull"
+type ScenarioSets struct {
+	SchemaVersion int                   `json:"schema_version,omitempty"`
+	Sets          map[string][]Scenario `json:"sets"`
+}
+
+// DefaultScenarioSets returns a stress set of Bitcoin drawdowns with mNAV compression and a
+// recovery set
+func DefaultScenarioSets() *ScenarioSets {
+	return &ScenarioSets{Sets: map[string][]Scenario{
+		"stress": {
+			{Name: "BTC -20%, mNAV -25%", BTCChange: -0.20, MNAVChange: map[string]float64{AllTreasuries: -0.25}},
+			{Name: "BTC -40%, mNAV 1.0", BTCChange: -0.40, MNAV: map[string]float64{AllTreasuries: 1.0}},
+			{Name: "BTC -60%, mNAV 0.8, gold +10%", BTCChange: -0.60, GoldChange: 0.10, MNAV: map[string]float64{AllTreasuries: 0.8}},
+		},
+		"recovery": {
+			{Name: "BTC +50%, mNAV +30%", BTCChange: 0.50, MNAVChange: map[string]float64{AllTreasuries: 0.30}},
+			{Name: "BTC +100%, mNAV 2.5", BTCChange: 1.00, MNAV: map[string]float64{AllTreasuries: 2.5}},
+		},
+	}}
+}
+
+// Names returns the set names in order
+func (c *ScenarioSets) Names() []string {
+	names := make([]string, 0, len(c.Sets))
+	for name := range c.Sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks every scenario
+func (c *ScenarioSets) Validate() error {
+	for name, scenarios := range c.Sets {
+		for _, scenario := range scenarios {
+			if err := scenario.Validate(); err != nil {
+				return fmt.Errorf("set %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadScenarioSets loads the scenario sets at path. A missing file gives the default sets.
+func LoadScenarioSets(path string) (*ScenarioSets, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultScenarioSets(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenarios: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.PortfolioScenarios, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade scenarios: %w", err)
+	}
+	c := &ScenarioSets{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse scenarios: %w", err)
+	}
+	for _, scenarios := range c.Sets {
+		for i := range scenarios {
+			scenarios[i].MNAV = upperKeys(scenarios[i].MNAV)
+			scenarios[i].MNAVChange = upperKeys(scenarios[i].MNAVChange)
+			scenarios[i].SymbolChange = upperKeys(scenarios[i].SymbolChange)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenarios %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// SaveScenarioSets writes the scenario sets to path
+func SaveScenarioSets(c *ScenarioSets, path string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	c.SchemaVersion = schema.CurrentVersion(schema.PortfolioScenarios)
+	return storage.WithLock(path+".lock", func() error {
+		return storage.WriteJSONAtomic(path, c)
+	})
+}
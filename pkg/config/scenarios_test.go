@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScenarioMNAV(t *testing.T) {
+	s := Scenario{
+		Name:       "mixed",
+		MNAV:       map[string]float64{AllTreasuries: 1},
+		MNAVChange: map[string]float64{"MSTR": -0.5},
+	}
+	// A symbol's own change beats the absolute mNAV for every treasury company
+	if mnav := s.MNAVFor("mstr", 3); mnav != 1.5 {
+		t.Errorf("expected MSTR at 1.5, got %v", mnav)
+	}
+	if mnav := s.MNAVFor("MTPLF", 4); mnav != 1 {
+		t.Errorf("expected MTPLF at 1, got %v", mnav)
+	}
+	if multiple := s.MNAVMultiple("MTPLF", 4); multiple != 0.25 {
+		t.Errorf("expected MTPLF's mNAV to fall to a quarter, got %v", multiple)
+	}
+	// Without a current mNAV only changes apply
+	if multiple := s.MNAVMultiple("MTPLF", 0); multiple != 1 {
+		t.Errorf("expected no change without a current mNAV, got %v", multiple)
+	}
+	if multiple := s.MNAVMultiple("MSTR", 0); multiple != 0.5 {
+		t.Errorf("expected MSTR's change without a current mNAV, got %v", multiple)
+	}
+}
+
+func TestLoadScenarioSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenarios.json")
+	sets, err := LoadScenarioSets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := sets.Names(); len(names) != 2 || names[0] != "recovery" || names[1] != "stress" {
+		t.Errorf("expected the built-in sets without a file, got %v", names)
+	}
+
+	data := `{"sets": {"crash": [{"name": "crash", "btc_change": -0.5, "mnav": {"mstr": 0.9}, "symbol_change": {"mara": -0.7}}]}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sets, err = LoadScenarioSets(path); err != nil {
+		t.Fatal(err)
+	}
+	crash := sets.Sets["crash"]
+	if len(crash) != 1 || crash[0].MNAV["MSTR"] != 0.9 || crash[0].SymbolChange["MARA"] != -0.7 {
+		t.Errorf("expected upper-case symbols, got %+v", crash)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"sets": {"bad": [{"name": "wipeout", "btc_change": -1}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenarioSets(path); err == nil {
+		t.Error("expected a -100% price change to be rejected")
+	}
+}
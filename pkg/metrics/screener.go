@@ -0,0 +1,507 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+// ScreenerPoint is one day of a company's mNAV history. Prices and NAV are in the
+// row's ValueCurrency (USD unless converted with ScreenerRow.ConvertTo).
+type ScreenerPoint struct {
+	Date             time.Time          `json:"date"`
+	StockPrice       float64            `json:"stockPrice"`
+	Shares           float64            `json:"sharesOutstanding"`
+	Holdings         map[string]float64 `json:"holdings"`    // Units held per treasury asset
+	AssetPrices      map[string]float64 `json:"assetPrices"` // Unit price per treasury asset
+	NAV              float64            `json:"nav"`         // Value of all treasury assets
+	MNAV             float64            `json:"mnav"`
+	HoldingsPerShare float64            `json:"holdingsPerShare"` // Primary asset units per share
+}
+
+// ScreenerRow holds the screening metrics for one company
+type ScreenerRow struct {
+	Symbol                 string             `json:"symbol"`
+	Name                   string             `json:"name"`
+	Exchange               string             `json:"exchange,omitempty"`
+	Currency               string             `json:"currency"`      // Trading currency of the listing
+	ValueCurrency          string             `json:"valueCurrency"` // Currency of prices, market cap, NAV and EV
+	PrimaryAsset           string             `json:"primaryAsset"`
+	AsOf                   time.Time          `json:"asOf"`
+	StockPrice             float64            `json:"stockPrice"`
+	MarketCap              float64            `json:"marketCap"`
+	Holdings               map[string]float64 `json:"holdings"`
+	SharesOutstanding      float64            `json:"sharesOutstanding"`
+	NAV                    float64            `json:"nav"`
+	HoldingsPerShare       float64            `json:"holdingsPerShare"`
+	MNAV                   float64            `json:"mnav"`
+	Premium                float64            `json:"premiumPercent"`
+	EnterpriseValue        float64            `json:"enterpriseValue"`
+	EVMNAV                 float64            `json:"evMnav"` // (Market cap + debt + preferred - cash) / NAV
+	MNAVLow                float64            `json:"mnavLow"`
+	MNAVMedian             float64            `json:"mnavMedian"`
+	MNAVHigh               float64            `json:"mnavHigh"`
+	PremiumPercentile      float64            `json:"premiumPercentile"` // Share of days in the window with mNAV at or below today's
+	HoldingsPerShareGrowth float64            `json:"holdingsPerShareGrowthPercent"`
+	WindowStart            time.Time          `json:"windowStart"`
+
+	History []ScreenerPoint `json:"history,omitempty"`
+}
+
+// ScreenerSortKeys lists the columns rows can be sorted by
+var ScreenerSortKeys = []string{
+	"symbol", "mnav", "ev_mnav", "premium_percentile", "holdings_per_share_growth",
+	"market_cap", "nav", "holdings_per_share",
+}
+
+// BuildScreenerHistory combines daily stock closes with each treasury asset's unit
+// price and the holdings and shares in effect on each day. Bitcoin holdings follow the
+// company's transactions; other assets, and companies without filings, use the
+// registry snapshot. assetPrices is keyed by the asset's price series symbol. Stock
+// closes must already be in USD; see ConvertPrices for other listings.
+func BuildScreenerHistory(stock []repository.PricePoint, assetPrices map[string][]repository.PricePoint,
+	txs []models.BitcoinTransaction, shares []models.SharesOutstandingRecord, company config.CompanyData) []ScreenerPoint {
+
+	primary, _ := company.PrimaryAsset()
+	btcSteps := holdingsSteps(txs)
+	shareSteps := sharesSteps(shares)
+	cursors := make([]priceCursor, len(company.Assets))
+	for i, a := range company.Assets {
+		cursors[i] = priceCursor{prices: assetPrices[a.PriceSymbol()]}
+	}
+
+	var history []ScreenerPoint
+	for _, bar := range stock {
+		point := ScreenerPoint{
+			Date:        bar.Date,
+			StockPrice:  bar.Close,
+			Shares:      stepAt(shareSteps, bar.Date, company.OutstandingShares, true),
+			Holdings:    make(map[string]float64, len(company.Assets)),
+			AssetPrices: make(map[string]float64, len(company.Assets)),
+		}
+
+		priced := true
+		for i, a := range company.Assets {
+			holdings := a.Holdings
+			if strings.EqualFold(a.Symbol, "BTC") {
+				holdings = stepAt(btcSteps, bar.Date, a.Holdings, false)
+			}
+			if holdings <= 0 {
+				continue
+			}
+			price, ok := cursors[i].at(bar.Date)
+			if !ok {
+				priced = false
+				break
+			}
+			point.Holdings[a.Symbol] = holdings
+			point.AssetPrices[a.Symbol] = price
+			point.NAV += holdings * price
+		}
+		if !priced || point.NAV <= 0 || point.Shares <= 0 {
+			continue
+		}
+		point.MNAV = point.StockPrice * point.Shares / point.NAV
+		point.HoldingsPerShare = point.Holdings[primary.Symbol] / point.Shares
+		history = append(history, point)
+	}
+	return history
+}
+
+// priceCursor walks a date-sorted price series forward
+type priceCursor struct {
+	prices []repository.PricePoint
+	i      int
+}
+
+// at returns the latest close on or before date. Dates must be queried in order.
+func (c *priceCursor) at(date time.Time) (float64, bool) {
+	for c.i+1 < len(c.prices) && !c.prices[c.i+1].Date.After(date) {
+		c.i++
+	}
+	if len(c.prices) == 0 || c.prices[c.i].Date.After(date) {
+		return 0, false
+	}
+	return c.prices[c.i].Close, true
+}
+
+// ConvertPrices converts daily closes between currencies at each day's rate. Days
+// without a rate are dropped and counted in skipped.
+func ConvertPrices(prices []repository.PricePoint, from, to string, fx *models.FXTable) (converted []repository.PricePoint, skipped int) {
+	if models.CurrencyCode(from) == models.CurrencyCode(to) {
+		return prices, 0
+	}
+	converted = make([]repository.PricePoint, 0, len(prices))
+	for _, p := range prices {
+		rate, err := fx.Convert(1, from, to, p.Date)
+		if err != nil {
+			skipped++
+			continue
+		}
+		p.Open *= rate
+		p.High *= rate
+		p.Low *= rate
+		p.Close *= rate
+		converted = append(converted, p)
+	}
+	return converted, skipped
+}
+
+// step is a value that takes effect on a date
+type step struct {
+	date  time.Time
+	value float64
+}
+
+// holdingsSteps returns total holdings after each transaction, using the reported
+// total when a filing gives one
+func holdingsSteps(txs []models.BitcoinTransaction) []step {
+	sorted := append([]models.BitcoinTransaction(nil), txs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	var steps []step
+	total := 0.0
+	for _, tx := range sorted {
+		total += tx.BTCPurchased
+		if tx.TotalBTCAfter > 0 {
+			total = tx.TotalBTCAfter
+		}
+		steps = append(steps, step{tx.Date, total})
+	}
+	return steps
+}
+
+// sharesSteps returns shares outstanding from each filing
+func sharesSteps(records []models.SharesOutstandingRecord) []step {
+	var steps []step
+	for _, r := range records {
+		shares := r.TotalShares
+		if shares <= 0 {
+			shares = r.CommonShares
+		}
+		if shares > 0 {
+			steps = append(steps, step{r.Date, shares})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].date.Before(steps[j].date) })
+	return steps
+}
+
+// stepAt returns the latest value on or before date, or fallback when there are no
+// steps. Before the first step it returns the first value when backfill is set, else 0.
+func stepAt(steps []step, date time.Time, fallback float64, backfill bool) float64 {
+	if len(steps) == 0 {
+		return fallback
+	}
+	var value float64
+	if backfill {
+		value = steps[0].value
+	}
+	for _, s := range steps {
+		if s.date.After(date) {
+			break
+		}
+		value = s.value
+	}
+	return value
+}
+
+// HoldingsAt returns a company's Bitcoin holdings and shares outstanding on date. Holdings
+// follow the company's transactions and shares its filings; without them the registry
+// snapshot is used. Companies missing from the registry are taken to hold Bitcoin.
+func HoldingsAt(company config.CompanyData, txs []models.BitcoinTransaction, shares []models.SharesOutstandingRecord, date time.Time) (holdings, sharesOutstanding float64) {
+	primary, ok := company.PrimaryAsset()
+	if ok && !strings.EqualFold(primary.Symbol, "BTC") {
+		return 0, stepAt(sharesSteps(shares), date, company.OutstandingShares, true)
+	}
+	holdings = stepAt(holdingsSteps(txs), date, primary.Holdings, false)
+	sharesOutstanding = stepAt(sharesSteps(shares), date, company.OutstandingShares, true)
+	return holdings, sharesOutstanding
+}
+
+// Screen computes a company's screening metrics from its USD history, using the days
+// since windowStart for the mNAV range, premium percentile and holdings/share growth.
+// With no history the registry snapshot and the latest unit prices (keyed by price
+// series symbol) give the current values only. Registry market cap, debt, preferred
+// equity and cash are in the trading currency and converted to USD with fx at the
+// as-of date.
+func Screen(company config.CompanyData, history []ScreenerPoint, latestPrices map[string]float64, windowStart time.Time, fx *models.FXTable) (*ScreenerRow, error) {
+	primary, ok := company.PrimaryAsset()
+	if !ok {
+		return nil, fmt.Errorf("%s: no treasury assets in the registry", company.Symbol)
+	}
+
+	row := &ScreenerRow{
+		Symbol:        company.Symbol,
+		Name:          company.Name,
+		Exchange:      company.Exchange,
+		Currency:      company.TradingCurrency(),
+		ValueCurrency: models.USD,
+		PrimaryAsset:  primary.Symbol,
+		WindowStart:   windowStart,
+	}
+
+	// toUSD converts a registry amount at the as-of date
+	toUSD := func(amount float64) (float64, error) {
+		if amount == 0 {
+			return 0, nil
+		}
+		date := row.AsOf
+		if date.IsZero() {
+			date = time.Now()
+		}
+		usd, err := fx.Convert(amount, row.Currency, models.USD, date)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", company.Symbol, err)
+		}
+		return usd, nil
+	}
+
+	if len(history) > 0 {
+		last := history[len(history)-1]
+		row.AsOf = last.Date
+		row.StockPrice = last.StockPrice
+		row.Holdings = last.Holdings
+		row.SharesOutstanding = last.Shares
+		row.MarketCap = last.StockPrice * last.Shares
+		row.NAV = last.NAV
+	} else {
+		row.AsOf = company.LastUpdated
+		row.Holdings = make(map[string]float64)
+		row.SharesOutstanding = company.OutstandingShares
+		marketCap, err := toUSD(company.MarketCap)
+		if err != nil {
+			return nil, err
+		}
+		row.MarketCap = marketCap
+		if row.SharesOutstanding > 0 {
+			row.StockPrice = row.MarketCap / row.SharesOutstanding
+		}
+		for _, a := range company.Assets {
+			if a.Holdings > 0 && latestPrices[a.PriceSymbol()] > 0 {
+				row.Holdings[a.Symbol] = a.Holdings
+				row.NAV += a.Holdings * latestPrices[a.PriceSymbol()]
+			}
+		}
+	}
+
+	if row.NAV <= 0 {
+		return nil, fmt.Errorf("%s: no treasury holdings or prices to value", company.Symbol)
+	}
+	row.MNAV = row.MarketCap / row.NAV
+	row.Premium = (row.MNAV - 1) * 100
+	if row.SharesOutstanding > 0 {
+		row.HoldingsPerShare = row.Holdings[primary.Symbol] / row.SharesOutstanding
+	}
+	claims, err := toUSD(company.TotalDebt + company.PreferredEquity - company.Cash)
+	if err != nil {
+		return nil, err
+	}
+	row.EnterpriseValue = row.MarketCap + claims
+	row.EVMNAV = row.EnterpriseValue / row.NAV
+
+	var window []ScreenerPoint
+	for _, p := range history {
+		if !p.Date.Before(windowStart) {
+			window = append(window, p)
+		}
+	}
+	if len(window) == 0 {
+		row.MNAVLow, row.MNAVMedian, row.MNAVHigh = row.MNAV, row.MNAV, row.MNAV
+		return row, nil
+	}
+
+	mnavs := make([]float64, len(window))
+	atOrBelow := 0
+	for i, p := range window {
+		mnavs[i] = p.MNAV
+		if p.MNAV <= row.MNAV {
+			atOrBelow++
+		}
+	}
+	sort.Float64s(mnavs)
+	row.MNAVLow = mnavs[0]
+	row.MNAVHigh = mnavs[len(mnavs)-1]
+	row.MNAVMedian = median(mnavs)
+	row.PremiumPercentile = float64(atOrBelow) / float64(len(window)) * 100
+
+	if first := window[0].HoldingsPerShare; first > 0 && row.HoldingsPerShare > 0 {
+		row.HoldingsPerShareGrowth = (row.HoldingsPerShare/first - 1) * 100
+	}
+	return row, nil
+}
+
+// ConvertTo restates the row's prices, market cap, NAV and enterprise value, and its
+// history, in currency at each date's rate. Ratios are unchanged.
+func (r *ScreenerRow) ConvertTo(currency string, fx *models.FXTable) error {
+	currency = models.CurrencyCode(currency)
+	if currency == r.ValueCurrency {
+		return nil
+	}
+
+	rate, err := fx.Convert(1, r.ValueCurrency, currency, r.AsOf)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.Symbol, err)
+	}
+	r.StockPrice *= rate
+	r.MarketCap *= rate
+	r.NAV *= rate
+	r.EnterpriseValue *= rate
+
+	for i := range r.History {
+		p := &r.History[i]
+		rate, err := fx.Convert(1, r.ValueCurrency, currency, p.Date)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Symbol, err)
+		}
+		p.StockPrice *= rate
+		p.NAV *= rate
+		for asset := range p.AssetPrices {
+			p.AssetPrices[asset] *= rate
+		}
+	}
+	r.ValueCurrency = currency
+	return nil
+}
+
+// median returns the median of sorted values
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// SortScreenerRows sorts rows by one of ScreenerSortKeys
+func SortScreenerRows(rows []*ScreenerRow, key string, descending bool) error {
+	value := map[string]func(r *ScreenerRow) float64{
+		"mnav":                      func(r *ScreenerRow) float64 { return r.MNAV },
+		"ev_mnav":                   func(r *ScreenerRow) float64 { return r.EVMNAV },
+		"premium_percentile":        func(r *ScreenerRow) float64 { return r.PremiumPercentile },
+		"holdings_per_share_growth": func(r *ScreenerRow) float64 { return r.HoldingsPerShareGrowth },
+		"market_cap":                func(r *ScreenerRow) float64 { return r.MarketCap },
+		"nav":                       func(r *ScreenerRow) float64 { return r.NAV },
+		"holdings_per_share":        func(r *ScreenerRow) float64 { return r.HoldingsPerShare },
+	}
+
+	var less func(i, j int) bool
+	if key == "symbol" {
+		less = func(i, j int) bool { return rows[i].Symbol < rows[j].Symbol }
+	} else if f, ok := value[key]; ok {
+		less = func(i, j int) bool { return f(rows[i]) < f(rows[j]) }
+	} else {
+		return fmt.Errorf("unknown sort key %q (expected one of %s)", key, strings.Join(ScreenerSortKeys, ", "))
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if descending {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+	return nil
+}
+
+// IndexSeries rebases values to 100 at the first valid value, leaving NaN where a
+// value is missing, so companies of different sizes can share one chart
+func IndexSeries(values []float64) []float64 {
+	indexed := make([]float64, len(values))
+	base := math.NaN()
+	for i, v := range values {
+		if math.IsNaN(base) && v > 0 {
+			base = v
+		}
+		if v > 0 && !math.IsNaN(base) {
+			indexed[i] = v / base * 100
+		} else {
+			indexed[i] = math.NaN()
+		}
+	}
+	return indexed
+}
+
+// ScreenCompanies screens every company in the registry using the store's price,
+// transaction and shares history since start. Stock prices of non-USD listings are
+// converted with fx at each day's rate. The window covers the last windowDays days up
+// to the latest treasury asset price. Companies that cannot be valued are reported in
+// the returned error list and skipped.
+func ScreenCompanies(store repository.Store, companies []config.CompanyData, start time.Time, windowDays int, fx *models.FXTable) ([]*ScreenerRow, []error) {
+	var errs []error
+
+	// Load each unit price series once, even when several companies hold the asset
+	assetPrices := make(map[string][]repository.PricePoint)
+	latestPrices := make(map[string]float64)
+	var latest time.Time
+	for _, company := range companies {
+		for _, a := range company.Assets {
+			symbol := a.PriceSymbol()
+			if _, loaded := assetPrices[symbol]; loaded {
+				continue
+			}
+			prices, err := store.Prices().GetPrices(symbol, start, time.Time{})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to load %s prices: %w", symbol, err))
+			}
+			assetPrices[symbol] = prices
+			if len(prices) > 0 {
+				last := prices[len(prices)-1]
+				latestPrices[symbol] = last.Close
+				if last.Date.After(latest) {
+					latest = last.Date
+				}
+			}
+		}
+	}
+	if latest.IsZero() {
+		latest = time.Now()
+	}
+	windowStart := latest.AddDate(0, 0, -windowDays)
+
+	var rows []*ScreenerRow
+	for _, company := range companies {
+		stock, err := store.Prices().GetPrices(company.Symbol, start, time.Time{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to load prices: %w", company.Symbol, err))
+			continue
+		}
+		if currency := company.TradingCurrency(); currency != models.USD && len(stock) > 0 {
+			converted, skipped := ConvertPrices(stock, currency, models.USD, fx)
+			if len(converted) == 0 {
+				_, err := fx.RateAt(currency, stock[len(stock)-1].Date)
+				errs = append(errs, fmt.Errorf("%s: cannot convert %s prices to USD: %v", company.Symbol, currency, err))
+				continue
+			}
+			if skipped > 0 {
+				errs = append(errs, fmt.Errorf("%s: skipped %d days without a %s/USD rate", company.Symbol, skipped, currency))
+			}
+			stock = converted
+		}
+		txs, err := store.Transactions().LoadBTCTransactions(company.Symbol)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to load transactions: %w", company.Symbol, err))
+			continue
+		}
+		shares, err := store.Shares().LoadSharesHistory(company.Symbol)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to load shares history: %w", company.Symbol, err))
+			continue
+		}
+
+		history := BuildScreenerHistory(stock, assetPrices, txs, shares, company)
+		row, err := Screen(company, history, latestPrices, windowStart, fx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		row.History = history
+		rows = append(rows, row)
+	}
+	return rows, errs
+}
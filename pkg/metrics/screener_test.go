@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBuildScreenerHistory(t *testing.T) {
+	var stock, btc []repository.PricePoint
+	for d := 1; d <= 5; d++ {
+		stock = append(stock, repository.PricePoint{Date: day(d), Close: 100})
+		btc = append(btc, repository.PricePoint{Date: day(d), Close: 50000})
+	}
+	txs := []models.BitcoinTransaction{
+		{Date: day(2), BTCPurchased: 100},
+		{Date: day(4), BTCPurchased: 50, TotalBTCAfter: 200},
+	}
+	shares := []models.SharesOutstandingRecord{{Date: day(3), TotalShares: 1000000}}
+
+	company := config.CompanyData{Symbol: "TEST", Assets: []config.TreasuryAsset{{Symbol: "BTC"}}}
+	history := BuildScreenerHistory(stock, map[string][]repository.PricePoint{"BTC-USD": btc}, txs, shares, company)
+
+	// Day 1 has no holdings yet; shares are backfilled from the first filing
+	if len(history) != 4 || !history[0].Date.Equal(day(2)) {
+		t.Fatalf("expected 4 points from day 2, got %d", len(history))
+	}
+	if history[0].Shares != 1000000 {
+		t.Errorf("shares should be backfilled, got %.0f", history[0].Shares)
+	}
+	if history[1].Holdings["BTC"] != 100 || history[2].Holdings["BTC"] != 200 {
+		t.Errorf("unexpected holdings %.0f, %.0f", history[1].Holdings["BTC"], history[2].Holdings["BTC"])
+	}
+	if want := 100.0 * 1000000 / (200 * 50000); math.Abs(history[3].MNAV-want) > 1e-9 {
+		t.Errorf("mNAV = %f, want %f", history[3].MNAV, want)
+	}
+}
+
+func TestBuildScreenerHistoryMultiAsset(t *testing.T) {
+	stock := []repository.PricePoint{{Date: day(1), Close: 10}, {Date: day(2), Close: 10}, {Date: day(3), Close: 10}}
+	prices := map[string][]repository.PricePoint{
+		"ETH-USD": {{Date: day(1), Close: 2000}, {Date: day(3), Close: 3000}},
+		"SOL-USD": {{Date: day(2), Close: 100}},
+	}
+	company := config.CompanyData{
+		Symbol:            "MULTI",
+		OutstandingShares: 1000,
+		Assets:            []config.TreasuryAsset{{Symbol: "ETH", Holdings: 2}, {Symbol: "SOL", Holdings: 10}},
+	}
+
+	history := BuildScreenerHistory(stock, prices, nil, nil, company)
+
+	// Day 1 has no SOL price, so it cannot be valued
+	if len(history) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(history))
+	}
+	if history[0].NAV != 2*2000+10*100 || history[1].NAV != 2*3000+10*100 {
+		t.Errorf("unexpected NAV %.0f, %.0f", history[0].NAV, history[1].NAV)
+	}
+	if history[1].HoldingsPerShare != 2.0/1000 {
+		t.Errorf("holdings per share should use the primary asset, got %f", history[1].HoldingsPerShare)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	company := config.CompanyData{Symbol: "TEST", Name: "Test Co", TotalDebt: 200, PreferredEquity: 100, Cash: 50,
+		Assets: []config.TreasuryAsset{{Symbol: "BTC"}}}
+	history := []ScreenerPoint{
+		{Date: day(1), StockPrice: 10, Shares: 10, Holdings: map[string]float64{"BTC": 10}, NAV: 100, MNAV: 1.0, HoldingsPerShare: 1.0},
+		{Date: day(2), StockPrice: 30, Shares: 10, Holdings: map[string]float64{"BTC": 10}, NAV: 100, MNAV: 3.0, HoldingsPerShare: 1.0},
+		{Date: day(3), StockPrice: 20, Shares: 10, Holdings: map[string]float64{"BTC": 12}, NAV: 120, MNAV: 2.0 / 1.2, HoldingsPerShare: 1.2},
+	}
+
+	row, err := Screen(company, history, nil, day(1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.MarketCap != 200 || row.NAV != 120 {
+		t.Errorf("market cap %.0f, NAV %.0f", row.MarketCap, row.NAV)
+	}
+	if want := 450.0 / 120; math.Abs(row.EVMNAV-want) > 1e-9 {
+		t.Errorf("EV mNAV = %f, want %f", row.EVMNAV, want)
+	}
+	if math.Abs(row.PremiumPercentile-200.0/3) > 1e-9 {
+		t.Errorf("premium percentile = %f", row.PremiumPercentile)
+	}
+	if math.Abs(row.HoldingsPerShareGrowth-20) > 1e-9 {
+		t.Errorf("holdings/share growth = %f", row.HoldingsPerShareGrowth)
+	}
+	if row.MNAVLow != 1 || row.MNAVHigh != 3 || row.MNAVMedian != 2.0/1.2 {
+		t.Errorf("range %f / %f / %f", row.MNAVLow, row.MNAVMedian, row.MNAVHigh)
+	}
+
+	if _, err := Screen(config.CompanyData{Symbol: "NONE"}, nil, nil, day(1), nil); err == nil {
+		t.Error("expected an error for a company without treasury assets")
+	}
+}
+
+func TestScreenConvertsCurrencies(t *testing.T) {
+	fx := models.NewFXTable(&models.FXRates{Currency: "JPY", Rates: []models.FXRate{
+		{Date: day(1), USDPerUnit: 0.01},
+		{Date: day(3), USDPerUnit: 0.005},
+	}})
+	jpy := config.CompanyData{Symbol: "3350.T", Currency: "JPY", OutstandingShares: 1000, MarketCap: 10000000,
+		LastUpdated: day(2), TotalDebt: 2000000, Assets: []config.TreasuryAsset{{Symbol: "BTC", Holdings: 1}}}
+
+	if _, err := Screen(jpy, nil, map[string]float64{"BTC-USD": 50000}, day(1), nil); err == nil {
+		t.Error("expected an error for a non-USD listing without FX rates")
+	}
+
+	// Registry values are converted at the as-of date's rate, carried forward from day 1
+	row, err := Screen(jpy, nil, map[string]float64{"BTC-USD": 50000}, day(1), fx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.MarketCap != 100000 || row.EnterpriseValue != 120000 || row.MNAV != 2 {
+		t.Errorf("market cap %.0f, EV %.0f, mNAV %f", row.MarketCap, row.EnterpriseValue, row.MNAV)
+	}
+
+	// Daily closes convert at each day's rate
+	stock := []repository.PricePoint{{Date: day(1), Close: 1000}, {Date: day(3), Close: 1000}}
+	converted, skipped := ConvertPrices(stock, "JPY", "USD", fx)
+	if skipped != 0 || converted[0].Close != 10 || converted[1].Close != 5 {
+		t.Errorf("unexpected conversion %+v (skipped %d)", converted, skipped)
+	}
+	if _, skipped := ConvertPrices([]repository.PricePoint{{Date: day(1).AddDate(0, 0, -1), Close: 1}}, "JPY", "USD", fx); skipped != 1 {
+		t.Error("expected days before the first rate to be skipped")
+	}
+
+	// Output currency restates money but not ratios
+	row.History = []ScreenerPoint{{Date: day(3), StockPrice: 5, NAV: 50000, AssetPrices: map[string]float64{"BTC": 50000}}}
+	if err := row.ConvertTo("JPY", fx); err != nil {
+		t.Fatal(err)
+	}
+	if row.ValueCurrency != "JPY" || row.MarketCap != 10000000 || row.MNAV != 2 || row.History[0].StockPrice != 1000 {
+		t.Errorf("unexpected converted row %+v", row)
+	}
+}
+
+func TestSortScreenerRows(t *testing.T) {
+	rows := []*ScreenerRow{{Symbol: "B", MNAV: 1}, {Symbol: "A", MNAV: 3}, {Symbol: "C", MNAV: 2}}
+	if err := SortScreenerRows(rows, "mnav", true); err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Symbol != "A" || rows[2].Symbol != "B" {
+		t.Errorf("unexpected mNAV order %s %s %s", rows[0].Symbol, rows[1].Symbol, rows[2].Symbol)
+	}
+	SortScreenerRows(rows, "symbol", false)
+	if rows[0].Symbol != "A" || rows[2].Symbol != "C" {
+		t.Errorf("unexpected symbol order %s %s %s", rows[0].Symbol, rows[1].Symbol, rows[2].Symbol)
+	}
+	if err := SortScreenerRows(rows, "bogus", false); err == nil {
+		t.Error("expected an error for an unknown sort key")
+	}
+}
+
+func TestIndexSeries(t *testing.T) {
+	indexed := IndexSeries([]float64{math.NaN(), 50, 75, math.NaN(), 100})
+	if !math.IsNaN(indexed[0]) || !math.IsNaN(indexed[3]) {
+		t.Error("missing values should stay NaN")
+	}
+	if indexed[1] != 100 || indexed[2] != 150 || indexed[4] != 200 {
+		t.Errorf("unexpected index %v", indexed)
+	}
+}
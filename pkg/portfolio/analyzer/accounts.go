@@ -0,0 +1,155 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// valueEpsilon is the trade value below which a rebalance is complete
+const valueEpsilon = 0.005
+
+// sellCandidate is a position that can fund a rebalancing trade
+type sellCandidate struct {
+	position models.Position
+	account  string
+	settings config.AccountSettings
+	buy      models.SymbolSummary // What the account buys with the proceeds
+	taxRate  float64              // Estimated tax per unit of value sold
+}
+
+// accountBuy returns the largest holding of a class the account may buy
+func accountBuy(holding ClassHolding, settings config.AccountSettings) (models.SymbolSummary, bool) {
+	for _, summary := range holding.Symbols {
+		if settings.Allows(summary.Symbol) && summary.LastPrice > 0 {
+			return summary, true
+		}
+	}
+	return models.SymbolSummary{}, false
+}
+
+// sellCandidates returns the positions of a class that may be sold, in the order to sell them:
+// tax-advantaged accounts first, largest positions first, then the taxable positions whose sale
+// costs the least tax. Accounts that may not sell are left out, as are those include rejects.
+// With buy set, accounts that may not buy any of its holdings are left out with a warning.
+func (a *Analyzer) sellCandidates(portfolio *models.Portfolio, sellClass string, buy *ClassHolding, amount float64,
+	include func(position models.Position, settings config.AccountSettings) bool) ([]sellCandidate, []string) {
+	classes := a.classification()
+	var candidates []sellCandidate
+	var warnings []string
+	blocked := make(map[string]bool)
+	for _, position := range portfolio.Positions {
+		if classes.Classify(position.Symbol).Class != sellClass || position.CurrentValue <= 0 {
+			continue
+		}
+		candidate := sellCandidate{
+			position: position,
+			account:  positionAccount(position),
+			settings: a.Accounts.Settings(position.AccountNumber, position.AccountName),
+		}
+		if candidate.settings.NoSell || (include != nil && !include(position, candidate.settings)) {
+			continue
+		}
+		if buy != nil {
+			var ok bool
+			if candidate.buy, ok = accountBuy(*buy, candidate.settings); !ok {
+				if !blocked[candidate.account] {
+					blocked[candidate.account] = true
+					warnings = append(warnings, fmt.Sprintf("%s may not buy any %s holding (%s), so it sells nothing",
+						models.MaskAccount(candidate.account), config.AssetClassLabel(buy.Class), strings.Join(candidate.settings.Exclude, ", ")))
+				}
+				continue
+			}
+		}
+		if !config.TaxAdvantaged(candidate.settings.Type) {
+			value := math.Min(amount, position.CurrentValue)
+			trade, _ := a.sellTrade(position, candidate.settings.Type, value, portfolio.Date)
+			candidate.taxRate = trade.EstimatedTax / value
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ai, aj := config.TaxAdvantaged(ci.settings.Type), config.TaxAdvantaged(cj.settings.Type); ai != aj {
+			return ai
+		}
+		if ci.taxRate != cj.taxRate {
+			return ci.taxRate < cj.taxRate
+		}
+		return ci.position.CurrentValue > cj.position.CurrentValue
+	})
+	return candidates, warnings
+}
+
+// placeSells sells amount from the candidates in order, skipping sells below an account's
+// minimum, and returns the sells and the value left unplaced
+func (a *Analyzer) placeSells(portfolio *models.Portfolio, candidates []sellCandidate, amount float64) ([]models.RecommendedTrade, []string, float64) {
+	var sells []models.RecommendedTrade
+	var warnings []string
+	remaining := amount
+	for _, candidate := range candidates {
+		if remaining < valueEpsilon {
+			break
+		}
+		value := math.Min(remaining, candidate.position.CurrentValue)
+		if value < candidate.settings.MinTrade {
+			continue // Too small here; another account may place it
+		}
+		remaining -= value
+
+		sell, sellWarnings := a.sellTrade(candidate.position, candidate.settings.Type, value, portfolio.Date)
+		sells = append(sells, sell)
+		warnings = append(warnings, sellWarnings...)
+	}
+	return sells, warnings, remaining
+}
+
+// planTrades sells amount of a class and buys the other class in each selling account, since
+// proceeds can't move between accounts (see sellCandidates for the order of sells). Trades below
+// an account's minimum are not placed. Each account buys one symbol, so it places at most one buy.
+func (a *Analyzer) planTrades(portfolio *models.Portfolio, sellClass string, buy ClassHolding, amount float64) ([]models.RecommendedTrade, []string) {
+	candidates, warnings := a.sellCandidates(portfolio, sellClass, &buy, amount, nil)
+	sells, sellWarnings, remaining := a.placeSells(portfolio, candidates, amount)
+	warnings = append(warnings, sellWarnings...)
+	if remaining >= valueEpsilon {
+		warnings = append(warnings, fmt.Sprintf("account constraints leave %.2f of the %.2f trade unplaced", remaining, amount))
+	}
+
+	// Sells and buys by account, in the order accounts first sell
+	var accounts []string
+	bySell := make(map[string][]models.RecommendedTrade)
+	buyFor := make(map[string]sellCandidate)
+	for _, candidate := range candidates {
+		if _, ok := buyFor[candidate.account]; !ok {
+			buyFor[candidate.account] = candidate
+		}
+	}
+	for _, sell := range sells {
+		if len(bySell[sell.Account]) == 0 {
+			accounts = append(accounts, sell.Account)
+		}
+		bySell[sell.Account] = append(bySell[sell.Account], sell)
+	}
+
+	var trades []models.RecommendedTrade
+	for _, account := range accounts {
+		candidate := buyFor[account]
+		b := models.RecommendedTrade{
+			Action:      "BUY",
+			Symbol:      candidate.buy.Symbol,
+			Account:     account,
+			AccountType: candidate.settings.Type,
+		}
+		for _, sell := range bySell[account] {
+			b.EstimatedValue += sell.EstimatedValue
+		}
+		b.Shares = shares(b.EstimatedValue, candidate.buy.LastPrice)
+		trades = append(trades, bySell[account]...)
+		trades = append(trades, b)
+	}
+	return trades, warnings
+}
@@ -1,20 +1,42 @@
 package analyzer
 
 import (
-	"encoding/csv"
 	"fmt"
-	"io"
-	"os"
-	"strconv"
-	"strings"
+	"math"
+	"sort"
 	"time"
 
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/importer"
 	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
 )
 
 // Analyzer handles portfolio analysis operations
 type Analyzer struct {
-	// Could add configuration here if needed
+	Currency          string                      // Reporting currency for totals (default USD)
+	FX                *sharedmodels.FXTable       // Rates for positions held in other currencies
+	AccountCurrencies map[string]string           // Account number to currency for accounts not held in USD
+	Prices            repository.PriceRepository  // Prices positions the export has no price for
+	Classes           *config.AssetClassification // Symbol to asset class mapping (default: config.DefaultAssetClassification)
+
+	// Treasury company data for look-through Bitcoin exposure; without it treasury equity
+	// counts value × btc_factor like other positions
+	Companies    *config.CompaniesConfig          // Registry snapshots and balance sheets
+	Transactions repository.TransactionRepository // Bitcoin purchases per company
+	Shares       repository.SharesRepository      // Shares outstanding per company
+	KnownAt      time.Time                        // Only use company facts known then (zero means now)
+
+	// Tax-aware rebalancing
+	Accounts  *config.AccountsConfig // Account tax treatment (default: inferred from account names)
+	Lots      *models.TaxLots        // Open lots; sells without lots are estimated from average cost
+	LotMethod taxlots.Method         // Lots sold by rebalancing trades (default FIFO)
+	TaxRates  taxlots.Rates          // Default taxlots.DefaultRates
+
+	Files *vault.Files // Decrypts sealed CSV exports (nil reads them as they are)
 }
 
 // NewAnalyzer creates a new portfolio analyzer
@@ -22,148 +44,154 @@ func NewAnalyzer() *Analyzer {
 	return &Analyzer{}
 }
 
-// ParseCSV parses a Fidelity portfolio CSV file into a Portfolio struct
+// ParseCSV parses a broker positions CSV file into a Portfolio struct, detecting the broker
+// from the file's contents
 func (a *Analyzer) ParseCSV(filePath string) (*models.Portfolio, error) {
-	file, err := os.Open(filePath)
+	portfolio, _, err := a.ImportCSV(filePath, nil)
+	return portfolio, err
+}
+
+// readExport reads a CSV export, decrypting it when it's sealed
+func (a *Analyzer) readExport(filePath string) (*importer.File, error) {
+	if a.Files == nil {
+		return importer.ReadFile(filePath)
+	}
+	data, err := a.Files.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1 // Allow variable number of fields
+	return importer.ParseCSV(filePath, data)
+}
 
-	// Read header
-	headers, err := reader.Read()
+// ImportCSV parses a positions CSV file with imp, or the detected importer when imp is nil,
+// and returns the importer's report alongside the portfolio. Positions the export has no
+// price for, such as crypto balances, are priced from the price repository.
+func (a *Analyzer) ImportCSV(filePath string, imp importer.Importer) (*models.Portfolio, *importer.Result, error) {
+	f, err := a.readExport(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read headers: %w", err)
+		return nil, nil, err
 	}
-
-	// Create header map for flexible parsing
-	headerMap := make(map[string]int)
-	for i, header := range headers {
-		headerMap[header] = i
+	result, err := importer.ImportFile(f, imp)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var positions []models.Position
-	var rawRecords [][]string
-
-	// Read all records
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read record: %w", err)
-		}
-		rawRecords = append(rawRecords, record)
+	// Use the current date when neither the content nor the filename has one
+	date := result.Date
+	if date.IsZero() {
+		date = time.Now()
+		result.Warnings = append(result.Warnings, "no as-of date in the file or its name; using today")
 	}
 
-	// Parse positions, skipping disclaimer rows
-	for _, record := range rawRecords {
-		// Skip disclaimer rows (they don't have proper data structure)
-		if len(record) < len(headers) ||
-			strings.Contains(record[0], "The data and information") ||
-			strings.Contains(record[0], "Brokerage services") ||
-			strings.Contains(record[0], "Date downloaded") ||
-			len(record) == 1 { // Single column rows are usually disclaimers
-			continue
-		}
-
-		position, err := a.parsePosition(record, headerMap)
-		if err != nil {
-			continue // Skip invalid records
+	var positions []models.Position
+	for _, position := range result.Positions {
+		if position.Currency == "" {
+			position.Currency = a.AccountCurrencies[position.AccountNumber]
 		}
-
-		// Skip cash positions (SPAXX)
-		if strings.Contains(position.Symbol, "SPAXX") {
-			continue
+		position.Currency = sharedmodels.CurrencyCode(position.Currency)
+		if position.CurrentValue == 0 && position.Quantity > 0 {
+			if err := a.priceFromRepository(&position, date); err != nil {
+				result.Warnings = append(result.Warnings, err.Error())
+			}
 		}
 
 		// Only include positions with actual value
 		if position.CurrentValue > 0 {
-			positions = append(positions, *position)
+			positions = append(positions, position)
 		}
 	}
 
-	// Extract date from filename or use current date
-	date := time.Now()
-	if strings.Contains(filePath, "Jun-11-2025") {
-		date, _ = time.Parse("Jan-2-2006", "Jun-11-2025")
-	}
-
 	// Create portfolio
 	portfolio := &models.Portfolio{
 		Date:       date,
 		SourceFile: filePath,
+		Currency:   sharedmodels.CurrencyCode(a.Currency),
 		Positions:  positions,
 		Accounts:   make(map[string]*models.Account),
 		CreatedAt:  time.Now(),
 	}
 
 	// Calculate aggregations
-	a.calculateAggregations(portfolio)
+	if err := a.calculateAggregations(portfolio); err != nil {
+		return nil, nil, err
+	}
 
-	return portfolio, nil
+	return portfolio, result, nil
 }
 
-// parsePosition converts a CSV record to a Position struct
-func (a *Analyzer) parsePosition(record []string, headerMap map[string]int) (*models.Position, error) {
-	position := &models.Position{}
-
-	// Helper function to safely get string values
-	getString := func(header string) string {
-		if idx, exists := headerMap[header]; exists && idx < len(record) {
-			return strings.TrimSpace(record[idx])
-		}
-		return ""
+// priceFromRepository values an unpriced position at the latest close of its USD price
+// series (BTC-USD, ...) on or before date
+func (a *Analyzer) priceFromRepository(position *models.Position, date time.Time) error {
+	series := position.Symbol + "-USD"
+	if a.Prices == nil {
+		return fmt.Errorf("%s has no price in the file and no price repository is configured", position.Symbol)
+	}
+	if position.Currency != "USD" {
+		return fmt.Errorf("%s has no price in the file and its cost basis is in %s, not USD", position.Symbol, position.Currency)
 	}
+	prices, err := a.Prices.GetPrices(series, date.AddDate(0, 0, -7), date)
+	if err != nil || len(prices) == 0 {
+		return fmt.Errorf("%s has no price in the file and no %s close in the week before %s", position.Symbol, series, date.Format("2006-01-02"))
+	}
+	position.LastPrice = prices[len(prices)-1].Close
+	importer.Complete(position)
+	return nil
+}
 
-	// Helper function to safely parse float values
-	getFloat := func(header string) float64 {
-		value := getString(header)
-		if value == "" {
-			return 0
+// ConvertPortfolio returns a copy of the portfolio with every position restated in
+// currency at the portfolio date's rates, and the totals recalculated. Cost basis is
+// converted at the same rate, so gains exclude earlier currency moves.
+func (a *Analyzer) ConvertPortfolio(portfolio *models.Portfolio, currency string) (*models.Portfolio, error) {
+	currency = sharedmodels.CurrencyCode(currency)
+	converted := *portfolio
+	converted.Currency = currency
+	converted.Positions = make([]models.Position, len(portfolio.Positions))
+	converted.Accounts = make(map[string]*models.Account)
+	converted.TotalValue, converted.TotalCostBasis, converted.TotalGainLoss, converted.TotalGainLossPct = 0, 0, 0, 0
+
+	for i, position := range portfolio.Positions {
+		rate, err := a.FX.Convert(1, position.Currency, currency, portfolio.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s in %s: %w", position.Symbol, models.MaskAccount(position.AccountName), err)
 		}
-		// Remove currency symbols and commas
-		value = strings.ReplaceAll(value, "$", "")
-		value = strings.ReplaceAll(value, ",", "")
-		value = strings.ReplaceAll(value, "+", "")
+		position.LastPrice *= rate
+		position.LastPriceChange *= rate
+		position.CurrentValue *= rate
+		position.TodayGainLoss *= rate
+		position.TotalGainLoss *= rate
+		position.CostBasisTotal *= rate
+		position.AverageCostBasis *= rate
+		position.Currency = currency
+		converted.Positions[i] = position
+	}
 
-		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
-			return parsed
-		}
-		return 0
-	}
-
-	position.AccountNumber = getString("Account Number")
-	position.AccountName = getString("Account Name")
-	position.Symbol = getString("Symbol")
-	position.Description = getString("Description")
-	position.Quantity = getFloat("Quantity")
-	position.LastPrice = getFloat("Last Price")
-	position.LastPriceChange = getFloat("Last Price Change")
-	position.CurrentValue = getFloat("Current Value")
-	position.TodayGainLoss = getFloat("Today's Gain/Loss Dollar")
-	position.TodayGainLossPct = getFloat("Today's Gain/Loss Percent")
-	position.TotalGainLoss = getFloat("Total Gain/Loss Dollar")
-	position.TotalGainLossPct = getFloat("Total Gain/Loss Percent")
-	position.PercentOfAccount = getFloat("Percent Of Account")
-	position.CostBasisTotal = getFloat("Cost Basis Total")
-	position.AverageCostBasis = getFloat("Average Cost Basis")
-	position.Type = getString("Type")
-
-	return position, nil
+	if err := a.calculateAggregations(&converted); err != nil {
+		return nil, err
+	}
+	return &converted, nil
 }
 
-// calculateAggregations calculates portfolio-level aggregations
-func (a *Analyzer) calculateAggregations(portfolio *models.Portfolio) {
+// calculateAggregations calculates portfolio-level aggregations in the portfolio's
+// currency, converting positions held in other currencies at the portfolio date
+func (a *Analyzer) calculateAggregations(portfolio *models.Portfolio) error {
 	accounts := make(map[string]*models.Account)
 
-	// Aggregate by account
-	for _, position := range portfolio.Positions {
+	// Work on positions restated in the portfolio currency; the portfolio keeps the originals
+	reporting := sharedmodels.CurrencyCode(portfolio.Currency)
+	restated := make([]models.Position, len(portfolio.Positions))
+	for i, position := range portfolio.Positions {
+		rate, err := a.FX.Convert(1, position.Currency, reporting, portfolio.Date)
+		if err != nil {
+			return fmt.Errorf("failed to value %s in %s: %w", position.Symbol, models.MaskAccount(position.AccountName), err)
+		}
+		position.CurrentValue *= rate
+		position.CostBasisTotal *= rate
+		position.TotalGainLoss *= rate
+		restated[i] = position
+	}
+
+	// Aggregate by account, listing each account's positions in their own currency
+	for i, position := range restated {
 		if _, exists := accounts[position.AccountName]; !exists {
 			accounts[position.AccountName] = &models.Account{
 				AccountNumber: position.AccountNumber,
@@ -173,7 +201,7 @@ func (a *Analyzer) calculateAggregations(portfolio *models.Portfolio) {
 		}
 
 		account := accounts[position.AccountName]
-		account.Positions = append(account.Positions, position)
+		account.Positions = append(account.Positions, portfolio.Positions[i])
 		account.TotalValue += position.CurrentValue
 		account.TotalCostBasis += position.CostBasisTotal
 		account.TotalGainLoss += position.TotalGainLoss
@@ -189,7 +217,7 @@ func (a *Analyzer) calculateAggregations(portfolio *models.Portfolio) {
 	portfolio.Accounts = accounts
 
 	// Calculate portfolio totals
-	for _, position := range portfolio.Positions {
+	for _, position := range restated {
 		portfolio.TotalValue += position.CurrentValue
 		portfolio.TotalCostBasis += position.CostBasisTotal
 		portfolio.TotalGainLoss += position.TotalGainLoss
@@ -200,56 +228,109 @@ func (a *Analyzer) calculateAggregations(portfolio *models.Portfolio) {
 	}
 
 	// Calculate asset allocation
-	portfolio.AssetAllocation = a.calculateAssetAllocation(portfolio)
+	portfolio.AssetAllocation = a.calculateAssetAllocation(portfolio, restated)
+	return nil
 }
 
-// calculateAssetAllocation calculates the asset allocation breakdown
-func (a *Analyzer) calculateAssetAllocation(portfolio *models.Portfolio) models.AssetAllocation {
-	allocation := models.AssetAllocation{}
-
-	symbolTotals := make(map[string]float64)
-	for _, position := range portfolio.Positions {
-		symbolTotals[position.Symbol] += position.CurrentValue
+// classification returns the analyzer's asset classification, or the defaults
+func (a *Analyzer) classification() *config.AssetClassification {
+	if a.Classes != nil {
+		return a.Classes
 	}
+	return config.DefaultAssetClassification()
+}
 
-	allocation.FBTCValue = symbolTotals["FBTC"]
-	allocation.MSTRValue = symbolTotals["MSTR"]
-	allocation.GLDValue = symbolTotals["GLD"]
-
-	// Calculate other assets
-	for symbol, value := range symbolTotals {
-		if symbol != "FBTC" && symbol != "MSTR" && symbol != "GLD" {
-			allocation.OtherValue += value
+// calculateAssetAllocation calculates the asset class breakdown from positions restated in
+// the portfolio currency
+func (a *Analyzer) calculateAssetAllocation(portfolio *models.Portfolio, positions []models.Position) models.AssetAllocation {
+	allocation := models.AssetAllocation{Classes: make(map[string]*models.ClassAllocation)}
+	classes := a.classification()
+
+	for _, position := range positions {
+		symbolClass := classes.Classify(position.Symbol)
+		class, ok := allocation.Classes[symbolClass.Class]
+		if !ok {
+			class = &models.ClassAllocation{Symbols: make(map[string]float64)}
+			allocation.Classes[symbolClass.Class] = class
 		}
+		class.Value += position.CurrentValue
+		class.Symbols[position.Symbol] += position.CurrentValue
+		allocation.BitcoinExposure += position.CurrentValue * symbolClass.BTCFactor
 	}
 
-	// Calculate percentages
-	if portfolio.TotalValue > 0 {
-		allocation.FBTCPercent = (allocation.FBTCValue / portfolio.TotalValue) * 100
-		allocation.MSTRPercent = (allocation.MSTRValue / portfolio.TotalValue) * 100
-		allocation.GLDPercent = (allocation.GLDValue / portfolio.TotalValue) * 100
-		allocation.OtherPercent = (allocation.OtherValue / portfolio.TotalValue) * 100
+	// Value the Bitcoin actually owned through the positions when there is a price for it
+	if lookThrough := a.lookThrough(portfolio, positions); lookThrough != nil {
+		allocation.LookThrough = lookThrough
+		allocation.BitcoinExposure = lookThrough.BTC * lookThrough.BitcoinPrice
 	}
 
-	// Calculate Bitcoin exposure
-	allocation.BitcoinExposure = allocation.FBTCValue + allocation.MSTRValue
+	// Calculate percentages
 	if portfolio.TotalValue > 0 {
+		for _, class := range allocation.Classes {
+			class.Percent = (class.Value / portfolio.TotalValue) * 100
+		}
 		allocation.BitcoinPercent = (allocation.BitcoinExposure / portfolio.TotalValue) * 100
 	}
 
-	// Calculate FBTC/MSTR ratio
-	if allocation.MSTRValue > 0 {
-		allocation.FBTCMSTRRatio = allocation.FBTCValue / allocation.MSTRValue
+	// Calculate spot BTC:treasury equity ratio
+	if treasury := allocation.Value(config.AssetClassBTCTreasury); treasury > 0 {
+		allocation.SpotTreasuryRatio = allocation.Value(config.AssetClassSpotBTC) / treasury
 	}
 
 	return allocation
 }
 
-// CalculateRebalance calculates how to rebalance to achieve a target FBTC:MSTR ratio
+// ClassHolding is a portfolio's holding in one asset class
+type ClassHolding struct {
+	Class   string
+	Value   float64
+	Symbol  string                 // Largest holding in the class, used for trades
+	Price   float64                // Last price of Symbol
+	Symbols []models.SymbolSummary // By value, largest first
+}
+
+// ClassHoldings sums the positions of a class. Positions are expected in the portfolio
+// currency (see ConvertPortfolio).
+func (a *Analyzer) ClassHoldings(portfolio *models.Portfolio, class string) ClassHolding {
+	holding := ClassHolding{Class: class}
+	classes := a.classification()
+	bySymbol := make(map[string]*models.SymbolSummary)
+	for _, position := range portfolio.Positions {
+		if classes.Classify(position.Symbol).Class != class {
+			continue
+		}
+		summary, ok := bySymbol[position.Symbol]
+		if !ok {
+			summary = &models.SymbolSummary{Symbol: position.Symbol, Description: position.Description, LastPrice: position.LastPrice}
+			bySymbol[position.Symbol] = summary
+		}
+		summary.TotalQuantity += position.Quantity
+		summary.TotalValue += position.CurrentValue
+		holding.Value += position.CurrentValue
+	}
+
+	for _, summary := range bySymbol {
+		holding.Symbols = append(holding.Symbols, *summary)
+	}
+	sort.Slice(holding.Symbols, func(i, j int) bool { return holding.Symbols[i].TotalValue > holding.Symbols[j].TotalValue })
+	if len(holding.Symbols) > 0 {
+		holding.Symbol = holding.Symbols[0].Symbol
+		holding.Price = holding.Symbols[0].LastPrice
+	}
+	return holding
+}
+
+// CalculateRebalance calculates the per-account trades that move towards a target spot
+// BTC:treasury equity ratio within the account constraints (see planTrades). Sells come from
+// tax-advantaged accounts first, then from the taxable positions that realize the least tax;
+// each account buys the largest holding of the other class it allows with its proceeds. The
+// new allocation shows the ratio the trades reach.
 func (a *Analyzer) CalculateRebalance(portfolio *models.Portfolio, targetRatio float64) *models.RebalanceRecommendation {
 	allocation := portfolio.AssetAllocation
+	spot := a.ClassHoldings(portfolio, config.AssetClassSpotBTC)
+	treasury := a.ClassHoldings(portfolio, config.AssetClassBTCTreasury)
 
-	if allocation.MSTRValue == 0 {
+	if treasury.Value == 0 {
 		return &models.RebalanceRecommendation{
 			CurrentRatio:    0,
 			TargetRatio:     targetRatio,
@@ -257,67 +338,62 @@ func (a *Analyzer) CalculateRebalance(portfolio *models.Portfolio, targetRatio f
 		}
 	}
 
-	currentRatio := allocation.FBTCMSTRRatio
+	currentRatio := allocation.SpotTreasuryRatio
 
 	// Calculate trade amount needed
-	// X = amount to sell from FBTC and buy in MSTR
-	// (FBTC - X) / (MSTR + X) = targetRatio
-	tradeAmount := (allocation.FBTCValue - targetRatio*allocation.MSTRValue) / (1 + targetRatio)
+	// X = amount to sell from spot and buy in treasury equity
+	// (spot - X) / (treasury + X) = targetRatio
+	tradeAmount := (spot.Value - targetRatio*treasury.Value) / (1 + targetRatio)
 
-	if tradeAmount <= 0 {
+	if tradeAmount == 0 {
 		return &models.RebalanceRecommendation{
 			CurrentRatio:    currentRatio,
 			TargetRatio:     targetRatio,
-			ReasonableRange: false,
+			ReasonableRange: true,
 		}
 	}
 
-	// Get current prices for share calculations
-	var fbtcPrice, mstrPrice float64
-	for _, position := range portfolio.Positions {
-		if position.Symbol == "FBTC" && fbtcPrice == 0 {
-			fbtcPrice = position.LastPrice
-		}
-		if position.Symbol == "MSTR" && mstrPrice == 0 {
-			mstrPrice = position.LastPrice
-		}
+	sell, buy := spot, treasury
+	if tradeAmount < 0 {
+		sell, buy = treasury, spot
 	}
+	amount := math.Abs(tradeAmount)
 
 	var trades []models.RecommendedTrade
-	if fbtcPrice > 0 && mstrPrice > 0 {
-		trades = append(trades,
-			models.RecommendedTrade{
-				Action:         "SELL",
-				Symbol:         "FBTC",
-				Shares:         tradeAmount / fbtcPrice,
-				EstimatedValue: tradeAmount,
-			},
-			models.RecommendedTrade{
-				Action:         "BUY",
-				Symbol:         "MSTR",
-				Shares:         tradeAmount / mstrPrice,
-				EstimatedValue: tradeAmount,
-			},
-		)
-	}
-
-	// Calculate new allocation after rebalancing
-	newAllocation := allocation
-	newAllocation.FBTCValue -= tradeAmount
-	newAllocation.MSTRValue += tradeAmount
-	newAllocation.FBTCPercent = (newAllocation.FBTCValue / portfolio.TotalValue) * 100
-	newAllocation.MSTRPercent = (newAllocation.MSTRValue / portfolio.TotalValue) * 100
-	newAllocation.FBTCMSTRRatio = newAllocation.FBTCValue / newAllocation.MSTRValue
+	var warnings []string
+	planned := sell.Price > 0 && buy.Price > 0
+	if planned {
+		trades, warnings = a.planTrades(portfolio, sell.Class, buy, amount)
+	}
+
+	// Calculate new allocation after rebalancing; with trades, only what they move
+	newAllocation := allocation.Clone()
+	if !planned {
+		newAllocation.Move(config.AssetClassSpotBTC, spot.Symbol, -tradeAmount, portfolio.TotalValue)
+		newAllocation.Move(config.AssetClassBTCTreasury, treasury.Symbol, tradeAmount, portfolio.TotalValue)
+	}
+	var realizedGain, estimatedTax float64
+	for _, trade := range trades {
+		if trade.Action == "SELL" {
+			newAllocation.Move(sell.Class, trade.Symbol, -trade.EstimatedValue, portfolio.TotalValue)
+			realizedGain += trade.RealizedGain()
+			estimatedTax += trade.EstimatedTax
+		} else {
+			newAllocation.Move(buy.Class, trade.Symbol, trade.EstimatedValue, portfolio.TotalValue)
+		}
+	}
+	newAllocation.SpotTreasuryRatio = newAllocation.Value(config.AssetClassSpotBTC) / newAllocation.Value(config.AssetClassBTCTreasury)
 
 	return &models.RebalanceRecommendation{
 		CurrentRatio:    currentRatio,
 		TargetRatio:     targetRatio,
 		TradeAmount:     tradeAmount,
-		FBTCToSell:      tradeAmount / fbtcPrice,
-		MSTRToBuy:       tradeAmount / mstrPrice,
 		NewAllocation:   newAllocation,
-		ReasonableRange: tradeAmount/portfolio.TotalValue <= 0.10, // Less than 10% of portfolio
+		ReasonableRange: amount/portfolio.TotalValue <= 0.10, // Less than 10% of portfolio
 		Trades:          trades,
+		RealizedGain:    realizedGain,
+		EstimatedTax:    estimatedTax,
+		Warnings:        warnings,
 	}
 }
 
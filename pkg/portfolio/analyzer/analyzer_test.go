@@ -0,0 +1,502 @@
+package analyzer
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/ledger"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+func TestParseCSVConvertsAccountCurrencies(t *testing.T) {
+	csv := `Account Number,Account Name,Symbol,Description,Quantity,Last Price,Current Value,Cost Basis Total,Total Gain/Loss Dollar
+X1,Brokerage,MSTR,STRATEGY,10,$100.00,"$1,000.00",$800.00,$200.00
+Z9,Japan,FBTC,FIDELITY BITCOIN,100,¥1000,"¥100,000",¥50000,¥50000
+`
+	path := filepath.Join(t.TempDir(), "positions.csv")
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	fx := sharedmodels.NewFXTable(&sharedmodels.FXRates{Currency: "JPY", Rates: []sharedmodels.FXRate{{Date: today.AddDate(0, 0, -1), USDPerUnit: 0.01}}})
+	a := &Analyzer{FX: fx, AccountCurrencies: map[string]string{"Z9": "jpy"}}
+
+	portfolio, err := a.ParseCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portfolio.Positions[1].Currency != "JPY" || portfolio.Positions[1].CurrentValue != 100000 {
+		t.Errorf("expected the JPY position to keep its own currency, got %+v", portfolio.Positions[1])
+	}
+	if portfolio.TotalValue != 2000 || portfolio.Accounts["Japan"].TotalValue != 1000 {
+		t.Errorf("expected USD totals of 2000 (Japan 1000), got %.2f (%.2f)", portfolio.TotalValue, portfolio.Accounts["Japan"].TotalValue)
+	}
+	if portfolio.AssetAllocation.SpotTreasuryRatio != 1 {
+		t.Errorf("expected allocation in USD, ratio %.2f", portfolio.AssetAllocation.SpotTreasuryRatio)
+	}
+
+	jpy, err := a.ConvertPortfolio(portfolio, "JPY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jpy.TotalValue != 200000 || jpy.Positions[0].LastPrice != 10000 || jpy.Positions[0].Currency != "JPY" {
+		t.Errorf("unexpected JPY restatement: total %.0f, MSTR price %.0f", jpy.TotalValue, jpy.Positions[0].LastPrice)
+	}
+
+	// Without rates a non-USD position cannot be valued
+	if _, err := (&Analyzer{AccountCurrencies: map[string]string{"Z9": "JPY"}}).ParseCSV(path); err == nil {
+		t.Error("expected an error without JPY rates")
+	}
+}
+
+func TestAllocationAndRebalanceUseAssetClasses(t *testing.T) {
+	a := &Analyzer{Classes: config.DefaultAssetClassification()}
+	a.Classes.Symbols["XYZ"] = config.SymbolClass{Class: config.AssetClassBTCTreasury, BTCFactor: 0.5}
+	portfolio := &models.Portfolio{
+		Date: time.Now(),
+		Positions: []models.Position{
+			{AccountName: "A", Symbol: "IBIT", Quantity: 100, LastPrice: 50, CurrentValue: 5000},
+			{AccountName: "A", Symbol: "FBTC", Quantity: 10, LastPrice: 100, CurrentValue: 1000},
+			{AccountName: "B", Symbol: "MSTR", Quantity: 5, LastPrice: 400, CurrentValue: 2000},
+			{AccountName: "B", Symbol: "XYZ", Quantity: 10, LastPrice: 100, CurrentValue: 1000},
+			{AccountName: "B", Symbol: "GLD", Quantity: 3, LastPrice: 300, CurrentValue: 900},
+			{AccountName: "B", Symbol: "SPAXX**", Quantity: 100, LastPrice: 1, CurrentValue: 100},
+		},
+	}
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	allocation := portfolio.AssetAllocation
+	if allocation.Value(config.AssetClassSpotBTC) != 6000 || allocation.Value(config.AssetClassBTCTreasury) != 3000 ||
+		allocation.Value(config.AssetClassGold) != 900 || allocation.Value(config.AssetClassCash) != 100 {
+		t.Errorf("unexpected classes %+v", allocation.Classes)
+	}
+	if allocation.SpotTreasuryRatio != 2 || allocation.BitcoinExposure != 8500 {
+		t.Errorf("expected ratio 2 and exposure 8500, got %.2f and %.2f", allocation.SpotTreasuryRatio, allocation.BitcoinExposure)
+	}
+
+	// Moving to 1:1 sells the largest spot holding and buys the largest treasury holding
+	rec := a.CalculateRebalance(portfolio, 1)
+	if rec.TradeAmount != 1500 || len(rec.Trades) != 2 || rec.Trades[0].Symbol != "IBIT" || rec.Trades[1].Symbol != "MSTR" || rec.Trades[1].Shares != 3.75 {
+		t.Errorf("unexpected recommendation %+v", rec)
+	}
+	if rec.NewAllocation.SpotTreasuryRatio != 1 || allocation.Value(config.AssetClassSpotBTC) != 6000 {
+		t.Errorf("expected a 1:1 new allocation without touching the current one, got %.2f", rec.NewAllocation.SpotTreasuryRatio)
+	}
+
+	// Moving to 4:1 goes the other way
+	rec = a.CalculateRebalance(portfolio, 4)
+	if rec.TradeAmount != -1200 || rec.Trades[0].Symbol != "MSTR" || rec.Trades[1].Symbol != "IBIT" {
+		t.Errorf("unexpected recommendation %+v", rec)
+	}
+}
+
+func TestLookThroughExposure(t *testing.T) {
+	date := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	store := repository.NewJSONStore(t.TempDir())
+	if err := store.SavePrices(repository.BitcoinSymbol, []repository.PricePoint{{Date: date.AddDate(0, 0, -1), Close: 100000}}); err != nil {
+		t.Fatal(err)
+	}
+	// Purchases after the snapshot date don't count
+	if err := store.SaveBTCTransactions("MSTR", []sharedmodels.BitcoinTransaction{
+		{Date: date.AddDate(0, 0, -30), BTCPurchased: 500, TotalBTCAfter: 1000},
+		{Date: date.AddDate(0, 0, 10), BTCPurchased: 500},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveSharesHistory("MSTR", []sharedmodels.SharesOutstandingRecord{{Date: date.AddDate(0, -3, 0), TotalShares: 10000}}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Analyzer{
+		Prices:       store.Prices(),
+		Transactions: store.Transactions(),
+		Shares:       store.Shares(),
+		Companies:    &config.CompaniesConfig{Companies: []config.CompanyData{{Symbol: "MSTR", TotalDebt: 50e6}}},
+	}
+	portfolio := &models.Portfolio{
+		Date: date,
+		Positions: []models.Position{
+			{AccountName: "A", Symbol: "MSTR", Quantity: 6, LastPrice: 20000, CurrentValue: 120000},
+			{AccountName: "B", Symbol: "MSTR", Quantity: 4, LastPrice: 20000, CurrentValue: 80000},
+			{AccountName: "B", Symbol: "FBTC", Quantity: 500, LastPrice: 100, CurrentValue: 50000},
+			{AccountName: "B", Symbol: "STRF", Quantity: 100, LastPrice: 100, CurrentValue: 10000},
+		},
+	}
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 MSTR × 0.1 BTC/share at mNAV 2, plus 0.5 BTC through FBTC; the preferred owns none
+	lookThrough := portfolio.AssetAllocation.LookThrough
+	if lookThrough == nil {
+		t.Fatal("expected look-through exposure")
+	}
+	if lookThrough.Sats != 150000000 || portfolio.AssetAllocation.BitcoinExposure != 150000 {
+		t.Errorf("expected 1.5 BTC worth 150000, got %d sats worth %.2f", lookThrough.Sats, portfolio.AssetAllocation.BitcoinExposure)
+	}
+	if lookThrough.PremiumPaid != 100000 || lookThrough.PremiumPercent != 100 {
+		t.Errorf("expected a 100000 (100%%) premium, got %.2f (%.1f%%)", lookThrough.PremiumPaid, lookThrough.PremiumPercent)
+	}
+	if len(lookThrough.Positions) != 2 {
+		t.Fatalf("expected MSTR and FBTC, got %+v", lookThrough.Positions)
+	}
+
+	// $50M of debt on a $200M market cap levers the shares 1.25x
+	mstr := lookThrough.Positions[0]
+	if mstr.Symbol != "MSTR" || mstr.BTCPerShare != 0.1 || mstr.Source != "filings" || mstr.MNAV() != 2 || mstr.Leverage != 1.25 {
+		t.Errorf("unexpected MSTR exposure %+v", mstr)
+	}
+	if mstr.EffectiveBTC != 2.5 || lookThrough.EffectiveBTC != 3 {
+		t.Errorf("expected 2.5 effective BTC through MSTR and 3 in total, got %.4f and %.4f", mstr.EffectiveBTC, lookThrough.EffectiveBTC)
+	}
+
+	// Without a Bitcoin price the exposure falls back to value × btc_factor
+	a.Prices = repository.NewJSONStore(t.TempDir()).Prices()
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+	if portfolio.AssetAllocation.LookThrough != nil || portfolio.AssetAllocation.BitcoinExposure != 250000 {
+		t.Errorf("expected the factor-based exposure, got %.2f", portfolio.AssetAllocation.BitcoinExposure)
+	}
+}
+
+func TestTaxAwareRebalancePrefersTaxAdvantagedAccounts(t *testing.T) {
+	date := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	a := &Analyzer{
+		Classes:   config.DefaultAssetClassification(),
+		LotMethod: taxlots.HIFO,
+		Lots: &models.TaxLots{Lots: []models.TaxLot{
+			{ID: "ibit-old", AccountNumber: "X1", Symbol: "IBIT", Acquired: date.AddDate(-2, 0, 0), Quantity: 40, CostBasis: 800},
+			{ID: "ibit-new", AccountNumber: "X1", Symbol: "IBIT", Acquired: date.AddDate(0, -2, 0), Quantity: 60, CostBasis: 3300},
+			{ID: "fbtc-loss", AccountNumber: "X1", Symbol: "FBTC", Acquired: date.AddDate(0, -3, 0), Quantity: 10, CostBasis: 1200},
+			{ID: "fbtc-ira", AccountNumber: "R1", Symbol: "FBTC", Acquired: date.AddDate(0, 0, -10), Quantity: 10, CostBasis: 1000},
+		}},
+	}
+	portfolio := &models.Portfolio{
+		Date: date,
+		Positions: []models.Position{
+			{AccountNumber: "X1", AccountName: "Individual", Symbol: "IBIT", Quantity: 100, LastPrice: 50, CurrentValue: 5000, CostBasisTotal: 4100},
+			{AccountNumber: "X1", AccountName: "Individual", Symbol: "FBTC", Quantity: 10, LastPrice: 100, CurrentValue: 1000, CostBasisTotal: 1200},
+			{AccountNumber: "R1", AccountName: "Rollover IRA", Symbol: "FBTC", Quantity: 10, LastPrice: 100, CurrentValue: 1000},
+			{AccountNumber: "R1", AccountName: "Rollover IRA", Symbol: "MSTR", Quantity: 5, LastPrice: 400, CurrentValue: 2000},
+		},
+	}
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	// 7000:2000 to 1:1 moves 2500: the IRA sells first, then the taxable FBTC loss, then IBIT,
+	// and each account places one MSTR buy
+	rec := a.CalculateRebalance(portfolio, 1)
+	if rec.TradeAmount != 2500 || len(rec.Trades) != 5 || rec.Trades[4].Action != "BUY" || rec.Trades[4].EstimatedValue != 1500 {
+		t.Fatalf("unexpected recommendation %+v", rec)
+	}
+	ira, loss, gain := rec.Trades[0], rec.Trades[2], rec.Trades[3]
+	if ira.Account != "R1" || ira.AccountType != config.AccountIRA || ira.EstimatedTax != 0 || rec.Trades[1].Account != "R1" || rec.Trades[1].Symbol != "MSTR" {
+		t.Errorf("expected the IRA to sell FBTC and buy MSTR first, got %+v and %+v", ira, rec.Trades[1])
+	}
+	if loss.Symbol != "FBTC" || loss.Account != "X1" || loss.ShortTermGain != -200 || loss.EstimatedTax != -48 {
+		t.Errorf("expected the taxable FBTC loss next, got %+v", loss)
+	}
+
+	// HIFO sells the newer IBIT lot: 10 shares at 55 cost, a 50 short-term loss
+	if gain.Symbol != "IBIT" || gain.EstimatedValue != 500 || len(gain.Lots) != 1 || gain.Lots[0].LotID != "ibit-new" || gain.ShortTermGain != -50 {
+		t.Errorf("expected 500 of IBIT from the newest lot, got %+v", gain)
+	}
+	if rec.RealizedGain != -250 || rec.NewAllocation.SpotTreasuryRatio != 1 {
+		t.Errorf("expected a 250 loss and a 1:1 allocation, got %.2f and %.2f", rec.RealizedGain, rec.NewAllocation.SpotTreasuryRatio)
+	}
+
+	// The IRA bought FBTC 10 days ago, so the taxable FBTC loss is a wash sale
+	washed := false
+	for _, warning := range rec.Warnings {
+		washed = washed || strings.Contains(warning, "FBTC") && strings.Contains(warning, "wash sale") && strings.Contains(warning, "in R1")
+	}
+	if !washed {
+		t.Errorf("expected a wash sale warning, got %v", rec.Warnings)
+	}
+}
+
+func TestRebalanceRespectsAccountConstraints(t *testing.T) {
+	a := &Analyzer{
+		Classes: config.DefaultAssetClassification(),
+		Accounts: &config.AccountsConfig{MinTrade: 100, Accounts: map[string]config.AccountSettings{
+			"IRA1":  {Type: config.AccountIRA, Exclude: []string{"MSTR"}},
+			"ROTH1": {Type: config.AccountRoth, NoSell: true},
+			"X1":    {MinTrade: 600},
+		}},
+	}
+	portfolio := &models.Portfolio{
+		Date: time.Now(),
+		Positions: []models.Position{
+			{AccountNumber: "IRA1", AccountName: "401K", Symbol: "FBTC", Quantity: 40, LastPrice: 100, CurrentValue: 4000},
+			{AccountNumber: "ROTH1", AccountName: "ROTH IRA", Symbol: "IBIT", Quantity: 40, LastPrice: 50, CurrentValue: 2000},
+			{AccountNumber: "X1", AccountName: "Individual", Symbol: "IBIT", Quantity: 10, LastPrice: 50, CurrentValue: 500, CostBasisTotal: 500},
+			{AccountNumber: "X2", AccountName: "Joint", Symbol: "IBIT", Quantity: 20, LastPrice: 50, CurrentValue: 1000, CostBasisTotal: 1000},
+			{AccountNumber: "X2", AccountName: "Joint", Symbol: "MSTR", Quantity: 10, LastPrice: 400, CurrentValue: 4000},
+			{AccountNumber: "X2", AccountName: "Joint", Symbol: "XYZ", Quantity: 10, LastPrice: 100, CurrentValue: 1000},
+		},
+	}
+	a.Classes.Symbols["XYZ"] = config.SymbolClass{Class: config.AssetClassBTCTreasury, BTCFactor: 1}
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	// 7500:5000 to 1:1 moves 1250. The 401(k) can't buy MSTR, so it buys XYZ; it covers the whole
+	// trade, leaving the Roth (no selling) and the taxable accounts alone.
+	rec := a.CalculateRebalance(portfolio, 1)
+	if len(rec.Trades) != 2 || rec.Trades[0].Account != "IRA1" || rec.Trades[0].Symbol != "FBTC" ||
+		rec.Trades[1].Symbol != "XYZ" || rec.Trades[1].EstimatedValue != 1250 || rec.NewAllocation.SpotTreasuryRatio != 1 {
+		t.Fatalf("unexpected trades %+v", rec.Trades)
+	}
+
+	// Without the 401(k), X1 is below its own 600 minimum and X2 sells what it holds
+	a.Accounts.Accounts["IRA1"] = config.AccountSettings{Type: config.AccountIRA, NoSell: true}
+	rec = a.CalculateRebalance(portfolio, 1)
+	if len(rec.Trades) != 2 || rec.Trades[0].Account != "X2" || rec.Trades[0].EstimatedValue != 1000 || rec.Trades[1].Symbol != "MSTR" {
+		t.Fatalf("unexpected trades %+v", rec.Trades)
+	}
+	if len(rec.Warnings) != 1 || !strings.Contains(rec.Warnings[0], "250.00 of the 1250.00") || rec.NewAllocation.SpotTreasuryRatio >= 1.1 {
+		t.Errorf("expected 250 left unplaced, got %v and ratio %.3f", rec.Warnings, rec.NewAllocation.SpotTreasuryRatio)
+	}
+
+	// Table recommendations get the same per-account trades
+	table := &RebalanceRecommendation{CurrentRatio: 1.5, TargetRatio: 1}
+	if plan := a.PlanAccountTrades(portfolio, table); plan == nil || len(table.Trades) != 2 || table.AchievedRatio != plan.NewAllocation.SpotTreasuryRatio {
+		t.Errorf("expected the plan's trades on the table recommendation, got %+v", table)
+	}
+}
+
+func TestCashRebalanceDoesNotSell(t *testing.T) {
+	a := &Analyzer{Classes: config.DefaultAssetClassification()}
+	portfolio := &models.Portfolio{
+		Date: time.Now(),
+		Positions: []models.Position{
+			{AccountNumber: "X1", AccountName: "Individual", Symbol: "IBIT", Quantity: 100, LastPrice: 50, CurrentValue: 5000, CostBasisTotal: 5000},
+			{AccountNumber: "X1", AccountName: "Individual", Symbol: "MSTR", Quantity: 5, LastPrice: 400, CurrentValue: 2000, CostBasisTotal: 2000},
+			{AccountNumber: "R1", AccountName: "Rollover IRA", Symbol: "FBTC", Quantity: 10, LastPrice: 100, CurrentValue: 1000},
+		},
+	}
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	// 6000:2000 toward 1:1: a 1000 deposit all goes to MSTR in the largest account, a partial rebalance
+	rec := a.CalculateCashRebalance(portfolio, 1, 1000, "")
+	if len(rec.Trades) != 1 || rec.Trades[0].Action != "BUY" || rec.Trades[0].Symbol != "MSTR" || rec.Trades[0].Account != "X1" ||
+		rec.NewAllocation.SpotTreasuryRatio != 2 || rec.Remaining != 1500 || rec.DepositToTarget != 4000 {
+		t.Fatalf("unexpected deposit plan %+v", rec)
+	}
+
+	// 5000 is more than enough: 500 of it goes to spot and the target is reached
+	rec = a.CalculateCashRebalance(portfolio, 1, 5000, "")
+	if len(rec.Trades) != 2 || rec.Trades[0].EstimatedValue != 500 || rec.Trades[1].EstimatedValue != 4500 ||
+		rec.NewAllocation.SpotTreasuryRatio != 1 || rec.Remaining != 0 {
+		t.Fatalf("unexpected deposit plan %+v", rec)
+	}
+
+	// A withdrawal sells the overweight class in taxable accounts only
+	rec = a.CalculateCashRebalance(portfolio, 1, -2000, "")
+	if len(rec.Trades) != 1 || rec.Trades[0].Action != "SELL" || rec.Trades[0].Symbol != "IBIT" || rec.Trades[0].EstimatedValue != 2000 ||
+		rec.NewAllocation.SpotTreasuryRatio != 2 {
+		t.Fatalf("unexpected withdrawal plan %+v", rec)
+	}
+
+	// Naming the IRA sells there, and it holds only 1000
+	rec = a.CalculateCashRebalance(portfolio, 1, -2000, "Rollover IRA")
+	if len(rec.Trades) != 1 || rec.Trades[0].Account != "R1" || len(rec.Warnings) != 1 || !strings.Contains(rec.Warnings[0], "1000.00 of the 2000.00") {
+		t.Errorf("expected 1000 from the IRA and 1000 unplaced, got %+v", rec)
+	}
+}
+
+func TestRunScenarioRevaluesThroughMNAV(t *testing.T) {
+	date := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	store := repository.NewJSONStore(t.TempDir())
+	if err := store.SavePrices(repository.BitcoinSymbol, []repository.PricePoint{{Date: date.AddDate(0, 0, -1), Close: 100000}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveBTCTransactions("MSTR", []sharedmodels.BitcoinTransaction{
+		{Date: date.AddDate(0, 0, -30), BTCPurchased: 1000, TotalBTCAfter: 1000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveSharesHistory("MSTR", []sharedmodels.SharesOutstandingRecord{{Date: date.AddDate(0, -3, 0), TotalShares: 10000}}); err != nil {
+		t.Fatal(err)
+	}
+	a := &Analyzer{
+		Prices:       store.Prices(),
+		Transactions: store.Transactions(),
+		Shares:       store.Shares(),
+		Companies:    &config.CompaniesConfig{Companies: []config.CompanyData{{Symbol: "MSTR"}}},
+	}
+	portfolio := &models.Portfolio{
+		Date: date,
+		Positions: []models.Position{
+			{AccountName: "A", Symbol: "MSTR", Quantity: 10, LastPrice: 20000, CurrentValue: 200000},
+			{AccountName: "A", Symbol: "FBTC", Quantity: 500, LastPrice: 100, CurrentValue: 50000},
+			{AccountName: "A", Symbol: "STRF", Quantity: 100, LastPrice: 100, CurrentValue: 10000},
+		},
+	}
+	if err := a.calculateAggregations(portfolio); err != nil {
+		t.Fatal(err)
+	}
+
+	// BTC -40% with MSTR's mNAV compressing from 2 to 1: MSTR falls to 0.6 × 0.5 of its price,
+	// FBTC to 0.6 and the preferred keeps its price
+	s := config.Scenario{Name: "crash", BTCChange: -0.4, MNAV: map[string]float64{config.AllTreasuries: 1}}
+	result, err := a.RunScenario(portfolio, s, testRebalancingTable(t), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := make(map[string]float64)
+	for _, position := range result.Portfolio.Positions {
+		prices[position.Symbol] = position.LastPrice
+	}
+	if math.Abs(prices["MSTR"]-6000) > 1e-9 || prices["FBTC"] != 60 || prices["STRF"] != 100 {
+		t.Errorf("unexpected shocked prices %v", prices)
+	}
+	if math.Abs(result.Portfolio.TotalValue-100000) > 1e-6 || math.Abs(result.ValueChange+160000) > 1e-6 {
+		t.Errorf("expected the portfolio to fall 160000 to 100000, got %.2f (%.2f)", result.Portfolio.TotalValue, result.ValueChange)
+	}
+	if portfolio.Positions[0].LastPrice != 20000 {
+		t.Error("expected the original portfolio to be unchanged")
+	}
+
+	// The shocked look-through values Bitcoin at 60000, so MSTR trades at mNAV 1
+	lookThrough := result.Portfolio.AssetAllocation.LookThrough
+	if lookThrough == nil || len(lookThrough.Positions) == 0 || math.Abs(lookThrough.Positions[0].MNAV()-1) > 1e-9 {
+		t.Fatalf("expected MSTR at mNAV 1 under the scenario, got %+v", lookThrough)
+	}
+
+	// mNAV 1 leaves the active 1:1 band, so the 30000:70000 split moves to 3:1 by selling MSTR for FBTC
+	if math.Abs(result.MNAV-1) > 1e-9 || result.Recommendation == nil || result.Recommendation.TargetRatio != 3 {
+		t.Fatalf("expected a 3:1 target at mNAV 1, got %v and %+v", result.MNAV, result.Recommendation)
+	}
+	if result.Plan == nil || len(result.Plan.Trades) != 2 {
+		t.Fatalf("expected a sell and a buy, got %+v", result.Plan)
+	}
+	sell, buy := result.Plan.Trades[0], result.Plan.Trades[1]
+	if sell.Action != "SELL" || sell.Symbol != "MSTR" || buy.Action != "BUY" || buy.Symbol != "FBTC" || math.Abs(sell.EstimatedValue-45000) > 1e-6 {
+		t.Errorf("expected to sell 45000 of MSTR for FBTC, got %+v", result.Plan.Trades)
+	}
+}
+
+func TestHistoricalScenariosReplayWorstWindows(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := repository.NewJSONStore(t.TempDir())
+	// Bitcoin halves over days 10-20 and falls 20% over days 30-40; MSTR falls harder in the first
+	closes := map[int]float64{0: 100, 10: 100, 20: 50, 30: 50, 40: 40, 50: 40}
+	var bitcoin, mstr []repository.PricePoint
+	for day := 0; day <= 50; day += 10 {
+		date := start.AddDate(0, 0, day)
+		bitcoin = append(bitcoin, repository.PricePoint{Date: date, Close: closes[day] * 1000})
+		mstrClose := closes[day]
+		if day >= 20 {
+			mstrClose *= 0.8
+		}
+		mstr = append(mstr, repository.PricePoint{Date: date, Close: mstrClose})
+	}
+	if err := store.SavePrices(repository.BitcoinSymbol, bitcoin); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SavePrices("MSTR", mstr); err != nil {
+		t.Fatal(err)
+	}
+	a := &Analyzer{Prices: store.Prices()}
+	portfolio := &models.Portfolio{Positions: []models.Position{{Symbol: "MSTR", Quantity: 1, CurrentValue: 40}}}
+
+	scenarios, err := a.HistoricalScenarios(portfolio, 10, 3, start.AddDate(0, 0, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) != 3 {
+		t.Fatalf("expected 3 windows, got %+v", scenarios)
+	}
+	worst := scenarios[0]
+	if !strings.Contains(worst.Name, "2025-01-11 to 2025-01-21") || math.Abs(worst.BTCChange+0.5) > 1e-9 {
+		t.Errorf("expected the halving first, got %+v", worst)
+	}
+	// Without holdings data MSTR's mNAV moves with its price relative to Bitcoin: 0.4 / 0.5
+	if math.Abs(worst.SymbolChange["MSTR"]+0.6) > 1e-9 || math.Abs(worst.MNAVChange["MSTR"]+0.2) > 1e-9 {
+		t.Errorf("expected MSTR -60%% at an mNAV 20%% lower, got %+v", worst)
+	}
+	if math.Abs(scenarios[1].BTCChange+0.2) > 1e-9 {
+		t.Errorf("expected the 20%% fall second, got %+v", scenarios[1])
+	}
+}
+
+func TestDailyValuesBetweenSnapshots(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := repository.NewJSONStore(t.TempDir())
+	// MSTR closes 100, 110, 120, ... with no close on day 2
+	var mstr []repository.PricePoint
+	for i := 0; i < 5; i++ {
+		if i != 2 {
+			mstr = append(mstr, repository.PricePoint{Date: start.AddDate(0, 0, i), Close: 100 + 10*float64(i)})
+		}
+	}
+	if err := store.SavePrices("MSTR", mstr); err != nil {
+		t.Fatal(err)
+	}
+	a := &Analyzer{Prices: store.Prices()}
+	snapshots := []*models.Portfolio{
+		{Date: start, TotalValue: 1500, Positions: []models.Position{
+			{Symbol: "MSTR", Quantity: 10, CurrentValue: 1000},
+			{Symbol: "SPAXX", Quantity: 500, CurrentValue: 500},
+		}},
+		{Date: start.AddDate(0, 0, 3), TotalValue: 2600, Positions: []models.Position{
+			{Symbol: "MSTR", Quantity: 20, CurrentValue: 2600},
+		}},
+	}
+
+	// Held constant: cash keeps its value and day 2 holds day 1's close
+	values, err := a.DailyValues(snapshots, nil, start.AddDate(0, 0, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(expected []float64) {
+		t.Helper()
+		if len(values) != len(expected) {
+			t.Fatalf("expected %d days, got %+v", len(expected), values)
+		}
+		for i, value := range values {
+			if math.Abs(value.Value-expected[i]) > 1e-9 || !value.Date.Equal(start.AddDate(0, 0, i)) {
+				t.Errorf("day %d: expected %.2f, got %.2f on %s", i, expected[i], value.Value, value.Date.Format("2006-01-02"))
+			}
+			if snapshot := i == 0 || i == 3; value.Interpolated == snapshot {
+				t.Errorf("day %d: expected interpolated %v", i, !snapshot)
+			}
+		}
+	}
+	check([]float64{1500, 1600, 1600, 2600, 2800})
+
+	// With the ledger: a 1000 deposit on day 1 buys 9 shares at 110 the same day
+	transactions := []models.Transaction{
+		{Date: start.AddDate(0, 0, 1), Type: models.TransactionDeposit, Amount: 1000},
+		{Date: start.AddDate(0, 0, 1), Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 9, Price: 110, Amount: -990},
+	}
+	if values, err = a.DailyValues(snapshots, transactions, start.AddDate(0, 0, 4)); err != nil {
+		t.Fatal(err)
+	}
+	check([]float64{1500, 2600, 2600, 2600, 2800})
+
+	// The deposit isn't a return; the rise in MSTR is
+	risk, err := a.DailyRisk(snapshots, transactions, ledger.RiskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if risk.Returns != 3 || risk.HistoricalVaR > 0 {
+		t.Errorf("expected 3 daily returns without a loss, got %+v", risk.Risk)
+	}
+}
@@ -0,0 +1,372 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+// RebalanceFrequency controls how often the backtester checks the rule table
+type RebalanceFrequency string
+
+const (
+	FrequencyDaily   RebalanceFrequency = "daily"
+	FrequencyWeekly  RebalanceFrequency = "weekly"
+	FrequencyMonthly RebalanceFrequency = "monthly"
+)
+
+// ParseRebalanceFrequency parses daily, weekly or monthly
+func ParseRebalanceFrequency(value string) (RebalanceFrequency, error) {
+	switch f := RebalanceFrequency(strings.ToLower(strings.TrimSpace(value))); f {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown rebalance frequency %q (want daily, weekly or monthly)", value)
+	}
+}
+
+// BacktestPoint is one day of the replayed dataset
+type BacktestPoint struct {
+	Date         time.Time `json:"date"`
+	BitcoinPrice float64   `json:"bitcoin_price"` // FBTC or BTC close
+	MSTRPrice    float64   `json:"mstr_price"`
+	MNAV         float64   `json:"mnav"`
+}
+
+// BacktestOptions configures a backtest run
+type BacktestOptions struct {
+	InitialCapital float64            `json:"initial_capital"`
+	Tolerance      float64            `json:"tolerance"`      // Fractional band around the target ratio before trading (0.05 = ±5%)
+	TradeCostBps   float64            `json:"trade_cost_bps"` // Commission per side, in basis points of traded value
+	SlippageBps    float64            `json:"slippage_bps"`   // Price impact per side, in basis points of traded value
+	Frequency      RebalanceFrequency `json:"frequency"`
+}
+
+// DefaultBacktestOptions returns the live advice settings: ±5% tolerance, daily checks, no costs
+func DefaultBacktestOptions() BacktestOptions {
+	return BacktestOptions{
+		InitialCapital: 100000,
+		Tolerance:      0.05,
+		Frequency:      FrequencyDaily,
+	}
+}
+
+// BacktestDay is one point on the equity curve, after any rebalance that day
+type BacktestDay struct {
+	Date         time.Time `json:"date"`
+	MNAV         float64   `json:"mnav"`
+	Equity       float64   `json:"equity"`
+	HODLBitcoin  float64   `json:"hodl_bitcoin"`
+	HODLMSTR     float64   `json:"hodl_mstr"`
+	BitcoinValue float64   `json:"bitcoin_value"`
+	MSTRValue    float64   `json:"mstr_value"`
+	TargetRatio  float64   `json:"target_ratio"`
+	Rebalanced   bool      `json:"rebalanced,omitempty"`
+}
+
+// BacktestTrade records one simulated rebalance
+type BacktestTrade struct {
+	Date         time.Time `json:"date"`
+	MNAV         float64   `json:"mnav"`
+	FromRatio    float64   `json:"from_ratio"`
+	TargetRatio  float64   `json:"target_ratio"`
+	BitcoinValue float64   `json:"bitcoin_value"` // Positive is a buy, negative a sell
+	MSTRValue    float64   `json:"mstr_value"`    // Positive is a buy, negative a sell
+	Fees         float64   `json:"fees"`
+	Slippage     float64   `json:"slippage"`
+	Explanation  string    `json:"explanation"`
+}
+
+// BacktestStats summarises one equity curve
+type BacktestStats struct {
+	FinalValue      float64   `json:"final_value"`
+	TotalReturnPct  float64   `json:"total_return_percent"`
+	CAGRPct         float64   `json:"cagr_percent"`
+	MaxDrawdownPct  float64   `json:"max_drawdown_percent"`
+	MaxDrawdownDate time.Time `json:"max_drawdown_date"`
+}
+
+// BacktestResult is the outcome of replaying the rule table over a dataset
+type BacktestResult struct {
+	Options            BacktestOptions `json:"options"`
+	StartDate          time.Time       `json:"start_date"`
+	EndDate            time.Time       `json:"end_date"`
+	Days               int             `json:"days"`
+	SkippedDays        int             `json:"skipped_days"` // Points with a missing price or mNAV
+	Strategy           BacktestStats   `json:"strategy"`
+	HODLBitcoin        BacktestStats   `json:"hodl_bitcoin"`
+	HODLMSTR           BacktestStats   `json:"hodl_mstr"`
+	TradeCount         int             `json:"trade_count"`
+	TradedValue        float64         `json:"traded_value"`        // One-way value traded
+	Turnover           float64         `json:"turnover"`            // Traded value over average equity
+	AnnualizedTurnover float64         `json:"annualized_turnover"` // Turnover per year
+	TotalFees          float64         `json:"total_fees"`
+	TotalSlippage      float64         `json:"total_slippage"`
+	Curve              []BacktestDay   `json:"curve"`
+	Trades             []BacktestTrade `json:"trades"`
+}
+
+// Backtest replays the rule table over daily points. The portfolio starts at the
+// first day's target ratio; on each check day the rule state machine moves the target
+// (see NextTargetRatio) and the portfolio trades back to it when the Bitcoin:MSTR
+// ratio has left the tolerance band, paying fees and slippage on both legs. HODL
+// benchmarks hold the initial capital entirely in one asset.
+func (dt *DynamicRebalancingTable) Backtest(points []BacktestPoint, opts BacktestOptions) (*BacktestResult, error) {
+	if opts.InitialCapital <= 0 {
+		return nil, fmt.Errorf("initial capital must be positive")
+	}
+	if opts.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	}
+	if opts.TradeCostBps < 0 || opts.SlippageBps < 0 {
+		return nil, fmt.Errorf("trade cost and slippage must not be negative")
+	}
+	if opts.Frequency == "" {
+		opts.Frequency = FrequencyDaily
+	}
+	if _, err := ParseRebalanceFrequency(string(opts.Frequency)); err != nil {
+		return nil, err
+	}
+
+	days, skipped := usableBacktestPoints(points)
+	if len(days) < 2 {
+		return nil, fmt.Errorf("need at least 2 days with prices and mNAV, got %d", len(days))
+	}
+
+	result := &BacktestResult{
+		Options:     opts,
+		StartDate:   days[0].Date,
+		EndDate:     days[len(days)-1].Date,
+		Days:        len(days),
+		SkippedDays: skipped,
+	}
+
+	first := days[0]
+	ratio, _, err := dt.GetTargetRatio(first.MNAV)
+	if err != nil {
+		return nil, err
+	}
+	bitcoinUnits := opts.InitialCapital * ratio / (ratio + 1) / first.BitcoinPrice
+	mstrUnits := opts.InitialCapital / (ratio + 1) / first.MSTRPrice
+	hodlBitcoinUnits := opts.InitialCapital / first.BitcoinPrice
+	hodlMSTRUnits := opts.InitialCapital / first.MSTRPrice
+
+	costRate := opts.TradeCostBps / 10000
+	slippageRate := opts.SlippageBps / 10000
+	lastCheck := first.Date
+
+	for i, day := range days {
+		bitcoinValue := bitcoinUnits * day.BitcoinPrice
+		mstrValue := mstrUnits * day.MSTRPrice
+		rebalanced := false
+
+		if i > 0 && isCheckDay(lastCheck, day.Date, opts.Frequency) {
+			lastCheck = day.Date
+			target, explanation := dt.NextTargetRatio(ratio, day.MNAV)
+			ratio = target
+
+			current := bitcoinValue / mstrValue
+			band := target * opts.Tolerance
+			if current < target-band || current > target+band {
+				total := bitcoinValue + mstrValue
+				delta := total*target/(target+1) - bitcoinValue
+				traded := math.Abs(delta)
+				fees := 2 * traded * costRate
+				slippage := 2 * traded * slippageRate
+				total -= fees + slippage
+
+				newBitcoinValue := total * target / (target + 1)
+				newMSTRValue := total / (target + 1)
+				result.Trades = append(result.Trades, BacktestTrade{
+					Date:         day.Date,
+					MNAV:         day.MNAV,
+					FromRatio:    current,
+					TargetRatio:  target,
+					BitcoinValue: newBitcoinValue - bitcoinValue,
+					MSTRValue:    newMSTRValue - mstrValue,
+					Fees:         fees,
+					Slippage:     slippage,
+					Explanation:  explanation,
+				})
+				result.TradedValue += traded
+				result.TotalFees += fees
+				result.TotalSlippage += slippage
+
+				bitcoinValue, mstrValue = newBitcoinValue, newMSTRValue
+				bitcoinUnits = bitcoinValue / day.BitcoinPrice
+				mstrUnits = mstrValue / day.MSTRPrice
+				rebalanced = true
+			}
+		}
+
+		result.Curve = append(result.Curve, BacktestDay{
+			Date:         day.Date,
+			MNAV:         day.MNAV,
+			Equity:       bitcoinValue + mstrValue,
+			HODLBitcoin:  hodlBitcoinUnits * day.BitcoinPrice,
+			HODLMSTR:     hodlMSTRUnits * day.MSTRPrice,
+			BitcoinValue: bitcoinValue,
+			MSTRValue:    mstrValue,
+			TargetRatio:  ratio,
+			Rebalanced:   rebalanced,
+		})
+	}
+
+	result.TradeCount = len(result.Trades)
+	result.Strategy = curveStats(result.Curve, opts.InitialCapital, func(d BacktestDay) float64 { return d.Equity })
+	result.HODLBitcoin = curveStats(result.Curve, opts.InitialCapital, func(d BacktestDay) float64 { return d.HODLBitcoin })
+	result.HODLMSTR = curveStats(result.Curve, opts.InitialCapital, func(d BacktestDay) float64 { return d.HODLMSTR })
+
+	var equitySum float64
+	for _, d := range result.Curve {
+		equitySum += d.Equity
+	}
+	if avg := equitySum / float64(len(result.Curve)); avg > 0 {
+		result.Turnover = result.TradedValue / avg
+	}
+	if years := backtestYears(result.StartDate, result.EndDate); years > 0 {
+		result.AnnualizedTurnover = result.Turnover / years
+	}
+
+	return result, nil
+}
+
+// usableBacktestPoints returns the points with prices and mNAV in date order, and how
+// many were dropped
+func usableBacktestPoints(points []BacktestPoint) ([]BacktestPoint, int) {
+	var days []BacktestPoint
+	for _, p := range points {
+		if p.BitcoinPrice > 0 && p.MSTRPrice > 0 && p.MNAV > 0 && !math.IsNaN(p.MNAV) {
+			days = append(days, p)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	return days, len(points) - len(days)
+}
+
+// isCheckDay reports whether date starts a new rebalance period after the last check
+func isCheckDay(last, date time.Time, frequency RebalanceFrequency) bool {
+	switch frequency {
+	case FrequencyWeekly:
+		lastYear, lastWeek := last.ISOWeek()
+		year, week := date.ISOWeek()
+		return year != lastYear || week != lastWeek
+	case FrequencyMonthly:
+		return date.Year() != last.Year() || date.Month() != last.Month()
+	default:
+		return true
+	}
+}
+
+// curveStats computes return, CAGR and maximum drawdown for one series of the curve
+func curveStats(curve []BacktestDay, initial float64, value func(BacktestDay) float64) BacktestStats {
+	stats := BacktestStats{FinalValue: value(curve[len(curve)-1])}
+	stats.TotalReturnPct = (stats.FinalValue/initial - 1) * 100
+	if years := backtestYears(curve[0].Date, curve[len(curve)-1].Date); years > 0 && stats.FinalValue > 0 {
+		stats.CAGRPct = (math.Pow(stats.FinalValue/initial, 1/years) - 1) * 100
+	}
+
+	peak := initial
+	for _, d := range curve {
+		v := value(d)
+		if v > peak {
+			peak = v
+		}
+		if drawdown := (peak - v) / peak * 100; drawdown > stats.MaxDrawdownPct {
+			stats.MaxDrawdownPct = drawdown
+			stats.MaxDrawdownDate = d.Date
+		}
+	}
+	return stats
+}
+
+// backtestYears returns the span between two dates in years
+func backtestYears(start, end time.Time) float64 {
+	return end.Sub(start).Hours() / 24 / 365.25
+}
+
+// LoadBacktestPoints reads the daily dataset from an mnav-historical output file,
+// returning the points and the company symbol
+func LoadBacktestPoints(path string) ([]BacktestPoint, string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read mNAV history: %w", err)
+	}
+
+	var history struct {
+		Symbol     string `json:"symbol"`
+		DataPoints []struct {
+			Date         string  `json:"date"`
+			StockPrice   float64 `json:"stock_price"`
+			BitcoinPrice float64 `json:"bitcoin_price"`
+			MNAV         float64 `json:"mnav"`
+		} `json:"data_points"`
+	}
+	if err := json.Unmarshal(raw, &history); err != nil {
+		return nil, "", fmt.Errorf("failed to parse mNAV history: %w", err)
+	}
+
+	points := make([]BacktestPoint, 0, len(history.DataPoints))
+	for _, dp := range history.DataPoints {
+		date, err := time.Parse("2006-01-02", dp.Date)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid date %q in mNAV history: %w", dp.Date, err)
+		}
+		points = append(points, BacktestPoint{
+			Date:         date,
+			BitcoinPrice: dp.BitcoinPrice,
+			MSTRPrice:    dp.StockPrice,
+			MNAV:         dp.MNAV,
+		})
+	}
+	return points, history.Symbol, nil
+}
+
+// UseFBTCPrices replaces the Bitcoin leg with stored FBTC closes where available,
+// and scales BTC prices on other days (before listing, weekends) so the series is continuous
+func UseFBTCPrices(points []BacktestPoint, prices repository.PriceRepository) (int, error) {
+	if len(points) == 0 {
+		return 0, nil
+	}
+	fbtc, err := prices.GetPrices("FBTC", points[0].Date, points[len(points)-1].Date)
+	if err != nil {
+		return 0, err
+	}
+	if len(fbtc) == 0 {
+		return 0, fmt.Errorf("no FBTC prices in data/stock-data/historical (run the stock collector or use -bitcoin btc)")
+	}
+
+	closes := make(map[string]float64, len(fbtc))
+	for _, p := range fbtc {
+		closes[p.Date.Format("2006-01-02")] = p.Close
+	}
+
+	// FBTC tracks BTC at a fixed fraction; use the first day with both to scale earlier BTC prices
+	scale := 0.0
+	for _, p := range points {
+		if c, ok := closes[p.Date.Format("2006-01-02")]; ok && p.BitcoinPrice > 0 {
+			scale = c / p.BitcoinPrice
+			break
+		}
+	}
+	if scale == 0 {
+		return 0, fmt.Errorf("FBTC prices do not overlap the mNAV history")
+	}
+
+	replaced := 0
+	for i := range points {
+		if c, ok := closes[points[i].Date.Format("2006-01-02")]; ok {
+			points[i].BitcoinPrice = c
+			replaced++
+		} else {
+			points[i].BitcoinPrice *= scale
+		}
+	}
+	return replaced, nil
+}
@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+)
+
+func testRebalancingTable(t *testing.T) *DynamicRebalancingTable {
+	t.Helper()
+	table, err := NewDynamicRebalancingTableFromConfig(&config.RebalancingConfig{Rules: []config.RebalancingRule{
+		{MinThreshold: 0, MaxThreshold: 2, TargetRatio: 3},
+		{MinThreshold: 1.5, MaxThreshold: 10, TargetRatio: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestBacktestRebalancesOutsideTolerance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []BacktestPoint{
+		{Date: start, BitcoinPrice: 100, MSTRPrice: 100, MNAV: 1},
+		{Date: start.AddDate(0, 0, 1), BitcoinPrice: 100, MSTRPrice: 200, MNAV: 1},
+		{Date: start.AddDate(0, 0, 2), BitcoinPrice: 100, MSTRPrice: 0, MNAV: 1},
+		{Date: start.AddDate(0, 0, 3), BitcoinPrice: 101, MSTRPrice: 202, MNAV: 1},
+	}
+	opts := DefaultBacktestOptions()
+	opts.InitialCapital = 100
+	opts.TradeCostBps = 10
+
+	result, err := testRebalancingTable(t).Backtest(points, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Days != 3 || result.SkippedDays != 1 {
+		t.Fatalf("expected 3 days and 1 skipped, got %d and %d", result.Days, result.SkippedDays)
+	}
+	if result.TradeCount != 1 {
+		t.Fatalf("expected one rebalance when MSTR doubles, got %d", result.TradeCount)
+	}
+
+	// 75 BTC + 50 MSTR = 125 at 1.5:1; back to 3:1 trades 18.75 each way
+	trade := result.Trades[0]
+	if math.Abs(result.TradedValue-18.75) > 1e-9 || math.Abs(trade.Fees-0.0375) > 1e-9 {
+		t.Errorf("expected 18.75 traded and 0.0375 fees, got %.4f and %.4f", result.TradedValue, trade.Fees)
+	}
+	if equity := result.Curve[1].Equity; math.Abs(equity-(125-0.0375)) > 1e-9 {
+		t.Errorf("expected equity net of fees, got %.4f", equity)
+	}
+	if ratio := result.Curve[1].BitcoinValue / result.Curve[1].MSTRValue; math.Abs(ratio-3) > 1e-9 {
+		t.Errorf("expected 3:1 after rebalancing, got %.4f", ratio)
+	}
+	if result.HODLMSTR.FinalValue != 202 || result.HODLBitcoin.FinalValue != 101 {
+		t.Errorf("unexpected HODL values %.2f / %.2f", result.HODLBitcoin.FinalValue, result.HODLMSTR.FinalValue)
+	}
+}
+
+func TestBacktestMonthlyFrequencyAndDrawdown(t *testing.T) {
+	start := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)
+	points := []BacktestPoint{
+		{Date: start, BitcoinPrice: 100, MSTRPrice: 100, MNAV: 1},
+		{Date: start.AddDate(0, 0, 1), BitcoinPrice: 50, MSTRPrice: 100, MNAV: 5},
+		{Date: start.AddDate(0, 0, 2), BitcoinPrice: 50, MSTRPrice: 100, MNAV: 5},
+	}
+	opts := DefaultBacktestOptions()
+	opts.InitialCapital = 100
+	opts.Frequency = FrequencyMonthly
+
+	result, err := testRebalancingTable(t).Backtest(points, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TradeCount != 1 || !result.Trades[0].Date.Equal(start.AddDate(0, 0, 2)) {
+		t.Fatalf("expected a single rebalance on the first day of February, got %+v", result.Trades)
+	}
+	if math.Abs(result.HODLBitcoin.MaxDrawdownPct-50) > 1e-9 {
+		t.Errorf("expected a 50%% HODL-BTC drawdown, got %.2f", result.HODLBitcoin.MaxDrawdownPct)
+	}
+	if _, err := ParseRebalanceFrequency("hourly"); err == nil {
+		t.Error("expected an error for an unknown frequency")
+	}
+}
@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// CashSplit divides a deposit (positive cash) or a withdrawal (negative cash) between the spot
+// BTC and treasury equity classes, moving their ratio as close to targetRatio as the cash allows
+// without selling one class to buy the other. It returns the change in each class; when the
+// cash is too small to reach the target, all of it goes to the class that is furthest off.
+func CashSplit(spot, treasury, targetRatio, cash float64) (spotChange, treasuryChange float64) {
+	target := (spot+treasury+cash)*targetRatio/(targetRatio+1) - spot
+	low, high := 0.0, cash
+	if cash < 0 {
+		low, high = math.Max(cash, -spot), math.Min(0, cash+treasury)
+	}
+	spotChange = math.Max(low, math.Min(high, target))
+	return spotChange, cash - spotChange
+}
+
+// depositToTarget returns the smallest deposit that reaches targetRatio without selling
+func depositToTarget(spot, treasury, targetRatio float64) float64 {
+	if spot > targetRatio*treasury {
+		return (spot - targetRatio*treasury) / targetRatio
+	}
+	return targetRatio*treasury - spot
+}
+
+// cashAccount returns the account named by account, by number, masked number or name, or else
+// the largest account in the portfolio. An account without positions is returned as named.
+func cashAccount(portfolio *models.Portfolio, account string) (key, number, name string) {
+	values := make(map[string]float64)
+	var largest *models.Position
+	for i, position := range portfolio.Positions {
+		if account != "" {
+			if position.AccountNumber == account || position.AccountName == account ||
+				(position.AccountNumber != "" && models.MaskAccount(position.AccountNumber) == account) {
+				return positionAccount(position), position.AccountNumber, position.AccountName
+			}
+			continue
+		}
+		values[positionAccount(position)] += position.CurrentValue
+		if largest == nil || values[positionAccount(position)] > values[positionAccount(*largest)] {
+			largest = &portfolio.Positions[i]
+		}
+	}
+	if largest == nil {
+		return account, account, account
+	}
+	return positionAccount(*largest), largest.AccountNumber, largest.AccountName
+}
+
+// planBuy invests value of a deposit in the largest holding of a class the account allows
+func (a *Analyzer) planBuy(portfolio *models.Portfolio, holding ClassHolding, value float64, account string) ([]models.RecommendedTrade, []string) {
+	key, number, name := cashAccount(portfolio, account)
+	settings := a.Accounts.Settings(number, name)
+	buy, ok := accountBuy(holding, settings)
+	if !ok {
+		return nil, []string{fmt.Sprintf("%s may not buy any %s holding, so %.2f stays in cash",
+			models.MaskAccount(key), config.AssetClassLabel(holding.Class), value)}
+	}
+	if value < settings.MinTrade {
+		return nil, []string{fmt.Sprintf("the %.2f %s buy is below the %.2f minimum of %s, so it stays in cash",
+			value, buy.Symbol, settings.MinTrade, models.MaskAccount(key))}
+	}
+	return []models.RecommendedTrade{{
+		Action:         "BUY",
+		Symbol:         buy.Symbol,
+		Shares:         shares(value, buy.LastPrice),
+		EstimatedValue: value,
+		Account:        key,
+		AccountType:    settings.Type,
+	}}, nil
+}
+
+// planWithdrawal sells value of a class to raise a withdrawal. Without an account it sells in
+// taxable accounts, cheapest tax first, since taking cash out of a tax-advantaged account is a
+// distribution; those only sell when named.
+func (a *Analyzer) planWithdrawal(portfolio *models.Portfolio, sellClass string, value float64, account string) ([]models.RecommendedTrade, []string) {
+	include := func(position models.Position, settings config.AccountSettings) bool {
+		if account != "" {
+			return position.AccountNumber == account || position.AccountName == account
+		}
+		return !config.TaxAdvantaged(settings.Type)
+	}
+	candidates, warnings := a.sellCandidates(portfolio, sellClass, nil, value, include)
+	sells, sellWarnings, remaining := a.placeSells(portfolio, candidates, value)
+	warnings = append(warnings, sellWarnings...)
+	if remaining >= valueEpsilon {
+		warnings = append(warnings, fmt.Sprintf("account constraints leave %.2f of the %.2f %s withdrawal unplaced",
+			remaining, value, config.AssetClassLabel(sellClass)))
+	}
+	return sells, warnings
+}
+
+// CalculateCashRebalance invests a deposit (positive cash) or raises a withdrawal (negative
+// cash) in the spot BTC and treasury equity classes, moving toward targetRatio without selling
+// to buy (see CashSplit). Deposits are bought in account, or the largest account, within its
+// constraints; withdrawals sell as planWithdrawal does. When the cash can't reach the target the
+// rebalance is partial: Remaining is the sell-and-buy still needed and DepositToTarget the
+// deposit that would get there.
+func (a *Analyzer) CalculateCashRebalance(portfolio *models.Portfolio, targetRatio, cash float64, account string) *models.RebalanceRecommendation {
+	allocation := portfolio.AssetAllocation
+	spot := a.ClassHoldings(portfolio, config.AssetClassSpotBTC)
+	treasury := a.ClassHoldings(portfolio, config.AssetClassBTCTreasury)
+	rec := &models.RebalanceRecommendation{
+		CurrentRatio:    allocation.SpotTreasuryRatio,
+		TargetRatio:     targetRatio,
+		Cash:            cash,
+		ReasonableRange: portfolio.TotalValue > 0 && math.Abs(cash)/portfolio.TotalValue <= 0.10,
+	}
+
+	if held := spot.Value + treasury.Value; -cash > held {
+		rec.Warnings = append(rec.Warnings, fmt.Sprintf("the %.2f withdrawal exceeds the %.2f held in both classes", -cash, held))
+		cash = -held
+	}
+	spotChange, treasuryChange := CashSplit(spot.Value, treasury.Value, targetRatio, cash)
+	for _, change := range []struct {
+		holding ClassHolding
+		value   float64
+	}{{spot, spotChange}, {treasury, treasuryChange}} {
+		var trades []models.RecommendedTrade
+		var warnings []string
+		switch {
+		case change.value >= valueEpsilon:
+			trades, warnings = a.planBuy(portfolio, change.holding, change.value, account)
+		case change.value <= -valueEpsilon:
+			trades, warnings = a.planWithdrawal(portfolio, change.holding.Class, -change.value, account)
+		}
+		rec.Trades = append(rec.Trades, trades...)
+		rec.Warnings = append(rec.Warnings, warnings...)
+	}
+
+	// The new allocation shows what the placed trades reach
+	classes := a.classification()
+	newAllocation := allocation.Clone()
+	total := portfolio.TotalValue
+	for _, trade := range rec.Trades {
+		if trade.Action == "BUY" {
+			total += trade.EstimatedValue
+		} else {
+			total -= trade.EstimatedValue
+		}
+	}
+	for _, trade := range rec.Trades {
+		value := trade.EstimatedValue
+		if trade.Action == "SELL" {
+			value = -value
+			rec.RealizedGain += trade.RealizedGain()
+			rec.EstimatedTax += trade.EstimatedTax
+		}
+		newAllocation.Move(classes.Classify(trade.Symbol).Class, trade.Symbol, value, total)
+	}
+	newSpot, newTreasury := newAllocation.Value(config.AssetClassSpotBTC), newAllocation.Value(config.AssetClassBTCTreasury)
+	if newTreasury > 0 {
+		newAllocation.SpotTreasuryRatio = newSpot / newTreasury
+	}
+	rec.NewAllocation = newAllocation
+
+	if remaining := (newSpot - targetRatio*newTreasury) / (1 + targetRatio); math.Abs(remaining) >= valueEpsilon {
+		rec.Remaining = remaining
+		if need := depositToTarget(spot.Value, treasury.Value, targetRatio); cash > 0 && need > cash {
+			rec.DepositToTarget = need
+		}
+	}
+	return rec
+}
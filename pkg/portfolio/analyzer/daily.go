@@ -0,0 +1,203 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/ledger"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+// day truncates a time to its UTC date
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// priceHistory returns a symbol's stored closes from start to end: its own history, else its
+// USD price series (BTC-USD, ...)
+func (a *Analyzer) priceHistory(symbol string, start, end time.Time) []repository.PricePoint {
+	symbol = strings.ToUpper(symbol)
+	series := []string{symbol}
+	if !repository.IsAssetPriceSeries(symbol) {
+		series = append(series, symbol+"-USD")
+	}
+	for _, s := range series {
+		if prices, err := a.Prices.GetPrices(s, start, end); err == nil && len(prices) > 0 {
+			return prices
+		}
+	}
+	return nil
+}
+
+// DailyValue is the portfolio's value on one day
+type DailyValue struct {
+	Date         time.Time `json:"date"`
+	Value        float64   `json:"value"`
+	Interpolated bool      `json:"interpolated"` // Reconstructed between snapshots
+}
+
+// dailyHolding is a symbol held between snapshots
+type dailyHolding struct {
+	quantity float64
+	value    float64 // Per share, for symbols without a price history
+	unit     float64 // Value per share per unit of the stored close
+	prices   []repository.PricePoint
+}
+
+// valueOn returns the holding's value on date
+func (h *dailyHolding) valueOn(date time.Time) float64 {
+	if price, ok := priceOn(h.prices, date); ok && h.unit > 0 {
+		return h.quantity * price * h.unit
+	}
+	return h.quantity * h.value
+}
+
+// DailyValues reconstructs the portfolio's value on every day from the first snapshot to end.
+// Snapshot days take the snapshot's value. In between, the last snapshot's positions are
+// repriced by their stored closes, and the ledger's transactions are applied: buys and sells
+// change the shares held, and every amount moves cash. Without transactions the positions are
+// held constant. Positions without a price history, such as cash, keep their snapshot price.
+// The snapshots must be in one currency, and currency moves between them aren't reflected.
+func (a *Analyzer) DailyValues(snapshots []*models.Portfolio, transactions []models.Transaction, end time.Time) ([]DailyValue, error) {
+	if a.Prices == nil {
+		return nil, fmt.Errorf("no price repository")
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no portfolio snapshots")
+	}
+	sorted := append([]*models.Portfolio(nil), snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	txs := append([]models.Transaction(nil), transactions...)
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].Date.Before(txs[j].Date) })
+	start, end := day(sorted[0].Date), day(end)
+
+	classes := a.classification()
+	isCash := func(symbol string) bool {
+		return symbol == "" || classes.Classify(symbol).Class == config.AssetClassCash
+	}
+	histories := make(map[string][]repository.PricePoint)
+	history := func(symbol string) []repository.PricePoint {
+		if prices, ok := histories[symbol]; ok {
+			return prices
+		}
+		prices := a.priceHistory(symbol, start.AddDate(0, 0, -7), end)
+		histories[symbol] = prices
+		return prices
+	}
+
+	var values []DailyValue
+	for i, snapshot := range sorted {
+		from, until := day(snapshot.Date), end
+		if i+1 < len(sorted) {
+			until = day(sorted[i+1].Date).AddDate(0, 0, -1)
+		}
+
+		// The snapshot's holdings by symbol, with cash pooled
+		cash := snapshot.TotalValue
+		holdings := make(map[string]*dailyHolding)
+		for _, position := range snapshot.Positions {
+			symbol := strings.ToUpper(position.Symbol)
+			if isCash(symbol) {
+				continue
+			}
+			cash -= position.CurrentValue
+			h, ok := holdings[symbol]
+			if !ok {
+				h = &dailyHolding{prices: history(symbol)}
+				holdings[symbol] = h
+			}
+			h.quantity += position.Quantity
+			h.value += position.CurrentValue
+		}
+		for _, h := range holdings {
+			total := h.value
+			h.value = 0
+			if h.quantity > 0 {
+				h.value = total / h.quantity
+				if base, ok := priceOn(h.prices, from); ok && base > 0 {
+					h.unit = total / (h.quantity * base)
+				}
+			}
+		}
+
+		next := sort.Search(len(txs), func(j int) bool { return day(txs[j].Date).After(from) })
+		for date := from; !date.After(until); date = date.AddDate(0, 0, 1) {
+			for ; next < len(txs) && !day(txs[next].Date).After(date); next++ {
+				tx := txs[next]
+				symbol := strings.ToUpper(tx.Symbol)
+				trade := tx.Type == models.TransactionBuy || tx.Type == models.TransactionSell
+				if trade && isCash(symbol) {
+					continue // Sweeps within cash
+				}
+				cash += tx.Amount
+				if !trade {
+					continue
+				}
+				h, ok := holdings[symbol]
+				if !ok {
+					h = &dailyHolding{prices: history(symbol), value: tx.Price, unit: 1}
+					holdings[symbol] = h
+				}
+				if tx.Type == models.TransactionBuy {
+					h.quantity += tx.Quantity
+				} else {
+					h.quantity -= tx.Quantity
+				}
+			}
+
+			value := DailyValue{Date: date, Value: snapshot.TotalValue, Interpolated: !date.Equal(from)}
+			if value.Interpolated {
+				value.Value = cash
+				for _, h := range holdings {
+					value.Value += h.valueOn(date)
+				}
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// Valuations returns the daily values as valuations for the ledger's return calculations
+func Valuations(values []DailyValue) []ledger.Valuation {
+	valuations := make([]ledger.Valuation, len(values))
+	for i, value := range values {
+		valuations[i] = ledger.Valuation{Date: value.Date, Value: value.Value}
+	}
+	return valuations
+}
+
+// DailyRisk computes risk metrics from the daily values reconstructed between the snapshots
+// with the ledger's transactions (see DailyValues), net of its deposits and withdrawals, with
+// beta and correlation to the stored Bitcoin closes
+func (a *Analyzer) DailyRisk(snapshots []*models.Portfolio, transactions []models.Transaction, opts ledger.RiskOptions) (*ledger.RiskMetrics, error) {
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no portfolio snapshots")
+	}
+	end := snapshots[0].Date
+	for _, snapshot := range snapshots {
+		if snapshot.Date.After(end) {
+			end = snapshot.Date
+		}
+	}
+	values, err := a.DailyValues(snapshots, transactions, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) < 3 {
+		return nil, fmt.Errorf("the snapshots span %d days; risk metrics need at least 3", len(values))
+	}
+
+	var bitcoin []ledger.Valuation
+	if prices, err := a.Prices.GetPrices(repository.BitcoinSymbol, values[0].Date.AddDate(0, 0, -7), end); err == nil {
+		for _, price := range prices {
+			bitcoin = append(bitcoin, ledger.Valuation{Date: price.Date, Value: price.Close})
+		}
+	}
+	periods := ledger.Periods(Valuations(values), transactions)
+	return ledger.CalculateRisk(periods, bitcoin, opts), nil
+}
@@ -2,8 +2,11 @@ package analyzer
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
 )
 
 // RebalancingRule represents a single row in the dynamic rebalancing table
@@ -19,6 +22,9 @@ type DynamicRebalancingTable struct {
 	config *config.RebalancingConfig
 }
 
+// RuleSymbol is the treasury company whose mNAV the rebalancing table's thresholds are set on
+const RuleSymbol = "MSTR"
+
 // NewDynamicRebalancingTable creates the rebalancing table from configuration
 func NewDynamicRebalancingTable() (*DynamicRebalancingTable, error) {
 	// Load configuration from CSV/JSON
@@ -27,6 +33,11 @@ func NewDynamicRebalancingTable() (*DynamicRebalancingTable, error) {
 		return nil, fmt.Errorf("failed to load rebalancing configuration: %w", err)
 	}
 
+	return NewDynamicRebalancingTableFromConfig(rebalanceConfig)
+}
+
+// NewDynamicRebalancingTableFromConfig creates the rebalancing table from an already loaded configuration
+func NewDynamicRebalancingTableFromConfig(rebalanceConfig *config.RebalancingConfig) (*DynamicRebalancingTable, error) {
 	// Validate configuration
 	if err := rebalanceConfig.ValidateConfig(); err != nil {
 		return nil, fmt.Errorf("invalid rebalancing configuration: %w", err)
@@ -53,6 +64,14 @@ func (dt *DynamicRebalancingTable) GetConfigSummary() string {
 	return dt.config.GetSummary()
 }
 
+// Tolerance returns the band around the target ratio within which no trade is needed
+func (dt *DynamicRebalancingTable) Tolerance() float64 {
+	if dt.config == nil {
+		return config.DefaultRebalancingTolerance
+	}
+	return dt.config.GetTolerance()
+}
+
 // GetTargetRatio determines the target Bitcoin:MSTR ratio based on current mNAV and transition thresholds
 func (dt *DynamicRebalancingTable) GetTargetRatio(currentMNAV float64) (float64, string, error) {
 	// First, check if mNAV falls within any ratio's Down-Up range
@@ -107,26 +126,157 @@ func (dt *DynamicRebalancingTable) GetTargetRatio(currentMNAV float64) (float64,
 		fmt.Sprintf("mNAV %.4f - fallback to conservative ratio", currentMNAV), nil
 }
 
-// RebalanceRecommendation contains the recommended portfolio adjustments
+// ruleIndex returns the index of the rule with the given target ratio, or -1
+func (dt *DynamicRebalancingTable) ruleIndex(ratio float64) int {
+	for i, rule := range dt.Rules {
+		if rule.TargetRatio == ratio {
+			return i
+		}
+	}
+	return -1
+}
+
+// NextTargetRatio evaluates one step of the hysteresis state machine. The active ratio
+// is kept while mNAV stays inside its rule's Down-Up band. Leaving the band moves to the
+// nearest rule in that direction whose band contains mNAV, or across a gap to the
+// nearest rule on that side. With no active rule (0 or not in the table) the stateless
+// GetTargetRatio picks the starting rule.
+func (dt *DynamicRebalancingTable) NextTargetRatio(activeRatio, currentMNAV float64) (float64, string) {
+	i := dt.ruleIndex(activeRatio)
+	if i < 0 {
+		ratio, explanation, _ := dt.GetTargetRatio(currentMNAV)
+		return ratio, explanation
+	}
+
+	rule := dt.Rules[i]
+	if currentMNAV >= rule.DownThreshold && currentMNAV <= rule.UpThreshold {
+		return rule.TargetRatio,
+			fmt.Sprintf("mNAV %.4f within active band [%.4f - %.4f] - hold %v:1 ratio",
+				currentMNAV, rule.DownThreshold, rule.UpThreshold, rule.TargetRatio)
+	}
+
+	// Above the band: move toward lower ratios (less MSTR)
+	if currentMNAV > rule.UpThreshold {
+		next := -1
+		for j := i + 1; j < len(dt.Rules); j++ {
+			if currentMNAV >= dt.Rules[j].DownThreshold && currentMNAV <= dt.Rules[j].UpThreshold {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			for j := i + 1; j < len(dt.Rules); j++ {
+				if currentMNAV >= dt.Rules[j].DownThreshold {
+					next = j
+				}
+			}
+		}
+		if next < 0 {
+			if i == len(dt.Rules)-1 {
+				return rule.TargetRatio,
+					fmt.Sprintf("mNAV %.4f above %.4f - already at minimum MSTR ratio %v:1",
+						currentMNAV, rule.UpThreshold, rule.TargetRatio)
+			}
+			next = i + 1
+		}
+		return dt.Rules[next].TargetRatio,
+			fmt.Sprintf("mNAV %.4f > %.4f - transition from %v:1 to %v:1 (sell MSTR)",
+				currentMNAV, rule.UpThreshold, rule.TargetRatio, dt.Rules[next].TargetRatio)
+	}
+
+	// Below the band: move toward higher ratios (more MSTR)
+	next := -1
+	for j := i - 1; j >= 0; j-- {
+		if currentMNAV >= dt.Rules[j].DownThreshold && currentMNAV <= dt.Rules[j].UpThreshold {
+			next = j
+			break
+		}
+	}
+	if next < 0 {
+		for j := i - 1; j >= 0; j-- {
+			if currentMNAV <= dt.Rules[j].UpThreshold {
+				next = j
+			}
+		}
+	}
+	if next < 0 {
+		if i == 0 {
+			return rule.TargetRatio,
+				fmt.Sprintf("mNAV %.4f below %.4f - already at maximum MSTR ratio %v:1",
+					currentMNAV, rule.DownThreshold, rule.TargetRatio)
+		}
+		next = i - 1
+	}
+	return dt.Rules[next].TargetRatio,
+		fmt.Sprintf("mNAV %.4f < %.4f - transition from %v:1 to %v:1 (buy MSTR)",
+			currentMNAV, rule.DownThreshold, rule.TargetRatio, dt.Rules[next].TargetRatio)
+}
+
+// Advance moves the persisted state to the mNAV observed at date, recording a
+// transition when the active rule changes. It returns the transition, or nil.
+func (dt *DynamicRebalancingTable) Advance(state *models.RebalancingState, currentMNAV float64, date time.Time) (*models.RebalancingTransition, string) {
+	ratio, explanation := dt.NextTargetRatio(state.ActiveRatio, currentMNAV)
+	state.LastMNAV = currentMNAV
+	state.UpdatedAt = date
+
+	var transition *models.RebalancingTransition
+	if ratio != state.ActiveRatio || state.ActiveSince.IsZero() {
+		reason := explanation
+		if state.ActiveRatio != 0 && dt.ruleIndex(state.ActiveRatio) < 0 {
+			reason = fmt.Sprintf("active %v:1 rule no longer in the table - %s", state.ActiveRatio, explanation)
+		}
+		state.Transitions = append(state.Transitions, models.RebalancingTransition{
+			Date:      date,
+			MNAV:      currentMNAV,
+			FromRatio: state.ActiveRatio,
+			ToRatio:   ratio,
+			Reason:    reason,
+		})
+		transition = state.LastTransition()
+		state.ActiveRatio = ratio
+		state.ActiveSince = date
+	}
+
+	rule := dt.Rules[dt.ruleIndex(ratio)]
+	state.DownThreshold = rule.DownThreshold
+	state.UpThreshold = rule.UpThreshold
+	return transition, explanation
+}
+
+// RebalanceRecommendation contains the recommended portfolio adjustments between the
+// spot BTC proxy and BTC treasury equity classes
 type RebalanceRecommendation struct {
 	CurrentRatio      float64
 	TargetRatio       float64
 	IsWellBalanced    bool
 	RecommendedAction string
-	FBTCAction        string // "BUY" or "SELL"
-	FBTCShares        float64
-	FBTCValue         float64
-	MSTRAction        string // "BUY" or "SELL"
-	MSTRShares        float64
-	MSTRValue         float64
+	SpotSymbol        string // Spot BTC proxy traded
+	SpotAction        string // "BUY" or "SELL"
+	SpotShares        float64
+	SpotValue         float64
+	TreasurySymbol    string // BTC treasury equity traded
+	TreasuryAction    string // "BUY" or "SELL"
+	TreasuryShares    float64
+	TreasuryValue     float64
 	Explanation       string
+
+	// Per-account trades (see Analyzer.PlanAccountTrades); the class-level trades above assume
+	// the whole portfolio is one account
+	Trades        []models.RecommendedTrade
+	Warnings      []string
+	AchievedRatio float64 // Ratio the per-account trades reach, short of the target when constrained
+
+	// Deposit or withdrawal the trades invest or raise (see Analyzer.PlanCashTrades)
+	Cash            float64
+	Remaining       float64 // Sell-and-buy still needed after the cash trades; positive from spot into treasury equity
+	DepositToTarget float64 // Smallest deposit that reaches the target without selling
 }
 
-// CalculateRebalanceRecommendation determines what trades are needed
+// CalculateRebalanceRecommendation determines what trades are needed at the class level;
+// Analyzer.PlanAccountTrades splits them across accounts
 func (dt *DynamicRebalancingTable) CalculateRebalanceRecommendation(
 	currentMNAV float64,
-	fbtcValue, mstrValue float64,
-	fbtcPrice, mstrPrice float64,
+	spot, treasury ClassHolding,
 ) (*RebalanceRecommendation, error) {
 
 	// Get target ratio based on current mNAV
@@ -135,17 +285,41 @@ func (dt *DynamicRebalancingTable) CalculateRebalanceRecommendation(
 		return nil, err
 	}
 
-	// Calculate current ratio (Bitcoin:MSTR)
-	currentRatio := fbtcValue / mstrValue
+	return dt.recommendationForTarget(targetRatio, explanation, spot, treasury), nil
+}
+
+// CalculateStatefulRecommendation advances the state machine to currentMNAV and
+// determines the trades needed to reach the active rule's ratio
+func (dt *DynamicRebalancingTable) CalculateStatefulRecommendation(
+	state *models.RebalancingState,
+	currentMNAV float64,
+	date time.Time,
+	spot, treasury ClassHolding,
+) (*RebalanceRecommendation, *models.RebalancingTransition) {
+	transition, explanation := dt.Advance(state, currentMNAV, date)
+	return dt.recommendationForTarget(state.ActiveRatio, explanation, spot, treasury), transition
+}
 
-	// Define "well balanced" tolerance (within 5% of target)
-	tolerance := 0.05
+// recommendationForTarget determines the trades needed to reach targetRatio, trading the
+// largest holding of each class
+func (dt *DynamicRebalancingTable) recommendationForTarget(
+	targetRatio float64,
+	explanation string,
+	spot, treasury ClassHolding,
+) *RebalanceRecommendation {
+	// Calculate current ratio (spot BTC:treasury equity)
+	currentRatio := spot.Value / treasury.Value
+
+	// Define "well balanced" tolerance (within the table's band, 5% by default)
+	tolerance := dt.Tolerance()
 	ratioTolerance := targetRatio * tolerance
 
 	recommendation := &RebalanceRecommendation{
 		CurrentRatio:   currentRatio,
 		TargetRatio:    targetRatio,
 		IsWellBalanced: false,
+		SpotSymbol:     spot.Symbol,
+		TreasurySymbol: treasury.Symbol,
 		Explanation:    explanation,
 	}
 
@@ -153,41 +327,129 @@ func (dt *DynamicRebalancingTable) CalculateRebalanceRecommendation(
 	if currentRatio >= (targetRatio-ratioTolerance) && currentRatio <= (targetRatio+ratioTolerance) {
 		recommendation.IsWellBalanced = true
 		recommendation.RecommendedAction = "HOLD - Portfolio is well balanced"
-		return recommendation, nil
+		return recommendation
 	}
 
 	// Calculate total Bitcoin-related value
-	totalBitcoinValue := fbtcValue + mstrValue
+	totalBitcoinValue := spot.Value + treasury.Value
 
 	// Calculate target allocations
-	targetFBTCValue := totalBitcoinValue * (targetRatio / (targetRatio + 1))
-	targetMSTRValue := totalBitcoinValue * (1 / (targetRatio + 1))
+	targetSpotValue := totalBitcoinValue * (targetRatio / (targetRatio + 1))
+	targetTreasuryValue := totalBitcoinValue * (1 / (targetRatio + 1))
 
 	// Calculate differences
-	fbtcDifference := targetFBTCValue - fbtcValue
-	mstrDifference := targetMSTRValue - mstrValue
+	spotDifference := targetSpotValue - spot.Value
+	treasuryDifference := targetTreasuryValue - treasury.Value
 
 	if currentRatio > targetRatio {
-		// Too much FBTC, need more MSTR
-		recommendation.RecommendedAction = "REBALANCE - Reduce FBTC, Increase MSTR"
-		recommendation.FBTCAction = "SELL"
-		recommendation.FBTCShares = -fbtcDifference / fbtcPrice
-		recommendation.FBTCValue = -fbtcDifference
-		recommendation.MSTRAction = "BUY"
-		recommendation.MSTRShares = mstrDifference / mstrPrice
-		recommendation.MSTRValue = mstrDifference
+		// Too much spot, need more treasury equity
+		recommendation.RecommendedAction = fmt.Sprintf("REBALANCE - Reduce %s, Increase %s", spot.Symbol, treasury.Symbol)
+		recommendation.SpotAction = "SELL"
+		recommendation.SpotShares = shares(-spotDifference, spot.Price)
+		recommendation.SpotValue = -spotDifference
+		recommendation.TreasuryAction = "BUY"
+		recommendation.TreasuryShares = shares(treasuryDifference, treasury.Price)
+		recommendation.TreasuryValue = treasuryDifference
+	} else {
+		// Too much treasury equity, need more spot
+		recommendation.RecommendedAction = fmt.Sprintf("REBALANCE - Increase %s, Reduce %s", spot.Symbol, treasury.Symbol)
+		recommendation.SpotAction = "BUY"
+		recommendation.SpotShares = shares(spotDifference, spot.Price)
+		recommendation.SpotValue = spotDifference
+		recommendation.TreasuryAction = "SELL"
+		recommendation.TreasuryShares = shares(-treasuryDifference, treasury.Price)
+		recommendation.TreasuryValue = -treasuryDifference
+	}
+
+	return recommendation
+}
+
+// PlanAccountTrades adds the per-account trades that carry out a recommendation within the
+// account constraints, and returns the full plan with its tax impact
+func (a *Analyzer) PlanAccountTrades(portfolio *models.Portfolio, rec *RebalanceRecommendation) *models.RebalanceRecommendation {
+	if rec.IsWellBalanced {
+		return nil
+	}
+	plan := a.CalculateRebalance(portfolio, rec.TargetRatio)
+	rec.Trades = plan.Trades
+	rec.Warnings = plan.Warnings
+	rec.AchievedRatio = plan.NewAllocation.SpotTreasuryRatio
+	return plan
+}
+
+// PlanCashTrades replaces a recommendation's trades with the per-account trades that invest a
+// deposit (positive cash) or raise a withdrawal (negative cash) toward its target ratio without
+// selling to buy, and returns the full plan with its tax impact
+func (a *Analyzer) PlanCashTrades(portfolio *models.Portfolio, rec *RebalanceRecommendation, cash float64, account string) *models.RebalanceRecommendation {
+	plan := a.CalculateCashRebalance(portfolio, rec.TargetRatio, cash, account)
+	rec.IsWellBalanced = false
+	if cash > 0 {
+		rec.RecommendedAction = fmt.Sprintf("DEPOSIT - Invest %.2f toward the target without selling", cash)
 	} else {
-		// Too much MSTR, need more FBTC
-		recommendation.RecommendedAction = "REBALANCE - Increase FBTC, Reduce MSTR"
-		recommendation.FBTCAction = "BUY"
-		recommendation.FBTCShares = fbtcDifference / fbtcPrice
-		recommendation.FBTCValue = fbtcDifference
-		recommendation.MSTRAction = "SELL"
-		recommendation.MSTRShares = -mstrDifference / mstrPrice
-		recommendation.MSTRValue = -mstrDifference
+		rec.RecommendedAction = fmt.Sprintf("WITHDRAW - Raise %.2f toward the target without buying", -cash)
+	}
+	rec.Trades = plan.Trades
+	rec.Warnings = plan.Warnings
+	rec.AchievedRatio = plan.NewAllocation.SpotTreasuryRatio
+	rec.Cash = cash
+	rec.Remaining = plan.Remaining
+	rec.DepositToTarget = plan.DepositToTarget
+	return plan
+}
+
+// DCATranche is one purchase of a dollar-cost averaging schedule
+type DCATranche struct {
+	Period      int
+	MNAV        float64 // Expected mNAV when the tranche is bought
+	TargetRatio float64 // Ratio of the rule active at MNAV
+	Amount      float64
+	Spot        float64 // Invested in the spot BTC class
+	Treasury    float64 // Invested in the BTC treasury equity class
+	Ratio       float64 // Spot BTC:treasury ratio after the tranche
+}
+
+// DCASchedule spreads a deposit of total over equal tranches, one per period. Each tranche is
+// split between the classes by CashSplit toward the ratio of the rule active at that period's
+// mNAV, following the table's hysteresis from activeRatio. mnavs lists the expected mNAV of each
+// period; the last one holds for the periods after it. Prices are assumed constant.
+func (dt *DynamicRebalancingTable) DCASchedule(spot, treasury, activeRatio, total float64, periods int, mnavs []float64) []DCATranche {
+	if periods <= 0 || len(mnavs) == 0 {
+		return nil
 	}
+	amount := total / float64(periods)
+	tranches := make([]DCATranche, 0, periods)
+	for i := 0; i < periods; i++ {
+		mnav := mnavs[len(mnavs)-1]
+		if i < len(mnavs) {
+			mnav = mnavs[i]
+		}
+		activeRatio, _ = dt.NextTargetRatio(activeRatio, mnav)
+		spotChange, treasuryChange := CashSplit(spot, treasury, activeRatio, amount)
+		spot += spotChange
+		treasury += treasuryChange
+
+		tranche := DCATranche{
+			Period:      i + 1,
+			MNAV:        mnav,
+			TargetRatio: activeRatio,
+			Amount:      amount,
+			Spot:        spotChange,
+			Treasury:    treasuryChange,
+		}
+		if treasury > 0 {
+			tranche.Ratio = spot / treasury
+		}
+		tranches = append(tranches, tranche)
+	}
+	return tranches
+}
 
-	return recommendation, nil
+// shares converts a trade value into shares, or 0 without a price
+func shares(value, price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return value / price
 }
 
 // PrintRebalanceRecommendation formats and displays the recommendation
@@ -196,8 +458,8 @@ func (r *RebalanceRecommendation) Print() {
 	fmt.Printf("===============================\n\n")
 
 	fmt.Printf("📊 Ratio Analysis:\n")
-	fmt.Printf("   Current Bitcoin:MSTR Ratio: %.2f:1\n", r.CurrentRatio)
-	fmt.Printf("   Target Bitcoin:MSTR Ratio:  %.2f:1\n", r.TargetRatio)
+	fmt.Printf("   Current Spot BTC:Treasury Ratio: %.2f:1\n", r.CurrentRatio)
+	fmt.Printf("   Target Spot BTC:Treasury Ratio:  %.2f:1\n", r.TargetRatio)
 	fmt.Printf("   %s\n\n", r.Explanation)
 
 	if r.IsWellBalanced {
@@ -211,19 +473,57 @@ func (r *RebalanceRecommendation) Print() {
 	fmt.Printf("   %s\n\n", r.RecommendedAction)
 
 	fmt.Printf("💱 Recommended Trades:\n")
-	if r.FBTCAction == "BUY" {
-		fmt.Printf("   📈 BUY %.2f shares of FBTC (~$%.2f)\n", r.FBTCShares, r.FBTCValue)
+	if len(r.Trades) > 0 || r.Cash != 0 {
+		r.printAccountTrades()
 	} else {
-		fmt.Printf("   📉 SELL %.2f shares of FBTC (~$%.2f)\n", r.FBTCShares, -r.FBTCValue)
-	}
+		if r.SpotAction == "BUY" {
+			fmt.Printf("   📈 BUY %.2f shares of %s (~$%.2f)\n", r.SpotShares, r.SpotSymbol, r.SpotValue)
+		} else {
+			fmt.Printf("   📉 SELL %.2f shares of %s (~$%.2f)\n", r.SpotShares, r.SpotSymbol, r.SpotValue)
+		}
 
-	if r.MSTRAction == "BUY" {
-		fmt.Printf("   📈 BUY %.2f shares of MSTR (~$%.2f)\n", r.MSTRShares, r.MSTRValue)
-	} else {
-		fmt.Printf("   📉 SELL %.2f shares of MSTR (~$%.2f)\n", r.MSTRShares, -r.MSTRValue)
+		if r.TreasuryAction == "BUY" {
+			fmt.Printf("   📈 BUY %.2f shares of %s (~$%.2f)\n", r.TreasuryShares, r.TreasurySymbol, r.TreasuryValue)
+		} else {
+			fmt.Printf("   📉 SELL %.2f shares of %s (~$%.2f)\n", r.TreasuryShares, r.TreasurySymbol, r.TreasuryValue)
+		}
 	}
 
+	newRatio := r.TargetRatio
+	if len(r.Trades) > 0 || r.Cash != 0 {
+		newRatio = r.AchievedRatio
+	}
 	fmt.Printf("\n🎯 After Rebalancing:\n")
-	fmt.Printf("   New Bitcoin:MSTR Ratio: %.2f:1\n", r.TargetRatio)
+	fmt.Printf("   New Spot BTC:Treasury Ratio: %.2f:1\n", newRatio)
+	if r.Remaining != 0 && r.Cash != 0 {
+		direction := "spot BTC into treasury equity"
+		if r.Remaining < 0 {
+			direction = "treasury equity into spot BTC"
+		}
+		fmt.Printf("   Partial rebalance: moving another $%.2f from %s reaches %.2f:1\n", math.Abs(r.Remaining), direction, r.TargetRatio)
+		if r.DepositToTarget > 0 {
+			fmt.Printf("   A deposit of $%.2f would reach it without selling\n", r.DepositToTarget)
+		}
+	}
 	fmt.Printf("   Portfolio optimized for current mNAV level\n\n")
 }
+
+// printAccountTrades lists the per-account trades, grouped by account
+func (r *RebalanceRecommendation) printAccountTrades() {
+	account := ""
+	for _, trade := range r.Trades {
+		if trade.Account != account {
+			account = trade.Account
+			fmt.Printf("   🏦 %s (%s)\n", models.MaskAccount(trade.Account), trade.AccountType)
+		}
+		if trade.Action == "BUY" {
+			fmt.Printf("      📈 BUY %.2f shares of %s (~$%.2f)\n", trade.Shares, trade.Symbol, trade.EstimatedValue)
+		} else {
+			fmt.Printf("      📉 SELL %.2f shares of %s (~$%.2f)\n", trade.Shares, trade.Symbol, trade.EstimatedValue)
+		}
+	}
+	fmt.Printf("   %d trades\n", len(r.Trades))
+	for _, warning := range r.Warnings {
+		fmt.Printf("   ⚠️  %s\n", warning)
+	}
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+func TestAdvanceHoldsRatioInsideActiveBand(t *testing.T) {
+	table := testRebalancingTable(t) // 3:1 for mNAV 0-2, 1:1 for mNAV 1.5-10
+	state := &models.RebalancingState{}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		mnav      float64
+		ratio     float64
+		stateless float64
+	}{
+		{1.8, 3, 3},
+		{1.9, 3, 3},
+		{2.1, 1, 1}, // Leaves the 3:1 band upward
+		{1.8, 1, 3}, // Inside the overlap: hysteresis keeps 1:1 where the stateless lookup says 3:1
+		{1.4, 3, 3}, // Leaves the 1:1 band downward
+	}
+	for i, step := range steps {
+		table.Advance(state, step.mnav, day.AddDate(0, 0, i))
+		if state.ActiveRatio != step.ratio {
+			t.Errorf("step %d (mNAV %.2f): expected %v:1, got %v:1", i, step.mnav, step.ratio, state.ActiveRatio)
+		}
+		if stateless, _, _ := table.GetTargetRatio(step.mnav); stateless != step.stateless {
+			t.Errorf("step %d: expected stateless %v:1, got %v:1", i, step.stateless, stateless)
+		}
+	}
+
+	if len(state.Transitions) != 3 {
+		t.Fatalf("expected start, 3→1 and 1→3 transitions, got %+v", state.Transitions)
+	}
+	last := state.LastTransition()
+	if last.FromRatio != 1 || last.ToRatio != 3 || !last.Date.Equal(day.AddDate(0, 0, 4)) {
+		t.Errorf("unexpected last transition %+v", last)
+	}
+	if state.DownThreshold != 0 || state.UpThreshold != 2 || !state.ActiveSince.Equal(last.Date) {
+		t.Errorf("expected the 3:1 band since the last transition, got %+v", state)
+	}
+}
+
+func TestNextTargetRatioCrossesGaps(t *testing.T) {
+	table, err := NewDynamicRebalancingTableFromConfig(&config.RebalancingConfig{Rules: []config.RebalancingRule{
+		{MinThreshold: 0, MaxThreshold: 1, TargetRatio: 4},
+		{MinThreshold: 2, MaxThreshold: 3, TargetRatio: 2},
+		{MinThreshold: 4, MaxThreshold: 10, TargetRatio: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		active, mnav, want float64
+	}{
+		{4, 3.5, 2}, // Above 4:1, past 2:1's floor but short of 1:1's
+		{4, 12, 1},  // Beyond every band
+		{1, 1.5, 2}, // Below 1:1, down to the nearest rule whose ceiling was crossed
+		{1, 0.5, 4}, // Inside 4:1's band
+		{4, -1, 4},  // Already at the maximum ratio
+		{0, 2.5, 2}, // No active rule: stateless lookup
+		{7, 2.5, 2}, // Active rule no longer in the table
+	} {
+		if got, _ := table.NextTargetRatio(tc.active, tc.mnav); got != tc.want {
+			t.Errorf("active %v:1 at mNAV %.2f: expected %v:1, got %v:1", tc.active, tc.mnav, tc.want, got)
+		}
+	}
+}
+
+func TestDCASchedule(t *testing.T) {
+	table, err := NewDynamicRebalancingTableFromConfig(&config.RebalancingConfig{Rules: []config.RebalancingRule{
+		{MinThreshold: 0, MaxThreshold: 2, TargetRatio: 3},
+		{MinThreshold: 1.5, MaxThreshold: 10, TargetRatio: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3000:1000 at 3:1; mNAV rises past 2 in the second period, so later tranches buy treasury
+	// equity toward 1:1 and the last mNAV holds for the rest
+	schedule := table.DCASchedule(3000, 1000, 3, 3000, 3, []float64{1.8, 2.5})
+	if len(schedule) != 3 {
+		t.Fatalf("expected 3 tranches, got %d", len(schedule))
+	}
+	first, second, third := schedule[0], schedule[1], schedule[2]
+	if first.TargetRatio != 3 || first.Spot != 750 || first.Treasury != 250 || first.Ratio != 3 {
+		t.Errorf("expected the first tranche split 3:1, got %+v", first)
+	}
+	if second.TargetRatio != 1 || second.Treasury != 1000 || third.MNAV != 2.5 || third.Treasury != 1000 {
+		t.Errorf("expected the later tranches all in treasury equity, got %+v and %+v", second, third)
+	}
+	if third.Ratio != 3750.0/3250.0 {
+		t.Errorf("expected 3750:3250 after the schedule, got %.4f", third.Ratio)
+	}
+}
@@ -0,0 +1,155 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/metrics"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+// SatsPerBTC is the number of satoshis in one Bitcoin
+const SatsPerBTC = 1e8
+
+// treasuryCompany returns the company behind a treasury equity symbol, from the registry or,
+// for unregistered companies, from its transactions in the store
+func (a *Analyzer) treasuryCompany(symbol string) (config.CompanyData, bool) {
+	if a.Companies != nil {
+		if company, ok := a.Companies.GetCompanyBySymbol(symbol); ok {
+			return company, true
+		}
+	}
+	if a.Transactions != nil {
+		if txs, err := a.Transactions.LoadBTCTransactionsAsOf(symbol, a.KnownAt); err == nil && len(txs) > 0 {
+			return config.CompanyData{Symbol: symbol}, true
+		}
+	}
+	return config.CompanyData{}, false
+}
+
+// bitcoinPrice returns the latest Bitcoin close in the week up to date, in currency
+func (a *Analyzer) bitcoinPrice(date time.Time, currency string) (float64, bool) {
+	if a.Prices == nil {
+		return 0, false
+	}
+	prices, err := a.Prices.GetPrices(repository.BitcoinSymbol, date.AddDate(0, 0, -7), date)
+	if err != nil || len(prices) == 0 {
+		return 0, false
+	}
+	price, err := a.FX.Convert(prices[len(prices)-1].Close, sharedmodels.USD, currency, date)
+	if err != nil || price <= 0 {
+		return 0, false
+	}
+	return price, true
+}
+
+// lookThrough computes the Bitcoin owned through positions restated in the portfolio
+// currency, or nil when there is no Bitcoin price for the snapshot date. Treasury company
+// shares own quantity × the company's Bitcoin per share on the snapshot date and are levered
+// by enterprise value over market cap; everything else owns value × btc_factor unlevered.
+func (a *Analyzer) lookThrough(portfolio *models.Portfolio, positions []models.Position) *models.LookThroughExposure {
+	currency := sharedmodels.CurrencyCode(portfolio.Currency)
+	price, ok := a.bitcoinPrice(portfolio.Date, currency)
+	if !ok {
+		return nil
+	}
+	classes := a.classification()
+
+	// Sum each symbol across accounts
+	bySymbol := make(map[string]*models.PositionExposure)
+	var order []string
+	for _, position := range positions {
+		exposure, ok := bySymbol[position.Symbol]
+		if !ok {
+			exposure = &models.PositionExposure{Symbol: position.Symbol, Class: classes.Classify(position.Symbol).Class}
+			bySymbol[position.Symbol] = exposure
+			order = append(order, position.Symbol)
+		}
+		exposure.Quantity += position.Quantity
+		exposure.Value += position.CurrentValue
+	}
+
+	result := &models.LookThroughExposure{BitcoinPrice: price}
+	var premiumBase float64
+	for _, symbol := range order {
+		exposure := bySymbol[symbol]
+		exposure.Leverage = 1
+		exposure.Source = "btc_factor"
+		exposure.BTC = exposure.Value * classes.Classify(symbol).BTCFactor / price
+
+		if exposure.Class == config.AssetClassBTCTreasury {
+			a.lookThroughCompany(exposure, portfolio.Date, currency, price)
+		}
+		if exposure.BTC <= 0 {
+			continue
+		}
+		if exposure.BTCPerShare > 0 {
+			exposure.Premium = exposure.Value - exposure.BTC*price
+			exposure.EffectiveBTC = exposure.Value * exposure.Leverage / price
+			result.PremiumPaid += exposure.Premium
+			premiumBase += exposure.BTC * price
+		} else {
+			exposure.EffectiveBTC = exposure.BTC
+		}
+		result.BTC += exposure.BTC
+		result.EffectiveBTC += exposure.EffectiveBTC
+		result.Positions = append(result.Positions, *exposure)
+	}
+
+	result.Sats = int64(math.Round(result.BTC * SatsPerBTC))
+	if premiumBase > 0 {
+		result.PremiumPercent = result.PremiumPaid / premiumBase * 100
+	}
+	sort.SliceStable(result.Positions, func(i, j int) bool {
+		return result.Positions[i].BTC > result.Positions[j].BTC
+	})
+	return result
+}
+
+// companyFacts returns a company's Bitcoin purchases and shares outstanding as known at KnownAt
+func (a *Analyzer) companyFacts(symbol string) ([]sharedmodels.BitcoinTransaction, []sharedmodels.SharesOutstandingRecord) {
+	var txs []sharedmodels.BitcoinTransaction
+	var shares []sharedmodels.SharesOutstandingRecord
+	if a.Transactions != nil {
+		txs, _ = a.Transactions.LoadBTCTransactionsAsOf(symbol, a.KnownAt)
+	}
+	if a.Shares != nil {
+		shares, _ = a.Shares.LoadSharesHistoryAsOf(symbol, a.KnownAt)
+	}
+	return txs, shares
+}
+
+// lookThroughCompany replaces a treasury equity exposure's factor-based Bitcoin with the
+// company's Bitcoin per share on date. Symbols without company data keep their factor.
+func (a *Analyzer) lookThroughCompany(exposure *models.PositionExposure, date time.Time, currency string, bitcoinPrice float64) {
+	company, ok := a.treasuryCompany(strings.ToUpper(exposure.Symbol))
+	if !ok || exposure.Quantity <= 0 {
+		return
+	}
+
+	txs, shares := a.companyFacts(company.Symbol)
+	holdings, sharesOutstanding := metrics.HoldingsAt(company, txs, shares, date)
+	if holdings <= 0 || sharesOutstanding <= 0 {
+		return
+	}
+
+	exposure.BTCPerShare = holdings / sharesOutstanding
+	exposure.BTC = exposure.Quantity * exposure.BTCPerShare
+	exposure.Source = "registry"
+	if len(txs) > 0 {
+		exposure.Source = "filings"
+	}
+
+	// Debt and preferreds rank ahead of the shares, so a move in the enterprise value moves
+	// the shares by EV / market cap times as much
+	claims, err := a.FX.Convert(company.TotalDebt+company.PreferredEquity-company.Cash, company.TradingCurrency(), currency, date)
+	marketCap := exposure.Value / exposure.Quantity * sharesOutstanding
+	if err == nil && marketCap > 0 && marketCap+claims > 0 {
+		exposure.Leverage = (marketCap + claims) / marketCap
+	}
+}
@@ -0,0 +1,382 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+)
+
+// Optimizer search methods and objectives
+const (
+	SearchGrid   = "grid"
+	SearchRandom = "random"
+
+	ObjectiveCAGR   = "cagr"
+	ObjectiveCalmar = "calmar"
+)
+
+// OptimizerOptions configures a rule-table search. Candidates scale the base table's
+// thresholds and ratios within the given ranges and pick a tolerance band.
+type OptimizerOptions struct {
+	Method         string          `json:"method"`    // grid or random
+	Objective      string          `json:"objective"` // cagr or calmar (CAGR over max drawdown)
+	Steps          int             `json:"steps"`     // Grid points per dimension
+	Samples        int             `json:"samples"`   // Random candidates
+	Seed           int64           `json:"seed"`
+	ThresholdScale [2]float64      `json:"threshold_scale"` // Multiplier range for Min/Max thresholds
+	RatioScale     [2]float64      `json:"ratio_scale"`     // Multiplier range for target ratios
+	Tolerance      [2]float64      `json:"tolerance"`       // Tolerance band range
+	Folds          int             `json:"folds"`           // Walk-forward folds
+	Top            int             `json:"top"`             // Candidates to keep
+	Backtest       BacktestOptions `json:"backtest"`        // Capital, costs and frequency; Tolerance comes from each candidate
+}
+
+// DefaultOptimizerOptions returns a 5×5×5 grid over ±20% thresholds, half to one and a
+// half times the ratios and 2-15% tolerance, validated over 4 walk-forward folds
+func DefaultOptimizerOptions() OptimizerOptions {
+	return OptimizerOptions{
+		Method:         SearchGrid,
+		Objective:      ObjectiveCalmar,
+		Steps:          5,
+		Samples:        200,
+		Seed:           1,
+		ThresholdScale: [2]float64{0.8, 1.2},
+		RatioScale:     [2]float64{0.5, 1.5},
+		Tolerance:      [2]float64{0.02, 0.15},
+		Folds:          4,
+		Top:            5,
+		Backtest:       DefaultBacktestOptions(),
+	}
+}
+
+// FoldResult is one candidate's performance on one walk-forward split
+type FoldResult struct {
+	TrainStart     time.Time     `json:"train_start"`
+	TrainEnd       time.Time     `json:"train_end"`
+	TestStart      time.Time     `json:"test_start"`
+	TestEnd        time.Time     `json:"test_end"`
+	TrainScore     float64       `json:"train_score"`
+	TestScore      float64       `json:"test_score"`
+	Test           BacktestStats `json:"test"`
+	TestTurnover   float64       `json:"test_annualized_turnover"`
+	TestTrades     int           `json:"test_trades"`
+	TestHODLBTC    BacktestStats `json:"test_hodl_bitcoin"`
+	TestHODLMSTR   BacktestStats `json:"test_hodl_mstr"`
+	CandidateLabel string        `json:"candidate,omitempty"` // Set on walk-forward selections
+}
+
+// OptimizerCandidate is one rule table with its in-sample and out-of-sample results. Its
+// scores come from the last fold: TrainScore over every segment but the last, TestScore
+// over the last segment, which no fold trains on.
+type OptimizerCandidate struct {
+	Label      string                    `json:"label"`
+	Config     *config.RebalancingConfig `json:"config"`
+	TrainScore float64                   `json:"train_score"` // Last fold's training window
+	TestScore  float64                   `json:"test_score"`  // Last fold's test segment
+	Folds      []FoldResult              `json:"folds"`
+}
+
+// MeanTestScore averages the candidate's test scores over every fold. Earlier test
+// segments are later folds' training data, so this is only out of sample for a table
+// that wasn't selected on them, such as the baseline.
+func (c OptimizerCandidate) MeanTestScore() float64 {
+	var mean float64
+	for _, f := range c.Folds {
+		mean += f.TestScore / float64(len(c.Folds))
+	}
+	return mean
+}
+
+// OptimizerResult is the outcome of a search
+type OptimizerResult struct {
+	Options   OptimizerOptions     `json:"options"`
+	StartDate time.Time            `json:"start_date"`
+	EndDate   time.Time            `json:"end_date"`
+	Evaluated int                  `json:"evaluated"`
+	Baseline  OptimizerCandidate   `json:"baseline"`
+	Top       []OptimizerCandidate `json:"top"` // Best by last-fold training score
+	// WalkForward holds, per fold, the out-of-sample result of the candidate that
+	// scored best in that fold's training window
+	WalkForward          []FoldResult `json:"walk_forward"`
+	WalkForwardTestScore float64      `json:"walk_forward_test_score"`
+}
+
+// walkForwardFold is one anchored train/test split
+type walkForwardFold struct {
+	train, test []BacktestPoint
+}
+
+// OptimizeRebalancingTable searches rule tables derived from base and validates them with
+// anchored walk-forward splits: the history is cut into Folds+1 equal segments, and fold k
+// trains on segments 1..k and tests on segment k+1. Top candidates are ranked by their
+// score on the last fold's training window, segments 1..Folds, so their test scores and
+// validation come only from segment Folds+1 and stay out of sample.
+func OptimizeRebalancingTable(base *config.RebalancingConfig, points []BacktestPoint, opts OptimizerOptions) (*OptimizerResult, error) {
+	if err := base.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid base table: %w", err)
+	}
+	if opts.Objective != ObjectiveCAGR && opts.Objective != ObjectiveCalmar {
+		return nil, fmt.Errorf("unknown objective %q (want cagr or calmar)", opts.Objective)
+	}
+	if opts.Folds < 1 {
+		return nil, fmt.Errorf("need at least 1 walk-forward fold")
+	}
+	if opts.Top < 1 {
+		opts.Top = 1
+	}
+
+	days, _ := usableBacktestPoints(points)
+	folds, err := walkForwardFolds(days, opts.Folds)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := optimizerCandidates(base, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OptimizerResult{
+		Options:   opts,
+		StartDate: days[0].Date,
+		EndDate:   days[len(days)-1].Date,
+		Evaluated: len(candidates),
+	}
+
+	baseline := OptimizerCandidate{Label: "current table", Config: base}
+	if err := evaluateCandidate(&baseline, folds, opts); err != nil {
+		return nil, fmt.Errorf("baseline: %w", err)
+	}
+	result.Baseline = baseline
+
+	best := make([]*OptimizerCandidate, len(folds))
+	for i := range candidates {
+		if err := evaluateCandidate(&candidates[i], folds, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", candidates[i].Label, err)
+		}
+		for f := range folds {
+			if best[f] == nil || candidates[i].Folds[f].TrainScore > best[f].Folds[f].TrainScore {
+				best[f] = &candidates[i]
+			}
+		}
+	}
+
+	for f, c := range best {
+		fold := c.Folds[f]
+		fold.CandidateLabel = c.Label
+		result.WalkForward = append(result.WalkForward, fold)
+		result.WalkForwardTestScore += fold.TestScore / float64(len(folds))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].TrainScore > candidates[j].TrainScore })
+	if len(candidates) > opts.Top {
+		candidates = candidates[:opts.Top]
+	}
+	for i := range candidates {
+		candidates[i].Config.Validation = candidateValidation(candidates[i], opts)
+	}
+	result.Top = candidates
+
+	return result, nil
+}
+
+// walkForwardFolds cuts days into folds+1 equal segments and returns the anchored splits
+func walkForwardFolds(days []BacktestPoint, folds int) ([]walkForwardFold, error) {
+	segment := len(days) / (folds + 1)
+	if segment < 2 {
+		return nil, fmt.Errorf("%d usable days is too short for %d walk-forward folds", len(days), folds)
+	}
+
+	splits := make([]walkForwardFold, folds)
+	for k := 1; k <= folds; k++ {
+		end := segment * (k + 1)
+		if k == folds {
+			end = len(days)
+		}
+		splits[k-1] = walkForwardFold{train: days[:segment*k], test: days[segment*k : end]}
+	}
+	return splits, nil
+}
+
+// optimizerCandidates builds the candidate tables for the configured search method
+func optimizerCandidates(base *config.RebalancingConfig, opts OptimizerOptions) ([]OptimizerCandidate, error) {
+	var candidates []OptimizerCandidate
+	switch opts.Method {
+	case SearchGrid:
+		if opts.Steps < 1 {
+			return nil, fmt.Errorf("grid search needs at least 1 step")
+		}
+		for _, ts := range linspace(opts.ThresholdScale, opts.Steps) {
+			for _, rs := range linspace(opts.RatioScale, opts.Steps) {
+				for _, tol := range linspace(opts.Tolerance, opts.Steps) {
+					cfg := scaledTable(base, func(int) (float64, float64, float64) { return ts, ts, rs }, tol)
+					candidates = append(candidates, OptimizerCandidate{
+						Label:  fmt.Sprintf("thresholds ×%.2f, ratios ×%.2f, tolerance ±%.1f%%", ts, rs, tol*100),
+						Config: cfg,
+					})
+				}
+			}
+		}
+
+	case SearchRandom:
+		if opts.Samples < 1 {
+			return nil, fmt.Errorf("random search needs at least 1 sample")
+		}
+		rng := rand.New(rand.NewSource(opts.Seed))
+		uniform := func(r [2]float64) float64 { return r[0] + rng.Float64()*(r[1]-r[0]) }
+		for attempts := 0; len(candidates) < opts.Samples && attempts < opts.Samples*100; attempts++ {
+			tol := uniform(opts.Tolerance)
+			cfg := scaledTable(base, func(int) (float64, float64, float64) {
+				return uniform(opts.ThresholdScale), uniform(opts.ThresholdScale), uniform(opts.RatioScale)
+			}, tol)
+			if cfg.ValidateConfig() != nil || !sameRuleOrder(base, cfg) {
+				continue
+			}
+			candidates = append(candidates, OptimizerCandidate{
+				Label:  fmt.Sprintf("random #%d, tolerance ±%.1f%%", len(candidates)+1, tol*100),
+				Config: cfg,
+			})
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no valid random candidates; widen the scale ranges")
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown search method %q (want grid or random)", opts.Method)
+	}
+	return candidates, nil
+}
+
+// scaledTable copies base, multiplying each rule's Min, Max and Ratio by the factors
+// returned for its index, rounded to the precision used in the CSV
+func scaledTable(base *config.RebalancingConfig, factors func(i int) (float64, float64, float64), tolerance float64) *config.RebalancingConfig {
+	cfg := &config.RebalancingConfig{
+		Version:   base.Version,
+		Source:    "optimizer",
+		Tolerance: roundTo(tolerance, 3),
+	}
+	for i, rule := range base.Rules {
+		minScale, maxScale, ratioScale := factors(i)
+		cfg.Rules = append(cfg.Rules, config.RebalancingRule{
+			MinThreshold: roundTo(rule.MinThreshold*minScale, 4),
+			MaxThreshold: roundTo(rule.MaxThreshold*maxScale, 4),
+			TargetRatio:  roundTo(rule.TargetRatio*ratioScale, 2),
+		})
+	}
+	return cfg
+}
+
+// sameRuleOrder reports whether adjacent rules keep the base table's ordering of
+// thresholds and ratios, so tiers don't swap places
+func sameRuleOrder(base, cfg *config.RebalancingConfig) bool {
+	order := func(a, b float64) int {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+	for i := 1; i < len(base.Rules); i++ {
+		b0, b1 := base.Rules[i-1], base.Rules[i]
+		c0, c1 := cfg.Rules[i-1], cfg.Rules[i]
+		if order(b0.MinThreshold, b1.MinThreshold) != order(c0.MinThreshold, c1.MinThreshold) ||
+			order(b0.MaxThreshold, b1.MaxThreshold) != order(c0.MaxThreshold, c1.MaxThreshold) ||
+			order(b0.TargetRatio, b1.TargetRatio) != order(c0.TargetRatio, c1.TargetRatio) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluateCandidate backtests a candidate on every fold's training and test windows
+func evaluateCandidate(c *OptimizerCandidate, folds []walkForwardFold, opts OptimizerOptions) error {
+	table, err := NewDynamicRebalancingTableFromConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	backtest := opts.Backtest
+	backtest.Tolerance = table.Tolerance()
+
+	c.Folds = make([]FoldResult, len(folds))
+	for i, fold := range folds {
+		train, err := table.Backtest(fold.train, backtest)
+		if err != nil {
+			return err
+		}
+		test, err := table.Backtest(fold.test, backtest)
+		if err != nil {
+			return err
+		}
+		c.Folds[i] = FoldResult{
+			TrainStart:   train.StartDate,
+			TrainEnd:     train.EndDate,
+			TestStart:    test.StartDate,
+			TestEnd:      test.EndDate,
+			TrainScore:   objectiveScore(opts.Objective, train.Strategy),
+			TestScore:    objectiveScore(opts.Objective, test.Strategy),
+			Test:         test.Strategy,
+			TestTurnover: test.AnnualizedTurnover,
+			TestTrades:   test.TradeCount,
+			TestHODLBTC:  test.HODLBitcoin,
+			TestHODLMSTR: test.HODLMSTR,
+		}
+	}
+
+	last := c.Folds[len(c.Folds)-1]
+	c.TrainScore, c.TestScore = last.TrainScore, last.TestScore
+	return nil
+}
+
+// objectiveScore scores a backtest: CAGR, or CAGR per point of maximum drawdown
+func objectiveScore(objective string, stats BacktestStats) float64 {
+	if objective == ObjectiveCalmar && stats.MaxDrawdownPct > 0 {
+		return stats.CAGRPct / stats.MaxDrawdownPct
+	}
+	return stats.CAGRPct
+}
+
+// candidateValidation records a candidate's results on the last fold's test segment, the
+// only one it wasn't ranked on
+func candidateValidation(c OptimizerCandidate, opts OptimizerOptions) *config.RebalancingValidation {
+	last := c.Folds[len(c.Folds)-1]
+	return &config.RebalancingValidation{
+		Method:             opts.Method,
+		Objective:          opts.Objective,
+		Candidate:          c.Label,
+		Folds:              len(c.Folds),
+		TrainScore:         last.TrainScore,
+		TestScore:          last.TestScore,
+		TestStart:          last.TestStart,
+		TestEnd:            last.TestEnd,
+		TestCAGRPct:        last.Test.CAGRPct,
+		TestMaxDrawdownPct: last.Test.MaxDrawdownPct,
+		TestTurnover:       last.TestTurnover,
+		HODLBitcoinCAGRPct: last.TestHODLBTC.CAGRPct,
+		HODLMSTRCAGRPct:    last.TestHODLMSTR.CAGRPct,
+		GeneratedAt:        time.Now(),
+	}
+}
+
+// linspace returns n evenly spaced values across r (its midpoint when n is 1)
+func linspace(r [2]float64, n int) []float64 {
+	if n == 1 {
+		return []float64{(r[0] + r[1]) / 2}
+	}
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = r[0] + (r[1]-r[0])*float64(i)/float64(n-1)
+	}
+	return values
+}
+
+// roundTo rounds v to the given number of decimal places
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+)
+
+func TestOptimizeRebalancingTableWalkForward(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	var points []BacktestPoint
+	for i := 0; i < 400; i++ {
+		mnav := 1.6 + math.Sin(float64(i)/20)
+		points = append(points, BacktestPoint{
+			Date:         start.AddDate(0, 0, i),
+			BitcoinPrice: 30000 * (1 + 0.2*math.Sin(float64(i)/45)),
+			MSTRPrice:    300 * mnav,
+			MNAV:         mnav,
+		})
+	}
+	base := &config.RebalancingConfig{Rules: []config.RebalancingRule{
+		{MinThreshold: 0, MaxThreshold: 1.5, TargetRatio: 4},
+		{MinThreshold: 1.2, MaxThreshold: 2, TargetRatio: 2},
+		{MinThreshold: 1.8, MaxThreshold: 10, TargetRatio: 1},
+	}}
+
+	opts := DefaultOptimizerOptions()
+	opts.Steps = 2
+	opts.Top = 3
+	result, err := OptimizeRebalancingTable(base, points, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Evaluated != 8 || len(result.Top) != 3 || len(result.WalkForward) != 4 {
+		t.Fatalf("expected 8 candidates, top 3 and 4 folds, got %d, %d and %d", result.Evaluated, len(result.Top), len(result.WalkForward))
+	}
+	if result.Top[0].TrainScore < result.Top[1].TrainScore {
+		t.Error("expected candidates ranked by training score")
+	}
+	for i, fold := range result.WalkForward {
+		if !fold.TrainEnd.Before(fold.TestStart) {
+			t.Errorf("fold %d: test window %s starts before training ends %s", i, fold.TestStart, fold.TrainEnd)
+		}
+	}
+	if v := result.Top[0].Config.Validation; v == nil || v.Folds != 4 || v.Candidate != result.Top[0].Label {
+		t.Errorf("expected validation stats on the top candidate, got %+v", v)
+	}
+
+	// Ranking and validation use only the last fold, so the validation never overlaps training
+	last := result.Top[0].Folds[3]
+	if v := result.Top[0].Config.Validation; v != nil && (!v.TestStart.Equal(last.TestStart) || v.TestScore != last.TestScore || !last.TrainEnd.Before(v.TestStart)) {
+		t.Errorf("expected validation on the held-out segment after %s, got %+v", last.TrainEnd, v)
+	}
+	if result.Top[0].TrainScore != last.TrainScore {
+		t.Errorf("expected the top candidate ranked on the last training window, got %.2f vs %.2f", result.Top[0].TrainScore, last.TrainScore)
+	}
+	if err := result.Top[0].Config.ValidateConfig(); err != nil {
+		t.Errorf("expected a valid candidate table: %v", err)
+	}
+
+	opts.Method = SearchRandom
+	opts.Samples = 10
+	result, err = OptimizeRebalancingTable(base, points, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range result.Top {
+		if !sameRuleOrder(base, c.Config) {
+			t.Errorf("random candidate %s reordered the tiers: %+v", c.Label, c.Config.Rules)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/metrics"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/repository"
+)
+
+// scaledPrices scales the prices of one symbol, so that a shocked portfolio's look-through
+// exposure values Bitcoin at the scenario's price
+type scaledPrices struct {
+	repository.PriceRepository
+	symbol string
+	factor float64
+}
+
+// GetPrices returns the stored prices, scaled for the shocked symbol
+func (p scaledPrices) GetPrices(symbol string, start, end time.Time) ([]repository.PricePoint, error) {
+	prices, err := p.PriceRepository.GetPrices(symbol, start, end)
+	if err != nil || symbol != p.symbol {
+		return prices, err
+	}
+	scaled := make([]repository.PricePoint, len(prices))
+	for i, price := range prices {
+		price.Open *= p.factor
+		price.High *= p.factor
+		price.Low *= p.factor
+		price.Close *= p.factor
+		scaled[i] = price
+	}
+	return scaled, nil
+}
+
+// ScenarioMNAVs returns the current mNAV of each treasury company held, from the portfolio's
+// look-through exposure
+func ScenarioMNAVs(portfolio *models.Portfolio) map[string]float64 {
+	mnavs := make(map[string]float64)
+	if lookThrough := portfolio.AssetAllocation.LookThrough; lookThrough != nil {
+		for _, position := range lookThrough.Positions {
+			if mnav := position.MNAV(); position.BTCPerShare > 0 && mnav > 0 {
+				mnavs[strings.ToUpper(position.Symbol)] = mnav
+			}
+		}
+	}
+	return mnavs
+}
+
+// priceChange returns the change in a symbol's price under a scenario. Spot BTC moves with
+// Bitcoin by its btc_factor and gold with gold. Treasury equity priced at mNAV × its Bitcoin
+// per share moves with Bitcoin and with its mNAV; symbols with a symbol_change take that.
+// Everything else, cash included, keeps its price.
+func (a *Analyzer) priceChange(symbol string, s config.Scenario, mnavs map[string]float64) float64 {
+	symbol = strings.ToUpper(symbol)
+	if change, ok := s.SymbolChange[symbol]; ok {
+		return change
+	}
+	class := a.classification().Classify(symbol)
+	switch class.Class {
+	case config.AssetClassSpotBTC:
+		return class.BTCFactor * s.BTCChange
+	case config.AssetClassGold:
+		return s.GoldChange
+	case config.AssetClassBTCTreasury:
+		if class.BTCFactor == 0 {
+			return 0 // Preferreds are claims on the treasury, not on the Bitcoin price
+		}
+		return (1+class.BTCFactor*s.BTCChange)*s.MNAVMultiple(symbol, mnavs[symbol]) - 1
+	}
+	return 0
+}
+
+// Shock returns a copy of the portfolio revalued under a scenario: every position's price moves
+// by its symbol's change (see priceChange), and the totals, allocation and look-through exposure
+// are recomputed with Bitcoin at the scenario's price. mnavs holds the current mNAV of the
+// treasury companies held (see ScenarioMNAVs).
+func (a *Analyzer) Shock(portfolio *models.Portfolio, s config.Scenario, mnavs map[string]float64) (*models.Portfolio, error) {
+	shocked := *portfolio
+	shocked.Positions = make([]models.Position, len(portfolio.Positions))
+	shocked.Accounts = nil
+	shocked.TotalValue, shocked.TotalCostBasis, shocked.TotalGainLoss, shocked.TotalGainLossPct = 0, 0, 0, 0
+	for i, position := range portfolio.Positions {
+		factor := 1 + a.priceChange(position.Symbol, s, mnavs)
+		position.TotalGainLoss += position.CurrentValue * (factor - 1)
+		position.LastPrice *= factor
+		position.CurrentValue *= factor
+		if position.CostBasisTotal > 0 {
+			position.TotalGainLossPct = position.TotalGainLoss / position.CostBasisTotal * 100
+		}
+		shocked.Positions[i] = position
+	}
+
+	scenario := *a
+	if a.Prices != nil {
+		scenario.Prices = scaledPrices{PriceRepository: a.Prices, symbol: repository.BitcoinSymbol, factor: 1 + s.BTCChange}
+	}
+	if err := scenario.calculateAggregations(&shocked); err != nil {
+		return nil, fmt.Errorf("failed to revalue portfolio under %s: %w", s.Name, err)
+	}
+	return &shocked, nil
+}
+
+// ScenarioResult is a portfolio revalued under a scenario and the rebalancing it calls for
+type ScenarioResult struct {
+	Scenario       config.Scenario
+	Portfolio      *models.Portfolio               // Revalued
+	ValueChange    float64                         // Change in total value
+	MNAV           float64                         // RuleSymbol's mNAV under the scenario
+	Recommendation *RebalanceRecommendation        // Nil without both spot BTC and treasury equity
+	Plan           *models.RebalanceRecommendation // Per-account trades; nil when well balanced
+}
+
+// RunScenario revalues a portfolio under a scenario, moves the table's rule from activeRatio
+// to the one for RuleSymbol's mNAV under the scenario, and plans the per-account trades that
+// reach its ratio. ruleMNAV is RuleSymbol's current mNAV, used when the portfolio's look-through
+// exposure has none.
+func (a *Analyzer) RunScenario(portfolio *models.Portfolio, s config.Scenario, table *DynamicRebalancingTable, activeRatio, ruleMNAV float64) (*ScenarioResult, error) {
+	mnavs := ScenarioMNAVs(portfolio)
+	if _, ok := mnavs[RuleSymbol]; !ok && ruleMNAV > 0 {
+		mnavs[RuleSymbol] = ruleMNAV
+	}
+	shocked, err := a.Shock(portfolio, s, mnavs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScenarioResult{
+		Scenario:    s,
+		Portfolio:   shocked,
+		ValueChange: shocked.TotalValue - portfolio.TotalValue,
+		MNAV:        s.MNAVFor(RuleSymbol, mnavs[RuleSymbol]),
+	}
+	spot := a.ClassHoldings(shocked, config.AssetClassSpotBTC)
+	treasury := a.ClassHoldings(shocked, config.AssetClassBTCTreasury)
+	if table == nil || result.MNAV <= 0 || spot.Value == 0 || treasury.Value == 0 {
+		return result, nil
+	}
+	ratio, explanation := table.NextTargetRatio(activeRatio, result.MNAV)
+	result.Recommendation = table.recommendationForTarget(ratio, explanation, spot, treasury)
+	result.Plan = a.PlanAccountTrades(shocked, result.Recommendation)
+	return result, nil
+}
+
+// priceOn returns the last close on or before date
+func priceOn(prices []repository.PricePoint, date time.Time) (float64, bool) {
+	i := sort.Search(len(prices), func(i int) bool { return prices[i].Date.After(date) })
+	if i == 0 {
+		return 0, false
+	}
+	return prices[i-1].Close, true
+}
+
+// mnavAt returns a treasury company's mNAV on date from stored prices and its holdings and
+// shares outstanding then
+func (a *Analyzer) mnavAt(symbol string, bitcoin []repository.PricePoint, date time.Time) (float64, bool) {
+	company, ok := a.treasuryCompany(symbol)
+	if !ok || a.Prices == nil {
+		return 0, false
+	}
+	prices, err := a.Prices.GetPrices(symbol, date.AddDate(0, 0, -7), date)
+	if err != nil {
+		return 0, false
+	}
+	price, ok := priceOn(prices, date)
+	bitcoinPrice, btcOK := priceOn(bitcoin, date)
+	if !ok || !btcOK {
+		return 0, false
+	}
+	if price, err = a.FX.Convert(price, company.TradingCurrency(), sharedmodels.USD, date); err != nil {
+		return 0, false
+	}
+	txs, shares := a.companyFacts(symbol)
+	holdings, sharesOutstanding := metrics.HoldingsAt(company, txs, shares, date)
+	mnav, err := metrics.CalculateMNAV(price*sharesOutstanding, holdings, bitcoinPrice)
+	return mnav, err == nil && mnav > 0
+}
+
+// window is a period of the Bitcoin price history
+type window struct {
+	from, to time.Time
+	change   float64
+}
+
+// changeOver returns a symbol's stored price change over a window
+func (a *Analyzer) changeOver(symbol string, w window) (float64, bool) {
+	prices, err := a.Prices.GetPrices(symbol, w.from.AddDate(0, 0, -7), w.to)
+	if err != nil {
+		return 0, false
+	}
+	before, okBefore := priceOn(prices, w.from)
+	after, okAfter := priceOn(prices, w.to)
+	if !okBefore || !okAfter || before <= 0 {
+		return 0, false
+	}
+	return after/before - 1, true
+}
+
+// HistoricalScenarios replays the count worst non-overlapping windows of windowDays in the stored
+// Bitcoin prices up to end. Each scenario moves Bitcoin by its change over the window, every
+// symbol held with stored prices by its own change, and the mNAV of RuleSymbol and the treasury
+// companies held by theirs, from their holdings or else their price relative to Bitcoin.
+func (a *Analyzer) HistoricalScenarios(portfolio *models.Portfolio, windowDays, count int, end time.Time) ([]config.Scenario, error) {
+	if a.Prices == nil {
+		return nil, fmt.Errorf("no price repository")
+	}
+	if windowDays <= 0 || count <= 0 {
+		return nil, fmt.Errorf("window and count must be positive")
+	}
+	bitcoin, err := a.Prices.GetPrices(repository.BitcoinSymbol, time.Time{}, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Bitcoin prices: %w", err)
+	}
+
+	var windows []window
+	for i, start := range bitcoin {
+		due := start.Date.AddDate(0, 0, windowDays)
+		j := sort.Search(len(bitcoin), func(j int) bool { return !bitcoin[j].Date.Before(due) })
+		if j == len(bitcoin) {
+			break
+		}
+		if start.Close > 0 {
+			windows = append(windows, window{from: bitcoin[i].Date, to: bitcoin[j].Date, change: bitcoin[j].Close/start.Close - 1})
+		}
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("stored Bitcoin prices cover less than %d days", windowDays)
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].change < windows[j].change })
+
+	var worst []window
+	for _, w := range windows {
+		overlaps := false
+		for _, chosen := range worst {
+			overlaps = overlaps || (w.from.Before(chosen.to) && chosen.from.Before(w.to))
+		}
+		if !overlaps {
+			worst = append(worst, w)
+		}
+		if len(worst) == count {
+			break
+		}
+	}
+
+	// The symbols held and the treasury companies whose mNAV moves
+	classes := a.classification()
+	var symbols []string
+	treasuries := []string{RuleSymbol}
+	seen := map[string]bool{RuleSymbol: true}
+	held := make(map[string]bool)
+	for _, position := range portfolio.Positions {
+		symbol := strings.ToUpper(position.Symbol)
+		if held[symbol] || classes.Classify(symbol).Class == config.AssetClassCash {
+			continue
+		}
+		held[symbol] = true
+		symbols = append(symbols, symbol)
+		if classes.Classify(symbol).Class == config.AssetClassBTCTreasury && !seen[symbol] {
+			seen[symbol] = true
+			treasuries = append(treasuries, symbol)
+		}
+	}
+
+	scenarios := make([]config.Scenario, 0, len(worst))
+	for _, w := range worst {
+		s := config.Scenario{
+			Name: fmt.Sprintf("Replay %s to %s (BTC %+.1f%%)",
+				w.from.Format("2006-01-02"), w.to.Format("2006-01-02"), w.change*100),
+			BTCChange:    w.change,
+			MNAVChange:   make(map[string]float64),
+			SymbolChange: make(map[string]float64),
+		}
+		for _, symbol := range symbols {
+			if change, ok := a.changeOver(symbol, w); ok {
+				s.SymbolChange[symbol] = change
+				if classes.Classify(symbol).Class == config.AssetClassGold && s.GoldChange == 0 {
+					s.GoldChange = change
+				}
+			}
+		}
+		for _, symbol := range treasuries {
+			before, okBefore := a.mnavAt(symbol, bitcoin, w.from)
+			after, okAfter := a.mnavAt(symbol, bitcoin, w.to)
+			if okBefore && okAfter {
+				s.MNAVChange[symbol] = after/before - 1
+			} else if change, ok := a.changeOver(symbol, w); ok {
+				// Without holdings data, the mNAV moves with the price relative to Bitcoin
+				s.MNAVChange[symbol] = (1+change)/(1+w.change) - 1
+			}
+		}
+		scenarios = append(scenarios, s)
+	}
+	return scenarios, nil
+}
@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+)
+
+// taxRates returns the configured tax rates, or the defaults
+func (a *Analyzer) taxRates() taxlots.Rates {
+	if a.TaxRates == (taxlots.Rates{}) {
+		return taxlots.DefaultRates
+	}
+	return a.TaxRates
+}
+
+// positionAccount returns the account number of a position, or its name
+func positionAccount(position models.Position) string {
+	if position.AccountNumber != "" {
+		return position.AccountNumber
+	}
+	return position.AccountName
+}
+
+// positionLots returns the open lots of a position
+func (a *Analyzer) positionLots(position models.Position) []models.TaxLot {
+	lots := a.Lots.For(position.AccountNumber, position.Symbol)
+	if len(lots) == 0 {
+		lots = a.Lots.For(position.AccountName, position.Symbol)
+	}
+	return lots
+}
+
+// sellTrade sells value of a position and estimates the tax it realizes. Taxable sells use the
+// position's lots with the lot method; without lots the gain is estimated from the average cost
+// and taxed as short-term.
+func (a *Analyzer) sellTrade(position models.Position, accountType string, value float64, date time.Time) (models.RecommendedTrade, []string) {
+	price := position.LastPrice
+	if price <= 0 && position.Quantity > 0 {
+		price = position.CurrentValue / position.Quantity
+	}
+	trade := models.RecommendedTrade{
+		Action:         "SELL",
+		Symbol:         position.Symbol,
+		Shares:         shares(value, price),
+		EstimatedValue: value,
+		Account:        positionAccount(position),
+		AccountType:    accountType,
+	}
+	if config.TaxAdvantaged(accountType) {
+		trade.TaxNote = "tax-advantaged account"
+		return trade, nil
+	}
+
+	var warnings []string
+	unmatched := trade.Shares
+	if lots := a.positionLots(position); len(lots) > 0 {
+		sales, err := taxlots.Select(lots, trade.Shares, price, date, a.LotMethod, nil)
+		if err != nil && !errors.Is(err, taxlots.ErrInsufficientLots) {
+			warnings = append(warnings, fmt.Sprintf("%s in %s: %v", position.Symbol, models.MaskAccount(trade.Account), err))
+		}
+		trade.Lots = sales
+		trade.ShortTermGain, trade.LongTermGain = taxlots.Gains(sales)
+		for _, sale := range sales {
+			unmatched -= sale.Quantity
+		}
+	}
+	if unmatched > 1e-9 {
+		averageCost := 0.0
+		if position.Quantity > 0 {
+			averageCost = position.CostBasisTotal / position.Quantity
+		}
+		trade.ShortTermGain += unmatched * (price - averageCost)
+		trade.TaxNote = "no lots: gain from average cost, holding period unknown (taxed as short-term)"
+		if len(trade.Lots) > 0 {
+			trade.TaxNote = fmt.Sprintf("lots cover %.4f of %.4f shares; the rest is estimated from average cost as short-term",
+				trade.Shares-unmatched, trade.Shares)
+		}
+	}
+	trade.EstimatedTax = a.taxRates().Tax(trade.ShortTermGain, trade.LongTermGain)
+
+	if trade.RealizedGain() < 0 {
+		warnings = append(warnings, a.washSaleWarnings(trade, date)...)
+	}
+	return trade, warnings
+}
+
+// washSaleWarnings warns of purchases, in any account, that would make a loss sale a wash sale
+func (a *Analyzer) washSaleWarnings(trade models.RecommendedTrade, date time.Time) []string {
+	sold := make(map[string]bool, len(trade.Lots))
+	for _, sale := range trade.Lots {
+		sold[sale.LotID] = true
+	}
+	var warnings []string
+	var lots []models.TaxLot
+	if a.Lots != nil {
+		lots = a.Lots.Lots
+	}
+	for _, lot := range taxlots.RecentPurchases(lots, trade.Symbol, date) {
+		if sold[lot.ID] {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("selling %s at a loss in %s is a wash sale: %.4f shares bought in %s on %s",
+			trade.Symbol, models.MaskAccount(trade.Account), lot.Quantity, models.MaskAccount(lotAccountName(lot)), lot.Acquired.Format("2006-01-02")))
+	}
+	if len(warnings) == 0 {
+		warnings = append(warnings, fmt.Sprintf("selling %s at a loss in %s: don't buy %s in any account for %d days",
+			trade.Symbol, models.MaskAccount(trade.Account), trade.Symbol, taxlots.WashSaleWindow))
+	}
+	return warnings
+}
+
+// lotAccountName returns the account name of a lot, or its number
+func lotAccountName(lot models.TaxLot) string {
+	if lot.AccountName != "" {
+		return lot.AccountName
+	}
+	return lot.AccountNumber
+}
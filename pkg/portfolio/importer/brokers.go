@@ -0,0 +1,294 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fidelity parses Fidelity's Portfolio_Positions CSV export
+type Fidelity struct{}
+
+var fidelityColumns = columnMap{
+	"Account Number":            "account_number",
+	"Account Name":              "account_name",
+	"Symbol":                    "symbol",
+	"Description":               "description",
+	"Quantity":                  "quantity",
+	"Last Price":                "last_price",
+	"Last Price Change":         "last_price_change",
+	"Current Value":             "current_value",
+	"Today's Gain/Loss Dollar":  "today_gain_loss_dollar",
+	"Today's Gain/Loss Percent": "today_gain_loss_percent",
+	"Total Gain/Loss Dollar":    "total_gain_loss_dollar",
+	"Total Gain/Loss Percent":   "total_gain_loss_percent",
+	"Percent Of Account":        "percent_of_account",
+	"Cost Basis Total":          "cost_basis_total",
+	"Average Cost Basis":        "average_cost_basis",
+	"Type":                      "type",
+	"Currency":                  "currency",
+}
+
+// fidelityDownloaded matches the "Date downloaded Jun-11-2025 3:45 p.m ET" footer
+var fidelityDownloaded = regexp.MustCompile(`Date downloaded\s+([A-Za-z]{3}-\d{1,2}-\d{4}|\d{2}/\d{2}/\d{4})`)
+
+func (Fidelity) Name() string { return "fidelity" }
+
+func (Fidelity) Detect(f *File) bool {
+	h := fidelityColumns.header(f.Rows[0])
+	return h.has("symbol", "current_value") && (h.has("account_number") || h.has("account_name"))
+}
+
+func (Fidelity) Parse(f *File) (*Result, error) {
+	result := &Result{}
+	h := fidelityColumns.header(f.Rows[0])
+	result.noteUnmapped(h.unmapped)
+
+	for _, record := range f.Rows[1:] {
+		if len(record) > 0 {
+			if match := fidelityDownloaded.FindStringSubmatch(record[0]); match != nil {
+				date, _ := parseDate(match[1], "")
+				result.noteDate(date)
+			}
+		}
+		// Disclaimer and footer rows don't have the proper data structure
+		if len(record) < h.width || len(record) == 1 ||
+			strings.Contains(record[0], "The data and information") ||
+			strings.Contains(record[0], "Brokerage services") ||
+			strings.Contains(record[0], "Date downloaded") {
+			continue
+		}
+
+		position, _ := h.position(record, "")
+		// Skip the SPAXX core cash position and pending activity rows
+		if position.Symbol == "" || strings.Contains(position.Symbol, "SPAXX") {
+			continue
+		}
+		result.Positions = append(result.Positions, position)
+	}
+	return result, nil
+}
+
+// Schwab parses Schwab's positions export, for one account or All-Accounts
+type Schwab struct{}
+
+var schwabColumns = columnMap{
+	"Symbol":                        "symbol",
+	"Description":                   "description",
+	"Qty (Quantity)":                "quantity",
+	"Quantity":                      "quantity",
+	"Price":                         "last_price",
+	"Price Chng $ (Price Change $)": "last_price_change",
+	"Price Change $":                "last_price_change",
+	"Mkt Val (Market Value)":        "current_value",
+	"Market Value":                  "current_value",
+	"Day Chng $ (Day Change $)":     "today_gain_loss_dollar",
+	"Day Change $":                  "today_gain_loss_dollar",
+	"Day Chng % (Day Change %)":     "today_gain_loss_percent",
+	"Day Change %":                  "today_gain_loss_percent",
+	"Cost Basis":                    "cost_basis_total",
+	"Gain $ (Gain/Loss $)":          "total_gain_loss_dollar",
+	"Gain/Loss $":                   "total_gain_loss_dollar",
+	"Gain % (Gain/Loss %)":          "total_gain_loss_percent",
+	"Gain/Loss %":                   "total_gain_loss_percent",
+	"% of Acct (% of Account)":      "percent_of_account",
+	"% Of Account":                  "percent_of_account",
+	"Security Type":                 "type",
+	"Asset Type":                    "type",
+}
+
+// schwabAsOf matches the date in "Positions for account Individual ...123 as of 04:15 PM ET, 2025/06/11"
+var schwabAsOf = regexp.MustCompile(`as of .*?(\d{4}/\d{2}/\d{2}|\d{2}/\d{2}/\d{4})`)
+
+// schwabAccount matches the account in a single-account title
+var schwabAccount = regexp.MustCompile(`Positions for account (.+?) as of`)
+
+func (Schwab) Name() string { return "schwab" }
+
+func (Schwab) Detect(f *File) bool {
+	return strings.HasPrefix(strings.TrimSpace(f.Rows[0][0]), "Positions for")
+}
+
+func (Schwab) Parse(f *File) (*Result, error) {
+	result := &Result{}
+	title := f.Rows[0][0]
+	if match := schwabAsOf.FindStringSubmatch(title); match != nil {
+		date, _ := parseDate(match[1], "")
+		result.noteDate(date)
+	}
+	account := ""
+	if match := schwabAccount.FindStringSubmatch(title); match != nil {
+		account = match[1]
+	}
+
+	var h *header
+	for _, record := range f.Rows[1:] {
+		first := strings.TrimSpace(record[0])
+		switch {
+		case first == "":
+			continue
+		case isTitleRow(record):
+			// All-Accounts exports head each account's section with its name
+			account, h = first, nil
+			continue
+		case strings.EqualFold(first, "Symbol"):
+			next := schwabColumns.header(record)
+			h = &next
+			result.noteUnmapped(h.unmapped)
+			continue
+		case h == nil, first == "Account Total", strings.HasPrefix(first, "Cash & Cash Investments"):
+			continue
+		}
+
+		position, _ := h.position(record, "")
+		position.AccountName = account
+		if i := strings.LastIndex(account, "..."); i >= 0 {
+			position.AccountNumber = account[i+3:]
+		}
+		result.Positions = append(result.Positions, position)
+	}
+	if len(result.Positions) == 0 && findHeader(f.Rows, schwabColumns, "symbol") < 0 {
+		return nil, fmt.Errorf("no positions header found")
+	}
+	return result, nil
+}
+
+// isTitleRow reports whether only the first cell of a row has text
+func isTitleRow(record []string) bool {
+	for _, cell := range record[1:] {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Vanguard parses the positions section of Vanguard's OfxDownload CSV export
+type Vanguard struct{}
+
+var vanguardColumns = columnMap{
+	"Account Number":  "account_number",
+	"Investment Name": "description",
+	"Symbol":          "symbol",
+	"Shares":          "quantity",
+	"Share Price":     "last_price",
+	"Total Value":     "current_value",
+}
+
+func (Vanguard) Name() string { return "vanguard" }
+
+func (Vanguard) Detect(f *File) bool {
+	return findHeader(f.Rows, vanguardColumns, "description", "last_price", "current_value") >= 0
+}
+
+func (Vanguard) Parse(f *File) (*Result, error) {
+	result := &Result{}
+	start := findHeader(f.Rows, vanguardColumns, "description", "last_price", "current_value")
+	if start < 0 {
+		return nil, fmt.Errorf("no positions header found")
+	}
+	h := vanguardColumns.header(f.Rows[start])
+	result.noteUnmapped(h.unmapped)
+
+	// The transactions section follows the positions with its own header
+	for _, record := range f.Rows[start+1:] {
+		if len(record) < 2 || strings.EqualFold(strings.TrimSpace(record[0]), "Account Number") {
+			break
+		}
+		position, _ := h.position(record, "")
+		// VMFXX is the settlement fund, like Fidelity's SPAXX core position
+		if position.Symbol == "VMFXX" {
+			continue
+		}
+		if position.AccountNumber != "" {
+			position.AccountName = "Vanguard " + position.AccountNumber
+		}
+		result.Positions = append(result.Positions, position)
+	}
+	return result, nil
+}
+
+// IBKRFlex parses an Interactive Brokers Flex Query CSV of open positions, with or without
+// header and trailer records
+type IBKRFlex struct{}
+
+var ibkrColumns = columnMap{
+	"ClientAccountID":   "account_number",
+	"AccountAlias":      "account_name",
+	"CurrencyPrimary":   "currency",
+	"AssetClass":        "type",
+	"Symbol":            "symbol",
+	"Description":       "description",
+	"Quantity":          "quantity",
+	"Position":          "quantity",
+	"MarkPrice":         "last_price",
+	"PositionValue":     "current_value",
+	"CostBasisMoney":    "cost_basis_total",
+	"CostBasisPrice":    "average_cost_basis",
+	"FifoPnlUnrealized": "total_gain_loss_dollar",
+	"PercentOfNAV":      "percent_of_account",
+	"ReportDate":        "date",
+	"LevelOfDetail":     "level_of_detail",
+}
+
+func (IBKRFlex) Name() string { return "ibkr" }
+
+func (IBKRFlex) Detect(f *File) bool {
+	for _, row := range f.Rows {
+		if ibkrColumns.header(flexRecord(row)).has("account_number", "symbol", "current_value") {
+			return true
+		}
+	}
+	return false
+}
+
+// flexRecord strips the record type and section code from HEADER and DATA records
+func flexRecord(row []string) []string {
+	if len(row) > 2 && (row[0] == "HEADER" || row[0] == "DATA") {
+		return row[2:]
+	}
+	return row
+}
+
+func (IBKRFlex) Parse(f *File) (*Result, error) {
+	result := &Result{}
+	var h *header
+	for _, row := range f.Rows {
+		if len(row) > 0 && (row[0] == "BOF" || row[0] == "BOA" || row[0] == "BOS" ||
+			row[0] == "EOS" || row[0] == "EOA" || row[0] == "EOF") {
+			continue
+		}
+		record := flexRecord(row)
+		if strings.EqualFold(strings.TrimSpace(record[0]), "ClientAccountID") {
+			// Every section starts with its own header; only position sections are read
+			next := ibkrColumns.header(record)
+			h = nil
+			if next.has("symbol", "current_value") {
+				h = &next
+				result.noteUnmapped(h.unmapped)
+			}
+			continue
+		}
+		if h == nil {
+			continue
+		}
+
+		// Lot rows repeat the summary row's quantity
+		if level := h.get(record, "level_of_detail"); level != "" && !strings.EqualFold(level, "SUMMARY") {
+			continue
+		}
+		position, date := h.position(record, "")
+		if strings.EqualFold(position.Type, "CASH") || position.Symbol == "" {
+			continue
+		}
+		if position.AccountName == "" {
+			position.AccountName = position.AccountNumber
+		}
+		result.noteDate(date)
+		result.Positions = append(result.Positions, position)
+	}
+	if h == nil && len(result.Positions) == 0 {
+		return nil, fmt.Errorf("no open positions section found")
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// Fields are the position fields a column can map to, named after the Position JSON fields.
+// "date" is the as-of date of the row rather than a position field.
+var Fields = []string{
+	"account_number", "account_name", "symbol", "description", "quantity", "last_price",
+	"last_price_change", "current_value", "today_gain_loss_dollar", "today_gain_loss_percent",
+	"total_gain_loss_dollar", "total_gain_loss_percent", "percent_of_account", "cost_basis_total",
+	"average_cost_basis", "type", "currency", "date",
+}
+
+// columnMap maps export header names to fields
+type columnMap map[string]string
+
+// header locates the mapped fields in a header row
+type header struct {
+	index    map[string]int
+	unmapped []string
+	width    int
+}
+
+// normalizeColumn makes header matching insensitive to case and surrounding space
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// header maps the columns of a header row. Columns without a mapping are reported as
+// unmapped; blank trailing columns are not.
+func (m columnMap) header(row []string) header {
+	fields := make(map[string]string, len(m))
+	for column, field := range m {
+		fields[normalizeColumn(column)] = field
+	}
+
+	h := header{index: make(map[string]int), width: len(row)}
+	for i, column := range row {
+		field, ok := fields[normalizeColumn(column)]
+		switch {
+		case ok:
+			if _, exists := h.index[field]; !exists {
+				h.index[field] = i
+			}
+		case strings.TrimSpace(column) != "":
+			h.unmapped = append(h.unmapped, strings.TrimSpace(column))
+		}
+	}
+	return h
+}
+
+// has reports whether every field is mapped
+func (h header) has(fields ...string) bool {
+	for _, field := range fields {
+		if _, ok := h.index[field]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the trimmed cell of a mapped field
+func (h header) get(record []string, field string) string {
+	if i, ok := h.index[field]; ok && i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
+
+// amount returns the numeric cell of a mapped field, or 0
+func (h header) amount(record []string, field string) float64 {
+	value, _ := parseAmount(h.get(record, field))
+	return value
+}
+
+// position builds a position from a record, with the row's date when "date" is mapped
+func (h header) position(record []string, dateLayout string) (models.Position, time.Time) {
+	var p models.Position
+	for field := range h.index {
+		setField(&p, field, h.get(record, field))
+	}
+	date, _ := parseDate(h.get(record, "date"), dateLayout)
+	Complete(&p)
+	return p, date
+}
+
+// setField stores a cell in the position field it maps to; unparseable numbers stay zero
+func setField(p *models.Position, field, value string) {
+	text := map[string]*string{
+		"account_number": &p.AccountNumber,
+		"account_name":   &p.AccountName,
+		"symbol":         &p.Symbol,
+		"description":    &p.Description,
+		"type":           &p.Type,
+		"currency":       &p.Currency,
+	}
+	if target, ok := text[field]; ok {
+		*target = value
+		return
+	}
+
+	number := map[string]*float64{
+		"quantity":                &p.Quantity,
+		"last_price":              &p.LastPrice,
+		"last_price_change":       &p.LastPriceChange,
+		"current_value":           &p.CurrentValue,
+		"today_gain_loss_dollar":  &p.TodayGainLoss,
+		"today_gain_loss_percent": &p.TodayGainLossPct,
+		"total_gain_loss_dollar":  &p.TotalGainLoss,
+		"total_gain_loss_percent": &p.TotalGainLossPct,
+		"percent_of_account":      &p.PercentOfAccount,
+		"cost_basis_total":        &p.CostBasisTotal,
+		"average_cost_basis":      &p.AverageCostBasis,
+	}
+	if target, ok := number[field]; ok {
+		*target, _ = parseAmount(value)
+	}
+	// date and importer-specific fields are read separately
+}
+
+// findHeader returns the index of the first row that maps all required fields, or -1
+func findHeader(rows [][]string, columns columnMap, required ...string) int {
+	for i, row := range rows {
+		if columns.header(row).has(required...) {
+			return i
+		}
+	}
+	return -1
+}
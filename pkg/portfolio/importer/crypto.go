@@ -0,0 +1,234 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// Crypto exports list transactions rather than holdings, so these importers replay them into
+// balances. They carry no current prices: positions are priced from the price repository.
+
+// Coinbase builds balances from Coinbase's transaction history export
+type Coinbase struct{}
+
+var coinbaseColumns = columnMap{
+	"ID":                        "id",
+	"Timestamp":                 "date",
+	"Transaction Type":          "tx_type",
+	"Asset":                     "symbol",
+	"Quantity Transacted":       "quantity",
+	"Price Currency":            "currency",
+	"Spot Price Currency":       "currency",
+	"Price at Transaction":      "price",
+	"Spot Price at Transaction": "price",
+	"Subtotal":                  "subtotal",
+	"Total (inclusive of fees and/or spread)": "total",
+	"Total (inclusive of fees)":               "total",
+	"Fees and/or Spread":                      "fees",
+	"Fees":                                    "fees",
+	"Notes":                                   "notes",
+}
+
+// Coinbase transaction types that add to or take from a balance
+var (
+	coinbaseIn = map[string]bool{"buy": true, "advanced trade buy": true, "receive": true, "deposit": true,
+		"rewards income": true, "reward income": true, "staking income": true, "learning reward": true,
+		"coinbase earn": true, "inflation reward": true}
+	coinbaseOut = map[string]bool{"sell": true, "advanced trade sell": true, "send": true, "withdrawal": true}
+)
+
+// coinbaseConvert matches "Converted 0.01 BTC to 612.34 USDC"
+var coinbaseConvert = regexp.MustCompile(`Converted ([\d.,]+) (\S+) to ([\d.,]+) (\S+)`)
+
+func (Coinbase) Name() string { return "coinbase" }
+
+func (Coinbase) Detect(f *File) bool {
+	return findHeader(f.Rows, coinbaseColumns, "date", "tx_type", "symbol", "quantity") >= 0
+}
+
+// holding is a running crypto balance with its average cost basis
+type holding struct {
+	quantity, basis float64
+	currency        string
+}
+
+func (h *holding) add(quantity, cost float64) {
+	h.quantity += quantity
+	h.basis += cost
+}
+
+func (h *holding) remove(quantity float64) {
+	if h.quantity > 0 {
+		h.basis -= h.basis * math.Min(quantity/h.quantity, 1)
+	}
+	h.quantity -= quantity
+}
+
+func (Coinbase) Parse(f *File) (*Result, error) {
+	start := findHeader(f.Rows, coinbaseColumns, "date", "tx_type", "symbol", "quantity")
+	if start < 0 {
+		return nil, fmt.Errorf("no transactions header found")
+	}
+	result := &Result{}
+	h := coinbaseColumns.header(f.Rows[start])
+	result.noteUnmapped(h.unmapped)
+
+	holdings := make(map[string]*holding)
+	get := func(symbol, currency string) *holding {
+		if holdings[symbol] == nil {
+			holdings[symbol] = &holding{currency: currency}
+		}
+		return holdings[symbol]
+	}
+	skipped := make(map[string]int)
+
+	for _, record := range f.Rows[start+1:] {
+		date, _ := parseDate(h.get(record, "date"), "")
+		symbol := strings.ToUpper(h.get(record, "symbol"))
+		if date.IsZero() || symbol == "" {
+			continue
+		}
+		result.noteDate(date)
+		quantity := math.Abs(h.amount(record, "quantity"))
+		currency := h.get(record, "currency")
+		kind := strings.ToLower(h.get(record, "tx_type"))
+
+		switch {
+		case kind == "buy" || kind == "advanced trade buy":
+			get(symbol, currency).add(quantity, math.Abs(h.amount(record, "total")))
+		case coinbaseIn[kind]:
+			// Transfers and income are valued at the spot price
+			get(symbol, currency).add(quantity, quantity*h.amount(record, "price"))
+		case coinbaseOut[kind]:
+			get(symbol, currency).remove(quantity)
+		case kind == "convert":
+			get(symbol, currency).remove(quantity)
+			if match := coinbaseConvert.FindStringSubmatch(h.get(record, "notes")); match != nil {
+				received, _ := parseAmount(match[3])
+				get(strings.ToUpper(match[4]), currency).add(received, math.Abs(h.amount(record, "total")))
+			}
+		default:
+			skipped[h.get(record, "tx_type")]++
+		}
+	}
+
+	for kind, count := range skipped {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("skipped %d %q transactions", count, kind))
+	}
+	sort.Strings(result.Warnings)
+	result.Positions = cryptoPositions("Coinbase", holdings)
+	return result, nil
+}
+
+// Kraken builds balances from Kraken's ledgers export
+type Kraken struct{}
+
+var krakenColumns = columnMap{
+	"txid":    "id",
+	"refid":   "refid",
+	"time":    "date",
+	"type":    "tx_type",
+	"subtype": "subtype",
+	"aclass":  "aclass",
+	"asset":   "symbol",
+	"wallet":  "wallet",
+	"amount":  "amount",
+	"fee":     "fee",
+	"balance": "quantity",
+}
+
+func (Kraken) Name() string { return "kraken" }
+
+func (Kraken) Detect(f *File) bool {
+	return krakenColumns.header(f.Rows[0]).has("id", "refid", "symbol", "quantity")
+}
+
+func (Kraken) Parse(f *File) (*Result, error) {
+	result := &Result{}
+	h := krakenColumns.header(f.Rows[0])
+	result.noteUnmapped(h.unmapped)
+
+	// The balance column is the running balance of the asset in its wallet
+	type balance struct {
+		date     time.Time
+		quantity float64
+	}
+	latest := make(map[string]balance)
+	for _, record := range f.Rows[1:] {
+		date, _ := parseDate(h.get(record, "date"), "")
+		asset := h.get(record, "symbol")
+		if date.IsZero() || asset == "" {
+			continue
+		}
+		result.noteDate(date)
+		key := asset + "|" + h.get(record, "wallet")
+		if date.Before(latest[key].date) {
+			continue
+		}
+		latest[key] = balance{date: date, quantity: h.amount(record, "quantity")}
+	}
+
+	holdings := make(map[string]*holding)
+	for key, b := range latest {
+		asset, _, _ := strings.Cut(key, "|")
+		symbol := krakenAsset(asset)
+		if holdings[symbol] == nil {
+			holdings[symbol] = &holding{currency: "USD"}
+		}
+		holdings[symbol].quantity += b.quantity
+	}
+	result.Positions = cryptoPositions("Kraken", holdings)
+	if len(result.Positions) > 0 {
+		result.Warnings = append(result.Warnings, "Kraken ledgers have no cost basis")
+	}
+	return result, nil
+}
+
+// krakenAsset maps Kraken asset codes (XXBT, XETH, ZUSD, ETH2.S, ...) to common symbols
+func krakenAsset(asset string) string {
+	asset = strings.ToUpper(asset)
+	if i := strings.Index(asset, "."); i > 0 {
+		asset = asset[:i] // Staked and earn variants
+	}
+	if len(asset) == 4 && (asset[0] == 'X' || asset[0] == 'Z') {
+		asset = asset[1:]
+	}
+	switch asset {
+	case "XBT":
+		return "BTC"
+	case "XDG":
+		return "DOGE"
+	case "ETH2":
+		return "ETH"
+	}
+	return asset
+}
+
+// cryptoPositions turns non-zero, non-fiat balances into unpriced positions
+func cryptoPositions(account string, holdings map[string]*holding) []models.Position {
+	var positions []models.Position
+	for symbol, h := range holdings {
+		if fiat[symbol] || h.quantity <= 1e-12 {
+			continue
+		}
+		p := models.Position{
+			AccountName:    account,
+			Symbol:         symbol,
+			Description:    symbol,
+			Quantity:       h.quantity,
+			CostBasisTotal: math.Max(h.basis, 0),
+			Type:           "Crypto",
+			Currency:       h.currency,
+		}
+		Complete(&p)
+		positions = append(positions, p)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions
+}
@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Generic parses any positions CSV using a mapping from its columns to position fields
+type Generic struct {
+	Mapping GenericMapping
+}
+
+// GenericMapping describes a positions CSV that has no built-in importer
+type GenericMapping struct {
+	Name        string            `json:"name"`                   // Broker name shown on import
+	Columns     map[string]string `json:"columns"`                // CSV column → field (see Fields)
+	DateFormat  string            `json:"date_format,omitempty"`  // Go layout of the "date" column
+	AccountName string            `json:"account_name,omitempty"` // Used when no account column is mapped
+	SkipSymbols []string          `json:"skip_symbols,omitempty"` // Cash and other rows to leave out
+}
+
+// Validate checks that every column maps to a known field and that positions can be built
+func (m GenericMapping) Validate() error {
+	known := make(map[string]bool, len(Fields))
+	for _, field := range Fields {
+		known[field] = true
+	}
+	mapped := make(map[string]bool)
+	for column, field := range m.Columns {
+		if !known[field] {
+			return fmt.Errorf("column %q maps to unknown field %q (want one of %s)", column, field, strings.Join(Fields, ", "))
+		}
+		mapped[field] = true
+	}
+	if !mapped["symbol"] || !(mapped["quantity"] || mapped["current_value"]) {
+		return fmt.Errorf("mapping needs a symbol column and a quantity or current_value column")
+	}
+	return nil
+}
+
+func (g Generic) Name() string {
+	if g.Mapping.Name != "" {
+		return g.Mapping.Name
+	}
+	return "generic"
+}
+
+func (g Generic) Detect(f *File) bool {
+	return g.headerRow(f) >= 0
+}
+
+// headerRow is the first row that has every mapped column
+func (g Generic) headerRow(f *File) int {
+	var fields []string
+	for _, field := range g.Mapping.Columns {
+		fields = append(fields, field)
+	}
+	return findHeader(f.Rows, columnMap(g.Mapping.Columns), fields...)
+}
+
+func (g Generic) Parse(f *File) (*Result, error) {
+	if err := g.Mapping.Validate(); err != nil {
+		return nil, err
+	}
+	start := g.headerRow(f)
+	if start < 0 {
+		return nil, fmt.Errorf("no row has all the mapped columns")
+	}
+
+	result := &Result{}
+	h := columnMap(g.Mapping.Columns).header(f.Rows[start])
+	result.noteUnmapped(h.unmapped)
+	skip := make(map[string]bool)
+	for _, symbol := range g.Mapping.SkipSymbols {
+		skip[strings.ToUpper(symbol)] = true
+	}
+
+	for _, record := range f.Rows[start+1:] {
+		position, date := h.position(record, g.Mapping.DateFormat)
+		if position.Symbol == "" || skip[strings.ToUpper(position.Symbol)] {
+			continue
+		}
+		if position.AccountName == "" {
+			position.AccountName = g.Mapping.AccountName
+		}
+		if position.AccountName == "" {
+			position.AccountName = position.AccountNumber
+		}
+		result.noteDate(date)
+		result.Positions = append(result.Positions, position)
+	}
+	return result, nil
+}
+
+// LoadGenericMapping reads a generic importer mapping from a JSON file
+func LoadGenericMapping(path string) (*Generic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping: %w", err)
+	}
+	var mapping GenericMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping %s: %w", path, err)
+	}
+	if err := mapping.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping %s: %w", path, err)
+	}
+	return &Generic{Mapping: mapping}, nil
+}
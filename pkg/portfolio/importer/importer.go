@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// Importer parses one broker's positions export
+type Importer interface {
+	// Name is the broker name used on the command line (fidelity, schwab, ...)
+	Name() string
+	// Detect reports whether the file looks like this broker's export
+	Detect(f *File) bool
+	// Parse extracts the positions and, when the content has one, the as-of date
+	Parse(f *File) (*Result, error)
+}
+
+// File is an export read into CSV rows
+type File struct {
+	Path string
+	Rows [][]string
+}
+
+// Result is what an importer found in a file
+type Result struct {
+	Broker          string            `json:"broker"`
+	Date            time.Time         `json:"date"`        // As-of date; zero when neither the content nor the filename has one
+	DateSource      string            `json:"date_source"` // "content" or "filename"
+	Positions       []models.Position `json:"positions"`
+	UnmappedColumns []string          `json:"unmapped_columns,omitempty"` // Header columns the importer ignored
+	Warnings        []string          `json:"warnings,omitempty"`
+}
+
+// noteDate keeps the latest date seen in the content
+func (r *Result) noteDate(t time.Time) {
+	if !t.IsZero() && t.After(r.Date) {
+		r.Date = t
+		r.DateSource = "content"
+	}
+}
+
+// noteUnmapped records header columns without a mapping, once each
+func (r *Result) noteUnmapped(columns []string) {
+	for _, column := range columns {
+		seen := false
+		for _, existing := range r.UnmappedColumns {
+			seen = seen || existing == column
+		}
+		if !seen {
+			r.UnmappedColumns = append(r.UnmappedColumns, column)
+		}
+	}
+}
+
+// Importers returns the built-in importers in detection order. The generic importer needs a
+// mapping and is not auto-detected.
+func Importers() []Importer {
+	return []Importer{Schwab{}, IBKRFlex{}, Vanguard{}, Coinbase{}, Kraken{}, Fidelity{}}
+}
+
+// ByName returns the built-in importer called name
+func ByName(name string) (Importer, error) {
+	var names []string
+	for _, imp := range Importers() {
+		if strings.EqualFold(imp.Name(), name) {
+			return imp, nil
+		}
+		names = append(names, imp.Name())
+	}
+	return nil, fmt.Errorf("unknown broker %q (want auto, %s or generic)", name, strings.Join(names, ", "))
+}
+
+// ReadFile reads a CSV export, allowing ragged rows and a UTF-8 byte order mark
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return ParseCSV(path, data)
+}
+
+// ParseCSV parses the contents of the CSV export at path, e.g. after decrypting it
+func ParseCSV(path string, data []byte) (*File, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return &File{Path: path, Rows: rows}, nil
+}
+
+// Detect returns the first built-in importer that recognises the file
+func Detect(f *File) (Importer, error) {
+	for _, imp := range Importers() {
+		if imp.Detect(f) {
+			return imp, nil
+		}
+	}
+	return nil, fmt.Errorf("unrecognised positions export %s (use -broker, or -broker generic with a mapping)", filepath.Base(f.Path))
+}
+
+// Import reads path and parses it with imp, or the detected importer when imp is nil. When the
+// content has no as-of date, the date is taken from the filename.
+func Import(path string, imp Importer) (*Result, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ImportFile(f, imp)
+}
+
+// ImportFile parses a read export like Import
+func ImportFile(f *File, imp Importer) (*Result, error) {
+	var err error
+	if imp == nil {
+		if imp, err = Detect(f); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := imp.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s import failed: %w", imp.Name(), err)
+	}
+	result.Broker = imp.Name()
+	if result.Date.IsZero() {
+		if date, ok := DateFromFilename(f.Path); ok {
+			result.Date, result.DateSource = date, "filename"
+		}
+	}
+	return result, nil
+}
+
+// filenameDates are the date patterns brokers put in export filenames, tried in order
+var filenameDates = []struct {
+	pattern *regexp.Regexp
+	layout  string
+}{
+	{regexp.MustCompile(`[A-Z][a-z]{2}-\d{1,2}-\d{4}`), "Jan-2-2006"}, // Portfolio_Positions_Jun-11-2025.csv
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), "2006-01-02"},
+	{regexp.MustCompile(`\d{2}-\d{2}-\d{4}`), "01-02-2006"},
+	{regexp.MustCompile(`(?:^|\D)(20\d{6})(?:\D|$)`), "20060102"},
+}
+
+// DateFromFilename finds an as-of date in an export's filename
+func DateFromFilename(path string) (time.Time, bool) {
+	name := strings.ReplaceAll(filepath.Base(path), "_", "-")
+	for _, d := range filenameDates {
+		match := d.pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		value := match[len(match)-1]
+		if date, err := time.Parse(d.layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// contentDateLayouts are the date formats found inside exports
+var contentDateLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05.0000",
+	"2006-01-02 15:04:05",
+	"2006-01-02;15:04:05",
+	"2006-01-02",
+	"20060102",
+	"2006/01/02",
+	"01/02/2006",
+	"Jan-2-2006",
+}
+
+// parseDate parses a date in layout, or in any of the formats found inside exports
+func parseDate(value, layout string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	layouts := contentDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseAmount parses a money, quantity or percent cell: currency symbols, thousands
+// separators, signs, percent signs and accounting parentheses are accepted, and
+// placeholders like "--" or "n/a" are not numbers
+func parseAmount(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	value = strings.Trim(value, "()")
+	value = strings.NewReplacer("$", "", "€", "", "£", "", "¥", "", ",", "", "+", "", "%", "", " ", "").Replace(value)
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		parsed = -parsed
+	}
+	return parsed, true
+}
+
+// fiat are the currencies crypto exports list as cash balances
+var fiat = map[string]bool{"USD": true, "EUR": true, "GBP": true, "CAD": true, "AUD": true, "CHF": true, "JPY": true}
+
+// Complete fills the value and average cost of a position from the fields the export has
+func Complete(p *models.Position) {
+	if p.CurrentValue == 0 && p.Quantity != 0 && p.LastPrice != 0 {
+		p.CurrentValue = p.Quantity * p.LastPrice
+	}
+	if p.LastPrice == 0 && p.Quantity != 0 && p.CurrentValue != 0 {
+		p.LastPrice = p.CurrentValue / p.Quantity
+	}
+	if p.AverageCostBasis == 0 && p.Quantity != 0 && p.CostBasisTotal != 0 {
+		p.AverageCostBasis = p.CostBasisTotal / p.Quantity
+	}
+	if p.TotalGainLoss == 0 && p.CostBasisTotal != 0 && p.CurrentValue != 0 {
+		p.TotalGainLoss = p.CurrentValue - p.CostBasisTotal
+		p.TotalGainLossPct = p.TotalGainLoss / p.CostBasisTotal * 100
+	}
+}
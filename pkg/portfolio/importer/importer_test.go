@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeExport(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportDetectsBrokers(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		broker, date        string
+		dateSource          string
+		symbols             []string
+		value               float64 // Current value of the first position
+		unmapped            []string
+	}{
+		{
+			name: "fidelity", file: "Portfolio_Positions_Jun-11-2025.csv",
+			content: `Account Number,Account Name,Symbol,Description,Quantity,Last Price,Current Value,Cost Basis Total,Type
+X1,Brokerage,MSTR,STRATEGY,10,$380.00,"$3,800.00",$2000.00,Cash
+X1,Brokerage,SPAXX**,HELD IN MONEY MARKET,,,$50.00,,Cash
+
+"The data and information in this spreadsheet is provided to you solely for your use"
+"Brokerage services are provided by Fidelity Brokerage Services LLC"
+"Date downloaded Jun-12-2025 3:45 p.m ET"
+`,
+			broker: "fidelity", date: "2025-06-12", dateSource: "content", symbols: []string{"MSTR"}, value: 3800,
+		},
+		{
+			name: "schwab all accounts", file: "All-Accounts-Positions.csv",
+			content: `"Positions for All-Accounts as of 04:15 PM ET, 2025/06/11","","",""
+
+"Individual ...123","","",""
+"Symbol","Description","Qty (Quantity)","Price","Mkt Val (Market Value)","Cost Basis","Ratings",""
+"MSTR","STRATEGY INC","10","$380.00","$3,800.00","$2,000.00","C",""
+"Cash & Cash Investments","--","--","--","$100.00","--","--",""
+"Account Total","--","--","--","$3,900.00","$2,000.00","--",""
+
+"Roth IRA ...456","","",""
+"Symbol","Description","Qty (Quantity)","Price","Mkt Val (Market Value)","Cost Basis","Ratings",""
+"FBTC","FIDELITY WISE ORIGIN BITCOIN","100","$90.00","$9,000.00","(1,000.00)","--",""
+`,
+			broker: "schwab", date: "2025-06-11", dateSource: "content", symbols: []string{"MSTR", "FBTC"}, value: 3800,
+			unmapped: []string{"Ratings"},
+		},
+		{
+			name: "vanguard", file: "OfxDownload_2025-06-10.csv",
+			content: `Account Number,Investment Name,Symbol,Shares,Share Price,Total Value,
+12345678,VANGUARD FEDERAL MONEY MARKET,VMFXX,100,1,100,
+12345678,ISHARES BITCOIN TRUST,IBIT,20,60.5,1210,
+
+Account Number,Trade Date,Settlement Date,Transaction Type,Transaction Description,Investment Name,Symbol,Shares,Share Price,Principal Amount,Commissions and Fees,Net Amount,Accrued Interest,Account Type,
+12345678,2025-06-01,2025-06-02,Buy,Buy,ISHARES BITCOIN TRUST,IBIT,20,60,-1200,0,-1200,0,CASH,
+`,
+			broker: "vanguard", date: "2025-06-10", dateSource: "filename", symbols: []string{"IBIT"}, value: 1210,
+		},
+		{
+			name: "ibkr flex", file: "positions.csv",
+			content: `"HEADER","POST","ClientAccountID","AccountAlias","CurrencyPrimary","AssetClass","Symbol","Description","ReportDate","Quantity","MarkPrice","PositionValue","CostBasisMoney","LevelOfDetail","Multiplier"
+"DATA","POST","U123","","EUR","STK","MSTR","STRATEGY","20250611","10","350","3500","2000","SUMMARY","1"
+"DATA","POST","U123","","EUR","STK","MSTR","STRATEGY","20250611","10","350","3500","2000","LOT","1"
+`,
+			broker: "ibkr", date: "2025-06-11", dateSource: "content", symbols: []string{"MSTR"}, value: 3500,
+			unmapped: []string{"Multiplier"},
+		},
+		{
+			name: "coinbase", file: "coinbase.csv",
+			content: `You can use this transaction report to inform your likely tax obligations.
+
+Transactions
+User,someone,abc
+ID,Timestamp,Transaction Type,Asset,Quantity Transacted,Price Currency,Price at Transaction,Subtotal,Total (inclusive of fees and/or spread),Fees and/or Spread,Notes
+a1,2025-01-02 10:00:00 UTC,Buy,BTC,0.2,USD,$90000,$18000,$18100,$100,Bought 0.2 BTC
+a2,2025-03-02 10:00:00 UTC,Send,BTC,-0.05,USD,$85000,$4250,$4250,$0,Sent 0.05 BTC
+a3,2025-06-09 10:00:00 UTC,Convert,BTC,0.05,USD,$100000,$5000,$5000,$0,Converted 0.05 BTC to 5000 USDC
+a4,2025-06-10 10:00:00 UTC,Retail Unstaking Transfer,ETH,1,USD,$2500,$2500,$2500,$0,
+`,
+			broker: "coinbase", date: "2025-06-10", dateSource: "content", symbols: []string{"BTC", "USDC"},
+		},
+		{
+			name: "kraken", file: "ledgers.csv",
+			content: `"txid","refid","time","type","subtype","aclass","asset","wallet","amount","fee","balance"
+"L1","R1","2025-06-01 10:00:00","deposit","","currency","XXBT","spot / main",0.5,0,0.5
+"L2","R2","2025-06-03 10:00:00","trade","","currency","XXBT","spot / main",-0.1,0,0.4
+"L3","R2","2025-06-03 10:00:00","trade","","currency","ZUSD","spot / main",10000,0,10000
+`,
+			broker: "kraken", date: "2025-06-03", dateSource: "content", symbols: []string{"BTC"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Import(writeExport(t, tt.file, tt.content), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Broker != tt.broker {
+				t.Fatalf("expected %s, detected %s", tt.broker, result.Broker)
+			}
+			if got := result.Date.Format("2006-01-02"); got != tt.date || result.DateSource != tt.dateSource {
+				t.Errorf("expected date %s from %s, got %s from %s", tt.date, tt.dateSource, got, result.DateSource)
+			}
+			if len(result.Positions) != len(tt.symbols) {
+				t.Fatalf("expected positions %v, got %+v", tt.symbols, result.Positions)
+			}
+			for i, symbol := range tt.symbols {
+				if result.Positions[i].Symbol != symbol {
+					t.Errorf("position %d: expected %s, got %s", i, symbol, result.Positions[i].Symbol)
+				}
+			}
+			if result.Positions[0].CurrentValue != tt.value {
+				t.Errorf("expected first value %.2f, got %.2f", tt.value, result.Positions[0].CurrentValue)
+			}
+			if len(result.UnmappedColumns) != len(tt.unmapped) || (len(tt.unmapped) > 0 && result.UnmappedColumns[0] != tt.unmapped[0]) {
+				t.Errorf("expected unmapped %v, got %v", tt.unmapped, result.UnmappedColumns)
+			}
+		})
+	}
+}
+
+func TestCoinbaseAveragesCostBasis(t *testing.T) {
+	content := `ID,Timestamp,Transaction Type,Asset,Quantity Transacted,Price Currency,Price at Transaction,Subtotal,Total (inclusive of fees and/or spread),Fees and/or Spread,Notes
+a1,2025-01-02T10:00:00Z,Buy,BTC,0.2,USD,$90000,$18000,$18100,$100,
+a2,2025-03-02T10:00:00Z,Sell,BTC,-0.1,USD,$85000,$8500,$8450,$50,
+`
+	result, err := Import(writeExport(t, "coinbase.csv", content), Coinbase{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	btc := result.Positions[0]
+	if btc.Quantity != 0.1 || btc.CostBasisTotal != 9050 || btc.AverageCostBasis != 90500 {
+		t.Errorf("expected 0.1 BTC with a 9050 basis, got %+v", btc)
+	}
+}
+
+func TestGenericMapping(t *testing.T) {
+	content := `Broker statement
+Ticker,Units,Value,Paid,As Of,Rating
+MSTR,10,"3,800.00","2,000.00",06/11/2025,Buy
+CASH,1,500,500,06/11/2025,
+`
+	g := Generic{Mapping: GenericMapping{
+		Name:        "mybank",
+		Columns:     map[string]string{"Ticker": "symbol", "Units": "quantity", "Value": "current_value", "Paid": "cost_basis_total", "As Of": "date"},
+		DateFormat:  "01/02/2006",
+		AccountName: "Bank",
+		SkipSymbols: []string{"cash"},
+	}}
+	result, err := Import(writeExport(t, "statement.csv", content), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Broker != "mybank" || result.Date.Format("2006-01-02") != "2025-06-11" {
+		t.Errorf("unexpected broker %s or date %s", result.Broker, result.Date)
+	}
+	if len(result.Positions) != 1 || result.Positions[0].LastPrice != 380 || result.Positions[0].AccountName != "Bank" {
+		t.Errorf("unexpected positions %+v", result.Positions)
+	}
+	if len(result.UnmappedColumns) != 1 || result.UnmappedColumns[0] != "Rating" {
+		t.Errorf("expected Rating to be unmapped, got %v", result.UnmappedColumns)
+	}
+
+	g.Mapping.Columns["Units"] = "shares"
+	if err := g.Mapping.Validate(); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+}
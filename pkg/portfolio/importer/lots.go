@@ -0,0 +1,310 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+)
+
+// Lot exports list the open lots of each position; activity exports list dated trades, which
+// are replayed into lots, along with income, transfers and fees for the transactions ledger.
+
+// LotResult is what was read from a lot or activity export
+type LotResult struct {
+	Format          string // "lots" or "activity"
+	Lots            []models.TaxLot
+	Transactions    []models.Transaction // Activity exports only
+	Sales           []models.RealizedSale
+	UnmappedColumns []string
+	Warnings        []string
+}
+
+var lotColumns = columnMap{
+	"Account Number":       "account_number",
+	"Account":              "account_number",
+	"Account Name":         "account_name",
+	"Symbol":               "symbol",
+	"Description":          "description",
+	"Lot ID":               "lot_id",
+	"Date Acquired":        "acquired",
+	"Acquired":             "acquired",
+	"Acquisition Date":     "acquired",
+	"Open Date":            "acquired",
+	"Quantity":             "quantity",
+	"Qty":                  "quantity",
+	"Shares":               "quantity",
+	"Price":                "price",
+	"Cost Basis":           "cost_basis",
+	"Cost Basis Total":     "cost_basis",
+	"Total Cost":           "cost_basis",
+	"Cost/Share":           "unit_cost",
+	"Cost Per Share":       "unit_cost",
+	"Cost Basis Per Share": "unit_cost",
+	"Market Value":         "market_value",
+	"Current Value":        "market_value",
+	"Gain/Loss $":          "gain",
+	"Gain/Loss %":          "gain_percent",
+	"Holding Period":       "term",
+	"Term":                 "term",
+}
+
+// lotTitle matches the title of Schwab's per-symbol lot details, e.g.
+// "MSTR Lot Details for Individual ...123 as of 04:15 PM ET, 2025/06/11"
+var lotTitle = regexp.MustCompile(`^([A-Z][A-Z0-9.\-]*) Lot Details(?: for (.+?))?(?: as of|$)`)
+
+var activityColumns = columnMap{
+	"Run Date":             "date",
+	"Date":                 "date",
+	"Trade Date":           "date",
+	"Account":              "account_name", // Fidelity names the account here and numbers it in "Account Number"
+	"Account Number":       "account_number",
+	"Account Name":         "account_name",
+	"Action":               "action",
+	"Transaction Type":     "action",
+	"Symbol":               "symbol",
+	"Description":          "description",
+	"Security Description": "description",
+	"Security Type":        "security_type",
+	"Quantity":             "quantity",
+	"Shares":               "quantity",
+	"Price":                "price",
+	"Price ($)":            "price",
+	"Share Price":          "price",
+	"Commission":           "commission",
+	"Commission ($)":       "commission",
+	"Commissions and Fees": "commission",
+	"Fees":                 "fees",
+	"Fees ($)":             "fees",
+	"Fees & Comm":          "fees",
+	"Accrued Interest ($)": "accrued_interest",
+	"Amount":               "amount",
+	"Amount ($)":           "amount",
+	"Net Amount":           "amount",
+	"Settlement Date":      "settlement_date",
+}
+
+// activityType classifies an activity row by its action, and for transfers by the sign of its
+// amount. Money market sweeps and other rows that move nothing in or out of the positions give "".
+func activityType(action string, amount float64) string {
+	upper := strings.ToUpper(strings.TrimSpace(action))
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(upper, w) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("CORE ACCOUNT", "SWEEP"):
+		return ""
+	case strings.HasPrefix(upper, "YOU BOUGHT"), strings.HasPrefix(upper, "BUY"), strings.HasPrefix(upper, "BOUGHT"),
+		strings.HasPrefix(upper, "REINVEST") && !has("DIV", "INTEREST"):
+		return models.TransactionBuy
+	case strings.HasPrefix(upper, "YOU SOLD"), strings.HasPrefix(upper, "SELL"), strings.HasPrefix(upper, "SOLD"):
+		return models.TransactionSell
+	case has("FEE", "MARGIN INTEREST", "TAX PAID", "TAX WITHHELD"):
+		return models.TransactionFee
+	case has("DIV", "INTEREST", "CAP GAIN", "CAPITAL GAIN"):
+		return models.TransactionDividend
+	case has("CONTRIBUTION", "DEPOSIT", "FUNDS RECEIVED", "TRANSFER RECEIVED", "WIRE RECEIVED"):
+		return models.TransactionDeposit
+	case has("WITHDRAWAL", "DISTRIBUTION", "TRANSFER PAID", "FUNDS PAID", "WIRE SENT", "FUNDS DISBURSED"):
+		return models.TransactionWithdrawal
+	case has("TRANSFER", "JOURNAL", "WIRE", "MONEYLINK"):
+		if amount > 0 {
+			return models.TransactionDeposit
+		}
+		if amount < 0 {
+			return models.TransactionWithdrawal
+		}
+	}
+	return ""
+}
+
+// ImportLots reads open tax lots from a broker lot export, or replays them with method from an
+// activity export of buys and sells
+func ImportLots(path string, method taxlots.Method) (*LotResult, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if findHeader(f.Rows, lotColumns, "acquired", "quantity") >= 0 {
+		return parseLots(f)
+	}
+
+	result, err := parseActivity(f)
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	result.Lots, result.Sales, warnings = taxlots.Replay(result.Transactions, method)
+	result.Warnings = append(result.Warnings, warnings...)
+	return result, nil
+}
+
+// ImportActivity reads the trades, income, transfers and fees of an activity export
+func ImportActivity(path string) (*LotResult, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseActivity(f)
+}
+
+// parseLots reads a lot export. Exports without a symbol column take it from a
+// "SYMBOL Lot Details" title row.
+func parseLots(f *File) (*LotResult, error) {
+	result := &LotResult{Format: "lots"}
+	var h *header
+	symbol, account := "", ""
+	for i, record := range f.Rows {
+		first := strings.TrimSpace(record[0])
+		if match := lotTitle.FindStringSubmatch(first); match != nil {
+			symbol, account, h = match[1], match[2], nil
+			continue
+		}
+		if next := lotColumns.header(record); next.has("acquired", "quantity") {
+			h = &next
+			result.UnmappedColumns = appendNew(result.UnmappedColumns, h.unmapped)
+			continue
+		}
+		if h == nil || first == "" || strings.EqualFold(first, "Total") || strings.HasPrefix(strings.ToUpper(first), "TOTAL") {
+			continue
+		}
+
+		acquired, ok := parseDate(h.get(record, "acquired"), "")
+		quantity := h.amount(record, "quantity")
+		if !ok || quantity <= 0 {
+			continue
+		}
+		lot := models.TaxLot{
+			ID:            h.get(record, "lot_id"),
+			AccountNumber: h.get(record, "account_number"),
+			AccountName:   h.get(record, "account_name"),
+			Symbol:        strings.ToUpper(h.get(record, "symbol")),
+			Acquired:      acquired,
+			Quantity:      quantity,
+			CostBasis:     math.Abs(h.amount(record, "cost_basis")),
+			Source:        "lots",
+		}
+		if lot.Symbol == "" {
+			lot.Symbol = symbol
+		}
+		if lot.AccountNumber == "" && lot.AccountName == "" {
+			lot.AccountName = account
+			if i := strings.LastIndex(account, "..."); i >= 0 {
+				lot.AccountNumber = account[i+3:] // As Schwab position exports number accounts
+			}
+		}
+		if lot.CostBasis == 0 {
+			lot.CostBasis = math.Abs(h.amount(record, "unit_cost")) * quantity
+		}
+		if lot.Symbol == "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("row %d: lot without a symbol", i+1))
+			continue
+		}
+		if lot.ID == "" {
+			lot.ID = fmt.Sprintf("%s-%s-%s-%d", lotAccount(lot), lot.Symbol, acquired.Format("20060102"), i+1)
+		}
+		result.Lots = append(result.Lots, lot)
+	}
+	if len(result.Lots) == 0 {
+		return nil, fmt.Errorf("no lots found")
+	}
+	return result, nil
+}
+
+// lotAccount returns the account number of a lot, or its name
+func lotAccount(lot models.TaxLot) string {
+	if lot.AccountNumber != "" {
+		return lot.AccountNumber
+	}
+	return lot.AccountName
+}
+
+// parseActivity reads the trades, income, transfers and fees of an activity export, skipping
+// other activity
+func parseActivity(f *File) (*LotResult, error) {
+	start := findHeader(f.Rows, activityColumns, "date", "action", "amount")
+	if start < 0 {
+		start = findHeader(f.Rows, activityColumns, "date", "action", "symbol", "quantity")
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no lot or activity header found")
+	}
+	result := &LotResult{Format: "activity"}
+	h := activityColumns.header(f.Rows[start])
+	result.UnmappedColumns = h.unmapped
+
+	skipped := 0
+	for _, record := range f.Rows[start+1:] {
+		date, ok := parseDate(h.get(record, "date"), "")
+		if !ok {
+			continue
+		}
+		amount := h.amount(record, "amount")
+		kind := activityType(h.get(record, "action"), amount)
+		if kind == "" {
+			skipped++
+			continue
+		}
+
+		tx := models.Transaction{
+			Date:          date,
+			AccountNumber: h.get(record, "account_number"),
+			AccountName:   h.get(record, "account_name"),
+			Type:          kind,
+			Symbol:        strings.ToUpper(h.get(record, "symbol")),
+			Description:   h.get(record, "description"),
+			Fees:          math.Abs(h.amount(record, "commission")) + math.Abs(h.amount(record, "fees")),
+			Amount:        amount,
+		}
+		switch kind {
+		case models.TransactionBuy, models.TransactionSell:
+			tx.Quantity = math.Abs(h.amount(record, "quantity"))
+			tx.Price = math.Abs(h.amount(record, "price"))
+			if tx.Symbol == "" || tx.Quantity == 0 {
+				skipped++
+				continue
+			}
+			if tx.Amount == 0 {
+				tx.Amount = tx.Quantity*tx.Price - tx.Fees
+				if kind == models.TransactionBuy {
+					tx.Amount = -(tx.Quantity*tx.Price + tx.Fees)
+				}
+			}
+		case models.TransactionDividend, models.TransactionDeposit:
+			tx.Amount = math.Abs(amount)
+		case models.TransactionWithdrawal, models.TransactionFee:
+			tx.Amount = -math.Abs(amount)
+		}
+		if tx.Amount == 0 {
+			skipped++
+			continue
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+	if skipped > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("skipped %d rows that are sweeps, transfers of securities or other activity", skipped))
+	}
+	return result, nil
+}
+
+// appendNew appends the values not in list yet
+func appendNew(list, values []string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			found = found || existing == v
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
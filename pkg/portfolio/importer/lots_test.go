@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/taxlots"
+)
+
+func TestImportLotsFromLotExport(t *testing.T) {
+	path := writeExport(t, "MSTR_Lot_Details.csv", `"MSTR Lot Details for Individual ...123 as of 04:15 PM ET, 2025/06/11"
+
+"Open Date","Quantity","Price","Cost/Share","Market Value","Cost Basis","Gain/Loss $","Holding Period"
+"01/15/2024","5","$380.00","$60.00","$1,900.00","$300.00","$1,600.00","Long Term"
+"03/01/2025","2","$380.00","$300.00","$760.00","$600.00","$160.00","Short Term"
+"Total","7","","","$2,660.00","$900.00","",""
+`)
+	result, err := ImportLots(path, taxlots.FIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != "lots" || len(result.Lots) != 2 {
+		t.Fatalf("expected 2 lots from a lot export, got %+v", result)
+	}
+	lot := result.Lots[0]
+	if lot.Symbol != "MSTR" || lot.AccountNumber != "123" || lot.AccountName != "Individual ...123" ||
+		lot.Quantity != 5 || lot.CostBasis != 300 || lot.Acquired.Format("2006-01-02") != "2024-01-15" {
+		t.Errorf("unexpected lot %+v", lot)
+	}
+}
+
+func TestImportLotsReplaysActivity(t *testing.T) {
+	path := writeExport(t, "Accounts_History.csv", `Run Date,Account,Account Number,Action,Symbol,Description,Quantity,Price ($),Commission ($),Fees ($),Amount ($)
+03/10/2025,Brokerage,X1,YOU SOLD STRATEGY INC (MSTR),MSTR,STRATEGY INC,-3,300,,,900
+02/20/2025,ROTH IRA,R1,YOU BOUGHT STRATEGY INC (MSTR),MSTR,STRATEGY INC,2,310,,,-620
+02/01/2025,Brokerage,X1,DIVIDEND RECEIVED,SPAXX,MONEY MARKET,,,,,1.50
+02/01/2025,Brokerage,X1,REINVESTMENT,SPAXX,MONEY MARKET,1.5,1,,,-1.50
+01/31/2025,Brokerage,X1,PURCHASE INTO CORE ACCOUNT,SPAXX,MONEY MARKET,500,1,,,-500
+01/30/2025,Brokerage,X1,Electronic Funds Transfer Received (Cash),,No Description,,,,,500
+01/15/2025,ROTH IRA,R1,FEE CHARGED ANNUAL FEE,,ANNUAL FEE,,,,,25
+06/01/2024,Brokerage,X1,YOU BOUGHT STRATEGY INC (MSTR),MSTR,STRATEGY INC,4,150,,,-600
+01/02/2024,Brokerage,X1,YOU BOUGHT STRATEGY INC (MSTR),MSTR,STRATEGY INC,2,400,,,-800
+`)
+	result, err := ImportLots(path, taxlots.HIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != "activity" || len(result.Transactions) != 8 || len(result.Warnings) != 1 {
+		t.Fatalf("expected 8 transactions and a skipped sweep, got %+v", result)
+	}
+	types := make(map[string]int)
+	for _, tx := range result.Transactions {
+		types[tx.Type]++
+	}
+	if types[models.TransactionBuy] != 4 || types[models.TransactionDividend] != 1 || types[models.TransactionDeposit] != 1 {
+		t.Errorf("unexpected transaction types %v", types)
+	}
+	if fee := result.Transactions[5]; fee.Type != models.TransactionFee || fee.Amount != -25 {
+		t.Errorf("expected a 25 fee, got %+v", fee)
+	}
+
+	// HIFO sells the 2024-01 lot at $400 first, then one $150 share
+	if len(result.Sales) != 1 || len(result.Sales[0].Lots) != 2 || result.Sales[0].Gain() != 900-800-150 {
+		t.Fatalf("unexpected sales %+v", result.Sales)
+	}
+	if len(result.Lots) != 3 || result.Lots[0].Quantity != 3 || result.Lots[0].CostBasis != 450 || result.Lots[2].AccountNumber != "R1" {
+		t.Errorf("unexpected open lots %+v", result.Lots)
+	}
+
+	// The Roth purchase within 30 days makes the loss a wash sale
+	washes := taxlots.WashSales(result.Sales, result.Transactions)
+	if len(washes) != 1 || washes[0].ReplacementAccount != "R1" || washes[0].DisallowedLoss != 50*2/3.0 {
+		t.Errorf("expected a wash sale against the Roth purchase, got %+v", washes)
+	}
+	if result.Transactions[0].Type != models.TransactionSell {
+		t.Errorf("expected the first row to be a sell, got %+v", result.Transactions[0])
+	}
+}
@@ -0,0 +1,185 @@
+package ledger
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// quantityTolerance is the share difference below which ledger and snapshot agree
+const quantityTolerance = 1e-4
+
+// key identifies a transaction across overlapping activity exports
+func key(tx models.Transaction) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%.6f|%.2f", tx.Date.Format("2006-01-02"), tx.Account(), tx.Type,
+		strings.ToUpper(tx.Symbol), tx.Quantity, tx.Amount)
+}
+
+// Merge adds imported transactions to the ledger, skipping those already in it, and returns
+// the ledger by date and the number added. Identical transactions on the same day are kept as
+// often as the export with the most of them lists them.
+func Merge(existing, imported []models.Transaction) ([]models.Transaction, int) {
+	seen := make(map[string]int, len(existing))
+	for _, tx := range existing {
+		seen[key(tx)]++
+	}
+
+	merged := append([]models.Transaction(nil), existing...)
+	added := 0
+	for _, tx := range imported {
+		k := key(tx)
+		if seen[k] > 0 {
+			seen[k]--
+			continue
+		}
+		merged = append(merged, tx)
+		added++
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Date.Before(merged[j].Date) })
+	return merged, added
+}
+
+// Between returns the transactions after from and on or before to. A zero from starts at the
+// first transaction.
+func Between(transactions []models.Transaction, from, to time.Time) []models.Transaction {
+	var between []models.Transaction
+	for _, tx := range transactions {
+		if (from.IsZero() || tx.Date.After(from)) && !tx.Date.After(to) {
+			between = append(between, tx)
+		}
+	}
+	return between
+}
+
+// Holding identifies a symbol held in an account
+type Holding struct {
+	Account string
+	Symbol  string
+}
+
+// accountAliases maps the account names of a snapshot to their numbers, so that ledgers
+// exported with names reconcile against snapshots keyed by number
+func accountAliases(portfolio *models.Portfolio) map[string]string {
+	aliases := make(map[string]string)
+	if portfolio == nil {
+		return aliases
+	}
+	for _, position := range portfolio.Positions {
+		if position.AccountName != "" && position.AccountNumber != "" {
+			aliases[position.AccountName] = position.AccountNumber
+		}
+	}
+	return aliases
+}
+
+// transactionAccount returns the account of a transaction, by number where known
+func transactionAccount(tx models.Transaction, aliases map[string]string) string {
+	if tx.AccountNumber != "" {
+		return tx.AccountNumber
+	}
+	if number, ok := aliases[tx.AccountName]; ok {
+		return number
+	}
+	return tx.AccountName
+}
+
+// positionAccount returns the account number of a position, or its name
+func positionAccount(position models.Position) string {
+	if position.AccountNumber != "" {
+		return position.AccountNumber
+	}
+	return position.AccountName
+}
+
+// Discrepancy is a holding whose ledger quantity differs from the snapshot
+type Discrepancy struct {
+	Account    string  `json:"account"`
+	Symbol     string  `json:"symbol"`
+	Snapshot   float64 `json:"snapshot"` // Shares in the snapshot
+	Ledger     float64 `json:"ledger"`   // Shares expected from the opening snapshot and the ledger
+	Difference float64 `json:"difference"`
+}
+
+// Reconciliation compares the holdings the ledger implies with a snapshot
+type Reconciliation struct {
+	From          time.Time     `json:"from"` // Opening snapshot date; zero when the ledger starts from nothing
+	To            time.Time     `json:"to"`
+	Transactions  int           `json:"transactions"` // Buys and sells replayed
+	Matched       int           `json:"matched"`      // Holdings that agree
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Reconcile replays the buys and sells after the opening snapshot, or from nothing when it is
+// nil, up to the snapshot's date and compares the resulting shares with the snapshot's. Symbols
+// for which skip returns true, such as money market sweeps, are left out.
+func Reconcile(transactions []models.Transaction, opening, snapshot *models.Portfolio, skip func(symbol string) bool) *Reconciliation {
+	result := &Reconciliation{To: snapshot.Date}
+	expected := make(map[Holding]float64)
+	if opening != nil {
+		result.From = opening.Date
+		for _, position := range opening.Positions {
+			expected[Holding{positionAccount(position), strings.ToUpper(position.Symbol)}] += position.Quantity
+		}
+	}
+
+	aliases := accountAliases(snapshot)
+	for name, number := range accountAliases(opening) {
+		if _, ok := aliases[name]; !ok {
+			aliases[name] = number
+		}
+	}
+	for _, tx := range Between(transactions, result.From, snapshot.Date) {
+		h := Holding{transactionAccount(tx, aliases), strings.ToUpper(tx.Symbol)}
+		switch tx.Type {
+		case models.TransactionBuy:
+			expected[h] += tx.Quantity
+		case models.TransactionSell:
+			expected[h] -= tx.Quantity
+		default:
+			continue
+		}
+		result.Transactions++
+	}
+
+	actual := make(map[Holding]float64)
+	for _, position := range snapshot.Positions {
+		actual[Holding{positionAccount(position), strings.ToUpper(position.Symbol)}] += position.Quantity
+	}
+
+	holdings := make(map[Holding]bool)
+	for h := range expected {
+		holdings[h] = true
+	}
+	for h := range actual {
+		holdings[h] = true
+	}
+	for h := range holdings {
+		if h.Symbol == "" || (skip != nil && skip(h.Symbol)) {
+			continue
+		}
+		difference := actual[h] - expected[h]
+		if math.Abs(difference) <= quantityTolerance {
+			result.Matched++
+			continue
+		}
+		result.Discrepancies = append(result.Discrepancies, Discrepancy{
+			Account:    h.Account,
+			Symbol:     h.Symbol,
+			Snapshot:   actual[h],
+			Ledger:     expected[h],
+			Difference: difference,
+		})
+	}
+	sort.Slice(result.Discrepancies, func(i, j int) bool {
+		di, dj := result.Discrepancies[i], result.Discrepancies[j]
+		if di.Account != dj.Account {
+			return di.Account < dj.Account
+		}
+		return di.Symbol < dj.Symbol
+	})
+	return result
+}
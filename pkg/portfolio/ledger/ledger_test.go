@@ -0,0 +1,169 @@
+package ledger
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+func day(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestMergeSkipsOverlappingExports(t *testing.T) {
+	buy := models.Transaction{Date: day("2025-01-02"), AccountNumber: "X1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 1, Amount: -300}
+	deposit := models.Transaction{Date: day("2025-01-01"), AccountNumber: "X1", Type: models.TransactionDeposit, Amount: 1000}
+
+	// Two identical buys on one day are both kept; a re-import of the same export adds nothing
+	merged, added := Merge(nil, []models.Transaction{buy, buy, deposit})
+	if added != 3 || merged[0].Type != models.TransactionDeposit {
+		t.Fatalf("expected 3 transactions by date, got %d: %+v", added, merged)
+	}
+	if merged, added = Merge(merged, []models.Transaction{buy, buy, deposit}); added != 0 || len(merged) != 3 {
+		t.Errorf("expected no duplicates, got %d added", added)
+	}
+}
+
+func TestTimeAndMoneyWeightedReturns(t *testing.T) {
+	// 10% in January, a 500 deposit at the February snapshot, then 10% in February
+	valuations := []Valuation{{day("2025-01-01"), 1000}, {day("2025-02-01"), 1600}, {day("2025-03-01"), 1760}}
+	transactions := []models.Transaction{
+		{Date: day("2025-02-01"), AccountNumber: "X1", Type: models.TransactionDeposit, Amount: 500},
+		{Date: day("2025-02-10"), AccountNumber: "X1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 1, Amount: -400},
+	}
+
+	periods := Periods(valuations, transactions)
+	if len(periods) != 2 || !near(periods[0].Return, 0.1) || !near(periods[1].Return, 0.1) || periods[0].NetFlow != 500 {
+		t.Fatalf("unexpected periods %+v", periods)
+	}
+	if twr := TWR(periods); !near(twr, 0.21) {
+		t.Errorf("expected a 21%% time-weighted return, got %.4f", twr)
+	}
+
+	// The snapshot-diff return would count the deposit as performance
+	flows := InvestorFlows(valuations, transactions)
+	if len(flows) != 3 || flows[1].Amount != -500 || flows[2].Amount != 1760 {
+		t.Fatalf("unexpected flows %+v", flows)
+	}
+	xirr, err := XIRR(flows)
+	if err != nil || xirr <= 0 || xirr > 5 {
+		t.Errorf("expected a positive annual money-weighted return, got %.4f (%v)", xirr, err)
+	}
+
+	year, err := XIRR([]CashFlow{{day("2024-01-01"), -1000}, {day("2024-12-31"), 1100}})
+	if err != nil || math.Abs(year-0.1) > 1e-4 {
+		t.Errorf("expected 10%%, got %.6f (%v)", year, err)
+	}
+	if _, err := XIRR([]CashFlow{{day("2024-01-01"), 1000}, {day("2024-12-31"), 1100}}); err == nil {
+		t.Errorf("expected no rate for flows that are all positive")
+	}
+}
+
+func TestAttributionAddsUpToGain(t *testing.T) {
+	start := &models.Portfolio{Date: day("2025-01-01"), TotalValue: 2000, Positions: []models.Position{
+		{AccountNumber: "X1", Symbol: "MSTR", Quantity: 2, CurrentValue: 800},
+		{AccountNumber: "X1", Symbol: "IBIT", Quantity: 20, CurrentValue: 1000},
+		{AccountNumber: "X1", Symbol: "SPAXX**", Quantity: 200, CurrentValue: 200},
+	}}
+	end := &models.Portfolio{Date: day("2025-03-01"), TotalValue: 2590, Positions: []models.Position{
+		{AccountNumber: "X1", Symbol: "MSTR", Quantity: 3, CurrentValue: 1350},
+		{AccountNumber: "X1", Symbol: "IBIT", Quantity: 18, CurrentValue: 990},
+		{AccountNumber: "X1", Symbol: "SPAXX**", Quantity: 250, CurrentValue: 250},
+	}}
+	transactions := []models.Transaction{
+		{Date: day("2025-01-10"), AccountNumber: "X1", Type: models.TransactionDeposit, Amount: 400},
+		{Date: day("2025-01-15"), AccountNumber: "X1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 1, Amount: -420},
+		{Date: day("2025-02-01"), AccountNumber: "X1", Type: models.TransactionSell, Symbol: "IBIT", Quantity: 2, Amount: 110},
+		{Date: day("2025-02-15"), AccountNumber: "X1", Type: models.TransactionDividend, Symbol: "SPAXX", Amount: 2},
+		{Date: day("2025-02-20"), AccountNumber: "X1", Type: models.TransactionFee, Amount: -10},
+	}
+	cash := func(symbol string) bool { return symbol == "SPAXX**" || symbol == "SPAXX" }
+
+	a := Attribute(start, end, transactions, cash)
+	if !near(a.Gain, 190) || len(a.Symbols) != 2 {
+		t.Fatalf("expected a 190 gain over two symbols, got %+v", a)
+	}
+	mstr, ibit := a.Symbols[0], a.Symbols[1]
+	if mstr.Symbol != "MSTR" || !near(mstr.Gain, 130) || ibit.Symbol != "IBIT" || !near(ibit.Gain, 100) {
+		t.Errorf("unexpected symbol gains %+v", a.Symbols)
+	}
+	if !near(a.Cash.Income, 2) || !near(a.Fees, -10) {
+		t.Errorf("expected 2 of interest and 10 of fees, got %+v and %.2f", a.Cash, a.Fees)
+	}
+	if total := mstr.Gain + ibit.Gain + a.Cash.Gain + a.Fees; !near(total, a.Gain) {
+		t.Errorf("expected attribution to add up to %.2f, got %.2f", a.Gain, total)
+	}
+}
+
+func TestReconcileReportsDiscrepancies(t *testing.T) {
+	opening := &models.Portfolio{Date: day("2025-01-01"), Positions: []models.Position{
+		{AccountNumber: "X1", AccountName: "Brokerage", Symbol: "MSTR", Quantity: 2},
+		{AccountNumber: "X1", AccountName: "Brokerage", Symbol: "SPAXX**", Quantity: 100},
+	}}
+	snapshot := &models.Portfolio{Date: day("2025-03-01"), Positions: []models.Position{
+		{AccountNumber: "X1", AccountName: "Brokerage", Symbol: "MSTR", Quantity: 3},
+		{AccountNumber: "X1", AccountName: "Brokerage", Symbol: "IBIT", Quantity: 10},
+		{AccountNumber: "X1", AccountName: "Brokerage", Symbol: "SPAXX**", Quantity: 50},
+	}}
+	transactions := []models.Transaction{
+		{Date: day("2024-12-01"), AccountNumber: "X1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 2}, // Before the opening snapshot
+		{Date: day("2025-01-15"), AccountName: "Brokerage", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 1},
+		{Date: day("2025-02-01"), AccountName: "Brokerage", Type: models.TransactionBuy, Symbol: "IBIT", Quantity: 12},
+		{Date: day("2025-03-02"), AccountName: "Brokerage", Type: models.TransactionSell, Symbol: "IBIT", Quantity: 2}, // After the snapshot
+	}
+
+	r := Reconcile(transactions, opening, snapshot, func(symbol string) bool { return symbol == "SPAXX**" })
+	if r.Transactions != 2 || r.Matched != 1 || len(r.Discrepancies) != 1 {
+		t.Fatalf("expected MSTR to match and IBIT to differ, got %+v", r)
+	}
+	if d := r.Discrepancies[0]; d.Account != "X1" || d.Symbol != "IBIT" || d.Snapshot != 10 || d.Ledger != 12 || d.Difference != -2 {
+		t.Errorf("unexpected discrepancy %+v", d)
+	}
+}
+
+func TestCalculateRisk(t *testing.T) {
+	// Bitcoin moves daily; the portfolio moves twice as much
+	moves := []float64{0.02, -0.03, 0.01, 0.04, -0.05, 0.02, -0.01, 0.03, -0.02, 0.01}
+	start := day("2025-01-01")
+	bitcoin := []Valuation{{Date: start, Value: 100}}
+	var periods []Period
+	for i, move := range moves {
+		date := start.AddDate(0, 0, i+1)
+		bitcoin = append(bitcoin, Valuation{Date: date, Value: bitcoin[i].Value * (1 + move)})
+		periods = append(periods, Period{Start: date.AddDate(0, 0, -1), End: date, Return: 2 * move})
+	}
+
+	metrics := CalculateRisk(periods, bitcoin, RiskOptions{Confidence: 0.9, Window: 5})
+	if !near(metrics.PeriodsPerYear, 365.25) || metrics.Returns != 10 {
+		t.Errorf("expected 10 daily returns, got %d at %.2f a year", metrics.Returns, metrics.PeriodsPerYear)
+	}
+	if !near(metrics.Beta, 2) || !near(metrics.Correlation, 1) {
+		t.Errorf("expected beta 2 and correlation 1, got %.4f and %.4f", metrics.Beta, metrics.Correlation)
+	}
+	// The worst 10% of 10 returns is the -10% day
+	if !near(metrics.HistoricalVaR, 10) || !near(metrics.HistoricalCVaR, 10) {
+		t.Errorf("expected a 10%% VaR and CVaR, got %.4f and %.4f", metrics.HistoricalVaR, metrics.HistoricalCVaR)
+	}
+	if metrics.ParametricCVaR <= metrics.ParametricVaR || metrics.Sortino <= metrics.Sharpe || metrics.Sharpe <= 0 {
+		t.Errorf("unexpected parametric or ratio metrics %+v", metrics.Risk)
+	}
+	if len(metrics.Rolling) != 6 || !metrics.Rolling[5].End.Equal(periods[9].End) {
+		t.Errorf("expected 6 rolling windows ending with the last day, got %d", len(metrics.Rolling))
+	}
+
+	// Snapshot returns a month apart are annualized by their length, not as if daily
+	monthly := []Period{
+		{Start: day("2025-01-01"), End: day("2025-01-31"), Return: 0.1},
+		{Start: day("2025-01-31"), End: day("2025-03-02"), Return: -0.1},
+	}
+	if vol := Volatility(monthly); !near(vol, math.Sqrt(0.02)*math.Sqrt(365.25/30)) {
+		t.Errorf("expected monthly returns annualized 12.175 times a year, got %.4f", vol)
+	}
+}
@@ -0,0 +1,272 @@
+package ledger
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// Valuation is the portfolio's value on a snapshot date
+type Valuation struct {
+	Date  time.Time
+	Value float64
+}
+
+// Period is the return between two consecutive valuations, net of deposits and withdrawals
+type Period struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	StartValue float64   `json:"start_value"`
+	EndValue   float64   `json:"end_value"`
+	NetFlow    float64   `json:"net_flow"` // Deposits less withdrawals
+	Return     float64   `json:"return"`   // Fraction, e.g. 0.05 for 5%
+}
+
+// days returns the days between two dates
+func days(from, to time.Time) float64 {
+	return to.Sub(from).Hours() / 24
+}
+
+// ExternalFlows returns the net deposits of the transactions after from and on or before to
+func ExternalFlows(transactions []models.Transaction, from, to time.Time) float64 {
+	var flow float64
+	for _, tx := range Between(transactions, from, to) {
+		if tx.External() {
+			flow += tx.Amount
+		}
+	}
+	return flow
+}
+
+// modifiedDietz returns the gain and the return from start to end with the flows in between
+// weighted by the part of the period they were invested. Flows on the end date are weighted 0.
+func modifiedDietz(start, end Valuation, transactions []models.Transaction) (gain, denominator float64) {
+	length := days(start.Date, end.Date)
+	denominator = start.Value
+	var flow float64
+	for _, tx := range Between(transactions, start.Date, end.Date) {
+		if !tx.External() {
+			continue
+		}
+		flow += tx.Amount
+		if length > 0 {
+			denominator += tx.Amount * days(tx.Date, end.Date) / length
+		}
+	}
+	return end.Value - start.Value - flow, denominator
+}
+
+// Periods returns the Modified Dietz return between each pair of consecutive valuations.
+// Linking them approximates the time-weighted return; it is exact when deposits and
+// withdrawals fall on valuation dates.
+func Periods(valuations []Valuation, transactions []models.Transaction) []Period {
+	sorted := append([]Valuation(nil), valuations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	var periods []Period
+	for i := 1; i < len(sorted); i++ {
+		start, end := sorted[i-1], sorted[i]
+		gain, denominator := modifiedDietz(start, end, transactions)
+		period := Period{
+			Start:      start.Date,
+			End:        end.Date,
+			StartValue: start.Value,
+			EndValue:   end.Value,
+			NetFlow:    ExternalFlows(transactions, start.Date, end.Date),
+		}
+		if denominator > 0 {
+			period.Return = gain / denominator
+		}
+		periods = append(periods, period)
+	}
+	return periods
+}
+
+// TWR links period returns into the time-weighted return
+func TWR(periods []Period) float64 {
+	growth := 1.0
+	for _, period := range periods {
+		growth *= 1 + period.Return
+	}
+	return growth - 1
+}
+
+// Annualize converts a return over a date range into a yearly rate
+func Annualize(r float64, from, to time.Time) float64 {
+	years := days(from, to) / 365.25
+	if years <= 0 || r <= -1 {
+		return 0
+	}
+	return math.Pow(1+r, 1/years) - 1
+}
+
+// CashFlow is money paid into (negative) or out of (positive) the portfolio by its owner
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// InvestorFlows returns the owner's cash flows from the first to the last valuation: the
+// starting value paid in, deposits paid in, withdrawals paid out and the ending value paid out
+func InvestorFlows(valuations []Valuation, transactions []models.Transaction) []CashFlow {
+	if len(valuations) < 2 {
+		return nil
+	}
+	sorted := append([]Valuation(nil), valuations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	first, last := sorted[0], sorted[len(sorted)-1]
+
+	flows := []CashFlow{{Date: first.Date, Amount: -first.Value}}
+	for _, tx := range Between(transactions, first.Date, last.Date) {
+		if tx.External() {
+			flows = append(flows, CashFlow{Date: tx.Date, Amount: -tx.Amount})
+		}
+	}
+	return append(flows, CashFlow{Date: last.Date, Amount: last.Value})
+}
+
+// ErrNoXIRR is returned when cash flows have no internal rate of return
+var ErrNoXIRR = errors.New("cash flows have no internal rate of return")
+
+// XIRR returns the annual money-weighted return of dated cash flows: the rate at which their
+// present value is zero
+func XIRR(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoXIRR
+	}
+	t0 := flows[0].Date
+	for _, f := range flows {
+		if f.Date.Before(t0) {
+			t0 = f.Date
+		}
+	}
+	npv := func(rate float64) float64 {
+		var sum float64
+		for _, f := range flows {
+			sum += f.Amount / math.Pow(1+rate, days(t0, f.Date)/365)
+		}
+		return sum
+	}
+
+	// Bisect between a total loss and a 10,000x gain; NPV falls as the rate rises when money
+	// goes in before it comes out
+	low, high := -0.9999, 100.0
+	fLow, fHigh := npv(low), npv(high)
+	if math.IsNaN(fLow) || math.IsNaN(fHigh) || fLow*fHigh > 0 {
+		return 0, ErrNoXIRR
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		fMid := npv(mid)
+		if math.Abs(fMid) < 1e-9 || high-low < 1e-12 {
+			return mid, nil
+		}
+		if fMid*fLow > 0 {
+			low, fLow = mid, fMid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+// SymbolAttribution is one symbol's share of the portfolio's gain over a period
+type SymbolAttribution struct {
+	Symbol       string  `json:"symbol"`
+	StartValue   float64 `json:"start_value"`
+	EndValue     float64 `json:"end_value"`
+	Bought       float64 `json:"bought"` // Including fees
+	Sold         float64 `json:"sold"`   // Net of fees
+	Income       float64 `json:"income"` // Dividends and interest
+	Gain         float64 `json:"gain"`
+	Contribution float64 `json:"contribution"` // Percentage points of the period's Modified Dietz return
+}
+
+// Attribution splits the gain between two snapshots by symbol
+type Attribution struct {
+	Start   time.Time           `json:"start"`
+	End     time.Time           `json:"end"`
+	Gain    float64             `json:"gain"`    // Change in value less deposits plus withdrawals
+	Return  float64             `json:"return"`  // Modified Dietz return over the whole period
+	Symbols []SymbolAttribution `json:"symbols"` // Most gain first
+	Cash    SymbolAttribution   `json:"cash"`    // Cash positions: interest and cash changes the ledger doesn't explain
+	Fees    float64             `json:"fees"`    // Account fees and taxes not tied to a trade (negative)
+}
+
+// Attribute splits the gain from start to end between the symbols held, using the ledger's
+// trades and income in between. Symbols for which cash returns true, such as money market
+// sweeps, are pooled as cash. The symbol, cash and fee gains add up to the total gain.
+func Attribute(start, end *models.Portfolio, transactions []models.Transaction, cash func(symbol string) bool) *Attribution {
+	isCash := func(symbol string) bool { return symbol == "" || (cash != nil && cash(symbol)) }
+	bySymbol := make(map[string]*SymbolAttribution)
+	get := func(symbol string) *SymbolAttribution {
+		symbol = strings.ToUpper(symbol)
+		a, ok := bySymbol[symbol]
+		if !ok {
+			a = &SymbolAttribution{Symbol: symbol}
+			bySymbol[symbol] = a
+		}
+		return a
+	}
+
+	result := &Attribution{Start: start.Date, End: end.Date, Cash: SymbolAttribution{Symbol: "CASH"}}
+	for _, position := range start.Positions {
+		if isCash(position.Symbol) {
+			result.Cash.StartValue += position.CurrentValue
+		} else {
+			get(position.Symbol).StartValue += position.CurrentValue
+		}
+	}
+	for _, position := range end.Positions {
+		if isCash(position.Symbol) {
+			result.Cash.EndValue += position.CurrentValue
+		} else {
+			get(position.Symbol).EndValue += position.CurrentValue
+		}
+	}
+
+	// Cash receives every amount except trades within cash, so its gain is what remains
+	var cashIn float64
+	for _, tx := range Between(transactions, start.Date, end.Date) {
+		switch {
+		case tx.Type == models.TransactionFee:
+			result.Fees += tx.Amount
+		case tx.Type == models.TransactionDividend && isCash(tx.Symbol):
+			result.Cash.Income += tx.Amount
+		case tx.Type == models.TransactionDividend:
+			get(tx.Symbol).Income += tx.Amount
+		case (tx.Type == models.TransactionBuy || tx.Type == models.TransactionSell) && isCash(tx.Symbol):
+			continue
+		case tx.Type == models.TransactionBuy:
+			get(tx.Symbol).Bought -= tx.Amount
+		case tx.Type == models.TransactionSell:
+			get(tx.Symbol).Sold += tx.Amount
+		}
+		cashIn += tx.Amount
+	}
+
+	gain, denominator := modifiedDietz(Valuation{start.Date, start.TotalValue}, Valuation{end.Date, end.TotalValue}, transactions)
+	result.Gain = gain
+	if denominator > 0 {
+		result.Return = gain / denominator
+	}
+	contribution := func(a *SymbolAttribution) {
+		if denominator > 0 {
+			a.Contribution = a.Gain / denominator * 100
+		}
+	}
+
+	for _, a := range bySymbol {
+		a.Gain = a.EndValue - a.StartValue - a.Bought + a.Sold + a.Income
+		contribution(a)
+		result.Symbols = append(result.Symbols, *a)
+	}
+	sort.Slice(result.Symbols, func(i, j int) bool { return result.Symbols[i].Gain > result.Symbols[j].Gain })
+	result.Cash.Gain = result.Cash.EndValue - result.Cash.StartValue - cashIn + result.Cash.Income
+	contribution(&result.Cash)
+	return result
+}
@@ -0,0 +1,239 @@
+package ledger
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// RiskOptions configures CalculateRisk
+type RiskOptions struct {
+	RiskFreeRate float64 // Annual rate for Sharpe and Sortino, e.g. 0.04
+	Confidence   float64 // VaR and CVaR confidence, e.g. 0.95
+	Window       int     // Returns in each rolling window; 0 for none
+}
+
+// DefaultRiskOptions are a 4% risk-free rate, 95% VaR and a 90-day rolling window
+var DefaultRiskOptions = RiskOptions{RiskFreeRate: 0.04, Confidence: 0.95, Window: 90}
+
+// Risk holds risk metrics of a return series. Percentages are of the portfolio value; VaR and
+// CVaR are losses over one period of the series (a day for daily valuations) and positive.
+type Risk struct {
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Returns        int       `json:"returns"`
+	PeriodsPerYear float64   `json:"periods_per_year"`
+	Volatility     float64   `json:"volatility"`      // Annualized, percent
+	DownsideDev    float64   `json:"downside_dev"`    // Annualized, below the risk-free rate, percent
+	HistoricalVaR  float64   `json:"historical_var"`  // Loss exceeded in 1-confidence of the periods
+	HistoricalCVaR float64   `json:"historical_cvar"` // Average loss in those periods
+	ParametricVaR  float64   `json:"parametric_var"`  // From a normal distribution with the sample mean and volatility
+	ParametricCVaR float64   `json:"parametric_cvar"` // Normal expected shortfall
+	Sharpe         float64   `json:"sharpe"`          // Annualized excess return over volatility
+	Sortino        float64   `json:"sortino"`         // Annualized excess return over downside deviation
+	Beta           float64   `json:"beta,omitempty"`  // To the benchmark
+	Correlation    float64   `json:"correlation,omitempty"`
+}
+
+// RiskMetrics holds a series' risk over its whole span and over rolling windows
+type RiskMetrics struct {
+	RiskFreeRate float64 `json:"risk_free_rate"`
+	Confidence   float64 `json:"confidence"`
+	Window       int     `json:"window,omitempty"`
+	Risk
+	Rolling []Risk `json:"rolling,omitempty"` // One per window end, oldest first
+}
+
+// periodsPerYear returns how many periods of the average length fit in a year
+func periodsPerYear(periods []Period) float64 {
+	if len(periods) == 0 {
+		return 0
+	}
+	length := days(periods[0].Start, periods[len(periods)-1].End) / float64(len(periods))
+	if length <= 0 {
+		return 0
+	}
+	return 365.25 / length
+}
+
+// meanStd returns the mean and sample standard deviation of xs
+func meanStd(xs []float64) (mean, std float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	var variance float64
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(xs)-1))
+}
+
+// Volatility returns the annualized standard deviation of the period returns, as a fraction.
+// Periods are annualized by their average length, so snapshot-to-snapshot returns aren't
+// treated as daily.
+func Volatility(periods []Period) float64 {
+	returns := make([]float64, len(periods))
+	for i, period := range periods {
+		returns[i] = period.Return
+	}
+	_, std := meanStd(returns)
+	return std * math.Sqrt(periodsPerYear(periods))
+}
+
+// normalQuantile returns the standard normal quantile of p
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// normalDensity returns the standard normal density at z
+func normalDensity(z float64) float64 {
+	return math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+}
+
+// ValueAtRisk returns the historical VaR and CVaR of returns at confidence, as positive
+// fractional losses: the loss of the worst 1-confidence of the returns, and their average
+func ValueAtRisk(returns []float64, confidence float64) (VaR, CVaR float64) {
+	if len(returns) == 0 {
+		return 0, 0
+	}
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+	tail := int(math.Ceil((1 - confidence) * float64(len(sorted))))
+	if tail < 1 {
+		tail = 1
+	}
+	var sum float64
+	for _, r := range sorted[:tail] {
+		sum += r
+	}
+	return -sorted[tail-1], -sum / float64(tail)
+}
+
+// ParametricValueAtRisk returns the VaR and CVaR at confidence of normally distributed
+// returns with mean and standard deviation std, as positive fractional losses
+func ParametricValueAtRisk(mean, std, confidence float64) (VaR, CVaR float64) {
+	z := normalQuantile(confidence)
+	return std*z - mean, std*normalDensity(z)/(1-confidence) - mean
+}
+
+// benchmarkReturns returns the benchmark's return over each period from the last close on or
+// before its start and end; ok is false for periods without both
+func benchmarkReturns(periods []Period, benchmark []Valuation) (returns []float64, ok []bool) {
+	sorted := append([]Valuation(nil), benchmark...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	valueOn := func(date time.Time) (float64, bool) {
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Date.After(date) })
+		if i == 0 || sorted[i-1].Value <= 0 {
+			return 0, false
+		}
+		return sorted[i-1].Value, true
+	}
+
+	returns, ok = make([]float64, len(periods)), make([]bool, len(periods))
+	for i, period := range periods {
+		start, okStart := valueOn(period.Start)
+		end, okEnd := valueOn(period.End)
+		if okStart && okEnd {
+			returns[i], ok[i] = end/start-1, true
+		}
+	}
+	return returns, ok
+}
+
+// riskOf computes the risk of periods; benchmark returns are used where ok
+func riskOf(periods []Period, benchmark []float64, ok []bool, opts RiskOptions) Risk {
+	risk := Risk{Returns: len(periods)}
+	if len(periods) == 0 {
+		return risk
+	}
+	risk.Start, risk.End = periods[0].Start, periods[len(periods)-1].End
+	risk.PeriodsPerYear = periodsPerYear(periods)
+	n := risk.PeriodsPerYear
+
+	returns := make([]float64, len(periods))
+	for i, period := range periods {
+		returns[i] = period.Return
+	}
+	mean, std := meanStd(returns)
+	risk.Volatility = std * math.Sqrt(n) * 100
+
+	// Risk-free return per period, compounded to the annual rate
+	riskFree := 0.0
+	if n > 0 {
+		riskFree = math.Pow(1+opts.RiskFreeRate, 1/n) - 1
+	}
+	var downside float64
+	for _, r := range returns {
+		if r < riskFree {
+			downside += (r - riskFree) * (r - riskFree)
+		}
+	}
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+	risk.DownsideDev = downsideDev * math.Sqrt(n) * 100
+	if std > 0 {
+		risk.Sharpe = (mean - riskFree) / std * math.Sqrt(n)
+	}
+	if downsideDev > 0 {
+		risk.Sortino = (mean - riskFree) / downsideDev * math.Sqrt(n)
+	}
+
+	VaR, CVaR := ValueAtRisk(returns, opts.Confidence)
+	risk.HistoricalVaR, risk.HistoricalCVaR = VaR*100, CVaR*100
+	VaR, CVaR = ParametricValueAtRisk(mean, std, opts.Confidence)
+	risk.ParametricVaR, risk.ParametricCVaR = VaR*100, CVaR*100
+
+	// Beta and correlation over the periods the benchmark covers
+	var portfolio, market []float64
+	for i := range periods {
+		if i < len(ok) && ok[i] {
+			portfolio = append(portfolio, returns[i])
+			market = append(market, benchmark[i])
+		}
+	}
+	if len(market) > 1 {
+		portfolioMean, portfolioStd := meanStd(portfolio)
+		marketMean, marketStd := meanStd(market)
+		var covariance float64
+		for i := range market {
+			covariance += (portfolio[i] - portfolioMean) * (market[i] - marketMean)
+		}
+		covariance /= float64(len(market) - 1)
+		if marketStd > 0 {
+			risk.Beta = covariance / (marketStd * marketStd)
+			if portfolioStd > 0 {
+				risk.Correlation = covariance / (portfolioStd * marketStd)
+			}
+		}
+	}
+	return risk
+}
+
+// CalculateRisk computes the risk of the period returns over their whole span and, with a
+// window, over each run of Window consecutive periods. Beta and correlation are to the
+// benchmark's valuations (e.g. Bitcoin closes) over the same periods; without one they are 0.
+func CalculateRisk(periods []Period, benchmark []Valuation, opts RiskOptions) *RiskMetrics {
+	if opts.Confidence <= 0 || opts.Confidence >= 1 {
+		opts.Confidence = DefaultRiskOptions.Confidence
+	}
+	market, ok := benchmarkReturns(periods, benchmark)
+	metrics := &RiskMetrics{
+		RiskFreeRate: opts.RiskFreeRate,
+		Confidence:   opts.Confidence,
+		Risk:         riskOf(periods, market, ok, opts),
+	}
+	if opts.Window > 1 && opts.Window <= len(periods) {
+		metrics.Window = opts.Window
+		for end := opts.Window; end <= len(periods); end++ {
+			start := end - opts.Window
+			metrics.Rolling = append(metrics.Rolling, riskOf(periods[start:end], market[start:end], ok[start:end], opts))
+		}
+	}
+	return metrics
+}
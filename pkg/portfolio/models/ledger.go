@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Ledger is the persisted activity of all accounts, imported from broker activity exports
+type Ledger struct {
+	SchemaVersion int           `json:"schema_version,omitempty"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	Transactions  []Transaction `json:"transactions"` // By date
+}
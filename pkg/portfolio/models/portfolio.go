@@ -1,7 +1,11 @@
 package models
 
 import (
+	"strings"
 	"time"
+	"unicode"
+
+	sharedmodels "github.com/ultrarare-tech/mNAV/pkg/shared/models"
 )
 
 // Position represents a single position in the portfolio
@@ -22,12 +26,25 @@ type Position struct {
 	CostBasisTotal   float64 `json:"cost_basis_total" csv:"Cost Basis Total"`
 	AverageCostBasis float64 `json:"average_cost_basis" csv:"Average Cost Basis"`
 	Type             string  `json:"type" csv:"Type"`
+	Currency         string  `json:"currency,omitempty" csv:"Currency"` // Currency of the price, value and cost fields (empty is USD)
+}
+
+// MarketValue returns the position's current value tagged with its currency
+func (p Position) MarketValue() sharedmodels.Money {
+	return sharedmodels.Money{Amount: p.CurrentValue, Currency: sharedmodels.CurrencyCode(p.Currency)}
+}
+
+// CostBasis returns the position's total cost basis tagged with its currency
+func (p Position) CostBasis() sharedmodels.Money {
+	return sharedmodels.Money{Amount: p.CostBasisTotal, Currency: sharedmodels.CurrencyCode(p.Currency)}
 }
 
 // Portfolio represents a complete portfolio snapshot
 type Portfolio struct {
+	SchemaVersion    int                 `json:"schema_version,omitempty"`
 	Date             time.Time           `json:"date"`
 	SourceFile       string              `json:"source_file"`
+	Currency         string              `json:"currency,omitempty"` // Currency of the totals, accounts and allocation (empty is USD)
 	Positions        []Position          `json:"positions"`
 	Accounts         map[string]*Account `json:"accounts"`
 	TotalValue       float64             `json:"total_value"`
@@ -49,19 +66,136 @@ type Account struct {
 	Positions        []Position `json:"positions"`
 }
 
-// AssetAllocation represents portfolio allocation breakdown
+// MaskAccount hides account numbers for display: every word of s with four or more digits
+// keeps only its last four characters, so "Individual Z23889908" becomes "Individual ****9908".
+// Account names without numbers are unchanged.
+func MaskAccount(s string) string {
+	words := strings.Fields(s)
+	masked := false
+	for i, word := range words {
+		digits := 0
+		for _, r := range word {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		if runes := []rune(word); digits >= 4 && len(runes) > 4 {
+			words[i] = "****" + string(runes[len(runes)-4:])
+			masked = true
+		}
+	}
+	if !masked {
+		return s
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskAccountIn masks the account number wherever it appears in s, such as in a lot ID
+func MaskAccountIn(s, accountNumber string) string {
+	if accountNumber == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, accountNumber, MaskAccount(accountNumber))
+}
+
+// AssetAllocation represents portfolio allocation breakdown by asset class
 type AssetAllocation struct {
-	FBTCValue       float64 `json:"fbtc_value"`
-	FBTCPercent     float64 `json:"fbtc_percent"`
-	MSTRValue       float64 `json:"mstr_value"`
-	MSTRPercent     float64 `json:"mstr_percent"`
-	GLDValue        float64 `json:"gld_value"`
-	GLDPercent      float64 `json:"gld_percent"`
-	OtherValue      float64 `json:"other_value"`
-	OtherPercent    float64 `json:"other_percent"`
-	BitcoinExposure float64 `json:"bitcoin_exposure"`
-	BitcoinPercent  float64 `json:"bitcoin_percent"`
-	FBTCMSTRRatio   float64 `json:"fbtc_mstr_ratio"`
+	Classes           map[string]*ClassAllocation `json:"classes"`          // By asset class (spot_btc, btc_treasury, gold, cash, other)
+	BitcoinExposure   float64                     `json:"bitcoin_exposure"` // Value of the Bitcoin owned through positions (see LookThrough)
+	BitcoinPercent    float64                     `json:"bitcoin_percent"`
+	SpotTreasuryRatio float64                     `json:"spot_treasury_ratio"`    // Spot BTC proxy value per unit of BTC treasury equity (X:1)
+	LookThrough       *LookThroughExposure        `json:"look_through,omitempty"` // Nil without a Bitcoin price; exposure is then value × btc_factor
+}
+
+// LookThroughExposure is the Bitcoin a portfolio owns through its positions. Treasury company
+// shares own the company's Bitcoin per share; other positions own their value × btc_factor.
+type LookThroughExposure struct {
+	BitcoinPrice   float64            `json:"bitcoin_price"` // On the snapshot date, in the portfolio currency
+	BTC            float64            `json:"btc"`
+	Sats           int64              `json:"sats"`
+	EffectiveBTC   float64            `json:"effective_btc"`   // Bitcoin that would move the portfolio as much, with treasury equity levered
+	PremiumPaid    float64            `json:"premium_paid"`    // Treasury equity value above the value of its look-through Bitcoin
+	PremiumPercent float64            `json:"premium_percent"` // PremiumPaid over the look-through Bitcoin value of the same positions
+	Positions      []PositionExposure `json:"positions"`       // By symbol, most Bitcoin first
+}
+
+// PositionExposure is the look-through Bitcoin of one symbol across all accounts
+type PositionExposure struct {
+	Symbol       string  `json:"symbol"`
+	Class        string  `json:"class"`
+	Quantity     float64 `json:"quantity"`
+	Value        float64 `json:"value"`
+	BTCPerShare  float64 `json:"btc_per_share,omitempty"` // Treasury companies only
+	BTC          float64 `json:"btc"`
+	Premium      float64 `json:"premium"`  // Value above BTC × Bitcoin price
+	Leverage     float64 `json:"leverage"` // Enterprise value over market cap; 1 for unlevered positions
+	EffectiveBTC float64 `json:"effective_btc"`
+	Source       string  `json:"source"` // "filings", "registry" or "btc_factor"
+}
+
+// MNAV returns the position's value over the value of its look-through Bitcoin
+func (p PositionExposure) MNAV() float64 {
+	if p.BTC <= 0 || p.Value == p.Premium {
+		return 0
+	}
+	return p.Value / (p.Value - p.Premium)
+}
+
+// ClassAllocation is the part of the portfolio held in one asset class
+type ClassAllocation struct {
+	Value   float64            `json:"value"`
+	Percent float64            `json:"percent"`
+	Symbols map[string]float64 `json:"symbols"` // Value by symbol
+}
+
+// Value returns the value held in a class
+func (a AssetAllocation) Value(class string) float64 {
+	if c, ok := a.Classes[class]; ok {
+		return c.Value
+	}
+	return 0
+}
+
+// Percent returns the share of the portfolio held in a class
+func (a AssetAllocation) Percent(class string) float64 {
+	if c, ok := a.Classes[class]; ok {
+		return c.Percent
+	}
+	return 0
+}
+
+// Clone returns a copy that shares no class or symbol maps with a
+func (a AssetAllocation) Clone() AssetAllocation {
+	clone := a
+	clone.Classes = make(map[string]*ClassAllocation, len(a.Classes))
+	for name, class := range a.Classes {
+		c := *class
+		c.Symbols = make(map[string]float64, len(class.Symbols))
+		for symbol, value := range class.Symbols {
+			c.Symbols[symbol] = value
+		}
+		clone.Classes[name] = &c
+	}
+	return clone
+}
+
+// Move adds amount to a symbol's value in a class and updates the class percent
+func (a *AssetAllocation) Move(class, symbol string, amount, totalValue float64) {
+	if a.Classes == nil {
+		a.Classes = make(map[string]*ClassAllocation)
+	}
+	c, ok := a.Classes[class]
+	if !ok {
+		c = &ClassAllocation{Symbols: make(map[string]float64)}
+		a.Classes[class] = c
+	}
+	c.Value += amount
+	if symbol != "" {
+		c.Symbols[symbol] += amount
+	}
+	if totalValue > 0 {
+		c.Percent = c.Value / totalValue * 100
+	}
 }
 
 // SymbolSummary represents aggregated data for a specific symbol across all accounts
@@ -99,24 +233,31 @@ type PortfolioChanges struct {
 
 // PortfolioSummary represents a high-level portfolio summary
 type PortfolioSummary struct {
-	Date            time.Time       `json:"date"`
-	TotalValue      float64         `json:"total_value"`
-	BitcoinExposure float64         `json:"bitcoin_exposure"`
-	FBTCMSTRRatio   float64         `json:"fbtc_mstr_ratio"`
-	TopSymbols      []SymbolSummary `json:"top_symbols"`
-	AssetAllocation AssetAllocation `json:"asset_allocation"`
+	Date              time.Time       `json:"date"`
+	TotalValue        float64         `json:"total_value"`
+	BitcoinExposure   float64         `json:"bitcoin_exposure"`
+	SpotTreasuryRatio float64         `json:"spot_treasury_ratio"`
+	TopSymbols        []SymbolSummary `json:"top_symbols"`
+	AssetAllocation   AssetAllocation `json:"asset_allocation"`
 }
 
-// RebalanceRecommendation represents suggested portfolio rebalancing
+// RebalanceRecommendation represents suggested rebalancing between the spot BTC proxy
+// and BTC treasury equity classes
 type RebalanceRecommendation struct {
 	CurrentRatio    float64            `json:"current_ratio"`
 	TargetRatio     float64            `json:"target_ratio"`
-	TradeAmount     float64            `json:"trade_amount"`
-	FBTCToSell      float64            `json:"fbtc_to_sell"`
-	MSTRToBuy       float64            `json:"mstr_to_buy"`
+	TradeAmount     float64            `json:"trade_amount"` // Value moved; positive from spot into treasury equity
 	NewAllocation   AssetAllocation    `json:"new_allocation"`
 	ReasonableRange bool               `json:"reasonable_range"`
 	Trades          []RecommendedTrade `json:"trades"`
+	RealizedGain    float64            `json:"realized_gain"` // Across taxable sells
+	EstimatedTax    float64            `json:"estimated_tax"`
+	Warnings        []string           `json:"warnings,omitempty"` // Wash sale risks and lots that don't cover a sale
+
+	// Deposits and withdrawals (see analyzer.CalculateCashRebalance)
+	Cash            float64 `json:"cash,omitempty"`              // Deposit invested (positive) or withdrawal raised (negative)
+	Remaining       float64 `json:"remaining,omitempty"`         // Value a sell-and-buy still moves to the target; positive from spot into treasury equity
+	DepositToTarget float64 `json:"deposit_to_target,omitempty"` // Smallest deposit that reaches the target without selling
 }
 
 // RecommendedTrade represents a specific trade recommendation
@@ -126,4 +267,17 @@ type RecommendedTrade struct {
 	Shares         float64 `json:"shares"`
 	EstimatedValue float64 `json:"estimated_value"`
 	Account        string  `json:"account"`
+	AccountType    string  `json:"account_type,omitempty"` // taxable, ira, roth or hsa
+
+	// Tax impact of sells in taxable accounts
+	Lots          []LotSale `json:"lots,omitempty"`            // Lots chosen by the lot method; empty when estimated from average cost
+	ShortTermGain float64   `json:"short_term_gain,omitempty"` // Includes gains of unknown holding period
+	LongTermGain  float64   `json:"long_term_gain,omitempty"`
+	EstimatedTax  float64   `json:"estimated_tax,omitempty"` // Negative when losses save tax
+	TaxNote       string    `json:"tax_note,omitempty"`
+}
+
+// RealizedGain returns the gain the trade realizes, negative for a loss
+func (t RecommendedTrade) RealizedGain() float64 {
+	return t.ShortTermGain + t.LongTermGain
 }
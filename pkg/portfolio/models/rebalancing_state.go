@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// RebalancingState is the persisted position of the rebalancing rule state machine.
+// The active ratio only changes when mNAV leaves the active rule's band.
+type RebalancingState struct {
+	SchemaVersion int                     `json:"schema_version,omitempty"`
+	ActiveRatio   float64                 `json:"active_ratio"` // Target Bitcoin:MSTR ratio (X:1); 0 before the first evaluation
+	DownThreshold float64                 `json:"down_threshold"`
+	UpThreshold   float64                 `json:"up_threshold"`
+	ActiveSince   time.Time               `json:"active_since"`
+	LastMNAV      float64                 `json:"last_mnav"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+	Transitions   []RebalancingTransition `json:"transitions"`
+}
+
+// RebalancingTransition records a change of active rule
+type RebalancingTransition struct {
+	Date      time.Time `json:"date"`
+	MNAV      float64   `json:"mnav"`
+	FromRatio float64   `json:"from_ratio"` // 0 for the initial rule
+	ToRatio   float64   `json:"to_ratio"`
+	Reason    string    `json:"reason"`
+}
+
+// LastTransition returns the most recent transition, or nil if there is none
+func (s *RebalancingState) LastTransition() *RebalancingTransition {
+	if s == nil || len(s.Transitions) == 0 {
+		return nil
+	}
+	return &s.Transitions[len(s.Transitions)-1]
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Transaction types of the portfolio activity ledger
+const (
+	TransactionBuy        = "BUY"
+	TransactionSell       = "SELL"
+	TransactionDividend   = "DIVIDEND" // Dividends and interest
+	TransactionDeposit    = "DEPOSIT"
+	TransactionWithdrawal = "WITHDRAWAL"
+	TransactionFee        = "FEE"
+)
+
+// Transaction is a dated entry in a brokerage account's activity. Quantity is always positive
+// and only set for buys and sells; Amount is the cash moved into the account, negative for
+// buys, withdrawals and fees, and includes Fees.
+type Transaction struct {
+	Date          time.Time `json:"date"`
+	AccountNumber string    `json:"account_number"`
+	AccountName   string    `json:"account_name"`
+	Type          string    `json:"type"`
+	Symbol        string    `json:"symbol"`
+	Description   string    `json:"description,omitempty"`
+	Quantity      float64   `json:"quantity"`
+	Price         float64   `json:"price"`
+	Fees          float64   `json:"fees,omitempty"`
+	Amount        float64   `json:"amount"`
+}
+
+// Account returns the account number, or the name when the export has no numbers
+func (t Transaction) Account() string {
+	if t.AccountNumber != "" {
+		return t.AccountNumber
+	}
+	return t.AccountName
+}
+
+// External reports whether the transaction moves money into or out of the portfolio, rather
+// than being part of its performance
+func (t Transaction) External() bool {
+	return t.Type == TransactionDeposit || t.Type == TransactionWithdrawal
+}
+
+// TaxLot is a purchase of shares that are still held
+type TaxLot struct {
+	ID            string    `json:"id"`
+	AccountNumber string    `json:"account_number"`
+	AccountName   string    `json:"account_name"`
+	Symbol        string    `json:"symbol"`
+	Acquired      time.Time `json:"acquired"`
+	Quantity      float64   `json:"quantity"`
+	CostBasis     float64   `json:"cost_basis"` // Total, including fees
+	Source        string    `json:"source"`     // Broker of a lot export, or "ledger" when replayed from activity
+}
+
+// UnitCost returns the cost basis per share
+func (l TaxLot) UnitCost() float64 {
+	if l.Quantity <= 0 {
+		return 0
+	}
+	return l.CostBasis / l.Quantity
+}
+
+// LongTerm reports whether a sale on date is long-term: more than one year after acquisition
+func (l TaxLot) LongTerm(date time.Time) bool {
+	return date.After(l.Acquired.AddDate(1, 0, 0))
+}
+
+// HoldingDays returns the days the lot has been held on date
+func (l TaxLot) HoldingDays(date time.Time) int {
+	return int(date.Sub(l.Acquired).Hours() / 24)
+}
+
+// DisplayID returns the lot's ID with its account number masked (see MaskAccount)
+func (l TaxLot) DisplayID() string {
+	return MaskAccountIn(l.ID, l.AccountNumber)
+}
+
+// InAccount reports whether the lot is held in the account with this number, masked number
+// or name
+func (l TaxLot) InAccount(account string) bool {
+	if account == "" {
+		return false
+	}
+	return l.AccountNumber == account || l.AccountName == account ||
+		(l.AccountNumber != "" && MaskAccount(l.AccountNumber) == account)
+}
+
+// TaxLots is the persisted set of open lots across all accounts
+type TaxLots struct {
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Lots          []TaxLot  `json:"lots"`
+}
+
+// For returns the lots of a symbol held in an account (number or name)
+func (s *TaxLots) For(account, symbol string) []TaxLot {
+	if s == nil {
+		return nil
+	}
+	var lots []TaxLot
+	for _, lot := range s.Lots {
+		if lot.InAccount(account) && strings.EqualFold(lot.Symbol, symbol) {
+			lots = append(lots, lot)
+		}
+	}
+	return lots
+}
+
+// Symbol returns the lots of a symbol across all accounts
+func (s *TaxLots) Symbol(symbol string) []TaxLot {
+	if s == nil {
+		return nil
+	}
+	var lots []TaxLot
+	for _, lot := range s.Lots {
+		if strings.EqualFold(lot.Symbol, symbol) {
+			lots = append(lots, lot)
+		}
+	}
+	return lots
+}
+
+// LotSale is the part of a lot disposed of by a sale
+type LotSale struct {
+	LotID     string    `json:"lot_id"`
+	Acquired  time.Time `json:"acquired"`
+	Quantity  float64   `json:"quantity"`
+	Proceeds  float64   `json:"proceeds"`
+	CostBasis float64   `json:"cost_basis"`
+	Gain      float64   `json:"gain"`
+	LongTerm  bool      `json:"long_term"`
+}
+
+// RealizedSale is a sale matched against the lots it disposed of
+type RealizedSale struct {
+	Date     time.Time `json:"date"`
+	Account  string    `json:"account"`
+	Symbol   string    `json:"symbol"`
+	Quantity float64   `json:"quantity"`
+	Proceeds float64   `json:"proceeds"`
+	Lots     []LotSale `json:"lots"`
+}
+
+// Gain returns the realized gain, negative for a loss
+func (s RealizedSale) Gain() float64 {
+	var gain float64
+	for _, lot := range s.Lots {
+		gain += lot.Gain
+	}
+	return gain
+}
+
+// WashSale is a loss sale with a purchase of the same security in any account within 30 days
+// before or after it. The disallowed part of the loss is added to the replacement's basis.
+type WashSale struct {
+	Symbol              string    `json:"symbol"`
+	SaleAccount         string    `json:"sale_account"`
+	SaleDate            time.Time `json:"sale_date"`
+	Loss                float64   `json:"loss"` // Positive
+	ReplacementAccount  string    `json:"replacement_account"`
+	ReplacementDate     time.Time `json:"replacement_date"`
+	ReplacementQuantity float64   `json:"replacement_quantity"`
+	DisallowedLoss      float64   `json:"disallowed_loss"`
+}
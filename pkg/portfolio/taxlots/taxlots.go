@@ -0,0 +1,294 @@
+package taxlots
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+// Method chooses which lots a sale disposes of
+type Method string
+
+// Lot selection methods
+const (
+	FIFO           Method = "fifo"             // Oldest lots first, the broker default
+	HIFO           Method = "hifo"             // Highest cost lots first, realizing the least gain
+	SpecificID     Method = "specific"         // Named lots, in the order given
+	AvoidShortTerm Method = "avoid-short-term" // Long-term lots first, highest cost first within each term
+)
+
+// Methods lists the lot selection methods
+var Methods = []Method{FIFO, HIFO, SpecificID, AvoidShortTerm}
+
+// ParseMethod returns the method with the given name
+func ParseMethod(name string) (Method, error) {
+	for _, m := range Methods {
+		if strings.EqualFold(name, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown lot method %q (want one of fifo, hifo, specific, avoid-short-term)", name)
+}
+
+// ErrInsufficientLots is returned when the lots hold fewer shares than a sale
+var ErrInsufficientLots = errors.New("lots do not cover the sale")
+
+// quantityEpsilon absorbs rounding in share quantities
+const quantityEpsilon = 1e-9
+
+// Select picks the lots a sale of quantity shares at price on date disposes of. ids names the
+// lots for SpecificID, by ID or as displayed with a masked account number. When the lots hold
+// too few shares it returns the sales it could match and an error wrapping ErrInsufficientLots.
+func Select(lots []models.TaxLot, quantity, price float64, date time.Time, method Method, ids []string) ([]models.LotSale, error) {
+	ordered, err := order(lots, date, method, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var sales []models.LotSale
+	remaining := quantity
+	for _, lot := range ordered {
+		if remaining <= quantityEpsilon {
+			break
+		}
+		if lot.Quantity <= 0 {
+			continue
+		}
+		sold := math.Min(remaining, lot.Quantity)
+		sale := models.LotSale{
+			LotID:     lot.ID,
+			Acquired:  lot.Acquired,
+			Quantity:  sold,
+			Proceeds:  sold * price,
+			CostBasis: sold * lot.UnitCost(),
+			LongTerm:  lot.LongTerm(date),
+		}
+		sale.Gain = sale.Proceeds - sale.CostBasis
+		sales = append(sales, sale)
+		remaining -= sold
+	}
+
+	if remaining > quantityEpsilon {
+		return sales, fmt.Errorf("%.4f of %.4f shares unmatched: %w", remaining, quantity, ErrInsufficientLots)
+	}
+	return sales, nil
+}
+
+// order returns the lots in the order a method sells them
+func order(lots []models.TaxLot, date time.Time, method Method, ids []string) ([]models.TaxLot, error) {
+	ordered := append([]models.TaxLot(nil), lots...)
+	switch method {
+	case FIFO, "":
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Acquired.Before(ordered[j].Acquired) })
+	case HIFO:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].UnitCost() > ordered[j].UnitCost() })
+	case AvoidShortTerm:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if li, lj := ordered[i].LongTerm(date), ordered[j].LongTerm(date); li != lj {
+				return li
+			}
+			return ordered[i].UnitCost() > ordered[j].UnitCost()
+		})
+	case SpecificID:
+		byID := make(map[string]models.TaxLot, len(lots))
+		for _, lot := range lots {
+			byID[lot.DisplayID()] = lot // IDs as printed, with masked account numbers
+		}
+		for _, lot := range lots {
+			byID[lot.ID] = lot
+		}
+		ordered = ordered[:0]
+		for _, id := range ids {
+			lot, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("no lot %q", id)
+			}
+			ordered = append(ordered, lot)
+		}
+	default:
+		return nil, fmt.Errorf("unknown lot method %q", method)
+	}
+	return ordered, nil
+}
+
+// Remove takes sold quantities out of the lots they came from, dropping emptied lots
+func Remove(lots []models.TaxLot, sales []models.LotSale) []models.TaxLot {
+	sold := make(map[string]float64)
+	for _, sale := range sales {
+		sold[sale.LotID] += sale.Quantity
+	}
+
+	var remaining []models.TaxLot
+	for _, lot := range lots {
+		if q := sold[lot.ID]; q > 0 {
+			fraction := math.Min(q/lot.Quantity, 1)
+			lot.CostBasis -= lot.CostBasis * fraction
+			lot.Quantity -= q
+		}
+		if lot.Quantity > quantityEpsilon {
+			remaining = append(remaining, lot)
+		}
+	}
+	return remaining
+}
+
+// Replay builds the open lots and realized sales from trades. Each buy opens a lot; sells
+// dispose of lots in the same account with method (FIFO when empty), as brokers do without
+// a specific-ID instruction. Sells without enough lots are returned as warnings.
+func Replay(transactions []models.Transaction, method Method) ([]models.TaxLot, []models.RealizedSale, []string) {
+	sorted := append([]models.Transaction(nil), transactions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	var lots []models.TaxLot
+	var sales []models.RealizedSale
+	var warnings []string
+	for i, tx := range sorted {
+		symbol := strings.ToUpper(tx.Symbol)
+		switch tx.Type {
+		case models.TransactionBuy:
+			lots = append(lots, models.TaxLot{
+				ID:            fmt.Sprintf("%s-%s-%s-%d", tx.Account(), symbol, tx.Date.Format("20060102"), i+1),
+				AccountNumber: tx.AccountNumber,
+				AccountName:   tx.AccountName,
+				Symbol:        symbol,
+				Acquired:      tx.Date,
+				Quantity:      tx.Quantity,
+				CostBasis:     math.Abs(tx.Amount),
+				Source:        "ledger",
+			})
+		case models.TransactionSell:
+			var held []models.TaxLot
+			for _, lot := range lots {
+				if lot.InAccount(tx.Account()) && lot.Symbol == symbol {
+					held = append(held, lot)
+				}
+			}
+			price := tx.Price
+			if tx.Quantity > 0 && tx.Amount != 0 {
+				price = math.Abs(tx.Amount) / tx.Quantity // Net of fees
+			}
+			matched, err := Select(held, tx.Quantity, price, tx.Date, method, nil)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s sell of %s in %s: %v", tx.Date.Format("2006-01-02"), symbol, models.MaskAccount(tx.Account()), err))
+			}
+			lots = Remove(lots, matched)
+			sales = append(sales, models.RealizedSale{
+				Date:     tx.Date,
+				Account:  tx.Account(),
+				Symbol:   symbol,
+				Quantity: tx.Quantity,
+				Proceeds: price * tx.Quantity,
+				Lots:     matched,
+			})
+		}
+	}
+	return lots, sales, warnings
+}
+
+// WashSaleWindow is the number of days before and after a loss sale in which buying the same
+// security makes it a wash sale
+const WashSaleWindow = 30
+
+// withinWindow reports whether two dates are at most WashSaleWindow days apart
+func withinWindow(a, b time.Time) bool {
+	return math.Abs(b.Sub(a).Hours()/24) <= WashSaleWindow
+}
+
+// WashSales finds loss sales with a purchase of the same symbol in any account, including
+// retirement accounts, within 30 days before or after. Each purchase replaces at most its own
+// quantity, and a purchase whose lot the sale itself disposed of doesn't count.
+func WashSales(sales []models.RealizedSale, transactions []models.Transaction) []models.WashSale {
+	type buy struct {
+		tx        models.Transaction
+		remaining float64
+	}
+	var buys []*buy
+	for _, tx := range transactions {
+		if tx.Type == models.TransactionBuy {
+			buys = append(buys, &buy{tx: tx, remaining: tx.Quantity})
+		}
+	}
+	sort.SliceStable(buys, func(i, j int) bool { return buys[i].tx.Date.Before(buys[j].tx.Date) })
+
+	var washes []models.WashSale
+	for _, sale := range sales {
+		loss := -sale.Gain()
+		if loss <= 0 {
+			continue
+		}
+		sold := make(map[string]bool)
+		for _, lot := range sale.Lots {
+			sold[lot.Acquired.Format("2006-01-02")] = true
+		}
+
+		unreplaced := sale.Quantity
+		for _, b := range buys {
+			if unreplaced <= quantityEpsilon {
+				break
+			}
+			if b.remaining <= 0 || !strings.EqualFold(b.tx.Symbol, sale.Symbol) || !withinWindow(sale.Date, b.tx.Date) {
+				continue
+			}
+			if b.tx.Account() == sale.Account && sold[b.tx.Date.Format("2006-01-02")] && !b.tx.Date.After(sale.Date) {
+				continue // The lot being sold
+			}
+			replaced := math.Min(unreplaced, b.remaining)
+			b.remaining -= replaced
+			unreplaced -= replaced
+			washes = append(washes, models.WashSale{
+				Symbol:              sale.Symbol,
+				SaleAccount:         sale.Account,
+				SaleDate:            sale.Date,
+				Loss:                loss,
+				ReplacementAccount:  b.tx.Account(),
+				ReplacementDate:     b.tx.Date,
+				ReplacementQuantity: replaced,
+				DisallowedLoss:      loss * replaced / sale.Quantity,
+			})
+		}
+	}
+	return washes
+}
+
+// RecentPurchases returns the lots of a symbol, in any account, bought in the 30 days up to
+// date. Selling the symbol at a loss on date would be a wash sale against them.
+func RecentPurchases(lots []models.TaxLot, symbol string, date time.Time) []models.TaxLot {
+	var recent []models.TaxLot
+	for _, lot := range lots {
+		if strings.EqualFold(lot.Symbol, symbol) && !lot.Acquired.After(date) && withinWindow(lot.Acquired, date) {
+			recent = append(recent, lot)
+		}
+	}
+	return recent
+}
+
+// Rates are the marginal tax rates applied to realized gains
+type Rates struct {
+	ShortTerm float64 `json:"short_term"`
+	LongTerm  float64 `json:"long_term"`
+}
+
+// DefaultRates are typical federal rates for a mid-bracket filer
+var DefaultRates = Rates{ShortTerm: 0.24, LongTerm: 0.15}
+
+// Tax returns the estimated tax on short- and long-term gains; losses give a negative saving
+func (r Rates) Tax(shortTerm, longTerm float64) float64 {
+	return shortTerm*r.ShortTerm + longTerm*r.LongTerm
+}
+
+// Gains splits lot sales into short- and long-term gains
+func Gains(sales []models.LotSale) (shortTerm, longTerm float64) {
+	for _, sale := range sales {
+		if sale.LongTerm {
+			longTerm += sale.Gain
+		} else {
+			shortTerm += sale.Gain
+		}
+	}
+	return shortTerm, longTerm
+}
@@ -0,0 +1,116 @@
+package taxlots
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+)
+
+func day(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func testLots() []models.TaxLot {
+	return []models.TaxLot{
+		{ID: "old-cheap", AccountNumber: "X1", Symbol: "MSTR", Acquired: day("2023-01-10"), Quantity: 10, CostBasis: 1500},
+		{ID: "old-dear", AccountNumber: "X1", Symbol: "MSTR", Acquired: day("2024-02-01"), Quantity: 10, CostBasis: 4000},
+		{ID: "new-dearest", AccountNumber: "X1", Symbol: "MSTR", Acquired: day("2025-04-01"), Quantity: 10, CostBasis: 4500},
+	}
+}
+
+func TestSelectMethods(t *testing.T) {
+	date := day("2025-06-11")
+	tests := []struct {
+		method Method
+		ids    []string
+		lots   []string
+	}{
+		{FIFO, nil, []string{"old-cheap", "old-dear"}},
+		{HIFO, nil, []string{"new-dearest", "old-dear"}},
+		{AvoidShortTerm, nil, []string{"old-dear", "old-cheap"}},
+		{SpecificID, []string{"old-cheap", "new-dearest"}, []string{"old-cheap", "new-dearest"}},
+	}
+	for _, tt := range tests {
+		sales, err := Select(testLots(), 15, 400, date, tt.method, tt.ids)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.method, err)
+		}
+		if len(sales) != len(tt.lots) || sales[0].LotID != tt.lots[0] || sales[1].LotID != tt.lots[1] || sales[1].Quantity != 5 {
+			t.Errorf("%s: expected lots %v, got %+v", tt.method, tt.lots, sales)
+		}
+	}
+
+	// HIFO realizes a short-term gain on the newest lot; avoiding short-term keeps it long-term
+	hifo, _ := Select(testLots(), 10, 400, date, HIFO, nil)
+	if st, lt := Gains(hifo); st != -500 || lt != 0 {
+		t.Errorf("expected a 500 short-term loss, got %.2f and %.2f", st, lt)
+	}
+	avoid, _ := Select(testLots(), 10, 400, date, AvoidShortTerm, nil)
+	if st, lt := Gains(avoid); st != 0 || lt != 0 || !avoid[0].LongTerm {
+		t.Errorf("expected a long-term break-even sale, got %.2f and %.2f", st, lt)
+	}
+
+	if _, err := Select(testLots(), 40, 400, date, FIFO, nil); !errors.Is(err, ErrInsufficientLots) {
+		t.Errorf("expected ErrInsufficientLots, got %v", err)
+	}
+	if _, err := Select(testLots(), 1, 400, date, SpecificID, []string{"missing"}); err == nil {
+		t.Errorf("expected an error for an unknown lot")
+	}
+}
+
+func TestRemoveKeepsUnitCost(t *testing.T) {
+	lots := Remove(testLots(), []models.LotSale{{LotID: "old-cheap", Quantity: 10}, {LotID: "old-dear", Quantity: 4}})
+	if len(lots) != 2 || lots[0].ID != "old-dear" || lots[0].Quantity != 6 || lots[0].CostBasis != 2400 {
+		t.Errorf("unexpected lots %+v", lots)
+	}
+}
+
+func TestWashSalesAcrossAccounts(t *testing.T) {
+	transactions := []models.Transaction{
+		{Date: day("2025-01-02"), AccountNumber: "X1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 10, Price: 400, Amount: -4000},
+		{Date: day("2025-03-01"), AccountNumber: "X1", Type: models.TransactionSell, Symbol: "MSTR", Quantity: 10, Price: 300, Amount: 3000},
+		{Date: day("2025-03-20"), AccountNumber: "IRA1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 4, Price: 310, Amount: -1240},
+		{Date: day("2025-05-01"), AccountNumber: "X1", Type: models.TransactionBuy, Symbol: "MSTR", Quantity: 4, Price: 320, Amount: -1280},
+	}
+	lots, sales, warnings := Replay(transactions, FIFO)
+	if len(warnings) != 0 || len(sales) != 1 || sales[0].Gain() != -1000 || len(lots) != 2 {
+		t.Fatalf("unexpected replay: lots %+v sales %+v warnings %v", lots, sales, warnings)
+	}
+
+	// The IRA buy 19 days later replaces 4 of the 10 shares; the May buy is outside the window
+	washes := WashSales(sales, transactions)
+	if len(washes) != 1 || washes[0].ReplacementAccount != "IRA1" || washes[0].DisallowedLoss != 400 {
+		t.Errorf("expected 400 of the loss disallowed by the IRA buy, got %+v", washes)
+	}
+
+	if recent := RecentPurchases(lots, "MSTR", day("2025-04-10")); len(recent) != 1 || recent[0].AccountNumber != "IRA1" {
+		t.Errorf("expected the IRA lot as a recent purchase, got %+v", recent)
+	}
+}
+
+func TestSelectSpecificMaskedID(t *testing.T) {
+	lot := models.TaxLot{ID: "Z23889908-MSTR-20240201-1", AccountNumber: "Z23889908", Symbol: "MSTR", Acquired: day("2024-02-01"), Quantity: 10, CostBasis: 4000}
+	if got := lot.DisplayID(); got != "****9908-MSTR-20240201-1" {
+		t.Fatalf("expected the account number masked in the ID, got %s", got)
+	}
+	if got := models.MaskAccount("Individual Z23889908"); got != "Individual ****9908" {
+		t.Errorf("expected the number masked, got %s", got)
+	}
+	if got := models.MaskAccount("Roth IRA 2"); got != "Roth IRA 2" {
+		t.Errorf("expected a name without a number unchanged, got %s", got)
+	}
+
+	sales, err := Select([]models.TaxLot{lot}, 5, 400, day("2025-06-11"), SpecificID, []string{lot.DisplayID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sales) != 1 || sales[0].LotID != lot.ID {
+		t.Errorf("expected the lot selected by its masked ID, got %+v", sales)
+	}
+	if !lot.InAccount("****9908") {
+		t.Errorf("expected the lot in its masked account")
+	}
+}
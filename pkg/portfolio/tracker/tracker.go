@@ -4,27 +4,39 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/ledger"
 	"github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
 )
 
 // Tracker handles historical portfolio data management
 type Tracker struct {
 	dataDir string
+	files   *vault.Files // Seals files when data/portfolio has a keyring
 }
 
-// NewTracker creates a new portfolio tracker
+// NewTracker creates a new portfolio tracker. Snapshots, state, lots and the ledger are read
+// and written through the portfolio keyring in the parent directory, if there is one.
 func NewTracker(dataDir string) *Tracker {
 	return &Tracker{
 		dataDir: dataDir,
+		files:   vault.NewFiles(filepath.Dir(dataDir)),
 	}
 }
 
+// Files returns the reader and writer that seals the tracker's files
+func (t *Tracker) Files() *vault.Files {
+	return t.files
+}
+
 // Store saves a portfolio snapshot to persistent storage
 func (t *Tracker) Store(portfolio *models.Portfolio) error {
 	// Ensure directory exists
@@ -37,17 +49,219 @@ func (t *Tracker) Store(portfolio *models.Portfolio) error {
 	filepath := filepath.Join(t.dataDir, filename)
 
 	// Convert to JSON
+	portfolio.SchemaVersion = schema.CurrentVersion(schema.Portfolio)
 	data, err := json.MarshalIndent(portfolio, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal portfolio: %w", err)
 	}
 
-	// Write to file
-	if err := ioutil.WriteFile(filepath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write portfolio file: %w", err)
+	// Write to file atomically, serialised with other writers of the snapshot directory
+	return storage.WithLock(t.lockPath(), func() error {
+		if err := t.files.WriteFile(filepath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write portfolio file: %w", err)
+		}
+		return nil
+	})
+}
+
+// LockPath returns the lock file guarding a snapshot directory; every writer of its
+// snapshots, rule state, tax lots and ledger holds it
+func LockPath(dataDir string) string {
+	return filepath.Join(dataDir, ".lock")
+}
+
+// lockPath returns the lock file guarding the snapshot directory
+func (t *Tracker) lockPath() string {
+	return LockPath(t.dataDir)
+}
+
+// rebalancingStatePath returns the file holding the rebalancing rule state
+func (t *Tracker) rebalancingStatePath() string {
+	return filepath.Join(t.dataDir, "rebalancing_state.json")
+}
+
+// LoadRebalancingState loads the rebalancing rule state. A missing file is a fresh state.
+func (t *Tracker) LoadRebalancingState() (*models.RebalancingState, error) {
+	data, err := t.files.ReadFile(t.rebalancingStatePath())
+	if os.IsNotExist(err) {
+		return &models.RebalancingState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rebalancing state: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.RebalancingState, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade rebalancing state: %w", err)
+	}
+
+	var state models.RebalancingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rebalancing state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveRebalancingState writes the rebalancing rule state
+func (t *Tracker) SaveRebalancingState(state *models.RebalancingState) error {
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return storage.WithLock(t.lockPath(), func() error {
+		return t.saveRebalancingStateLocked(state)
+	})
+}
+
+// UpdateRebalancingState loads, advances and saves the rebalancing rule state while
+// holding the lock, so a concurrent run can't overwrite a transition with stale state
+func (t *Tracker) UpdateRebalancingState(update func(state *models.RebalancingState) error) error {
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return storage.WithLock(t.lockPath(), func() error {
+		state, err := t.LoadRebalancingState()
+		if err != nil {
+			return err
+		}
+		if err := update(state); err != nil {
+			return err
+		}
+		return t.saveRebalancingStateLocked(state)
+	})
+}
+
+// saveRebalancingStateLocked writes the rule state; the caller must hold the lock
+func (t *Tracker) saveRebalancingStateLocked(state *models.RebalancingState) error {
+	state.SchemaVersion = schema.CurrentVersion(schema.RebalancingState)
+	return t.files.WriteJSON(t.rebalancingStatePath(), state)
+}
+
+// taxLotsPath returns the file holding the open tax lots
+func (t *Tracker) taxLotsPath() string {
+	return filepath.Join(t.dataDir, "tax_lots.json")
+}
+
+// LoadTaxLots loads the open tax lots. A missing file has no lots.
+func (t *Tracker) LoadTaxLots() (*models.TaxLots, error) {
+	data, err := t.files.ReadFile(t.taxLotsPath())
+	if os.IsNotExist(err) {
+		return &models.TaxLots{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax lots: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.TaxLots, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade tax lots: %w", err)
+	}
+
+	var lots models.TaxLots
+	if err := json.Unmarshal(data, &lots); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tax lots: %w", err)
+	}
+	return &lots, nil
+}
+
+// SaveTaxLots writes the open tax lots
+func (t *Tracker) SaveTaxLots(lots *models.TaxLots) error {
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return storage.WithLock(t.lockPath(), func() error {
+		return t.saveTaxLotsLocked(lots)
+	})
+}
+
+// UpdateTaxLots loads, modifies and saves the tax lots while holding the lock, so
+// concurrent imports are not lost
+func (t *Tracker) UpdateTaxLots(update func(lots *models.TaxLots) error) error {
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return storage.WithLock(t.lockPath(), func() error {
+		lots, err := t.LoadTaxLots()
+		if err != nil {
+			return err
+		}
+		if err := update(lots); err != nil {
+			return err
+		}
+		return t.saveTaxLotsLocked(lots)
+	})
+}
+
+// saveTaxLotsLocked writes the tax lots; the caller must hold the lock
+func (t *Tracker) saveTaxLotsLocked(lots *models.TaxLots) error {
+	lots.SchemaVersion = schema.CurrentVersion(schema.TaxLots)
+	return t.files.WriteJSON(t.taxLotsPath(), lots)
+}
+
+// ledgerPath returns the file holding the transactions ledger
+func (t *Tracker) ledgerPath() string {
+	return filepath.Join(t.dataDir, "ledger.json")
+}
+
+// LoadLedger loads the transactions ledger. A missing file has no transactions.
+func (t *Tracker) LoadLedger() (*models.Ledger, error) {
+	data, err := t.files.ReadFile(t.ledgerPath())
+	if os.IsNotExist(err) {
+		return &models.Ledger{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.PortfolioLedger, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade ledger: %w", err)
+	}
+
+	var ledger models.Ledger
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ledger: %w", err)
+	}
+	return &ledger, nil
+}
+
+// SaveLedger writes the transactions ledger
+func (t *Tracker) SaveLedger(ledger *models.Ledger) error {
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return storage.WithLock(t.lockPath(), func() error {
+		return t.saveLedgerLocked(ledger)
+	})
+}
+
+// UpdateLedger loads, modifies and saves the ledger while holding the lock, so
+// concurrent imports are not lost
+func (t *Tracker) UpdateLedger(update func(ledger *models.Ledger) error) error {
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	return nil
+	return storage.WithLock(t.lockPath(), func() error {
+		ledger, err := t.LoadLedger()
+		if err != nil {
+			return err
+		}
+		if err := update(ledger); err != nil {
+			return err
+		}
+		return t.saveLedgerLocked(ledger)
+	})
+}
+
+// saveLedgerLocked writes the ledger; the caller must hold the lock
+func (t *Tracker) saveLedgerLocked(ledger *models.Ledger) error {
+	ledger.SchemaVersion = schema.CurrentVersion(schema.PortfolioLedger)
+	return t.files.WriteJSON(t.ledgerPath(), ledger)
 }
 
 // Load retrieves a portfolio snapshot by date
@@ -55,11 +269,16 @@ func (t *Tracker) Load(date time.Time) (*models.Portfolio, error) {
 	filename := fmt.Sprintf("portfolio_%s.json", date.Format("2006-01-02"))
 	filepath := filepath.Join(t.dataDir, filename)
 
-	data, err := ioutil.ReadFile(filepath)
+	data, err := t.files.ReadFile(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read portfolio file: %w", err)
 	}
 
+	data, err = schema.Upgrade(schema.Portfolio, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade portfolio: %w", err)
+	}
+
 	var portfolio models.Portfolio
 	if err := json.Unmarshal(data, &portfolio); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal portfolio: %w", err)
@@ -77,7 +296,8 @@ func (t *Tracker) ListAll() ([]time.Time, error) {
 
 	var dates []time.Time
 	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".json" {
+		// Skip the state and lot files kept alongside the snapshots
+		if filepath.Ext(file.Name()) == ".json" && strings.HasPrefix(file.Name(), "portfolio_") {
 			dateStr := file.Name()
 			dateStr = dateStr[10 : len(dateStr)-5] // Remove "portfolio_" prefix and ".json" suffix
 
@@ -232,16 +452,20 @@ func (t *Tracker) calculateChanges(previous, current *models.Portfolio) *models.
 		}
 	}
 
-	// Allocation changes
-	changes.AllocationChanges["FBTC"] = current.AssetAllocation.FBTCPercent - previous.AssetAllocation.FBTCPercent
-	changes.AllocationChanges["MSTR"] = current.AssetAllocation.MSTRPercent - previous.AssetAllocation.MSTRPercent
-	changes.AllocationChanges["GLD"] = current.AssetAllocation.GLDPercent - previous.AssetAllocation.GLDPercent
-	changes.AllocationChanges["Other"] = current.AssetAllocation.OtherPercent - previous.AssetAllocation.OtherPercent
+	// Allocation changes by asset class
+	for class := range previous.AssetAllocation.Classes {
+		changes.AllocationChanges[class] = current.AssetAllocation.Percent(class) - previous.AssetAllocation.Percent(class)
+	}
+	for class := range current.AssetAllocation.Classes {
+		changes.AllocationChanges[class] = current.AssetAllocation.Percent(class) - previous.AssetAllocation.Percent(class)
+	}
 
 	return changes
 }
 
-// GetPerformanceMetrics calculates performance metrics over time
+// GetPerformanceMetrics calculates performance metrics over time. Returns are time-weighted:
+// deposits and withdrawals in the ledger are taken out of each period between snapshots, so
+// only market moves, income and fees count as performance.
 func (t *Tracker) GetPerformanceMetrics() (*PerformanceMetrics, error) {
 	history, err := t.GetHistoricalSummary()
 	if err != nil {
@@ -252,66 +476,57 @@ func (t *Tracker) GetPerformanceMetrics() (*PerformanceMetrics, error) {
 		return nil, fmt.Errorf("no historical data available")
 	}
 
+	ledgerData, err := t.LoadLedger()
+	if err != nil {
+		return nil, err
+	}
+	transactions := ledgerData.Transactions
+
 	metrics := &PerformanceMetrics{
 		StartDate:  history[0].Date,
 		EndDate:    history[len(history)-1].Date,
 		StartValue: history[0].TotalValue,
 		EndValue:   history[len(history)-1].TotalValue,
 	}
+	metrics.NetContributions = ledger.ExternalFlows(transactions, metrics.StartDate, metrics.EndDate)
 
-	// Calculate total return
-	metrics.TotalReturn = metrics.EndValue - metrics.StartValue
-	if metrics.StartValue > 0 {
-		metrics.TotalReturnPercent = (metrics.TotalReturn / metrics.StartValue) * 100
-	}
+	// Calculate total return, net of deposits and withdrawals
+	metrics.TotalReturn = metrics.EndValue - metrics.StartValue - metrics.NetContributions
 
-	// Calculate CAGR (Compound Annual Growth Rate)
-	days := metrics.EndDate.Sub(metrics.StartDate).Hours() / 24
-	years := days / 365.25
-	if years > 0 && metrics.StartValue > 0 {
-		metrics.CAGR = (math.Pow(metrics.EndValue/metrics.StartValue, 1/years) - 1) * 100
+	valuations := make([]ledger.Valuation, len(history))
+	for i, snapshot := range history {
+		valuations[i] = ledger.Valuation{Date: snapshot.Date, Value: snapshot.TotalValue}
 	}
+	periods := ledger.Periods(valuations, transactions)
+	twr := ledger.TWR(periods)
+	metrics.TotalReturnPercent = twr * 100
 
-	// Track volatility and max drawdown
-	var returns []float64
-	var maxValue float64 = metrics.StartValue
-	var maxDrawdown float64
+	// Calculate CAGR (Compound Annual Growth Rate) from the time-weighted return
+	metrics.CAGR = ledger.Annualize(twr, metrics.StartDate, metrics.EndDate) * 100
 
-	for i, snapshot := range history {
-		if i > 0 {
-			previousValue := history[i-1].TotalValue
-			if previousValue > 0 {
-				dailyReturn := (snapshot.TotalValue - previousValue) / previousValue
-				returns = append(returns, dailyReturn)
-			}
-		}
+	// Money-weighted return of the owner's cash flows
+	if xirr, err := ledger.XIRR(ledger.InvestorFlows(valuations, transactions)); err == nil && len(periods) > 0 {
+		metrics.XIRR = xirr * 100
+	}
 
-		// Track max value and drawdown
-		if snapshot.TotalValue > maxValue {
-			maxValue = snapshot.TotalValue
+	// Track max drawdown of the time-weighted growth
+	growth, peak := 1.0, 1.0
+	var maxDrawdown float64
+	for _, period := range periods {
+		growth *= 1 + period.Return
+		if growth > peak {
+			peak = growth
 		}
-		drawdown := (maxValue - snapshot.TotalValue) / maxValue
-		if drawdown > maxDrawdown {
+		if drawdown := (peak - growth) / peak; drawdown > maxDrawdown {
 			maxDrawdown = drawdown
 		}
 	}
 
 	metrics.MaxDrawdown = maxDrawdown * 100
 
-	// Calculate volatility (standard deviation of returns)
-	if len(returns) > 1 {
-		var sum, mean float64
-		for _, ret := range returns {
-			sum += ret
-		}
-		mean = sum / float64(len(returns))
-
-		var variance float64
-		for _, ret := range returns {
-			variance += math.Pow(ret-mean, 2)
-		}
-		variance /= float64(len(returns) - 1)
-		metrics.Volatility = math.Sqrt(variance) * math.Sqrt(365) * 100 // Annualized
+	// Volatility of the period returns, annualized by the average time between snapshots
+	if len(periods) > 1 {
+		metrics.Volatility = ledger.Volatility(periods) * 100
 	}
 
 	return metrics, nil
@@ -323,9 +538,14 @@ type PerformanceMetrics struct {
 	EndDate            time.Time `json:"end_date"`
 	StartValue         float64   `json:"start_value"`
 	EndValue           float64   `json:"end_value"`
-	TotalReturn        float64   `json:"total_return"`
-	TotalReturnPercent float64   `json:"total_return_percent"`
+	NetContributions   float64   `json:"net_contributions"`    // Deposits less withdrawals in the ledger
+	TotalReturn        float64   `json:"total_return"`         // Change in value less net contributions
+	TotalReturnPercent float64   `json:"total_return_percent"` // Time-weighted
+	XIRR               float64   `json:"xirr"`                 // Annual money-weighted return, percent
 	CAGR               float64   `json:"cagr"`
 	Volatility         float64   `json:"volatility"`
 	MaxDrawdown        float64   `json:"max_drawdown"`
+
+	// Risk of the daily reconstructed values; set by callers with price histories
+	Risk *ledger.RiskMetrics `json:"risk,omitempty"`
 }
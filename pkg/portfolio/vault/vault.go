@@ -0,0 +1,436 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// Environment variables holding the secret that unlocks the keyring
+const (
+	PassphraseEnv = "MNAV_PORTFOLIO_PASSPHRASE"
+	KeyFileEnv    = "MNAV_PORTFOLIO_KEYFILE"
+)
+
+// KeyringFile is the keyring's name in the portfolio data directory (data/portfolio)
+const KeyringFile = "keyring.json"
+
+// magic prefixes every sealed file, so plaintext files keep loading
+const magic = "MNAVENC1"
+
+// Key derivation functions for the key that wraps the data key
+const (
+	KDFScrypt  = "scrypt"  // From a passphrase
+	KDFKeyFile = "keyfile" // From a key file's contents with HKDF-SHA256
+)
+
+// Default scrypt cost for passphrases
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Keyring holds the data key that seals the portfolio files, wrapped by a key derived from
+// a passphrase or key file. Rotating the secret rewraps the data key without touching the
+// files; rekeying replaces the data key and needs every file resealed.
+type Keyring struct {
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	KeyID         string    `json:"key_id"` // Fingerprint of the data key
+	KDF           string    `json:"kdf"`
+	Salt          []byte    `json:"salt"`
+	N             int       `json:"n,omitempty"` // scrypt cost
+	R             int       `json:"r,omitempty"`
+	P             int       `json:"p,omitempty"`
+	WrappedKey    []byte    `json:"wrapped_key"` // Nonce then the sealed data key
+	CreatedAt     time.Time `json:"created_at"`
+	RotatedAt     time.Time `json:"rotated_at,omitempty"`
+}
+
+// Secret is the passphrase or key file that unlocks a keyring
+type Secret struct {
+	Passphrase string
+	KeyFile    string
+}
+
+// SecretFromEnv reads the secret from MNAV_PORTFOLIO_PASSPHRASE or MNAV_PORTFOLIO_KEYFILE
+func SecretFromEnv() Secret {
+	return Secret{Passphrase: os.Getenv(PassphraseEnv), KeyFile: os.Getenv(KeyFileEnv)}
+}
+
+// IsZero reports whether no secret is set
+func (s Secret) IsZero() bool {
+	return s.Passphrase == "" && s.KeyFile == ""
+}
+
+// keyMaterial returns the key file's contents: hex, base64 or raw bytes, at least 32 bytes
+func keyMaterial(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) >= 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) >= 32 {
+		return key, nil
+	}
+	if len(data) < 32 {
+		return nil, fmt.Errorf("key file %s has %d bytes; at least 32 are needed", path, len(data))
+	}
+	return data, nil
+}
+
+// newWrapping sets the keyring's KDF and fresh salt for the secret
+func (k *Keyring) newWrapping(secret Secret) error {
+	k.Salt = make([]byte, 16)
+	if _, err := rand.Read(k.Salt); err != nil {
+		return err
+	}
+	k.N, k.R, k.P = 0, 0, 0
+	switch {
+	case secret.KeyFile != "":
+		k.KDF = KDFKeyFile
+	case secret.Passphrase != "":
+		k.KDF = KDFScrypt
+		k.N, k.R, k.P = scryptN, scryptR, scryptP
+	default:
+		return fmt.Errorf("no passphrase or key file (set %s or %s)", PassphraseEnv, KeyFileEnv)
+	}
+	return nil
+}
+
+// wrappingKey derives the key that wraps the data key from the secret
+func (k *Keyring) wrappingKey(secret Secret) (*[32]byte, error) {
+	var derived []byte
+	var err error
+	switch k.KDF {
+	case KDFScrypt:
+		if secret.Passphrase == "" {
+			return nil, fmt.Errorf("the keyring needs a passphrase (set %s)", PassphraseEnv)
+		}
+		derived, err = scrypt.Key([]byte(secret.Passphrase), k.Salt, k.N, k.R, k.P, 32)
+	case KDFKeyFile:
+		if secret.KeyFile == "" {
+			return nil, fmt.Errorf("the keyring needs a key file (set %s)", KeyFileEnv)
+		}
+		var material []byte
+		if material, err = keyMaterial(secret.KeyFile); err == nil {
+			derived, err = hkdf.Key(sha256.New, material, k.Salt, "mNAV portfolio keyring", 32)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key derivation %q", k.KDF)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive the wrapping key: %w", err)
+	}
+	var key [32]byte
+	copy(key[:], derived)
+	return &key, nil
+}
+
+// keyID returns the fingerprint of a data key
+func keyID(key *[32]byte) string {
+	sum := sha256.Sum256(key[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+// wrap seals the data key under the secret
+func (k *Keyring) wrap(key *[32]byte, secret Secret) error {
+	if err := k.newWrapping(secret); err != nil {
+		return err
+	}
+	wrapping, err := k.wrappingKey(secret)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	k.WrappedKey = secretbox.Seal(nonce[:], key[:], &nonce, wrapping)
+	k.KeyID = keyID(key)
+	return nil
+}
+
+// Unlock unwraps the data key with the secret
+func (k *Keyring) Unlock(secret Secret) (*Box, error) {
+	wrapping, err := k.wrappingKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(k.WrappedKey) < 24 {
+		return nil, fmt.Errorf("keyring has no wrapped key")
+	}
+	var nonce [24]byte
+	copy(nonce[:], k.WrappedKey[:24])
+	opened, ok := secretbox.Open(nil, k.WrappedKey[24:], &nonce, wrapping)
+	if !ok || len(opened) != 32 {
+		return nil, fmt.Errorf("wrong passphrase or key file")
+	}
+	box := &Box{}
+	copy(box.key[:], opened)
+	return box, nil
+}
+
+// NewKeyring creates a keyring with a random data key wrapped under the secret
+func NewKeyring(secret Secret) (*Keyring, *Box, error) {
+	box := &Box{}
+	if _, err := rand.Read(box.key[:]); err != nil {
+		return nil, nil, err
+	}
+	keyring := &Keyring{CreatedAt: time.Now()}
+	if err := keyring.wrap(&box.key, secret); err != nil {
+		return nil, nil, err
+	}
+	return keyring, box, nil
+}
+
+// Rotate rewraps the data key under a new secret; sealed files stay readable as they are
+func (k *Keyring) Rotate(old, next Secret) error {
+	box, err := k.Unlock(old)
+	if err != nil {
+		return err
+	}
+	if err := k.wrap(&box.key, next); err != nil {
+		return err
+	}
+	k.RotatedAt = time.Now()
+	return nil
+}
+
+// Rekey replaces the data key with a fresh one wrapped under the secret. It returns the old
+// and new boxes; every sealed file must be opened with the old and resealed with the new.
+func (k *Keyring) Rekey(old, next Secret) (oldBox, newBox *Box, err error) {
+	if oldBox, err = k.Unlock(old); err != nil {
+		return nil, nil, err
+	}
+	newBox = &Box{}
+	if _, err := rand.Read(newBox.key[:]); err != nil {
+		return nil, nil, err
+	}
+	if err := k.wrap(&newBox.key, next); err != nil {
+		return nil, nil, err
+	}
+	k.RotatedAt = time.Now()
+	return oldBox, newBox, nil
+}
+
+// KeyringPath returns the keyring's path in the portfolio data directory
+func KeyringPath(portfolioDir string) string {
+	return filepath.Join(portfolioDir, KeyringFile)
+}
+
+// LoadKeyring loads a keyring. A missing file means the portfolio isn't encrypted: nil, nil.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.PortfolioKeyring, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade keyring: %w", err)
+	}
+
+	var keyring Keyring
+	if err := json.Unmarshal(data, &keyring); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	return &keyring, nil
+}
+
+// SaveKeyring writes a keyring readable only by its owner
+func SaveKeyring(path string, keyring *Keyring) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %w", err)
+	}
+	keyring.SchemaVersion = schema.CurrentVersion(schema.PortfolioKeyring)
+	data, err := json.MarshalIndent(keyring, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyring: %w", err)
+	}
+	return storage.WithLock(path+".lock", func() error {
+		return storage.WriteFileAtomic(path, data, 0600)
+	})
+}
+
+// GenerateKeyFile writes a new random 32-byte key, hex-encoded, readable only by its owner
+func GenerateKeyFile(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("key file %s already exists", path)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600)
+}
+
+// Box seals and opens portfolio files with the data key (NaCl secretbox:
+// XSalsa20-Poly1305)
+type Box struct {
+	key [32]byte
+}
+
+// KeyID returns the fingerprint of the box's data key
+func (b *Box) KeyID() string {
+	return keyID(&b.key)
+}
+
+// Sealed reports whether data is a sealed file
+func Sealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Seal encrypts data behind the magic header and a random nonce
+func (b *Box) Seal(data []byte) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	out := append([]byte(magic), nonce[:]...)
+	return secretbox.Seal(out, data, &nonce, &b.key), nil
+}
+
+// Open decrypts a sealed file; plaintext is returned unchanged
+func (b *Box) Open(data []byte) ([]byte, error) {
+	if !Sealed(data) {
+		return data, nil
+	}
+	data = data[len(magic):]
+	if len(data) < 24+secretbox.Overhead {
+		return nil, fmt.Errorf("sealed file is truncated")
+	}
+	var nonce [24]byte
+	copy(nonce[:], data[:24])
+	opened, ok := secretbox.Open(nil, data[24:], &nonce, &b.key)
+	if !ok {
+		return nil, fmt.Errorf("sealed file doesn't match the keyring's data key")
+	}
+	return opened, nil
+}
+
+// Files reads and writes the files of one portfolio data directory, sealing them when the
+// directory has a keyring. Without a keyring files are plaintext, and sealed files are always
+// opened, so encryption can be turned on and off without converting every file at once.
+type Files struct {
+	keyringPath string
+	secret      Secret
+
+	once sync.Once
+	box  *Box
+	err  error
+}
+
+// NewFiles returns the files of portfolioDir (data/portfolio), unlocked with the secret in
+// the environment
+func NewFiles(portfolioDir string) *Files {
+	return &Files{keyringPath: KeyringPath(portfolioDir), secret: SecretFromEnv()}
+}
+
+// unlock loads the keyring and unwraps the data key once; a nil box means no keyring
+func (f *Files) unlock() (*Box, error) {
+	f.once.Do(func() {
+		keyring, err := LoadKeyring(f.keyringPath)
+		if err != nil || keyring == nil {
+			f.err = err
+			return
+		}
+		if f.secret.IsZero() {
+			f.err = fmt.Errorf("portfolio data is encrypted: set %s or %s", PassphraseEnv, KeyFileEnv)
+			return
+		}
+		if f.box, f.err = keyring.Unlock(f.secret); f.err != nil {
+			f.err = fmt.Errorf("failed to unlock %s: %w", f.keyringPath, f.err)
+		}
+	})
+	return f.box, f.err
+}
+
+// Encrypted reports whether new files are sealed
+func (f *Files) Encrypted() (bool, error) {
+	box, err := f.unlock()
+	return box != nil, err
+}
+
+// Open returns data, decrypted if it's sealed
+func (f *Files) Open(data []byte) ([]byte, error) {
+	if !Sealed(data) {
+		return data, nil
+	}
+	box, err := f.unlock()
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, fmt.Errorf("file is encrypted but %s is missing", f.keyringPath)
+	}
+	return box.Open(data)
+}
+
+// ReadFile reads a file, decrypting it if it's sealed
+func (f *Files) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = f.Open(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return data, nil
+}
+
+// Seal returns data sealed when the directory has a keyring, and unchanged otherwise
+func (f *Files) Seal(data []byte) ([]byte, error) {
+	box, err := f.unlock()
+	if err != nil || box == nil {
+		return data, err
+	}
+	return box.Seal(data)
+}
+
+// WriteFile writes a file atomically, sealed when the directory has a keyring. Sealed files
+// are readable only by their owner.
+func (f *Files) WriteFile(path string, data []byte, perm os.FileMode) error {
+	box, err := f.unlock()
+	if err != nil {
+		return err
+	}
+	if box != nil {
+		if data, err = box.Seal(data); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", filepath.Base(path), err)
+		}
+		perm = 0600
+	}
+	return storage.WriteFileAtomic(path, data, perm)
+}
+
+// WriteJSON marshals v with indentation and writes it with WriteFile
+func (f *Files) WriteJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	return f.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,115 @@
+package vault
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyringRotateAndRekey(t *testing.T) {
+	passphrase := Secret{Passphrase: "correct horse"}
+	keyring, box, err := NewKeyring(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal([]byte(`{"account_number": "Z23889908"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sealed(sealed) || bytes.Contains(sealed, []byte("Z23889908")) {
+		t.Fatalf("expected a sealed file without the plaintext")
+	}
+	if _, err := keyring.Unlock(Secret{Passphrase: "wrong"}); err == nil {
+		t.Errorf("expected a wrong passphrase to fail")
+	}
+
+	// Rotating to a key file keeps the data key, so sealed files still open
+	keyFile := filepath.Join(t.TempDir(), "portfolio.key")
+	if err := GenerateKeyFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	id := keyring.KeyID
+	if err := keyring.Rotate(passphrase, Secret{KeyFile: keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	if keyring.KDF != KDFKeyFile || keyring.KeyID != id {
+		t.Errorf("expected the same data key wrapped by the key file, got %s key %s", keyring.KDF, keyring.KeyID)
+	}
+	if _, err := keyring.Unlock(passphrase); err == nil {
+		t.Errorf("expected the old passphrase to stop working")
+	}
+	rotated, err := keyring.Unlock(Secret{KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := rotated.Open(sealed); err != nil || !bytes.Contains(opened, []byte("Z23889908")) {
+		t.Fatalf("expected the file to open after rotation: %v", err)
+	}
+
+	// Rekeying replaces the data key; files sealed with the old one need resealing
+	oldBox, newBox, err := keyring.Rekey(Secret{KeyFile: keyFile}, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.KeyID == id || newBox.KeyID() != keyring.KeyID {
+		t.Errorf("expected a new data key, got %s", keyring.KeyID)
+	}
+	if _, err := newBox.Open(sealed); err == nil {
+		t.Errorf("expected the new key not to open the old file")
+	}
+	opened, err := oldBox.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resealed, _ := newBox.Seal(opened)
+	unlocked, err := keyring.Unlock(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unlocked.Open(resealed); err != nil {
+		t.Errorf("expected the resealed file to open: %v", err)
+	}
+}
+
+func TestFilesReadAndWriteTransparently(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "processed", "ledger.json")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	secret := Secret{Passphrase: "correct horse"}
+
+	// Without a keyring files are plaintext
+	plain := &Files{keyringPath: KeyringPath(dir), secret: secret}
+	if err := plain.WriteFile(path, []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, _, err := NewKeyring(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKeyring(KeyringPath(dir), keyring); err != nil {
+		t.Fatal(err)
+	}
+	files := &Files{keyringPath: KeyringPath(dir), secret: secret}
+	if data, err := files.ReadFile(path); err != nil || string(data) != "before" {
+		t.Fatalf("expected the plaintext file to still load, got %q: %v", data, err)
+	}
+	if err := files.WriteFile(path, []byte("after"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(path); !Sealed(raw) {
+		t.Fatalf("expected the file sealed on write")
+	}
+	if data, err := files.ReadFile(path); err != nil || string(data) != "after" {
+		t.Errorf("expected the sealed file to read back, got %q: %v", data, err)
+	}
+
+	locked := &Files{keyringPath: KeyringPath(dir)}
+	if _, err := locked.ReadFile(path); err == nil {
+		t.Errorf("expected reading without the secret to fail")
+	}
+	if err := locked.WriteFile(path, []byte("leak"), 0644); err == nil {
+		t.Errorf("expected writing without the secret to fail rather than write plaintext")
+	}
+}
@@ -0,0 +1,364 @@
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Format is an output image format
+type Format string
+
+// Supported formats
+const (
+	SVG Format = "svg"
+	PNG Format = "png"
+)
+
+// Axis selects which y axis a series is plotted against
+type Axis int
+
+// Y axes
+const (
+	Left Axis = iota
+	Right
+)
+
+// Default image size in pixels
+const (
+	DefaultWidth  = 1200
+	DefaultHeight = 600
+)
+
+// Colors used for the frame, grid and text
+var (
+	white     = color.RGBA{255, 255, 255, 255}
+	textColor = color.RGBA{51, 51, 51, 255}
+	axisColor = color.RGBA{102, 102, 102, 255}
+	gridColor = color.RGBA{224, 224, 224, 255}
+)
+
+// Series is one line on the chart. Values align with Chart.Dates; NaN leaves a gap.
+type Series struct {
+	Name   string
+	Values []float64
+	Color  color.RGBA
+	Axis   Axis
+}
+
+// YAxis configures a vertical axis
+type YAxis struct {
+	Label  string
+	Log    bool
+	Format func(float64) string // Tick label format, FormatCompact when nil
+}
+
+// Annotation marks an event on the timeline: a marker line at Start, or a shaded
+// range when End is after Start. Colors are non-premultiplied; a translucent color
+// suits ranges.
+type Annotation struct {
+	Start, End time.Time
+	Label      string   // Short text drawn beside the marker; empty draws none
+	Group      string   // Legend entry shared by annotations of the same kind
+	Details    []string // Hover card lines (SVG title tooltips)
+	Color      color.RGBA
+}
+
+// Chart is a time series line chart with a date axis and up to two y axes
+type Chart struct {
+	Title       string
+	Dates       []time.Time
+	Series      []Series
+	Annotations []Annotation
+	Left        YAxis
+	Right       YAxis
+	Width       int
+	Height      int
+}
+
+// point is a pixel position
+type point struct{ x, y float64 }
+
+// anchor is the horizontal alignment of text
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas is the drawing surface shared by the SVG and PNG backends
+type canvas interface {
+	rect(x, y, w, h float64, fill color.RGBA)
+	polyline(points []point, stroke color.RGBA, width float64)
+	text(x, y float64, s string, fill color.RGBA, a anchor)
+	hotspot(x, y, w, h float64, title string) // Invisible hover target showing title
+	encode(w io.Writer) error
+}
+
+// Render draws the chart and writes it in the given format
+func (c *Chart) Render(w io.Writer, format Format) error {
+	if len(c.Dates) == 0 {
+		return fmt.Errorf("chart %q has no data", c.Title)
+	}
+	for _, s := range c.Series {
+		if len(s.Values) != len(c.Dates) {
+			return fmt.Errorf("series %q has %d values for %d dates", s.Name, len(s.Values), len(c.Dates))
+		}
+	}
+
+	width, height := c.Width, c.Height
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if height <= 0 {
+		height = DefaultHeight
+	}
+
+	var cv canvas
+	switch format {
+	case SVG:
+		cv = newSVGCanvas(width, height)
+	case PNG:
+		cv = newPNGCanvas(width, height)
+	default:
+		return fmt.Errorf("unsupported chart format %q", format)
+	}
+
+	c.draw(cv, float64(width), float64(height))
+	return cv.encode(w)
+}
+
+// draw lays out and draws every chart element
+func (c *Chart) draw(cv canvas, width, height float64) {
+	hasRight := false
+	for _, s := range c.Series {
+		if s.Axis == Right {
+			hasRight = true
+		}
+	}
+
+	left, top, bottom := 80.0, 70.0, height-50
+	right := width - 30
+	if hasRight {
+		right = width - 80
+	}
+
+	cv.rect(0, 0, width, height, white)
+	cv.text(width/2, 26, c.Title, textColor, anchorMiddle)
+	c.drawLegend(cv, left, 48)
+
+	// Date axis
+	xs := dateScale{min: c.Dates[0], max: c.Dates[len(c.Dates)-1], p0: left, p1: right}
+	dateTicks, layout := xs.ticks(int((right - left) / 90))
+	for _, t := range dateTicks {
+		x := xs.pos(t)
+		cv.polyline([]point{{x, top}, {x, bottom}}, gridColor, 1)
+		cv.polyline([]point{{x, bottom}, {x, bottom + 5}}, axisColor, 1)
+		cv.text(x, bottom+20, t.Format(layout), textColor, anchorMiddle)
+	}
+
+	// Value axes; the left axis draws the horizontal grid unless only the right one is used
+	scales := map[Axis]valueScale{}
+	for _, axis := range []Axis{Left, Right} {
+		cfg := c.axis(axis)
+		lo, hi, ok := c.valueRange(axis, cfg.Log)
+		if !ok {
+			continue
+		}
+		ys := newValueScale(lo, hi, cfg.Log, bottom, top)
+		scales[axis] = ys
+
+		format := cfg.Format
+		if format == nil {
+			format = FormatCompact
+		}
+		for _, v := range ys.ticks() {
+			y := ys.pos(v)
+			if axis == Left || len(scales) == 1 {
+				cv.polyline([]point{{left, y}, {right, y}}, gridColor, 1)
+			}
+			if axis == Left {
+				cv.polyline([]point{{left - 5, y}, {left, y}}, axisColor, 1)
+				cv.text(left-8, y+4, format(v), textColor, anchorEnd)
+			} else {
+				cv.polyline([]point{{right, y}, {right + 5, y}}, axisColor, 1)
+				cv.text(right+8, y+4, format(v), textColor, anchorStart)
+			}
+		}
+
+		if axis == Left {
+			cv.text(8, top-10, axisLabel(cfg), textColor, anchorStart)
+		} else {
+			cv.text(width-8, top-10, axisLabel(cfg), textColor, anchorEnd)
+		}
+	}
+
+	ranges, markers := c.layoutAnnotations(xs, top, bottom)
+	for _, r := range ranges {
+		cv.rect(r.x, top, r.w, bottom-top, r.a.Color)
+	}
+
+	// Frame
+	cv.polyline([]point{{left, top}, {left, bottom}, {right, bottom}}, axisColor, 1)
+	if hasRight {
+		cv.polyline([]point{{right, top}, {right, bottom}}, axisColor, 1)
+	}
+
+	// Series, split into runs at gaps
+	for _, s := range c.Series {
+		ys, ok := scales[s.Axis]
+		if !ok {
+			continue
+		}
+		var run []point
+		for i, v := range s.Values {
+			if !ys.valid(v) {
+				if len(run) > 0 {
+					cv.polyline(run, s.Color, 2)
+				}
+				run = nil
+				continue
+			}
+			run = append(run, point{xs.pos(c.Dates[i]), ys.pos(v)})
+		}
+		if len(run) > 0 {
+			cv.polyline(run, s.Color, 2)
+		}
+	}
+
+	// Markers and labels go over the series; hover targets go over everything
+	for _, m := range markers {
+		cv.polyline([]point{{m.x, top}, {m.x, bottom}}, m.a.Color, 1)
+		cv.rect(m.x-3, top-3, 6, 6, m.a.Color)
+		switch {
+		case m.labelY > 0 && m.labelLeft:
+			cv.text(m.x-4, m.labelY, m.a.Label, textColor, anchorEnd)
+		case m.labelY > 0:
+			cv.text(m.x+4, m.labelY, m.a.Label, textColor, anchorStart)
+		}
+	}
+	for _, r := range ranges {
+		cv.hotspot(r.x, top, r.w, bottom-top, strings.Join(r.a.Details, "\n"))
+	}
+	for _, m := range markers {
+		cv.hotspot(m.x-4, top-4, 8, bottom-top+4, strings.Join(m.a.Details, "\n"))
+	}
+}
+
+// placedAnnotation is an annotation positioned on the plot
+type placedAnnotation struct {
+	a         Annotation
+	x, w      float64
+	labelY    float64 // Baseline of the label; 0 when the label is omitted
+	labelLeft bool    // Label drawn left of the marker
+}
+
+// layoutAnnotations positions annotations within the date range, splitting them into
+// shaded ranges and markers. Labels are stacked in rows and dropped when no row has room.
+func (c *Chart) layoutAnnotations(xs dateScale, top, bottom float64) (ranges, markers []placedAnnotation) {
+	const rows = 3
+	rowEnd := make([]float64, rows)
+	for i := range rowEnd {
+		rowEnd[i] = math.Inf(-1)
+	}
+
+	for _, a := range c.Annotations {
+		last := a.Start
+		if a.End.After(a.Start) {
+			last = a.End
+		}
+		if a.Start.After(xs.max) || last.Before(xs.min) {
+			continue
+		}
+
+		if a.End.After(a.Start) {
+			start, end := a.Start, a.End
+			if start.Before(xs.min) {
+				start = xs.min
+			}
+			if end.After(xs.max) {
+				end = xs.max
+			}
+			x0, x1 := xs.pos(start), xs.pos(end)
+			ranges = append(ranges, placedAnnotation{a: a, x: x0, w: math.Max(x1-x0, 1)})
+			continue
+		}
+
+		m := placedAnnotation{a: a, x: xs.pos(a.Start)}
+		if a.Label != "" {
+			// Labels sit right of the marker, or left of it near the right edge
+			width := float64(len(a.Label))*7 + 8
+			start, end := m.x, m.x+width
+			if end >= xs.p1 {
+				start, end, m.labelLeft = m.x-width, m.x, true
+			}
+			for row := 0; row < rows; row++ {
+				if start > rowEnd[row] && start >= xs.p0 && top+16*float64(row+1) < bottom {
+					m.labelY = top + 16*float64(row+1)
+					rowEnd[row] = end
+					break
+				}
+			}
+		}
+		markers = append(markers, m)
+	}
+	return ranges, markers
+}
+
+// drawLegend draws a colored swatch and name for each series and annotation group
+func (c *Chart) drawLegend(cv canvas, x, y float64) {
+	for _, s := range c.Series {
+		cv.rect(x, y-6, 18, 4, s.Color)
+		cv.text(x+24, y, s.Name, textColor, anchorStart)
+		x += 24 + float64(len(s.Name))*7 + 24
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range c.Annotations {
+		if a.Group == "" || seen[a.Group] {
+			continue
+		}
+		seen[a.Group] = true
+		cv.rect(x+6, y-9, 8, 8, a.Color)
+		cv.text(x+24, y, a.Group, textColor, anchorStart)
+		x += 24 + float64(len(a.Group))*7 + 24
+	}
+}
+
+// axis returns the configuration of an axis
+func (c *Chart) axis(axis Axis) YAxis {
+	if axis == Right {
+		return c.Right
+	}
+	return c.Left
+}
+
+// valueRange returns the range of plottable values on an axis
+func (c *Chart) valueRange(axis Axis, log bool) (lo, hi float64, ok bool) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	probe := valueScale{log: log}
+	for _, s := range c.Series {
+		if s.Axis != axis {
+			continue
+		}
+		for _, v := range s.Values {
+			if probe.valid(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	return lo, hi, lo <= hi
+}
+
+// axisLabel returns the axis title, noting log scales
+func axisLabel(cfg YAxis) string {
+	if cfg.Log {
+		return cfg.Label + " (log)"
+	}
+	return cfg.Label
+}
@@ -0,0 +1,143 @@
+package chart
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testChart() *Chart {
+	var dates []time.Time
+	var linear, exponential []float64
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		dates = append(dates, start.AddDate(0, 0, i*7))
+		linear = append(linear, 1+float64(i)/50)
+		exponential = append(exponential, 20000*math.Exp(float64(i)/40))
+	}
+	linear[50] = math.NaN()
+
+	return &Chart{
+		Title: "Test",
+		Dates: dates,
+		Series: []Series{
+			{Name: "mNAV", Values: linear, Color: color.RGBA{75, 192, 192, 255}, Axis: Left},
+			{Name: "BTC", Values: exponential, Color: color.RGBA{247, 147, 26, 255}, Axis: Right},
+		},
+		Left:   YAxis{Label: "mNAV"},
+		Right:  YAxis{Label: "BTC", Log: true},
+		Width:  400,
+		Height: 300,
+	}
+}
+
+func TestLinearScaleTicks(t *testing.T) {
+	s := newValueScale(0.83, 2.4, false, 100, 0)
+	if s.min != 0.5 || s.max != 2.5 || s.step != 0.5 {
+		t.Fatalf("Expected [0.5, 2.5] step 0.5, got [%v, %v] step %v", s.min, s.max, s.step)
+	}
+	ticks := s.ticks()
+	if len(ticks) != 5 || ticks[1] != 1.0 {
+		t.Errorf("Expected 5 ticks from 0.5 to 2.5, got %v", ticks)
+	}
+	if s.pos(s.min) != 100 || s.pos(s.max) != 0 {
+		t.Errorf("Expected bounds at the pixel range ends")
+	}
+}
+
+func TestLogScaleTicks(t *testing.T) {
+	s := newValueScale(15000, 90000, true, 0, 100)
+	if s.min != 10000 || s.max != 100000 {
+		t.Fatalf("Expected decade bounds [1e4, 1e5], got [%v, %v]", s.min, s.max)
+	}
+	expected := []float64{10000, 20000, 50000, 100000}
+	ticks := s.ticks()
+	if len(ticks) != len(expected) {
+		t.Fatalf("Expected ticks %v, got %v", expected, ticks)
+	}
+	for i := range expected {
+		if math.Abs(ticks[i]-expected[i]) > 1e-6 {
+			t.Errorf("Expected ticks %v, got %v", expected, ticks)
+		}
+	}
+	if math.Abs(s.pos(math.Sqrt(10000*100000))-50) > 1e-9 {
+		t.Error("Expected the geometric midpoint at the pixel midpoint")
+	}
+	if s.valid(0) {
+		t.Error("Expected zero to be unplottable on a log scale")
+	}
+}
+
+func TestDateTicks(t *testing.T) {
+	s := dateScale{
+		min: time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC),
+		max: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+	}
+	ticks, layout := s.ticks(10)
+	if layout != "Jan 2006" || len(ticks) != 7 || !ticks[0].Equal(time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 7 six-monthly ticks from Jul 2021, got %v (%s)", ticks, layout)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testChart().Render(&buf, SVG); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "<svg") || !strings.Contains(out, "<polyline") {
+		t.Fatal("Expected an SVG document with polylines")
+	}
+	if !strings.Contains(out, "BTC (log)") {
+		t.Error("Expected the log axis to be labelled")
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testChart().Render(&buf, PNG); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 300 {
+		t.Errorf("Expected 400x300, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestRenderRejectsMismatchedSeries(t *testing.T) {
+	c := testChart()
+	c.Series[0].Values = c.Series[0].Values[:10]
+	if err := c.Render(&bytes.Buffer{}, SVG); err == nil {
+		t.Error("Expected an error for a series shorter than the dates")
+	}
+}
+
+func TestRenderAnnotations(t *testing.T) {
+	c := testChart()
+	c.Annotations = []Annotation{
+		{Start: c.Dates[10], Label: "Split", Group: "Stock split", Details: []string{"10-for-1 split"}, Color: color.RGBA{46, 160, 67, 255}},
+		{Start: c.Dates[20], End: c.Dates[30], Group: "Offering", Details: []string{"ATM"}, Color: color.RGBA{54, 162, 235, 40}},
+		{Start: c.Dates[0].AddDate(-1, 0, 0), Details: []string{"Out of range"}},
+	}
+
+	var buf bytes.Buffer
+	if err := c.Render(&buf, SVG); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"<title>10-for-1 split</title>", "<title>ATM</title>", ">Split</text>", ">Offering</text>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the SVG", want)
+		}
+	}
+	if strings.Contains(out, "Out of range") {
+		t.Error("Expected annotations before the first date to be skipped")
+	}
+}
@@ -0,0 +1,89 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// pngCanvas rasterizes onto an RGBA image with anti-aliased lines
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	return &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+// paint returns a source image for a non-premultiplied chart color
+func paint(c color.RGBA) *image.Uniform {
+	return image.NewUniform(color.NRGBA{c.R, c.G, c.B, c.A})
+}
+
+func (p *pngCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(p.img, r, paint(fill), image.Point{}, draw.Over)
+}
+
+// polyline strokes each segment as a quad of the given width, filling the joins
+// with a small square at every inner vertex
+func (p *pngCanvas) polyline(points []point, stroke color.RGBA, width float64) {
+	bounds := p.img.Bounds()
+	r := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	half := width / 2
+
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dx, dy := b.x-a.x, b.y-a.y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*half, dx/length*half
+		r.MoveTo(float32(a.x+nx), float32(a.y+ny))
+		r.LineTo(float32(b.x+nx), float32(b.y+ny))
+		r.LineTo(float32(b.x-nx), float32(b.y-ny))
+		r.LineTo(float32(a.x-nx), float32(a.y-ny))
+		r.ClosePath()
+	}
+	if width > 1 && len(points) > 2 {
+		// Same winding as the segment quads so overlaps add up instead of cancelling
+		for _, pt := range points[1 : len(points)-1] {
+			r.MoveTo(float32(pt.x-half), float32(pt.y-half))
+			r.LineTo(float32(pt.x-half), float32(pt.y+half))
+			r.LineTo(float32(pt.x+half), float32(pt.y+half))
+			r.LineTo(float32(pt.x+half), float32(pt.y-half))
+			r.ClosePath()
+		}
+	}
+
+	r.Draw(p.img, bounds, paint(stroke), image.Point{})
+}
+
+func (p *pngCanvas) text(x, y float64, s string, fill color.RGBA, a anchor) {
+	d := &font.Drawer{Dst: p.img, Src: paint(fill), Face: basicfont.Face7x13}
+	w := float64(d.MeasureString(s).Round())
+	switch a {
+	case anchorMiddle:
+		x -= w / 2
+	case anchorEnd:
+		x -= w
+	}
+	d.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	d.DrawString(s)
+}
+
+// hotspot is a no-op: raster images have no hover cards
+func (p *pngCanvas) hotspot(x, y, w, h float64, title string) {}
+
+func (p *pngCanvas) encode(w io.Writer) error {
+	return png.Encode(w, p.img)
+}
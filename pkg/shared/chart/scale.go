@@ -0,0 +1,200 @@
+package chart
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valueScale maps data values onto a pixel range, linearly or logarithmically
+type valueScale struct {
+	min, max float64 // Data range, already rounded out to tick boundaries
+	step     float64 // Linear tick spacing
+	log      bool
+	p0, p1   float64 // Pixel positions of min and max
+}
+
+// newValueScale builds a scale covering [lo, hi] with readable tick boundaries.
+// Log scales ignore non-positive values, so lo must be > 0 when log is set.
+func newValueScale(lo, hi float64, log bool, p0, p1 float64) valueScale {
+	s := valueScale{log: log, p0: p0, p1: p1}
+
+	if log {
+		if lo <= 0 || hi <= 0 {
+			lo, hi = 1, 10
+		}
+		s.min = math.Pow(10, math.Floor(math.Log10(lo)))
+		s.max = math.Pow(10, math.Ceil(math.Log10(hi)))
+		if s.min == s.max {
+			s.max = s.min * 10
+		}
+		return s
+	}
+
+	if lo == hi {
+		pad := math.Abs(lo) * 0.1
+		if pad == 0 {
+			pad = 1
+		}
+		lo, hi = lo-pad, hi+pad
+	}
+	s.step = niceStep((hi - lo) / 5)
+	s.min = math.Floor(lo/s.step) * s.step
+	s.max = math.Ceil(hi/s.step) * s.step
+	return s
+}
+
+// valid reports whether v can be plotted on this scale
+func (s valueScale) valid(v float64) bool {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return false
+	}
+	return !s.log || v > 0
+}
+
+// pos returns the pixel position of v
+func (s valueScale) pos(v float64) float64 {
+	var t float64
+	if s.log {
+		t = (math.Log10(v) - math.Log10(s.min)) / (math.Log10(s.max) - math.Log10(s.min))
+	} else {
+		t = (v - s.min) / (s.max - s.min)
+	}
+	return s.p0 + t*(s.p1-s.p0)
+}
+
+// ticks returns the values to label on the axis
+func (s valueScale) ticks() []float64 {
+	var ticks []float64
+
+	if s.log {
+		lo := int(math.Round(math.Log10(s.min)))
+		hi := int(math.Round(math.Log10(s.max)))
+		multiples := []float64{1}
+		if hi-lo <= 2 {
+			multiples = []float64{1, 2, 5}
+		}
+		for e := lo; e <= hi; e++ {
+			for _, m := range multiples {
+				if v := m * math.Pow(10, float64(e)); v <= s.max*(1+1e-9) {
+					ticks = append(ticks, v)
+				}
+			}
+		}
+		return ticks
+	}
+
+	for v := s.min; v <= s.max+s.step/2; v += s.step {
+		// Snap to the step to avoid float drift like 0.30000000000000004
+		ticks = append(ticks, math.Round(v/s.step)*s.step)
+	}
+	return ticks
+}
+
+// niceStep rounds a raw tick step up to 1, 2 or 5 times a power of ten
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*magnitude >= raw {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// dateScale maps dates onto a pixel range
+type dateScale struct {
+	min, max time.Time
+	p0, p1   float64
+}
+
+// pos returns the pixel position of t
+func (s dateScale) pos(t time.Time) float64 {
+	span := s.max.Sub(s.min)
+	if span <= 0 {
+		return (s.p0 + s.p1) / 2
+	}
+	return s.p0 + float64(t.Sub(s.min))/float64(span)*(s.p1-s.p0)
+}
+
+// dateInterval is a candidate spacing between date ticks
+type dateInterval struct {
+	days, months, years int
+	layout              string
+}
+
+// dateIntervals lists tick spacings from finest to coarsest
+var dateIntervals = []dateInterval{
+	{days: 1, layout: "Jan 02"},
+	{days: 7, layout: "Jan 02"},
+	{months: 1, layout: "Jan 2006"},
+	{months: 3, layout: "Jan 2006"},
+	{months: 6, layout: "Jan 2006"},
+	{years: 1, layout: "2006"},
+	{years: 2, layout: "2006"},
+	{years: 5, layout: "2006"},
+	{years: 10, layout: "2006"},
+}
+
+// ticks returns aligned tick dates (at most about maxTicks) and their label layout
+func (s dateScale) ticks(maxTicks int) ([]time.Time, string) {
+	for _, interval := range dateIntervals {
+		ticks := interval.ticks(s.min, s.max)
+		if len(ticks) <= maxTicks {
+			return ticks, interval.layout
+		}
+	}
+	last := dateIntervals[len(dateIntervals)-1]
+	return last.ticks(s.min, s.max), last.layout
+}
+
+// ticks returns the aligned dates of this interval within [min, max]
+func (i dateInterval) ticks(min, max time.Time) []time.Time {
+	start := time.Date(min.Year(), min.Month(), min.Day(), 0, 0, 0, 0, min.Location())
+	switch {
+	case i.years > 0:
+		year := min.Year() - min.Year()%i.years
+		start = time.Date(year, 1, 1, 0, 0, 0, 0, min.Location())
+	case i.months > 0:
+		month := int(min.Month()) - (int(min.Month())-1)%i.months
+		start = time.Date(min.Year(), time.Month(month), 1, 0, 0, 0, 0, min.Location())
+	case i.days == 7:
+		// Align weekly ticks to Mondays
+		for start.Weekday() != time.Monday {
+			start = start.AddDate(0, 0, 1)
+		}
+	}
+
+	var ticks []time.Time
+	for t := start; !t.After(max); t = t.AddDate(i.years, i.months, i.days) {
+		if !t.Before(min) {
+			ticks = append(ticks, t)
+		}
+	}
+	return ticks
+}
+
+// FormatCompact formats axis values with K/M/B suffixes and no trailing zeros
+func FormatCompact(v float64) string {
+	abs := math.Abs(v)
+	suffix := ""
+	switch {
+	case abs >= 1e9:
+		v, suffix = v/1e9, "B"
+	case abs >= 1e6:
+		v, suffix = v/1e6, "M"
+	case abs >= 1e4:
+		v, suffix = v/1e3, "K"
+	}
+
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s + suffix
+}
@@ -0,0 +1,72 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// svgCanvas accumulates SVG elements
+type svgCanvas struct {
+	width, height int
+	buf           bytes.Buffer
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	return &svgCanvas{width: width, height: height}
+}
+
+// svgColor formats a color as an SVG paint value
+func svgColor(c color.RGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%.2f)", c.R, c.G, c.B, float64(c.A)/255)
+}
+
+func (s *svgCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&s.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, svgColor(fill))
+}
+
+func (s *svgCanvas) polyline(points []point, stroke color.RGBA, width float64) {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", p.x, p.y)
+	}
+	fmt.Fprintf(&s.buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%.1f" stroke-linejoin="round"/>`+"\n",
+		strings.Join(coords, " "), svgColor(stroke), width)
+}
+
+func (s *svgCanvas) text(x, y float64, str string, fill color.RGBA, a anchor) {
+	anchors := map[anchor]string{anchorStart: "start", anchorMiddle: "middle", anchorEnd: "end"}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(str))
+	fmt.Fprintf(&s.buf, `<text x="%.1f" y="%.1f" fill="%s" text-anchor="%s">%s</text>`+"\n",
+		x, y, svgColor(fill), anchors[a], escaped.String())
+}
+
+func (s *svgCanvas) hotspot(x, y, w, h float64, title string) {
+	if title == "" {
+		return
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(title))
+	fmt.Fprintf(&s.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="transparent"><title>%s</title></rect>`+"\n",
+		x, y, w, h, escaped.String())
+}
+
+func (s *svgCanvas) encode(w io.Writer) error {
+	header := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Arial, sans-serif" font-size="12">`+"\n",
+		s.width, s.height, s.width, s.height)
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(s.buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "</svg>\n")
+	return err
+}
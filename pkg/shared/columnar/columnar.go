@@ -0,0 +1,330 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// Type is the logical type of a column
+type Type int
+
+// Column types
+const (
+	Date      Type = iota // time.Time stored as a calendar date
+	Timestamp             // time.Time stored with millisecond precision (UTC)
+	Float                 // float64
+	Int                   // int64
+	String                // string
+	Bool                  // bool
+)
+
+// Format selects the file format written by Write
+type Format string
+
+// Supported formats
+const (
+	Parquet Format = "parquet"
+	Arrow   Format = "arrow" // Arrow IPC file format
+)
+
+// Partitioning selects how rows are split into files by date
+type Partitioning string
+
+// Supported partitionings
+const (
+	PartitionNone  Partitioning = "none"
+	PartitionYear  Partitioning = "year"
+	PartitionMonth Partitioning = "month"
+)
+
+// LineageMetadataKey is the file metadata key holding the lineage of the rows in the file
+const LineageMetadataKey = "mnav.lineage"
+
+// Column describes one typed column
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Table is an in-memory table of typed rows ready for columnar export
+type Table struct {
+	Name       string
+	Columns    []Column
+	Rows       [][]interface{}              // One value per column, nil for null
+	DateColumn string                       // Column used for partitioning; empty disables it
+	Metadata   map[string]string            // Written as file-level key/value metadata
+	Lineage    map[string]models.RowLineage // Optional per-date lineage, keyed by YYYY-MM-DD
+}
+
+// NewTable creates an empty table with the given columns
+func NewTable(name string, columns ...Column) *Table {
+	return &Table{Name: name, Columns: columns, Metadata: make(map[string]string)}
+}
+
+// Append adds a row, checking that each value matches its column type
+func (t *Table) Append(values ...interface{}) error {
+	if len(values) != len(t.Columns) {
+		return fmt.Errorf("%s: expected %d values, got %d", t.Name, len(t.Columns), len(values))
+	}
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		var ok bool
+		switch t.Columns[i].Type {
+		case Date, Timestamp:
+			_, ok = v.(time.Time)
+		case Float:
+			_, ok = v.(float64)
+		case Int:
+			_, ok = v.(int64)
+		case String:
+			_, ok = v.(string)
+		case Bool:
+			_, ok = v.(bool)
+		}
+		if !ok {
+			return fmt.Errorf("%s: column %s cannot hold %T", t.Name, t.Columns[i].Name, v)
+		}
+	}
+	t.Rows = append(t.Rows, values)
+	return nil
+}
+
+// Write writes the table under dir and returns the files written. Unpartitioned tables
+// are written to dir/{name}.{ext}; partitioned ones to hive-style directories such as
+// dir/{name}/year=2024/month=03/part-0.{ext}.
+func Write(t *Table, dir string, format Format, partitioning Partitioning) ([]string, error) {
+	ext, err := extension(format)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := t.partition(partitioning)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var files []string
+	for _, key := range keys {
+		path := filepath.Join(dir, t.Name+ext)
+		if key != "" {
+			path = filepath.Join(dir, t.Name, key, "part-0"+ext)
+		}
+		if err := t.writeFile(path, format, partitions[key]); err != nil {
+			return files, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// extension returns the file extension for a format
+func extension(format Format) (string, error) {
+	switch format {
+	case Parquet:
+		return ".parquet", nil
+	case Arrow:
+		return ".arrow", nil
+	default:
+		return "", fmt.Errorf("unsupported columnar format %q", format)
+	}
+}
+
+// dateIndex returns the index of the date column, or -1 when there is none
+func (t *Table) dateIndex() int {
+	for i, c := range t.Columns {
+		if t.DateColumn != "" && c.Name == t.DateColumn {
+			return i
+		}
+	}
+	return -1
+}
+
+// partition groups rows by partition directory ("" when unpartitioned)
+func (t *Table) partition(partitioning Partitioning) (map[string][][]interface{}, error) {
+	if partitioning == "" || partitioning == PartitionNone || t.DateColumn == "" {
+		return map[string][][]interface{}{"": t.Rows}, nil
+	}
+
+	index := t.dateIndex()
+	if index < 0 {
+		return nil, fmt.Errorf("%s: partition column %s not found", t.Name, t.DateColumn)
+	}
+
+	partitions := make(map[string][][]interface{})
+	for _, row := range t.Rows {
+		date, _ := row[index].(time.Time)
+		var key string
+		switch partitioning {
+		case PartitionYear:
+			key = fmt.Sprintf("year=%04d", date.Year())
+		case PartitionMonth:
+			key = fmt.Sprintf("year=%04d/month=%02d", date.Year(), int(date.Month()))
+		default:
+			return nil, fmt.Errorf("unsupported partitioning %q", partitioning)
+		}
+		partitions[key] = append(partitions[key], row)
+	}
+	if len(partitions) == 0 {
+		partitions[""] = nil
+	}
+	return partitions, nil
+}
+
+// schema builds the Arrow schema, embedding the table metadata and the lineage of rows
+func (t *Table) schema(rows [][]interface{}) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(t.Columns))
+	for i, c := range t.Columns {
+		fields[i] = arrow.Field{Name: c.Name, Type: arrowType(c.Type), Nullable: true}
+	}
+
+	meta := make(map[string]string, len(t.Metadata)+1)
+	for k, v := range t.Metadata {
+		meta[k] = v
+	}
+	if lineage := t.lineageFor(rows); len(lineage) > 0 {
+		data, err := json.Marshal(lineage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode lineage: %w", err)
+		}
+		meta[LineageMetadataKey] = string(data)
+	}
+
+	metadata := arrow.MetadataFrom(meta)
+	return arrow.NewSchema(fields, &metadata), nil
+}
+
+// lineageFor returns the lineage of the dates present in rows
+func (t *Table) lineageFor(rows [][]interface{}) map[string]models.RowLineage {
+	index := t.dateIndex()
+	if len(t.Lineage) == 0 || index < 0 {
+		return nil
+	}
+
+	lineage := make(map[string]models.RowLineage)
+	for _, row := range rows {
+		if date, ok := row[index].(time.Time); ok {
+			key := date.Format("2006-01-02")
+			if rowLineage, ok := t.Lineage[key]; ok {
+				lineage[key] = rowLineage
+			}
+		}
+	}
+	return lineage
+}
+
+// arrowType maps a column type to its Arrow data type
+func arrowType(typ Type) arrow.DataType {
+	switch typ {
+	case Date:
+		return arrow.FixedWidthTypes.Date32
+	case Timestamp:
+		return &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}
+	case Float:
+		return arrow.PrimitiveTypes.Float64
+	case Int:
+		return arrow.PrimitiveTypes.Int64
+	case Bool:
+		return arrow.FixedWidthTypes.Boolean
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// record builds an Arrow record batch from rows
+func (t *Table) record(schema *arrow.Schema, rows [][]interface{}) arrow.RecordBatch {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	for _, row := range rows {
+		for i, v := range row {
+			field := builder.Field(i)
+			if v == nil {
+				field.AppendNull()
+				continue
+			}
+			switch b := field.(type) {
+			case *array.Date32Builder:
+				b.Append(arrow.Date32FromTime(v.(time.Time)))
+			case *array.TimestampBuilder:
+				b.Append(arrow.Timestamp(v.(time.Time).UnixMilli()))
+			case *array.Float64Builder:
+				b.Append(v.(float64))
+			case *array.Int64Builder:
+				b.Append(v.(int64))
+			case *array.BooleanBuilder:
+				b.Append(v.(bool))
+			case *array.StringBuilder:
+				b.Append(v.(string))
+			}
+		}
+	}
+	return builder.NewRecordBatch()
+}
+
+// writeFile encodes rows into one file, replacing it atomically
+func (t *Table) writeFile(path string, format Format, rows [][]interface{}) error {
+	schema, err := t.schema(rows)
+	if err != nil {
+		return err
+	}
+	rec := t.record(schema, rows)
+	defer rec.Release()
+
+	var buf bytes.Buffer
+	switch format {
+	case Parquet:
+		props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+		writer, err := pqarrow.NewFileWriter(schema, &buf, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(rec); err != nil {
+			writer.Close()
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+	case Arrow:
+		writer, err := ipc.NewFileWriter(&buf, ipc.WithSchema(schema))
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(rec); err != nil {
+			writer.Close()
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,149 @@
+package columnar
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+)
+
+func testTable(t *testing.T) *Table {
+	table := NewTable("daily",
+		Column{"date", Date},
+		Column{"price", Float},
+		Column{"closed", Bool},
+	)
+	table.DateColumn = "date"
+	table.Metadata["symbol"] = "MSTR"
+	table.Lineage = map[string]models.RowLineage{
+		"2024-12-31": {models.FieldStockPrice: {Source: "fmp", Method: models.LineageObserved}},
+		"2025-01-02": {models.FieldStockPrice: {Source: "fmp", Method: models.LineageForwardFilled, Filled: true}},
+	}
+
+	rows := [][]interface{}{
+		{time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 350.5, false},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), nil, true},
+		{time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), 340.0, false},
+	}
+	for _, row := range rows {
+		if err := table.Append(row...); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	return table
+}
+
+func TestAppendChecksTypes(t *testing.T) {
+	table := NewTable("t", Column{"date", Date}, Column{"price", Float})
+	if err := table.Append(time.Now(), "not a float"); err == nil {
+		t.Error("Expected a type error for a string in a float column")
+	}
+	if err := table.Append(time.Now()); err == nil {
+		t.Error("Expected an error for a short row")
+	}
+}
+
+func TestWriteParquetPartitioned(t *testing.T) {
+	dir := t.TempDir()
+	files, err := Write(testTable(t), dir, Parquet, PartitionYear)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "daily", "year=2024", "part-0.parquet"),
+		filepath.Join(dir, "daily", "year=2025", "part-0.parquet"),
+	}
+	if len(files) != len(expected) || files[0] != expected[0] || files[1] != expected[1] {
+		t.Fatalf("Expected files %v, got %v", expected, files)
+	}
+
+	reader, err := file.OpenParquetFile(expected[1], false)
+	if err != nil {
+		t.Fatalf("Failed to open parquet file: %v", err)
+	}
+	defer reader.Close()
+
+	meta := reader.MetaData().KeyValueMetadata()
+	if v := meta.FindValue("symbol"); v == nil || *v != "MSTR" {
+		t.Errorf("Expected symbol metadata MSTR, got %v", v)
+	}
+	var lineage map[string]models.RowLineage
+	if v := meta.FindValue(LineageMetadataKey); v == nil {
+		t.Fatal("Expected lineage metadata")
+	} else if err := json.Unmarshal([]byte(*v), &lineage); err != nil {
+		t.Fatalf("Invalid lineage metadata: %v", err)
+	}
+	if len(lineage) != 1 || !lineage["2025-01-02"][models.FieldStockPrice].Filled {
+		t.Errorf("Expected only this partition's lineage, got %v", lineage)
+	}
+
+	fileReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatalf("Failed to create arrow reader: %v", err)
+	}
+	table, err := fileReader.ReadTable(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read table: %v", err)
+	}
+	defer table.Release()
+
+	if table.NumRows() != 2 {
+		t.Errorf("Expected 2 rows in the 2025 partition, got %d", table.NumRows())
+	}
+	if typ := table.Schema().Field(0).Type; typ.ID() != arrow.DATE32 {
+		t.Errorf("Expected date32 date column, got %s", typ)
+	}
+	prices := table.Column(1).Data().Chunk(0).(*array.Float64)
+	if !prices.IsNull(0) || prices.Value(1) != 340.0 {
+		t.Errorf("Expected [null 340], got %v", prices)
+	}
+}
+
+func TestWriteArrowUnpartitioned(t *testing.T) {
+	dir := t.TempDir()
+	files, err := Write(testTable(t), dir, Arrow, PartitionNone)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(dir, "daily.arrow") {
+		t.Fatalf("Expected a single daily.arrow file, got %v", files)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("Failed to open arrow file: %v", err)
+	}
+	defer f.Close()
+
+	reader, err := ipc.NewFileReader(f)
+	if err != nil {
+		t.Fatalf("Failed to read arrow file: %v", err)
+	}
+	defer reader.Close()
+
+	if reader.NumRecords() != 1 {
+		t.Fatalf("Expected 1 record batch, got %d", reader.NumRecords())
+	}
+	rec, err := reader.Record(0)
+	if err != nil {
+		t.Fatalf("Failed to read record: %v", err)
+	}
+	if rec.NumRows() != 3 {
+		t.Errorf("Expected 3 rows, got %d", rec.NumRows())
+	}
+	if _, ok := reader.Schema().Metadata().GetValue(LineageMetadataKey); !ok {
+		t.Error("Expected lineage in the schema metadata")
+	}
+}
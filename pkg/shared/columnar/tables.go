@@ -0,0 +1,83 @@
+package columnar
+
+import (
+	"time"
+
+	portfoliomodels "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+)
+
+// TransactionsTable builds a table of a company's Bitcoin transactions
+func TransactionsTable(symbol string, txs []models.BitcoinTransaction) (*Table, error) {
+	table := NewTable("transactions",
+		Column{"symbol", String},
+		Column{"date", Date},
+		Column{"filing_type", String},
+		Column{"filing_url", String},
+		Column{"btc_purchased", Float},
+		Column{"usd_spent", Float},
+		Column{"avg_price_usd", Float},
+		Column{"total_btc_after", Float},
+		Column{"confidence_score", Float},
+		Column{"known_at", Timestamp},
+	)
+	table.DateColumn = "date"
+	table.Metadata["symbol"] = symbol
+
+	for _, tx := range txs {
+		if err := table.Append(symbol, tx.Date, tx.FilingType, tx.FilingURL, tx.BTCPurchased, tx.USDSpent,
+			tx.AvgPriceUSD, NullableFloat(tx.TotalBTCAfter), tx.ConfidenceScore, tx.KnowledgeTime()); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// PortfolioTable builds a long table with one row per position per portfolio snapshot
+func PortfolioTable(portfolios []*portfoliomodels.Portfolio) (*Table, error) {
+	table := NewTable("portfolio",
+		Column{"date", Date},
+		Column{"account_number", String},
+		Column{"account_name", String},
+		Column{"symbol", String},
+		Column{"description", String},
+		Column{"type", String},
+		Column{"quantity", Float},
+		Column{"last_price", Float},
+		Column{"current_value", Float},
+		Column{"cost_basis_total", Float},
+		Column{"average_cost_basis", Float},
+		Column{"total_gain_loss", Float},
+		Column{"total_gain_loss_percent", Float},
+		Column{"percent_of_account", Float},
+		Column{"source_file", String},
+	)
+	table.DateColumn = "date"
+
+	for _, p := range portfolios {
+		for _, pos := range p.Positions {
+			if err := table.Append(p.Date, portfoliomodels.MaskAccount(pos.AccountNumber), portfoliomodels.MaskAccount(pos.AccountName), pos.Symbol, pos.Description, pos.Type,
+				pos.Quantity, pos.LastPrice, pos.CurrentValue, pos.CostBasisTotal, pos.AverageCostBasis,
+				pos.TotalGainLoss, pos.TotalGainLossPct, pos.PercentOfAccount, p.SourceFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return table, nil
+}
+
+// NullableFloat returns nil for zero so missing values are written as nulls
+func NullableFloat(v float64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// NullableTime returns nil for the zero time
+func NullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Company facts are bitemporal: Date is the valid time (when the fact was true)
+// and KnownAt/SupersededAt bound the knowledge time (when we believed it).
+// Every save stamps new facts with KnownAt; only records written before knowledge
+// tracking lack it, and those are treated as known from their valid date.
+
+// ParseKnowledgeDate parses a YYYY-MM-DD knowledge date into the end of that day (UTC),
+// so that everything recorded during the day counts as known
+func ParseKnowledgeDate(value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid knowledge date %q (expected YYYY-MM-DD): %w", value, err)
+	}
+	return date.Add(24*time.Hour - time.Nanosecond), nil
+}
+
+// knownAsOf reports whether a fact version was believed at the given knowledge time.
+// A zero asOf means "now", i.e. only the current version counts.
+func knownAsOf(knownAt, supersededAt, asOf time.Time) bool {
+	if asOf.IsZero() {
+		return supersededAt.IsZero()
+	}
+	if knownAt.After(asOf) {
+		return false
+	}
+	return supersededAt.IsZero() || supersededAt.After(asOf)
+}
+
+// KnowledgeTime returns when this version of the transaction became known
+func (t BitcoinTransaction) KnowledgeTime() time.Time {
+	if !t.KnownAt.IsZero() {
+		return t.KnownAt
+	}
+	return t.Date
+}
+
+// KnownAsOf reports whether this version of the transaction was known at asOf
+func (t BitcoinTransaction) KnownAsOf(asOf time.Time) bool {
+	return knownAsOf(t.KnowledgeTime(), t.SupersededAt, asOf)
+}
+
+// factKey identifies a transaction version by its content, ignoring knowledge time
+func (t BitcoinTransaction) factKey() string {
+	return fmt.Sprintf("%s|%s|%s|%g|%g|%g|%g", t.Date.UTC().Format(time.RFC3339), t.FilingType, t.FilingURL,
+		t.BTCPurchased, t.USDSpent, t.AvgPriceUSD, t.TotalBTCAfter)
+}
+
+// KnowledgeTime returns when this version of the shares record became known
+func (r SharesOutstandingRecord) KnowledgeTime() time.Time {
+	if !r.KnownAt.IsZero() {
+		return r.KnownAt
+	}
+	return r.Date
+}
+
+// KnownAsOf reports whether this version of the shares record was known at asOf
+func (r SharesOutstandingRecord) KnownAsOf(asOf time.Time) bool {
+	return knownAsOf(r.KnowledgeTime(), r.SupersededAt, asOf)
+}
+
+// factKey identifies a shares record version by its content, ignoring knowledge time
+func (r SharesOutstandingRecord) factKey() string {
+	return fmt.Sprintf("%s|%s|%s|%g|%g|%g", r.Date.UTC().Format(time.RFC3339), r.FilingType, r.AccessionNumber,
+		r.CommonShares, r.PreferredShares, r.TotalShares)
+}
+
+// TransactionsKnownAsOf returns the transaction versions known at asOf, sorted by date
+func TransactionsKnownAsOf(txs []BitcoinTransaction, asOf time.Time) []BitcoinTransaction {
+	known := []BitcoinTransaction{}
+	for _, tx := range txs {
+		if tx.KnownAsOf(asOf) {
+			known = append(known, tx)
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool { return known[i].Date.Before(known[j].Date) })
+	return known
+}
+
+// SharesKnownAsOf returns the shares record versions known at asOf, sorted by date
+func SharesKnownAsOf(records []SharesOutstandingRecord, asOf time.Time) []SharesOutstandingRecord {
+	known := []SharesOutstandingRecord{}
+	for _, r := range records {
+		if r.KnownAsOf(asOf) {
+			known = append(known, r)
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool { return known[i].Date.Before(known[j].Date) })
+	return known
+}
+
+// ReviseTransactions compares a new set of transactions against the previous versions.
+// Unchanged facts keep their original knowledge time, new facts (all of them on a first
+// save) are stamped with now, and previous facts missing from next are returned as
+// superseded at now.
+func ReviseTransactions(previous, next []BitcoinTransaction, now time.Time) (current, superseded []BitcoinTransaction) {
+	prior := make(map[string][]BitcoinTransaction)
+	for _, tx := range previous {
+		prior[tx.factKey()] = append(prior[tx.factKey()], tx)
+	}
+
+	for _, tx := range next {
+		key := tx.factKey()
+		if matches := prior[key]; len(matches) > 0 {
+			tx.KnownAt = matches[0].KnownAt
+			prior[key] = matches[1:]
+		} else if tx.KnownAt.IsZero() {
+			tx.KnownAt = now
+		}
+		tx.SupersededAt = time.Time{}
+		current = append(current, tx)
+	}
+
+	for _, tx := range previous {
+		key := tx.factKey()
+		if matches := prior[key]; len(matches) > 0 {
+			removed := matches[0]
+			removed.KnownAt = removed.KnowledgeTime()
+			removed.SupersededAt = now
+			superseded = append(superseded, removed)
+			prior[key] = matches[1:]
+		}
+	}
+
+	return current, superseded
+}
+
+// ReviseShares is the shares history counterpart of ReviseTransactions
+func ReviseShares(previous, next []SharesOutstandingRecord, now time.Time) (current, superseded []SharesOutstandingRecord) {
+	prior := make(map[string][]SharesOutstandingRecord)
+	for _, r := range previous {
+		prior[r.factKey()] = append(prior[r.factKey()], r)
+	}
+
+	for _, r := range next {
+		key := r.factKey()
+		if matches := prior[key]; len(matches) > 0 {
+			r.KnownAt = matches[0].KnownAt
+			prior[key] = matches[1:]
+		} else if r.KnownAt.IsZero() {
+			r.KnownAt = now
+		}
+		r.SupersededAt = time.Time{}
+		current = append(current, r)
+	}
+
+	for _, r := range previous {
+		key := r.factKey()
+		if matches := prior[key]; len(matches) > 0 {
+			removed := matches[0]
+			removed.KnownAt = removed.KnowledgeTime()
+			removed.SupersededAt = now
+			superseded = append(superseded, removed)
+			prior[key] = matches[1:]
+		}
+	}
+
+	return current, superseded
+}
+
+// Revise records this data as a new version of previous: unchanged facts keep their
+// knowledge time and amended or removed facts move to the superseded lists. A nil
+// previous is a first save, which stamps every fact as known at now.
+func (d *CompanyFinancialData) Revise(previous *CompanyFinancialData, now time.Time) {
+	if previous == nil {
+		previous = &CompanyFinancialData{}
+	}
+
+	var supersededTxs []BitcoinTransaction
+	d.BTCTransactions, supersededTxs = ReviseTransactions(previous.BTCTransactions, d.BTCTransactions, now)
+	d.SupersededTransactions = append(append([]BitcoinTransaction{}, previous.SupersededTransactions...), supersededTxs...)
+
+	var supersededShares []SharesOutstandingRecord
+	d.SharesHistory, supersededShares = ReviseShares(previous.SharesHistory, d.SharesHistory, now)
+	d.SupersededShares = append(append([]SharesOutstandingRecord{}, previous.SupersededShares...), supersededShares...)
+}
+
+// AsOfKnowledge returns a copy of the company data containing only the fact versions
+// that were known at asOf. A zero asOf returns the current versions. Facts without a
+// KnownAt, written before knowledge tracking, count as known from their valid date.
+func (d *CompanyFinancialData) AsOfKnowledge(asOf time.Time) *CompanyFinancialData {
+	view := *d
+	view.BTCTransactions = TransactionsKnownAsOf(append(append([]BitcoinTransaction{}, d.BTCTransactions...), d.SupersededTransactions...), asOf)
+	view.SharesHistory = SharesKnownAsOf(append(append([]SharesOutstandingRecord{}, d.SharesHistory...), d.SupersededShares...), asOf)
+	view.SupersededTransactions = nil
+	view.SupersededShares = nil
+	return &view
+}
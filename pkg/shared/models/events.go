@@ -0,0 +1,252 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventType classifies a company or market event shown on timelines
+type EventType string
+
+// Event types
+const (
+	EventBTCPurchase         EventType = "btc_purchase"
+	EventEquityOffering      EventType = "equity_offering"
+	EventPreferredOffering   EventType = "preferred_offering"
+	EventConvertibleIssuance EventType = "convertible_issuance"
+	EventEarnings            EventType = "earnings"
+	EventStockSplit          EventType = "stock_split"
+	EventIndexInclusion      EventType = "index_inclusion"
+	EventOther               EventType = "other"
+)
+
+// EventTypes lists the known event types in display order
+var EventTypes = []EventType{
+	EventBTCPurchase, EventEquityOffering, EventPreferredOffering, EventConvertibleIssuance,
+	EventEarnings, EventStockSplit, EventIndexInclusion, EventOther,
+}
+
+// Label returns a human-readable name for the event type
+func (t EventType) Label() string {
+	switch t {
+	case EventBTCPurchase:
+		return "BTC purchase"
+	case EventEquityOffering:
+		return "Equity offering"
+	case EventPreferredOffering:
+		return "Preferred offering"
+	case EventConvertibleIssuance:
+		return "Convertible notes"
+	case EventEarnings:
+		return "Earnings"
+	case EventStockSplit:
+		return "Stock split"
+	case EventIndexInclusion:
+		return "Index inclusion"
+	default:
+		return "Other"
+	}
+}
+
+// Valid reports whether t is a known event type
+func (t EventType) Valid() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CompanyEvent is a dated event for a company. Events with an EndDate after Date
+// cover a range, such as an at-the-market offering program.
+type CompanyEvent struct {
+	Date        time.Time `json:"date"`
+	EndDate     time.Time `json:"endDate,omitzero"`
+	Type        EventType `json:"type"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	AmountUSD   float64   `json:"amountUsd,omitempty"` // Proceeds raised or cash spent
+	BTC         float64   `json:"btc,omitempty"`       // Bitcoin acquired
+	Ratio       float64   `json:"ratio,omitempty"`     // Split ratio, e.g. 10 for a 10-for-1 split
+	URL         string    `json:"url,omitempty"`
+	Source      string    `json:"source,omitempty"` // "sec" for events derived from filings, "user" for the events file
+}
+
+// eventJSON is the on-disk layout, with dates as YYYY-MM-DD so the file is easy to edit
+type eventJSON struct {
+	Date        string    `json:"date"`
+	EndDate     string    `json:"endDate,omitempty"`
+	Type        EventType `json:"type"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	AmountUSD   float64   `json:"amountUsd,omitempty"`
+	BTC         float64   `json:"btc,omitempty"`
+	Ratio       float64   `json:"ratio,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Source      string    `json:"source,omitempty"`
+}
+
+// MarshalJSON writes dates as YYYY-MM-DD
+func (e CompanyEvent) MarshalJSON() ([]byte, error) {
+	out := eventJSON{
+		Date: e.Date.Format("2006-01-02"), Type: e.Type, Title: e.Title, Description: e.Description,
+		AmountUSD: e.AmountUSD, BTC: e.BTC, Ratio: e.Ratio, URL: e.URL, Source: e.Source,
+	}
+	if !e.EndDate.IsZero() {
+		out.EndDate = e.EndDate.Format("2006-01-02")
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON accepts YYYY-MM-DD or RFC 3339 dates and rejects unknown event types
+func (e *CompanyEvent) UnmarshalJSON(data []byte) error {
+	var in eventJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	date, err := parseEventDate(in.Date)
+	if err != nil {
+		return fmt.Errorf("event %q: %w", in.Title, err)
+	}
+	var end time.Time
+	if in.EndDate != "" {
+		if end, err = parseEventDate(in.EndDate); err != nil {
+			return fmt.Errorf("event %q: %w", in.Title, err)
+		}
+		if end.Before(date) {
+			return fmt.Errorf("event %q ends before it starts", in.Title)
+		}
+	}
+	if !in.Type.Valid() {
+		return fmt.Errorf("event %q has unknown type %q", in.Title, in.Type)
+	}
+
+	*e = CompanyEvent{
+		Date: date, EndDate: end, Type: in.Type, Title: in.Title, Description: in.Description,
+		AmountUSD: in.AmountUSD, BTC: in.BTC, Ratio: in.Ratio, URL: in.URL, Source: in.Source,
+	}
+	return nil
+}
+
+// parseEventDate parses a YYYY-MM-DD or RFC 3339 date
+func parseEventDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", s)
+	}
+	return t, nil
+}
+
+// IsRange reports whether the event spans more than one day
+func (e CompanyEvent) IsRange() bool {
+	return e.EndDate.After(e.Date)
+}
+
+// Summary returns the event's details as lines for hover cards
+func (e CompanyEvent) Summary() []string {
+	when := e.Date.Format("2006-01-02")
+	if e.IsRange() {
+		when += " to " + e.EndDate.Format("2006-01-02")
+	}
+	lines := []string{fmt.Sprintf("%s: %s", e.Type.Label(), e.Title), when}
+
+	if e.BTC != 0 {
+		lines = append(lines, fmt.Sprintf("BTC: %.0f", e.BTC))
+	}
+	if e.AmountUSD != 0 {
+		lines = append(lines, fmt.Sprintf("Amount: $%.1fM", e.AmountUSD/1e6))
+	}
+	if e.Ratio != 0 {
+		lines = append(lines, fmt.Sprintf("Ratio: %g-for-1", e.Ratio))
+	}
+	if e.Description != "" {
+		lines = append(lines, e.Description)
+	}
+	return lines
+}
+
+// CompanyEvents is the user-editable events file for a company
+type CompanyEvents struct {
+	SchemaVersion int            `json:"schemaVersion,omitempty"`
+	Symbol        string         `json:"symbol"`
+	Events        []CompanyEvent `json:"events"`
+}
+
+// EventsFromTransactions turns Bitcoin purchases into purchase events
+func EventsFromTransactions(txs []BitcoinTransaction) []CompanyEvent {
+	events := make([]CompanyEvent, 0, len(txs))
+	for _, tx := range txs {
+		if tx.BTCPurchased <= 0 {
+			continue
+		}
+		title := fmt.Sprintf("Bought %.0f BTC", tx.BTCPurchased)
+		if tx.AvgPriceUSD > 0 {
+			title += fmt.Sprintf(" at $%.0f", tx.AvgPriceUSD)
+		}
+		var description string
+		if tx.TotalBTCAfter > 0 {
+			description = fmt.Sprintf("Total holdings %.0f BTC", tx.TotalBTCAfter)
+		}
+		events = append(events, CompanyEvent{
+			Date:        tx.Date,
+			Type:        EventBTCPurchase,
+			Title:       title,
+			Description: description,
+			AmountUSD:   tx.USDSpent,
+			BTC:         tx.BTCPurchased,
+			URL:         tx.FilingURL,
+			Source:      "sec",
+		})
+	}
+	return events
+}
+
+// MergeEvents combines event lists sorted by date. An event with the same date and
+// type as one in an earlier list replaces it, so user edits override derived events.
+func MergeEvents(lists ...[]CompanyEvent) []CompanyEvent {
+	index := make(map[string]int)
+	var merged []CompanyEvent
+	for _, list := range lists {
+		for _, e := range list {
+			key := e.Date.Format("2006-01-02") + "|" + string(e.Type)
+			if e.Type == EventOther {
+				key += "|" + strings.ToLower(e.Title)
+			}
+			if i, ok := index[key]; ok {
+				merged[i] = e
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, e)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Date.Before(merged[j].Date)
+	})
+	return merged
+}
+
+// EventsBetween returns the events that overlap [start, end]
+func EventsBetween(events []CompanyEvent, start, end time.Time) []CompanyEvent {
+	var within []CompanyEvent
+	for _, e := range events {
+		last := e.Date
+		if e.IsRange() {
+			last = e.EndDate
+		}
+		if last.Before(start) || e.Date.After(end) {
+			continue
+		}
+		within = append(within, e)
+	}
+	return within
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// USD is the currency all valuations are computed in
+const USD = "USD"
+
+// MaxFXRateAge is how far a rate is carried forward over weekends and holidays
+const MaxFXRateAge = 7 * 24 * time.Hour
+
+// CurrencyCode normalises an ISO 4217 code, treating an empty code as USD
+func CurrencyCode(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return USD
+	}
+	return currency
+}
+
+// CurrencySymbol returns the display prefix for amounts in a currency
+func CurrencySymbol(currency string) string {
+	switch CurrencyCode(currency) {
+	case "USD":
+		return "$"
+	case "EUR":
+		return "€"
+	case "JPY":
+		return "¥"
+	case "GBP":
+		return "£"
+	default:
+		return CurrencyCode(currency) + " "
+	}
+}
+
+// Money is an amount tagged with its currency
+type Money struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// String formats the amount with its currency symbol
+func (m Money) String() string {
+	return fmt.Sprintf("%s%.2f", CurrencySymbol(m.Currency), m.Amount)
+}
+
+// FXRate is the USD value of one unit of a currency on a date
+type FXRate struct {
+	Date       time.Time `json:"date"`
+	USDPerUnit float64   `json:"usdPerUnit"`
+}
+
+// fxRateJSON is the on-disk layout, with dates as YYYY-MM-DD
+type fxRateJSON struct {
+	Date       string  `json:"date"`
+	USDPerUnit float64 `json:"usdPerUnit"`
+}
+
+// MarshalJSON writes the date as YYYY-MM-DD
+func (r FXRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fxRateJSON{Date: r.Date.Format("2006-01-02"), USDPerUnit: r.USDPerUnit})
+}
+
+// UnmarshalJSON accepts YYYY-MM-DD or RFC 3339 dates
+func (r *FXRate) UnmarshalJSON(data []byte) error {
+	var in fxRateJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	date, err := parseEventDate(in.Date)
+	if err != nil {
+		return fmt.Errorf("fx rate: %w", err)
+	}
+	*r = FXRate{Date: date, USDPerUnit: in.USDPerUnit}
+	return nil
+}
+
+// FXRates is the stored daily rate history for one currency against USD
+type FXRates struct {
+	SchemaVersion int       `json:"schemaVersion,omitempty"`
+	Currency      string    `json:"currency"`
+	Source        string    `json:"source,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt,omitzero"`
+	Rates         []FXRate  `json:"rates"`
+}
+
+// Merge adds rates, replacing any already stored for the same date, and keeps the
+// history sorted. It returns how many dates were new.
+func (r *FXRates) Merge(rates []FXRate) int {
+	byDate := make(map[string]int, len(r.Rates))
+	for i, rate := range r.Rates {
+		byDate[rate.Date.Format("2006-01-02")] = i
+	}
+
+	added := 0
+	for _, rate := range rates {
+		if rate.USDPerUnit <= 0 {
+			continue
+		}
+		key := rate.Date.Format("2006-01-02")
+		if i, ok := byDate[key]; ok {
+			r.Rates[i] = rate
+			continue
+		}
+		byDate[key] = len(r.Rates)
+		r.Rates = append(r.Rates, rate)
+		added++
+	}
+	sort.SliceStable(r.Rates, func(i, j int) bool { return r.Rates[i].Date.Before(r.Rates[j].Date) })
+	return added
+}
+
+// FXTable converts amounts between currencies at the rates in effect on a date.
+// A nil table can only "convert" between identical currencies.
+type FXTable struct {
+	rates map[string][]FXRate
+}
+
+// NewFXTable builds a table from stored rate histories
+func NewFXTable(histories ...*FXRates) *FXTable {
+	t := &FXTable{rates: make(map[string][]FXRate)}
+	for _, h := range histories {
+		t.Add(h)
+	}
+	return t
+}
+
+// Add adds or replaces a currency's rate history
+func (t *FXTable) Add(history *FXRates) {
+	if history == nil || len(history.Rates) == 0 {
+		return
+	}
+	rates := append([]FXRate(nil), history.Rates...)
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	t.rates[CurrencyCode(history.Currency)] = rates
+}
+
+// Currencies lists the currencies the table has rates for, plus USD
+func (t *FXTable) Currencies() []string {
+	currencies := []string{USD}
+	if t != nil {
+		for currency := range t.rates {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// RateAt returns the USD value of one unit of currency on date, carrying the latest
+// earlier rate forward by up to MaxFXRateAge
+func (t *FXTable) RateAt(currency string, date time.Time) (float64, error) {
+	currency = CurrencyCode(currency)
+	if currency == USD {
+		return 1, nil
+	}
+	if t == nil || len(t.rates[currency]) == 0 {
+		return 0, fmt.Errorf("no %s/USD rates (run fx-rates -currencies %s)", currency, currency)
+	}
+
+	rates := t.rates[currency]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(date) }) - 1
+	if i < 0 {
+		return 0, fmt.Errorf("no %s/USD rate on or before %s", currency, date.Format("2006-01-02"))
+	}
+	if date.Sub(rates[i].Date) > MaxFXRateAge {
+		return 0, fmt.Errorf("latest %s/USD rate before %s is from %s", currency,
+			date.Format("2006-01-02"), rates[i].Date.Format("2006-01-02"))
+	}
+	return rates[i].USDPerUnit, nil
+}
+
+// Convert converts an amount between currencies at the rates in effect on date,
+// crossing through USD
+func (t *FXTable) Convert(amount float64, from, to string, date time.Time) (float64, error) {
+	from, to = CurrencyCode(from), CurrencyCode(to)
+	if from == to {
+		return amount, nil
+	}
+	fromRate, err := t.RateAt(from, date)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := t.RateAt(to, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount * fromRate / toRate, nil
+}
+
+// ConvertMoney converts m into currency at the rates in effect on date
+func (t *FXTable) ConvertMoney(m Money, currency string, date time.Time) (Money, error) {
+	amount, err := t.Convert(m.Amount, m.Currency, currency, date)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: CurrencyCode(currency)}, nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFXTable(t *testing.T) {
+	date := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	table := NewFXTable(
+		&FXRates{Currency: "JPY", Rates: []FXRate{{Date: date(5), USDPerUnit: 0.007}, {Date: date(2), USDPerUnit: 0.006}}},
+		&FXRates{Currency: "eur", Rates: []FXRate{{Date: date(2), USDPerUnit: 1.1}}},
+	)
+
+	tests := []struct {
+		amount   float64
+		from, to string
+		date     time.Time
+		want     float64
+	}{
+		{100, "USD", "", date(1), 100},
+		{1000, "JPY", "USD", date(2), 6},
+		{1000, "JPY", "USD", date(4), 6}, // carried forward
+		{1000, "JPY", "USD", date(5), 7},
+		{11, "USD", "EUR", date(3), 10},
+		{110, "EUR", "JPY", date(2), 110 * 1.1 / 0.006}, // crossed through USD
+	}
+	for _, tt := range tests {
+		got, err := table.Convert(tt.amount, tt.from, tt.to, tt.date)
+		if err != nil {
+			t.Errorf("%s→%s on %s: %v", tt.from, tt.to, tt.date.Format("2006-01-02"), err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9*math.Max(1, tt.want) {
+			t.Errorf("%s→%s on %s = %f, want %f", tt.from, tt.to, tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+
+	if _, err := table.Convert(1, "JPY", "USD", date(1)); err == nil {
+		t.Error("expected an error before the first rate")
+	}
+	if _, err := table.Convert(1, "EUR", "USD", date(2).Add(MaxFXRateAge+time.Hour)); err == nil {
+		t.Error("expected an error for a stale rate")
+	}
+	if _, err := table.Convert(1, "GBP", "USD", date(2)); err == nil {
+		t.Error("expected an error for a currency without rates")
+	}
+
+	var none *FXTable
+	if got, err := none.ConvertMoney(Money{Amount: 5, Currency: "JPY"}, "jpy", date(1)); err != nil || got.Amount != 5 || got.Currency != "JPY" {
+		t.Errorf("a nil table should pass same-currency amounts through, got %+v (%v)", got, err)
+	}
+}
+
+func TestFXRatesMerge(t *testing.T) {
+	date := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	rates := &FXRates{Currency: "JPY", Rates: []FXRate{{Date: date(3), USDPerUnit: 0.007}}}
+
+	added := rates.Merge([]FXRate{{Date: date(3), USDPerUnit: 0.0071}, {Date: date(1), USDPerUnit: 0.006}, {Date: date(2), USDPerUnit: 0}})
+	if added != 1 || len(rates.Rates) != 2 {
+		t.Fatalf("expected one new rate, got %d (%d stored)", added, len(rates.Rates))
+	}
+	if !rates.Rates[0].Date.Equal(date(1)) || rates.Rates[1].USDPerUnit != 0.0071 {
+		t.Errorf("expected sorted rates with the revision applied, got %+v", rates.Rates)
+	}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Lineage methods describe how a field value for a given day was obtained
+const (
+	LineageObserved       = "observed"        // Read from a source for that exact date
+	LineageLive           = "live"            // Fetched in real time during the run
+	LineageForwardFilled  = "forward_filled"  // Copied from an earlier day's observation
+	LineageCarriedForward = "carried_forward" // Running balance unchanged since its last event
+	LineageNearestPrior   = "nearest_prior"   // Most recent earlier record
+	LineageLatestKnown    = "latest_known"    // Current value applied to a historical date
+	LineageFreshOverride  = "fresh_override"  // Replaced by a newer holdings snapshot
+	LineageDerived        = "derived"         // Calculated from other fields
+)
+
+// Lineage field names shared by the exporters and chart tooltips
+const (
+	FieldStockPrice        = "stock_price"
+	FieldStockVolume       = "stock_volume"
+	FieldBitcoinPrice      = "bitcoin_price"
+	FieldBitcoinHoldings   = "bitcoin_holdings"
+	FieldSharesOutstanding = "shares_outstanding"
+	FieldMarketCap         = "market_cap"
+	FieldBitcoinValue      = "bitcoin_value"
+	FieldMNAV              = "mnav"
+	FieldPremium           = "premium"
+	FieldBitcoinPerShare   = "bitcoin_per_share"
+	FieldBookValuePerShare = "book_value_per_share"
+	FieldPriceToBook       = "price_to_book"
+	FieldBitcoinYield      = "bitcoin_yield"
+	FieldTransactionAmount = "transaction_amount"
+	FieldCumulativeInvest  = "cumulative_investment"
+	FieldAverageCost       = "average_bitcoin_cost"
+)
+
+// FieldLineage records where one field of one daily row came from
+type FieldLineage struct {
+	Source     string    `json:"source"`               // Source system, e.g. coinmarketcap, yahoo, sec
+	File       string    `json:"file,omitempty"`       // File or database the value was read from
+	FetchedAt  time.Time `json:"fetchedAt,omitzero"`   // When the source data was collected
+	Method     string    `json:"method"`               // One of the Lineage* methods
+	Filled     bool      `json:"filled,omitempty"`     // Not observed for this date (filled or interpolated)
+	FilledFrom string    `json:"filledFrom,omitempty"` // Date (YYYY-MM-DD) the value was taken from
+}
+
+// RowLineage maps field names to their lineage for one day
+type RowLineage map[string]FieldLineage
+
+// LineageManifest is the JSON sidecar describing every field of an exported dataset
+type LineageManifest struct {
+	Symbol      string                `json:"symbol"`
+	DataFile    string                `json:"dataFile"`
+	GeneratedAt time.Time             `json:"generatedAt"`
+	Rows        map[string]RowLineage `json:"rows"` // Keyed by date (YYYY-MM-DD)
+}
+
+// FilledFromDate returns a copy of the lineage marked as filled from the given date (YYYY-MM-DD)
+func (l FieldLineage) FilledFromDate(method, date string) FieldLineage {
+	l.Method = method
+	l.Filled = true
+	l.FilledFrom = date
+	return l
+}
+
+// DerivedLineage returns the lineage of a value calculated from the given inputs.
+// The result counts as filled when any input was.
+func DerivedLineage(inputs ...FieldLineage) FieldLineage {
+	derived := FieldLineage{Source: "calculated", Method: LineageDerived}
+	for _, input := range inputs {
+		if input.Filled {
+			derived.Filled = true
+		}
+	}
+	return derived
+}
+
+// FileLineage describes values observed in a local data file, using its modification time
+// as the fetch time
+func FileLineage(source, file string) FieldLineage {
+	lineage := FieldLineage{Source: source, File: file, Method: LineageObserved}
+	if info, err := os.Stat(file); err == nil {
+		lineage.FetchedAt = info.ModTime()
+	}
+	return lineage
+}
+
+// String formats the lineage for tooltips and CSV sidecar columns
+func (l FieldLineage) String() string {
+	if l.Source == "" && l.Method == "" {
+		return ""
+	}
+
+	parts := []string{l.Source}
+	if l.File != "" {
+		parts = append(parts, "("+l.File+")")
+	}
+	parts = append(parts, l.Method)
+	switch {
+	case l.FilledFrom != "":
+		parts = append(parts, "from "+l.FilledFrom)
+	case l.Filled && l.Method == LineageDerived:
+		parts = append(parts, "(uses filled inputs)")
+	case l.Filled:
+		parts = append(parts, "(filled)")
+	}
+	if !l.FetchedAt.IsZero() {
+		parts = append(parts, fmt.Sprintf("fetched %s", l.FetchedAt.Format("2006-01-02 15:04")))
+	}
+	return strings.Join(parts, " ")
+}
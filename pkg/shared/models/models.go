@@ -13,14 +13,17 @@ type SharesOutstandingRecord struct {
 	CommonShares    float64   `json:"commonShares"`
 	PreferredShares float64   `json:"preferredShares,omitempty"`
 	TotalShares     float64   `json:"totalShares"`
-	ExtractedFrom   string    `json:"extractedFrom"`   // Section of filing where data was found
-	ExtractedText   string    `json:"extractedText"`   // Raw text that was parsed
-	ConfidenceScore float64   `json:"confidenceScore"` // 0.0 to 1.0
-	Notes           string    `json:"notes,omitempty"` // Any additional notes
+	ExtractedFrom   string    `json:"extractedFrom"`          // Section of filing where data was found
+	ExtractedText   string    `json:"extractedText"`          // Raw text that was parsed
+	ConfidenceScore float64   `json:"confidenceScore"`        // 0.0 to 1.0
+	Notes           string    `json:"notes,omitempty"`        // Any additional notes
+	KnownAt         time.Time `json:"knownAt,omitempty"`      // When this version was first recorded
+	SupersededAt    time.Time `json:"supersededAt,omitempty"` // When a later version replaced it
 }
 
 // CompanyFinancialData represents comprehensive financial data for a company from SEC filings
 type CompanyFinancialData struct {
+	SchemaVersion     int                       `json:"schemaVersion,omitempty"`
 	Symbol            string                    `json:"symbol"`
 	CompanyName       string                    `json:"companyName"`
 	CIK               string                    `json:"cik"`
@@ -29,6 +32,10 @@ type CompanyFinancialData struct {
 	LastUpdated       time.Time                 `json:"lastUpdated"`
 	LastFilingDate    time.Time                 `json:"lastFilingDate"`
 	LastProcessedDate time.Time                 `json:"lastProcessedDate"`
+
+	// Earlier versions of amended or removed facts, kept for as-of-knowledge queries
+	SupersededShares       []SharesOutstandingRecord `json:"supersededShares,omitempty"`
+	SupersededTransactions []BitcoinTransaction      `json:"supersededTransactions,omitempty"`
 }
 
 // FilingMetadata represents metadata about a processed filing
@@ -80,6 +87,7 @@ type AuditableSharesData struct {
 
 // CompanyDataSnapshot represents a point-in-time snapshot of all company data
 type CompanyDataSnapshot struct {
+	SchemaVersion     int                 `json:"schemaVersion,omitempty"`
 	Symbol            string              `json:"symbol"`
 	SnapshotDate      time.Time           `json:"snapshotDate"`
 	SharesOutstanding AuditableSharesData `json:"sharesOutstanding"`
@@ -90,6 +98,7 @@ type CompanyDataSnapshot struct {
 
 // RawFilingDocument represents a raw SEC filing document
 type RawFilingDocument struct {
+	SchemaVersion   int       `json:"schemaVersion,omitempty"`
 	AccessionNumber string    `json:"accessionNumber"`
 	FilingType      string    `json:"filingType"`
 	FilingDate      time.Time `json:"filingDate"`
@@ -134,6 +143,8 @@ type BitcoinTransaction struct {
 	ExtractedText   string                 `json:"extractedText"`
 	ConfidenceScore float64                `json:"confidenceScore"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	KnownAt         time.Time              `json:"knownAt,omitempty"`      // When this version was first recorded
+	SupersededAt    time.Time              `json:"supersededAt,omitempty"` // When a later version replaced it
 }
 
 // FilingParseResult represents the result of parsing a filing with enhanced parser
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -128,3 +129,170 @@ func abs(x float64) float64 {
 	}
 	return x
 }
+
+func TestCompanyFinancialDataAsOfKnowledge(t *testing.T) {
+	jan := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)
+	amendedAt := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	previous := &CompanyFinancialData{
+		Symbol: "MSTR",
+		BTCTransactions: []BitcoinTransaction{
+			{Date: jan, BTCPurchased: 1000},
+			{Date: feb, BTCPurchased: 500},
+		},
+	}
+
+	// The February purchase is later amended
+	revised := &CompanyFinancialData{
+		Symbol: "MSTR",
+		BTCTransactions: []BitcoinTransaction{
+			{Date: jan, BTCPurchased: 1000},
+			{Date: feb, BTCPurchased: 550},
+		},
+	}
+	revised.Revise(previous, amendedAt)
+
+	if len(revised.SupersededTransactions) != 1 || revised.SupersededTransactions[0].BTCPurchased != 500 {
+		t.Fatalf("Expected the original February purchase to be superseded, got %+v", revised.SupersededTransactions)
+	}
+
+	tests := []struct {
+		asOf  string
+		count int
+		total float64
+	}{
+		{"2025-01-31", 1, 1000},
+		{"2025-03-01", 2, 1500},
+		{"2025-03-05", 2, 1550},
+	}
+
+	for _, tt := range tests {
+		asOf, err := ParseKnowledgeDate(tt.asOf)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.asOf, err)
+		}
+
+		view := revised.AsOfKnowledge(asOf)
+		var total float64
+		for _, tx := range view.BTCTransactions {
+			total += tx.BTCPurchased
+		}
+		if len(view.BTCTransactions) != tt.count || total != tt.total {
+			t.Errorf("As of %s: expected %d transactions totalling %.0f, got %d totalling %.0f",
+				tt.asOf, tt.count, tt.total, len(view.BTCTransactions), total)
+		}
+	}
+
+	// Current view excludes superseded versions
+	if current := revised.AsOfKnowledge(time.Time{}); len(current.BTCTransactions) != 2 {
+		t.Errorf("Expected 2 current transactions, got %d", len(current.BTCTransactions))
+	}
+}
+
+func TestReviseStampsFirstSave(t *testing.T) {
+	jan := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	savedAt := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	// A backfill saved in April wasn't known in February, even though it dates from January
+	data := &CompanyFinancialData{
+		Symbol:          "MSTR",
+		BTCTransactions: []BitcoinTransaction{{Date: jan, BTCPurchased: 1000}},
+		SharesHistory:   []SharesOutstandingRecord{{Date: jan, TotalShares: 100000000}},
+	}
+	data.Revise(nil, savedAt)
+
+	if !data.BTCTransactions[0].KnownAt.Equal(savedAt) || !data.SharesHistory[0].KnownAt.Equal(savedAt) {
+		t.Fatalf("Expected first-save facts known at %v, got %+v and %+v", savedAt, data.BTCTransactions[0], data.SharesHistory[0])
+	}
+
+	feb, _ := ParseKnowledgeDate("2025-02-01")
+	if view := data.AsOfKnowledge(feb); len(view.BTCTransactions) != 0 || len(view.SharesHistory) != 0 {
+		t.Errorf("Expected nothing known on 2025-02-01, got %d transactions and %d shares records",
+			len(view.BTCTransactions), len(view.SharesHistory))
+	}
+}
+
+func TestFieldLineage(t *testing.T) {
+	observed := FieldLineage{Source: "coinmarketcap", File: "btc.json", Method: LineageObserved}
+	filled := observed.FilledFromDate(LineageForwardFilled, "2024-03-08")
+
+	if observed.Filled || observed.Method != LineageObserved {
+		t.Errorf("FilledFromDate modified the original lineage: %+v", observed)
+	}
+	if !filled.Filled || filled.FilledFrom != "2024-03-08" || filled.Source != "coinmarketcap" {
+		t.Errorf("Unexpected filled lineage: %+v", filled)
+	}
+	if got, want := filled.String(), "coinmarketcap (btc.json) forward_filled from 2024-03-08"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if DerivedLineage(observed, observed).Filled {
+		t.Errorf("Derived lineage from observed inputs should not be filled")
+	}
+	derived := DerivedLineage(observed, filled)
+	if !derived.Filled || derived.Method != LineageDerived {
+		t.Errorf("Derived lineage from a filled input should be filled: %+v", derived)
+	}
+
+	// Lineage round-trips through a manifest
+	manifest := LineageManifest{Symbol: "MSTR", Rows: map[string]RowLineage{
+		"2024-03-09": {FieldBitcoinPrice: filled, FieldMNAV: derived},
+	}}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Failed to marshal manifest: %v", err)
+	}
+	var decoded LineageManifest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal manifest: %v", err)
+	}
+	if decoded.Rows["2024-03-09"][FieldBitcoinPrice] != filled {
+		t.Errorf("Lineage did not round-trip: %+v", decoded.Rows["2024-03-09"][FieldBitcoinPrice])
+	}
+}
+
+func TestCompanyEvents(t *testing.T) {
+	var file CompanyEvents
+	raw := `{"symbol": "MSTR", "events": [
+		{"date": "2024-08-08", "type": "stock_split", "title": "10-for-1 split", "ratio": 10},
+		{"date": "2024-09-13", "endDate": "2024-10-01", "type": "equity_offering", "title": "ATM"},
+		{"date": "2024-09-20", "type": "btc_purchase", "title": "User note", "btc": 7420}
+	]}`
+	if err := json.Unmarshal([]byte(raw), &file); err != nil {
+		t.Fatalf("Failed to parse events: %v", err)
+	}
+	if !file.Events[1].IsRange() || file.Events[0].IsRange() {
+		t.Error("Expected only the offering to be a range")
+	}
+
+	var bad CompanyEvent
+	if err := json.Unmarshal([]byte(`{"date": "2024-01-01", "type": "rumour", "title": "x"}`), &bad); err == nil {
+		t.Error("Expected an error for an unknown event type")
+	}
+
+	out, err := json.Marshal(file.Events[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	if !strings.Contains(string(out), `"date":"2024-08-08"`) {
+		t.Errorf("Expected a date-only field, got %s", out)
+	}
+
+	txs := []BitcoinTransaction{
+		{Date: time.Date(2024, 9, 20, 0, 0, 0, 0, time.UTC), BTCPurchased: 7420, AvgPriceUSD: 61750},
+		{Date: time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), BTCPurchased: 0},
+	}
+	merged := MergeEvents(EventsFromTransactions(txs), file.Events)
+	if len(merged) != 3 {
+		t.Fatalf("Expected the user purchase to replace the derived one, got %d events", len(merged))
+	}
+	if merged[2].Title != "User note" || !merged[0].Date.Before(merged[1].Date) {
+		t.Errorf("Expected merged events sorted by date with user edits winning, got %+v", merged)
+	}
+
+	within := EventsBetween(merged, time.Date(2024, 9, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	if len(within) != 2 {
+		t.Errorf("Expected the overlapping range and the purchase, got %d events", len(within))
+	}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// CompanyEvents returns a company's event timeline: Bitcoin purchases from the
+// transaction store merged with the user-editable events file, which takes precedence.
+// Either source may be nil.
+func CompanyEvents(txs TransactionRepository, events *storage.EventStorage, symbol string) ([]models.CompanyEvent, error) {
+	var derived, edited []models.CompanyEvent
+
+	if txs != nil {
+		transactions, err := txs.LoadBTCTransactions(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load transactions for %s: %w", symbol, err)
+		}
+		derived = models.EventsFromTransactions(transactions)
+	}
+
+	if events != nil {
+		file, err := events.LoadEvents(symbol)
+		if err != nil {
+			return nil, err
+		}
+		edited = file.Events
+	}
+
+	return models.MergeEvents(derived, edited), nil
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/config"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// ImportReport summarises what a legacy import copied into the destination store
+type ImportReport struct {
+	Symbols      []string       `json:"symbols"`
+	Prices       map[string]int `json:"prices"`
+	Filings      map[string]int `json:"filings"`
+	Transactions map[string]int `json:"transactions"`
+	Shares       map[string]int `json:"shares"`
+	Portfolios   int            `json:"portfolios"`
+	Warnings     []string       `json:"warnings,omitempty"`
+}
+
+// ImportLegacyData copies everything found in the JSON/CSV file layout under basePath
+// into dst. When symbols is empty, companies are discovered from companies.json and
+// the EDGAR company directories.
+func ImportLegacyData(basePath string, dst Store, symbols []string) (*ImportReport, error) {
+	src := NewJSONStore(basePath)
+
+	if len(symbols) == 0 {
+		symbols = discoverSymbols(basePath)
+	}
+
+	report := &ImportReport{
+		Symbols:      symbols,
+		Prices:       make(map[string]int),
+		Filings:      make(map[string]int),
+		Transactions: make(map[string]int),
+		Shares:       make(map[string]int),
+	}
+
+	// Prices: every company plus Bitcoin and the registry's other treasury assets
+	for _, symbol := range append(assetPriceSymbols(basePath), symbols...) {
+		prices, err := src.GetPrices(symbol, time.Time{}, time.Time{})
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("prices %s: %v", symbol, err))
+			continue
+		}
+		if len(prices) == 0 {
+			continue
+		}
+		if err := dst.Prices().SavePrices(symbol, prices); err != nil {
+			return report, fmt.Errorf("failed to import prices for %s: %w", symbol, err)
+		}
+		report.Prices[symbol] = len(prices)
+	}
+
+	for _, symbol := range symbols {
+		// Filings
+		filings, err := src.ListFilings(symbol)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("filings %s: %v", symbol, err))
+		}
+		for _, filing := range filings {
+			if err := dst.Filings().SaveFiling(symbol, filing); err != nil {
+				return report, fmt.Errorf("failed to import filing %s: %w", filing.AccessionNumber, err)
+			}
+		}
+		report.Filings[symbol] = len(filings)
+
+		// Transactions
+		txs, err := loadLegacyTransactions(basePath, src, symbol)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("transactions %s: %v", symbol, err))
+		}
+		if len(txs) > 0 {
+			if err := dst.Transactions().SaveBTCTransactions(symbol, txs); err != nil {
+				return report, fmt.Errorf("failed to import transactions for %s: %w", symbol, err)
+			}
+		}
+		report.Transactions[symbol] = len(txs)
+
+		// Shares
+		shares, err := loadLegacyShares(basePath, src, symbol)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("shares %s: %v", symbol, err))
+		}
+		if len(shares) > 0 {
+			if err := dst.Shares().SaveSharesHistory(symbol, shares); err != nil {
+				return report, fmt.Errorf("failed to import shares for %s: %w", symbol, err)
+			}
+		}
+		report.Shares[symbol] = len(shares)
+	}
+
+	// Portfolios. Sealed snapshots are never copied into a database that would store them in
+	// the clear.
+	if sealed, _ := src.tracker.Files().Encrypted(); sealed {
+		if db, ok := dst.(*SQLiteStore); ok {
+			if dbSealed, err := db.PortfolioEncrypted(); err != nil || !dbSealed {
+				return report, fmt.Errorf("portfolio snapshots are encrypted but the database's portfolio directory has no keyring to seal them")
+			}
+		}
+	}
+	dates, err := src.ListPortfolioDates()
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("portfolios: %v", err))
+	}
+	for _, date := range dates {
+		p, err := src.LoadPortfolio(date)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("portfolio %s: %v", date.Format("2006-01-02"), err))
+			continue
+		}
+		if err := dst.Portfolios().SavePortfolio(p); err != nil {
+			return report, fmt.Errorf("failed to import portfolio %s: %w", date.Format("2006-01-02"), err)
+		}
+		report.Portfolios++
+	}
+
+	return report, nil
+}
+
+// assetPriceSymbols lists Bitcoin and the unit price series of every asset in companies.json
+func assetPriceSymbols(basePath string) []string {
+	symbols := []string{BitcoinSymbol}
+	if companies, err := config.LoadCompaniesConfig(basePath); err == nil {
+		for _, symbol := range companies.AssetPriceSymbols() {
+			if symbol != BitcoinSymbol {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	return symbols
+}
+
+// discoverSymbols lists companies from companies.json and the EDGAR company directories
+func discoverSymbols(basePath string) []string {
+	seen := make(map[string]bool)
+
+	if companies, err := config.LoadCompaniesConfig(basePath); err == nil {
+		for _, company := range companies.Companies {
+			seen[company.Symbol] = true
+		}
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join(basePath, "data", "edgar", "companies", "*"))
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			seen[filepath.Base(dir)] = true
+		}
+	}
+
+	symbols := make([]string, 0, len(seen))
+	for symbol := range seen {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// loadLegacyTransactions prefers the EDGAR company store, then the transaction
+// storage files, then the comprehensive analysis output
+func loadLegacyTransactions(basePath string, src *JSONStore, symbol string) ([]models.BitcoinTransaction, error) {
+	txs, err := src.LoadBTCTransactions(symbol)
+	if err == nil && len(txs) > 0 {
+		return txs, nil
+	}
+
+	txs, err = storage.NewTransactionStorage(basePath).LoadBTCTransactions(symbol)
+	if err == nil && len(txs) > 0 {
+		return txs, nil
+	}
+
+	analysisFile := filepath.Join(basePath, "data", "analysis", fmt.Sprintf("%s_comprehensive_bitcoin_analysis.json", symbol))
+	data, err := os.ReadFile(analysisFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var analysis models.ComprehensiveBitcoinAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", analysisFile, err)
+	}
+
+	return analysis.AllTransactions, nil
+}
+
+// loadLegacyShares merges the EDGAR shares history with the shares outstanding
+// analysis files and the companies.json snapshot, keeping one record per date
+func loadLegacyShares(basePath string, src *JSONStore, symbol string) ([]models.SharesOutstandingRecord, error) {
+	records, err := src.LoadSharesHistory(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string]models.SharesOutstandingRecord)
+	for _, r := range records {
+		byDate[r.Date.Format("2006-01-02")] = r
+	}
+
+	addIfMissing := func(date time.Time, shares float64, source string) {
+		key := date.Format("2006-01-02")
+		if _, exists := byDate[key]; exists || shares <= 0 {
+			return
+		}
+		byDate[key] = models.SharesOutstandingRecord{
+			Date:            date,
+			CommonShares:    shares,
+			TotalShares:     shares,
+			ExtractedFrom:   source,
+			ConfidenceScore: 0.8,
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(basePath, "data", "analysis", symbol+"_shares_outstanding_*.json"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var sharesData struct {
+			HistoricalData []struct {
+				Date              string  `json:"date"`
+				SharesOutstanding float64 `json:"shares_outstanding"`
+			} `json:"historical_data"`
+		}
+		if err := json.Unmarshal(data, &sharesData); err != nil {
+			continue
+		}
+		for _, point := range sharesData.HistoricalData {
+			if date, err := time.Parse("2006-01-02", point.Date); err == nil {
+				addIfMissing(date, point.SharesOutstanding, filepath.Base(file))
+			}
+		}
+	}
+
+	if companies, err := config.LoadCompaniesConfig(basePath); err == nil {
+		if company, ok := companies.GetCompanyBySymbol(symbol); ok && !company.LastUpdated.IsZero() {
+			date := time.Date(company.LastUpdated.Year(), company.LastUpdated.Month(), company.LastUpdated.Day(), 0, 0, 0, 0, time.UTC)
+			addIfMissing(date, company.OutstandingShares, "companies.json")
+		}
+	}
+
+	merged := make([]models.SharesOutstandingRecord, 0, len(byDate))
+	for _, r := range byDate {
+		merged = append(merged, r)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Date.Before(merged[j].Date) })
+
+	return merged, nil
+}
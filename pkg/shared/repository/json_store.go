@@ -0,0 +1,456 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	portfolio "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/tracker"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/storage"
+)
+
+// JSONStore implements Store on top of the existing JSON file layout under data/
+type JSONStore struct {
+	basePath  string
+	companies *storage.CompanyDataStorage
+	tracker   *tracker.Tracker
+}
+
+// NewJSONStore creates a JSON-file backed store rooted at the project directory
+func NewJSONStore(basePath string) *JSONStore {
+	return &JSONStore{
+		basePath:  basePath,
+		companies: storage.NewCompanyDataStorage(filepath.Join(basePath, "data", "edgar", "companies")),
+		tracker:   tracker.NewTracker(filepath.Join(basePath, "data", "portfolio", "processed")),
+	}
+}
+
+// Prices returns the price repository
+func (s *JSONStore) Prices() PriceRepository { return s }
+
+// Filings returns the filing repository
+func (s *JSONStore) Filings() FilingRepository { return s }
+
+// Transactions returns the transaction repository
+func (s *JSONStore) Transactions() TransactionRepository { return s }
+
+// Shares returns the shares repository
+func (s *JSONStore) Shares() SharesRepository { return s }
+
+// Portfolios returns the portfolio repository
+func (s *JSONStore) Portfolios() PortfolioRepository { return s }
+
+// Close is a no-op for the JSON backend
+func (s *JSONStore) Close() error { return nil }
+
+// jsonPriceBar is the per-day price layout shared by the stock and Bitcoin files
+type jsonPriceBar struct {
+	Date   string  `json:"date"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+}
+
+// SavePrices writes a new price file in the same layout the collectors produce
+func (s *JSONStore) SavePrices(symbol string, points []PricePoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	sorted := append([]PricePoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	bars := make([]jsonPriceBar, 0, len(sorted))
+	for _, p := range sorted {
+		bars = append(bars, jsonPriceBar{
+			Date:   p.Date.Format("2006-01-02"),
+			Open:   p.Open,
+			High:   p.High,
+			Low:    p.Low,
+			Close:  p.Close,
+			Volume: p.Volume,
+		})
+	}
+
+	source := sorted[len(sorted)-1].Source
+	if source == "" {
+		source = "repository"
+	}
+
+	var dir, filename string
+	if symbol == BitcoinSymbol {
+		dir = filepath.Join(s.basePath, "data", "bitcoin-prices", "historical")
+		filename = fmt.Sprintf("bitcoin_historical_%s_to_%s.json", bars[0].Date, bars[len(bars)-1].Date)
+	} else {
+		dir = filepath.Join(s.basePath, "data", "stock-data")
+		filename = fmt.Sprintf("%s_stock_data_%s.json", symbol, time.Now().Format("2006-01-02_15-04-05"))
+	}
+	path := filepath.Join(dir, filename)
+
+	// A save landing on an existing file name (the same second, or the same Bitcoin range)
+	// keeps that file's other days
+	if existing, _, err := readPriceFile(path); err == nil {
+		bars = mergePriceBars(existing, bars)
+	}
+
+	var doc interface{}
+	if symbol == BitcoinSymbol {
+		doc = map[string]interface{}{
+			"symbol":     "BTC",
+			"start_date": bars[0].Date,
+			"end_date":   bars[len(bars)-1].Date,
+			"data":       bars,
+			"source":     source,
+			"fetched_at": time.Now(),
+		}
+	} else {
+		doc = map[string]interface{}{
+			"symbol":       symbol,
+			"collected_at": time.Now(),
+			"historical_prices": map[string]interface{}{
+				"symbol":     symbol,
+				"historical": bars,
+			},
+			"sources": map[string]string{"historical_prices": source},
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating price directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling prices: %w", err)
+	}
+
+	if err := storage.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing prices: %w", err)
+	}
+
+	return nil
+}
+
+// mergePriceBars combines two date-ordered bar lists, next winning on the same date
+func mergePriceBars(existing, next []jsonPriceBar) []jsonPriceBar {
+	byDate := make(map[string]jsonPriceBar, len(existing)+len(next))
+	for _, bar := range existing {
+		byDate[bar.Date] = bar
+	}
+	for _, bar := range next {
+		byDate[bar.Date] = bar
+	}
+
+	merged := make([]jsonPriceBar, 0, len(byDate))
+	for _, bar := range byDate {
+		merged = append(merged, bar)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Date < merged[j].Date })
+	return merged
+}
+
+// GetPrices merges every price file for the symbol, later files winning on the same date
+func (s *JSONStore) GetPrices(symbol string, start, end time.Time) ([]PricePoint, error) {
+	files, err := s.priceFiles(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string]PricePoint)
+	for _, file := range files {
+		bars, source, err := readPriceFile(file)
+		if err != nil {
+			continue // Skip files in formats we don't understand
+		}
+		for _, bar := range bars {
+			date, err := time.Parse("2006-01-02", bar.Date)
+			if err != nil || bar.Close <= 0 {
+				continue
+			}
+			byDate[bar.Date] = PricePoint{
+				Symbol: symbol,
+				Date:   date,
+				Open:   bar.Open,
+				High:   bar.High,
+				Low:    bar.Low,
+				Close:  bar.Close,
+				Volume: bar.Volume,
+				Source: source,
+			}
+		}
+	}
+
+	return filterPrices(byDate, start, end), nil
+}
+
+// LatestPrice returns the most recent stored price for the symbol
+func (s *JSONStore) LatestPrice(symbol string) (*PricePoint, error) {
+	prices, err := s.GetPrices(symbol, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("no prices for %s: %w", symbol, ErrNotFound)
+	}
+	return &prices[len(prices)-1], nil
+}
+
+// priceFiles lists the files that may contain prices for the symbol, oldest first
+func (s *JSONStore) priceFiles(symbol string) ([]string, error) {
+	var patterns []string
+	if symbol == BitcoinSymbol {
+		patterns = []string{
+			filepath.Join(s.basePath, "data", "bitcoin-prices", "historical", "bitcoin_*.json"),
+		}
+	} else if IsAssetPriceSeries(symbol) {
+		patterns = []string{
+			filepath.Join(s.basePath, "data", "asset-prices", "historical", symbol+"_*.json"),
+		}
+	} else {
+		patterns = []string{
+			filepath.Join(s.basePath, "data", "stock-data", "historical", symbol+"_*.json"),
+			filepath.Join(s.basePath, "data", "stock-data", symbol+"_stock_data_*.json"),
+		}
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error listing price files: %w", err)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	return files, nil
+}
+
+// readPriceFile understands the Bitcoin history layout and both stock data layouts
+func readPriceFile(filename string) ([]jsonPriceBar, string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var doc struct {
+		Data             []jsonPriceBar `json:"data"`
+		Source           string         `json:"source"`
+		Historical       []jsonPriceBar `json:"historical"`
+		HistoricalPrices *struct {
+			Historical []jsonPriceBar `json:"historical"`
+		} `json:"historical_prices"`
+		Sources map[string]string `json:"sources"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case doc.HistoricalPrices != nil:
+		return doc.HistoricalPrices.Historical, doc.Sources["historical_prices"], nil
+	case len(doc.Historical) > 0:
+		return doc.Historical, "Financial Modeling Prep", nil
+	case len(doc.Data) > 0:
+		return doc.Data, doc.Source, nil
+	}
+
+	return nil, "", fmt.Errorf("no price data in %s", filename)
+}
+
+// filterPrices returns the map values within [start, end] sorted by date; zero bounds are open
+func filterPrices(byDate map[string]PricePoint, start, end time.Time) []PricePoint {
+	prices := make([]PricePoint, 0, len(byDate))
+	for _, p := range byDate {
+		if !start.IsZero() && p.Date.Before(start) {
+			continue
+		}
+		if !end.IsZero() && p.Date.After(end) {
+			continue
+		}
+		prices = append(prices, p)
+	}
+
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Date.Before(prices[j].Date) })
+	return prices
+}
+
+// rawFilingsDir returns the raw filing directory for a company
+func (s *JSONStore) rawFilingsDir(symbol string) string {
+	return filepath.Join(s.basePath, "data", "edgar", "companies", symbol, "raw_filings")
+}
+
+// SaveFiling writes filing metadata next to the raw filing content
+func (s *JSONStore) SaveFiling(symbol string, doc models.RawFilingDocument) error {
+	dir := s.rawFilingsDir(symbol)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating raw filings directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("%s_%s_%s.json",
+		doc.FilingDate.Format("2006-01-02"),
+		strings.ReplaceAll(doc.FilingType, "/", "-"),
+		doc.AccessionNumber)
+
+	doc.SchemaVersion = schema.CurrentVersion(schema.RawFiling)
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling raw filing metadata: %w", err)
+	}
+
+	// Hold the company lock that migrate and the company data writers take
+	err = storage.WithLock(filepath.Join(filepath.Dir(dir), ".lock"), func() error {
+		return storage.WriteFileAtomic(filepath.Join(dir, filename), data, 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("error writing raw filing metadata: %w", err)
+	}
+
+	return nil
+}
+
+// ListFilings returns all filing metadata for a company sorted by filing date
+func (s *JSONStore) ListFilings(symbol string) ([]models.RawFilingDocument, error) {
+	files, err := filepath.Glob(filepath.Join(s.rawFilingsDir(symbol), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing raw filings: %w", err)
+	}
+
+	filings := []models.RawFilingDocument{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue // Skip files we can't read
+		}
+		if data, err = schema.Upgrade(schema.RawFiling, data); err != nil {
+			continue // Skip files we can't upgrade
+		}
+
+		var doc models.RawFilingDocument
+		if err := json.Unmarshal(data, &doc); err != nil || doc.AccessionNumber == "" {
+			continue // Skip files that aren't filing metadata
+		}
+		filings = append(filings, doc)
+	}
+
+	sort.Slice(filings, func(i, j int) bool {
+		return filings[i].FilingDate.Before(filings[j].FilingDate)
+	})
+
+	return filings, nil
+}
+
+// loadCompany loads a company's financial data, returning an empty record if none exists
+func (s *JSONStore) loadCompany(symbol string) (*models.CompanyFinancialData, error) {
+	path := filepath.Join(s.basePath, "data", "edgar", "companies", symbol, "financial_data.json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &models.CompanyFinancialData{Symbol: symbol, CompanyName: symbol}, nil
+	}
+	return s.companies.LoadCompanyData(symbol)
+}
+
+// SaveBTCTransactions replaces the company's Bitcoin transactions
+func (s *JSONStore) SaveBTCTransactions(symbol string, transactions []models.BitcoinTransaction) error {
+	return s.companies.UpdateCompanyData(symbol, func(data *models.CompanyFinancialData) error {
+		if data.CompanyName == "" {
+			data.CompanyName = symbol
+		}
+		data.BTCTransactions = append([]models.BitcoinTransaction(nil), transactions...)
+		sort.Slice(data.BTCTransactions, func(i, j int) bool {
+			return data.BTCTransactions[i].Date.Before(data.BTCTransactions[j].Date)
+		})
+		data.LastUpdated = time.Now()
+		return nil
+	})
+}
+
+// LoadBTCTransactions returns the company's Bitcoin transactions sorted by date
+func (s *JSONStore) LoadBTCTransactions(symbol string) ([]models.BitcoinTransaction, error) {
+	data, err := s.loadCompany(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := append([]models.BitcoinTransaction{}, data.BTCTransactions...)
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Date.Before(txs[j].Date) })
+	return txs, nil
+}
+
+// LoadBTCTransactionsAsOf returns the Bitcoin transactions as known at knownAt
+func (s *JSONStore) LoadBTCTransactionsAsOf(symbol string, knownAt time.Time) ([]models.BitcoinTransaction, error) {
+	data, err := s.loadCompany(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return data.AsOfKnowledge(knownAt).BTCTransactions, nil
+}
+
+// SaveSharesHistory replaces the company's shares outstanding history
+func (s *JSONStore) SaveSharesHistory(symbol string, records []models.SharesOutstandingRecord) error {
+	return s.companies.UpdateCompanyData(symbol, func(data *models.CompanyFinancialData) error {
+		if data.CompanyName == "" {
+			data.CompanyName = symbol
+		}
+		data.SharesHistory = append([]models.SharesOutstandingRecord(nil), records...)
+		sort.Slice(data.SharesHistory, func(i, j int) bool {
+			return data.SharesHistory[i].Date.Before(data.SharesHistory[j].Date)
+		})
+		data.LastUpdated = time.Now()
+		return nil
+	})
+}
+
+// LoadSharesHistory returns the company's shares outstanding history sorted by date
+func (s *JSONStore) LoadSharesHistory(symbol string) ([]models.SharesOutstandingRecord, error) {
+	data, err := s.loadCompany(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	records := append([]models.SharesOutstandingRecord{}, data.SharesHistory...)
+	sort.Slice(records, func(i, j int) bool { return records[i].Date.Before(records[j].Date) })
+	return records, nil
+}
+
+// LoadSharesHistoryAsOf returns the shares outstanding history as known at knownAt
+func (s *JSONStore) LoadSharesHistoryAsOf(symbol string, knownAt time.Time) ([]models.SharesOutstandingRecord, error) {
+	data, err := s.loadCompany(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return data.AsOfKnowledge(knownAt).SharesHistory, nil
+}
+
+// SavePortfolio stores a processed portfolio snapshot
+func (s *JSONStore) SavePortfolio(p *portfolio.Portfolio) error {
+	return s.tracker.Store(p)
+}
+
+// LoadPortfolio loads the portfolio snapshot for a date
+func (s *JSONStore) LoadPortfolio(date time.Time) (*portfolio.Portfolio, error) {
+	p, err := s.tracker.Load(date)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no portfolio for %s: %w", date.Format("2006-01-02"), ErrNotFound)
+	}
+	return p, err
+}
+
+// ListPortfolioDates returns the dates of all stored portfolio snapshots
+func (s *JSONStore) ListPortfolioDates() ([]time.Time, error) {
+	dates, err := s.tracker.ListAll()
+	if errors.Is(err, fs.ErrNotExist) {
+		return []time.Time{}, nil
+	}
+	return dates, err
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	portfolio "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+)
+
+// BitcoinSymbol is the symbol under which Bitcoin prices are stored in the price repository
+const BitcoinSymbol = "BTC-USD"
+
+// IsAssetPriceSeries reports whether a price symbol is a treasury asset's USD unit
+// price series (BTC-USD, ETH-USD, ...) rather than a stock
+func IsAssetPriceSeries(symbol string) bool {
+	return strings.HasSuffix(symbol, "-USD")
+}
+
+// ErrNotFound is returned when a requested record does not exist in the store
+var ErrNotFound = errors.New("record not found")
+
+// PricePoint represents a single daily price bar for a symbol
+type PricePoint struct {
+	Symbol string    `json:"symbol"`
+	Date   time.Time `json:"date"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+	Source string    `json:"source,omitempty"`
+}
+
+// PriceRepository stores daily price histories for stocks, ETFs and treasury assets
+type PriceRepository interface {
+	SavePrices(symbol string, points []PricePoint) error
+	GetPrices(symbol string, start, end time.Time) ([]PricePoint, error)
+	LatestPrice(symbol string) (*PricePoint, error)
+}
+
+// FilingRepository stores metadata about downloaded SEC filings
+type FilingRepository interface {
+	SaveFiling(symbol string, doc models.RawFilingDocument) error
+	ListFilings(symbol string) ([]models.RawFilingDocument, error)
+}
+
+// TransactionRepository stores Bitcoin transactions per company. Saving keeps the
+// versions it replaces so earlier states can be read back with LoadBTCTransactionsAsOf.
+type TransactionRepository interface {
+	SaveBTCTransactions(symbol string, transactions []models.BitcoinTransaction) error
+	LoadBTCTransactions(symbol string) ([]models.BitcoinTransaction, error)
+	LoadBTCTransactionsAsOf(symbol string, knownAt time.Time) ([]models.BitcoinTransaction, error)
+}
+
+// SharesRepository stores shares outstanding history per company. Saving keeps the
+// versions it replaces so earlier states can be read back with LoadSharesHistoryAsOf.
+type SharesRepository interface {
+	SaveSharesHistory(symbol string, records []models.SharesOutstandingRecord) error
+	LoadSharesHistory(symbol string) ([]models.SharesOutstandingRecord, error)
+	LoadSharesHistoryAsOf(symbol string, knownAt time.Time) ([]models.SharesOutstandingRecord, error)
+}
+
+// PortfolioRepository stores processed portfolio snapshots
+type PortfolioRepository interface {
+	SavePortfolio(p *portfolio.Portfolio) error
+	LoadPortfolio(date time.Time) (*portfolio.Portfolio, error)
+	ListPortfolioDates() ([]time.Time, error)
+}
+
+// Store groups all repositories provided by a storage backend
+type Store interface {
+	Prices() PriceRepository
+	Filings() FilingRepository
+	Transactions() TransactionRepository
+	Shares() SharesRepository
+	Portfolios() PortfolioRepository
+	Close() error
+}
+
+// Backend names accepted by Open
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+// Open opens a store for the given backend. For the JSON backend path is the
+// project root containing the data directory; for SQLite it is the database file.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case BackendJSON, "":
+		return NewJSONStore(path), nil
+	case BackendSQLite:
+		return OpenSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// SharesAt returns the most recent shares record on or before the given date
+func SharesAt(repo SharesRepository, symbol string, date time.Time) (*models.SharesOutstandingRecord, error) {
+	return SharesAtAsOf(repo, symbol, date, time.Time{})
+}
+
+// SharesAtAsOf is SharesAt using only the records known at knownAt (zero means now)
+func SharesAtAsOf(repo SharesRepository, symbol string, date, knownAt time.Time) (*models.SharesOutstandingRecord, error) {
+	history, err := repo.LoadSharesHistoryAsOf(symbol, knownAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.SharesOutstandingRecord
+	for i := range history {
+		if history[i].Date.After(date) {
+			break
+		}
+		best = &history[i]
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no shares outstanding for %s on or before %s: %w",
+			symbol, date.Format("2006-01-02"), ErrNotFound)
+	}
+
+	return best, nil
+}
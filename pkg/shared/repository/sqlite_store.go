@@ -0,0 +1,637 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+
+	portfolio "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+)
+
+// sqliteSchema creates the tables used by the SQLite backend
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS prices (
+	symbol TEXT NOT NULL,
+	date   TEXT NOT NULL,
+	open   REAL,
+	high   REAL,
+	low    REAL,
+	close  REAL NOT NULL,
+	volume REAL,
+	source TEXT,
+	PRIMARY KEY (symbol, date)
+);
+
+CREATE TABLE IF NOT EXISTS filings (
+	symbol           TEXT NOT NULL,
+	accession_number TEXT NOT NULL,
+	filing_type      TEXT,
+	filing_date      TEXT,
+	document         TEXT NOT NULL,
+	PRIMARY KEY (symbol, accession_number)
+);
+
+CREATE TABLE IF NOT EXISTS btc_transactions (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	symbol          TEXT NOT NULL,
+	date            TEXT NOT NULL,
+	filing_type     TEXT,
+	filing_url      TEXT,
+	btc_purchased   REAL,
+	usd_spent       REAL,
+	avg_price_usd   REAL,
+	total_btc_after REAL,
+	extracted_text  TEXT,
+	confidence      REAL,
+	metadata        TEXT,
+	known_at        TEXT,
+	superseded_at   TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_btc_transactions_symbol_date ON btc_transactions (symbol, date);
+
+CREATE TABLE IF NOT EXISTS shares_history (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	symbol           TEXT NOT NULL,
+	date             TEXT NOT NULL,
+	filing_type      TEXT,
+	filing_url       TEXT,
+	accession_number TEXT,
+	common_shares    REAL,
+	preferred_shares REAL,
+	total_shares     REAL,
+	extracted_from   TEXT,
+	extracted_text   TEXT,
+	confidence       REAL,
+	notes            TEXT,
+	known_at         TEXT,
+	superseded_at    TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_shares_history_symbol_date ON shares_history (symbol, date);
+
+CREATE TABLE IF NOT EXISTS portfolios (
+	date        TEXT PRIMARY KEY,
+	source_file TEXT,
+	total_value REAL,
+	document    TEXT NOT NULL
+);
+`
+
+// SQLiteStore implements Store on an embedded SQLite database
+type SQLiteStore struct {
+	db    *sql.DB
+	files *vault.Files // Seals portfolio documents when the portfolio directory has a keyring
+}
+
+// OpenSQLiteStore opens (and if needed creates) the SQLite database at path. Portfolio
+// snapshots are sealed with the keyring of the portfolio directory beside the database
+// (data/portfolio for data/mnav.db), as the JSON store seals its files.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db, files: vault.NewFiles(filepath.Join(filepath.Dir(path), "portfolio"))}, nil
+}
+
+// PortfolioEncrypted reports whether portfolio snapshots are sealed
+func (s *SQLiteStore) PortfolioEncrypted() (bool, error) {
+	return s.files.Encrypted()
+}
+
+// sqliteMigrations upgrade databases created by older versions; entry i moves the
+// database from user_version i to i+1. Fresh databases get the full schema above
+// and run them as no-ops.
+var sqliteMigrations = []func(db *sql.DB) error{
+	// 0 → 1: bitemporal knowledge columns on company facts
+	func(db *sql.DB) error {
+		for _, table := range []string{"btc_transactions", "shares_history"} {
+			for _, column := range []string{"known_at", "superseded_at"} {
+				if err := ensureColumn(db, table, column, "TEXT"); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// migrateSQLite applies pending schema migrations and records the version in PRAGMA user_version
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, len(sqliteMigrations))
+	}
+
+	for v := version; v < len(sqliteMigrations); v++ {
+		if err := sqliteMigrations[v](db); err != nil {
+			return fmt.Errorf("schema migration %d→%d failed: %w", v, v+1, err)
+		}
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func ensureColumn(db *sql.DB, table, column, columnType string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, kind string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultVal, &pk); err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// Prices returns the price repository
+func (s *SQLiteStore) Prices() PriceRepository { return s }
+
+// Filings returns the filing repository
+func (s *SQLiteStore) Filings() FilingRepository { return s }
+
+// Transactions returns the transaction repository
+func (s *SQLiteStore) Transactions() TransactionRepository { return s }
+
+// Shares returns the shares repository
+func (s *SQLiteStore) Shares() SharesRepository { return s }
+
+// Portfolios returns the portfolio repository
+func (s *SQLiteStore) Portfolios() PortfolioRepository { return s }
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// withTx runs fn inside a transaction, committing on success
+func (s *SQLiteStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SavePrices upserts price bars for the symbol
+func (s *SQLiteStore) SavePrices(symbol string, points []PricePoint) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT INTO prices (symbol, date, open, high, low, close, volume, source)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (symbol, date) DO UPDATE SET
+				open = excluded.open, high = excluded.high, low = excluded.low,
+				close = excluded.close, volume = excluded.volume, source = excluded.source`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare price insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, p := range points {
+			if _, err := stmt.Exec(symbol, p.Date.Format("2006-01-02"),
+				p.Open, p.High, p.Low, p.Close, p.Volume, p.Source); err != nil {
+				return fmt.Errorf("failed to insert price for %s: %w", p.Date.Format("2006-01-02"), err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetPrices returns prices for the symbol within [start, end]; zero bounds are open
+func (s *SQLiteStore) GetPrices(symbol string, start, end time.Time) ([]PricePoint, error) {
+	from, to := "0000-01-01", "9999-12-31"
+	if !start.IsZero() {
+		from = start.Format("2006-01-02")
+	}
+	if !end.IsZero() {
+		to = end.Format("2006-01-02")
+	}
+
+	rows, err := s.db.Query(`SELECT date, open, high, low, close, volume, source FROM prices
+		WHERE symbol = ? AND date >= ? AND date <= ? ORDER BY date`, symbol, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []PricePoint{}
+	for rows.Next() {
+		var date string
+		var source sql.NullString
+		p := PricePoint{Symbol: symbol}
+		if err := rows.Scan(&date, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume, &source); err != nil {
+			return nil, fmt.Errorf("failed to scan price: %w", err)
+		}
+		p.Date, _ = time.Parse("2006-01-02", date)
+		p.Source = source.String
+		prices = append(prices, p)
+	}
+
+	return prices, rows.Err()
+}
+
+// LatestPrice returns the most recent stored price for the symbol
+func (s *SQLiteStore) LatestPrice(symbol string) (*PricePoint, error) {
+	var date string
+	var source sql.NullString
+	p := PricePoint{Symbol: symbol}
+
+	err := s.db.QueryRow(`SELECT date, open, high, low, close, volume, source FROM prices
+		WHERE symbol = ? ORDER BY date DESC LIMIT 1`, symbol).
+		Scan(&date, &p.Open, &p.High, &p.Low, &p.Close, &p.Volume, &source)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no prices for %s: %w", symbol, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest price: %w", err)
+	}
+
+	p.Date, _ = time.Parse("2006-01-02", date)
+	p.Source = source.String
+	return &p, nil
+}
+
+// SaveFiling upserts filing metadata
+func (s *SQLiteStore) SaveFiling(symbol string, doc models.RawFilingDocument) error {
+	doc.SchemaVersion = schema.CurrentVersion(schema.RawFiling)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal filing: %w", err)
+	}
+
+	_, err = s.db.Exec(`INSERT INTO filings (symbol, accession_number, filing_type, filing_date, document)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (symbol, accession_number) DO UPDATE SET
+			filing_type = excluded.filing_type, filing_date = excluded.filing_date, document = excluded.document`,
+		symbol, doc.AccessionNumber, doc.FilingType, doc.FilingDate.Format(time.RFC3339), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save filing: %w", err)
+	}
+	return nil
+}
+
+// ListFilings returns all filing metadata for a company sorted by filing date
+func (s *SQLiteStore) ListFilings(symbol string) ([]models.RawFilingDocument, error) {
+	rows, err := s.db.Query(`SELECT document FROM filings WHERE symbol = ? ORDER BY filing_date`, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query filings: %w", err)
+	}
+	defer rows.Close()
+
+	filings := []models.RawFilingDocument{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan filing: %w", err)
+		}
+		upgraded, err := schema.Upgrade(schema.RawFiling, []byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to upgrade filing: %w", err)
+		}
+		var doc models.RawFilingDocument
+		if err := json.Unmarshal(upgraded, &doc); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal filing: %w", err)
+		}
+		filings = append(filings, doc)
+	}
+
+	return filings, rows.Err()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// formatKnowledgeTime stores zero knowledge times as NULL
+func formatKnowledgeTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseKnowledgeTime reads a nullable knowledge time column
+func parseKnowledgeTime(value sql.NullString) time.Time {
+	if !value.Valid || value.String == "" {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, value.String)
+	return t
+}
+
+// SaveBTCTransactions replaces the company's current Bitcoin transactions, keeping
+// amended and removed versions as superseded rows
+func (s *SQLiteStore) SaveBTCTransactions(symbol string, transactions []models.BitcoinTransaction) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		existing, err := queryBTCTransactions(tx, symbol, false)
+		if err != nil {
+			return err
+		}
+
+		var previous, history []models.BitcoinTransaction
+		for _, t := range existing {
+			if t.SupersededAt.IsZero() {
+				previous = append(previous, t)
+			} else {
+				history = append(history, t)
+			}
+		}
+		current, superseded := models.ReviseTransactions(previous, transactions, time.Now())
+
+		if _, err := tx.Exec(`DELETE FROM btc_transactions WHERE symbol = ?`, symbol); err != nil {
+			return fmt.Errorf("failed to clear transactions: %w", err)
+		}
+
+		for _, t := range append(append(current, history...), superseded...) {
+			var metadata []byte
+			if len(t.Metadata) > 0 {
+				var err error
+				if metadata, err = json.Marshal(t.Metadata); err != nil {
+					return fmt.Errorf("failed to marshal transaction metadata: %w", err)
+				}
+			}
+
+			if _, err := tx.Exec(`INSERT INTO btc_transactions (symbol, date, filing_type, filing_url,
+				btc_purchased, usd_spent, avg_price_usd, total_btc_after, extracted_text, confidence, metadata,
+				known_at, superseded_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				symbol, t.Date.Format(time.RFC3339), t.FilingType, t.FilingURL,
+				t.BTCPurchased, t.USDSpent, t.AvgPriceUSD, t.TotalBTCAfter,
+				t.ExtractedText, t.ConfidenceScore, string(metadata),
+				formatKnowledgeTime(t.KnownAt), formatKnowledgeTime(t.SupersededAt)); err != nil {
+				return fmt.Errorf("failed to insert transaction: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// LoadBTCTransactions returns the company's current Bitcoin transactions sorted by date
+func (s *SQLiteStore) LoadBTCTransactions(symbol string) ([]models.BitcoinTransaction, error) {
+	return queryBTCTransactions(s.db, symbol, true)
+}
+
+// LoadBTCTransactionsAsOf returns the Bitcoin transactions as known at knownAt
+func (s *SQLiteStore) LoadBTCTransactionsAsOf(symbol string, knownAt time.Time) ([]models.BitcoinTransaction, error) {
+	txs, err := queryBTCTransactions(s.db, symbol, false)
+	if err != nil {
+		return nil, err
+	}
+	return models.TransactionsKnownAsOf(txs, knownAt), nil
+}
+
+// queryBTCTransactions loads transaction rows, optionally only the current versions
+func queryBTCTransactions(q queryer, symbol string, currentOnly bool) ([]models.BitcoinTransaction, error) {
+	query := `SELECT date, filing_type, filing_url, btc_purchased, usd_spent, avg_price_usd,
+		total_btc_after, extracted_text, confidence, metadata, known_at, superseded_at
+		FROM btc_transactions WHERE symbol = ?`
+	if currentOnly {
+		query += ` AND superseded_at IS NULL`
+	}
+
+	rows, err := q.Query(query+` ORDER BY date, id`, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	txs := []models.BitcoinTransaction{}
+	for rows.Next() {
+		var date string
+		var metadata, knownAt, supersededAt sql.NullString
+		var t models.BitcoinTransaction
+		if err := rows.Scan(&date, &t.FilingType, &t.FilingURL, &t.BTCPurchased, &t.USDSpent,
+			&t.AvgPriceUSD, &t.TotalBTCAfter, &t.ExtractedText, &t.ConfidenceScore, &metadata,
+			&knownAt, &supersededAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		t.Date, _ = time.Parse(time.RFC3339, date)
+		if metadata.String != "" {
+			json.Unmarshal([]byte(metadata.String), &t.Metadata)
+		}
+		t.KnownAt = parseKnowledgeTime(knownAt)
+		t.SupersededAt = parseKnowledgeTime(supersededAt)
+		txs = append(txs, t)
+	}
+
+	return txs, rows.Err()
+}
+
+// SaveSharesHistory replaces the company's current shares outstanding history, keeping
+// amended and removed versions as superseded rows
+func (s *SQLiteStore) SaveSharesHistory(symbol string, records []models.SharesOutstandingRecord) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		existing, err := querySharesHistory(tx, symbol, false)
+		if err != nil {
+			return err
+		}
+
+		var previous, history []models.SharesOutstandingRecord
+		for _, r := range existing {
+			if r.SupersededAt.IsZero() {
+				previous = append(previous, r)
+			} else {
+				history = append(history, r)
+			}
+		}
+		current, superseded := models.ReviseShares(previous, records, time.Now())
+
+		if _, err := tx.Exec(`DELETE FROM shares_history WHERE symbol = ?`, symbol); err != nil {
+			return fmt.Errorf("failed to clear shares history: %w", err)
+		}
+
+		for _, r := range append(append(current, history...), superseded...) {
+			if _, err := tx.Exec(`INSERT INTO shares_history (symbol, date, filing_type, filing_url,
+				accession_number, common_shares, preferred_shares, total_shares, extracted_from,
+				extracted_text, confidence, notes, known_at, superseded_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				symbol, r.Date.Format(time.RFC3339), r.FilingType, r.FilingURL, r.AccessionNumber,
+				r.CommonShares, r.PreferredShares, r.TotalShares, r.ExtractedFrom,
+				r.ExtractedText, r.ConfidenceScore, r.Notes,
+				formatKnowledgeTime(r.KnownAt), formatKnowledgeTime(r.SupersededAt)); err != nil {
+				return fmt.Errorf("failed to insert shares record: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// LoadSharesHistory returns the company's current shares outstanding history sorted by date
+func (s *SQLiteStore) LoadSharesHistory(symbol string) ([]models.SharesOutstandingRecord, error) {
+	return querySharesHistory(s.db, symbol, true)
+}
+
+// LoadSharesHistoryAsOf returns the shares outstanding history as known at knownAt
+func (s *SQLiteStore) LoadSharesHistoryAsOf(symbol string, knownAt time.Time) ([]models.SharesOutstandingRecord, error) {
+	records, err := querySharesHistory(s.db, symbol, false)
+	if err != nil {
+		return nil, err
+	}
+	return models.SharesKnownAsOf(records, knownAt), nil
+}
+
+// querySharesHistory loads shares rows, optionally only the current versions
+func querySharesHistory(q queryer, symbol string, currentOnly bool) ([]models.SharesOutstandingRecord, error) {
+	query := `SELECT date, filing_type, filing_url, accession_number, common_shares,
+		preferred_shares, total_shares, extracted_from, extracted_text, confidence, notes,
+		known_at, superseded_at
+		FROM shares_history WHERE symbol = ?`
+	if currentOnly {
+		query += ` AND superseded_at IS NULL`
+	}
+
+	rows, err := q.Query(query+` ORDER BY date, id`, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shares history: %w", err)
+	}
+	defer rows.Close()
+
+	records := []models.SharesOutstandingRecord{}
+	for rows.Next() {
+		var date string
+		var knownAt, supersededAt sql.NullString
+		var r models.SharesOutstandingRecord
+		if err := rows.Scan(&date, &r.FilingType, &r.FilingURL, &r.AccessionNumber, &r.CommonShares,
+			&r.PreferredShares, &r.TotalShares, &r.ExtractedFrom, &r.ExtractedText,
+			&r.ConfidenceScore, &r.Notes, &knownAt, &supersededAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shares record: %w", err)
+		}
+		r.Date, _ = time.Parse(time.RFC3339, date)
+		r.KnownAt = parseKnowledgeTime(knownAt)
+		r.SupersededAt = parseKnowledgeTime(supersededAt)
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// SavePortfolio upserts a processed portfolio snapshot, sealing the document when the
+// portfolio directory has a keyring
+func (s *SQLiteStore) SavePortfolio(p *portfolio.Portfolio) error {
+	p.SchemaVersion = schema.CurrentVersion(schema.Portfolio)
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal portfolio: %w", err)
+	}
+	if data, err = s.files.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt portfolio: %w", err)
+	}
+
+	_, err = s.db.Exec(`INSERT INTO portfolios (date, source_file, total_value, document) VALUES (?, ?, ?, ?)
+		ON CONFLICT (date) DO UPDATE SET
+			source_file = excluded.source_file, total_value = excluded.total_value, document = excluded.document`,
+		p.Date.Format("2006-01-02"), p.SourceFile, p.TotalValue, data)
+	if err != nil {
+		return fmt.Errorf("failed to save portfolio: %w", err)
+	}
+	return nil
+}
+
+// LoadPortfolio loads the portfolio snapshot for a date
+func (s *SQLiteStore) LoadPortfolio(date time.Time) (*portfolio.Portfolio, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT document FROM portfolios WHERE date = ?`, date.Format("2006-01-02")).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no portfolio for %s: %w", date.Format("2006-01-02"), ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query portfolio: %w", err)
+	}
+
+	if data, err = s.files.Open(data); err != nil {
+		return nil, fmt.Errorf("failed to decrypt portfolio: %w", err)
+	}
+
+	upgraded, err := schema.Upgrade(schema.Portfolio, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade portfolio: %w", err)
+	}
+
+	var p portfolio.Portfolio
+	if err := json.Unmarshal(upgraded, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal portfolio: %w", err)
+	}
+	return &p, nil
+}
+
+// ListPortfolioDates returns the dates of all stored portfolio snapshots
+func (s *SQLiteStore) ListPortfolioDates() ([]time.Time, error) {
+	rows, err := s.db.Query(`SELECT date FROM portfolios ORDER BY date`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query portfolio dates: %w", err)
+	}
+	defer rows.Close()
+
+	dates := []time.Time{}
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio date: %w", err)
+		}
+		if d, err := time.Parse("2006-01-02", date); err == nil {
+			dates = append(dates, d)
+		}
+	}
+
+	return dates, rows.Err()
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	portfolio "github.com/ultrarare-tech/mNAV/pkg/portfolio/models"
+	"github.com/ultrarare-tech/mNAV/pkg/portfolio/vault"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+)
+
+// TestStoreContract runs the same round trip against every storage backend
+func TestStoreContract(t *testing.T) {
+	backends := map[string]func(t *testing.T) Store{
+		BackendJSON: func(t *testing.T) Store {
+			return NewJSONStore(t.TempDir())
+		},
+		BackendSQLite: func(t *testing.T) Store {
+			store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "mnav.db"))
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			defer store.Close()
+			testPrices(t, store.Prices())
+			testShares(t, store.Shares())
+			testTransactions(t, store.Transactions())
+		})
+	}
+}
+
+func testPrices(t *testing.T, repo PriceRepository) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	// Prices upsert by date
+	prices := []PricePoint{
+		{Date: day(2), Close: 100},
+		{Date: day(3), Close: 110},
+	}
+	if err := repo.SavePrices("MSTR", prices); err != nil {
+		t.Fatalf("Failed to save prices: %v", err)
+	}
+	if err := repo.SavePrices("MSTR", []PricePoint{{Date: day(3), Close: 120}}); err != nil {
+		t.Fatalf("Failed to update prices: %v", err)
+	}
+
+	got, err := repo.GetPrices("MSTR", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get prices: %v", err)
+	}
+	if len(got) != 2 || got[1].Close != 120 {
+		t.Errorf("Expected 2 prices with latest close 120, got %+v", got)
+	}
+
+	latest, err := repo.LatestPrice("MSTR")
+	if err != nil || !latest.Date.Equal(day(3)) {
+		t.Errorf("Expected latest price on 2024-01-03, got %+v (err %v)", latest, err)
+	}
+
+	if _, err := repo.LatestPrice("NOPE"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown symbol, got %v", err)
+	}
+}
+
+func testShares(t *testing.T, repo SharesRepository) {
+	// Shares history and point-in-time lookup
+	records := []models.SharesOutstandingRecord{
+		{Date: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), TotalShares: 100},
+		{Date: time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), TotalShares: 150},
+	}
+	if err := repo.SaveSharesHistory("MSTR", records); err != nil {
+		t.Fatalf("Failed to save shares: %v", err)
+	}
+
+	shares, err := SharesAt(repo, "MSTR", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to get shares: %v", err)
+	}
+	if shares.TotalShares != 100 {
+		t.Errorf("Expected 100 shares on 2024-05-01, got %.0f", shares.TotalShares)
+	}
+
+	if _, err := SharesAt(repo, "MSTR", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before the first record, got %v", err)
+	}
+}
+
+func testTransactions(t *testing.T, repo TransactionRepository) {
+	date := time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)
+
+	// An amended purchase keeps the original version for earlier knowledge dates
+	if err := repo.SaveBTCTransactions("MSTR", []models.BitcoinTransaction{{Date: date, BTCPurchased: 3000}}); err != nil {
+		t.Fatalf("Failed to save transactions: %v", err)
+	}
+	beforeAmendment := time.Now()
+	if err := repo.SaveBTCTransactions("MSTR", []models.BitcoinTransaction{{Date: date, BTCPurchased: 3000.5}}); err != nil {
+		t.Fatalf("Failed to amend transactions: %v", err)
+	}
+
+	current, err := repo.LoadBTCTransactions("MSTR")
+	if err != nil || len(current) != 1 || current[0].BTCPurchased != 3000.5 {
+		t.Errorf("Expected the amended purchase, got %+v (err %v)", current, err)
+	}
+
+	earlier, err := repo.LoadBTCTransactionsAsOf("MSTR", beforeAmendment)
+	if err != nil || len(earlier) != 1 || earlier[0].BTCPurchased != 3000 {
+		t.Errorf("Expected the original purchase before the amendment, got %+v (err %v)", earlier, err)
+	}
+}
+
+func TestSQLiteStoreSealsPortfolios(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(t.TempDir(), "portfolio.key")
+	if err := vault.GenerateKeyFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(vault.KeyFileEnv, keyFile)
+	keyring, _, err := vault.NewKeyring(vault.Secret{KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.SaveKeyring(vault.KeyringPath(filepath.Join(dir, "portfolio")), keyring); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenSQLiteStore(filepath.Join(dir, "mnav.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	date := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+	snapshot := &portfolio.Portfolio{Date: date, Positions: []portfolio.Position{{AccountNumber: "Z23889908", Symbol: "MSTR"}}}
+	if err := store.SavePortfolio(snapshot); err != nil {
+		t.Fatalf("Failed to save portfolio: %v", err)
+	}
+
+	// The stored document is sealed, like the JSON store's snapshot files
+	var document []byte
+	if err := store.db.QueryRow(`SELECT document FROM portfolios`).Scan(&document); err != nil {
+		t.Fatal(err)
+	}
+	if !vault.Sealed(document) || bytes.Contains(document, []byte("Z23889908")) {
+		t.Errorf("Expected a sealed document without the account number, got %q", document)
+	}
+
+	loaded, err := store.LoadPortfolio(date)
+	if err != nil || len(loaded.Positions) != 1 || loaded.Positions[0].AccountNumber != "Z23889908" {
+		t.Errorf("Expected the snapshot to load back, got %+v (err %v)", loaded, err)
+	}
+}
@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Built-in migrations. Version 1 is the first stamped version of every document;
+// append new migrations here when a persisted model changes shape.
+func init() {
+	Register(Migration{
+		Kind:        CompanyData,
+		From:        0,
+		Description: "stamp schema version; replace null fact lists with empty lists",
+		Apply: func(doc map[string]interface{}) error {
+			for _, field := range []string{"sharesHistory", "btcTransactions"} {
+				if doc[field] == nil {
+					doc[field] = []interface{}{}
+				}
+			}
+			return nil
+		},
+	})
+
+	for _, kind := range []Kind{CompanySnapshot, RawFiling, Portfolio, RebalancingConfig, CompanyEvents, FXRates, RebalancingState, AssetClassification, PortfolioAccounts, TaxLots, PortfolioLedger, PortfolioScenarios, PortfolioKeyring} {
+		Register(Migration{
+			Kind:        kind,
+			From:        0,
+			Description: "stamp schema version",
+			Apply:       func(doc map[string]interface{}) error { return nil },
+		})
+	}
+
+	Register(Migration{
+		Kind:        CompanyRegistry,
+		From:        0,
+		Description: "move btcHoldings and btcYield into a BTC treasury asset",
+		Apply: func(doc map[string]interface{}) error {
+			companies, _ := doc["companies"].([]interface{})
+			for _, c := range companies {
+				company, ok := c.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("company entry is not an object")
+				}
+				holdings, hasHoldings := company["btcHoldings"]
+				yield, hasYield := company["btcYield"]
+				delete(company, "btcHoldings")
+				delete(company, "btcYield")
+				if _, ok := company["assets"]; ok || (!hasHoldings && !hasYield) {
+					continue
+				}
+				asset := map[string]interface{}{"symbol": "BTC", "holdings": holdings, "priceSeries": "BTC-USD"}
+				if holdings == nil {
+					asset["holdings"] = 0
+				}
+				if hasYield {
+					asset["yield"] = yield
+				}
+				company["assets"] = []interface{}{asset}
+			}
+			return nil
+		},
+	})
+
+	Register(Migration{
+		Kind:        Portfolio,
+		From:        1,
+		Description: "replace the FBTC/MSTR/GLD allocation fields with asset classes",
+		Apply: func(doc map[string]interface{}) error {
+			allocation, ok := doc["asset_allocation"].(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			classes := map[string]interface{}{}
+			for _, c := range []struct{ class, prefix, symbol string }{
+				{"spot_btc", "fbtc", "FBTC"},
+				{"btc_treasury", "mstr", "MSTR"},
+				{"gold", "gld", "GLD"},
+				{"other", "other", ""},
+			} {
+				value, percent := allocation[c.prefix+"_value"], allocation[c.prefix+"_percent"]
+				delete(allocation, c.prefix+"_value")
+				delete(allocation, c.prefix+"_percent")
+				if number, ok := value.(json.Number); !ok || number.String() == "0" {
+					continue
+				}
+				symbols := map[string]interface{}{}
+				if c.symbol != "" {
+					symbols[c.symbol] = value
+				}
+				classes[c.class] = map[string]interface{}{"value": value, "percent": percent, "symbols": symbols}
+			}
+			allocation["classes"] = classes
+			allocation["spot_treasury_ratio"] = allocation["fbtc_mstr_ratio"]
+			delete(allocation, "fbtc_mstr_ratio")
+			return nil
+		},
+	})
+}
@@ -0,0 +1,203 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Kind identifies a type of persisted document
+type Kind string
+
+// Persisted document kinds
+const (
+	CompanyData         Kind = "company_data"         // data/edgar/companies/{SYM}/financial_data.json
+	CompanySnapshot     Kind = "company_snapshot"     // data/edgar/companies/{SYM}/latest_snapshot.json
+	RawFiling           Kind = "raw_filing"           // data/edgar/companies/{SYM}/raw_filings/*.json
+	Portfolio           Kind = "portfolio"            // data/portfolio/processed/portfolio_*.json
+	RebalancingConfig   Kind = "rebalancing_config"   // configs/rebalancing/*.json
+	CompanyEvents       Kind = "company_events"       // data/events/{SYM}.json
+	CompanyRegistry     Kind = "company_registry"     // data/companies.json
+	FXRates             Kind = "fx_rates"             // data/fx/{CUR}.json
+	RebalancingState    Kind = "rebalancing_state"    // data/portfolio/processed/rebalancing_state.json
+	AssetClassification Kind = "asset_classification" // configs/portfolio/asset_classes.json
+	PortfolioAccounts   Kind = "portfolio_accounts"   // configs/portfolio/accounts.json
+	TaxLots             Kind = "tax_lots"             // data/portfolio/processed/tax_lots.json
+	PortfolioLedger     Kind = "portfolio_ledger"     // data/portfolio/processed/ledger.json
+	PortfolioScenarios  Kind = "portfolio_scenarios"  // configs/portfolio/scenarios.json
+	PortfolioKeyring    Kind = "portfolio_keyring"    // data/portfolio/keyring.json
+)
+
+// Migration upgrades a document of one kind from version From to From+1. Documents
+// without a version field are version 0. For example, adding a field to
+// BitcoinTransaction that needs a default would register a CompanyData migration
+// that walks btcTransactions and fills it in.
+type Migration struct {
+	Kind        Kind
+	From        int
+	Description string
+	Apply       func(doc map[string]interface{}) error
+}
+
+// kindInfo tracks the version field and registered migrations for a kind
+type kindInfo struct {
+	field      string
+	current    int
+	migrations map[int]Migration
+}
+
+var registry = map[Kind]*kindInfo{
+	CompanyData:         {field: "schemaVersion"},
+	CompanySnapshot:     {field: "schemaVersion"},
+	RawFiling:           {field: "schemaVersion"},
+	Portfolio:           {field: "schema_version"},
+	RebalancingConfig:   {field: "schema_version"},
+	CompanyEvents:       {field: "schemaVersion"},
+	CompanyRegistry:     {field: "schemaVersion"},
+	FXRates:             {field: "schemaVersion"},
+	RebalancingState:    {field: "schema_version"},
+	AssetClassification: {field: "schema_version"},
+	PortfolioAccounts:   {field: "schema_version"},
+	TaxLots:             {field: "schema_version"},
+	PortfolioLedger:     {field: "schema_version"},
+	PortfolioScenarios:  {field: "schema_version"},
+	PortfolioKeyring:    {field: "schema_version"},
+}
+
+// Register adds a forward migration. The current version of a kind is one past its
+// newest migration, so registering From=N makes N+1 the version written on save.
+func Register(m Migration) {
+	info, ok := registry[m.Kind]
+	if !ok {
+		panic(fmt.Sprintf("schema: unknown document kind %q", m.Kind))
+	}
+	if info.migrations == nil {
+		info.migrations = make(map[int]Migration)
+	}
+	if _, exists := info.migrations[m.From]; exists {
+		panic(fmt.Sprintf("schema: duplicate %s migration from version %d", m.Kind, m.From))
+	}
+	info.migrations[m.From] = m
+	if m.From+1 > info.current {
+		info.current = m.From + 1
+	}
+}
+
+// Kinds returns all registered document kinds
+func Kinds() []Kind {
+	kinds := make([]Kind, 0, len(registry))
+	for kind := range registry {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// CurrentVersion returns the schema version this code writes for a kind
+func CurrentVersion(kind Kind) int {
+	if info, ok := registry[kind]; ok {
+		return info.current
+	}
+	return 0
+}
+
+// VersionField returns the JSON field holding a kind's schema version
+func VersionField(kind Kind) string {
+	if info, ok := registry[kind]; ok {
+		return info.field
+	}
+	return "schemaVersion"
+}
+
+// Version reads the schema version of a decoded document; missing means 0
+func Version(kind Kind, doc map[string]interface{}) int {
+	switch v := doc[VersionField(kind)].(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Pending returns the migrations needed to bring a document at version from up to date
+func Pending(kind Kind, from int) ([]Migration, error) {
+	info, ok := registry[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown document kind %q", kind)
+	}
+	if from > info.current {
+		return nil, fmt.Errorf("%s schema version %d is newer than supported version %d", kind, from, info.current)
+	}
+
+	var pending []Migration
+	for v := from; v < info.current; v++ {
+		m, ok := info.migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no %s migration from version %d", kind, v)
+		}
+		pending = append(pending, m)
+	}
+	return pending, nil
+}
+
+// Migrate upgrades a decoded document in place, stamping the version after each step
+func Migrate(kind Kind, doc map[string]interface{}) ([]Migration, error) {
+	pending, err := Pending(kind, Version(kind, doc))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range pending {
+		if err := m.Apply(doc); err != nil {
+			return nil, fmt.Errorf("%s migration %d→%d (%s) failed: %w", kind, m.From, m.From+1, m.Description, err)
+		}
+		doc[VersionField(kind)] = m.From + 1
+	}
+	return pending, nil
+}
+
+// Decode parses a JSON object keeping numbers exact
+func Decode(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("document is not a JSON object")
+	}
+	return doc, nil
+}
+
+// Upgrade returns data migrated to the current schema version. Up-to-date documents
+// are returned unchanged; documents from a newer version are rejected.
+func Upgrade(kind Kind, data []byte) ([]byte, error) {
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	version := 0
+	if raw, ok := header[VersionField(kind)]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", VersionField(kind), err)
+		}
+	}
+	if version == CurrentVersion(kind) {
+		return data, nil
+	}
+
+	doc, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Migrate(kind, doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
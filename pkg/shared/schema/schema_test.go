@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestUpgradeCompanyData(t *testing.T) {
+	legacy := []byte(`{"symbol":"MSTR","sharesHistory":null,"btcTransactions":[{"btcPurchased":12345678901}]}`)
+
+	upgraded, err := Upgrade(CompanyData, legacy)
+	if err != nil {
+		t.Fatalf("Failed to upgrade: %v", err)
+	}
+
+	var doc struct {
+		SchemaVersion   int               `json:"schemaVersion"`
+		SharesHistory   []json.RawMessage `json:"sharesHistory"`
+		BTCTransactions []struct {
+			BTCPurchased float64 `json:"btcPurchased"`
+		} `json:"btcTransactions"`
+	}
+	if err := json.Unmarshal(upgraded, &doc); err != nil {
+		t.Fatalf("Failed to parse upgraded document: %v", err)
+	}
+
+	if doc.SchemaVersion != CurrentVersion(CompanyData) {
+		t.Errorf("Expected schema version %d, got %d", CurrentVersion(CompanyData), doc.SchemaVersion)
+	}
+	if doc.SharesHistory == nil {
+		t.Errorf("Expected null sharesHistory to become an empty list")
+	}
+	if len(doc.BTCTransactions) != 1 || doc.BTCTransactions[0].BTCPurchased != 12345678901 {
+		t.Errorf("Expected transaction to survive unchanged, got %+v", doc.BTCTransactions)
+	}
+
+	// Current documents are returned as-is
+	again, err := Upgrade(CompanyData, upgraded)
+	if err != nil || string(again) != string(upgraded) {
+		t.Errorf("Expected current document to be unchanged (err %v)", err)
+	}
+}
+
+func TestUpgradeRejectsNewerVersion(t *testing.T) {
+	if _, err := Upgrade(Portfolio, []byte(`{"schema_version": 999}`)); err == nil {
+		t.Errorf("Expected an error for a document from a newer schema version")
+	}
+}
+
+func TestUpgradeCompanyRegistry(t *testing.T) {
+	legacy := []byte(`{"companies":[
+		{"symbol":"MSTR","btcHoldings":214400,"btcYield":0.0012},
+		{"symbol":"ETHX","assets":[{"symbol":"ETH","holdings":500}]}
+	]}`)
+
+	upgraded, err := Upgrade(CompanyRegistry, legacy)
+	if err != nil {
+		t.Fatalf("Failed to upgrade: %v", err)
+	}
+
+	var doc struct {
+		SchemaVersion int `json:"schemaVersion"`
+		Companies     []map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(upgraded, &doc); err != nil {
+		t.Fatalf("Failed to parse upgraded document: %v", err)
+	}
+
+	if doc.SchemaVersion != CurrentVersion(CompanyRegistry) {
+		t.Errorf("Expected schema version %d, got %d", CurrentVersion(CompanyRegistry), doc.SchemaVersion)
+	}
+	if _, ok := doc.Companies[0]["btcHoldings"]; ok {
+		t.Errorf("Expected btcHoldings to be removed")
+	}
+	compact := func(raw json.RawMessage) string {
+		var buf bytes.Buffer
+		json.Compact(&buf, raw)
+		return buf.String()
+	}
+	want := `[{"holdings":214400,"priceSeries":"BTC-USD","symbol":"BTC","yield":0.0012}]`
+	if got := compact(doc.Companies[0]["assets"]); got != want {
+		t.Errorf("Expected assets %s, got %s", want, got)
+	}
+	if got := compact(doc.Companies[1]["assets"]); got != `[{"holdings":500,"symbol":"ETH"}]` {
+		t.Errorf("Expected existing assets to be kept, got %s", got)
+	}
+}
+
+func TestUpgradePortfolioAllocationToClasses(t *testing.T) {
+	legacy := []byte(`{"schema_version":1,"asset_allocation":{"fbtc_value":9000,"fbtc_percent":75,"mstr_value":3000,"mstr_percent":25,"gld_value":0,"gld_percent":0,"other_value":0,"other_percent":0,"bitcoin_exposure":12000,"fbtc_mstr_ratio":3}}`)
+
+	upgraded, err := Upgrade(Portfolio, legacy)
+	if err != nil {
+		t.Fatalf("Failed to upgrade: %v", err)
+	}
+
+	var doc struct {
+		AssetAllocation map[string]json.RawMessage `json:"asset_allocation"`
+	}
+	if err := json.Unmarshal(upgraded, &doc); err != nil {
+		t.Fatalf("Failed to parse upgraded document: %v", err)
+	}
+	var classes map[string]struct {
+		Value   float64            `json:"value"`
+		Percent float64            `json:"percent"`
+		Symbols map[string]float64 `json:"symbols"`
+	}
+	if err := json.Unmarshal(doc.AssetAllocation["classes"], &classes); err != nil {
+		t.Fatalf("Failed to parse classes: %v", err)
+	}
+	if len(classes) != 2 || classes["spot_btc"].Symbols["FBTC"] != 9000 || classes["btc_treasury"].Percent != 25 {
+		t.Errorf("Unexpected classes %+v", classes)
+	}
+	if string(doc.AssetAllocation["spot_treasury_ratio"]) != "3" || doc.AssetAllocation["fbtc_value"] != nil {
+		t.Errorf("Expected the ratio to move and the old fields to go, got %v", doc.AssetAllocation)
+	}
+}
@@ -2,13 +2,16 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"time"
 
 	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
 )
 
 // CompanyDataStorage manages storage of company financial data
@@ -23,8 +26,51 @@ func NewCompanyDataStorage(baseDir string) *CompanyDataStorage {
 	}
 }
 
-// SaveCompanyData saves company financial data to JSON file
+// companyLock returns the lock file guarding a company's data
+func (s *CompanyDataStorage) companyLock(symbol string) string {
+	return filepath.Join(s.baseDir, symbol, ".lock")
+}
+
+// SaveCompanyData saves company financial data to JSON file. Facts that differ from
+// the stored version are kept as superseded versions rather than overwritten.
 func (s *CompanyDataStorage) SaveCompanyData(data *models.CompanyFinancialData) error {
+	return WithLock(s.companyLock(data.Symbol), func() error {
+		return s.saveCompanyDataLocked(data)
+	})
+}
+
+// UpdateCompanyData loads, modifies and saves a company's data while holding its lock,
+// so concurrent updates are not lost. Missing data starts as an empty record; unreadable
+// data is an error, so it is never overwritten.
+func (s *CompanyDataStorage) UpdateCompanyData(symbol string, update func(data *models.CompanyFinancialData) error) error {
+	return WithLock(s.companyLock(symbol), func() error {
+		data, err := s.LoadCompanyData(symbol)
+		if errors.Is(err, fs.ErrNotExist) {
+			data = &models.CompanyFinancialData{Symbol: symbol}
+		} else if err != nil {
+			return err
+		}
+
+		if err := update(data); err != nil {
+			return err
+		}
+		return s.saveCompanyDataLocked(data)
+	})
+}
+
+// saveCompanyDataLocked writes company data; the caller must hold the company lock
+func (s *CompanyDataStorage) saveCompanyDataLocked(data *models.CompanyFinancialData) error {
+	// Without previous data this is a first save, and every fact is known from now. Data
+	// that exists but can't be read fails the save rather than losing its history.
+	previous, err := s.LoadCompanyData(data.Symbol)
+	if errors.Is(err, fs.ErrNotExist) {
+		previous = nil
+	} else if err != nil {
+		return err
+	}
+	data.Revise(previous, time.Now())
+	data.SchemaVersion = schema.CurrentVersion(schema.CompanyData)
+
 	// Create company directory
 	companyDir := filepath.Join(s.baseDir, data.Symbol)
 	if err := os.MkdirAll(companyDir, 0755); err != nil {
@@ -38,7 +84,7 @@ func (s *CompanyDataStorage) SaveCompanyData(data *models.CompanyFinancialData)
 		return fmt.Errorf("failed to marshal company data: %w", err)
 	}
 
-	if err := os.WriteFile(dataPath, dataBytes, 0644); err != nil {
+	if err := WriteFileAtomic(dataPath, dataBytes, 0644); err != nil {
 		return fmt.Errorf("failed to write company data: %w", err)
 	}
 
@@ -50,7 +96,7 @@ func (s *CompanyDataStorage) LoadCompanyData(symbol string) (*models.CompanyFina
 	dataPath := filepath.Join(s.baseDir, symbol, "financial_data.json")
 
 	if _, err := os.Stat(dataPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("no data found for symbol %s", symbol)
+		return nil, fmt.Errorf("no data found for symbol %s: %w", symbol, fs.ErrNotExist)
 	}
 
 	dataBytes, err := os.ReadFile(dataPath)
@@ -58,6 +104,11 @@ func (s *CompanyDataStorage) LoadCompanyData(symbol string) (*models.CompanyFina
 		return nil, fmt.Errorf("failed to read company data: %w", err)
 	}
 
+	dataBytes, err = schema.Upgrade(schema.CompanyData, dataBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade company data: %w", err)
+	}
+
 	var companyData models.CompanyFinancialData
 	if err := json.Unmarshal(dataBytes, &companyData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal company data: %w", err)
@@ -66,50 +117,45 @@ func (s *CompanyDataStorage) LoadCompanyData(symbol string) (*models.CompanyFina
 	return &companyData, nil
 }
 
-// AddSharesRecord adds a new shares outstanding record to the company data
-func (s *CompanyDataStorage) AddSharesRecord(symbol string, record *models.SharesOutstandingRecord) error {
+// LoadCompanyDataAsOf loads company financial data as it was known at the given time
+func (s *CompanyDataStorage) LoadCompanyDataAsOf(symbol string, asOf time.Time) (*models.CompanyFinancialData, error) {
 	data, err := s.LoadCompanyData(symbol)
 	if err != nil {
-		// Create new company data if it doesn't exist
-		data = &models.CompanyFinancialData{
-			Symbol:        symbol,
-			SharesHistory: []models.SharesOutstandingRecord{},
-		}
+		return nil, err
 	}
+	return data.AsOfKnowledge(asOf), nil
+}
+
+// AddSharesRecord adds a new shares outstanding record to the company data
+func (s *CompanyDataStorage) AddSharesRecord(symbol string, record *models.SharesOutstandingRecord) error {
+	return s.UpdateCompanyData(symbol, func(data *models.CompanyFinancialData) error {
+		// Add the new record
+		data.SharesHistory = append(data.SharesHistory, *record)
 
-	// Add the new record
-	data.SharesHistory = append(data.SharesHistory, *record)
+		// Sort by date
+		sort.Slice(data.SharesHistory, func(i, j int) bool {
+			return data.SharesHistory[i].Date.Before(data.SharesHistory[j].Date)
+		})
 
-	// Sort by date
-	sort.Slice(data.SharesHistory, func(i, j int) bool {
-		return data.SharesHistory[i].Date.Before(data.SharesHistory[j].Date)
+		data.LastUpdated = time.Now()
+		return nil
 	})
-
-	data.LastUpdated = time.Now()
-	return s.SaveCompanyData(data)
 }
 
 // AddBitcoinTransaction adds a new Bitcoin transaction to the company data
 func (s *CompanyDataStorage) AddBitcoinTransaction(symbol string, transaction *models.BitcoinTransaction) error {
-	data, err := s.LoadCompanyData(symbol)
-	if err != nil {
-		// Create new company data if it doesn't exist
-		data = &models.CompanyFinancialData{
-			Symbol:          symbol,
-			BTCTransactions: []models.BitcoinTransaction{},
-		}
-	}
+	return s.UpdateCompanyData(symbol, func(data *models.CompanyFinancialData) error {
+		// Add the new transaction
+		data.BTCTransactions = append(data.BTCTransactions, *transaction)
 
-	// Add the new transaction
-	data.BTCTransactions = append(data.BTCTransactions, *transaction)
+		// Sort by date
+		sort.Slice(data.BTCTransactions, func(i, j int) bool {
+			return data.BTCTransactions[i].Date.Before(data.BTCTransactions[j].Date)
+		})
 
-	// Sort by date
-	sort.Slice(data.BTCTransactions, func(i, j int) bool {
-		return data.BTCTransactions[i].Date.Before(data.BTCTransactions[j].Date)
+		data.LastUpdated = time.Now()
+		return nil
 	})
-
-	data.LastUpdated = time.Now()
-	return s.SaveCompanyData(data)
 }
 
 // GetLatestShares returns the most recent shares outstanding for a company
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+)
+
+// EventStorage manages the user-editable company events files ({SYM}.json)
+type EventStorage struct {
+	baseDir string
+}
+
+// NewEventStorage creates an event storage rooted at baseDir (normally data/events)
+func NewEventStorage(baseDir string) *EventStorage {
+	return &EventStorage{baseDir: baseDir}
+}
+
+// eventsPath returns the events file for a company
+func (s *EventStorage) eventsPath(symbol string) string {
+	return filepath.Join(s.baseDir, symbol+".json")
+}
+
+// LoadEvents loads a company's events. A missing file is an empty list.
+func (s *EventStorage) LoadEvents(symbol string) (*models.CompanyEvents, error) {
+	path := s.eventsPath(symbol)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &models.CompanyEvents{Symbol: symbol}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	data, err = schema.Upgrade(schema.CompanyEvents, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade events: %w", err)
+	}
+
+	var events models.CompanyEvents
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if events.Symbol == "" {
+		events.Symbol = symbol
+	}
+	for i := range events.Events {
+		if events.Events[i].Source == "" {
+			events.Events[i].Source = "user"
+		}
+	}
+	return &events, nil
+}
+
+// SaveEvents writes a company's events sorted by date
+func (s *EventStorage) SaveEvents(events *models.CompanyEvents) error {
+	path := s.eventsPath(events.Symbol)
+	if err := os.MkdirAll(s.baseDir, 0755); err != nil {
+		return fmt.Errorf("failed to create events directory: %w", err)
+	}
+
+	sort.SliceStable(events.Events, func(i, j int) bool {
+		return events.Events[i].Date.Before(events.Events[j].Date)
+	})
+	events.SchemaVersion = schema.CurrentVersion(schema.CompanyEvents)
+
+	return WithLock(path+".lock", func() error {
+		return WriteJSONAtomic(path, events)
+	})
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+	"github.com/ultrarare-tech/mNAV/pkg/shared/schema"
+)
+
+// FXStorage manages the local exchange rate store, one file per currency ({CUR}.json)
+type FXStorage struct {
+	baseDir string
+}
+
+// NewFXStorage creates an FX rate storage rooted at baseDir (normally data/fx)
+func NewFXStorage(baseDir string) *FXStorage {
+	return &FXStorage{baseDir: baseDir}
+}
+
+// ratesPath returns the rates file for a currency
+func (s *FXStorage) ratesPath(currency string) string {
+	return filepath.Join(s.baseDir, models.CurrencyCode(currency)+".json")
+}
+
+// LoadRates loads a currency's USD rate history. A missing file is an empty history.
+func (s *FXStorage) LoadRates(currency string) (*models.FXRates, error) {
+	currency = models.CurrencyCode(currency)
+	path := s.ratesPath(currency)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &models.FXRates{Currency: currency}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s rates: %w", currency, err)
+	}
+
+	data, err = schema.Upgrade(schema.FXRates, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade %s rates: %w", currency, err)
+	}
+
+	var rates models.FXRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if rates.Currency == "" {
+		rates.Currency = currency
+	}
+	return &rates, nil
+}
+
+// SaveRates writes a currency's rate history
+func (s *FXStorage) SaveRates(rates *models.FXRates) error {
+	rates.Currency = models.CurrencyCode(rates.Currency)
+	path := s.ratesPath(rates.Currency)
+	if err := os.MkdirAll(s.baseDir, 0755); err != nil {
+		return fmt.Errorf("failed to create fx directory: %w", err)
+	}
+
+	rates.SchemaVersion = schema.CurrentVersion(schema.FXRates)
+	rates.UpdatedAt = time.Now()
+
+	return WithLock(path+".lock", func() error {
+		return WriteJSONAtomic(path, rates)
+	})
+}
+
+// LoadTable loads the given currencies into a conversion table, or every stored
+// currency when none are given. Requested currencies without a file are left out,
+// so conversions involving them report the missing rates.
+func (s *FXStorage) LoadTable(currencies ...string) (*models.FXTable, error) {
+	if len(currencies) == 0 {
+		files, err := filepath.Glob(filepath.Join(s.baseDir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			currencies = append(currencies, strings.TrimSuffix(filepath.Base(file), ".json"))
+		}
+	}
+
+	table := models.NewFXTable()
+	for _, currency := range currencies {
+		if models.CurrencyCode(currency) == models.USD {
+			continue
+		}
+		rates, err := s.LoadRates(currency)
+		if err != nil {
+			return nil, err
+		}
+		table.Add(rates)
+	}
+	return table, nil
+}
@@ -0,0 +1,42 @@
+//go:build !unix && !windows
+
+package storage
+
+import (
+	"os"
+	"sync"
+)
+
+// Platforms without file locking fall back to an in-process lock per path
+var (
+	fallbackMu    sync.Mutex
+	fallbackLocks = make(map[string]*sync.Mutex)
+)
+
+func lockFile(f *os.File) error {
+	fallbackMu.Lock()
+	mu, ok := fallbackLocks[f.Name()]
+	if !ok {
+		mu = &sync.Mutex{}
+		fallbackLocks[f.Name()] = mu
+	}
+	fallbackMu.Unlock()
+
+	mu.Lock()
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	fallbackMu.Lock()
+	mu := fallbackLocks[f.Name()]
+	fallbackMu.Unlock()
+
+	if mu != nil {
+		mu.Unlock()
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
@@ -50,12 +50,13 @@ func (s *TransactionStorage) SaveBTCTransactions(symbol string, transactions []m
 		return fmt.Errorf("error marshaling transactions: %w", err)
 	}
 
-	// Write the file
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("error writing file: %w", err)
-	}
-
-	return nil
+	// Write the file atomically while holding the per-company lock
+	return WithLock(filePath+".lock", func() error {
+		if err := WriteFileAtomic(filePath, data, 0644); err != nil {
+			return fmt.Errorf("error writing file: %w", err)
+		}
+		return nil
+	})
 }
 
 // LoadBTCTransactions loads Bitcoin transactions from a JSON file
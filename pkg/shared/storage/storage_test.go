@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ultrarare-tech/mNAV/pkg/shared/models"
+)
+
+const (
+	writerProcesses   = 4
+	writesPerProcess  = 10
+	writerGoroutines  = 8
+	writesPerRoutine  = 10
+	helperProcessFlag = "MNAV_STORAGE_HELPER_DIR"
+)
+
+// addTransactions appends count distinct transactions for writer id
+func addTransactions(s *CompanyDataStorage, id, count int) error {
+	for i := 0; i < count; i++ {
+		tx := &models.BitcoinTransaction{
+			Date:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, id*count+i),
+			FilingType:   "8-K",
+			BTCPurchased: float64(id*1000 + i),
+		}
+		if err := s.AddBitcoinTransaction("MSTR", tx); err != nil {
+			return fmt.Errorf("writer %d: failed to add transaction: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// TestHelperProcess is run as a separate writer process by TestConcurrentWriters
+func TestHelperProcess(t *testing.T) {
+	dir := os.Getenv(helperProcessFlag)
+	if dir == "" {
+		t.Skip("helper process only")
+	}
+	id, _ := strconv.Atoi(os.Getenv(helperProcessFlag + "_ID"))
+	if err := addTransactions(NewCompanyDataStorage(dir), id, writesPerProcess); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	s := NewCompanyDataStorage(dir)
+	dataPath := filepath.Join(dir, "MSTR", "financial_data.json")
+
+	// Readers must never see a torn file while writers are running
+	done := make(chan struct{})
+	readErrs := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				close(readErrs)
+				return
+			default:
+			}
+			data, err := os.ReadFile(dataPath)
+			if os.IsNotExist(err) {
+				continue
+			}
+			var company models.CompanyFinancialData
+			if err == nil {
+				err = json.Unmarshal(data, &company)
+			}
+			if err != nil {
+				readErrs <- fmt.Errorf("torn read: %w", err)
+				close(readErrs)
+				return
+			}
+		}
+	}()
+
+	// Writers in other processes
+	var procs []*exec.Cmd
+	for p := 0; p < writerProcesses; p++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		cmd.Env = append(os.Environ(),
+			helperProcessFlag+"="+dir,
+			fmt.Sprintf("%s_ID=%d", helperProcessFlag, p))
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start writer process: %v", err)
+		}
+		procs = append(procs, cmd)
+	}
+
+	// Writers in this process
+	var wg sync.WaitGroup
+	for g := 0; g < writerGoroutines; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if err := addTransactions(s, writerProcesses+id, writesPerRoutine); err != nil {
+				t.Error(err)
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	for _, cmd := range procs {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("Writer process failed: %v", err)
+		}
+	}
+	close(done)
+	if err := <-readErrs; err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.LoadCompanyData("MSTR")
+	if err != nil {
+		t.Fatalf("Failed to load company data: %v", err)
+	}
+
+	expected := writerProcesses*writesPerProcess + writerGoroutines*writesPerRoutine
+	if len(data.BTCTransactions) != expected {
+		t.Errorf("Expected %d transactions, got %d (lost updates)", expected, len(data.BTCTransactions))
+	}
+
+	// No temp files should be left behind
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "MSTR", ".financial_data.json.tmp-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("Expected no temp files, found %v", leftovers)
+	}
+}
+
+func TestUnreadableCompanyDataIsNotOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	s := NewCompanyDataStorage(dir)
+	dataPath := filepath.Join(dir, "MSTR", "financial_data.json")
+
+	corrupt := []byte(`{"symbol": "MSTR", "btcTransactions": [`)
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dataPath, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &models.BitcoinTransaction{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), BTCPurchased: 1}
+	if err := s.AddBitcoinTransaction("MSTR", tx); err == nil {
+		t.Error("Expected UpdateCompanyData to fail on corrupt data")
+	}
+	if err := s.SaveCompanyData(&models.CompanyFinancialData{Symbol: "MSTR"}); err == nil {
+		t.Error("Expected SaveCompanyData to fail on corrupt data")
+	}
+
+	if got, err := os.ReadFile(dataPath); err != nil || string(got) != string(corrupt) {
+		t.Errorf("Expected the corrupt file to be left untouched, got %q (%v)", got, err)
+	}
+
+	// A symbol with no data yet still starts empty
+	if err := s.AddBitcoinTransaction("NEW", tx); err != nil {
+		t.Errorf("Expected a first save to succeed, got %v", err)
+	}
+}
+
+func TestFXStorageRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFXStorage(dir)
+
+	empty, err := s.LoadRates("jpy")
+	if err != nil || empty.Currency != "JPY" || len(empty.Rates) != 0 {
+		t.Fatalf("expected an empty JPY history, got %+v (%v)", empty, err)
+	}
+
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	empty.Merge([]models.FXRate{{Date: day, USDPerUnit: 0.007}})
+	if err := s.SaveRates(empty); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := s.LoadTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := table.Convert(1000, "JPY", "USD", day); err != nil || got != 7 {
+		t.Errorf("expected 1000 JPY = 7 USD from the stored rate, got %f (%v)", got, err)
+	}
+}